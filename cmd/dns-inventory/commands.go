@@ -0,0 +1,595 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/NeonSludge/ansible-dns-inventory/internal/build"
+	"github.com/NeonSludge/ansible-dns-inventory/internal/config"
+	"github.com/NeonSludge/ansible-dns-inventory/internal/cron"
+	"github.com/NeonSludge/ansible-dns-inventory/internal/server"
+	"github.com/NeonSludge/ansible-dns-inventory/internal/util"
+	"github.com/NeonSludge/ansible-dns-inventory/pkg/inventory"
+)
+
+// loadHosts acquires host records, or merges the partial exports listed in -merge, and loads them into the inventory tree.
+func loadHosts(inv *inventory.Inventory, opts *options) (map[string][]*inventory.HostAttributes, error) {
+	var hosts map[string][]*inventory.HostAttributes
+	var err error
+
+	if len(opts.merge) > 0 {
+		hosts, err = readMergeFiles(inv, opts)
+	} else {
+		// Acquire and parse host TXT records.
+		hosts, err = inv.GetHosts()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hosts) == 0 {
+		return nil, errors.New("no host records found")
+	}
+
+	// Load host records into the inventory tree.
+	if err := inv.ImportHosts(hosts); err != nil {
+		return nil, err
+	}
+
+	return hosts, nil
+}
+
+// output marshals v and writes it to stdout.
+func output(v interface{}, format string, inv *inventory.Inventory) error {
+	bytes, err := util.Marshal(inv.Redact(v), format, inv.Config)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(bytes))
+
+	return nil
+}
+
+// runVersion displays version and build info.
+func runVersion(_ *inventory.Inventory, _ *options) error {
+	info := build.Get()
+
+	fmt.Println("version:", info.Version)
+	fmt.Println("build time:", info.Time)
+	fmt.Println("revision:", info.Revision)
+	fmt.Println("dirty:", info.Dirty)
+	fmt.Println("go version:", info.GoVersion)
+
+	return nil
+}
+
+// readImportFile reads host attributes from the import file.
+func readImportFile(inv *inventory.Inventory, opts *options) (map[string][]*inventory.HostAttributes, error) {
+	hosts := make(map[string][]*inventory.HostAttributes)
+
+	importFile, err := os.ReadFile(opts.importFile)
+	if err != nil {
+		return nil, err
+	}
+
+	switch opts.importFormat {
+	case "yaml":
+		if err := yaml.Unmarshal(importFile, hosts); err != nil {
+			return nil, err
+		}
+	case "ansible":
+		if hosts, err = inv.ParseAnsibleInventory(importFile); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("unsupported import format: %s", opts.importFormat)
+	}
+
+	return hosts, nil
+}
+
+// readMergeFiles reads the partial 'attrs' exports listed in -merge and merges them.
+func readMergeFiles(inv *inventory.Inventory, opts *options) (map[string][]*inventory.HostAttributes, error) {
+	parts := make([]map[string][]*inventory.HostAttributes, 0)
+
+	for _, path := range strings.Split(opts.merge, ",") {
+		data, err := os.ReadFile(strings.TrimSpace(path))
+		if err != nil {
+			return nil, err
+		}
+
+		// JSON exports are valid YAML.
+		part := make(map[string][]*inventory.HostAttributes)
+		if err := yaml.Unmarshal(data, part); err != nil {
+			return nil, errors.Wrapf(err, "%s: partial export parsing failure", path)
+		}
+
+		parts = append(parts, part)
+	}
+
+	return inv.MergeHosts(parts)
+}
+
+// runImport imports host records from a file.
+func runImport(inv *inventory.Inventory, opts *options) error {
+	log := inv.Logger
+
+	hosts, err := readImportFile(inv, opts)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("importing hosts from file: %s", opts.importFile)
+
+	_, err = inv.PublishHostsBulk(hosts, opts.state)
+
+	return err
+}
+
+// runHost produces a JSON dictionary of host variables for Ansible.
+func runHost(inv *inventory.Inventory, opts *options) error {
+	cfg := inv.Config
+
+	if !cfg.Txt.Vars.Enabled && !cfg.Varsources.Enabled && !cfg.Services.Enabled {
+		fmt.Println("{}")
+		return nil
+	}
+
+	// Acquire host variables.
+	vars, err := inv.ExportHostVariables(opts.host)
+	if err != nil {
+		return err
+	}
+
+	return output(vars, "json", inv)
+}
+
+// runList produces a JSON inventory for Ansible.
+func runList(inv *inventory.Inventory, opts *options) error {
+	if _, err := loadHosts(inv, opts); err != nil {
+		return err
+	}
+
+	export := make(map[string]*inventory.AnsibleGroup)
+
+	// Export the inventory tree into a map.
+	inv.ExportInventory(export)
+
+	// Marshal the map into a JSON representation of an Ansible inventory.
+	return output(export, "json", inv)
+}
+
+// runDefault builds the inventory and exports an empty host list. It only fails if the inventory cannot be built, which keeps
+// scripts relying on the command line without mode flags working.
+func runDefault(inv *inventory.Inventory, opts *options) error {
+	if _, err := loadHosts(inv, opts); err != nil {
+		return err
+	}
+
+	return output(make(map[string][]string), opts.format, inv)
+}
+
+// runHosts exports hosts, mapping each one to a list of groups.
+func runHosts(inv *inventory.Inventory, opts *options) error {
+	if _, err := loadHosts(inv, opts); err != nil {
+		return err
+	}
+
+	export := make(map[string][]string)
+	inv.ExportHosts(export)
+
+	return output(export, opts.format, inv)
+}
+
+// runGroups exports groups, mapping each one to a list of hosts.
+func runGroups(inv *inventory.Inventory, opts *options) error {
+	if _, err := loadHosts(inv, opts); err != nil {
+		return err
+	}
+
+	export := make(map[string][]string)
+	inv.ExportGroups(export)
+
+	return output(export, opts.format, inv)
+}
+
+// runAttrs exports hosts, mapping each one to a list of dictionaries of attributes.
+func runAttrs(inv *inventory.Inventory, opts *options) error {
+	hosts, err := loadHosts(inv, opts)
+	if err != nil {
+		return err
+	}
+
+	return output(hosts, opts.format, inv)
+}
+
+// runTree exports the raw inventory tree.
+func runTree(inv *inventory.Inventory, opts *options) error {
+	if _, err := loadHosts(inv, opts); err != nil {
+		return err
+	}
+
+	return output(inv.Tree, opts.format, inv)
+}
+
+// runSplit writes an inventory file for every value of the split key into a directory and exports the number of hosts written to every file.
+// Every file is built from the hosts having the value only, and files are replaced atomically.
+func runSplit(inv *inventory.Inventory, opts *options) error {
+	cfg := inv.Config
+	log := inv.Logger
+
+	if strings.Count(cfg.Split.Name, "%s") != 1 || strings.ContainsAny(cfg.Split.Name, `/\`) {
+		return errors.Errorf("invalid split file name pattern: %s", cfg.Split.Name)
+	}
+
+	// Build the complete inventory first, so that thresholds and transforms are checked against all hosts.
+	hosts, err := loadHosts(inv, opts)
+	if err != nil {
+		return err
+	}
+
+	parts, err := inv.SplitHosts(hosts)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(opts.split, 0755); err != nil {
+		return err
+	}
+
+	written := make(map[string]int)
+	for value, part := range parts {
+		inv.Tree = inventory.NewTree()
+		if err := inv.ImportHosts(part); err != nil {
+			return errors.Wrapf(err, "%s: inventory building failure", value)
+		}
+
+		data, err := util.Marshal(inv.Tree, cfg.Split.Format, cfg)
+		if err != nil {
+			return err
+		}
+
+		path, err := inv.SplitPath(opts.split, value)
+		if err != nil {
+			return err
+		}
+		name := filepath.Base(path)
+		tmp := path + ".tmp"
+
+		err = os.WriteFile(tmp, data, 0644)
+		if err == nil {
+			err = os.Rename(tmp, path)
+		}
+		if err != nil {
+			os.Remove(tmp)
+			return err
+		}
+
+		written[name] = len(part)
+	}
+
+	if cfg.Split.Prune {
+		stale, err := filepath.Glob(filepath.Join(opts.split, strings.Replace(cfg.Split.Name, "%s", "*", 1)))
+		if err != nil {
+			return err
+		}
+
+		for _, path := range stale {
+			if _, ok := written[filepath.Base(path)]; !ok {
+				if err := os.Remove(path); err != nil {
+					return err
+				}
+
+				log.Infof("stale inventory file removed: %s", path)
+			}
+		}
+	}
+
+	log.Infof("%d inventory files written to %s", len(written), opts.split)
+
+	return output(written, opts.format, inv)
+}
+
+// runRecords exports raw host records as returned by the datasource, without parsing or filtering them.
+func runRecords(inv *inventory.Inventory, opts *options) error {
+	records, err := inv.Datasource.GetAllRecords()
+	if err != nil {
+		return &inventory.DatasourceError{Err: errors.Wrap(err, "record loading failure")}
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Hostname != records[j].Hostname {
+			return inv.CompareNames(records[i].Hostname, records[j].Hostname) < 0
+		}
+
+		return records[i].Source < records[j].Source
+	})
+
+	return output(records, opts.format, inv)
+}
+
+// runLint checks host records against the constraints of DNS TXT records: records in the import file if one is specified, records in the datasource otherwise.
+func runLint(inv *inventory.Inventory, opts *options) error {
+	var problems []*inventory.LintProblem
+
+	if len(opts.importFile) > 0 {
+		hosts, err := readImportFile(inv, opts)
+		if err != nil {
+			return err
+		}
+
+		problems = inv.LintHosts(hosts)
+	} else {
+		records, err := inv.Datasource.GetAllRecords()
+		if err != nil {
+			return &inventory.DatasourceError{Err: errors.Wrap(err, "record loading failure")}
+		}
+
+		problems = inv.LintRecords(records)
+	}
+
+	if err := output(problems, opts.format, inv); err != nil {
+		return err
+	}
+
+	if n := inventory.LintErrors(problems); n > 0 {
+		return errors.Errorf("%d lint errors found", n)
+	}
+
+	return nil
+}
+
+// runLimits exports Ansible --limit host patterns for the named limit expressions.
+func runLimits(inv *inventory.Inventory, opts *options) error {
+	limits := make(map[string]string)
+
+	if err := inv.ExportLimits(limits); err != nil {
+		return err
+	}
+
+	return output(limits, opts.format, inv)
+}
+
+// runConflicts exports hosts whose records or variable sources disagree.
+func runConflicts(inv *inventory.Inventory, opts *options) error {
+	conflicts, err := inv.ExportConflicts()
+	if err != nil {
+		return err
+	}
+
+	return output(conflicts, opts.format, inv)
+}
+
+// runServe serves the inventory over HTTP until interrupted.
+func runServe(inv *inventory.Inventory, _ *options) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv, err := server.New(inv)
+	if err != nil {
+		return err
+	}
+
+	return srv.Run(ctx)
+}
+
+// runRefreshZone asks the running inventory server to refresh a single zone and exports the new inventory metadata.
+func runRefreshZone(inv *inventory.Inventory, opts *options) error {
+	metadata, err := server.RequestZoneRefresh(inv.Config, opts.refreshZone)
+	if err != nil {
+		return err
+	}
+
+	inv.Logger.Infof("[%s] zone refreshed: %d hosts in the inventory", opts.refreshZone, metadata.Hosts)
+
+	return output(metadata, opts.format, inv)
+}
+
+// runCron rebuilds the inventory and writes the configured exports on a schedule until interrupted.
+func runCron(inv *inventory.Inventory, opts *options) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return cron.New(inv, opts.schedule).Run(ctx)
+}
+
+// runMigrateSeparator rewrites service identifiers using the deprecated '-' separator to use '_' and reports group names that change.
+func runMigrateSeparator(inv *inventory.Inventory, opts *options) error {
+	log := inv.Logger
+
+	report, err := inv.MigrateSeparator("-", "_", opts.dryRun)
+	if err != nil {
+		return err
+	}
+
+	if opts.dryRun {
+		log.Infof("dry run: %d of %d host records would be rewritten", report.Changed, report.Records)
+	} else {
+		log.Infof("%d of %d host records rewritten, set 'txt.keys.separator' to '_'", report.Changed, report.Records)
+	}
+
+	return output(report, opts.format, inv)
+}
+
+// runReencrypt encrypts all host records with the current encryption key and reports the keys they have been encrypted with.
+func runReencrypt(inv *inventory.Inventory, opts *options) error {
+	log := inv.Logger
+
+	report, err := inv.Reencrypt(opts.dryRun)
+	if err != nil {
+		return err
+	}
+
+	if opts.dryRun {
+		log.Infof("dry run: %d of %d values would be encrypted again", report.Changed, report.Records)
+	} else {
+		log.Infof("%d of %d values encrypted again", report.Changed, report.Records)
+	}
+
+	return output(report, opts.format, inv)
+}
+
+// runRename moves all records of a host to a new hostname.
+func runRename(inv *inventory.Inventory, opts *options) error {
+	log := inv.Logger
+
+	report, err := inv.RenameHost(opts.renameFrom, opts.renameTo, opts.dryRun)
+	if err != nil {
+		return err
+	}
+
+	if opts.dryRun {
+		log.Infof("dry run: %d host records would be moved from %s to %s", report.Records, report.From, report.To)
+	} else {
+		log.Infof("%d host records moved from %s to %s", report.Records, report.From, report.To)
+	}
+
+	return output(report, opts.format, inv)
+}
+
+// runHistory exports the timeline of the record attribute changes of a host.
+func runHistory(inv *inventory.Inventory, opts *options) error {
+	changes, err := inv.HostHistory(opts.history)
+	if err != nil {
+		return err
+	}
+
+	return output(changes, opts.format, inv)
+}
+
+// runBundle writes all host records to a portable bundle or verifies a portable bundle and publishes its host records.
+// Bundles are written to a temporary file first, so that a failed export does not replace an existing bundle.
+func runBundle(inv *inventory.Inventory, opts *options) error {
+	log := inv.Logger
+
+	var manifest *inventory.BundleManifest
+
+	switch opts.bundle {
+	case "export":
+		tmp := opts.bundleFile + ".tmp"
+
+		file, err := os.Create(tmp)
+		if err != nil {
+			return err
+		}
+
+		manifest, err = inv.ExportBundle(file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmp, opts.bundleFile)
+		}
+		if err != nil {
+			os.Remove(tmp)
+			return err
+		}
+
+		log.Infof("%d host records of %d hosts written to bundle: %s", manifest.Records, manifest.Hosts, opts.bundleFile)
+	case "import":
+		file, err := os.Open(opts.bundleFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		if manifest, err = inv.ImportBundle(file, opts.dryRun); err != nil {
+			return err
+		}
+
+		if opts.dryRun {
+			log.Infof("dry run: bundle verified, %d host records of %d hosts would be published", manifest.Records, manifest.Hosts)
+		} else {
+			log.Infof("%d host records of %d hosts published from bundle: %s", manifest.Records, manifest.Hosts, opts.bundleFile)
+		}
+	}
+
+	return output(manifest, opts.format, inv)
+}
+
+// runCompare builds the inventory of another configuration file and exports the differences between the two inventories.
+// Runtime host record filters apply to both inventories. The command fails if the inventories differ.
+func runCompare(inv *inventory.Inventory, opts *options) error {
+	cfg, err := config.LoadFile(opts.compare)
+	if err != nil {
+		return errors.Wrap(err, "other inventory")
+	}
+
+	other, err := inventory.New(cfg, inv.Logger)
+	if err != nil {
+		return errors.Wrap(err, "other inventory")
+	}
+	defer other.Close()
+
+	other.Filters = inv.Filters
+
+	comparison, err := inv.Compare(other)
+	if err != nil {
+		return err
+	}
+
+	if err := output(comparison, opts.format, inv); err != nil {
+		return err
+	}
+
+	if !comparison.Equal() {
+		return errors.New("inventories differ")
+	}
+
+	return nil
+}
+
+// runBenchDatasource optionally seeds the datasource with synthetic host records and measures the inventory build throughput.
+func runBenchDatasource(inv *inventory.Inventory, opts *options) error {
+	cfg := inv.Config
+	log := inv.Logger
+
+	if cfg.Bench.Seed || len(cfg.Bench.ZoneFile) > 0 {
+		hosts, err := inv.GenerateHosts()
+		if err != nil {
+			return errors.Wrap(err, "synthetic host generation failure")
+		}
+
+		if len(cfg.Bench.ZoneFile) > 0 {
+			if err := inv.WriteZoneFile(cfg.Bench.ZoneFile, hosts); err != nil {
+				return err
+			}
+
+			log.Infof("%d synthetic hosts written to %s", len(hosts), cfg.Bench.ZoneFile)
+		}
+
+		if cfg.Bench.Seed {
+			if _, ok := inv.Datasource.(inventory.IncrementalDatasource); !ok {
+				return errors.Errorf("datasource does not support seeding: %s", cfg.Datasource)
+			}
+
+			report, err := inv.PublishHostsBulk(hosts, "")
+			if err != nil {
+				return err
+			}
+
+			if len(report.Failed) > 0 {
+				return errors.Errorf("%d of %d synthetic hosts could not be published", len(report.Failed), report.Hosts)
+			}
+
+			log.Infof("%d synthetic hosts published", report.Published)
+		}
+	}
+
+	report, err := inv.Benchmark()
+	if err != nil {
+		return err
+	}
+
+	return output(report, opts.format, inv)
+}
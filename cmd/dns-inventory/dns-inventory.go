@@ -4,134 +4,331 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 
-	"gopkg.in/yaml.v3"
-
-	"github.com/NeonSludge/ansible-dns-inventory/internal/build"
 	"github.com/NeonSludge/ansible-dns-inventory/internal/config"
+	"github.com/NeonSludge/ansible-dns-inventory/internal/cron"
 	"github.com/NeonSludge/ansible-dns-inventory/internal/logger"
-	"github.com/NeonSludge/ansible-dns-inventory/internal/util"
 	"github.com/NeonSludge/ansible-dns-inventory/pkg/inventory"
 )
 
+type (
+	// options represents command line flags that are shared by several commands.
+	options struct {
+		// Export format.
+		format string
+		// Host name for the host variables command.
+		host string
+		// Path to the import file.
+		importFile string
+		// Format of the import file.
+		importFormat string
+		// Runtime host record filter expression.
+		where string
+		// Name of a runtime host record filter set.
+		filter string
+		// Report changes without writing them to the datasource.
+		dryRun bool
+		// Path to the import state file.
+		state string
+		// Suppress per-record warnings.
+		quiet bool
+		// Use distinct exit codes for successful runs with warnings.
+		detailedExitCodes bool
+		// Etcd host record namespace to use.
+		namespace string
+		// Dump datasource requests and responses to the trace file.
+		debugDNS bool
+		// Host name for the host record history command.
+		history string
+		// Host rename expression.
+		rename string
+		// Old and new hostnames parsed from the host rename expression.
+		renameFrom, renameTo string
+		// Scheduled export schedule expression.
+		cron string
+		// Scheduled export schedule parsed from the schedule expression.
+		schedule cron.Schedule
+		// Path to the configuration file of the inventory to compare with.
+		compare string
+		// Zone to refresh in the running inventory server.
+		refreshZone string
+		// Comma-separated list of partial exports to merge instead of reading the datasource.
+		merge string
+		// Portable bundle action: 'export' or 'import'.
+		bundle string
+		// Path to the portable bundle.
+		bundleFile string
+		// Directory to write the inventory files of the split export to.
+		split string
+	}
+
+	// command represents a single mutually exclusive CLI mode.
+	command struct {
+		// Name of the flag that selects this command.
+		flag string
+		// Command has been selected by the user.
+		selected bool
+		// Command requires an initialized inventory.
+		inventory bool
+		// Names of the optional flags supported by this command.
+		options []string
+		// Command handler.
+		run func(inv *inventory.Inventory, opts *options) error
+	}
+)
+
+// globalFlags lists the flags supported by all commands that require an initialized inventory.
+var globalFlags = []string{"quiet", "detailed-exit-codes", "namespace", "debug-dns"}
+
+// parseRename parses a host rename expression (e.g. 'old=app01.infra.local,new=app02.infra.local').
+func parseRename(expr string) (string, string, error) {
+	var from, to string
+
+	for _, term := range strings.Split(expr, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(term), "=")
+		if !ok {
+			return "", "", fmt.Errorf("invalid rename expression term: %s", term)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "old":
+			from = strings.TrimSpace(value)
+		case "new":
+			to = strings.TrimSpace(value)
+		default:
+			return "", "", fmt.Errorf("unknown rename expression key: %s", key)
+		}
+	}
+
+	if len(from) == 0 || len(to) == 0 {
+		return "", "", fmt.Errorf("rename expression must specify both 'old' and 'new' hostnames: %s", expr)
+	}
+
+	return from, to, nil
+}
+
+// selectCommand validates the flags set in a flag set and returns the selected command.
+// The fallback command is returned if no command has been selected.
+func selectCommand(set *flag.FlagSet, commands []*command, fallback *command) (*command, error) {
+	selected := make([]*command, 0)
+	names := make([]string, 0)
+	modes := make([]string, 0, len(commands))
+
+	for _, c := range commands {
+		modes = append(modes, c.flag)
+
+		if c.selected {
+			selected = append(selected, c)
+			names = append(names, "-"+c.flag)
+		}
+	}
+
+	switch len(selected) {
+	case 0:
+		selected = append(selected, fallback)
+	case 1:
+	default:
+		return nil, fmt.Errorf("conflicting flags: %s", strings.Join(names, ", "))
+	}
+
+	// Check flags that only make sense for some of the commands.
+	var err error
+	set.Visit(func(f *flag.Flag) {
+		if slices.Contains(globalFlags, f.Name) && selected[0].inventory {
+			return
+		}
+
+		if !slices.Contains(modes, f.Name) && !slices.Contains(selected[0].options, f.Name) {
+			if len(selected[0].flag) == 0 {
+				err = fmt.Errorf("flag -%s is not supported without a mode flag", f.Name)
+			} else {
+				err = fmt.Errorf("flag -%s is not supported by -%s", f.Name, selected[0].flag)
+			}
+		}
+	})
+
+	return selected[0], err
+}
+
 func main() {
+	opts := &options{}
+
 	// Parse flags.
 	listFlag := flag.Bool("list", false, "produce a JSON inventory for Ansible")
 	hostsFlag := flag.Bool("hosts", false, "export hosts")
 	attrsFlag := flag.Bool("attrs", false, "export host attributes")
+	benchDatasourceFlag := flag.Bool("bench-datasource", false, "measure host record acquisition and inventory build times, optionally seeding the datasource with synthetic host records first")
 	groupsFlag := flag.Bool("groups", false, "export groups")
 	treeFlag := flag.Bool("tree", false, "export raw inventory tree")
-	formatFlag := flag.String("format", "yaml", "select export format, if available")
-	hostFlag := flag.String("host", "", "produce a JSON dictionary of host variables for Ansible")
-	importFlag := flag.String("import", "", "import host records from file")
+	flag.StringVar(&opts.split, "split", "", "write an inventory file for every value of the split key (e.g. every environment) into a directory")
+	flag.StringVar(&opts.format, "format", "yaml", "select export format, if available")
+	flag.StringVar(&opts.host, "host", "", "produce a JSON dictionary of host variables for Ansible")
+	flag.StringVar(&opts.importFile, "import", "", "import host records from file")
+	flag.StringVar(&opts.importFormat, "import-format", "yaml", "select import file format: 'yaml' or 'ansible' (the JSON output of 'ansible-inventory --list')")
+	flag.StringVar(&opts.where, "where", "", "filter exported host records by attributes, e.g. 'env=prod,role=db|app'")
+	flag.StringVar(&opts.merge, "merge", "", "build the inventory from partial 'attrs' exports (e.g. of zone shards) instead of the datasource, e.g. 'shard0.json,shard1.json'")
+	flag.StringVar(&opts.filter, "filter", "", "filter exported host records using a named filter set from the configuration")
+	flag.StringVar(&opts.state, "state", "", "record imported hosts in a state file to resume an interrupted import")
+	flag.BoolVar(&opts.quiet, "quiet", false, "suppress per-record warnings and only print a summary at the end of the run")
+	flag.BoolVar(&opts.detailedExitCodes, "detailed-exit-codes", false, "exit with a non-zero code if warnings have been logged during a successful run")
+	flag.StringVar(&opts.namespace, "namespace", "", "read and publish etcd host records in the namespace of this environment only")
+	flag.BoolVar(&opts.debugDNS, "debug-dns", false, "dump DNS messages and etcd request metadata to the trace file ('log.file')")
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "report changes without writing them to the datasource")
+	flag.StringVar(&opts.history, "history", "", "show a timeline of the record attribute changes of a host")
+	flag.StringVar(&opts.rename, "rename", "", "move all records of a host to a new hostname, e.g. 'old=app01.infra.local,new=app02.infra.local'")
+	migrateSeparatorFlag := flag.Bool("migrate-separator", false, "rewrite service identifiers using the deprecated '-' separator to use '_'")
+	reencryptFlag := flag.Bool("reencrypt", false, "encrypt all etcd host records with the current encryption key, e.g. after a key rotation")
+	recordsFlag := flag.Bool("records", false, "export raw host records as returned by the datasource")
+	conflictsFlag := flag.Bool("conflicts", false, "export hosts whose records or variable sources disagree and how the conflicts have been resolved")
+	lintFlag := flag.Bool("lint", false, "check host records against DNS TXT record limits: records in the datasource or, with -import, in the import file")
+	limitsFlag := flag.Bool("limits", false, "export Ansible --limit host patterns for the named limit expressions")
+	serveFlag := flag.Bool("serve", false, "serve the inventory over HTTP")
+	flag.StringVar(&opts.refreshZone, "refresh-zone", "", "refresh the host records of a single zone in the running inventory server, e.g. 'corp.local.'")
+	flag.StringVar(&opts.bundle, "bundle", "", "write all host records to a signed portable bundle ('export') or publish the host records of a bundle ('import')")
+	flag.StringVar(&opts.bundleFile, "bundle-file", "inventory.bundle", "path to the portable bundle")
+	flag.StringVar(&opts.compare, "compare", "", "compare the inventory with the one built from another configuration file and export the differences")
+	flag.StringVar(&opts.cron, "cron", "", "rebuild the inventory and write the configured exports on a schedule, e.g. '*/15 * * * *' or '@every 5m'")
 	versionFlag := flag.Bool("version", false, "display ansible-dns-inventory version and build info")
 	flag.Parse()
 
-	// Create a global logger.
-	log, err := logger.New("info")
-	if err != nil {
-		fmt.Println("Logger initialization failure: ", err)
-		os.Exit(1)
-	}
-
-	// Create a configuration object.
-	cfg, err := config.Load()
+	// Route flags to commands. Without a mode flag, the inventory is built and an empty host list is exported, as before modes were introduced.
+	cmd, err := selectCommand(flag.CommandLine, []*command{
+		{flag: "version", selected: *versionFlag, run: runVersion},
+		{flag: "import", selected: len(opts.importFile) > 0 && !*lintFlag, inventory: true, options: []string{"state", "import-format"}, run: runImport},
+		{flag: "host", selected: len(opts.host) > 0, inventory: true, run: runHost},
+		{flag: "list", selected: *listFlag, inventory: true, options: []string{"where", "filter", "merge"}, run: runList},
+		{flag: "hosts", selected: *hostsFlag, inventory: true, options: []string{"format", "where", "filter", "merge"}, run: runHosts},
+		{flag: "groups", selected: *groupsFlag, inventory: true, options: []string{"format", "where", "filter", "merge"}, run: runGroups},
+		{flag: "attrs", selected: *attrsFlag, inventory: true, options: []string{"format", "where", "filter", "merge"}, run: runAttrs},
+		{flag: "tree", selected: *treeFlag, inventory: true, options: []string{"format", "where", "filter", "merge"}, run: runTree},
+		{flag: "split", selected: len(opts.split) > 0, inventory: true, options: []string{"format", "where", "filter", "merge"}, run: runSplit},
+		{flag: "records", selected: *recordsFlag, inventory: true, options: []string{"format"}, run: runRecords},
+		{flag: "conflicts", selected: *conflictsFlag, inventory: true, options: []string{"format", "where", "filter"}, run: runConflicts},
+		{flag: "lint", selected: *lintFlag, inventory: true, options: []string{"format", "import", "import-format"}, run: runLint},
+		{flag: "limits", selected: *limitsFlag, inventory: true, options: []string{"format"}, run: runLimits},
+		{flag: "serve", selected: *serveFlag, inventory: true, run: runServe},
+		{flag: "refresh-zone", selected: len(opts.refreshZone) > 0, inventory: true, options: []string{"format"}, run: runRefreshZone},
+		{flag: "cron", selected: len(opts.cron) > 0, inventory: true, options: []string{"where", "filter"}, run: runCron},
+		{flag: "migrate-separator", selected: *migrateSeparatorFlag, inventory: true, options: []string{"format", "dry-run"}, run: runMigrateSeparator},
+		{flag: "reencrypt", selected: *reencryptFlag, inventory: true, options: []string{"format", "dry-run"}, run: runReencrypt},
+		{flag: "rename", selected: len(opts.rename) > 0, inventory: true, options: []string{"format", "dry-run"}, run: runRename},
+		{flag: "history", selected: len(opts.history) > 0, inventory: true, options: []string{"format"}, run: runHistory},
+		{flag: "bundle", selected: len(opts.bundle) > 0, inventory: true, options: []string{"bundle-file", "format", "dry-run"}, run: runBundle},
+		{flag: "compare", selected: len(opts.compare) > 0, inventory: true, options: []string{"format", "where", "filter"}, run: runCompare},
+		{flag: "bench-datasource", selected: *benchDatasourceFlag, inventory: true, options: []string{"format", "where", "filter"}, run: runBenchDatasource},
+	}, &command{inventory: true, options: []string{"format"}, run: runDefault})
 	if err != nil {
-		log.Fatal(err)
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		flag.Usage()
+		os.Exit(exitUsage)
 	}
 
-	// Initialize a new inventory.
-	dnsInventory, err := inventory.New(cfg, log)
-	if err != nil {
-		log.Fatal(err)
+	// Parse the host rename expression.
+	if len(opts.rename) > 0 {
+		if opts.renameFrom, opts.renameTo, err = parseRename(opts.rename); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			flag.Usage()
+			os.Exit(exitUsage)
+		}
 	}
-	defer dnsInventory.Datasource.Close()
 
-	if len(*importFlag) > 0 {
-		hosts := make(map[string][]*inventory.HostAttributes)
-
-		importFile, err := os.ReadFile(*importFlag)
-		if err != nil {
-			log.Fatal(err)
+	// Check the portable bundle action.
+	if len(opts.bundle) > 0 {
+		switch {
+		case opts.bundle != "export" && opts.bundle != "import":
+			err = fmt.Errorf("unknown bundle action: %s", opts.bundle)
+		case opts.bundle == "export" && opts.dryRun:
+			err = fmt.Errorf("flag -dry-run is not supported by -bundle export")
 		}
 
-		err = yaml.Unmarshal(importFile, hosts)
 		if err != nil {
-			log.Fatal(err)
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			flag.Usage()
+			os.Exit(exitUsage)
 		}
+	}
 
-		log.Infof("importing hosts from file: %s", *importFlag)
-
-		if err := dnsInventory.PublishHosts(hosts); err != nil {
-			log.Fatal(err)
+	// Parse the export schedule.
+	if len(opts.cron) > 0 {
+		if opts.schedule, err = cron.ParseSchedule(opts.cron); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			flag.Usage()
+			os.Exit(exitUsage)
 		}
-	} else if len(*hostFlag) == 0 {
-		var bytes []byte
-		var err error
+	}
 
-		// Acquire and parse host TXT records.
-		hosts, err := dnsInventory.GetHosts()
-		if err != nil {
-			log.Fatal(err)
+	// Cheap commands never touch the configuration or the datasource.
+	if !cmd.inventory {
+		if err := cmd.run(nil, opts); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(exitFailure)
 		}
+		return
+	}
 
-		if len(hosts) == 0 {
-			log.Fatal("no host records found")
-		}
+	// Create a global logger.
+	zapLog, err := logger.New("info")
+	if err != nil {
+		fmt.Println("Logger initialization failure: ", err)
+		os.Exit(exitFailure)
+	}
 
-		// Load host records into the inventory tree.
-		dnsInventory.ImportHosts(hosts)
+	// Count per-record warnings to summarize them at the end of the run.
+	log := inventory.NewWarningLogger(zapLog, opts.quiet)
 
-		// Export the inventory tree in various formats.
-		switch {
-		case *versionFlag:
-			fmt.Println("version:", build.Version)
-			fmt.Println("build time:", build.Time)
-		case *listFlag:
-			export := make(map[string]*inventory.AnsibleGroup)
-
-			// Export the inventory tree into a map.
-			dnsInventory.ExportInventory(export)
-
-			// Marshal the map into a JSON representation of an Ansible inventory.
-			bytes, err = util.Marshal(export, "json", dnsInventory.Config)
-		case *attrsFlag:
-			bytes, err = util.Marshal(hosts, *formatFlag, dnsInventory.Config)
-		case *treeFlag:
-			bytes, err = util.Marshal(dnsInventory.Tree, *formatFlag, dnsInventory.Config)
-		default:
-			export := make(map[string][]string)
-
-			// Export hosts or groups.
-			switch {
-			case *hostsFlag:
-				dnsInventory.ExportHosts(export)
-			case *groupsFlag:
-				dnsInventory.ExportGroups(export)
-			}
+	// Create a configuration object.
+	cfg, err := config.Load()
+	if err != nil {
+		exit(log, exitConfig, err)
+	}
 
-			bytes, err = util.Marshal(export, *formatFlag, dnsInventory.Config)
-		}
+	// Select an etcd host record namespace.
+	if len(opts.namespace) > 0 {
+		cfg.Etcd.Namespace = opts.namespace
+	}
 
-		if err != nil {
-			log.Fatal(err)
-		}
+	// Enable request tracing.
+	if opts.debugDNS {
+		cfg.Log.Trace = true
+	}
+
+	// Initialize a new inventory.
+	dnsInventory, err := inventory.New(cfg, log)
+	if err != nil {
+		exit(log, exitConfig, err)
+	}
 
-		fmt.Println(string(bytes))
-	} else if len(*hostFlag) > 0 && dnsInventory.Config.Txt.Vars.Enabled {
-		// Acquire host variables.
-		vars, err := dnsInventory.GetHostVariables(*hostFlag)
+	// Select a runtime host record filter set.
+	if len(opts.filter) > 0 {
+		filters, err := dnsInventory.FilterSet(opts.filter)
 		if err != nil {
-			log.Fatal(err)
+			exit(log, exitUsage, err)
 		}
 
-		bytes, err := util.Marshal(vars, "json", dnsInventory.Config)
+		dnsInventory.Filters = append(dnsInventory.Filters, filters...)
+	}
+
+	// Parse runtime host record filters.
+	if len(opts.where) > 0 {
+		filters, err := dnsInventory.ParseFilters(opts.where)
 		if err != nil {
-			log.Fatal(err)
+			exit(log, exitUsage, err)
 		}
 
-		fmt.Println(string(bytes))
-	} else {
-		fmt.Println("{}")
+		dnsInventory.Filters = append(dnsInventory.Filters, filters...)
 	}
+
+	err = cmd.run(dnsInventory, opts)
+	dnsInventory.Close()
+
+	// The summary itself is not a warning of the run.
+	code := exitCode(err, len(dnsInventory.FailedZones()) > 0, log, opts.detailedExitCodes)
+
+	if summary := log.Summary(); len(summary) > 0 {
+		log.Warn(summary)
+	}
+
+	exit(log, code, err)
 }
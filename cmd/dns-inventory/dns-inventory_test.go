@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+)
+
+func Test_selectCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{
+			// The inventory is built and an empty host list is exported.
+			name: "valid-default",
+			args: []string{},
+			want: "default",
+		},
+		{
+			name: "valid-default-format",
+			args: []string{"-format", "json"},
+			want: "default",
+		},
+		{
+			name: "valid-list",
+			args: []string{"-list"},
+			want: "list",
+		},
+		{
+			name: "valid-options",
+			args: []string{"-hosts", "-format", "json", "-where", "ENV=prod"},
+			want: "hosts",
+		},
+		{
+			name: "valid-global",
+			args: []string{"-attrs", "-quiet"},
+			want: "attrs",
+		},
+		{
+			name:    "invalid-conflicting",
+			args:    []string{"-list", "-hosts"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid-option",
+			args:    []string{"-list", "-format", "json"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid-default-option",
+			args:    []string{"-where", "ENV=prod"},
+			wantErr: true,
+		},
+		{
+			// Global flags only apply to commands that build the inventory.
+			name:    "invalid-global",
+			args:    []string{"-version", "-quiet"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := flag.NewFlagSet("dns-inventory", flag.ContinueOnError)
+			set.SetOutput(io.Discard)
+
+			list := set.Bool("list", false, "")
+			hosts := set.Bool("hosts", false, "")
+			attrs := set.Bool("attrs", false, "")
+			version := set.Bool("version", false, "")
+			set.String("format", "yaml", "")
+			set.String("where", "", "")
+			set.Bool("quiet", false, "")
+
+			if err := set.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := selectCommand(set, []*command{
+				{flag: "version", selected: *version},
+				{flag: "list", selected: *list, inventory: true, options: []string{"where"}},
+				{flag: "hosts", selected: *hosts, inventory: true, options: []string{"format", "where"}},
+				{flag: "attrs", selected: *attrs, inventory: true, options: []string{"format", "where"}},
+			}, &command{flag: "", inventory: true, options: []string{"format"}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			name := got.flag
+			if len(name) == 0 {
+				name = "default"
+			}
+			if name != tt.want {
+				t.Errorf("selectCommand() = %v, want %v", name, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/NeonSludge/ansible-dns-inventory/pkg/inventory"
+)
+
+// Exit codes.
+const (
+	// Success.
+	exitOK int = 0
+	// Unclassified failure.
+	exitFailure int = 1
+	// Invalid command line flags or filter expressions.
+	exitUsage int = 2
+	// Configuration loading or inventory initialization failure.
+	exitConfig int = 3
+	// Host records could not be acquired from the datasource (e.g. all zones failed).
+	exitDatasource int = 4
+	// Success, but some zones could not be queried (-detailed-exit-codes only).
+	exitPartial int = 5
+	// Success, but some host records failed validation (-detailed-exit-codes only).
+	exitValidation int = 6
+	// Success, but other warnings have been logged (-detailed-exit-codes only).
+	exitWarnings int = 7
+)
+
+// exitCode classifies the result of a command run.
+// Failures are classified by the type of the error, successful runs by the zones skipped by the datasource ('partial') and by the warnings logged.
+// Unless detailed exit codes are requested, a successful run always exits with exitOK, as Ansible treats any other exit code of an inventory script as a failure.
+func exitCode(err error, partial bool, log *inventory.WarningLogger, detailed bool) int {
+	var dsErr *inventory.DatasourceError
+
+	switch {
+	case errors.As(err, &dsErr):
+		return exitDatasource
+	case err != nil:
+		return exitFailure
+	case !detailed:
+		return exitOK
+	case partial:
+		return exitPartial
+	case log.Counts()["validation"] > 0:
+		return exitValidation
+	case log.Counts()["variable source"] > 0 || log.Other() > 0:
+		return exitWarnings
+	default:
+		return exitOK
+	}
+}
+
+// exit logs an error, if any, and terminates the program with an exit code.
+func exit(log inventory.Logger, code int, err error) {
+	if err != nil {
+		log.Error(err)
+	}
+
+	os.Exit(code)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/NeonSludge/ansible-dns-inventory/pkg/inventory"
+)
+
+// TestMain runs the program instead of the tests in the processes started by runProgram.
+func TestMain(m *testing.M) {
+	if os.Getenv("ADI_TEST_MAIN") == "1" {
+		main()
+		os.Exit(exitOK)
+	}
+
+	os.Exit(m.Run())
+}
+
+// runProgram runs the program with arguments and environment variables in a new process and returns its exit code.
+func runProgram(t *testing.T, env []string, args ...string) int {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = t.TempDir()
+	cmd.Env = append([]string{"ADI_TEST_MAIN=1", "HOME=" + cmd.Dir, "PATH=" + os.Getenv("PATH")}, env...)
+
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	return exitOK
+}
+
+// testLogger discards all messages.
+type testLogger struct {
+	inventory.Logger
+}
+
+func (l *testLogger) Warn(args ...interface{}) {}
+
+func (l *testLogger) Warnf(template string, args ...interface{}) {}
+
+func Test_exitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		partial  bool
+		warnings []string
+		detailed bool
+		want     int
+	}{
+		{
+			name: "valid-ok",
+			want: exitOK,
+		},
+		{
+			// Warnings don't change the exit code unless detailed exit codes are requested.
+			name:     "valid-ok-warnings",
+			partial:  true,
+			warnings: []string{"[%s] skipping host record: %v"},
+			want:     exitOK,
+		},
+		{
+			name:     "valid-ok-detailed",
+			detailed: true,
+			want:     exitOK,
+		},
+		{
+			// Filtered records are expected and don't count as warnings.
+			name:     "valid-ok-filtered",
+			warnings: []string{"[%s] skipping filtered host record"},
+			detailed: true,
+			want:     exitOK,
+		},
+		{
+			name: "valid-failure",
+			err:  errors.New("invalid host attributes"),
+			want: exitFailure,
+		},
+		{
+			name: "valid-datasource",
+			err:  errors.Wrap(&inventory.DatasourceError{Err: errors.New("connection refused")}, "inventory building failure"),
+			want: exitDatasource,
+		},
+		{
+			name:     "valid-partial",
+			partial:  true,
+			warnings: []string{"[%s] skipping zone: %v", "[%s] skipping host record: %v"},
+			detailed: true,
+			want:     exitPartial,
+		},
+		{
+			name:     "valid-validation",
+			warnings: []string{"[%s] skipping host record: %v", "[%s] skipping variable source: %v"},
+			detailed: true,
+			want:     exitValidation,
+		},
+		{
+			name:     "valid-warnings-varsource",
+			warnings: []string{"[%s] skipping variable source: %v"},
+			detailed: true,
+			want:     exitWarnings,
+		},
+		{
+			name:     "valid-warnings-other",
+			warnings: []string{"host record cache writing failure: %v"},
+			detailed: true,
+			want:     exitWarnings,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := inventory.NewWarningLogger(&testLogger{}, false)
+			for _, template := range tt.warnings {
+				log.Warnf(template, "app01.infra.local", nil)
+			}
+
+			if got := exitCode(tt.err, tt.partial, log, tt.detailed); got != tt.want {
+				t.Errorf("exitCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_main_exitCode(t *testing.T) {
+	// A DNS server address nothing listens on.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := l.Addr().String()
+	l.Close()
+
+	tests := []struct {
+		name string
+		env  []string
+		args []string
+		want int
+	}{
+		{
+			name: "valid-version",
+			args: []string{"-version"},
+			want: exitOK,
+		},
+		{
+			name: "invalid-usage",
+			args: []string{"-list", "-hosts"},
+			want: exitUsage,
+		},
+		{
+			name: "invalid-config",
+			env:  []string{"ADI_CONFIG_FILE=missing.yaml"},
+			args: []string{"-list"},
+			want: exitConfig,
+		},
+		{
+			name: "invalid-datasource",
+			env:  []string{"ADI_DNS_SERVER=" + unreachable, "ADI_DNS_ZONES=infra.local.", "ADI_DNS_TIMEOUT=1s"},
+			args: []string{"-list"},
+			want: exitDatasource,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runProgram(t, tt.env, tt.args...); got != tt.want {
+				t.Errorf("exit code = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,8 +1,80 @@
 package build
 
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
 var (
 	// Version represents ansible-dns-inventory version.
 	Version string
 	// Time represents ansible-dns-inventory build time.
 	Time string
 )
+
+// Info represents ansible-dns-inventory version and build information.
+type Info struct {
+	// Release version, set at build time or derived from the module version.
+	Version string `json:"version" yaml:"version"`
+	// Build time.
+	Time string `json:"time" yaml:"time"`
+	// VCS revision.
+	Revision string `json:"revision" yaml:"revision"`
+	// VCS working tree had uncommitted changes at build time.
+	Dirty bool `json:"dirty" yaml:"dirty"`
+	// Go toolchain version.
+	GoVersion string `json:"go" yaml:"go"`
+}
+
+// String returns a single line representation of build information.
+func (i *Info) String() string {
+	b := strings.Builder{}
+	b.WriteString(i.Version)
+
+	if len(i.Revision) > 0 {
+		b.WriteString(fmt.Sprintf(" (%s", i.Revision))
+		if i.Dirty {
+			b.WriteString(", dirty")
+		}
+		b.WriteString(")")
+	}
+
+	b.WriteString(fmt.Sprintf(" %s", i.GoVersion))
+
+	return b.String()
+}
+
+// Get collects build information from the variables set at build time and the information embedded by the Go toolchain.
+func Get() *Info {
+	info := &Info{
+		Version:   Version,
+		Time:      Time,
+		GoVersion: runtime.Version(),
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	if len(info.Version) == 0 {
+		info.Version = bi.Main.Version
+	}
+
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.time":
+			if len(info.Time) == 0 {
+				info.Time = s.Value
+			}
+		case "vcs.modified":
+			info.Dirty = s.Value == "true"
+		}
+	}
+
+	return info
+}
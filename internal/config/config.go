@@ -1,10 +1,19 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
+	"math"
 	"os"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/creasty/defaults"
+	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 
@@ -13,56 +22,152 @@ import (
 
 const (
 	adiEnvPrefix = "ADI"
+
+	// Allowed duration formats, reported in configuration errors.
+	durationFormats = `a number with a unit suffix: "ns", "us", "ms", "s", "m" or "h" (e.g. "30s", "1m30s")`
+	// Allowed size formats, reported in configuration errors.
+	sizeFormats = `a number of bytes or a number with a unit suffix: "B", "KB", "MB", "GB", "KiB", "MiB" or "GiB" (e.g. "4MiB")`
 )
 
-func configKeys() []string {
-	return []string{
-		"datasource",
-		"dns.server",
-		"dns.timeout",
-		"dns.zones",
-		"dns.notransfer.enabled",
-		"dns.notransfer.host",
-		"dns.notransfer.separator",
-		"dns.tsig.enabled",
-		"dns.tsig.key",
-		"dns.tsig.secret",
-		"dns.tsig.algo",
-		"etcd.endpoints",
-		"etcd.timeout",
-		"etcd.prefix",
-		"etcd.zones",
-		"etcd.auth.username",
-		"etcd.auth.password",
-		"etcd.tls.enabled",
-		"etcd.tls.insecure",
-		"etcd.tls.ca.path",
-		"etcd.tls.ca.pem",
-		"etcd.tls.certificate.path",
-		"etcd.tls.certificate.pem",
-		"etcd.tls.key.path",
-		"etcd.tls.key.pem",
-		"etcd.import.clear",
-		"etcd.import.batch",
-		"txt.kv.separator",
-		"txt.kv.equalsign",
-		"txt.vars.enabled",
-		"txt.vars.separator",
-		"txt.vars.equalsign",
-		"txt.keys.separator",
-		"txt.keys.os",
-		"txt.keys.env",
-		"txt.keys.role",
-		"txt.keys.srv",
-		"txt.keys.vars",
-		"filter.enabled",
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	sizeType     = reflect.TypeOf(inventory.ByteSize(0))
+
+	// Size unit multipliers by lowercase unit suffix.
+	sizeUnits = map[string]float64{"": 1, "b": 1, "kb": 1e3, "mb": 1e6, "gb": 1e9, "kib": 1 << 10, "mib": 1 << 20, "gib": 1 << 30}
+)
+
+// configKeys returns the keys of all configuration parameters, derived from the 'mapstructure' tags of inventory.Config.
+// Every key can be set via an environment variable: ADI_ followed by the key in upper case with dots replaced by underscores.
+func configKeys(t reflect.Type, prefix string) []string {
+	keys := make([]string, 0)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name := field.Tag.Get("mapstructure")
+		if len(name) == 0 {
+			name = strings.ToLower(field.Name)
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			keys = append(keys, configKeys(field.Type, prefix+name+".")...)
+		} else {
+			keys = append(keys, prefix+name)
+		}
 	}
+
+	return keys
+}
+
+// jsonDecodeHook decodes JSON-encoded strings (e.g. supplied via environment variables) into lists, maps and structs.
+func jsonDecodeHook(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	if f.Kind() != reflect.String {
+		return data, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Struct:
+	default:
+		return data, nil
+	}
+
+	raw := strings.TrimSpace(data.(string))
+	if !strings.HasPrefix(raw, "[") && !strings.HasPrefix(raw, "{") {
+		return data, nil
+	}
+
+	var result interface{}
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		return nil, errors.Wrapf(err, "invalid JSON value: %s", raw)
+	}
+
+	return result, nil
+}
+
+// durationDecodeHook decodes durations from duration strings.
+// Numbers other than 0 are rejected: without a unit suffix they would silently be read as nanoseconds.
+func durationDecodeHook(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	if t != durationType || f == durationType {
+		return data, nil
+	}
+
+	value := reflect.ValueOf(data)
+
+	switch f.Kind() {
+	case reflect.String:
+		d, err := time.ParseDuration(strings.TrimSpace(value.String()))
+		if err != nil {
+			return nil, errors.Errorf("invalid duration %q, expected %s", value.String(), durationFormats)
+		}
+
+		return d, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if !value.IsZero() {
+			return nil, errors.Errorf("invalid duration %v, expected %s", data, durationFormats)
+		}
+
+		return time.Duration(0), nil
+	default:
+		return data, nil
+	}
+}
+
+// parseSize parses a size: a number of bytes or a number with a unit suffix.
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+
+	number := strings.TrimRightFunc(s, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' })
+	unit := strings.ToLower(strings.TrimSpace(s[len(number):]))
+
+	multiplier, ok := sizeUnits[unit]
+	if !ok || len(number) == 0 {
+		return 0, errors.Errorf("invalid size %q, expected %s", s, sizeFormats)
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n*multiplier > math.MaxInt32 {
+		return 0, errors.Errorf("invalid size %q, expected %s", s, sizeFormats)
+	}
+
+	return int64(n * multiplier), nil
+}
+
+// sizeDecodeHook decodes sizes from size strings and rejects negative numbers.
+func sizeDecodeHook(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	if t != sizeType || f == sizeType {
+		return data, nil
+	}
+
+	value := reflect.ValueOf(data)
+
+	switch f.Kind() {
+	case reflect.String:
+		n, err := parseSize(value.String())
+		if err != nil {
+			return nil, err
+		}
+
+		return inventory.ByteSize(n), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value.Int() < 0 {
+			return nil, errors.Errorf("invalid size %v, expected %s", data, sizeFormats)
+		}
+	case reflect.Float32, reflect.Float64:
+		if value.Float() < 0 {
+			return nil, errors.Errorf("invalid size %v, expected %s", data, sizeFormats)
+		}
+	}
+
+	return data, nil
 }
 
 // tsigAlgo processes user-supplied TSIG algorithm names.
 func tsigAlgo(algo string) string {
 	switch algo {
-	case "hmac-sha1", "hmac-sha224", "hmac-sha256", "hmac-sha384", "hmac-sha512":
+	case "hmac-sha1", "hmac-sha224", "hmac-sha256", "hmac-sha384", "hmac-sha512", "gss-tsig":
 		return algo + "."
 	default:
 		return "hmac-sha256."
@@ -75,7 +180,7 @@ func Load() (*inventory.Config, error) {
 
 	// Load YAML configuration.
 	path, ok := os.LookupEnv("ADI_CONFIG_FILE")
-	if ok {
+	if ok && len(path) > 0 {
 		// Load a specific config file.
 		v.SetConfigFile(path)
 	} else {
@@ -98,13 +203,31 @@ func Load() (*inventory.Config, error) {
 		}
 	}
 
+	return unmarshal(v)
+}
+
+// LoadFile reads the configuration from a specific config file with Viper.
+// Environment variables override the settings of the file just like with Load.
+func LoadFile(path string) (*inventory.Config, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, errors.Wrap(err, "failed to read config file")
+	}
+
+	return unmarshal(v)
+}
+
+// unmarshal binds environment variables and unmarshals the configuration into an instance of inventory.Config.
+func unmarshal(v *viper.Viper) (*inventory.Config, error) {
 	// Setup environment variables handling.
 	v.SetEnvPrefix(adiEnvPrefix)
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	v.AutomaticEnv()
 
 	// Bind environment variables to configuration keys.
-	for _, key := range configKeys() {
+	for _, key := range configKeys(reflect.TypeOf(inventory.Config{}), "") {
 		if err := v.BindEnv(key); err != nil {
 			return nil, errors.Wrap(err, "failed to bind environment variables")
 		}
@@ -113,6 +236,15 @@ func Load() (*inventory.Config, error) {
 	// Process user-supplied TSIG algorithm name.
 	v.Set("dns.tsig.algo", tsigAlgo(v.GetString("dns.tsig.algo")))
 
+	// Process datasource lists: several datasource types are kept as a comma-separated list.
+	if types, ok := v.Get("datasource").([]interface{}); ok {
+		names := make([]string, 0, len(types))
+		for _, t := range types {
+			names = append(names, fmt.Sprint(t))
+		}
+		v.Set("datasource", strings.Join(names, ","))
+	}
+
 	cfg := &inventory.Config{}
 
 	if err := defaults.Set(cfg); err != nil {
@@ -120,7 +252,22 @@ func Load() (*inventory.Config, error) {
 	}
 
 	// Unmarshal Viper configuration to an instance of inventory.Config.
-	if err := v.Unmarshal(cfg); err != nil {
+	hooks := mapstructure.ComposeDecodeHookFunc(
+		jsonDecodeHook,
+		durationDecodeHook,
+		sizeDecodeHook,
+		mapstructure.StringToSliceHookFunc(","),
+	)
+
+	if err := v.Unmarshal(cfg, viper.DecodeHook(hooks)); err != nil {
+		// Report every offending key on a single line.
+		if merr, ok := err.(*mapstructure.Error); ok {
+			messages := append([]string(nil), merr.Errors...)
+			sort.Strings(messages)
+
+			return nil, errors.Errorf("invalid configuration: %s", strings.Join(messages, "; "))
+		}
+
 		return nil, errors.Wrap(err, "failed to unmarshal configuration")
 	}
 
@@ -0,0 +1,226 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/NeonSludge/ansible-dns-inventory/pkg/inventory"
+)
+
+func Test_configKeys(t *testing.T) {
+	keys := configKeys(reflect.TypeOf(inventory.Config{}), "")
+
+	for _, key := range []string{"datasource", "dns.zones", "dns.notransfer.host", "etcd.tls.ca.pem", "txt.keys.vars", "filter.enabled", "filter.filters", "varsources.sources", "server.listen"} {
+		if !slices.Contains(keys, key) {
+			t.Errorf("configKeys() is missing key %s", key)
+		}
+	}
+
+	for _, key := range []string{"dns", "etcd.tls", "txt.keys"} {
+		if slices.Contains(keys, key) {
+			t.Errorf("configKeys() contains non-leaf key %s", key)
+		}
+	}
+}
+
+func Test_unmarshal_env(t *testing.T) {
+	t.Setenv("ADI_DATASOURCE", "etcd")
+	t.Setenv("ADI_DNS_TIMEOUT", "10s")
+	t.Setenv("ADI_DNS_ZONES", `["infra.local.", "server.local."]`)
+	t.Setenv("ADI_DNS_NOTRANSFER_ENABLED", "true")
+	t.Setenv("ADI_DNS_TSIG_ALGO", "hmac-sha512")
+	t.Setenv("ADI_DNS_TSIG_GSS_KDCS", "dc01.corp.local,dc02.corp.local:88")
+	t.Setenv("ADI_ETCD_ENDPOINTS", "10.0.0.1:2379,10.0.0.2:2379")
+	t.Setenv("ADI_ETCD_TLS_CA_PEM", "-----BEGIN CERTIFICATE-----")
+	t.Setenv("ADI_ETCD_IMPORT_BATCH", "64")
+	t.Setenv("ADI_ETCD_GRPC_MAXRECVSIZE", "4MiB")
+	t.Setenv("ADI_TXT_KEYS_ENV", "PRJ")
+	t.Setenv("ADI_FILTER_ENABLED", "true")
+	t.Setenv("ADI_FILTER_FILTERS", `[{"key": "PRJ", "operator": "in", "values": ["prod", "lab"]}]`)
+	t.Setenv("ADI_VARSOURCES_SOURCES", `[{"type": "http", "path": "https://cmdb/{host}", "timeout": "5s"}]`)
+
+	cfg, err := unmarshal(viper.New())
+	if err != nil {
+		t.Fatalf("unmarshal() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{name: "datasource", got: cfg.Datasource, want: "etcd"},
+		{name: "dns.timeout", got: cfg.DNS.Timeout, want: 10 * time.Second},
+		{name: "dns.zones", got: cfg.DNS.Zones, want: []string{"infra.local.", "server.local."}},
+		{name: "dns.notransfer.enabled", got: cfg.DNS.Notransfer.Enabled, want: true},
+		{name: "dns.notransfer.host", got: cfg.DNS.Notransfer.Host, want: "ansible-dns-inventory"},
+		{name: "dns.tsig.algo", got: cfg.DNS.Tsig.Algo, want: "hmac-sha512."},
+		{name: "dns.tsig.gss.kdcs", got: cfg.DNS.Tsig.Gss.KDCs, want: []string{"dc01.corp.local", "dc02.corp.local:88"}},
+		{name: "etcd.endpoints", got: cfg.Etcd.Endpoints, want: []string{"10.0.0.1:2379", "10.0.0.2:2379"}},
+		{name: "etcd.tls.ca.pem", got: cfg.Etcd.TLS.CA.PEM, want: "-----BEGIN CERTIFICATE-----"},
+		{name: "etcd.import.batch", got: cfg.Etcd.Import.Batch, want: 64},
+		{name: "etcd.grpc.maxrecvsize", got: cfg.Etcd.GRPC.MaxRecvSize, want: inventory.ByteSize(4 << 20)},
+		{name: "txt.keys.env", got: cfg.Txt.Keys.Env, want: "PRJ"},
+		{name: "filter.enabled", got: cfg.Filter.Enabled, want: true},
+		{name: "filter.filters", got: cfg.Filter.Filters, want: []inventory.HostFilter{{Key: "PRJ", Operator: "in", Values: []string{"prod", "lab"}}}},
+		{name: "varsources.sources", got: cfg.Varsources.Sources, want: []inventory.VarsourceSpec{{Type: "http", Path: "https://cmdb/{host}", Timeout: 5 * time.Second}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("unmarshal() %s = %v, want %v", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_unmarshal_timeouts(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		settings map[string]interface{}
+		wantDNS  inventory.Timeouts
+		wantEtcd inventory.Timeouts
+		wantErr  bool
+	}{
+		{
+			name: "valid-unset",
+		},
+		{
+			name:     "valid",
+			settings: map[string]interface{}{"dns.timeouts.dial": "2s", "dns.timeouts.read": "5s", "etcd.timeouts.write": "1m"},
+			wantDNS:  inventory.Timeouts{Dial: 2 * time.Second, Read: 5 * time.Second},
+			wantEtcd: inventory.Timeouts{Write: time.Minute},
+		},
+		{
+			name:     "valid-env",
+			env:      map[string]string{"ADI_DNS_TIMEOUTS_WRITE": "500ms", "ADI_ETCD_TIMEOUTS_DIAL": "3s", "ADI_ETCD_TIMEOUTS_READ": "4s"},
+			wantDNS:  inventory.Timeouts{Write: 500 * time.Millisecond},
+			wantEtcd: inventory.Timeouts{Dial: 3 * time.Second, Read: 4 * time.Second},
+		},
+		{
+			name:     "invalid-duration",
+			settings: map[string]interface{}{"etcd.timeouts.read": "4"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			v := viper.New()
+			for key, value := range tt.settings {
+				v.Set(key, value)
+			}
+
+			cfg, err := unmarshal(v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(cfg.DNS.Timeouts, tt.wantDNS) {
+				t.Errorf("unmarshal() dns.timeouts = %+v, want %+v", cfg.DNS.Timeouts, tt.wantDNS)
+			}
+
+			if !reflect.DeepEqual(cfg.Etcd.Timeouts, tt.wantEtcd) {
+				t.Errorf("unmarshal() etcd.timeouts = %+v, want %+v", cfg.Etcd.Timeouts, tt.wantEtcd)
+			}
+		})
+	}
+}
+
+func Test_unmarshal_invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		want     []string
+	}{
+		{
+			name:     "valid-template",
+			settings: map[string]interface{}{},
+			want:     nil,
+		},
+		{
+			name:     "invalid-duration-unit",
+			settings: map[string]interface{}{"dns.timeout": "10x"},
+			want:     []string{"'dns.timeout'", `invalid duration "10x"`, `"1m30s"`},
+		},
+		{
+			name:     "invalid-duration-number",
+			settings: map[string]interface{}{"dns.timeout": 30},
+			want:     []string{"'dns.timeout'", "invalid duration 30", `"1m30s"`},
+		},
+		{
+			name:     "invalid-duration-list",
+			settings: map[string]interface{}{"varsources.sources": `[{"type": "http", "path": "https://cmdb/{host}", "timeout": "5"}]`},
+			want:     []string{"'varsources.sources[0].", `invalid duration "5"`},
+		},
+		{
+			name:     "invalid-size",
+			settings: map[string]interface{}{"etcd.grpc.maxrecvsize": "4MX", "etcd.grpc.maxsendsize": -1},
+			want:     []string{"'etcd.grpc.maxrecvsize'", `invalid size "4MX"`, "'etcd.grpc.maxsendsize'", "invalid size -1", `"4MiB"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			v.SetConfigFile("../../config/ansible-dns-inventory.yaml")
+			if err := v.ReadInConfig(); err != nil {
+				t.Fatal(err)
+			}
+
+			for key, value := range tt.settings {
+				v.Set(key, value)
+			}
+
+			_, err := unmarshal(v)
+			if (err != nil) != (tt.want != nil) {
+				t.Fatalf("unmarshal() error = %v, want %v", err, tt.want)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("unmarshal() error = %v, want %s", err, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_unmarshal_datasource(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{name: "valid-single", value: "etcd", want: "etcd"},
+		{name: "valid-string-list", value: "dns,etcd", want: "dns,etcd"},
+		{name: "valid-list", value: []interface{}{"dns", "etcd", "csv"}, want: "dns,etcd,csv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			v.Set("datasource", tt.value)
+
+			cfg, err := unmarshal(v)
+			if err != nil {
+				t.Fatalf("unmarshal() error = %v", err)
+			}
+
+			if cfg.Datasource != tt.want {
+				t.Errorf("unmarshal() datasource = %v, want %v", cfg.Datasource, tt.want)
+			}
+		})
+	}
+}
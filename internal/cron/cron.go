@@ -0,0 +1,294 @@
+package cron
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/NeonSludge/ansible-dns-inventory/internal/election"
+	"github.com/NeonSludge/ansible-dns-inventory/internal/util"
+	"github.com/NeonSludge/ansible-dns-inventory/pkg/inventory"
+)
+
+const (
+	// Default export format.
+	cronDefaultFormat string = "yaml"
+	// Default push request timeout.
+	cronDefaultTimeout time.Duration = 30 * time.Second
+)
+
+// cronExports lists the supported kinds of exported data.
+var cronExports = []string{"list", "hosts", "groups", "attrs", "tree"}
+
+// Daemon rebuilds the inventory on a schedule and writes the configured exports.
+type Daemon struct {
+	// Inventory.
+	Inventory *inventory.Inventory
+	// Daemon logger.
+	Logger inventory.Logger
+	// Export schedule.
+	Schedule Schedule
+
+	// HTTP client used to push exports.
+	client *http.Client
+	// Leader election among redundant daemon instances.
+	elector leaderElector
+}
+
+// leaderElector takes part in leader election among redundant daemon instances.
+type leaderElector interface {
+	// IsLeader reports whether this instance holds the leadership.
+	IsLeader() bool
+	// Run takes part in the leader election until the context is cancelled.
+	Run(ctx context.Context)
+}
+
+// contentType returns the MIME type of an export format.
+func contentType(format string) string {
+	switch format {
+	case "json":
+		return "application/json"
+	default:
+		return "application/yaml"
+	}
+}
+
+// validate checks the export configuration before the first run.
+func (d *Daemon) validate() error {
+	cfg := d.Inventory.Config
+
+	if len(cfg.Cron.Exports) == 0 {
+		return errors.New("no exports configured")
+	}
+
+	for n, spec := range cfg.Cron.Exports {
+		if !slices.Contains(cronExports, spec.Export) {
+			return errors.Errorf("export %d: unsupported export: %s", n, spec.Export)
+		}
+
+		if len(spec.Path) == 0 && len(spec.URL) == 0 {
+			return errors.Errorf("export %d: either a path or a URL is required", n)
+		}
+	}
+
+	return nil
+}
+
+// build exports the inventory tree.
+func (d *Daemon) build(export string, hosts map[string][]*inventory.HostAttributes) interface{} {
+	inv := d.Inventory
+
+	switch export {
+	case "list":
+		list := make(map[string]*inventory.AnsibleGroup)
+		inv.ExportInventory(list)
+		return list
+	case "hosts":
+		groups := make(map[string][]string)
+		inv.ExportHosts(groups)
+		return groups
+	case "groups":
+		members := make(map[string][]string)
+		inv.ExportGroups(members)
+		return members
+	case "attrs":
+		return hosts
+	default:
+		return inv.Tree
+	}
+}
+
+// writeFile replaces a file atomically by writing to a temporary file in the same directory and renaming it.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// push sends an export to a URL with a POST request.
+func (d *Daemon) push(ctx context.Context, spec inventory.ExportSpec, format string, data []byte) error {
+	timeout := spec.Timeout
+	if timeout == 0 {
+		timeout = cronDefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, spec.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType(format))
+	req.Header.Set("User-Agent", "ansible-dns-inventory/"+inventory.Version().Version)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Export rebuilds the inventory and writes all configured exports.
+// Exports are written independently: a failed export does not prevent the others from being written, and a failed rebuild leaves all of them as is.
+func (d *Daemon) Export(ctx context.Context) error {
+	inv := d.Inventory
+	cfg := inv.Config
+	log := d.Logger
+
+	hosts, err := inv.GetHosts()
+	if err != nil {
+		return err
+	}
+
+	if len(hosts) == 0 {
+		return errors.New("no host records found")
+	}
+
+	inv.Tree = inventory.NewTree()
+	if err := inv.ImportHosts(hosts); err != nil {
+		return err
+	}
+	inv.TrackChanges(hosts)
+
+	var failed int
+	for n, spec := range cfg.Cron.Exports {
+		format := spec.Format
+		if len(format) == 0 {
+			format = cronDefaultFormat
+		}
+
+		data, err := util.Marshal(inv.Redact(d.build(spec.Export, hosts)), format, cfg)
+		if err != nil {
+			log.Warnf("[export %d] %v", n, err)
+			failed++
+			continue
+		}
+
+		if len(spec.Path) > 0 {
+			if err := writeFile(spec.Path, data); err != nil {
+				log.Warnf("[export %d] file write failure: %v", n, err)
+				failed++
+			}
+		}
+
+		if len(spec.URL) > 0 {
+			if err := d.push(ctx, spec, format, data); err != nil {
+				log.Warnf("[export %d] push failure: %v", n, err)
+				failed++
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d export(s) failed", failed)
+	}
+
+	return nil
+}
+
+// Run writes the exports once on startup and then on every scheduled activation until the context is cancelled.
+func (d *Daemon) Run(ctx context.Context) error {
+	log := d.Logger
+
+	if err := d.validate(); err != nil {
+		return errors.Wrap(err, "export configuration error")
+	}
+
+	log.Infof("running scheduled exports (ansible-dns-inventory %s)", inventory.Version())
+
+	if d.Inventory.Config.Cron.Election.Enabled {
+		go d.elector.Run(ctx)
+	}
+
+	for {
+		start := time.Now()
+		if !d.IsLeader() {
+			log.Debug("skipping scheduled export: not the leader")
+		} else if err := d.Export(ctx); err != nil {
+			log.Warnf("scheduled export failure: %v", err)
+		} else {
+			log.Infof("scheduled export completed in %s", time.Since(start).Round(time.Millisecond))
+		}
+
+		next := d.Schedule.Next(time.Now())
+		if next.IsZero() {
+			return errors.New("schedule has no future activations")
+		}
+
+		log.Debugf("next scheduled export at %s", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			log.Info("shutting down")
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// IsLeader reports whether this daemon instance should write the exports. It always returns true if leader election is disabled.
+func (d *Daemon) IsLeader() bool {
+	if !d.Inventory.Config.Cron.Election.Enabled {
+		return true
+	}
+
+	return d.elector.IsLeader()
+}
+
+// New creates a scheduled export daemon.
+func New(inv *inventory.Inventory, schedule Schedule) *Daemon {
+	// The next leader reports changes from its own baseline, this instance starts a new one if it is elected again.
+	elector := &election.Elector{
+		Config:   inv.Config,
+		Logger:   inv.Logger,
+		Key:      inv.Config.Cron.Election.Key,
+		TTL:      inv.Config.Cron.Election.TTL,
+		OnResign: inv.ResetChanges,
+	}
+
+	return &Daemon{
+		Inventory: inv,
+		Logger:    inv.Logger,
+		Schedule:  schedule,
+		client:    &http.Client{},
+		elector:   elector,
+	}
+}
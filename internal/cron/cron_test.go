@@ -0,0 +1,121 @@
+package cron
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/creasty/defaults"
+
+	"github.com/NeonSludge/ansible-dns-inventory/pkg/inventory"
+)
+
+type (
+	// testLogger discards all messages.
+	testLogger struct {
+		inventory.Logger
+	}
+
+	// testElector holds the leadership if set.
+	testElector bool
+
+	// testDatasource serves a fixed set of host records.
+	testDatasource struct {
+		records []*inventory.DatasourceRecord
+	}
+)
+
+func (l *testLogger) Info(args ...interface{})                    {}
+func (l *testLogger) Infof(template string, args ...interface{})  {}
+func (l *testLogger) Warnf(template string, args ...interface{})  {}
+func (l *testLogger) Debug(args ...interface{})                   {}
+func (l *testLogger) Debugf(template string, args ...interface{}) {}
+
+func (e testElector) IsLeader() bool { return bool(e) }
+
+func (e testElector) Run(ctx context.Context) {}
+
+func (d *testDatasource) GetAllRecords() ([]*inventory.DatasourceRecord, error) {
+	return d.records, nil
+}
+
+func (d *testDatasource) GetHostRecords(host string) ([]*inventory.DatasourceRecord, error) {
+	return nil, nil
+}
+
+func (d *testDatasource) PublishRecords(records []*inventory.DatasourceRecord) error {
+	return nil
+}
+
+func (d *testDatasource) Close() {}
+
+func TestDaemon_Run_election(t *testing.T) {
+	tests := []struct {
+		name     string
+		election bool
+		leader   bool
+		want     bool
+	}{
+		{
+			// Every instance writes the exports without leader election.
+			name: "valid-no-election",
+			want: true,
+		},
+		{
+			name:     "valid-leader",
+			election: true,
+			leader:   true,
+			want:     true,
+		},
+		{
+			name:     "valid-follower",
+			election: true,
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "hosts.yaml")
+
+			cfg := &inventory.Config{}
+			if err := defaults.Set(cfg); err != nil {
+				t.Fatal(err)
+			}
+			cfg.Cron.Exports = []inventory.ExportSpec{{Export: "hosts", Path: path}}
+			cfg.Cron.Election.Enabled = tt.election
+
+			inv, err := inventory.New(cfg, &testLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer inv.Close()
+
+			inv.Datasource = &testDatasource{records: []*inventory.DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=app;SRV=;VARS="},
+			}}
+
+			schedule, err := ParseSchedule("@every 1h")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d := New(inv, schedule)
+			d.elector = testElector(tt.leader)
+
+			// The exports are written on startup if this instance is the leader.
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			if err := d.Run(ctx); err != nil {
+				t.Fatalf("Daemon.Run() error = %v", err)
+			}
+
+			_, err = os.Stat(path)
+			if got := err == nil; got != tt.want {
+				t.Errorf("Daemon.Run() export written = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
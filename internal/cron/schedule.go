@@ -0,0 +1,193 @@
+package cron
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Maximum time span searched for the next activation of a schedule.
+const scheduleSearchLimit time.Duration = 5 * 366 * 24 * time.Hour
+
+// scheduleAliases maps predefined schedules to their cron expressions.
+var scheduleAliases = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type (
+	// Schedule determines when scheduled exports run.
+	Schedule interface {
+		// Next returns the first activation time after t.
+		Next(t time.Time) time.Time
+	}
+
+	// intervalSchedule runs at a fixed interval ('@every <duration>').
+	intervalSchedule struct {
+		interval time.Duration
+	}
+
+	// cronSchedule runs at times matching a 5-field cron expression. Every field is a bit set of matching values.
+	cronSchedule struct {
+		minute, hour, dom, month, dow uint64
+		// The day of month or the day of week field is a wildcard.
+		domStar, dowStar bool
+	}
+)
+
+// Next returns the first activation time after t.
+func (s *intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval)
+}
+
+// Next returns the first activation time after t or the zero time if there is none within the search limit.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	limit := t.Add(scheduleSearchLimit)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// matchDay checks the day fields. As in cron, if both of them are restricted, either of them has to match.
+func (s *cronSchedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+// parseField parses a comma-separated list of values, ranges and steps (e.g. '*/15', '1-5', '0,30') into a bit set.
+func parseField(field string, min int, max int) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		expr, stepStr, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return 0, errors.Errorf("invalid step: %s", part)
+			}
+		}
+
+		var lo, hi int
+		switch {
+		case expr == "*":
+			lo, hi = min, max
+		case strings.Contains(expr, "-"):
+			loStr, hiStr, _ := strings.Cut(expr, "-")
+
+			var err error
+			if lo, err = strconv.Atoi(loStr); err != nil {
+				return 0, errors.Errorf("invalid range: %s", part)
+			}
+			if hi, err = strconv.Atoi(hiStr); err != nil {
+				return 0, errors.Errorf("invalid range: %s", part)
+			}
+		default:
+			var err error
+			if lo, err = strconv.Atoi(expr); err != nil {
+				return 0, errors.Errorf("invalid value: %s", part)
+			}
+
+			hi = lo
+			if hasStep {
+				hi = max
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return 0, errors.Errorf("value out of range [%d-%d]: %s", min, max, part)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+// ParseSchedule parses a 5-field cron expression (minute, hour, day of month, month, day of week),
+// a predefined schedule (e.g. '@hourly') or a fixed interval (e.g. '@every 5m').
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	if interval, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid schedule interval")
+		}
+
+		if d < time.Second {
+			return nil, errors.Errorf("schedule interval is too short: %s", d)
+		}
+
+		return &intervalSchedule{interval: d}, nil
+	}
+
+	if expr, ok := scheduleAliases[spec]; ok {
+		spec = expr
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("invalid schedule: expected 5 fields, got %d: %s", len(fields), spec)
+	}
+
+	s := &cronSchedule{
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+
+	var err error
+	if s.minute, err = parseField(fields[0], 0, 59); err != nil {
+		return nil, errors.Wrap(err, "invalid minute field")
+	}
+	if s.hour, err = parseField(fields[1], 0, 23); err != nil {
+		return nil, errors.Wrap(err, "invalid hour field")
+	}
+	if s.dom, err = parseField(fields[2], 1, 31); err != nil {
+		return nil, errors.Wrap(err, "invalid day of month field")
+	}
+	if s.month, err = parseField(fields[3], 1, 12); err != nil {
+		return nil, errors.Wrap(err, "invalid month field")
+	}
+	if s.dow, err = parseField(fields[4], 0, 7); err != nil {
+		return nil, errors.Wrap(err, "invalid day of week field")
+	}
+
+	// Both 0 and 7 stand for Sunday.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	return s, nil
+}
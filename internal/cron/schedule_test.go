@@ -0,0 +1,101 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2024, time.March, 15, 10, 7, 30, 0, time.UTC) // Friday
+
+	tests := []struct {
+		name    string
+		spec    string
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name:    "valid-every-minute",
+			spec:    "* * * * *",
+			want:    time.Date(2024, time.March, 15, 10, 8, 0, 0, time.UTC),
+			wantErr: false,
+		},
+		{
+			name:    "valid-step",
+			spec:    "*/15 * * * *",
+			want:    time.Date(2024, time.March, 15, 10, 15, 0, 0, time.UTC),
+			wantErr: false,
+		},
+		{
+			name:    "valid-list-range",
+			spec:    "0,30 8-9 * * *",
+			want:    time.Date(2024, time.March, 16, 8, 0, 0, 0, time.UTC),
+			wantErr: false,
+		},
+		{
+			name:    "valid-dow-sunday",
+			spec:    "0 3 * * 7",
+			want:    time.Date(2024, time.March, 17, 3, 0, 0, 0, time.UTC),
+			wantErr: false,
+		},
+		{
+			name:    "valid-dom-or-dow",
+			spec:    "0 0 1 * 1",
+			want:    time.Date(2024, time.March, 18, 0, 0, 0, 0, time.UTC),
+			wantErr: false,
+		},
+		{
+			name:    "valid-alias",
+			spec:    "@monthly",
+			want:    time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC),
+			wantErr: false,
+		},
+		{
+			name:    "valid-leap-day",
+			spec:    "0 0 29 2 *",
+			want:    time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC),
+			wantErr: false,
+		},
+		{
+			name:    "valid-interval",
+			spec:    "@every 5m",
+			want:    time.Date(2024, time.March, 15, 10, 12, 30, 0, time.UTC),
+			wantErr: false,
+		},
+		{
+			name:    "invalid-fields",
+			spec:    "* * * *",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-range",
+			spec:    "60 * * * *",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-step",
+			spec:    "*/0 * * * *",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-interval",
+			spec:    "@every 10ms",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSchedule(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseSchedule() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if next := got.Next(from); !next.Equal(tt.want) {
+				t.Errorf("ParseSchedule().Next() = %v, want %v", next, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package election
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/client/v3/concurrency"
+
+	"github.com/NeonSludge/ansible-dns-inventory/pkg/inventory"
+)
+
+const (
+	// Delay before campaigning again after losing an etcd session.
+	retryDelay time.Duration = 5 * time.Second
+)
+
+// Elector takes part in an etcd leader election among redundant instances, so that only one of them performs tasks with side effects.
+type Elector struct {
+	// Inventory configuration, the etcd cluster configured in the 'etcd' section is used regardless of the datasource.
+	Config *inventory.Config
+	// Elector logger.
+	Logger inventory.Logger
+	// Election key, relative to the etcd k/v path prefix.
+	Key string
+	// Leadership lease TTL.
+	TTL time.Duration
+	// Called when the leadership is lost or given up, may be nil.
+	OnResign func()
+
+	// This instance holds the leadership.
+	leader atomic.Bool
+}
+
+// IsLeader reports whether this instance holds the leadership.
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// resign gives up the leadership.
+func (e *Elector) resign() {
+	e.leader.Store(false)
+
+	if e.OnResign != nil {
+		e.OnResign()
+	}
+}
+
+// campaign waits for this instance to become the leader and holds the leadership until the session expires or the context is cancelled.
+func (e *Elector) campaign(ctx context.Context, session *concurrency.Session, identity string) error {
+	election := concurrency.NewElection(session, e.Key)
+	if err := election.Campaign(ctx, identity); err != nil {
+		return err
+	}
+
+	e.leader.Store(true)
+	e.Logger.Infof("elected as the leader: %s", identity)
+
+	select {
+	case <-ctx.Done():
+		e.resign()
+
+		rctx, cancel := context.WithTimeout(context.Background(), e.Config.Etcd.Timeout)
+		defer cancel()
+
+		return election.Resign(rctx)
+	case <-session.Done():
+		e.resign()
+
+		return errors.New("etcd session expired")
+	}
+}
+
+// Run takes part in the leader election until the context is cancelled.
+func (e *Elector) Run(ctx context.Context) {
+	log := e.Logger
+
+	hostname, _ := os.Hostname()
+	identity := fmt.Sprintf("%s/%d", hostname, os.Getpid())
+
+	client, err := inventory.NewEtcdClient(e.Config)
+	if err != nil {
+		log.Errorf("leader election failure: %v", err)
+		return
+	}
+	defer client.Close()
+
+	for ctx.Err() == nil {
+		session, err := concurrency.NewSession(client, concurrency.WithTTL(int(e.TTL.Seconds())), concurrency.WithContext(ctx))
+		if err == nil {
+			err = e.campaign(ctx, session, identity)
+			session.Close()
+		}
+
+		if err != nil && ctx.Err() == nil {
+			log.Warnf("leader election failure: %v", err)
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(retryDelay):
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/NeonSludge/ansible-dns-inventory/pkg/inventory"
+)
+
+// editError writes a host editing error with a status code matching its cause.
+func editError(w http.ResponseWriter, err error) {
+	var editErr *inventory.HostEditError
+	var policyErr *inventory.PolicyError
+
+	switch {
+	case errors.As(err, &editErr):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.As(err, &policyErr):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+}
+
+// refreshAfterEdit rebuilds the inventory tree so that edits are served immediately.
+func (s *Server) refreshAfterEdit(host string) {
+	if err := s.Refresh(); err != nil {
+		s.Logger.Warnf("[%s] inventory refresh failure after editing: %v", host, err)
+	}
+}
+
+// handlePutHost replaces all records of a host with the attribute sets in the request body: a YAML or JSON list of dictionaries of attributes.
+func (s *Server) handlePutHost(w http.ResponseWriter, r *http.Request) {
+	cfg := s.Inventory.Config
+	host := r.PathValue("name")
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(cfg.Server.API.MaxBody)))
+	if err != nil {
+		http.Error(w, errors.Wrap(err, "request body reading failure").Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	attrs := make([]*inventory.HostAttributes, 0)
+	if err := yaml.Unmarshal(body, &attrs); err != nil {
+		http.Error(w, errors.Wrap(err, "request body parsing failure").Error(), http.StatusBadRequest)
+		return
+	}
+
+	records, err := s.Inventory.EditHostAs(actor(r), host, attrs)
+	if err != nil {
+		editError(w, err)
+		return
+	}
+
+	s.Logger.Infof("[%s] host edited via API: %d record(s)", host, len(records))
+	s.refreshAfterEdit(host)
+
+	s.write(w, r, records)
+}
+
+// handleDeleteHost removes all records of a host.
+func (s *Server) handleDeleteHost(w http.ResponseWriter, r *http.Request) {
+	host := r.PathValue("name")
+
+	records, err := s.Inventory.DeleteHostAs(actor(r), host)
+	if err != nil {
+		editError(w, err)
+		return
+	}
+
+	if len(records) == 0 {
+		http.Error(w, "host not found", http.StatusNotFound)
+		return
+	}
+
+	s.Logger.Infof("[%s] host deleted via API: %d record(s)", host, len(records))
+	s.refreshAfterEdit(host)
+
+	s.write(w, r, records)
+}
@@ -0,0 +1,255 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/NeonSludge/ansible-dns-inventory/pkg/inventory"
+)
+
+const (
+	// Permission to read inventory data.
+	readPermission string = "read"
+	// Permission to edit hosts.
+	editPermission string = "edit"
+
+	// Static token authentication provider.
+	staticAuthProviderType string = "static"
+	// OpenID Connect authentication provider.
+	oidcAuthProviderType string = "oidc"
+)
+
+// identityKey is the request context key of the authenticated identity.
+type identityKey struct{}
+
+// identity represents an authenticated client.
+type identity struct {
+	// Identity name.
+	Name string
+	// Identity groups.
+	Groups []string
+	// Permissions granted regardless of the groups.
+	Permissions []string
+}
+
+// authProvider authenticates bearer tokens.
+type authProvider interface {
+	// Authenticate returns the identity a bearer token belongs to, or nil if the provider does not handle tokens of this kind.
+	// An error is returned for tokens the provider handles but cannot accept.
+	Authenticate(token string) (*identity, error)
+}
+
+// apiTokenProvider accepts the host editing API tokens, identifying clients by the fingerprint of the token.
+type apiTokenProvider struct {
+	tokens []string
+}
+
+// Authenticate implements authProvider.
+func (p *apiTokenProvider) Authenticate(token string) (*identity, error) {
+	for _, t := range p.tokens {
+		if len(t) > 0 && subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			sum := sha256.Sum256([]byte(token))
+
+			return &identity{Name: "token:" + hex.EncodeToString(sum[:4]), Permissions: []string{readPermission, editPermission}}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// staticAuthProvider accepts named bearer tokens from the configuration.
+type staticAuthProvider struct {
+	tokens []inventory.AuthTokenSpec
+}
+
+// Authenticate implements authProvider.
+func (p *staticAuthProvider) Authenticate(token string) (*identity, error) {
+	for _, t := range p.tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+			return &identity{Name: t.Name, Groups: t.Groups}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// authenticator authenticates requests and checks their permissions.
+type authenticator struct {
+	// Authentication providers, in order.
+	providers []authProvider
+	// Permissions of requests without a bearer token.
+	anonymous map[string]bool
+	// Permissions of groups.
+	groups map[string]map[string]bool
+}
+
+// permissionSet validates a list of permissions and converts it to a set.
+func permissionSet(permissions []string) (map[string]bool, error) {
+	set := make(map[string]bool, len(permissions))
+
+	for _, p := range permissions {
+		if p != readPermission && p != editPermission {
+			return nil, errors.Errorf("unknown permission: %s", p)
+		}
+
+		set[p] = true
+	}
+
+	return set, nil
+}
+
+// authenticate returns the identity of a request, or nil if the request has no bearer token.
+func (a *authenticator) authenticate(r *http.Request) (*identity, error) {
+	header := r.Header.Get("Authorization")
+	if len(header) == 0 {
+		return nil, nil
+	}
+
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || len(token) == 0 {
+		return nil, errors.New("not a bearer token")
+	}
+
+	for _, p := range a.providers {
+		id, err := p.Authenticate(token)
+		if err != nil {
+			return nil, err
+		}
+
+		if id != nil {
+			return id, nil
+		}
+	}
+
+	return nil, errors.New("unknown token")
+}
+
+// allowed checks whether an identity has a permission. Authenticated identities have the permissions of anonymous requests too.
+func (a *authenticator) allowed(id *identity, permission string) bool {
+	if a.anonymous[permission] {
+		return true
+	}
+
+	if id == nil {
+		return false
+	}
+
+	for _, p := range id.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	for _, g := range id.Groups {
+		if a.groups[g][permission] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newAuthenticator creates an authenticator with the configured providers.
+// The host editing API tokens are always accepted first.
+func newAuthenticator(cfg *inventory.Config) (*authenticator, error) {
+	a := &authenticator{
+		providers: []authProvider{&apiTokenProvider{tokens: cfg.Server.API.Tokens}},
+		groups:    make(map[string]map[string]bool),
+	}
+
+	var err error
+	if a.anonymous, err = permissionSet(cfg.Server.Auth.Anonymous); err != nil {
+		return nil, errors.Wrap(err, "anonymous permissions")
+	}
+
+	for _, g := range cfg.Server.Auth.Groups {
+		set, err := permissionSet(g.Permissions)
+		if err != nil {
+			return nil, errors.Wrapf(err, "group %s", g.Group)
+		}
+
+		if a.groups[g.Group] == nil {
+			a.groups[g.Group] = make(map[string]bool)
+		}
+		for p := range set {
+			a.groups[g.Group][p] = true
+		}
+	}
+
+	for _, name := range cfg.Server.Auth.Providers {
+		switch name {
+		case staticAuthProviderType:
+			for _, t := range cfg.Server.Auth.Static.Tokens {
+				if len(t.Name) == 0 || len(t.Token) == 0 {
+					return nil, errors.New("static token name or token is not set")
+				}
+			}
+
+			a.providers = append(a.providers, &staticAuthProvider{tokens: cfg.Server.Auth.Static.Tokens})
+		case oidcAuthProviderType:
+			p, err := newOIDCAuthProvider(cfg)
+			if err != nil {
+				return nil, err
+			}
+
+			a.providers = append(a.providers, p)
+		default:
+			return nil, errors.Errorf("unknown authentication provider: %s", name)
+		}
+	}
+
+	return a, nil
+}
+
+// require rejects requests without a permission: '401 Unauthorized' if the request is not authenticated, '403 Forbidden' otherwise.
+// The identity of the request is passed to the handler in the request context.
+func (s *Server) require(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := s.auth.authenticate(r)
+		if err != nil {
+			s.Logger.Warnf("[%s] authentication failure: %v", r.RemoteAddr, err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ansible-dns-inventory", error="invalid_token"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if !s.auth.allowed(id, permission) {
+			if id == nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="ansible-dns-inventory"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		if id != nil {
+			r = r.WithContext(context.WithValue(r.Context(), identityKey{}, id))
+		}
+
+		next(w, r)
+	}
+}
+
+// actor identifies the client of a request by its authenticated identity and its address.
+func actor(r *http.Request) *inventory.Actor {
+	name := "anonymous"
+	if id, ok := r.Context().Value(identityKey{}).(*identity); ok {
+		name = id.Name
+	}
+
+	address, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		address = r.RemoteAddr
+	}
+
+	return &inventory.Actor{Name: name, Address: address}
+}
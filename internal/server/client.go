@@ -0,0 +1,164 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/NeonSludge/ansible-dns-inventory/pkg/inventory"
+)
+
+// serverURL returns the URL of the inventory server listening on the configured address, using the loopback address if the server listens on all addresses.
+func serverURL(cfg *inventory.Config) (string, error) {
+	host, port, err := net.SplitHostPort(cfg.Server.Listen)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid listen address")
+	}
+
+	if ip := net.ParseIP(host); len(host) == 0 || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+		if ip != nil && ip.To4() == nil {
+			host = "::1"
+		}
+	}
+
+	return "http://" + net.JoinHostPort(host, port), nil
+}
+
+// clientCredentialsToken requests an access token from the OIDC issuer with the client credentials grant.
+func clientCredentialsToken(cfg *inventory.Config) (string, error) {
+	issuer := strings.TrimSuffix(cfg.Server.Auth.OIDC.Issuer, "/")
+	if len(issuer) == 0 {
+		return "", errors.New("oidc issuer is not set")
+	}
+
+	client, err := newOIDCClient(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	discovery := struct {
+		TokenEndpoint string `json:"token_endpoint"`
+	}{}
+	if err := (&oidcAuthProvider{Config: cfg, client: client}).get(issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return "", errors.Wrap(err, "discovery failure")
+	}
+
+	if len(discovery.TokenEndpoint) == 0 {
+		return "", errors.New("discovery failure: no token endpoint")
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(cfg.Server.Client.Scopes) > 0 {
+		form.Set("scope", strings.Join(cfg.Server.Client.Scopes, " "))
+	}
+
+	req, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(cfg.Server.Client.ClientID), url.QueryEscape(cfg.Server.Client.ClientSecret))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+		return "", errors.Errorf("token request failure: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	token := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", errors.Wrap(err, "token response parsing failure")
+	}
+
+	if len(token.AccessToken) == 0 {
+		return "", errors.New("token response has no access token")
+	}
+
+	return token.AccessToken, nil
+}
+
+// clientToken returns the bearer token used to authenticate requests to the inventory server: the configured token, the token read from
+// the configured file, an access token requested from the OIDC issuer or the first host editing API token, whichever is configured first.
+// No token is used if none are configured.
+func clientToken(cfg *inventory.Config) (string, error) {
+	client := cfg.Server.Client
+
+	switch {
+	case len(client.Token) > 0:
+		return client.Token, nil
+	case len(client.TokenPath) > 0:
+		token, err := os.ReadFile(client.TokenPath)
+		if err != nil {
+			return "", errors.Wrap(err, "token file reading failure")
+		}
+
+		return strings.TrimSpace(string(token)), nil
+	case len(client.ClientID) > 0:
+		token, err := clientCredentialsToken(cfg)
+		return token, errors.Wrap(err, "oidc client credentials")
+	case len(cfg.Server.API.Tokens) > 0:
+		return cfg.Server.API.Tokens[0], nil
+	default:
+		return "", nil
+	}
+}
+
+// RequestZoneRefresh asks the inventory server running with the same configuration to refresh a single zone and returns the new inventory metadata.
+// The request is authenticated with the configured client credentials (see clientToken).
+func RequestZoneRefresh(cfg *inventory.Config, zone string) (*inventory.InventoryMetadata, error) {
+	base, err := serverURL(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, base+"/refresh?zone="+url.QueryEscape(zone), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	token, err := clientToken(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: cfg.Server.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "zone refresh request failure")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+		return nil, errors.Errorf("zone refresh failure: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	metadata := &inventory.InventoryMetadata{}
+	if err := json.NewDecoder(resp.Body).Decode(metadata); err != nil {
+		return nil, errors.Wrap(err, "zone refresh response parsing failure")
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// startTestIssuer starts an OIDC issuer issuing an access token for the client credentials grant.
+func startTestIssuer(t *testing.T) *httptest.Server {
+	var issuer *httptest.Server
+	issuer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"issuer": issuer.URL, "token_endpoint": issuer.URL + "/token"})
+		case "/token":
+			id, secret, _ := r.BasicAuth()
+			if r.PostFormValue("grant_type") != "client_credentials" || id != "adi" || secret != "s3cr3t" {
+				http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+				return
+			}
+
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "issued:" + r.PostFormValue("scope"), "token_type": "Bearer"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(issuer.Close)
+
+	return issuer
+}
+
+func Test_clientToken(t *testing.T) {
+	issuer := startTestIssuer(t)
+
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		token        string
+		tokenPath    string
+		clientID     string
+		clientSecret string
+		apiTokens    []string
+		want         string
+		wantErr      bool
+	}{
+		{
+			name:      "valid-token",
+			token:     "static",
+			tokenPath: path,
+			apiTokens: []string{"api"},
+			want:      "static",
+		},
+		{
+			name:      "valid-token-path",
+			tokenPath: path,
+			clientID:  "adi",
+			want:      "from-file",
+		},
+		{
+			name:         "valid-client-credentials",
+			clientID:     "adi",
+			clientSecret: "s3cr3t",
+			apiTokens:    []string{"api"},
+			want:         "issued:inventory",
+		},
+		{
+			name:      "valid-api-token",
+			apiTokens: []string{"api", "other"},
+			want:      "api",
+		},
+		{
+			name: "valid-anonymous",
+			want: "",
+		},
+		{
+			name:      "invalid-token-path",
+			tokenPath: path + ".missing",
+			wantErr:   true,
+		},
+		{
+			name:         "invalid-client-credentials",
+			clientID:     "adi",
+			clientSecret: "wrong",
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			cfg.Server.Auth.OIDC.Issuer = issuer.URL
+			cfg.Server.Client.Token = tt.token
+			cfg.Server.Client.TokenPath = tt.tokenPath
+			cfg.Server.Client.ClientID = tt.clientID
+			cfg.Server.Client.ClientSecret = tt.clientSecret
+			cfg.Server.Client.Scopes = []string{"inventory"}
+			cfg.Server.API.Tokens = tt.apiTokens
+
+			got, err := clientToken(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("clientToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("clientToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestZoneRefresh(t *testing.T) {
+	var authorization string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+
+		if r.Method != http.MethodPost || r.URL.Path != "/refresh" || r.URL.Query().Get("zone") != "corp.local." {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+
+		w.Write([]byte(`{"hosts": 2}`))
+	}))
+	defer srv.Close()
+
+	cfg := newTestConfig(t)
+	cfg.Server.Listen = strings.TrimPrefix(srv.URL, "http://")
+	cfg.Server.Client.Token = "static"
+
+	metadata, err := RequestZoneRefresh(cfg, "corp.local.")
+	if err != nil {
+		t.Fatalf("RequestZoneRefresh() error = %v", err)
+	}
+
+	if metadata.Hosts != 2 {
+		t.Errorf("RequestZoneRefresh() hosts = %d, want 2", metadata.Hosts)
+	}
+
+	if authorization != "Bearer static" {
+		t.Errorf("RequestZoneRefresh() authorization = %q, want %q", authorization, "Bearer static")
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Maximum length of a single character string in a TXT record.
+	dnsTxtStringMaxLength int = 255
+)
+
+// dnsIndex maps lower case FQDNs to the TXT strings served for them.
+type dnsIndex map[string][]string
+
+// splitTxt splits a string into chunks that fit into TXT record character strings.
+func splitTxt(s string) []string {
+	chunks := make([]string, 0, len(s)/dnsTxtStringMaxLength+1)
+
+	for len(s) > dnsTxtStringMaxLength {
+		chunks = append(chunks, s[:dnsTxtStringMaxLength])
+		s = s[dnsTxtStringMaxLength:]
+	}
+
+	return append(chunks, s)
+}
+
+// buildDNSIndex prepares the records served over DNS: host attributes for every host and host lists for every group.
+// Must be called with the lock held.
+func (s *Server) buildDNSIndex() {
+	cfg := s.Inventory.Config
+	log := s.Logger
+
+	index := make(dnsIndex)
+
+	for host, attrsList := range s.hosts {
+		name := strings.ToLower(dns.Fqdn(host))
+
+		for _, attrs := range attrsList {
+			record, err := s.Inventory.RenderAttributes(attrs)
+			if err != nil {
+				log.Warnf("[%s] skipping host record: %v", host, err)
+				continue
+			}
+
+			index[name] = append(index[name], record)
+		}
+	}
+
+	groups := make(map[string][]string)
+	s.Inventory.ExportGroups(groups)
+
+	zone := dns.Fqdn(cfg.Server.DNS.Zone)
+	for group, hosts := range groups {
+		index[strings.ToLower(group+"."+zone)] = hosts
+	}
+
+	s.dnsIndex = index
+}
+
+// handleDNS answers TXT queries for host attributes and group membership.
+func (s *Server) handleDNS(w dns.ResponseWriter, req *dns.Msg) {
+	cfg := s.Inventory.Config
+
+	msg := new(dns.Msg)
+	msg.SetReply(req)
+	msg.Authoritative = true
+
+	if !s.ready.Load() {
+		msg.Rcode = dns.RcodeServerFailure
+		w.WriteMsg(msg)
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, q := range req.Question {
+		if q.Qclass != dns.ClassINET {
+			msg.Rcode = dns.RcodeRefused
+			break
+		}
+
+		values, ok := s.dnsIndex[strings.ToLower(q.Name)]
+		if !ok {
+			msg.Rcode = dns.RcodeNameError
+			continue
+		}
+
+		if q.Qtype != dns.TypeTXT && q.Qtype != dns.TypeANY {
+			continue
+		}
+
+		for _, value := range values {
+			msg.Answer = append(msg.Answer, &dns.TXT{
+				Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: uint32(cfg.Server.DNS.TTL.Seconds())},
+				Txt: splitTxt(value),
+			})
+		}
+	}
+
+	w.WriteMsg(msg)
+}
+
+// serveDNS serves inventory data over DNS (UDP and TCP) until the context is cancelled.
+func (s *Server) serveDNS(ctx context.Context, errc chan<- error) {
+	cfg := s.Inventory.Config
+	log := s.Logger
+
+	handler := dns.HandlerFunc(s.handleDNS)
+	servers := []*dns.Server{
+		{Addr: cfg.Server.DNS.Listen, Net: "udp", Handler: handler},
+		{Addr: cfg.Server.DNS.Listen, Net: "tcp", Handler: handler},
+	}
+
+	for _, srv := range servers {
+		go func(srv *dns.Server) {
+			if err := srv.ListenAndServe(); err != nil {
+				errc <- errors.Wrapf(err, "DNS server failure (%s)", srv.Net)
+			}
+		}(srv)
+	}
+
+	log.Infof("serving inventory over DNS on %s", cfg.Server.DNS.Listen)
+
+	<-ctx.Done()
+
+	for _, srv := range servers {
+		srv.ShutdownContext(context.Background())
+	}
+}
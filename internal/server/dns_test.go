@@ -0,0 +1,161 @@
+package server
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	"github.com/NeonSludge/ansible-dns-inventory/pkg/inventory"
+)
+
+// startTestDNSResponder serves the DNS responder of a server on a local UDP socket.
+func startTestDNSResponder(t *testing.T, s *Server) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	srv := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(s.handleDNS), NotifyStartedFunc: func() { close(started) }}
+	go srv.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { srv.Shutdown() })
+
+	return pc.LocalAddr().String()
+}
+
+func Test_splitTxt(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want []int
+	}{
+		{name: "valid-empty", s: "", want: []int{0}},
+		{name: "valid-short", s: "OS=linux", want: []int{8}},
+		{name: "valid-max", s: strings.Repeat("a", 255), want: []int{255}},
+		{name: "valid-split", s: strings.Repeat("a", 256), want: []int{255, 1}},
+		{name: "valid-split-multiple", s: strings.Repeat("a", 600), want: []int{255, 255, 90}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitTxt(tt.s)
+
+			lengths := make([]int, 0, len(got))
+			for _, chunk := range got {
+				lengths = append(lengths, len(chunk))
+			}
+
+			if strings.Join(got, "") != tt.s || len(lengths) != len(tt.want) {
+				t.Fatalf("splitTxt() chunk lengths = %v, want %v", lengths, tt.want)
+			}
+			for i := range lengths {
+				if lengths[i] != tt.want[i] {
+					t.Errorf("splitTxt() chunk lengths = %v, want %v", lengths, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestServer_handleDNS(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.Server.DNS.Listen = "127.0.0.1:0"
+
+	long := &inventory.DatasourceRecord{Hostname: "big01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=;VARS=blob=" + strings.Repeat("x", 300)}
+
+	s := newTestServer(t, cfg, append(testRecords[:len(testRecords):len(testRecords)], long))
+	addr := startTestDNSResponder(t, s)
+
+	query := func(name string, qtype, qclass uint16) *dns.Msg {
+		req := new(dns.Msg)
+		req.SetQuestion(name, qtype)
+		req.Question[0].Qclass = qclass
+
+		resp, err := dns.Exchange(req, addr)
+		if err != nil {
+			t.Fatalf("DNS query failure: %v", err)
+		}
+
+		return resp
+	}
+
+	// Nothing is served before the initial refresh.
+	if resp := query("app01.infra.local.", dns.TypeTXT, dns.ClassINET); resp.Rcode != dns.RcodeServerFailure {
+		t.Errorf("query before the initial refresh: rcode = %s, want SERVFAIL", dns.RcodeToString[resp.Rcode])
+	}
+
+	if err := s.Refresh(); err != nil {
+		t.Fatalf("Server.Refresh() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		qname     string
+		qtype     uint16
+		qclass    uint16
+		wantRcode int
+		want      []string
+	}{
+		{name: "valid-host", qname: "db01.infra.local.", qtype: dns.TypeTXT, want: []string{"OS=linux;ENV=prod;ROLE=db;SRV=postgres;VARS="}},
+		{name: "valid-host-case", qname: "DB01.Infra.Local.", qtype: dns.TypeTXT, want: []string{"OS=linux;ENV=prod;ROLE=db;SRV=postgres;VARS="}},
+		{name: "valid-host-any", qname: "db01.infra.local.", qtype: dns.TypeANY, want: []string{"OS=linux;ENV=prod;ROLE=db;SRV=postgres;VARS="}},
+		{name: "valid-host-vars", qname: "app01.infra.local.", qtype: dns.TypeTXT, want: []string{"VARS=heap=2g"}},
+		{name: "valid-host-long", qname: "big01.infra.local.", qtype: dns.TypeTXT, want: []string{strings.Repeat("x", 300)}},
+		{name: "valid-group", qname: "prod_db.groups.inventory.", qtype: dns.TypeTXT, want: []string{"db01.infra.local"}},
+		{name: "valid-group-hosts", qname: "prod.groups.inventory.", qtype: dns.TypeTXT, want: []string{"app01.infra.local", "db01.infra.local"}},
+		{name: "valid-other-type", qname: "db01.infra.local.", qtype: dns.TypeA},
+		{name: "invalid-host", qname: "web01.infra.local.", qtype: dns.TypeTXT, wantRcode: dns.RcodeNameError},
+		{name: "invalid-group", qname: "nonexistent.groups.inventory.", qtype: dns.TypeTXT, wantRcode: dns.RcodeNameError},
+		{name: "invalid-class", qname: "db01.infra.local.", qtype: dns.TypeTXT, qclass: dns.ClassCHAOS, wantRcode: dns.RcodeRefused},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qclass := tt.qclass
+			if qclass == 0 {
+				qclass = dns.ClassINET
+			}
+
+			resp := query(tt.qname, tt.qtype, qclass)
+
+			if resp.Rcode != tt.wantRcode {
+				t.Fatalf("rcode = %s, want %s", dns.RcodeToString[resp.Rcode], dns.RcodeToString[tt.wantRcode])
+			}
+			if !resp.Authoritative {
+				t.Error("response is not authoritative")
+			}
+
+			answers := make([]string, 0, len(resp.Answer))
+			for _, rr := range resp.Answer {
+				txt, ok := rr.(*dns.TXT)
+				if !ok {
+					t.Fatalf("unexpected answer: %s", rr)
+				}
+				if txt.Hdr.Name != tt.qname || txt.Hdr.Ttl != 60 {
+					t.Errorf("answer header = %s", &txt.Hdr)
+				}
+				for _, chunk := range txt.Txt {
+					if len(chunk) > dnsTxtStringMaxLength {
+						t.Errorf("TXT string length = %d", len(chunk))
+					}
+				}
+
+				answers = append(answers, strings.Join(txt.Txt, ""))
+			}
+
+			if len(tt.want) == 0 && len(answers) > 0 {
+				t.Errorf("answers = %q, want none", answers)
+			}
+			for _, want := range tt.want {
+				found := false
+				for _, a := range answers {
+					found = found || strings.Contains(a, want)
+				}
+				if !found {
+					t.Errorf("answers = %q, want %q", answers, want)
+				}
+			}
+		})
+	}
+}
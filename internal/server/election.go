@@ -0,0 +1,23 @@
+package server
+
+import (
+	"context"
+)
+
+// leaderElector takes part in leader election among redundant server instances.
+type leaderElector interface {
+	// IsLeader reports whether this instance holds the leadership.
+	IsLeader() bool
+	// Run takes part in the leader election until the context is cancelled.
+	Run(ctx context.Context)
+}
+
+// IsLeader reports whether this server instance should perform tasks with side effects (e.g. change notifications).
+// It always returns true if leader election is disabled.
+func (s *Server) IsLeader() bool {
+	if !s.Inventory.Config.Server.Election.Enabled {
+		return true
+	}
+
+	return s.elector.IsLeader()
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/NeonSludge/ansible-dns-inventory/internal/zstd"
+)
+
+// Export formats selected by the media types of the Accept header.
+var serverMediaFormats = map[string]string{
+	"application/json":       "json",
+	"application/yaml":       "yaml",
+	"application/x-yaml":     "yaml",
+	"text/yaml":              "yaml",
+	"text/x-yaml":            "yaml",
+	"application/protobuf":   "pb",
+	"application/x-protobuf": "pb",
+	"application/msgpack":    "msgpack",
+	"application/x-msgpack":  "msgpack",
+}
+
+// Response encoders, by content coding.
+var serverEncoders = map[string]func(w io.Writer, level int) (io.WriteCloser, error){
+	"gzip": func(w io.Writer, level int) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, level)
+	},
+	"zstd": func(w io.Writer, level int) (io.WriteCloser, error) {
+		return zstd.NewWriterLevel(w, level)
+	},
+}
+
+// Content codings used if the client accepts any coding, most preferred first.
+var serverDefaultEncodings = []string{"gzip", "zstd"}
+
+// acceptValue represents an element of an Accept or Accept-Encoding header.
+type acceptValue struct {
+	// Media type or content coding, in lowercase.
+	Value string
+	// Quality value.
+	Q float64
+}
+
+// parseAccept parses an Accept or Accept-Encoding header, ordering the values by their quality.
+// Values with equal quality keep the order of the header.
+func parseAccept(header string) []acceptValue {
+	values := make([]acceptValue, 0)
+
+	for _, part := range strings.Split(header, ",") {
+		// Content codings are parsed as media types without a subtype.
+		value, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		values = append(values, acceptValue{Value: value, Q: q})
+	}
+
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].Q > values[j].Q
+	})
+
+	return values
+}
+
+// negotiateFormats returns the export formats acceptable for a response, most preferred first: the 'format' query parameter,
+// or the supported media types of the Accept header followed by the default format, as not every export is available in every format.
+func negotiateFormats(r *http.Request) []string {
+	if format := r.URL.Query().Get("format"); len(format) > 0 {
+		return []string{format}
+	}
+
+	formats := make([]string, 0)
+	for _, v := range parseAccept(r.Header.Get("Accept")) {
+		if format, ok := serverMediaFormats[v.Value]; ok && v.Q > 0 {
+			formats = append(formats, format)
+		}
+	}
+
+	return append(formats, serverDefaultFormat)
+}
+
+// negotiateEncoding selects the content coding of a response from the Accept-Encoding header. An empty string means no compression.
+func negotiateEncoding(r *http.Request) string {
+	values := parseAccept(r.Header.Get("Accept-Encoding"))
+
+	refused := make(map[string]bool)
+	for _, v := range values {
+		if v.Q <= 0 {
+			refused[v.Value] = true
+		}
+	}
+
+	for _, v := range values {
+		if v.Q <= 0 {
+			continue
+		}
+
+		if _, ok := serverEncoders[v.Value]; ok {
+			return v.Value
+		}
+
+		switch v.Value {
+		case "*":
+			for _, encoding := range serverDefaultEncodings {
+				if !refused[encoding] {
+					return encoding
+				}
+			}
+
+			return ""
+		case "identity":
+			return ""
+		}
+	}
+
+	return ""
+}
+
+// writeBody writes a response body, compressing it if the client accepts a supported content coding.
+func (s *Server) writeBody(w http.ResponseWriter, r *http.Request, body []byte) {
+	cfg := s.Inventory.Config
+
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	encoding := negotiateEncoding(r)
+	if !cfg.Server.Compression.Enabled || len(encoding) == 0 || len(body) < int(cfg.Server.Compression.MinSize) {
+		w.Write(body)
+		return
+	}
+
+	// The compression level is validated when the server is created.
+	enc, _ := serverEncoders[encoding](w, cfg.Server.Compression.Level)
+
+	w.Header().Set("Content-Encoding", encoding)
+	w.Header().Del("Content-Length")
+
+	enc.Write(body)
+	enc.Close()
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func Test_parseAccept(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   []acceptValue
+	}{
+		{name: "valid-empty", header: "", want: []acceptValue{}},
+		{name: "valid-single", header: "gzip", want: []acceptValue{{Value: "gzip", Q: 1}}},
+		{name: "valid-q-ordering", header: "gzip;q=0.5, zstd;q=0.8, identity;q=0.1", want: []acceptValue{{Value: "zstd", Q: 0.8}, {Value: "gzip", Q: 0.5}, {Value: "identity", Q: 0.1}}},
+		{name: "valid-q-default", header: "gzip;q=0.9, zstd", want: []acceptValue{{Value: "zstd", Q: 1}, {Value: "gzip", Q: 0.9}}},
+		{name: "valid-q-equal-header-order", header: "zstd;q=0.5, gzip;q=0.5, br", want: []acceptValue{{Value: "br", Q: 1}, {Value: "zstd", Q: 0.5}, {Value: "gzip", Q: 0.5}}},
+		{name: "valid-case", header: "GZIP;Q=0.5, Application/JSON", want: []acceptValue{{Value: "application/json", Q: 1}, {Value: "gzip", Q: 0.5}}},
+		{name: "valid-media-params", header: "application/json;charset=utf-8;q=0.2, application/yaml", want: []acceptValue{{Value: "application/yaml", Q: 1}, {Value: "application/json", Q: 0.2}}},
+		{name: "invalid-q", header: "gzip;q=high, zstd", want: []acceptValue{{Value: "zstd", Q: 1}}},
+		{name: "invalid-value", header: "/, zstd", want: []acceptValue{{Value: "zstd", Q: 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseAccept(tt.header)
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseAccept() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("parseAccept() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func Test_negotiateEncoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{name: "valid-none", header: "", want: ""},
+		{name: "valid-gzip", header: "gzip", want: "gzip"},
+		{name: "valid-zstd", header: "zstd", want: "zstd"},
+		{name: "valid-header-order", header: "zstd, gzip", want: "zstd"},
+		{name: "valid-q-zstd", header: "gzip;q=0.5, zstd", want: "zstd"},
+		{name: "valid-q-gzip", header: "zstd;q=0.1, gzip;q=0.2", want: "gzip"},
+		{name: "valid-q-unsupported-first", header: "br, zstd;q=0.5, gzip;q=0.4", want: "zstd"},
+		{name: "valid-wildcard", header: "*", want: "gzip"},
+		{name: "valid-wildcard-gzip-refused", header: "*, gzip;q=0", want: "zstd"},
+		{name: "valid-wildcard-all-refused", header: "*, gzip;q=0, zstd;q=0", want: ""},
+		{name: "valid-wildcard-lower-q", header: "zstd;q=0.5, *;q=0.1", want: "zstd"},
+		{name: "valid-identity-preferred", header: "identity, gzip;q=0.5", want: ""},
+		{name: "valid-identity-less-preferred", header: "identity;q=0.5, zstd", want: "zstd"},
+		{name: "invalid-refused", header: "zstd;q=0, gzip;q=0", want: ""},
+		{name: "invalid-unsupported", header: "br, compress", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/list", nil)
+			if len(tt.header) > 0 {
+				r.Header.Set("Accept-Encoding", tt.header)
+			}
+
+			if got := negotiateEncoding(r); got != tt.want {
+				t.Errorf("negotiateEncoding() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_negotiateFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		header string
+		want   []string
+	}{
+		{name: "valid-default", url: "/list", want: []string{"json"}},
+		{name: "valid-query", url: "/list?format=yaml-csv", header: "application/json", want: []string{"yaml-csv"}},
+		{name: "valid-q-ordering", url: "/list", header: "application/json;q=0.5, application/x-protobuf, application/msgpack;q=0.7", want: []string{"pb", "msgpack", "json", "json"}},
+		{name: "valid-refused", url: "/list", header: "application/yaml;q=0, application/msgpack", want: []string{"msgpack", "json"}},
+		{name: "valid-unsupported", url: "/list", header: "text/html, */*;q=0.8", want: []string{"json"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			r.Header.Set("Accept", tt.header)
+
+			got := negotiateFormats(r)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("negotiateFormats() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_writeBody(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.Server.Compression.MinSize = 16
+
+	s := newTestServer(t, cfg, testRecords)
+	body := bytes.Repeat([]byte("app01.infra.local\n"), 100)
+
+	tests := []struct {
+		name    string
+		header  string
+		small   bool
+		want    string
+		decoder func(t *testing.T, data []byte) []byte
+	}{
+		{name: "valid-identity", header: "identity", want: ""},
+		{name: "valid-small", header: "gzip", small: true, want: ""},
+		{name: "valid-gzip", header: "zstd;q=0.5, gzip", want: "gzip", decoder: func(t *testing.T, data []byte) []byte {
+			r, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return out
+		}},
+		{name: "valid-zstd", header: "gzip;q=0.5, zstd", want: "zstd", decoder: func(t *testing.T, data []byte) []byte {
+			if !bytes.HasPrefix(data, []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+				t.Fatalf("body is not a zstd frame: %x", data[:min(len(data), 8)])
+			}
+
+			path, err := exec.LookPath("zstd")
+			if err != nil {
+				t.Skip("zstd is not installed")
+			}
+
+			cmd := exec.Command(path, "-d", "-c", "-q")
+			cmd.Stdin = bytes.NewReader(data)
+			out, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("zstd -d failure: %v", err)
+			}
+			return out
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := body
+			if tt.small {
+				want = body[:8]
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/list", nil)
+			r.Header.Set("Accept-Encoding", tt.header)
+			rec := httptest.NewRecorder()
+
+			s.writeBody(rec, r, want)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.want {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.want)
+			}
+			if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want %q", got, "Accept-Encoding")
+			}
+
+			got := rec.Body.Bytes()
+			if tt.decoder != nil {
+				got = tt.decoder(t, got)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("decoded body = %q, want %q", got, want)
+			}
+		})
+	}
+}
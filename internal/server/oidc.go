@@ -0,0 +1,442 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/NeonSludge/ansible-dns-inventory/pkg/inventory"
+
+	// Hash implementations used by token signatures.
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+const (
+	// Minimum interval between signing key refreshes, so that tokens with unknown key IDs cannot be used to flood the identity provider.
+	oidcMinRefresh time.Duration = time.Minute
+)
+
+// Curves of EC signing keys.
+var oidcCurves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+// Hash functions of the supported token signature algorithms.
+var jwtHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"PS256": crypto.SHA256,
+	"PS384": crypto.SHA384,
+	"PS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// Curves of the ECDSA token signature algorithms.
+var jwtCurves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+// jwk represents a JSON Web Key.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey converts an RSA or EC JSON Web Key to a public key.
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	number := func(v string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil {
+			return nil, err
+		}
+
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := number(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := number(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curve, ok := oidcCurves[k.Crv]
+		if !ok {
+			return nil, errors.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := number(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := number(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("invalid EC point")
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, errors.Errorf("unsupported key type: %s", k.Kty)
+	}
+}
+
+// jwtVerify verifies the signature of a JWT. Only asymmetric algorithms are accepted.
+func jwtVerify(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	hash, ok := jwtHashes[alg]
+	if !ok {
+		return errors.Errorf("unsupported signature algorithm: %s", alg)
+	}
+
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "RS", "PS":
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("signing key is not an RSA key")
+		}
+
+		if alg[0] == 'P' {
+			return rsa.VerifyPSS(k, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+
+		return rsa.VerifyPKCS1v15(k, hash, digest, sig)
+	default:
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok || k.Curve != jwtCurves[alg] {
+			return errors.Errorf("signing key does not match the signature algorithm: %s", alg)
+		}
+
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("invalid signature length")
+		}
+
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+
+		return nil
+	}
+}
+
+// oidcAuthProvider accepts JWTs signed by an OpenID Connect identity provider.
+type oidcAuthProvider struct {
+	// Inventory configuration.
+	Config *inventory.Config
+
+	// Identity provider HTTP client.
+	client *http.Client
+	// Guards the signing keys and refresh state. Never held during identity provider requests.
+	mu sync.Mutex
+	// Signing keys, by key ID.
+	keys map[string]crypto.PublicKey
+	// Time of the last successful signing key refresh.
+	refreshed time.Time
+	// Time of the last signing key refresh attempt.
+	attempted time.Time
+	// Closed when the signing key refresh in progress completes, nil if there is none.
+	pending chan struct{}
+}
+
+// get fetches a JSON document from the identity provider.
+func (p *oidcAuthProvider) get(url string, v interface{}) error {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("%s: unexpected status: %s", url, resp.Status)
+	}
+
+	return errors.Wrap(json.NewDecoder(resp.Body).Decode(v), url)
+}
+
+// fetch acquires the signing keys of the identity provider.
+func (p *oidcAuthProvider) fetch() (map[string]crypto.PublicKey, error) {
+	cfg := p.Config
+	issuer := strings.TrimSuffix(cfg.Server.Auth.OIDC.Issuer, "/")
+
+	discovery := struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}{}
+	if err := p.get(issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, errors.Wrap(err, "discovery failure")
+	}
+
+	if strings.TrimSuffix(discovery.Issuer, "/") != issuer {
+		return nil, errors.Errorf("discovery failure: issuer mismatch: %s", discovery.Issuer)
+	}
+
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	if err := p.get(discovery.JWKSURI, &set); err != nil {
+		return nil, errors.Wrap(err, "signing key set failure")
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use == "enc" {
+			continue
+		}
+
+		// Keys of unsupported types are skipped: tokens signed with them are rejected as signed with an unknown key.
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+
+	return keys, nil
+}
+
+// key returns a signing key, refreshing the keys if they are stale or the key is unknown.
+// Previously acquired keys are used if the identity provider is not available.
+// Lookups of unknown keys wait for a refresh in progress instead of starting another one.
+func (p *oidcAuthProvider) key(kid string) (crypto.PublicKey, error) {
+	cfg := p.Config
+
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	stale := time.Since(p.refreshed) > cfg.Server.Auth.OIDC.Refresh
+	pending := p.pending
+
+	var err error
+	switch {
+	case pending == nil && (stale || !ok) && time.Since(p.attempted) > oidcMinRefresh:
+		p.attempted = time.Now()
+		p.pending = make(chan struct{})
+		p.mu.Unlock()
+
+		var keys map[string]crypto.PublicKey
+		keys, err = p.fetch()
+
+		p.mu.Lock()
+		if err == nil {
+			p.keys = keys
+			p.refreshed = time.Now()
+		}
+		close(p.pending)
+		p.pending = nil
+		p.mu.Unlock()
+	case pending != nil && !ok:
+		p.mu.Unlock()
+		<-pending
+	default:
+		p.mu.Unlock()
+	}
+
+	if !ok {
+		if err != nil {
+			return nil, err
+		}
+
+		p.mu.Lock()
+		key, ok = p.keys[kid]
+		p.mu.Unlock()
+	}
+
+	if !ok {
+		return nil, errors.Errorf("unknown signing key: %s", kid)
+	}
+
+	return key, nil
+}
+
+// claim looks up a claim by name, or by a dot-separated path of nested claims.
+func claim(claims map[string]interface{}, name string) interface{} {
+	if v, ok := claims[name]; ok {
+		return v
+	}
+
+	var v interface{} = claims
+	for _, part := range strings.Split(name, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		v = m[part]
+	}
+
+	return v
+}
+
+// Authenticate implements authProvider. Tokens that are not JWTs are left to other providers.
+func (p *oidcAuthProvider) Authenticate(token string) (*identity, error) {
+	cfg := p.Config
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil
+	}
+
+	decode := func(part string, v interface{}) error {
+		b, err := base64.RawURLEncoding.DecodeString(part)
+		if err != nil {
+			return err
+		}
+
+		return json.Unmarshal(b, v)
+	}
+
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	if err := decode(parts[0], &header); err != nil {
+		return nil, errors.Wrap(err, "oidc: token header parsing failure")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(err, "oidc: token signature parsing failure")
+	}
+
+	key, err := p.key(header.Kid)
+	if err != nil {
+		return nil, errors.Wrap(err, "oidc")
+	}
+
+	if err := jwtVerify(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, errors.Wrap(err, "oidc: token signature verification failure")
+	}
+
+	claims := make(map[string]interface{})
+	if err := decode(parts[1], &claims); err != nil {
+		return nil, errors.Wrap(err, "oidc: token claims parsing failure")
+	}
+
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != strings.TrimSuffix(cfg.Server.Auth.OIDC.Issuer, "/") {
+		return nil, errors.Errorf("oidc: unexpected token issuer: %s", iss)
+	}
+
+	audience := false
+	switch aud := claims["aud"].(type) {
+	case string:
+		audience = aud == cfg.Server.Auth.OIDC.Audience
+	case []interface{}:
+		for _, a := range aud {
+			audience = audience || a == cfg.Server.Auth.OIDC.Audience
+		}
+	}
+	if !audience {
+		return nil, errors.Errorf("oidc: unexpected token audience: %v", claims["aud"])
+	}
+
+	now := time.Now()
+	leeway := cfg.Server.Auth.OIDC.Leeway
+
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(leeway)) {
+		return nil, errors.New("oidc: token has expired")
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(leeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("oidc: token is not valid yet")
+	}
+
+	name, _ := claim(claims, cfg.Server.Auth.OIDC.Username).(string)
+	if len(name) == 0 {
+		return nil, errors.Errorf("oidc: token has no %s claim", cfg.Server.Auth.OIDC.Username)
+	}
+
+	id := &identity{Name: name, Groups: make([]string, 0)}
+
+	switch groups := claim(claims, cfg.Server.Auth.OIDC.Groups).(type) {
+	case string:
+		id.Groups = append(id.Groups, groups)
+	case []interface{}:
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				id.Groups = append(id.Groups, s)
+			}
+		}
+	}
+
+	return id, nil
+}
+
+// newOIDCClient creates an HTTP client for identity provider requests, trusting the configured CA certificate.
+func newOIDCClient(cfg *inventory.Config) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if len(cfg.Server.Auth.OIDC.CA) > 0 {
+		pem, err := os.ReadFile(cfg.Server.Auth.OIDC.CA)
+		if err != nil {
+			return nil, errors.Wrap(err, "oidc CA certificate")
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("oidc CA certificate: invalid CA certificate")
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	return &http.Client{Transport: transport, Timeout: cfg.Server.Auth.OIDC.Timeout}, nil
+}
+
+// newOIDCAuthProvider creates an OpenID Connect authentication provider.
+// Signing keys are acquired on first use, so that the server can start while the identity provider is not available.
+func newOIDCAuthProvider(cfg *inventory.Config) (*oidcAuthProvider, error) {
+	if len(cfg.Server.Auth.OIDC.Issuer) == 0 || len(cfg.Server.Auth.OIDC.Audience) == 0 {
+		return nil, errors.New("oidc issuer or audience is not set")
+	}
+
+	client, err := newOIDCClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oidcAuthProvider{
+		Config: cfg,
+		client: client,
+	}, nil
+}
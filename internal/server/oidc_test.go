@@ -0,0 +1,289 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testOIDCIssuer is an identity provider publishing an RSA and an EC signing key.
+type testOIDCIssuer struct {
+	*httptest.Server
+
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+	// Number of signing key set requests.
+	fetches atomic.Int32
+	// Blocks signing key set requests while write-locked.
+	gate sync.RWMutex
+}
+
+func startTestOIDCIssuer(t *testing.T) *testOIDCIssuer {
+	rk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+	iss := &testOIDCIssuer{rsa: rk, ec: ek}
+	iss.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"issuer": iss.URL, "jwks_uri": iss.URL + "/jwks"})
+		case "/jwks":
+			iss.fetches.Add(1)
+			iss.gate.RLock()
+			defer iss.gate.RUnlock()
+
+			json.NewEncoder(w).Encode(map[string]interface{}{"keys": []jwk{
+				{Kid: "rsa1", Kty: "RSA", Use: "sig", N: b64(rk.N.Bytes()), E: b64(big.NewInt(int64(rk.E)).Bytes())},
+				{Kid: "ec1", Kty: "EC", Crv: "P-256", X: b64(ek.X.FillBytes(make([]byte, 32))), Y: b64(ek.Y.FillBytes(make([]byte, 32)))},
+				{Kid: "enc1", Kty: "RSA", Use: "enc", N: b64(rk.N.Bytes()), E: b64(big.NewInt(int64(rk.E)).Bytes())},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(iss.Close)
+
+	return iss
+}
+
+// token creates a JWT with the given header algorithm and key ID, signed with the key matching the algorithm family.
+func (iss *testOIDCIssuer) token(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	signed := encode(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch alg {
+	case "RS256":
+		s, err := rsa.SignPKCS1v15(rand.Reader, iss.rsa, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = s
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, iss.ec, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func newTestOIDCAuthProvider(t *testing.T, iss *testOIDCIssuer) *oidcAuthProvider {
+	cfg := newTestConfig(t)
+	cfg.Server.Auth.OIDC.Issuer = iss.URL
+	cfg.Server.Auth.OIDC.Audience = "adi"
+
+	p, err := newOIDCAuthProvider(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestOIDCAuthProvider_Authenticate(t *testing.T) {
+	iss := startTestOIDCIssuer(t)
+	p := newTestOIDCAuthProvider(t, iss)
+
+	now := time.Now()
+	claims := func(mutate func(map[string]interface{})) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":                iss.URL,
+			"aud":                "adi",
+			"exp":                now.Add(time.Hour).Unix(),
+			"nbf":                now.Add(-time.Minute).Unix(),
+			"preferred_username": "alice",
+			"groups":             []string{"admins", "ops"},
+		}
+		if mutate != nil {
+			mutate(c)
+		}
+
+		return c
+	}
+
+	tampered := iss.token(t, "RS256", "rsa1", claims(nil))
+	parts := strings.Split(tampered, ".")
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"` + iss.URL + `","aud":"adi","exp":9999999999,"preferred_username":"mallory"}`))
+	tampered = strings.Join(parts, ".")
+
+	none := strings.Join(strings.Split(iss.token(t, "none", "rsa1", claims(nil)), ".")[:2], ".") + "."
+
+	tests := []struct {
+		name    string
+		token   string
+		want    *identity
+		wantErr string
+	}{
+		{name: "valid-rs256", token: iss.token(t, "RS256", "rsa1", claims(nil)), want: &identity{Name: "alice", Groups: []string{"admins", "ops"}}},
+		{name: "valid-es256", token: iss.token(t, "ES256", "ec1", claims(nil)), want: &identity{Name: "alice", Groups: []string{"admins", "ops"}}},
+		{name: "valid-aud-list", token: iss.token(t, "RS256", "rsa1", claims(func(c map[string]interface{}) { c["aud"] = []string{"other", "adi"} })), want: &identity{Name: "alice", Groups: []string{"admins", "ops"}}},
+		{name: "valid-nbf-leeway", token: iss.token(t, "RS256", "rsa1", claims(func(c map[string]interface{}) { c["nbf"] = now.Add(30 * time.Second).Unix() })), want: &identity{Name: "alice", Groups: []string{"admins", "ops"}}},
+		{name: "valid-not-jwt", token: "opaque-token"},
+		{name: "invalid-signature", token: tampered, wantErr: "token signature verification failure"},
+		{name: "invalid-alg-none", token: none, wantErr: "unsupported signature algorithm: none"},
+		{name: "invalid-alg-hs256", token: iss.token(t, "HS256", "rsa1", claims(nil)), wantErr: "unsupported signature algorithm: HS256"},
+		{name: "invalid-alg-mismatch-rsa", token: iss.token(t, "ES256", "rsa1", claims(nil)), wantErr: "signing key does not match the signature algorithm: ES256"},
+		{name: "invalid-alg-mismatch-ec", token: iss.token(t, "RS256", "ec1", claims(nil)), wantErr: "signing key is not an RSA key"},
+		{name: "invalid-expired", token: iss.token(t, "RS256", "rsa1", claims(func(c map[string]interface{}) { c["exp"] = now.Add(-2 * time.Minute).Unix() })), wantErr: "token has expired"},
+		{name: "invalid-no-exp", token: iss.token(t, "RS256", "rsa1", claims(func(c map[string]interface{}) { delete(c, "exp") })), wantErr: "token has expired"},
+		{name: "invalid-nbf", token: iss.token(t, "RS256", "rsa1", claims(func(c map[string]interface{}) { c["nbf"] = now.Add(5 * time.Minute).Unix() })), wantErr: "token is not valid yet"},
+		{name: "invalid-issuer", token: iss.token(t, "RS256", "rsa1", claims(func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" })), wantErr: "unexpected token issuer"},
+		{name: "invalid-audience", token: iss.token(t, "RS256", "rsa1", claims(func(c map[string]interface{}) { c["aud"] = "other" })), wantErr: "unexpected token audience"},
+		{name: "invalid-kid-unknown", token: iss.token(t, "RS256", "rsa2", claims(nil)), wantErr: "unknown signing key: rsa2"},
+		{name: "invalid-kid-encryption", token: iss.token(t, "RS256", "enc1", claims(nil)), wantErr: "unknown signing key: enc1"},
+		{name: "invalid-username", token: iss.token(t, "RS256", "rsa1", claims(func(c map[string]interface{}) { delete(c, "preferred_username") })), wantErr: "token has no preferred_username claim"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.Authenticate(tt.token)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Authenticate() error = %v, wantErr %q", err, tt.wantErr)
+				}
+				if got != nil {
+					t.Errorf("Authenticate() = %v, want nil", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("Authenticate() = %v, want nil", got)
+			case tt.want != nil && (got == nil || got.Name != tt.want.Name || strings.Join(got.Groups, ",") != strings.Join(tt.want.Groups, ",")):
+				t.Errorf("Authenticate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOIDCAuthProvider_key_unknownRateLimit(t *testing.T) {
+	iss := startTestOIDCIssuer(t)
+	p := newTestOIDCAuthProvider(t, iss)
+
+	if _, err := p.key("rsa1"); err != nil {
+		t.Fatalf("key() error = %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, err := p.key("rsa2"); err == nil {
+			t.Fatal("key() expected error for an unknown key ID")
+		}
+	}
+
+	if got := iss.fetches.Load(); got != 1 {
+		t.Errorf("signing key set requests = %d, want 1", got)
+	}
+
+	// An unknown key ID triggers a refresh once the minimum refresh interval has passed.
+	p.mu.Lock()
+	p.attempted = time.Now().Add(-2 * oidcMinRefresh)
+	p.mu.Unlock()
+
+	if _, err := p.key("rsa2"); err == nil {
+		t.Fatal("key() expected error for an unknown key ID")
+	}
+	if got := iss.fetches.Load(); got != 2 {
+		t.Errorf("signing key set requests = %d, want 2", got)
+	}
+}
+
+func TestOIDCAuthProvider_key_concurrent(t *testing.T) {
+	iss := startTestOIDCIssuer(t)
+	p := newTestOIDCAuthProvider(t, iss)
+
+	// Concurrent first uses wait for a single identity provider request.
+	iss.gate.Lock()
+
+	errs := make(chan error, 8)
+	go func() {
+		_, err := p.key("rsa1")
+		errs <- err
+	}()
+	for iss.fetches.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 1; i < cap(errs); i++ {
+		go func() {
+			_, err := p.key("ec1")
+			errs <- err
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	iss.gate.Unlock()
+
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("key() error = %v", err)
+		}
+	}
+	if got := iss.fetches.Load(); got != 1 {
+		t.Errorf("signing key set requests = %d, want 1", got)
+	}
+
+	// Known keys remain available while a stale key refresh waits on the identity provider.
+	iss.gate.Lock()
+	defer iss.gate.Unlock()
+
+	p.mu.Lock()
+	p.refreshed = time.Now().Add(-2 * p.Config.Server.Auth.OIDC.Refresh)
+	p.attempted = time.Time{}
+	p.mu.Unlock()
+
+	go p.key("rsa1")
+	for iss.fetches.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.key("ec1")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("key() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("key() blocked on an in-flight signing key refresh")
+	}
+}
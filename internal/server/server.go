@@ -0,0 +1,443 @@
+package server
+
+import (
+	"compress/gzip"
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/NeonSludge/ansible-dns-inventory/internal/election"
+	"github.com/NeonSludge/ansible-dns-inventory/internal/util"
+	"github.com/NeonSludge/ansible-dns-inventory/pkg/inventory"
+)
+
+const (
+	// Default export format for server responses.
+	serverDefaultFormat string = "json"
+	// Time allowed for in-flight requests to complete during shutdown.
+	serverShutdownTimeout time.Duration = 10 * time.Second
+)
+
+// errNotReady is returned by zone refreshes until the initial refresh has succeeded.
+var errNotReady = errors.New("inventory is not ready")
+
+// Server serves the inventory over HTTP.
+type Server struct {
+	// Inventory.
+	Inventory *inventory.Inventory
+	// Server logger.
+	Logger inventory.Logger
+
+	// Serializes inventory refreshes.
+	refreshMu sync.Mutex
+	// Guards the inventory tree and the host map.
+	mu sync.RWMutex
+	// Hosts and their attributes from the last successful refresh.
+	hosts map[string][]*inventory.HostAttributes
+	// Records served over DNS.
+	dnsIndex dnsIndex
+	// The initial inventory refresh has succeeded.
+	ready atomic.Bool
+	// Leader election among redundant server instances.
+	elector leaderElector
+	// Request authentication.
+	auth *authenticator
+}
+
+// contentType returns the MIME type of an export format.
+func contentType(format string) string {
+	switch format {
+	case "json", "pbjson":
+		return "application/json"
+	case "pb":
+		return "application/x-protobuf"
+	case "msgpack":
+		return "application/msgpack"
+	default:
+		return "application/yaml"
+	}
+}
+
+// write marshals v in the negotiated export format and writes it to the response.
+func (s *Server) write(w http.ResponseWriter, r *http.Request, v interface{}) {
+	var format string
+	var bytes []byte
+	var err error
+
+	for _, format = range negotiateFormats(r) {
+		if bytes, err = util.Marshal(s.Inventory.Redact(v), format, s.Inventory.Config); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType(format))
+	w.Header().Add("Vary", "Accept")
+	s.writeBody(w, r, bytes)
+}
+
+// Refresh acquires host records and rebuilds the inventory tree.
+func (s *Server) Refresh() error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	hosts, err := s.Inventory.GetHosts()
+	if err != nil {
+		return err
+	}
+
+	if len(hosts) == 0 {
+		return errors.New("no host records found")
+	}
+
+	return s.rebuild(hosts)
+}
+
+// RefreshZone acquires the host records of a single zone and rebuilds the inventory tree, keeping the hosts of other zones from the last refresh.
+func (s *Server) RefreshZone(zone string) error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	if !s.ready.Load() {
+		return errNotReady
+	}
+
+	s.mu.RLock()
+	current := s.hosts
+	s.mu.RUnlock()
+
+	hosts, err := s.Inventory.RefreshZoneHosts(current, zone)
+	if err != nil {
+		return err
+	}
+
+	return s.rebuild(hosts)
+}
+
+// rebuild replaces the inventory tree with a tree built from hosts.
+func (s *Server) rebuild(hosts map[string][]*inventory.HostAttributes) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tree := s.Inventory.Tree
+	s.Inventory.Tree = inventory.NewTree()
+	if err := s.Inventory.ImportHosts(hosts); err != nil {
+		// Keep serving the previous tree.
+		s.Inventory.Tree = tree
+		return err
+	}
+	// Only the leader sends change notifications, followers keep serving the rebuilt tree.
+	if s.IsLeader() {
+		s.Inventory.TrackChanges(hosts)
+	}
+	s.hosts = hosts
+
+	if len(s.Inventory.Config.Server.DNS.Listen) > 0 {
+		s.buildDNSIndex()
+	}
+
+	// Readiness is reported to systemd once, by whichever refresh succeeds first: the initial, a periodic or a requested one.
+	if !s.ready.Swap(true) {
+		if err := notify("READY=1"); err != nil {
+			s.Logger.Warn(err)
+		}
+	}
+
+	return nil
+}
+
+// requireReady rejects requests for inventory data until the initial refresh has succeeded.
+func (s *Server) requireReady(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.ready.Load() {
+			http.Error(w, "inventory is not ready", http.StatusServiceUnavailable)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// handleHealthz reports that the server process is alive.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok\n"))
+}
+
+// handleReadyz reports whether the server is ready to serve inventory data.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if !s.ready.Load() {
+		http.Error(w, "inventory is not ready", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Write([]byte("ok\n"))
+}
+
+// handleLeader reports whether this instance is the leader.
+func (s *Server) handleLeader(w http.ResponseWriter, r *http.Request) {
+	s.write(w, r, map[string]bool{"leader": s.IsLeader()})
+}
+
+// handleList serves a JSON inventory for Ansible.
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	export := make(map[string]*inventory.AnsibleGroup)
+
+	s.mu.RLock()
+	s.Inventory.ExportInventory(export)
+	s.mu.RUnlock()
+
+	s.write(w, r, export)
+}
+
+// handleHost serves a dictionary of host variables for Ansible.
+func (s *Server) handleHost(w http.ResponseWriter, r *http.Request) {
+	vars, err := s.Inventory.ExportHostVariables(r.PathValue("name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	s.write(w, r, vars)
+}
+
+// handleHosts serves hosts, mapping each one to a list of groups.
+func (s *Server) handleHosts(w http.ResponseWriter, r *http.Request) {
+	export := make(map[string][]string)
+
+	s.mu.RLock()
+	s.Inventory.ExportHosts(export)
+	s.mu.RUnlock()
+
+	s.write(w, r, export)
+}
+
+// handleGroups serves groups, mapping each one to a list of hosts.
+func (s *Server) handleGroups(w http.ResponseWriter, r *http.Request) {
+	export := make(map[string][]string)
+
+	s.mu.RLock()
+	s.Inventory.ExportGroups(export)
+	s.mu.RUnlock()
+
+	s.write(w, r, export)
+}
+
+// handleAttrs serves hosts, mapping each one to a list of dictionaries of attributes.
+func (s *Server) handleAttrs(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	s.write(w, r, s.hosts)
+}
+
+// handleTree serves the raw inventory tree.
+func (s *Server) handleTree(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	s.write(w, r, s.Inventory.Tree)
+}
+
+// handleRefresh refreshes the inventory and serves the inventory metadata. The 'zone' query parameter limits the refresh to a single zone.
+// Full refreshes are accepted before the inventory is ready, so that a failed initial refresh can be retried without waiting for the next tick.
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	zone := r.URL.Query().Get("zone")
+
+	var err error
+	if len(zone) > 0 {
+		err = s.RefreshZone(zone)
+	} else {
+		err = s.Refresh()
+	}
+
+	if err != nil {
+		var zoneErr *inventory.ZoneError
+		if errors.As(err, &zoneErr) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else if errors.Is(err, errNotReady) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+		return
+	}
+
+	if len(zone) > 0 {
+		s.Logger.Infof("[%s] zone refreshed via API", zone)
+	} else {
+		s.Logger.Info("inventory refreshed via API")
+	}
+
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	s.write(w, r, s.Inventory.Metadata)
+}
+
+// handleVersion serves version and build info.
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	s.write(w, r, inventory.Version())
+}
+
+// Handler returns the HTTP handler serving all server endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /list", s.require(readPermission, s.requireReady(s.handleList)))
+	mux.HandleFunc("GET /host/{name}", s.require(readPermission, s.handleHost))
+	mux.HandleFunc("GET /hosts", s.require(readPermission, s.requireReady(s.handleHosts)))
+	mux.HandleFunc("GET /groups", s.require(readPermission, s.requireReady(s.handleGroups)))
+	mux.HandleFunc("GET /attrs", s.require(readPermission, s.requireReady(s.handleAttrs)))
+	mux.HandleFunc("GET /tree", s.require(readPermission, s.requireReady(s.handleTree)))
+	mux.HandleFunc("GET /version", s.handleVersion)
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.HandleFunc("GET /readyz", s.handleReadyz)
+	mux.HandleFunc("GET /leader", s.handleLeader)
+
+	mux.HandleFunc("POST /refresh", s.require(editPermission, s.handleRefresh))
+
+	if s.Inventory.Config.Server.API.Enabled {
+		mux.HandleFunc("PUT /hosts/{name}", s.require(editPermission, s.handlePutHost))
+		mux.HandleFunc("DELETE /hosts/{name}", s.require(editPermission, s.handleDeleteHost))
+	}
+
+	if s.Inventory.Config.Server.UI.Enabled {
+		mux.Handle("GET /ui/", s.uiHandler())
+		mux.HandleFunc("GET /ui/settings", s.handleUISettings)
+		mux.Handle("GET /{$}", http.RedirectHandler("/ui/", http.StatusFound))
+	}
+
+	version := inventory.Version().Version
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "ansible-dns-inventory/"+version)
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// listen returns a listener passed by systemd socket activation or creates a new one.
+func (s *Server) listen() (net.Listener, error) {
+	cfg := s.Inventory.Config
+	log := s.Logger
+
+	ln, err := activationListener()
+	if err != nil {
+		return nil, err
+	}
+
+	if ln != nil {
+		log.Info("using a socket passed by systemd")
+		return ln, nil
+	}
+
+	return net.Listen("tcp", cfg.Server.Listen)
+}
+
+// Run serves the inventory until the context is cancelled, refreshing it periodically.
+// Inventory data is served only after the initial refresh has succeeded, which is retried on every refresh tick.
+func (s *Server) Run(ctx context.Context) error {
+	cfg := s.Inventory.Config
+	log := s.Logger
+
+	ln, err := s.listen()
+	if err != nil {
+		return errors.Wrap(err, "listener initialization failure")
+	}
+
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: cfg.Server.Timeout,
+		WriteTimeout:      cfg.Server.Timeout,
+	}
+
+	// Buffered for the HTTP server and both DNS servers.
+	errc := make(chan error, 3)
+	go func() {
+		errc <- srv.Serve(ln)
+	}()
+
+	if len(cfg.Server.DNS.Listen) > 0 {
+		go s.serveDNS(ctx, errc)
+	}
+
+	log.Infof("serving inventory on %s (ansible-dns-inventory %s)", ln.Addr(), inventory.Version())
+
+	if cfg.Server.Election.Enabled {
+		go s.elector.Run(ctx)
+	}
+
+	// A nil channel blocks forever, disabling periodic refreshes.
+	var refresh <-chan time.Time
+	if cfg.Server.Refresh > 0 {
+		ticker := time.NewTicker(cfg.Server.Refresh)
+		defer ticker.Stop()
+		refresh = ticker.C
+	}
+
+	if err := s.Refresh(); err != nil {
+		if refresh == nil {
+			srv.Close()
+			return errors.Wrap(err, "initial inventory refresh failure")
+		}
+
+		log.Warnf("initial inventory refresh failure: %v", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("shutting down")
+
+			if err := notify("STOPPING=1"); err != nil {
+				log.Warn(err)
+			}
+
+			sctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+			defer cancel()
+
+			return srv.Shutdown(sctx)
+		case err := <-errc:
+			return errors.Wrap(err, "server failure")
+		case <-refresh:
+			if err := s.Refresh(); err != nil {
+				log.Warnf("inventory refresh failure: %v", err)
+			}
+		}
+	}
+}
+
+// New creates an inventory server.
+func New(inv *inventory.Inventory) (*Server, error) {
+	auth, err := newAuthenticator(inv.Config)
+	if err != nil {
+		return nil, errors.Wrap(err, "authentication initialization failure")
+	}
+
+	if level := inv.Config.Server.Compression.Level; inv.Config.Server.Compression.Enabled && (level < gzip.BestSpeed || level > gzip.BestCompression) {
+		return nil, errors.Errorf("invalid compression level: %d", level)
+	}
+
+	// The next leader reports changes from its own baseline, this instance starts a new one if it is elected again.
+	elector := &election.Elector{
+		Config:   inv.Config,
+		Logger:   inv.Logger,
+		Key:      inv.Config.Server.Election.Key,
+		TTL:      inv.Config.Server.Election.TTL,
+		OnResign: inv.ResetChanges,
+	}
+
+	return &Server{
+		Inventory: inv,
+		Logger:    inv.Logger,
+		auth:      auth,
+		elector:   elector,
+	}, nil
+}
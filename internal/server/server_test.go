@@ -0,0 +1,395 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/creasty/defaults"
+
+	"github.com/NeonSludge/ansible-dns-inventory/pkg/inventory"
+)
+
+type (
+	// testLogger discards all messages.
+	testLogger struct{}
+
+	// testElector holds the leadership if set.
+	testElector bool
+
+	// testDatasource serves a fixed set of host records.
+	testDatasource struct {
+		records []*inventory.DatasourceRecord
+		err     error
+	}
+)
+
+func (l *testLogger) Info(args ...interface{})                    {}
+func (l *testLogger) Infof(template string, args ...interface{})  {}
+func (l *testLogger) Warn(args ...interface{})                    {}
+func (l *testLogger) Warnf(template string, args ...interface{})  {}
+func (l *testLogger) Error(args ...interface{})                   {}
+func (l *testLogger) Errorf(template string, args ...interface{}) {}
+func (l *testLogger) Fatal(args ...interface{})                   {}
+func (l *testLogger) Fatalf(template string, args ...interface{}) {}
+func (l *testLogger) Debug(args ...interface{})                   {}
+func (l *testLogger) Debugf(template string, args ...interface{}) {}
+
+func (e testElector) IsLeader() bool { return bool(e) }
+
+func (e testElector) Run(ctx context.Context) {}
+
+func (d *testDatasource) GetAllRecords() ([]*inventory.DatasourceRecord, error) {
+	return d.records, d.err
+}
+
+func (d *testDatasource) GetHostRecords(host string) ([]*inventory.DatasourceRecord, error) {
+	records := make([]*inventory.DatasourceRecord, 0)
+	for _, r := range d.records {
+		if r.Hostname == host {
+			records = append(records, r)
+		}
+	}
+
+	return records, d.err
+}
+
+func (d *testDatasource) PublishRecords(records []*inventory.DatasourceRecord) error {
+	d.records = records
+	return nil
+}
+
+func (d *testDatasource) Close() {}
+
+// newTestConfig returns the default configuration.
+func newTestConfig(t *testing.T) *inventory.Config {
+	cfg := &inventory.Config{}
+	if err := defaults.Set(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	return cfg
+}
+
+// newTestServer creates a server for an inventory serving a fixed set of host records.
+func newTestServer(t *testing.T, cfg *inventory.Config, records []*inventory.DatasourceRecord) *Server {
+	inv, err := inventory.New(cfg, &testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(inv.Close)
+
+	inv.Datasource = &testDatasource{records: records}
+
+	s, err := New(inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+// testRecords are the host records served by test servers.
+var testRecords = []*inventory.DatasourceRecord{
+	{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=app;SRV=tomcat;VARS=heap=2g,api_token=s3cr3t"},
+	{Hostname: "db01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=db;SRV=postgres;VARS="},
+}
+
+func TestServer_Refresh(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []*inventory.DatasourceRecord
+		wantErr bool
+	}{
+		{name: "valid", records: testRecords},
+		{name: "invalid-no-records", records: []*inventory.DatasourceRecord{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, newTestConfig(t), tt.records)
+
+			if err := s.Refresh(); (err != nil) != tt.wantErr {
+				t.Errorf("Server.Refresh() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServer_rebuild_leader(t *testing.T) {
+	events := make(chan *inventory.NotifyEvent, 4)
+
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := &inventory.NotifyEvent{}
+		if err := json.NewDecoder(r.Body).Decode(event); err != nil {
+			t.Errorf("notification payload decoding failure: %v", err)
+		}
+		events <- event
+	}))
+	defer sink.Close()
+
+	tests := []struct {
+		name     string
+		election bool
+		leader   bool
+		want     int
+	}{
+		{
+			// Every instance is the leader without leader election.
+			name: "valid-no-election",
+			want: 1,
+		},
+		{
+			name:     "valid-leader",
+			election: true,
+			leader:   true,
+			want:     1,
+		},
+		{
+			name:     "valid-follower",
+			election: true,
+			want:     0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			cfg.Server.Election.Enabled = tt.election
+
+			s := newTestServer(t, cfg, testRecords)
+			s.elector = testElector(tt.leader)
+
+			n, err := inventory.NewNotifier(inventory.NotifySpec{Type: "webhook", URL: sink.URL, Events: []string{inventory.ChangeNotifyEvent}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.Inventory.Notifiers = []*inventory.Notifier{n}
+
+			if err := s.Refresh(); err != nil {
+				t.Fatalf("Server.Refresh() error = %v", err)
+			}
+
+			// A host disappears between refreshes.
+			s.Inventory.Datasource = &testDatasource{records: testRecords[:1]}
+			if err := s.Refresh(); err != nil {
+				t.Fatalf("Server.Refresh() error = %v", err)
+			}
+
+			if got := len(events); got != tt.want {
+				t.Errorf("Server.Refresh() sent %d notifications, want %d", got, tt.want)
+			}
+
+			for len(events) > 0 {
+				<-events
+			}
+		})
+	}
+}
+
+func TestServer_handleRefresh_notReady(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.Server.Auth.Anonymous = []string{"read", "edit"}
+
+	s := newTestServer(t, cfg, testRecords)
+	handler := s.Handler()
+
+	// Zone refreshes need the hosts of the other zones from a full refresh.
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/refresh?zone=infra.local.", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("POST /refresh?zone status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	// A full refresh makes the inventory ready.
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/refresh", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("POST /refresh status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /readyz status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestServer_Handler(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		ready    bool
+		ui       bool
+		api      bool
+		want     int
+		wantBody string
+		wantLoc  string
+	}{
+		{name: "valid-healthz", method: http.MethodGet, path: "/healthz", want: http.StatusOK, wantBody: "ok"},
+		{name: "valid-version", method: http.MethodGet, path: "/version", want: http.StatusOK, wantBody: `"version"`},
+		{name: "valid-leader", method: http.MethodGet, path: "/leader", want: http.StatusOK, wantBody: `"leader":true`},
+		{name: "valid-host-not-ready", method: http.MethodGet, path: "/host/app01.infra.local", want: http.StatusOK, wantBody: `"heap":"2g"`},
+		{name: "valid-list", method: http.MethodGet, path: "/list", ready: true, want: http.StatusOK, wantBody: "app01.infra.local"},
+		{name: "valid-hosts", method: http.MethodGet, path: "/hosts", ready: true, want: http.StatusOK, wantBody: `"db01.infra.local":[`},
+		{name: "valid-groups", method: http.MethodGet, path: "/groups", ready: true, want: http.StatusOK, wantBody: "db01.infra.local"},
+		{name: "valid-attrs", method: http.MethodGet, path: "/attrs", ready: true, want: http.StatusOK, wantBody: `"SRV":"postgres"`},
+		{name: "valid-tree", method: http.MethodGet, path: "/tree", ready: true, want: http.StatusOK, wantBody: "app01.infra.local"},
+		{name: "valid-yaml", method: http.MethodGet, path: "/hosts?format=yaml", ready: true, want: http.StatusOK, wantBody: "db01.infra.local:"},
+		{name: "valid-readyz", method: http.MethodGet, path: "/readyz", ready: true, want: http.StatusOK, wantBody: "ok"},
+		{name: "valid-ui-redirect", method: http.MethodGet, path: "/", ui: true, want: http.StatusFound, wantLoc: "/ui/"},
+		{name: "valid-ui-settings", method: http.MethodGet, path: "/ui/settings", ui: true, want: http.StatusOK, wantBody: `"editing":false`},
+		{name: "valid-ui-settings-api", method: http.MethodGet, path: "/ui/settings", ui: true, api: true, want: http.StatusOK, wantBody: `"editing":true`},
+		{name: "invalid-readyz-not-ready", method: http.MethodGet, path: "/readyz", want: http.StatusServiceUnavailable},
+		{name: "invalid-list-not-ready", method: http.MethodGet, path: "/list", want: http.StatusServiceUnavailable},
+		{name: "invalid-hosts-not-ready", method: http.MethodGet, path: "/hosts", want: http.StatusServiceUnavailable},
+		{name: "invalid-groups-not-ready", method: http.MethodGet, path: "/groups", want: http.StatusServiceUnavailable},
+		{name: "invalid-attrs-not-ready", method: http.MethodGet, path: "/attrs", want: http.StatusServiceUnavailable},
+		{name: "invalid-tree-not-ready", method: http.MethodGet, path: "/tree", want: http.StatusServiceUnavailable},
+		{name: "invalid-format", method: http.MethodGet, path: "/hosts?format=xml", ready: true, want: http.StatusBadRequest},
+		{name: "invalid-method", method: http.MethodPost, path: "/list", ready: true, want: http.StatusMethodNotAllowed},
+		{name: "invalid-refresh-method", method: http.MethodGet, path: "/refresh", ready: true, want: http.StatusMethodNotAllowed},
+		{name: "invalid-path", method: http.MethodGet, path: "/nonexistent", want: http.StatusNotFound},
+		{name: "invalid-ui-disabled", method: http.MethodGet, path: "/ui/settings", want: http.StatusNotFound},
+		{name: "invalid-root-ui-disabled", method: http.MethodGet, path: "/", want: http.StatusNotFound},
+		{name: "invalid-api-anonymous", method: http.MethodDelete, path: "/hosts/app01.infra.local", ready: true, api: true, want: http.StatusUnauthorized},
+		{name: "invalid-api-disabled", method: http.MethodDelete, path: "/hosts/app01.infra.local", ready: true, want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			cfg.Txt.Vars.Enabled = true
+			cfg.Server.UI.Enabled = tt.ui
+			cfg.Server.API.Enabled = tt.api
+
+			s := newTestServer(t, cfg, testRecords)
+			if tt.ready {
+				if err := s.Refresh(); err != nil {
+					t.Fatalf("Server.Refresh() error = %v", err)
+				}
+			}
+
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code != tt.want {
+				t.Errorf("%s %s status = %d, want %d: %s", tt.method, tt.path, rec.Code, tt.want, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("%s %s body = %s, want %q", tt.method, tt.path, rec.Body, tt.wantBody)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLoc {
+				t.Errorf("%s %s Location = %q, want %q", tt.method, tt.path, got, tt.wantLoc)
+			}
+			if got := rec.Header().Get("Server"); !strings.HasPrefix(got, "ansible-dns-inventory/") {
+				t.Errorf("%s %s Server = %q", tt.method, tt.path, got)
+			}
+		})
+	}
+}
+
+func TestServer_Handler_permissions(t *testing.T) {
+	tests := []struct {
+		name      string
+		anonymous []string
+		method    string
+		path      string
+		token     string
+		header    string
+		want      int
+		wantAuth  string
+	}{
+		{name: "valid-anonymous-read", anonymous: []string{"read"}, method: http.MethodGet, path: "/hosts", want: http.StatusOK},
+		{name: "valid-anonymous-edit", anonymous: []string{"edit"}, method: http.MethodPost, path: "/refresh", want: http.StatusOK},
+		{name: "valid-reader", method: http.MethodGet, path: "/hosts", token: "r3ad", want: http.StatusOK},
+		{name: "valid-editor-read", method: http.MethodGet, path: "/list", token: "3dit", want: http.StatusOK},
+		{name: "valid-editor-refresh", method: http.MethodPost, path: "/refresh", token: "3dit", want: http.StatusOK},
+		{name: "valid-api-token-read", method: http.MethodGet, path: "/hosts", token: "api-s3cr3t", want: http.StatusOK},
+		{name: "valid-api-token-refresh", method: http.MethodPost, path: "/refresh", token: "api-s3cr3t", want: http.StatusOK},
+		{name: "valid-public-healthz", method: http.MethodGet, path: "/healthz", want: http.StatusOK},
+		{name: "valid-public-readyz", method: http.MethodGet, path: "/readyz", token: "unknown", want: http.StatusOK},
+		{name: "invalid-anonymous-read", method: http.MethodGet, path: "/hosts", want: http.StatusUnauthorized, wantAuth: `Bearer realm="ansible-dns-inventory"`},
+		{name: "invalid-anonymous-refresh", anonymous: []string{"read"}, method: http.MethodPost, path: "/refresh", want: http.StatusUnauthorized, wantAuth: `Bearer realm="ansible-dns-inventory"`},
+		{name: "invalid-reader-refresh", method: http.MethodPost, path: "/refresh", token: "r3ad", want: http.StatusForbidden},
+		{name: "invalid-no-permissions", method: http.MethodGet, path: "/hosts", token: "0ther", want: http.StatusForbidden},
+		{name: "invalid-unknown-token", anonymous: []string{"read"}, method: http.MethodGet, path: "/hosts", token: "unknown", want: http.StatusUnauthorized, wantAuth: `Bearer realm="ansible-dns-inventory", error="invalid_token"`},
+		{name: "invalid-basic-auth", anonymous: []string{"read"}, method: http.MethodGet, path: "/hosts", header: "Basic YWRpOnMzY3IzdA==", want: http.StatusUnauthorized, wantAuth: `Bearer realm="ansible-dns-inventory", error="invalid_token"`},
+		{name: "invalid-empty-bearer", method: http.MethodGet, path: "/hosts", header: "Bearer ", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			cfg.Server.API.Tokens = []string{"api-s3cr3t"}
+			cfg.Server.Auth.Anonymous = tt.anonymous
+			cfg.Server.Auth.Providers = []string{"static"}
+			cfg.Server.Auth.Static.Tokens = []inventory.AuthTokenSpec{
+				{Name: "reader", Token: "r3ad", Groups: []string{"readers"}},
+				{Name: "editor", Token: "3dit", Groups: []string{"readers", "editors"}},
+				{Name: "other", Token: "0ther", Groups: []string{"others"}},
+			}
+			cfg.Server.Auth.Groups = []inventory.AuthGroupSpec{
+				{Group: "readers", Permissions: []string{"read"}},
+				{Group: "editors", Permissions: []string{"edit"}},
+			}
+
+			s := newTestServer(t, cfg, testRecords)
+			if err := s.Refresh(); err != nil {
+				t.Fatalf("Server.Refresh() error = %v", err)
+			}
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if len(tt.token) > 0 {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if len(tt.header) > 0 {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("%s %s status = %d, want %d: %s", tt.method, tt.path, rec.Code, tt.want, rec.Body)
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); len(tt.wantAuth) > 0 && got != tt.wantAuth {
+				t.Errorf("%s %s WWW-Authenticate = %q, want %q", tt.method, tt.path, got, tt.wantAuth)
+			}
+		})
+	}
+}
+
+func Test_newAuthenticator(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(cfg *inventory.Config)
+		wantErr bool
+	}{
+		{name: "valid-default", mutate: func(cfg *inventory.Config) {}},
+		{name: "valid-static", mutate: func(cfg *inventory.Config) {
+			cfg.Server.Auth.Providers = []string{"static"}
+			cfg.Server.Auth.Static.Tokens = []inventory.AuthTokenSpec{{Name: "reader", Token: "r3ad"}}
+		}},
+		{name: "invalid-anonymous-permission", mutate: func(cfg *inventory.Config) { cfg.Server.Auth.Anonymous = []string{"admin"} }, wantErr: true},
+		{name: "invalid-group-permission", mutate: func(cfg *inventory.Config) {
+			cfg.Server.Auth.Groups = []inventory.AuthGroupSpec{{Group: "admins", Permissions: []string{"write"}}}
+		}, wantErr: true},
+		{name: "invalid-provider", mutate: func(cfg *inventory.Config) { cfg.Server.Auth.Providers = []string{"ldap"} }, wantErr: true},
+		{name: "invalid-static-token", mutate: func(cfg *inventory.Config) {
+			cfg.Server.Auth.Providers = []string{"static"}
+			cfg.Server.Auth.Static.Tokens = []inventory.AuthTokenSpec{{Name: "reader"}}
+		}, wantErr: true},
+		{name: "invalid-oidc-issuer", mutate: func(cfg *inventory.Config) { cfg.Server.Auth.Providers = []string{"oidc"} }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			tt.mutate(cfg)
+
+			if _, err := newAuthenticator(cfg); (err != nil) != tt.wantErr {
+				t.Errorf("newAuthenticator() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// First file descriptor passed by systemd socket activation.
+	sdListenFdsStart int = 3
+)
+
+// activationListener returns a listener passed by systemd socket activation or nil if the process was not socket activated.
+func activationListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, nil
+	}
+
+	// Do not pass the sockets to child processes (e.g. hooks).
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	f := os.NewFile(uintptr(sdListenFdsStart), "LISTEN_FD_"+strconv.Itoa(sdListenFdsStart))
+	defer f.Close()
+
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "socket activation failure")
+	}
+
+	return ln, nil
+}
+
+// notify sends a state notification (e.g. 'READY=1') to systemd if the service manager expects one.
+func notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if len(socket) == 0 {
+		return nil
+	}
+
+	// Abstract namespace socket.
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return errors.Wrap(err, "systemd notification failure")
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return errors.Wrap(err, "systemd notification failure")
+	}
+
+	return nil
+}
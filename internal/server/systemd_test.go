@@ -0,0 +1,198 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// activationHelperEnv makes the test binary act as a socket activated process.
+const activationHelperEnv string = "ADI_TEST_ACTIVATION_HELPER"
+
+func Test_activationListener(t *testing.T) {
+	if os.Getenv(activationHelperEnv) == "1" {
+		// systemd sets LISTEN_PID to the PID of the activated process, which is only known after it has started.
+		os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+
+		ln, err := activationListener()
+		if err != nil || ln == nil {
+			fmt.Printf("activationListener() = %v, %v\n", ln, err)
+			os.Exit(1)
+		}
+
+		for _, name := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+			if _, ok := os.LookupEnv(name); ok {
+				fmt.Printf("%s is still set\n", name)
+				os.Exit(1)
+			}
+		}
+
+		fmt.Printf("listening on %s\n", ln.Addr())
+		os.Exit(0)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// The first extra file is passed as descriptor 3.
+	cmd := exec.Command(os.Args[0], "-test.run=^Test_activationListener$")
+	cmd.Env = append(os.Environ(), activationHelperEnv+"=1", "LISTEN_FDS=1", "LISTEN_FDNAMES=http")
+	cmd.ExtraFiles = []*os.File{f}
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("socket activated process failure: %v: %s", err, out)
+	}
+
+	if want := "listening on " + ln.Addr().String(); !strings.Contains(string(out), want) {
+		t.Errorf("socket activated process output = %q, want %q", out, want)
+	}
+}
+
+func Test_activationListener_inactive(t *testing.T) {
+	tests := []struct {
+		name string
+		pid  string
+		fds  string
+	}{
+		{name: "valid-not-activated"},
+		{name: "valid-other-process", pid: strconv.Itoa(os.Getpid() + 1), fds: "1"},
+		{name: "valid-no-sockets", pid: strconv.Itoa(os.Getpid()), fds: "0"},
+		{name: "valid-invalid-sockets", pid: strconv.Itoa(os.Getpid()), fds: "x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LISTEN_PID", tt.pid)
+			t.Setenv("LISTEN_FDS", tt.fds)
+
+			ln, err := activationListener()
+			if ln != nil || err != nil {
+				t.Errorf("activationListener() = %v, %v, want nil, nil", ln, err)
+			}
+
+			// The variables are left for whichever process they are meant for.
+			if got := os.Getenv("LISTEN_PID"); got != tt.pid {
+				t.Errorf("LISTEN_PID = %q, want %q", got, tt.pid)
+			}
+		})
+	}
+}
+
+// listenNotify creates a datagram socket receiving systemd notifications and points NOTIFY_SOCKET at it.
+func listenNotify(t *testing.T, abstract bool) *net.UnixConn {
+	name := filepath.Join(t.TempDir(), "notify")
+	socket := name
+	if abstract {
+		name = fmt.Sprintf("\x00adi-test-%d-%d", os.Getpid(), time.Now().UnixNano())
+		socket = "@" + name[1:]
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	t.Setenv("NOTIFY_SOCKET", socket)
+
+	return conn
+}
+
+// readNotify returns the next notification received, or an empty string if none is received in time.
+func readNotify(t *testing.T, conn *net.UnixConn, timeout time.Duration) string {
+	buf := make([]byte, 256)
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	n, err := conn.Read(buf)
+	if err != nil {
+		return ""
+	}
+
+	return string(buf[:n])
+}
+
+func Test_notify(t *testing.T) {
+	tests := []struct {
+		name     string
+		abstract bool
+	}{
+		{name: "valid-path"},
+		{name: "valid-abstract", abstract: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := listenNotify(t, tt.abstract)
+
+			if err := notify("READY=1"); err != nil {
+				t.Fatalf("notify() error = %v", err)
+			}
+
+			if got := readNotify(t, conn, 5*time.Second); got != "READY=1" {
+				t.Errorf("notification = %q, want %q", got, "READY=1")
+			}
+		})
+	}
+
+	t.Run("valid-not-supervised", func(t *testing.T) {
+		t.Setenv("NOTIFY_SOCKET", "")
+
+		if err := notify("READY=1"); err != nil {
+			t.Errorf("notify() error = %v", err)
+		}
+	})
+
+	t.Run("invalid-socket", func(t *testing.T) {
+		t.Setenv("NOTIFY_SOCKET", filepath.Join(t.TempDir(), "missing"))
+
+		if err := notify("READY=1"); err == nil {
+			t.Error("notify() expected error")
+		}
+	})
+}
+
+func TestServer_rebuild_notify(t *testing.T) {
+	conn := listenNotify(t, false)
+
+	s := newTestServer(t, newTestConfig(t), testRecords)
+
+	// A failed initial refresh does not report readiness.
+	s.Inventory.Datasource = &testDatasource{err: errors.New("datasource is not available")}
+	if err := s.Refresh(); err == nil {
+		t.Fatal("Server.Refresh() expected error")
+	}
+	if got := readNotify(t, conn, 100*time.Millisecond); len(got) > 0 {
+		t.Errorf("notification after a failed refresh = %q", got)
+	}
+
+	// Readiness is reported once, by the first successful refresh.
+	s.Inventory.Datasource = &testDatasource{records: testRecords}
+	for i := 0; i < 2; i++ {
+		if err := s.Refresh(); err != nil {
+			t.Fatalf("Server.Refresh() error = %v", err)
+		}
+	}
+
+	if got := readNotify(t, conn, 5*time.Second); got != "READY=1" {
+		t.Errorf("notification = %q, want %q", got, "READY=1")
+	}
+	if got := readNotify(t, conn, 100*time.Millisecond); len(got) > 0 {
+		t.Errorf("unexpected second notification = %q", got)
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// Web UI assets.
+//
+//go:embed ui
+var uiAssets embed.FS
+
+// uiSettings describes the server features available to the web UI.
+type uiSettings struct {
+	// Host editing is enabled.
+	Editing bool `json:"editing"`
+	// Inventory data can only be read with a bearer token.
+	Protected bool `json:"protected"`
+	// Host attribute keys, in record order.
+	Keys []string `json:"keys"`
+	// Required host attribute keys.
+	Required []string `json:"required"`
+}
+
+// handleUISettings serves the settings of the web UI.
+func (s *Server) handleUISettings(w http.ResponseWriter, r *http.Request) {
+	cfg := s.Inventory.Config
+	keys := cfg.Txt.Keys
+
+	settings := &uiSettings{
+		Editing:   cfg.Server.API.Enabled,
+		Protected: !s.auth.allowed(nil, readPermission),
+		Keys:      append(append([]string{keys.Os, keys.Env, keys.Role, keys.Srv, keys.Vars}, keys.Extra...), keys.ID),
+		Required:  []string{keys.Os, keys.Env, keys.Role},
+	}
+
+	s.write(w, r, settings)
+}
+
+// uiHandler returns the HTTP handler serving the web UI assets.
+// Scripts and styles are only loaded from the server itself, data is always inserted into the page as text.
+func (s *Server) uiHandler() http.Handler {
+	assets, err := fs.Sub(uiAssets, "ui")
+	if err != nil {
+		// The embedded directory is always present.
+		panic(err)
+	}
+
+	files := http.StripPrefix("/ui/", http.FileServerFS(assets))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		files.ServeHTTP(w, r)
+	})
+}
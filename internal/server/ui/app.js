@@ -0,0 +1,399 @@
+'use strict';
+
+// Web UI of the ansible-dns-inventory server mode.
+// Inventory data is read from the regular server endpoints, edits are sent to the host editing API.
+// The bearer token entered in the UI is sent with all requests. All data is inserted into the page as text.
+
+const state = {
+  settings: { editing: false, protected: false, keys: [], required: [] },
+  tree: null,
+  attrs: {},
+  groups: {},
+  group: null,
+  host: null,
+  creating: false,
+};
+
+const $ = (id) => document.getElementById(id);
+
+function el(tag, text, cls) {
+  const e = document.createElement(tag);
+  if (text !== undefined) {
+    e.textContent = text;
+  }
+  if (cls) {
+    e.className = cls;
+  }
+  return e;
+}
+
+function token() {
+  return sessionStorage.getItem('adi-token') || '';
+}
+
+async function request(method, path, body) {
+  const opts = { method, headers: {} };
+  if (token()) {
+    opts.headers['Authorization'] = 'Bearer ' + token();
+  }
+  if (body !== undefined) {
+    opts.headers['Content-Type'] = 'application/json';
+    opts.body = JSON.stringify(body);
+  }
+
+  const resp = await fetch('../' + path, opts);
+  const text = await resp.text();
+  if (!resp.ok) {
+    const err = new Error(text.trim() || resp.statusText);
+    err.status = resp.status;
+    throw err;
+  }
+
+  return text.length > 0 ? JSON.parse(text) : null;
+}
+
+function status(message) {
+  $('status').textContent = message || '';
+}
+
+async function load() {
+  try {
+    const [settings, version] = await Promise.all([request('GET', 'ui/settings'), request('GET', 'version')]);
+    state.settings = settings;
+    $('version').textContent = version.version || '';
+    $('auth').hidden = !settings.editing && !settings.protected;
+    $('new-host').hidden = !settings.editing;
+
+    const [tree, attrs, groups] = await Promise.all([request('GET', 'tree'), request('GET', 'attrs'), request('GET', 'hosts')]);
+    state.tree = tree;
+    state.attrs = attrs || {};
+    state.groups = groups || {};
+    status('');
+  } catch (err) {
+    if (err.status === 401 || err.status === 403) {
+      // Loading is retried once a token is entered.
+      $('token').focus();
+      status(err.status === 401 ? 'A valid token is required to read the inventory.' : 'The token is not allowed to read the inventory.');
+      return;
+    }
+    status(err.status === 503 ? 'The inventory is not ready yet, retrying...' : 'Inventory loading failure: ' + err.message);
+    setTimeout(load, 5000);
+    return;
+  }
+
+  renderTree();
+  renderHosts();
+  if (state.host && !state.creating) {
+    if (state.attrs[state.host]) {
+      showHost(state.host);
+    } else {
+      $('details').hidden = true;
+      state.host = null;
+    }
+  }
+}
+
+// subtreeHosts returns the hosts of a group and all of its descendants.
+function subtreeHosts(node, hosts) {
+  hosts = hosts || new Set();
+  (node.hosts || []).forEach((h) => hosts.add(h));
+  (node.children || []).forEach((c) => subtreeHosts(c, hosts));
+  return hosts;
+}
+
+function renderTree() {
+  const root = $('tree');
+  root.replaceChildren();
+  if (state.tree) {
+    root.appendChild(treeNode(state.tree, true));
+  }
+}
+
+function treeNode(node, open) {
+  const details = el('details');
+  details.open = open || (state.group !== null && groupContains(node, state.group));
+
+  const summary = el('summary', node.name);
+  summary.appendChild(el('span', ' (' + subtreeHosts(node).size + ')', 'count'));
+  if (state.group === node.name) {
+    summary.classList.add('selected');
+  }
+  summary.addEventListener('click', () => {
+    // The tree is not rebuilt, so that the group is expanded or collapsed as usual.
+    $('tree').querySelectorAll('summary.selected').forEach((e) => e.classList.remove('selected'));
+    summary.classList.add('selected');
+    state.group = node.name;
+    renderHosts();
+  });
+  details.appendChild(summary);
+
+  (node.children || []).forEach((c) => details.appendChild(treeNode(c, false)));
+
+  return details;
+}
+
+function groupContains(node, name) {
+  return (node.children || []).some((c) => c.name === name || groupContains(c, name));
+}
+
+function findGroup(node, name) {
+  if (!node || node.name === name) {
+    return node;
+  }
+  for (const c of node.children || []) {
+    const found = findGroup(c, name);
+    if (found) {
+      return found;
+    }
+  }
+  return null;
+}
+
+// selectGroup selects a group and expands the tree down to it.
+function selectGroup(name) {
+  state.group = name;
+  renderTree();
+  renderHosts();
+}
+
+// matches checks whether a host matches the search query by its name, groups or attribute values.
+function matches(host, query) {
+  if (query.length === 0 || host.toLowerCase().includes(query)) {
+    return true;
+  }
+  if ((state.groups[host] || []).some((g) => g.toLowerCase().includes(query))) {
+    return true;
+  }
+  return (state.attrs[host] || []).some((a) => Object.values(a).some((v) => String(v).toLowerCase().includes(query)));
+}
+
+function renderHosts() {
+  const query = $('search').value.trim().toLowerCase();
+  const group = state.group ? findGroup(state.tree, state.group) : null;
+  const hosts = group ? [...subtreeHosts(group)] : Object.keys(state.attrs);
+
+  const list = $('hosts');
+  list.replaceChildren();
+
+  const shown = hosts.filter((h) => matches(h, query)).sort();
+  shown.forEach((h) => {
+    const li = el('li', h);
+    if (h === state.host) {
+      li.classList.add('selected');
+    }
+    li.addEventListener('click', () => showHost(h));
+    list.appendChild(li);
+  });
+
+  $('list-title').textContent = (group ? group.name : 'Hosts') + ' (' + shown.length + ')';
+}
+
+async function showHost(host) {
+  state.host = host;
+  state.creating = false;
+  renderHosts();
+
+  $('details').hidden = false;
+  $('host-name').textContent = host;
+
+  const groups = $('host-groups');
+  groups.replaceChildren();
+  (state.groups[host] || []).forEach((g) => {
+    const li = el('li', g);
+    li.addEventListener('click', () => selectGroup(g));
+    groups.appendChild(li);
+  });
+
+  const records = state.attrs[host] || [];
+  const keys = attributeKeys(records);
+  const table = el('table');
+  const head = el('tr');
+  keys.forEach((k) => head.appendChild(el('th', k)));
+  table.appendChild(head);
+  records.forEach((r) => {
+    const row = el('tr');
+    keys.forEach((k) => row.appendChild(el('td', r[k] || '')));
+    table.appendChild(row);
+  });
+  $('host-attrs').replaceChildren(table);
+
+  showEditor(records);
+
+  $('host-vars').textContent = '';
+  try {
+    const vars = await request('GET', 'host/' + encodeURIComponent(host));
+    if (state.host === host) {
+      $('host-vars').textContent = JSON.stringify(vars, null, 2);
+    }
+  } catch (err) {
+    $('host-vars').textContent = 'Host variables loading failure: ' + err.message;
+  }
+}
+
+// attributeKeys returns the configured attribute keys followed by any other keys found in records.
+function attributeKeys(records) {
+  const keys = [...state.settings.keys];
+  records.forEach((r) => Object.keys(r).forEach((k) => {
+    if (!keys.includes(k)) {
+      keys.push(k);
+    }
+  }));
+  return keys;
+}
+
+function showEditor(records) {
+  $('editor').hidden = !state.settings.editing;
+  $('editor-host-label').hidden = !state.creating;
+  $('delete').hidden = state.creating;
+  feedback('', '');
+
+  const container = $('editor-records');
+  container.replaceChildren();
+  const keys = attributeKeys(records);
+  const table = el('table');
+  const head = el('tr');
+  keys.forEach((k) => head.appendChild(el('th', k)));
+  head.appendChild(el('th'));
+  table.appendChild(head);
+  container.appendChild(table);
+
+  const addRow = (r) => {
+    const row = el('tr');
+    keys.forEach((k) => {
+      const td = el('td');
+      const input = el('input');
+      input.name = k;
+      input.value = r[k] || '';
+      input.required = state.settings.required.includes(k);
+      td.appendChild(input);
+      row.appendChild(td);
+    });
+    const td = el('td');
+    const remove = el('button', 'Remove');
+    remove.type = 'button';
+    remove.addEventListener('click', () => row.remove());
+    td.appendChild(remove);
+    row.appendChild(td);
+    table.appendChild(row);
+  };
+
+  records.forEach(addRow);
+  if (records.length === 0) {
+    addRow({});
+  }
+  $('add-record').onclick = () => addRow({});
+}
+
+// editedRecords collects attribute sets from the editor, omitting empty values.
+function editedRecords() {
+  const records = [];
+  $('editor-records').querySelectorAll('tr').forEach((row) => {
+    const inputs = row.querySelectorAll('input');
+    if (inputs.length === 0) {
+      return;
+    }
+    const r = {};
+    inputs.forEach((i) => {
+      if (i.value.trim().length > 0) {
+        r[i.name] = i.value.trim();
+      }
+    });
+    records.push(r);
+  });
+  return records;
+}
+
+function feedback(message, cls) {
+  const f = $('feedback');
+  f.textContent = message;
+  f.className = cls;
+}
+
+function editFailure(err) {
+  if (err.status === 401) {
+    $('token').focus();
+    feedback('A valid token is required for editing.', 'error');
+    return;
+  }
+  if (err.status === 403) {
+    feedback('The token is not allowed to edit hosts.', 'error');
+    return;
+  }
+  feedback(err.message, 'error');
+}
+
+async function save() {
+  const host = state.creating ? $('editor-host').value.trim().toLowerCase() : state.host;
+  if (!host) {
+    feedback('Hostname is not set.', 'error');
+    return;
+  }
+
+  const invalid = $('editor-records').querySelector('input:invalid');
+  if (invalid) {
+    invalid.focus();
+    feedback(invalid.name + ' is required.', 'error');
+    return;
+  }
+
+  try {
+    const records = await request('PUT', 'hosts/' + encodeURIComponent(host), editedRecords());
+    state.host = host;
+    state.creating = false;
+    await load();
+    feedback('Saved ' + records.length + ' record(s).', 'ok');
+  } catch (err) {
+    editFailure(err);
+  }
+}
+
+async function remove() {
+  const host = state.host;
+  if (!confirm('Delete all records of ' + host + '?')) {
+    return;
+  }
+
+  try {
+    await request('DELETE', 'hosts/' + encodeURIComponent(host));
+    state.host = null;
+    $('details').hidden = true;
+    await load();
+    status(host + ' has been deleted.');
+  } catch (err) {
+    editFailure(err);
+  }
+}
+
+function newHost() {
+  state.host = null;
+  state.creating = true;
+  renderHosts();
+
+  $('details').hidden = false;
+  $('host-name').textContent = 'New host';
+  $('host-groups').replaceChildren();
+  $('host-attrs').replaceChildren();
+  $('host-vars').textContent = '';
+  $('editor-host').value = '';
+  showEditor([]);
+  $('editor-host').focus();
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+  $('token').value = token();
+  $('auth').addEventListener('submit', (e) => {
+    e.preventDefault();
+    sessionStorage.setItem('adi-token', $('token').value.trim());
+    status('The token will be used in this tab.');
+    if (state.settings.protected) {
+      load();
+    }
+  });
+  $('search').addEventListener('input', renderHosts);
+  $('new-host').addEventListener('click', newHost);
+  $('save').addEventListener('click', save);
+  $('delete').addEventListener('click', remove);
+  $('list-title').addEventListener('click', () => selectGroup(null));
+
+  load();
+});
@@ -0,0 +1,123 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// orderedMap represents a map with string keys that is marshalled with its keys in a specific order.
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	yamlMarshalerType = reflect.TypeOf((*yaml.Marshaler)(nil)).Elem()
+)
+
+// MarshalJSON implements a custom JSON Marshaller for ordered maps.
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+
+	for n, key := range m.keys {
+		if n > 0 {
+			buf.WriteByte(',')
+		}
+
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// MarshalYAML implements a custom YAML Marshaller for ordered maps.
+func (m *orderedMap) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+
+	for _, key := range m.keys {
+		k, v := &yaml.Node{}, &yaml.Node{}
+
+		if err := k.Encode(key); err != nil {
+			return nil, err
+		}
+
+		if err := v.Encode(m.values[key]); err != nil {
+			return nil, err
+		}
+
+		node.Content = append(node.Content, k, v)
+	}
+
+	return node, nil
+}
+
+// collate returns v with all maps with string keys, including nested ones, replaced by maps that are marshalled with their keys ordered by compare.
+// Structs and maps with custom marshallers are left as is. If compare is nil, the encoders order map keys themselves and v is returned unchanged.
+func collate(v interface{}, compare func(a, b string) int) interface{} {
+	if compare == nil {
+		return v
+	}
+
+	return collateValue(reflect.ValueOf(v), compare)
+}
+
+// collateValue implements collate for reflected values.
+func collateValue(v reflect.Value, compare func(a, b string) int) interface{} {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+
+		return collateValue(v.Elem(), compare)
+	case reflect.Map:
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String || v.Type().Implements(jsonMarshalerType) || v.Type().Implements(yamlMarshalerType) {
+			return v.Interface()
+		}
+
+		m := &orderedMap{keys: make([]string, 0, v.Len()), values: make(map[string]interface{}, v.Len())}
+		for iter := v.MapRange(); iter.Next(); {
+			key := iter.Key().String()
+			m.keys = append(m.keys, key)
+			m.values[key] = collateValue(iter.Value(), compare)
+		}
+		slices.SortFunc(m.keys, compare)
+
+		return m
+	case reflect.Slice:
+		if kind := v.Type().Elem().Kind(); v.IsNil() || (kind != reflect.Interface && kind != reflect.Map) {
+			return v.Interface()
+		}
+
+		result := make([]interface{}, v.Len())
+		for n := range result {
+			result[n] = collateValue(v.Index(n), compare)
+		}
+
+		return result
+	}
+
+	return v.Interface()
+}
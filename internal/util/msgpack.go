@@ -0,0 +1,151 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// marshalMsgpack returns the MessagePack encoding of v.
+// The value is converted from its JSON encoding, so the output has the same structure and key order as the 'json' format.
+func marshalMsgpack(v interface{}, compare func(a, b string) int) ([]byte, error) {
+	data, err := json.Marshal(collate(v, compare))
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	buf := new(bytes.Buffer)
+	if err := msgpackValue(buf, dec); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// msgpackValue converts the next JSON value read by dec into MessagePack and writes it to buf.
+func msgpackValue(buf *bytes.Buffer, dec *json.Decoder) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		// Elements are encoded first as the header of a map or an array includes their number.
+		elements := new(bytes.Buffer)
+		n := 0
+
+		for dec.More() {
+			if err := msgpackValue(elements, dec); err != nil {
+				return err
+			}
+			n++
+		}
+
+		// Consume the closing delimiter.
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+
+		if t == '{' {
+			// Keys and values are counted separately.
+			msgpackHeader(buf, n/2, 0x80, 0xde, 0xdf)
+		} else {
+			msgpackHeader(buf, n, 0x90, 0xdc, 0xdd)
+		}
+		elements.WriteTo(buf)
+	case string:
+		msgpackString(buf, t)
+	case json.Number:
+		return msgpackNumber(buf, t)
+	case bool:
+		if t {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case nil:
+		buf.WriteByte(0xc0)
+	default:
+		return errors.Errorf("unsupported JSON token: %v", token)
+	}
+
+	return nil
+}
+
+// msgpackHeader writes the header of a map or an array with n elements using its fix, 16-bit or 32-bit form.
+func msgpackHeader(buf *bytes.Buffer, n int, fix, b16, b32 byte) {
+	switch {
+	case n < 16:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(b16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(b32)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+// msgpackString writes a string using the smallest str format.
+func msgpackString(buf *bytes.Buffer, s string) {
+	n := len(s)
+
+	switch {
+	case n < 32:
+		buf.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xda)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdb)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+
+	io.WriteString(buf, s)
+}
+
+// msgpackNumber writes a number as an integer if it has no fractional part and fits into 64 bits, or as a float otherwise.
+func msgpackNumber(buf *bytes.Buffer, n json.Number) error {
+	if i, err := strconv.ParseInt(n.String(), 10, 64); err == nil {
+		switch {
+		case i >= 0 && i <= math.MaxInt8:
+			buf.WriteByte(byte(i))
+		case i < 0 && i >= -32:
+			buf.WriteByte(byte(int8(i)))
+		default:
+			buf.WriteByte(0xd3)
+			binary.Write(buf, binary.BigEndian, i)
+		}
+
+		return nil
+	}
+
+	if u, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+		buf.WriteByte(0xcf)
+		binary.Write(buf, binary.BigEndian, u)
+
+		return nil
+	}
+
+	f, err := n.Float64()
+	if err != nil {
+		return err
+	}
+
+	buf.WriteByte(0xcb)
+	binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+
+	return nil
+}
@@ -0,0 +1,347 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// msgpackPair is a key/value pair of a decoded MessagePack map.
+type msgpackPair struct {
+	Key   string
+	Value interface{}
+}
+
+// decodeMsgpack decodes a single MessagePack value. Maps are decoded into lists of pairs, so that their key order is kept.
+func decodeMsgpack(r *bytes.Reader) (interface{}, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	read := func(n int) ([]byte, error) {
+		buf := make([]byte, n)
+		_, err := io.ReadFull(r, buf)
+		return buf, err
+	}
+	length := func(size int) (int, error) {
+		buf, err := read(size)
+		if err != nil {
+			return 0, err
+		}
+
+		switch size {
+		case 1:
+			return int(buf[0]), nil
+		case 2:
+			return int(binary.BigEndian.Uint16(buf)), nil
+		default:
+			return int(binary.BigEndian.Uint32(buf)), nil
+		}
+	}
+	str := func(n int) (interface{}, error) {
+		buf, err := read(n)
+		return string(buf), err
+	}
+	array := func(n int) (interface{}, error) {
+		values := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			v, err := decodeMsgpack(r)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	}
+	dict := func(n int) (interface{}, error) {
+		pairs := make([]msgpackPair, 0, n)
+		for i := 0; i < n; i++ {
+			k, err := decodeMsgpack(r)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, errors.Errorf("map key is not a string: %v", k)
+			}
+			v, err := decodeMsgpack(r)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, msgpackPair{Key: key, Value: v})
+		}
+		return pairs, nil
+	}
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xe0 == 0xa0:
+		return str(int(b & 0x1f))
+	case b&0xf0 == 0x90:
+		return array(int(b & 0x0f))
+	case b&0xf0 == 0x80:
+		return dict(int(b & 0x0f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcb:
+		buf, err := read(8)
+		return math.Float64frombits(binary.BigEndian.Uint64(buf)), err
+	case 0xcf:
+		buf, err := read(8)
+		return binary.BigEndian.Uint64(buf), err
+	case 0xd3:
+		buf, err := read(8)
+		return int64(binary.BigEndian.Uint64(buf)), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := length(1 << (b - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return str(n)
+	case 0xdc, 0xdd:
+		n, err := length(2 << (b - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return array(n)
+	case 0xde, 0xdf:
+		n, err := length(2 << (b - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return dict(n)
+	default:
+		return nil, errors.Errorf("unexpected format: 0x%02x", b)
+	}
+}
+
+// decodeJSON reads the next JSON value into the form produced by decodeMsgpack, keeping the key order of objects.
+func decodeJSON(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		pairs := make([]msgpackPair, 0)
+		values := make([]interface{}, 0)
+		for dec.More() {
+			var key string
+			if t == '{' {
+				k, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key = k.(string)
+			}
+
+			v, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			if t == '{' {
+				pairs = append(pairs, msgpackPair{Key: key, Value: v})
+			} else {
+				values = append(values, v)
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+
+		if t == '{' {
+			return pairs, nil
+		}
+		return values, nil
+	case json.Number:
+		if i, err := strconv.ParseInt(t.String(), 10, 64); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(t.String(), 10, 64); err == nil {
+			return u, nil
+		}
+		return t.Float64()
+	default:
+		return t, nil
+	}
+}
+
+func Test_marshalMsgpack_formats(t *testing.T) {
+	keys := func(n int) map[string]int {
+		m := make(map[string]int, n)
+		for i := 0; i < n; i++ {
+			m[strconv.Itoa(i)] = 0
+		}
+		return m
+	}
+
+	tests := []struct {
+		name string
+		v    interface{}
+		// Expected encoding prefix, hex-encoded.
+		want string
+	}{
+		{name: "valid-nil", v: nil, want: "c0"},
+		{name: "valid-false", v: false, want: "c2"},
+		{name: "valid-true", v: true, want: "c3"},
+		{name: "valid-fixstr-empty", v: "", want: "a0"},
+		{name: "valid-fixstr-max", v: strings.Repeat("a", 31), want: "bf61"},
+		{name: "valid-str8-min", v: strings.Repeat("a", 32), want: "d92061"},
+		{name: "valid-str8-max", v: strings.Repeat("a", 255), want: "d9ff61"},
+		{name: "valid-str16-min", v: strings.Repeat("a", 256), want: "da010061"},
+		{name: "valid-str16-max", v: strings.Repeat("a", 65535), want: "daffff61"},
+		{name: "valid-str32-min", v: strings.Repeat("a", 65536), want: "db0001000061"},
+		{name: "valid-str-utf8", v: strings.Repeat("é", 16), want: "d920c3a9"},
+		{name: "valid-fixarray-empty", v: []int{}, want: "90"},
+		{name: "valid-fixarray-max", v: make([]int, 15), want: "9f00"},
+		{name: "valid-array16-min", v: make([]int, 16), want: "dc001000"},
+		{name: "valid-array16-max", v: make([]int, 65535), want: "dcffff00"},
+		{name: "valid-array32-min", v: make([]int, 65536), want: "dd0001000000"},
+		{name: "valid-fixmap-empty", v: map[string]int{}, want: "80"},
+		{name: "valid-fixmap-max", v: keys(15), want: "8fa130"},
+		{name: "valid-map16-min", v: keys(16), want: "de0010a130"},
+		{name: "valid-map16-max", v: keys(65535), want: "deffffa130"},
+		{name: "valid-map32-min", v: keys(65536), want: "df00010000a130"},
+		{name: "valid-fixint-zero", v: 0, want: "00"},
+		{name: "valid-fixint-max", v: 127, want: "7f"},
+		{name: "valid-int-over-fixint", v: 128, want: "d30000000000000080"},
+		{name: "valid-negative-fixint-max", v: -1, want: "ff"},
+		{name: "valid-negative-fixint-min", v: -32, want: "e0"},
+		{name: "valid-int-under-negative-fixint", v: -33, want: "d3ffffffffffffffdf"},
+		{name: "valid-int64-max", v: int64(math.MaxInt64), want: "d37fffffffffffffff"},
+		{name: "valid-int64-min", v: int64(math.MinInt64), want: "d38000000000000000"},
+		{name: "valid-uint64-over-int64", v: uint64(math.MaxInt64) + 1, want: "cf8000000000000000"},
+		{name: "valid-uint64-max", v: uint64(math.MaxUint64), want: "cfffffffffffffffff"},
+		{name: "valid-float", v: 1.5, want: "cb3ff8000000000000"},
+		{name: "valid-float-negative", v: -0.25, want: "cbbfd0000000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := marshalMsgpack(tt.v, nil)
+			if err != nil {
+				t.Fatalf("marshalMsgpack() error = %v", err)
+			}
+
+			if prefix := hex.EncodeToString(got[:min(len(got), len(tt.want)/2)]); prefix != tt.want {
+				t.Errorf("marshalMsgpack() prefix = %s, want %s", prefix, tt.want)
+			}
+
+			// Every value is decoded back in full.
+			r := bytes.NewReader(got)
+			if _, err := decodeMsgpack(r); err != nil {
+				t.Fatalf("decodeMsgpack() error = %v", err)
+			}
+			if r.Len() > 0 {
+				t.Errorf("decodeMsgpack() left %d trailing bytes", r.Len())
+			}
+		})
+	}
+}
+
+func Test_marshalMsgpack_roundTrip(t *testing.T) {
+	type host struct {
+		Name   string            `json:"name"`
+		Vars   map[string]string `json:"vars"`
+		Groups []string          `json:"groups"`
+		Parent *host             `json:"parent"`
+	}
+
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{name: "valid-scalars", v: []interface{}{"", "app01", 0, -1, 127, 128, -33, 65536, 1.5, true, false, nil}},
+		{name: "valid-nil-values", v: map[string]interface{}{"nil": nil, "slice": []string(nil), "map": map[string]int(nil), "ptr": (*host)(nil)}},
+		{name: "valid-nested-maps", v: map[string]interface{}{
+			"b": map[string]interface{}{"z": map[string]interface{}{"deep": []interface{}{map[string]interface{}{"x": 1}, []interface{}{}}}},
+			"a": map[string]interface{}{"empty": map[string]interface{}{}},
+		}},
+		{name: "valid-structs", v: map[string]*host{
+			"app01.infra.local": {Name: "app01", Vars: map[string]string{"heap": "2g", "gc": "g1"}, Groups: []string{"app", "prod"}},
+			"app02.infra.local": {Name: "app02", Parent: &host{Name: "app01"}},
+		}},
+		{name: "valid-large-map", v: func() map[string]interface{} {
+			m := make(map[string]interface{})
+			for i := 0; i < 100; i++ {
+				m[fmt.Sprintf("host%03d", i)] = map[string]interface{}{"id": i, "name": strings.Repeat("x", i*3)}
+			}
+			return m
+		}()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := marshalMsgpack(tt.v, nil)
+			if err != nil {
+				t.Fatalf("marshalMsgpack() error = %v", err)
+			}
+
+			decoded, err := decodeMsgpack(bytes.NewReader(got))
+			if err != nil {
+				t.Fatalf("decodeMsgpack() error = %v", err)
+			}
+
+			data, err := json.Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec := json.NewDecoder(bytes.NewReader(data))
+			dec.UseNumber()
+			want, err := decodeJSON(dec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The 'msgpack' format has the same structure and key order as the 'json' format.
+			if !reflect.DeepEqual(decoded, want) {
+				t.Errorf("marshalMsgpack() decoded = %#v, want %#v", decoded, want)
+			}
+		})
+	}
+}
+
+func Test_marshalMsgpack_collation(t *testing.T) {
+	// Keys follow the collation, like in the 'json' format.
+	reverse := func(a, b string) int { return strings.Compare(b, a) }
+
+	got, err := marshalMsgpack(map[string]interface{}{"a": 1, "c": map[string]int{"x": 1, "y": 2}, "b": 2}, reverse)
+	if err != nil {
+		t.Fatalf("marshalMsgpack() error = %v", err)
+	}
+
+	decoded, err := decodeMsgpack(bytes.NewReader(got))
+	if err != nil {
+		t.Fatalf("decodeMsgpack() error = %v", err)
+	}
+
+	want := []msgpackPair{
+		{Key: "c", Value: []msgpackPair{{Key: "y", Value: int64(2)}, {Key: "x", Value: int64(1)}}},
+		{Key: "b", Value: int64(2)},
+		{Key: "a", Value: int64(1)},
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("marshalMsgpack() decoded = %v, want %v", decoded, want)
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
 	"gopkg.in/yaml.v3"
 
 	"github.com/NeonSludge/ansible-dns-inventory/pkg/inventory"
@@ -18,13 +20,35 @@ func Marshal(v interface{}, format string, cfg *inventory.Config) ([]byte, error
 	var bytes []byte
 	var err error
 
+	// Order map keys using the configured collation, if any.
+	var compare func(a, b string) int
+	if len(cfg.Sort.Collation) > 0 {
+		if compare, err = inventory.NewCollation(cfg.Sort.Collation, cfg.Sort.Numeric); err != nil {
+			return nil, err
+		}
+	}
+
 	switch format {
 	case "yaml":
-		bytes, err = yaml.Marshal(v)
+		bytes, err = yaml.Marshal(collate(v, compare))
 	case "json":
-		bytes, err = json.Marshal(v)
+		bytes, err = json.Marshal(collate(v, compare))
+	case "values":
+		bytes, err = marshalValues(v, compare)
+	case "terraform":
+		bytes, err = marshalTerraform(v, cfg, compare)
+	case "ansible-yaml":
+		bytes, err = marshalAnsibleYAML(v, compare)
+	case "pb", "pbjson":
+		bytes, err = marshalWire(v, format, compare)
+	case "msgpack":
+		bytes, err = marshalMsgpack(v, compare)
 	default:
-		bytes, err = marshalYAMLFlow(v, format, cfg)
+		if r, ok := inventory.GetRenderer(format); ok {
+			bytes, err = marshalRendered(v, format, r, cfg, compare)
+		} else {
+			bytes, err = marshalYAMLFlow(v, format, cfg, compare)
+		}
 	}
 
 	if err != nil {
@@ -36,13 +60,19 @@ func Marshal(v interface{}, format string, cfg *inventory.Config) ([]byte, error
 
 // marshalYAMLFlow returns the flow-style YAML encoding of v which can be a map[string][]string or a map[string]*types.TXTAttrs.
 // It supports two formats of marshalling the values in the map: as a YAML list (format=yaml-list) and as a CSV string (format=yaml-csv).
+// Keys are ordered by compare, or by byte order if compare is nil.
 // TODO: deal with yaml.Marshal's issues with flow-style encoding and switch to using that instead of this hack.
-func marshalYAMLFlow(v interface{}, format string, cfg *inventory.Config) ([]byte, error) {
+func marshalYAMLFlow(v interface{}, format string, cfg *inventory.Config, compare func(a, b string) int) ([]byte, error) {
 	buf := new(bytes.Buffer)
 
+	if compare == nil {
+		compare = strings.Compare
+	}
+
 	switch v := v.(type) {
 	case map[string][]string:
-		for key, value := range v {
+		for _, key := range sortedKeys(v, compare) {
+			value := v[key]
 			var yaml string
 
 			switch format {
@@ -60,7 +90,8 @@ func marshalYAMLFlow(v interface{}, format string, cfg *inventory.Config) ([]byt
 			}
 		}
 	case map[string][]*inventory.HostAttributes:
-		for key, value := range v {
+		for _, key := range sortedKeys(v, compare) {
+			value := v[key]
 			var yaml []string
 			for _, attrs := range value {
 				switch format {
@@ -82,6 +113,153 @@ func marshalYAMLFlow(v interface{}, format string, cfg *inventory.Config) ([]byt
 	return buf.Bytes(), nil
 }
 
+// marshalValues returns the Helm values-style YAML encoding of v which must be an inventory tree node.
+func marshalValues(v interface{}, compare func(a, b string) int) ([]byte, error) {
+	node, ok := v.(*inventory.Node)
+	if !ok {
+		return nil, fmt.Errorf("unsupported format: values")
+	}
+
+	values := make(map[string]interface{})
+	node.ExportValues(values)
+
+	return yaml.Marshal(collate(values, compare))
+}
+
+// marshalRendered returns the target list produced by a host list renderer from v which must be a map of host attributes.
+func marshalRendered(v interface{}, format string, r *inventory.Renderer, cfg *inventory.Config, compare func(a, b string) int) ([]byte, error) {
+	hosts, ok := v.(map[string][]*inventory.HostAttributes)
+	if !ok {
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+
+	rendered, err := r.Render(hosts, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch r.Encoding {
+	case "json":
+		return json.Marshal(collate(rendered, compare))
+	case "yaml":
+		return yaml.Marshal(collate(rendered, compare))
+	default:
+		return nil, fmt.Errorf("unsupported renderer encoding: %s", r.Encoding)
+	}
+}
+
+// marshalAnsibleYAML returns the static Ansible YAML inventory encoding of v which must be an inventory tree node or an exported Ansible inventory.
+// Groups are nested under their parents ('all: children: ...'), hosts are encoded as keys with empty values.
+func marshalAnsibleYAML(v interface{}, compare func(a, b string) int) ([]byte, error) {
+	groups := make(map[string]*inventory.AnsibleGroup)
+
+	switch v := v.(type) {
+	case *inventory.Node:
+		v.ExportInventory(groups)
+	case map[string]*inventory.AnsibleGroup:
+		groups = v
+	default:
+		return nil, fmt.Errorf("unsupported format: ansible-yaml")
+	}
+
+	if _, ok := groups["all"]; !ok {
+		return nil, fmt.Errorf("root group not found")
+	}
+
+	return yaml.Marshal(collate(map[string]interface{}{"all": ansibleYAMLGroup("all", groups)}, compare))
+}
+
+// ansibleYAMLGroup produces the static Ansible YAML inventory representation of a group and its children.
+func ansibleYAMLGroup(name string, groups map[string]*inventory.AnsibleGroup) map[string]interface{} {
+	group := groups[name]
+	result := make(map[string]interface{})
+
+	if len(group.Hosts) > 0 {
+		hosts := make(map[string]interface{}, len(group.Hosts))
+		for _, host := range group.Hosts {
+			hosts[host] = map[string]interface{}{}
+		}
+		result["hosts"] = hosts
+	}
+
+	if len(group.Vars) > 0 {
+		result["vars"] = group.Vars
+	}
+
+	children := make(map[string]interface{}, len(group.Children))
+	for _, child := range group.Children {
+		if _, ok := groups[child]; ok {
+			children[child] = ansibleYAMLGroup(child, groups)
+		}
+	}
+	if len(children) > 0 {
+		result["children"] = children
+	}
+
+	return result
+}
+
+// marshalWire returns the protobuf (format=pb) or protobuf JSON (format=pbjson) encoding of v as an inventory snapshot (see inventory.proto).
+func marshalWire(v interface{}, format string, compare func(a, b string) int) ([]byte, error) {
+	w, err := inventory.NewWireInventory(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if compare != nil {
+		w.SortNames(compare)
+	}
+
+	if format == "pb" {
+		return w.MarshalProto(), nil
+	}
+
+	return protojson.Marshal(w)
+}
+
+// marshalTerraform returns the JSON encoding of v as a flat map of strings, as required by Terraform's 'external' data source.
+// Lists are encoded as comma-separated strings, and attribute sets are flattened into '<host>.<index>.<key>' keys.
+func marshalTerraform(v interface{}, cfg *inventory.Config, compare func(a, b string) int) ([]byte, error) {
+	flat := make(map[string]string)
+
+	switch v := v.(type) {
+	case map[string]string:
+		flat = v
+	case map[string][]string:
+		for key, value := range v {
+			flat[key] = strings.Join(value, ",")
+		}
+	case map[string][]*inventory.HostAttributes:
+		for host, value := range v {
+			for i, attrs := range value {
+				prefix := host + "." + strconv.Itoa(i) + "."
+
+				flat[prefix+cfg.Txt.Keys.Os] = attrs.OS
+				flat[prefix+cfg.Txt.Keys.Env] = attrs.Env
+				flat[prefix+cfg.Txt.Keys.Role] = attrs.Role
+				flat[prefix+cfg.Txt.Keys.Srv] = attrs.Srv
+				flat[prefix+cfg.Txt.Keys.Vars] = attrs.Vars
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format: terraform")
+	}
+
+	// Map keys are sorted by json.Marshal unless a collation is configured, which keeps the output stable.
+	return json.Marshal(collate(flat, compare))
+}
+
+// sortedKeys returns the keys of a map ordered by compare.
+func sortedKeys[V any](m map[string]V, compare func(a, b string) int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, compare)
+
+	return keys
+}
+
 // Apply a function to all elements in a slice of strings.
 func mapStr(values []string, f func(string) string) []string {
 	result := make([]string, len(values))
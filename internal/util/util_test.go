@@ -0,0 +1,138 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/NeonSludge/ansible-dns-inventory/pkg/inventory"
+)
+
+func TestMarshal_terraform(t *testing.T) {
+	cfg := &inventory.Config{}
+	cfg.Txt.Keys.Os = "OS"
+	cfg.Txt.Keys.Env = "ENV"
+	cfg.Txt.Keys.Role = "ROLE"
+	cfg.Txt.Keys.Srv = "SRV"
+	cfg.Txt.Keys.Vars = "VARS"
+
+	tests := []struct {
+		name    string
+		v       interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "valid-strings",
+			v:    map[string]string{"b": "2", "a": "1"},
+			want: `{"a":"1","b":"2"}`,
+		},
+		{
+			// Lists are joined with commas.
+			name: "valid-lists",
+			v:    map[string][]string{"app": {"app01.infra.local", "app02.infra.local"}, "db": {}},
+			want: `{"app":"app01.infra.local,app02.infra.local","db":""}`,
+		},
+		{
+			// Attribute sets are flattened into '<host>.<index>.<key>' keys.
+			name: "valid-attributes",
+			v: map[string][]*inventory.HostAttributes{
+				"app01.infra.local": {
+					{OS: "linux", Env: "dev", Role: "app", Srv: "tomcat", Vars: "heap=2g"},
+					{OS: "linux", Env: "dev", Role: "app", Srv: "nginx"},
+				},
+			},
+			want: `{"app01.infra.local.0.ENV":"dev","app01.infra.local.0.OS":"linux","app01.infra.local.0.ROLE":"app","app01.infra.local.0.SRV":"tomcat","app01.infra.local.0.VARS":"heap=2g",` +
+				`"app01.infra.local.1.ENV":"dev","app01.infra.local.1.OS":"linux","app01.infra.local.1.ROLE":"app","app01.infra.local.1.SRV":"nginx","app01.infra.local.1.VARS":""}`,
+		},
+		{
+			name: "valid-empty",
+			v:    map[string][]string{},
+			want: `{}`,
+		},
+		{
+			// Terraform's 'external' data source only accepts a flat map of strings.
+			name:    "invalid-type",
+			v:       map[string]interface{}{"app": map[string]string{"a": "1"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.v, "terraform", cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Marshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshal_ansibleYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			// Groups are nested under their parents, hosts are keys with empty values.
+			name: "valid",
+			v: map[string]*inventory.AnsibleGroup{
+				"all":       {Children: []string{"dev", "ungrouped"}, Vars: map[string]interface{}{"ntp": "ntp.infra.local"}},
+				"dev":       {Children: []string{"dev_app"}},
+				"dev_app":   {Hosts: []string{"app02.infra.local", "app01.infra.local"}},
+				"ungrouped": {Hosts: []string{"db01.infra.local"}},
+				"unrelated": {Hosts: []string{"web01.infra.local"}},
+			},
+			want: `all:
+    children:
+        dev:
+            children:
+                dev_app:
+                    hosts:
+                        app01.infra.local: {}
+                        app02.infra.local: {}
+        ungrouped:
+            hosts:
+                db01.infra.local: {}
+    vars:
+        ntp: ntp.infra.local
+`,
+		},
+		{
+			// Children missing from the inventory are left out.
+			name: "valid-missing-child",
+			v: map[string]*inventory.AnsibleGroup{
+				"all": {Children: []string{"dev"}, Hosts: []string{"app01.infra.local"}},
+			},
+			want: `all:
+    hosts:
+        app01.infra.local: {}
+`,
+		},
+		{
+			name:    "invalid-no-root",
+			v:       map[string]*inventory.AnsibleGroup{"dev": {Hosts: []string{"app01.infra.local"}}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid-type",
+			v:       map[string][]string{"all": {"app01.infra.local"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.v, "ansible-yaml", &inventory.Config{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Marshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package zstd
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// Maximum length of a literal prefix code.
+const huffmanMaxBits int = 11
+
+// bitWriter writes a backward bitstream, which decoders read from the last bit written to the first.
+type bitWriter struct {
+	out   []byte
+	bits  uint64
+	nbits uint
+}
+
+// addBits appends the n low bits of v.
+func (b *bitWriter) addBits(v uint32, n uint) {
+	b.bits |= uint64(v&(1<<n-1)) << b.nbits
+	b.nbits += n
+
+	for b.nbits >= 8 {
+		b.out = append(b.out, byte(b.bits))
+		b.bits >>= 8
+		b.nbits -= 8
+	}
+}
+
+// close terminates the bitstream with the end mark and returns it.
+func (b *bitWriter) close() []byte {
+	b.addBits(1, 1)
+	if b.nbits > 0 {
+		b.out = append(b.out, byte(b.bits))
+	}
+
+	return b.out
+}
+
+// fseSymbol holds the state transformation of a symbol in an FSE encoding table.
+type fseSymbol struct {
+	deltaNbBits    uint32
+	deltaFindState int32
+}
+
+// fseTable is a finite state entropy encoding table.
+type fseTable struct {
+	log     uint
+	states  []uint16
+	symbols []fseSymbol
+}
+
+// newFSETable builds the encoding table of a normalized symbol distribution, spreading the symbols like decoders do.
+func newFSETable(norm []int16, log uint) *fseTable {
+	size := 1 << log
+	mask := size - 1
+	step := size>>1 + size>>3 + 3
+	high := size - 1
+
+	// Symbols with a "less than 1" probability take the last states.
+	spread := make([]int, size)
+	cumul := make([]int, len(norm)+1)
+	for s, n := range norm {
+		if n == -1 {
+			cumul[s+1] = cumul[s] + 1
+			spread[high] = s
+			high--
+		} else {
+			cumul[s+1] = cumul[s] + int(n)
+		}
+	}
+
+	pos := 0
+	for s, n := range norm {
+		for i := 0; i < int(n); i++ {
+			spread[pos] = s
+			pos = (pos + step) & mask
+			for pos > high {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+
+	t := &fseTable{log: log, states: make([]uint16, size), symbols: make([]fseSymbol, len(norm))}
+	for u := 0; u < size; u++ {
+		s := spread[u]
+		t.states[cumul[s]] = uint16(size + u)
+		cumul[s]++
+	}
+
+	total := int32(0)
+	for s, n := range norm {
+		switch n {
+		case 0:
+			t.symbols[s].deltaNbBits = uint32(log+1)<<16 - uint32(size)
+		case -1, 1:
+			t.symbols[s].deltaNbBits = uint32(log)<<16 - uint32(size)
+			t.symbols[s].deltaFindState = total - 1
+			total++
+		default:
+			maxBitsOut := uint32(log) - uint32(bits.Len16(uint16(n-1))-1)
+			t.symbols[s].deltaNbBits = maxBitsOut<<16 - uint32(n)<<maxBitsOut
+			t.symbols[s].deltaFindState = total - int32(n)
+			total += int32(n)
+		}
+	}
+
+	return t
+}
+
+// fseState is the state of an FSE encoder.
+type fseState struct {
+	table *fseTable
+	value uint32
+}
+
+// init sets the initial state to the one encoding symbol without writing any bits.
+func (s *fseState) init(table *fseTable, symbol uint8) {
+	tt := table.symbols[symbol]
+	nbBitsOut := (tt.deltaNbBits + 1<<15) >> 16
+	value := nbBitsOut<<16 - tt.deltaNbBits
+
+	s.table = table
+	s.value = uint32(table.states[int32(value>>nbBitsOut)+tt.deltaFindState])
+}
+
+// encode writes the state bits leading a decoder from symbol to the current state.
+func (s *fseState) encode(b *bitWriter, symbol uint8) {
+	tt := s.table.symbols[symbol]
+	nbBitsOut := (s.value + tt.deltaNbBits) >> 16
+
+	b.addBits(s.value, uint(nbBitsOut))
+	s.value = uint32(s.table.states[int32(s.value>>nbBitsOut)+tt.deltaFindState])
+}
+
+// flush writes the final state, which is the initial state of a decoder.
+func (s *fseState) flush(b *bitWriter) {
+	b.addBits(s.value, s.table.log)
+}
+
+// huffmanTable holds the prefix codes of literal bytes.
+type huffmanTable struct {
+	codes   [256]uint16
+	lengths [256]uint8
+	// Tree description, with the weights of the symbols encoded directly.
+	description []byte
+}
+
+// newHuffmanTable builds the prefix codes of literals with the given byte counts.
+// Direct weight encoding only describes symbols up to 128, false is returned if there are greater ones or less than two symbols.
+func newHuffmanTable(counts *[256]int) (*huffmanTable, bool) {
+	maxSymbol, symbols := 0, 0
+	for s, c := range counts {
+		if c > 0 {
+			maxSymbol = s
+			symbols++
+		}
+	}
+	if symbols < 2 || maxSymbol > 128 {
+		return nil, false
+	}
+
+	h := &huffmanTable{}
+	limited := *counts
+	for !huffmanLengths(limited[:maxSymbol+1], h.lengths[:maxSymbol+1]) {
+		// Flatter distributions produce shorter codes.
+		for s, c := range limited {
+			if c > 0 {
+				limited[s] = (c + 1) / 2
+			}
+		}
+	}
+
+	maxBits := uint8(0)
+	for _, l := range h.lengths {
+		maxBits = max(maxBits, l)
+	}
+
+	// Canonical codes are assigned by increasing weight, then by symbol.
+	code := uint16(0)
+	for l := maxBits; l > 0; l-- {
+		for s := 0; s <= maxSymbol; s++ {
+			if h.lengths[s] == l {
+				h.codes[s] = code
+				code++
+			}
+		}
+		code >>= 1
+	}
+
+	// The weight of the last symbol is implied.
+	weights := make([]byte, maxSymbol+1)
+	for s := 0; s < maxSymbol; s++ {
+		if h.lengths[s] > 0 {
+			weights[s] = maxBits + 1 - h.lengths[s]
+		}
+	}
+
+	h.description = append(h.description, byte(127+maxSymbol))
+	for s := 0; s < maxSymbol; s += 2 {
+		h.description = append(h.description, weights[s]<<4|weights[s+1])
+	}
+
+	return h, true
+}
+
+// huffmanLengths computes the prefix code lengths of symbols with the given counts.
+// It returns false if a code would be longer than the maximum length.
+func huffmanLengths(counts []int, lengths []uint8) bool {
+	leaves := make([]int, 0, len(counts))
+	for s, c := range counts {
+		if c > 0 {
+			leaves = append(leaves, s)
+		}
+	}
+	sort.SliceStable(leaves, func(i, j int) bool {
+		return counts[leaves[i]] < counts[leaves[j]]
+	})
+
+	// Internal nodes are created in order of weight, so the two lightest nodes are at the head of either queue.
+	n := len(leaves)
+	weight := make([]int, 2*n-1)
+	parent := make([]int, 2*n-1)
+	for i, s := range leaves {
+		weight[i] = counts[s]
+	}
+
+	leaf, internal := 0, n
+	for k := n; k < len(weight); k++ {
+		for i := 0; i < 2; i++ {
+			var next int
+			if leaf < n && (internal >= k || weight[leaf] <= weight[internal]) {
+				next = leaf
+				leaf++
+			} else {
+				next = internal
+				internal++
+			}
+
+			weight[k] += weight[next]
+			parent[next] = k
+		}
+	}
+
+	depth := make([]int, 2*n-1)
+	for i := len(depth) - 2; i >= 0; i-- {
+		depth[i] = depth[parent[i]] + 1
+	}
+
+	for i, s := range leaves {
+		if depth[i] > huffmanMaxBits {
+			return false
+		}
+		lengths[s] = uint8(depth[i])
+	}
+
+	return true
+}
+
+// encode writes literals to a bitstream in reverse order, as they are decoded from its end.
+func (h *huffmanTable) encode(lits []byte) []byte {
+	var b bitWriter
+	for i := len(lits) - 1; i >= 0; i-- {
+		b.addBits(uint32(h.codes[lits[i]]), uint(h.lengths[lits[i]]))
+	}
+
+	return b.close()
+}
+
+// code returns the code of a value from the baselines of the codes.
+func code(baselines []uint32, v uint32) uint8 {
+	return uint8(sort.Search(len(baselines), func(i int) bool { return baselines[i] > v }) - 1)
+}
@@ -0,0 +1,334 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"io"
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Fastest compression level.
+	BestSpeed int = 1
+	// Best compression level.
+	BestCompression int = 9
+
+	// Maximum size of a block, which is also the window size.
+	blockMaxSize int = 128 << 10
+	// Minimum length of a match.
+	minMatch int = 4
+	// Size of the match finder hash table, in bits.
+	hashLog uint = 15
+	// Minimum number of literals compressed with prefix codes.
+	huffmanMinLiterals int = 64
+)
+
+// Frame header: 128 KiB window, no content size, checksum or dictionary.
+var frameHeader = []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00, 0x38}
+
+// Predefined distributions of the literal length, match length and offset codes.
+var (
+	llNorm = []int16{4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1, -1, -1, -1, -1}
+	mlNorm = []int16{1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1, -1, -1}
+	ofNorm = []int16{1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1}
+
+	llTable = newFSETable(llNorm, 6)
+	mlTable = newFSETable(mlNorm, 6)
+	ofTable = newFSETable(ofNorm, 5)
+)
+
+// Baselines and extra bits of the literal length and match length codes.
+var (
+	llBase = []uint32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096, 8192, 16384, 32768, 65536}
+	llBits = []uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	mlBase = []uint32{3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34, 35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051, 4099, 8195, 16387, 32771, 65539}
+	mlBits = []uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+)
+
+// Block types.
+const (
+	blockRaw        uint32 = 0
+	blockCompressed uint32 = 2
+)
+
+// sequence is a run of literals followed by a match.
+type sequence struct {
+	litLen   uint32
+	matchLen uint32
+	offset   uint32
+}
+
+// Writer is a Zstandard (RFC 8878) compressor. It uses greedy LZ77 matching, prefix coded literals and the predefined sequence code distributions.
+type Writer struct {
+	w io.Writer
+	// Number of match candidates tried at each position.
+	chain  int
+	buf    []byte
+	header bool
+	closed bool
+	err    error
+
+	head [1 << hashLog]int32
+	prev []int32
+}
+
+// NewWriterLevel returns a Writer compressing data written to it into a single frame written to w.
+func NewWriterLevel(w io.Writer, level int) (*Writer, error) {
+	if level < BestSpeed || level > BestCompression {
+		return nil, errors.Errorf("invalid compression level: %d", level)
+	}
+
+	return &Writer{w: w, chain: 1 << (level - 1)}, nil
+}
+
+// Write compresses p, writing out every complete block.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.closed {
+		return 0, errors.New("write to a closed zstd writer")
+	}
+	if z.err != nil {
+		return 0, z.err
+	}
+
+	z.buf = append(z.buf, p...)
+
+	// The last block is held back until the writer is closed, as its header marks the end of the frame.
+	for len(z.buf) > blockMaxSize {
+		if z.err = z.writeBlock(z.buf[:blockMaxSize], false); z.err != nil {
+			return 0, z.err
+		}
+		z.buf = append(z.buf[:0], z.buf[blockMaxSize:]...)
+	}
+
+	return len(p), nil
+}
+
+// Close writes the last block, completing the frame. It does not close the underlying writer.
+func (z *Writer) Close() error {
+	if z.closed {
+		return z.err
+	}
+	z.closed = true
+
+	if z.err == nil {
+		z.err = z.writeBlock(z.buf, true)
+	}
+	z.buf = nil
+
+	return z.err
+}
+
+// writeBlock writes a block of data, compressed if that makes it smaller.
+func (z *Writer) writeBlock(src []byte, last bool) error {
+	if !z.header {
+		if _, err := z.w.Write(frameHeader); err != nil {
+			return err
+		}
+		z.header = true
+	}
+
+	kind, data := blockRaw, src
+	if compressed := z.compressBlock(src); compressed != nil && len(compressed) < len(src) {
+		kind, data = blockCompressed, compressed
+	}
+
+	header := uint32(len(data))<<3 | kind<<1
+	if last {
+		header |= 1
+	}
+
+	if _, err := z.w.Write([]byte{byte(header), byte(header >> 8), byte(header >> 16)}); err != nil {
+		return err
+	}
+	_, err := z.w.Write(data)
+
+	return err
+}
+
+// compressBlock returns the content of a compressed block holding src, or nil if there is nothing to compress.
+func (z *Writer) compressBlock(src []byte) []byte {
+	if len(src) < minMatch {
+		return nil
+	}
+
+	lits, seqs := z.match(src)
+
+	dst := encodeLiterals(make([]byte, 0, len(src)), lits)
+	return encodeSequences(dst, seqs)
+}
+
+// match splits src into sequences of literals and matches, returning the literals and the trailing ones separately.
+func (z *Writer) match(src []byte) ([]byte, []sequence) {
+	// Matches do not cross blocks. Positions are stored incremented, so that zero means none.
+	clear(z.head[:])
+	if cap(z.prev) < len(src) {
+		z.prev = make([]int32, len(src))
+	}
+	prev := z.prev[:len(src)]
+
+	insert := func(i int) int32 {
+		h := (binary.LittleEndian.Uint32(src[i:]) * 2654435761) >> (32 - hashLog)
+		candidate := z.head[h]
+		prev[i] = candidate
+		z.head[h] = int32(i + 1)
+
+		return candidate
+	}
+
+	lits := make([]byte, 0, len(src))
+	seqs := make([]sequence, 0)
+
+	anchor := 0
+	for i := 0; i+minMatch <= len(src); {
+		best, offset := 0, 0
+		for c, n := insert(i), 0; c > 0 && n < z.chain; c, n = prev[c-1], n+1 {
+			candidate := int(c - 1)
+
+			l := 0
+			for i+l < len(src) && src[candidate+l] == src[i+l] {
+				l++
+			}
+			if l > best {
+				best, offset = l, i-candidate
+			}
+		}
+
+		if best < minMatch {
+			i++
+			continue
+		}
+
+		for j := i + 1; j < i+best && j+minMatch <= len(src); j++ {
+			insert(j)
+		}
+
+		lits = append(lits, src[anchor:i]...)
+		seqs = append(seqs, sequence{litLen: uint32(i - anchor), matchLen: uint32(best), offset: uint32(offset)})
+
+		i += best
+		anchor = i
+	}
+
+	return append(lits, src[anchor:]...), seqs
+}
+
+// encodeLiterals appends the literals section of a block, with prefix coded literals if that makes it smaller.
+func encodeLiterals(dst []byte, lits []byte) []byte {
+	n := len(lits)
+
+	if n >= huffmanMinLiterals {
+		var counts [256]int
+		for _, b := range lits {
+			counts[b]++
+		}
+
+		if h, ok := newHuffmanTable(&counts); ok {
+			data := append([]byte(nil), h.description...)
+
+			if n <= 1023 {
+				data = append(data, h.encode(lits)...)
+			} else {
+				// Larger literal sections are split into four streams, with a jump table holding the sizes of the first three.
+				segment := (n + 3) / 4
+				streams := [4][]byte{h.encode(lits[:segment]), h.encode(lits[segment : 2*segment]), h.encode(lits[2*segment : 3*segment]), h.encode(lits[3*segment:])}
+
+				for _, s := range streams[:3] {
+					data = binary.LittleEndian.AppendUint16(data, uint16(len(s)))
+				}
+				for _, s := range streams {
+					data = append(data, s...)
+				}
+			}
+
+			size := uint64(len(data))
+			switch {
+			case size >= uint64(n):
+			case n <= 1023:
+				header := 2 | uint64(n)<<4 | size<<14
+				dst = append(dst, byte(header), byte(header>>8), byte(header>>16))
+				return append(dst, data...)
+			case n < 1<<14 && size < 1<<14:
+				header := 2 | 2<<2 | uint64(n)<<4 | size<<18
+				dst = binary.LittleEndian.AppendUint32(dst, uint32(header))
+				return append(dst, data...)
+			default:
+				header := 2 | 3<<2 | uint64(n)<<4 | size<<22
+				dst = append(dst, byte(header), byte(header>>8), byte(header>>16), byte(header>>24), byte(header>>32))
+				return append(dst, data...)
+			}
+		}
+	}
+
+	switch {
+	case n < 1<<5:
+		dst = append(dst, byte(n<<3))
+	case n < 1<<12:
+		header := 1<<2 | n<<4
+		dst = append(dst, byte(header), byte(header>>8))
+	default:
+		header := 3<<2 | n<<4
+		dst = append(dst, byte(header), byte(header>>8), byte(header>>16))
+	}
+
+	return append(dst, lits...)
+}
+
+// encodeSequences appends the sequences section of a block, coded with the predefined distributions.
+func encodeSequences(dst []byte, seqs []sequence) []byte {
+	n := len(seqs)
+
+	switch {
+	case n < 0x80:
+		dst = append(dst, byte(n))
+	case n < 0x7f00:
+		dst = append(dst, byte(n>>8+0x80), byte(n))
+	default:
+		dst = append(dst, 0xff, byte(n-0x7f00), byte((n-0x7f00)>>8))
+	}
+	if n == 0 {
+		return dst
+	}
+
+	// Predefined mode for all three codes.
+	dst = append(dst, 0)
+
+	llCodes := make([]uint8, n)
+	mlCodes := make([]uint8, n)
+	ofCodes := make([]uint8, n)
+	for i, s := range seqs {
+		llCodes[i] = code(llBase, s.litLen)
+		mlCodes[i] = code(mlBase, s.matchLen)
+		// Offset values up to 3 refer to repeated offsets.
+		ofCodes[i] = uint8(bits.Len32(s.offset+3) - 1)
+	}
+
+	// Sequences are written last to first, as they are decoded from the end of the bitstream.
+	var b bitWriter
+	var ll, ml, of fseState
+
+	extra := func(i int) {
+		s := seqs[i]
+		b.addBits(s.litLen-llBase[llCodes[i]], uint(llBits[llCodes[i]]))
+		b.addBits(s.matchLen-mlBase[mlCodes[i]], uint(mlBits[mlCodes[i]]))
+		b.addBits(s.offset+3, uint(ofCodes[i]))
+	}
+
+	ml.init(mlTable, mlCodes[n-1])
+	of.init(ofTable, ofCodes[n-1])
+	ll.init(llTable, llCodes[n-1])
+	extra(n - 1)
+
+	for i := n - 2; i >= 0; i-- {
+		of.encode(&b, ofCodes[i])
+		ml.encode(&b, mlCodes[i])
+		ll.encode(&b, llCodes[i])
+		extra(i)
+	}
+
+	ml.flush(&b)
+	of.flush(&b)
+	ll.flush(&b)
+
+	return append(dst, b.close()...)
+}
@@ -0,0 +1,531 @@
+package zstd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// bitReader reads a backward bitstream from its end.
+type bitReader struct {
+	data []byte
+	// Number of unread bits.
+	pos int
+}
+
+func newBitReader(data []byte) (*bitReader, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return nil, errors.New("bitstream has no end mark")
+	}
+
+	return &bitReader{data: data, pos: (len(data)-1)*8 + bits.Len8(data[len(data)-1]) - 1}, nil
+}
+
+func (r *bitReader) read(n uint8) (uint32, error) {
+	v := uint32(0)
+	for i := uint8(0); i < n; i++ {
+		if r.pos == 0 {
+			return 0, errors.New("bitstream overflow")
+		}
+		r.pos--
+		v = v<<1 | uint32(r.data[r.pos/8]>>(r.pos%8)&1)
+	}
+
+	return v, nil
+}
+
+// fseDecoder is the decoding table of a normalized symbol distribution, built as described in RFC 8878.
+type fseDecoder struct {
+	log     uint8
+	symbols []uint8
+	nbBits  []uint8
+	base    []uint32
+}
+
+func newFSEDecoder(norm []int16, log uint8) *fseDecoder {
+	size := 1 << log
+	d := &fseDecoder{log: log, symbols: make([]uint8, size), nbBits: make([]uint8, size), base: make([]uint32, size)}
+
+	next := make([]int, len(norm))
+	high := size - 1
+	for s, n := range norm {
+		if n == -1 {
+			d.symbols[high] = uint8(s)
+			high--
+			next[s] = 1
+		} else {
+			next[s] = int(n)
+		}
+	}
+
+	pos := 0
+	for s, n := range norm {
+		for i := 0; i < int(n); i++ {
+			d.symbols[pos] = uint8(s)
+			pos = (pos + size>>1 + size>>3 + 3) & (size - 1)
+			for pos > high {
+				pos = (pos + size>>1 + size>>3 + 3) & (size - 1)
+			}
+		}
+	}
+
+	for u := 0; u < size; u++ {
+		state := next[d.symbols[u]]
+		next[d.symbols[u]]++
+		d.nbBits[u] = log - uint8(bits.Len(uint(state))-1)
+		d.base[u] = uint32(state<<d.nbBits[u] - size)
+	}
+
+	return d
+}
+
+// decompress decodes the subset of Zstandard frames produced by Writer, with raw and compressed blocks,
+// directly described prefix codes and the predefined sequence code distributions.
+func decompress(src []byte) ([]byte, error) {
+	if !bytes.HasPrefix(src, []byte{0x28, 0xb5, 0x2f, 0xfd}) || len(src) < 6 {
+		return nil, errors.New("no frame header")
+	}
+	if src[4] != 0 {
+		return nil, errors.Errorf("unexpected frame header descriptor: 0x%02x", src[4])
+	}
+	src = src[6:]
+
+	out := make([]byte, 0)
+	for {
+		if len(src) < 3 {
+			return nil, errors.New("truncated block header")
+		}
+		header := uint32(src[0]) | uint32(src[1])<<8 | uint32(src[2])<<16
+		last, kind, size := header&1 == 1, header>>1&3, int(header>>3)
+		src = src[3:]
+
+		if size > len(src) || size > blockMaxSize {
+			return nil, errors.Errorf("invalid block size: %d", size)
+		}
+
+		switch kind {
+		case blockRaw:
+			out = append(out, src[:size]...)
+		case blockCompressed:
+			block, err := decompressBlock(src[:size])
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, block...)
+		default:
+			return nil, errors.Errorf("unexpected block type: %d", kind)
+		}
+		src = src[size:]
+
+		if last {
+			if len(src) > 0 {
+				return nil, errors.New("trailing data after the last block")
+			}
+			return out, nil
+		}
+	}
+}
+
+func decompressBlock(src []byte) ([]byte, error) {
+	lits, src, err := decodeLiterals(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(src) == 0 {
+		return nil, errors.New("no sequences section")
+	}
+	n := int(src[0])
+	switch {
+	case n == 0:
+		if len(src) > 1 {
+			return nil, errors.New("trailing data after the sequences section")
+		}
+		return lits, nil
+	case n < 0x80:
+		src = src[1:]
+	case n < 0xff:
+		n = (n-0x80)<<8 + int(src[1])
+		src = src[2:]
+	default:
+		n = int(src[1]) + int(src[2])<<8 + 0x7f00
+		src = src[3:]
+	}
+	if src[0] != 0 {
+		return nil, errors.Errorf("unexpected symbol compression modes: 0x%02x", src[0])
+	}
+
+	r, err := newBitReader(src[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	ll, of, ml := newFSEDecoder(llNorm, 6), newFSEDecoder(ofNorm, 5), newFSEDecoder(mlNorm, 6)
+	var llState, ofState, mlState uint32
+	for _, s := range []struct {
+		state *uint32
+		log   uint8
+	}{{&llState, ll.log}, {&ofState, of.log}, {&mlState, ml.log}} {
+		if *s.state, err = r.read(s.log); err != nil {
+			return nil, err
+		}
+	}
+
+	out := make([]byte, 0, len(lits))
+	for i := 0; i < n; i++ {
+		llCode, ofCode, mlCode := ll.symbols[llState], of.symbols[ofState], ml.symbols[mlState]
+
+		offset, err := r.read(ofCode)
+		if err != nil {
+			return nil, err
+		}
+		offset += 1 << ofCode
+		if offset <= 3 {
+			return nil, errors.New("repeated offsets are not supported")
+		}
+		offset -= 3
+
+		matchLen, err := r.read(mlBits[mlCode])
+		if err != nil {
+			return nil, err
+		}
+		matchLen += mlBase[mlCode]
+
+		litLen, err := r.read(llBits[llCode])
+		if err != nil {
+			return nil, err
+		}
+		litLen += llBase[llCode]
+
+		if i < n-1 {
+			for _, s := range []struct {
+				state *uint32
+				d     *fseDecoder
+			}{{&llState, ll}, {&mlState, ml}, {&ofState, of}} {
+				v, err := r.read(s.d.nbBits[*s.state])
+				if err != nil {
+					return nil, err
+				}
+				*s.state = s.d.base[*s.state] + v
+			}
+		}
+
+		if int(litLen) > len(lits) || int(offset) > len(out)+int(litLen) {
+			return nil, errors.Errorf("invalid sequence: literals %d, offset %d", litLen, offset)
+		}
+		out = append(out, lits[:litLen]...)
+		lits = lits[litLen:]
+		for j := uint32(0); j < matchLen; j++ {
+			out = append(out, out[len(out)-int(offset)])
+		}
+	}
+	if r.pos != 0 {
+		return nil, errors.Errorf("%d unread sequence bits", r.pos)
+	}
+
+	return append(out, lits...), nil
+}
+
+func decodeLiterals(src []byte) ([]byte, []byte, error) {
+	if len(src) == 0 {
+		return nil, nil, errors.New("no literals section")
+	}
+
+	kind, format := src[0]&3, src[0]>>2&3
+	switch kind {
+	case 0:
+		var n, size int
+		switch format {
+		case 0, 2:
+			n, size = int(src[0]>>3), 1
+		case 1:
+			n, size = int(binary.LittleEndian.Uint16(src)>>4), 2
+		default:
+			n, size = int(uint32(src[0])|uint32(src[1])<<8|uint32(src[2])<<16)>>4, 3
+		}
+		if size+n > len(src) {
+			return nil, nil, errors.New("truncated literals")
+		}
+		return src[size : size+n], src[size+n:], nil
+	case 2:
+	default:
+		return nil, nil, errors.Errorf("unexpected literals block type: %d", kind)
+	}
+
+	var header uint64
+	var size, n, csize int
+	switch format {
+	case 0, 1:
+		size = 3
+		header = uint64(src[0]) | uint64(src[1])<<8 | uint64(src[2])<<16
+		n, csize = int(header>>4&0x3ff), int(header>>14&0x3ff)
+	case 2:
+		size = 4
+		header = uint64(binary.LittleEndian.Uint32(src))
+		n, csize = int(header>>4&0x3fff), int(header>>18&0x3fff)
+	default:
+		size = 5
+		header = uint64(binary.LittleEndian.Uint32(src)) | uint64(src[4])<<32
+		n, csize = int(header>>4&0x3ffff), int(header>>22&0x3ffff)
+	}
+	if size+csize > len(src) {
+		return nil, nil, errors.New("truncated literals")
+	}
+	data, rest := src[size:size+csize], src[size+csize:]
+
+	// Prefix codes are built from the directly encoded weights, the weight of the last symbol completing the tree.
+	if data[0] < 128 {
+		return nil, nil, errors.New("compressed weights are not supported")
+	}
+	symbols := int(data[0]) - 127
+	weights := make([]int, symbols+1)
+	for i := 0; i < symbols; i++ {
+		weights[i] = int(data[1+i/2] >> (4 * (1 - i%2)) & 15)
+	}
+	data = data[1+(symbols+1)/2:]
+
+	total := 0
+	for _, w := range weights {
+		if w > 0 {
+			total += 1 << (w - 1)
+		}
+	}
+	maxBits := bits.Len(uint(total))
+	rest2 := 1<<maxBits - total
+	if rest2&(rest2-1) != 0 {
+		return nil, nil, errors.New("incomplete prefix code")
+	}
+	weights[symbols] = bits.Len(uint(rest2))
+
+	codes := make(map[[2]int]byte)
+	code := 0
+	for w := 1; w <= maxBits; w++ {
+		for s, sw := range weights {
+			if sw == w {
+				codes[[2]int{maxBits + 1 - w, code}] = byte(s)
+				code++
+			}
+		}
+		code >>= 1
+	}
+
+	decode := func(stream []byte, count int) ([]byte, error) {
+		r, err := newBitReader(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		out := make([]byte, 0, count)
+		for len(out) < count {
+			c, l := 0, 0
+			for {
+				b, err := r.read(1)
+				if err != nil {
+					return nil, err
+				}
+				c, l = c<<1|int(b), l+1
+				if s, ok := codes[[2]int{l, c}]; ok {
+					out = append(out, s)
+					break
+				}
+			}
+		}
+		if r.pos != 0 {
+			return nil, errors.Errorf("%d unread literal bits", r.pos)
+		}
+
+		return out, nil
+	}
+
+	if format == 0 {
+		lits, err := decode(data, n)
+		return lits, rest, err
+	}
+
+	segment := (n + 3) / 4
+	sizes := []int{int(binary.LittleEndian.Uint16(data)), int(binary.LittleEndian.Uint16(data[2:])), int(binary.LittleEndian.Uint16(data[4:]))}
+	sizes = append(sizes, len(data)-6-sizes[0]-sizes[1]-sizes[2])
+	data = data[6:]
+
+	lits := make([]byte, 0, n)
+	for i, s := range sizes {
+		count := segment
+		if i == 3 {
+			count = n - 3*segment
+		}
+
+		l, err := decode(data[:s], count)
+		if err != nil {
+			return nil, nil, err
+		}
+		lits = append(lits, l...)
+		data = data[s:]
+	}
+
+	return lits, rest, nil
+}
+
+// testInputs returns data with various degrees of redundancy.
+func testInputs() map[string][]byte {
+	rng := rand.New(rand.NewSource(1))
+
+	random := make([]byte, 200<<10)
+	rng.Read(random)
+
+	var inventory strings.Builder
+	for i := 0; rng.Intn(100) < 99 || i < 3000; i++ {
+		fmt.Fprintf(&inventory, "app%04d.infra.local:\n  ansible_host: 10.%d.%d.%d\n  dns_inventory_os: linux\n  dns_inventory_env: prod\n  dns_inventory_role: app\n  dns_inventory_srv: tomcat_%d\n", i, rng.Intn(256), rng.Intn(256), rng.Intn(256), rng.Intn(8))
+	}
+
+	binary := make([]byte, 4096)
+	for i := range binary {
+		binary[i] = byte(128 + rng.Intn(4))
+	}
+
+	return map[string][]byte{
+		"empty":     {},
+		"short":     []byte("abc"),
+		"text":      []byte(strings.Repeat("all:\n  children:\n  - app\n  - db\n", 20)),
+		"runs":      bytes.Repeat([]byte{'x'}, 300<<10),
+		"random":    random,
+		"inventory": []byte(inventory.String()),
+		"high":      binary,
+		"literals":  []byte("The quick brown fox jumps over the lazy dog, then sleeps under the old oak tree until the sun sets."),
+	}
+}
+
+func compress(t *testing.T, data []byte, level int, chunk int) []byte {
+	var buf bytes.Buffer
+	z, err := NewWriterLevel(&buf, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for p := data; len(p) > 0; {
+		n := min(chunk, len(p))
+		if _, err := z.Write(p[:n]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		p = p[n:]
+	}
+	if err := z.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestWriter_roundTrip(t *testing.T) {
+	for name, data := range testInputs() {
+		for _, level := range []int{BestSpeed, 6, BestCompression} {
+			for _, chunk := range []int{1000, 1 << 20} {
+				t.Run(fmt.Sprintf("valid-%s-level%d-chunk%d", name, level, chunk), func(t *testing.T) {
+					got := compress(t, data, level, chunk)
+
+					decoded, err := decompress(got)
+					if err != nil {
+						t.Fatalf("decompress() error = %v", err)
+					}
+					if !bytes.Equal(decoded, data) {
+						t.Fatalf("decompress() = %d bytes, want %d bytes", len(decoded), len(data))
+					}
+
+					// Incompressible blocks are stored as is.
+					blocks := max(1, (len(data)+blockMaxSize-1)/blockMaxSize)
+					if limit := len(frameHeader) + 3*blocks + len(data); len(got) > limit {
+						t.Errorf("compressed size = %d, want at most %d", len(got), limit)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestWriter_ratio(t *testing.T) {
+	data := testInputs()["inventory"]
+
+	fast := compress(t, data, BestSpeed, len(data))
+	best := compress(t, data, BestCompression, len(data))
+
+	if len(fast)*4 > len(data) {
+		t.Errorf("compressed size at level %d = %d, want at most a quarter of %d", BestSpeed, len(fast), len(data))
+	}
+	if len(best) > len(fast) {
+		t.Errorf("compressed size at level %d = %d, want at most %d", BestCompression, len(best), len(fast))
+	}
+}
+
+func TestWriter_reference(t *testing.T) {
+	path, err := exec.LookPath("zstd")
+	if err != nil {
+		t.Skip("zstd is not installed")
+	}
+
+	for name, data := range testInputs() {
+		t.Run("valid-"+name, func(t *testing.T) {
+			var out, stderr bytes.Buffer
+			cmd := exec.Command(path, "-d", "-c", "-q")
+			cmd.Stdin = bytes.NewReader(compress(t, data, 6, len(data)))
+			cmd.Stdout = &out
+			cmd.Stderr = &stderr
+
+			if err := cmd.Run(); err != nil {
+				t.Fatalf("zstd -d failure: %v: %s", err, stderr.String())
+			}
+			if !bytes.Equal(out.Bytes(), data) {
+				t.Errorf("zstd -d = %d bytes, want %d bytes", out.Len(), len(data))
+			}
+		})
+	}
+}
+
+func TestNewWriterLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   int
+		wantErr bool
+	}{
+		{name: "valid-fastest", level: BestSpeed},
+		{name: "valid-best", level: BestCompression},
+		{name: "invalid-zero", level: 0, wantErr: true},
+		{name: "invalid-high", level: 10, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewWriterLevel(&bytes.Buffer{}, tt.level)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewWriterLevel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriter_Close(t *testing.T) {
+	var buf bytes.Buffer
+	z, err := NewWriterLevel(&buf, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := z.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := z.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+	if _, err := z.Write([]byte("data")); err == nil {
+		t.Error("Write() after Close() expected error")
+	}
+
+	// An empty frame holds a single empty last block.
+	if want := append(append([]byte(nil), frameHeader...), 1, 0, 0); !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("empty frame = %x, want %x", buf.Bytes(), want)
+	}
+}
@@ -0,0 +1,170 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Ansible inventory JSON key holding host variables.
+	ansibleMetaKey string = "_meta"
+	// Group variable holding host attributes in inventories produced by ansible-dns-inventory.
+	ansibleAttributesVar string = "inventory_attributes"
+)
+
+// ansibleMeta represents the '_meta' section of an Ansible inventory JSON.
+type ansibleMeta struct {
+	Hostvars map[string]map[string]interface{} `json:"hostvars"`
+}
+
+// ansibleString converts a JSON value into a string, converting null into an empty string.
+func ansibleString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+
+	return fmt.Sprint(v)
+}
+
+// ansibleAttribute returns the value of a host attribute from the host variables or the configured defaults.
+func (i *Inventory) ansibleAttribute(key string, hostvars map[string]interface{}, used map[string]bool) string {
+	cfg := i.Config
+
+	// Viper folds map keys to lower case.
+	name := strings.ToLower(key)
+	if v, ok := cfg.Import.Ansible.Keys[name]; ok {
+		name = v
+	}
+
+	if v, ok := hostvars[name]; ok {
+		used[name] = true
+		return ansibleString(v)
+	}
+
+	return cfg.Import.Ansible.Defaults[strings.ToLower(key)]
+}
+
+// ansibleVariables renders the remaining scalar host variables into a host variables attribute string.
+func (i *Inventory) ansibleVariables(host string, hostvars map[string]interface{}, used map[string]bool) string {
+	cfg := i.Config
+	log := i.Logger
+
+	names := make([]string, 0, len(hostvars))
+	for name := range hostvars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	vars := make([]string, 0, len(names))
+	for _, name := range names {
+		if used[name] {
+			continue
+		}
+
+		switch value := hostvars[name].(type) {
+		case string, float64, bool:
+			v := fmt.Sprint(value)
+			if strings.ContainsAny(name+v, cfg.Txt.Vars.Separator+cfg.Txt.Vars.Equalsign+cfg.Txt.Kv.Separator) {
+				log.Warnf("[%s] skipping host variable containing separators: %s", host, name)
+				continue
+			}
+
+			vars = append(vars, name+cfg.Txt.Vars.Equalsign+v)
+		default:
+			log.Warnf("[%s] skipping non-scalar host variable: %s", host, name)
+		}
+	}
+
+	return strings.Join(vars, cfg.Txt.Vars.Separator)
+}
+
+// ParseAnsibleInventory converts the JSON output of 'ansible-inventory --list' into a map of hosts and their attributes.
+// Hosts in groups that carry the 'inventory_attributes' variable (i.e. inventories produced by ansible-dns-inventory) get one set of attributes per group.
+// Other hosts get a single set of attributes taken from their host variables (see 'import.ansible.keys') or the configured defaults.
+func (i *Inventory) ParseAnsibleInventory(data []byte) (map[string][]*HostAttributes, error) {
+	cfg := i.Config
+	hosts := make(map[string][]*HostAttributes)
+
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, errors.Wrap(err, "ansible inventory parsing failure")
+	}
+
+	meta := &ansibleMeta{}
+	if m, ok := raw[ansibleMetaKey]; ok {
+		if err := json.Unmarshal(m, meta); err != nil {
+			return nil, errors.Wrap(err, "ansible inventory parsing failure")
+		}
+	}
+
+	// Collect all hosts and the attribute sets found in group variables.
+	known := make(map[string]bool)
+	for host := range meta.Hostvars {
+		known[host] = true
+	}
+
+	for name, r := range raw {
+		if name == ansibleMetaKey {
+			continue
+		}
+
+		group := &AnsibleGroup{}
+		if err := json.Unmarshal(r, group); err != nil {
+			return nil, errors.Wrapf(err, "%s: ansible group parsing failure", name)
+		}
+
+		attrs, _ := group.Vars[ansibleAttributesVar].(map[string]interface{})
+
+		for _, host := range group.Hosts {
+			known[host] = true
+
+			if attrs == nil {
+				continue
+			}
+
+			hosts[host] = append(hosts[host], &HostAttributes{
+				OS:   ansibleString(attrs[cfg.Txt.Keys.Os]),
+				Env:  ansibleString(attrs[cfg.Txt.Keys.Env]),
+				Role: ansibleString(attrs[cfg.Txt.Keys.Role]),
+				Srv:  ansibleString(attrs[cfg.Txt.Keys.Srv]),
+			})
+		}
+	}
+
+	for host := range known {
+		hostvars := meta.Hostvars[host]
+		used := make(map[string]bool)
+
+		if _, ok := hosts[host]; !ok {
+			hosts[host] = []*HostAttributes{{
+				OS:   i.ansibleAttribute(cfg.Txt.Keys.Os, hostvars, used),
+				Env:  i.ansibleAttribute(cfg.Txt.Keys.Env, hostvars, used),
+				Role: i.ansibleAttribute(cfg.Txt.Keys.Role, hostvars, used),
+				Srv:  i.ansibleAttribute(cfg.Txt.Keys.Srv, hostvars, used),
+			}}
+		}
+
+		// Preserve unique host identifiers exported by this tool.
+		if id, ok := hostvars[hostIDVar].(string); ok {
+			used[hostIDVar] = true
+			for _, attrs := range hosts[host] {
+				attrs.ID = id
+			}
+		}
+
+		if !cfg.Import.Ansible.Vars {
+			continue
+		}
+
+		vars := i.ansibleVariables(host, hostvars, used)
+		for _, attrs := range hosts[host] {
+			attrs.Vars = vars
+		}
+	}
+
+	return hosts, nil
+}
@@ -0,0 +1,94 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInventory_ParseAnsibleInventory(t *testing.T) {
+	cfg := &Config{}
+	cfg.Txt.Kv.Separator = ";"
+	cfg.Txt.Kv.Equalsign = "="
+	cfg.Txt.Vars.Separator = ","
+	cfg.Txt.Vars.Equalsign = "="
+	cfg.Txt.Keys.Os = "OS"
+	cfg.Txt.Keys.Env = "ENV"
+	cfg.Txt.Keys.Role = "ROLE"
+	cfg.Txt.Keys.Srv = "SRV"
+	cfg.Txt.Keys.Vars = "VARS"
+	cfg.Import.Ansible.Keys = map[string]string{"role": "app_role"}
+	cfg.Import.Ansible.Defaults = map[string]string{"os": "linux"}
+	cfg.Import.Ansible.Vars = true
+
+	testInventory := &Inventory{
+		Config: cfg,
+	}
+
+	type args struct {
+		data string
+	}
+	tests := []struct {
+		name    string
+		i       *Inventory
+		args    args
+		want    map[string][]*HostAttributes
+		wantErr bool
+	}{
+		{
+			name: "valid-hostvars",
+			i:    testInventory,
+			args: args{
+				data: `{
+  "_meta": {"hostvars": {"app01.infra.local": {"env": "dev", "app_role": "app", "ansible_host": "10.0.0.1", "ansible_port": 22}}},
+  "all": {"children": ["ungrouped"]},
+  "ungrouped": {"hosts": ["app01.infra.local"]}
+}`,
+			},
+			want: map[string][]*HostAttributes{
+				"app01.infra.local": {
+					{OS: "linux", Env: "dev", Role: "app", Srv: "", Vars: "ansible_host=10.0.0.1,ansible_port=22"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid-inventory-attributes",
+			i:    testInventory,
+			args: args{
+				data: `{
+  "all": {"children": ["dev"]},
+  "dev": {"children": ["dev_app"]},
+  "dev_app": {"children": ["dev_app_tomcat"]},
+  "dev_app_tomcat": {"hosts": ["app01.infra.local"], "vars": {"inventory_attributes": {"OS": "linux", "ENV": "dev", "ROLE": "app", "SRV": "tomcat"}}}
+}`,
+			},
+			want: map[string][]*HostAttributes{
+				"app01.infra.local": {
+					{OS: "linux", Env: "dev", Role: "app", Srv: "tomcat", Vars: ""},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid-json",
+			i:    testInventory,
+			args: args{
+				data: `{"all": [`,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.i.ParseAnsibleInventory([]byte(tt.args.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Inventory.ParseAnsibleInventory() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inventory.ParseAnsibleInventory() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Audit log file output.
+	FileAuditOutput string = "file"
+	// Audit log syslog output.
+	SyslogAuditOutput string = "syslog"
+
+	// Audit result of a completed write operation.
+	SuccessAuditResult string = "success"
+	// Audit result of a failed write operation.
+	FailureAuditResult string = "failure"
+	// Audit result of a write operation rejected by the publishing policy or a pre-publish hook.
+	RejectedAuditResult string = "rejected"
+)
+
+// Syslog facility codes.
+var auditSyslogFacilities = map[string]int{
+	"user":     1,
+	"daemon":   3,
+	"auth":     4,
+	"authpriv": 10,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// AuditLog records write operations as JSON lines in a file or as syslog messages.
+type AuditLog struct {
+	// Inventory configuration.
+	Config *Config
+
+	// Guards the audit log file.
+	mu sync.Mutex
+	// Audit log file (file output only).
+	file *os.File
+	// Syslog facility code (syslog output only).
+	facility int
+	// Local host name sent in syslog messages.
+	hostname string
+}
+
+// syslog sends an audit log entry to the syslog server.
+// A new connection is made for every entry, so that a restarted syslog server does not break auditing.
+func (a *AuditLog) syslog(severity int, line []byte) error {
+	cfg := a.Config
+
+	conn, err := net.DialTimeout(cfg.Audit.Syslog.Network, cfg.Audit.Syslog.Address, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+
+	// Local syslog daemons expect the BSD format without a host name, network ones get an RFC 3339 timestamp and the host name.
+	priority := a.facility*8 + severity
+	var msg string
+	if cfg.Audit.Syslog.Network == "unixgram" || cfg.Audit.Syslog.Network == "unix" {
+		msg = fmt.Sprintf("<%d>%s %s[%d]: %s", priority, time.Now().Format(time.Stamp), cfg.Audit.Syslog.Tag, os.Getpid(), line)
+	} else {
+		msg = fmt.Sprintf("<%d>%s %s %s[%d]: %s", priority, time.Now().Format(time.RFC3339), a.hostname, cfg.Audit.Syslog.Tag, os.Getpid(), line)
+	}
+
+	// Stream connections need a frame delimiter.
+	if cfg.Audit.Syslog.Network == "tcp" {
+		msg += "\n"
+	}
+
+	_, err = conn.Write([]byte(msg))
+
+	return err
+}
+
+// Record writes an entry to the audit log.
+func (a *AuditLog) Record(entry *AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "audit entry marshalling failure")
+	}
+
+	if a.file == nil {
+		// Informational for completed operations, warning otherwise.
+		severity := 6
+		if entry.Result != SuccessAuditResult {
+			severity = 4
+		}
+
+		return errors.Wrap(a.syslog(severity, line), "audit log write failure")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, err := a.file.Write(append(line, '\n')); err != nil {
+		return errors.Wrap(err, "audit log write failure")
+	}
+
+	return errors.Wrap(a.file.Sync(), "audit log write failure")
+}
+
+// Close closes the audit log.
+func (a *AuditLog) Close() {
+	if a.file != nil {
+		a.file.Close()
+	}
+}
+
+// NewAuditLog opens the audit log. Nothing is opened if auditing is disabled.
+func NewAuditLog(cfg *Config) (*AuditLog, error) {
+	if !cfg.Audit.Enabled {
+		return nil, nil
+	}
+
+	a := &AuditLog{Config: cfg}
+
+	switch cfg.Audit.Output {
+	case FileAuditOutput:
+		file, err := os.OpenFile(cfg.Audit.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, errors.Wrap(err, "audit log opening failure")
+		}
+
+		a.file = file
+	case SyslogAuditOutput:
+		facility, ok := auditSyslogFacilities[cfg.Audit.Syslog.Facility]
+		if !ok {
+			return nil, errors.Errorf("unknown syslog facility: %s", cfg.Audit.Syslog.Facility)
+		}
+		a.facility = facility
+
+		if a.hostname, _ = os.Hostname(); len(a.hostname) == 0 {
+			a.hostname = "-"
+		}
+
+		// Check that the syslog server is reachable.
+		conn, err := net.DialTimeout(cfg.Audit.Syslog.Network, cfg.Audit.Syslog.Address, 5*time.Second)
+		if err != nil {
+			return nil, errors.Wrap(err, "syslog connection failure")
+		}
+		conn.Close()
+	default:
+		return nil, errors.Errorf("unknown audit log output: %s", cfg.Audit.Output)
+	}
+
+	return a, nil
+}
+
+// defaultActor returns the actor of write operations started from the command line: the current system user.
+func defaultActor() string {
+	if u, err := user.Current(); err == nil && len(u.Username) > 0 {
+		return u.Username
+	}
+
+	for _, name := range []string{"USER", "USERNAME"} {
+		if v := os.Getenv(name); len(v) > 0 {
+			return v
+		}
+	}
+
+	return "unknown"
+}
+
+// auditBefore acquires the attribute strings of hosts from the datasource.
+func (i *Inventory) auditBefore(hosts []string) (map[string][]string, error) {
+	var records []*DatasourceRecord
+	var err error
+
+	if len(hosts) == 1 {
+		records, err = i.hostRecords(hosts[0])
+	} else {
+		records, err = i.Datasource.GetAllRecords()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	before := make(map[string][]string, len(hosts))
+	for _, host := range hosts {
+		before[host] = make([]string, 0)
+	}
+
+	for _, r := range records {
+		if attrs, ok := before[r.Hostname]; ok {
+			before[r.Hostname] = append(attrs, r.Attributes)
+		}
+	}
+
+	return before, nil
+}
+
+// auditStart creates an audit log entry for a publishing event, recording the attributes of the affected hosts before the operation if configured.
+// It returns nil if auditing is disabled.
+func (i *Inventory) auditStart(event *HookEvent) *AuditEntry {
+	cfg := i.Config
+	log := i.Logger
+
+	if i.Audit == nil {
+		return nil
+	}
+
+	entry := &AuditEntry{
+		Actor:      event.Actor,
+		Address:    event.Address,
+		Operation:  event.Operation,
+		Datasource: cfg.Datasource,
+		After:      make(map[string][]string),
+	}
+
+	for _, host := range event.Hosts {
+		entry.After[host] = make([]string, 0)
+	}
+	for _, r := range event.Records {
+		entry.After[r.Hostname] = append(entry.After[r.Hostname], r.Attributes)
+	}
+
+	entry.Hosts = make([]string, 0, len(entry.After))
+	for host := range entry.After {
+		entry.Hosts = append(entry.Hosts, host)
+	}
+	sort.Strings(entry.Hosts)
+
+	if cfg.Audit.Before && len(entry.Hosts) > 0 {
+		before, err := i.auditBefore(entry.Hosts)
+		if err != nil {
+			log.Warnf("[%s] audit log: previous host records are not available: %v", event.Operation, err)
+		}
+
+		entry.Before = before
+	}
+
+	return entry
+}
+
+// auditFinish records the result of a publishing event in the audit log. Failures are logged.
+func (i *Inventory) auditFinish(entry *AuditEntry, result string, err error) {
+	if entry == nil {
+		return
+	}
+
+	entry.Time = time.Now().UTC()
+	entry.Result = result
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if err := i.Audit.Record(entry); err != nil {
+		i.Logger.Errorf("[%s] %v", entry.Operation, err)
+	}
+}
@@ -0,0 +1,171 @@
+package inventory
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInventory_audit(t *testing.T) {
+	records := []*DatasourceRecord{
+		{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat"},
+	}
+	actor := &Actor{Name: "token:0badf00d", Address: "192.0.2.10"}
+
+	tests := []struct {
+		name  string
+		roles []string
+		run   func(i *Inventory) error
+		want  *AuditEntry
+	}{
+		{
+			name: "valid-edit",
+			run: func(i *Inventory) error {
+				_, err := i.EditHostAs(actor, "app01.infra.local", []*HostAttributes{{OS: "linux", Env: "dev", Role: "web", Srv: "nginx"}})
+				return err
+			},
+			want: &AuditEntry{
+				Actor:      "token:0badf00d",
+				Address:    "192.0.2.10",
+				Operation:  "edit",
+				Datasource: "dns",
+				Hosts:      []string{"app01.infra.local"},
+				Before:     map[string][]string{"app01.infra.local": {"OS=linux;ENV=dev;ROLE=app;SRV=tomcat"}},
+				After:      map[string][]string{"app01.infra.local": {"OS=linux;ENV=dev;ROLE=web;SRV=nginx;VARS="}},
+				Result:     SuccessAuditResult,
+			},
+		},
+		{
+			name: "valid-delete",
+			run: func(i *Inventory) error {
+				_, err := i.DeleteHostAs(actor, "app01.infra.local")
+				return err
+			},
+			want: &AuditEntry{
+				Actor:      "token:0badf00d",
+				Address:    "192.0.2.10",
+				Operation:  "delete",
+				Datasource: "dns",
+				Hosts:      []string{"app01.infra.local"},
+				Before:     map[string][]string{"app01.infra.local": {"OS=linux;ENV=dev;ROLE=app;SRV=tomcat"}},
+				After:      map[string][]string{"app01.infra.local": {}},
+				Result:     SuccessAuditResult,
+			},
+		},
+		{
+			// Rejected operations are recorded with the attributes that would have been written.
+			name:  "valid-rejected",
+			roles: []string{"db"},
+			run: func(i *Inventory) error {
+				_, err := i.EditHostAs(actor, "app02.infra.local", []*HostAttributes{{OS: "linux", Env: "dev", Role: "app"}})
+				return err
+			},
+			want: &AuditEntry{
+				Actor:      "token:0badf00d",
+				Address:    "192.0.2.10",
+				Operation:  "edit",
+				Datasource: "dns",
+				Hosts:      []string{"app02.infra.local"},
+				Before:     map[string][]string{"app02.infra.local": {}},
+				After:      map[string][]string{"app02.infra.local": {"OS=linux;ENV=dev;ROLE=app;SRV=;VARS="}},
+				Result:     RejectedAuditResult,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newTestInventory(t, false, nil)
+			i.Config.Policy.Enabled = len(tt.roles) > 0
+			i.Config.Policy.Roles = tt.roles
+			i.Datasource = &testEditingDatasource{testDatasource: &testDatasource{records: records}, replaced: make(map[string][]*DatasourceRecord)}
+
+			i.Config.Audit.Enabled = true
+			i.Config.Audit.File = filepath.Join(t.TempDir(), "audit.log")
+
+			var err error
+			if i.Audit, err = NewAuditLog(i.Config); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.run(i)
+
+			data, err := os.ReadFile(i.Config.Audit.File)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			if len(lines) != 1 {
+				t.Fatalf("Inventory.auditFinish() wrote %d entries, want 1", len(lines))
+			}
+
+			got := &AuditEntry{}
+			if err := json.Unmarshal([]byte(lines[0]), got); err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Time.IsZero() || (got.Result == SuccessAuditResult) != (len(got.Error) == 0) {
+				t.Errorf("Inventory.auditFinish() entry time = %v, error = %q", got.Time, got.Error)
+			}
+			got.Time = time.Time{}
+			got.Error = ""
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inventory.auditFinish() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditLog_Record(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	cfg := &Config{}
+	cfg.Audit.Enabled = true
+	cfg.Audit.Output = SyslogAuditOutput
+	cfg.Audit.Syslog.Network = "udp"
+	cfg.Audit.Syslog.Address = conn.LocalAddr().String()
+	cfg.Audit.Syslog.Facility = "authpriv"
+	cfg.Audit.Syslog.Tag = "adi"
+
+	a, err := NewAuditLog(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	if err := a.Record(&AuditEntry{Actor: "root", Operation: "import", Result: FailureAuditResult}); err != nil {
+		t.Fatalf("AuditLog.Record() error = %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 4096)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// authpriv (10) * 8 + warning (4).
+	header, _, ok := strings.Cut(string(buf[:n]), "]: ")
+	if !ok || !strings.HasPrefix(header, "<84>") || !strings.Contains(header, " adi[") {
+		t.Errorf("AuditLog.Record() sent a message with an invalid header: %q", buf[:n])
+	}
+
+	if !strings.Contains(string(buf[:n]), `"actor":"root","operation":"import"`) {
+		t.Errorf("AuditLog.Record() sent %q", buf[:n])
+	}
+
+	cfg.Audit.Syslog.Facility = "kern"
+	if _, err := NewAuditLog(cfg); err == nil {
+		t.Errorf("NewAuditLog() accepted an unknown facility")
+	}
+}
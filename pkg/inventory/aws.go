@@ -0,0 +1,490 @@
+package inventory
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// AWS Signature Version 4 algorithm identifier.
+	awsSigningAlgorithm string = "AWS4-HMAC-SHA256"
+	// Format of the 'X-Amz-Date' header.
+	awsTimeFormat string = "20060102T150405Z"
+	// Temporary credentials are renewed this long before they expire.
+	awsCredentialsExpiryWindow time.Duration = 5 * time.Minute
+	// Lifetime of instance metadata service session tokens.
+	awsIMDSTokenTTL string = "21600"
+)
+
+type (
+	// awsCredentials represents a set of AWS API credentials.
+	awsCredentials struct {
+		AccessKeyID     string
+		SecretAccessKey string
+		SessionToken    string
+		// Expiration time of temporary credentials, zero for long-term credentials.
+		Expires time.Time
+	}
+
+	// awsClient signs and sends AWS API requests, acquiring and renewing credentials as needed.
+	awsClient struct {
+		// Inventory configuration.
+		config *Config
+		// HTTP client.
+		client *http.Client
+
+		// Guards the credentials.
+		mu sync.Mutex
+		// Current credentials, nil until the first request.
+		creds *awsCredentials
+	}
+
+	// awsSTSCredentials represents the credentials returned by the STS AssumeRole and AssumeRoleWithWebIdentity actions.
+	awsSTSCredentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	}
+
+	// awsError represents an AWS API error response. Query APIs wrap the error into an 'ErrorResponse' element, REST APIs do not.
+	awsError struct {
+		Code    string `xml:"Error>Code"`
+		Message string `xml:"Error>Message"`
+	}
+
+	// awsStatusError represents a non-2xx AWS API response.
+	awsStatusError struct {
+		// HTTP status code.
+		StatusCode int
+		// HTTP status line.
+		Status string
+		// AWS error code, if any.
+		Code string
+		// AWS error message, if any.
+		Message string
+	}
+)
+
+// Error implements the error interface.
+func (e *awsStatusError) Error() string {
+	if len(e.Code) == 0 {
+		return e.Status
+	}
+
+	return e.Status + ": " + e.Code + ": " + e.Message
+}
+
+// expired checks if temporary credentials are about to expire.
+func (c *awsCredentials) expired() bool {
+	return !c.Expires.IsZero() && time.Now().Add(awsCredentialsExpiryWindow).After(c.Expires)
+}
+
+// awsHash returns the hex-encoded SHA-256 hash of data.
+func awsHash(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// awsHMAC returns the HMAC-SHA256 of data.
+func awsHMAC(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
+
+// awsEscape encodes a string according to RFC 3986 as required by AWS Signature Version 4.
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// awsSign signs an HTTP request with AWS Signature Version 4.
+func awsSign(req *http.Request, body []byte, creds *awsCredentials, region string, service string, now time.Time) {
+	amzDate := now.UTC().Format(awsTimeFormat)
+	scope := amzDate[:8] + "/" + region + "/" + service + "/aws4_request"
+	payload := awsHash(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payload)
+	}
+	if len(creds.SessionToken) > 0 {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	// Sign the host header and all AWS-specific headers.
+	headers := map[string]string{"host": req.URL.Host}
+	if value := req.Header.Get("Content-Type"); len(value) > 0 {
+		headers["content-type"] = value
+	}
+	for name := range req.Header {
+		if name := strings.ToLower(name); strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// Query parameters are sorted by name and then by value.
+	query := req.URL.Query()
+	params := make([]string, 0, len(query))
+	for key, values := range query {
+		for _, value := range values {
+			params = append(params, awsEscape(key)+"="+awsEscape(value))
+		}
+	}
+	sort.Strings(params)
+
+	path := req.URL.EscapedPath()
+	if len(path) == 0 {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{req.Method, path, strings.Join(params, "&"), canonicalHeaders.String(), signedHeaders, payload}, "\n")
+	stringToSign := strings.Join([]string{awsSigningAlgorithm, amzDate, scope, awsHash([]byte(canonicalRequest))}, "\n")
+
+	key := awsHMAC([]byte("AWS4"+creds.SecretAccessKey), amzDate[:8])
+	key = awsHMAC(key, region)
+	key = awsHMAC(key, service)
+	key = awsHMAC(key, "aws4_request")
+
+	req.Header.Set("Authorization", awsSigningAlgorithm+" Credential="+creds.AccessKeyID+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+hex.EncodeToString(awsHMAC(key, stringToSign)))
+}
+
+// awsSharedCredentials reads a profile from the shared credentials file.
+// A missing file or profile is not an error unless the profile has been selected explicitly.
+func awsSharedCredentials(profile string) (*awsCredentials, error) {
+	explicit := len(profile) > 0
+	if !explicit {
+		if profile = os.Getenv("AWS_PROFILE"); len(profile) > 0 {
+			explicit = true
+		} else {
+			profile = "default"
+		}
+	}
+
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if len(path) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if explicit {
+			return nil, errors.Wrap(err, "shared credentials file loading failure")
+		}
+		return nil, nil
+	}
+	defer file.Close()
+
+	var section string
+	var found bool
+	creds := &awsCredentials{}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			found = found || section == profile
+			continue
+		}
+
+		if section != profile {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+
+		switch strings.TrimSpace(key) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			creds.SessionToken = strings.TrimSpace(value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "shared credentials file parsing failure")
+	}
+
+	if !found || len(creds.AccessKeyID) == 0 {
+		if explicit {
+			return nil, errors.Errorf("no credentials found for profile %s in %s", profile, path)
+		}
+		return nil, nil
+	}
+
+	return creds, nil
+}
+
+// sts calls an AWS STS action and returns the temporary credentials from its response. The request is only signed if credentials are provided.
+func (a *awsClient) sts(params url.Values, creds *awsCredentials) (*awsCredentials, error) {
+	cfg := a.config
+	params.Set("Version", "2011-06-15")
+	body := []byte(params.Encode())
+
+	endpoint := cfg.AWS.STSEndpoint
+	if len(endpoint) == 0 {
+		endpoint = "https://sts." + cfg.AWS.Region + ".amazonaws.com"
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if creds != nil {
+		awsSign(req, body, creds, cfg.AWS.Region, "sts", time.Now())
+	}
+
+	data, err := a.send(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "sts %s failure", params.Get("Action"))
+	}
+
+	var result struct {
+		AssumeRole            awsSTSCredentials `xml:"AssumeRoleResult>Credentials"`
+		AssumeRoleWebIdentity awsSTSCredentials `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(data, &result); err != nil {
+		return nil, errors.Wrap(err, "sts response parsing failure")
+	}
+
+	sts := result.AssumeRole
+	if len(sts.AccessKeyID) == 0 {
+		sts = result.AssumeRoleWebIdentity
+	}
+
+	if len(sts.AccessKeyID) == 0 {
+		return nil, errors.Errorf("sts %s failure: no credentials returned", params.Get("Action"))
+	}
+
+	return &awsCredentials{AccessKeyID: sts.AccessKeyID, SecretAccessKey: sts.SecretAccessKey, SessionToken: sts.SessionToken, Expires: sts.Expiration}, nil
+}
+
+// imds acquires the credentials of the IAM role attached to an EC2 instance from the instance metadata service (IMDSv2).
+func (a *awsClient) imds() (*awsCredentials, error) {
+	endpoint := strings.TrimSuffix(a.config.AWS.IMDSEndpoint, "/")
+
+	req, err := http.NewRequest(http.MethodPut, endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", awsIMDSTokenTTL)
+
+	token, err := a.send(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "instance metadata token request failure")
+	}
+
+	get := func(path string) ([]byte, error) {
+		req, err := http.NewRequest(http.MethodGet, endpoint+"/latest/meta-data/iam/security-credentials/"+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-Aws-Ec2-Metadata-Token", string(token))
+
+		return a.send(req)
+	}
+
+	role, err := get("")
+	if err != nil {
+		return nil, errors.Wrap(err, "instance role lookup failure")
+	}
+
+	data, err := get(strings.TrimSpace(strings.SplitN(string(role), "\n", 2)[0]))
+	if err != nil {
+		return nil, errors.Wrap(err, "instance role credentials request failure")
+	}
+
+	var result struct {
+		AccessKeyID     string    `json:"AccessKeyId"`
+		SecretAccessKey string    `json:"SecretAccessKey"`
+		Token           string    `json:"Token"`
+		Expiration      time.Time `json:"Expiration"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, errors.Wrap(err, "instance role credentials parsing failure")
+	}
+
+	return &awsCredentials{AccessKeyID: result.AccessKeyID, SecretAccessKey: result.SecretAccessKey, SessionToken: result.Token, Expires: result.Expiration}, nil
+}
+
+// resolve acquires credentials from the first available source: the configuration file, the environment, the shared credentials file,
+// a web identity token (e.g. IAM roles for Kubernetes service accounts) or the EC2 instance metadata service.
+// If a role is configured, it is assumed using these credentials.
+func (a *awsClient) resolve() (*awsCredentials, error) {
+	cfg := a.config
+	var creds *awsCredentials
+	var err error
+
+	switch {
+	case len(cfg.AWS.AccessKeyID) > 0:
+		creds = &awsCredentials{AccessKeyID: cfg.AWS.AccessKeyID, SecretAccessKey: cfg.AWS.SecretAccessKey, SessionToken: cfg.AWS.SessionToken}
+	case len(cfg.AWS.Profile) == 0 && len(os.Getenv("AWS_ACCESS_KEY_ID")) > 0:
+		creds = &awsCredentials{AccessKeyID: os.Getenv("AWS_ACCESS_KEY_ID"), SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"), SessionToken: os.Getenv("AWS_SESSION_TOKEN")}
+	default:
+		if creds, err = awsSharedCredentials(cfg.AWS.Profile); err != nil {
+			return nil, err
+		}
+	}
+
+	if creds == nil {
+		if file, role := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), os.Getenv("AWS_ROLE_ARN"); len(file) > 0 && len(role) > 0 {
+			token, err := os.ReadFile(file)
+			if err != nil {
+				return nil, errors.Wrap(err, "web identity token loading failure")
+			}
+
+			params := url.Values{"Action": {"AssumeRoleWithWebIdentity"}, "RoleArn": {role}, "RoleSessionName": {cfg.AWS.SessionName}, "WebIdentityToken": {strings.TrimSpace(string(token))}}
+			if creds, err = a.sts(params, nil); err != nil {
+				return nil, err
+			}
+		} else if creds, err = a.imds(); err != nil {
+			return nil, errors.Wrap(err, "no aws credentials found")
+		}
+	}
+
+	if len(cfg.AWS.RoleARN) == 0 {
+		return creds, nil
+	}
+
+	params := url.Values{"Action": {"AssumeRole"}, "RoleArn": {cfg.AWS.RoleARN}, "RoleSessionName": {cfg.AWS.SessionName}}
+	if len(cfg.AWS.ExternalID) > 0 {
+		params.Set("ExternalId", cfg.AWS.ExternalID)
+	}
+
+	return a.sts(params, creds)
+}
+
+// credentials returns the current credentials, renewing them if they are about to expire.
+func (a *awsClient) credentials() (*awsCredentials, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.creds == nil || a.creds.expired() {
+		creds, err := a.resolve()
+		if err != nil {
+			return nil, err
+		}
+
+		a.creds = creds
+	}
+
+	return a.creds, nil
+}
+
+// exchange performs an HTTP request and returns the response headers and body. Non-2xx responses are returned as *awsStatusError errors.
+func (a *awsClient) exchange(req *http.Request) (http.Header, []byte, error) {
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := &awsStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+
+		var body awsError
+		if xml.Unmarshal(data, &body) == nil && len(body.Code) > 0 {
+			e.Code = body.Code
+			e.Message = body.Message
+		}
+
+		return nil, nil, errors.WithStack(e)
+	}
+
+	return resp.Header, data, nil
+}
+
+// send performs an HTTP request and returns the response body. Non-2xx responses are returned as errors.
+func (a *awsClient) send(req *http.Request) ([]byte, error) {
+	_, data, err := a.exchange(req)
+
+	return data, err
+}
+
+// sign signs an AWS API request to a service in a specific region, acquiring credentials if needed.
+func (a *awsClient) sign(req *http.Request, body []byte, region string, service string) error {
+	creds, err := a.credentials()
+	if err != nil {
+		return err
+	}
+
+	awsSign(req, body, creds, region, service, time.Now())
+
+	return nil
+}
+
+// do signs and sends an AWS API request to a service in a specific region and returns the response body.
+func (a *awsClient) do(method string, endpoint string, region string, service string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.sign(req, body, region, service); err != nil {
+		return nil, err
+	}
+
+	return a.send(req)
+}
+
+// newAWSClient creates an AWS API client. Credentials are acquired on the first request.
+func newAWSClient(cfg *Config, timeout time.Duration) *awsClient {
+	return &awsClient{
+		config: cfg,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{Proxy: http.ProxyFromEnvironment},
+		},
+	}
+}
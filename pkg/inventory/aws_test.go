@@ -0,0 +1,44 @@
+package inventory
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_awsSign(t *testing.T) {
+	// AWS Signature Version 4 test suite: get-vanilla and get-vanilla-query-order-key-case.
+	creds := &awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "valid-vanilla",
+			url:  "https://example.amazonaws.com/",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name: "valid-query-order",
+			url:  "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			awsSign(req, nil, creds, "us-east-1", "service", now)
+
+			if got := req.Header.Get("Authorization"); got != tt.want {
+				t.Errorf("awsSign() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
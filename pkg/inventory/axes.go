@@ -0,0 +1,70 @@
+package inventory
+
+import (
+	"github.com/pkg/errors"
+)
+
+// defaultGroupAxes lists the group axes used if none are configured: root>environment>role>service.
+var defaultGroupAxes = []string{"ENV", "ROLE", "SRV"}
+
+// groupAxes resolves the configured group axes into attribute names: 'OS', 'ENV', 'ROLE', 'SRV' or an extra attribute key.
+// The environment axis must come first, since it defines the top-level groups. Host variables and host identifiers cannot be group axes.
+func (i *Inventory) groupAxes() ([]string, error) {
+	cfg := i.Config
+
+	if len(cfg.Axes) == 0 {
+		return defaultGroupAxes, nil
+	}
+
+	names := map[string]string{
+		cfg.Txt.Keys.Os:   "OS",
+		cfg.Txt.Keys.Env:  "ENV",
+		cfg.Txt.Keys.Role: "ROLE",
+		cfg.Txt.Keys.Srv:  "SRV",
+	}
+	for _, key := range cfg.Txt.Keys.Extra {
+		if _, ok := names[key]; ok || key == cfg.Txt.Keys.Vars || key == cfg.Txt.Keys.ID {
+			return nil, errors.Errorf("invalid extra attribute key: %s", key)
+		}
+
+		names[key] = key
+	}
+
+	axes := make([]string, 0, len(cfg.Axes))
+	seen := make(map[string]bool)
+	for _, key := range cfg.Axes {
+		name, ok := names[key]
+		if !ok {
+			return nil, errors.Errorf("invalid group axis: %s: not an attribute key", key)
+		}
+
+		if seen[name] {
+			return nil, errors.Errorf("invalid group axis: %s: duplicate key", key)
+		}
+		seen[name] = true
+
+		axes = append(axes, name)
+	}
+
+	if axes[0] != "ENV" {
+		return nil, errors.Errorf("invalid group axes: the first axis must be %s", cfg.Txt.Keys.Env)
+	}
+
+	return axes, nil
+}
+
+// axisValue returns the value of a host attribute by its name: 'OS', 'ENV', 'ROLE', 'SRV' or an extra attribute key.
+func (a *HostAttributes) axisValue(name string) string {
+	switch name {
+	case "OS":
+		return a.OS
+	case "ENV":
+		return a.Env
+	case "ROLE":
+		return a.Role
+	case "SRV":
+		return a.Srv
+	default:
+		return a.Extra[name]
+	}
+}
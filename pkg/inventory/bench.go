@@ -0,0 +1,169 @@
+package inventory
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Seed of the synthetic record generator, fixed to make benchmark runs comparable.
+const benchGeneratorSeed int64 = 1
+
+// benchZones returns the zones synthetic hosts are spread across.
+func (i *Inventory) benchZones() ([]string, error) {
+	cfg := i.Config
+
+	var zones []string
+	switch cfg.Datasource {
+	case EtcdDatasourceType:
+		zones = cfg.Etcd.Zones
+	case VaultDatasourceType:
+		zones = cfg.Vault.Zones
+	default:
+		zones = cfg.DNS.Zones
+	}
+
+	if cfg.Bench.Zones > 0 && cfg.Bench.Zones < len(zones) {
+		zones = zones[:cfg.Bench.Zones]
+	}
+
+	if len(zones) == 0 {
+		return nil, errors.New("no zones configured")
+	}
+
+	return zones, nil
+}
+
+// GenerateHosts produces a deterministic set of synthetic hosts and their attributes using the 'bench' configuration section.
+// Hosts are spread evenly across the configured zones, attribute values are picked from sets of the configured cardinality.
+func (i *Inventory) GenerateHosts() (map[string][]*HostAttributes, error) {
+	cfg := i.Config
+	spec := cfg.Bench
+
+	zones, err := i.benchZones()
+	if err != nil {
+		return nil, err
+	}
+
+	random := rand.New(rand.NewSource(benchGeneratorSeed))
+	pick := func(prefix string, cardinality int) string {
+		return prefix + strconv.Itoa(random.Intn(max(cardinality, 1)))
+	}
+
+	hosts := make(map[string][]*HostAttributes, spec.Hosts)
+	for n := 0; n < spec.Hosts; n++ {
+		zone := strings.TrimSuffix(zones[n%len(zones)], ".")
+		host := fmt.Sprintf("bench%06d.%s", n, zone)
+
+		vars := make([]string, 0, spec.Vars)
+		for v := 0; v < spec.Vars; v++ {
+			vars = append(vars, fmt.Sprintf("bench_var%d%s%d", v, cfg.Txt.Vars.Equalsign, random.Int()))
+		}
+
+		hosts[host] = []*HostAttributes{{
+			OS:   pick("os", spec.OS),
+			Env:  pick("env", spec.Envs),
+			Role: pick("role", spec.Roles),
+			Srv:  pick("srv", spec.Services),
+			Vars: strings.Join(vars, cfg.Txt.Vars.Separator),
+		}}
+	}
+
+	return hosts, nil
+}
+
+// WriteZoneFile writes host records as DNS TXT resource records with absolute owner names, ready to be included into test zones.
+func (i *Inventory) WriteZoneFile(path string, hosts map[string][]*HostAttributes) error {
+	var b strings.Builder
+
+	for host, attrsList := range hosts {
+		for _, attrs := range attrsList {
+			attrString, err := i.RenderAttributes(attrs)
+			if err != nil {
+				return errors.Wrapf(err, "%s: host record rendering failure", host)
+			}
+
+			fmt.Fprintf(&b, "%s. IN TXT %s\n", host, strconv.Quote(attrString))
+		}
+	}
+
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// Benchmark measures the time it takes to acquire all host records and build the inventory, repeating the run several times.
+func (i *Inventory) Benchmark() (*BenchmarkReport, error) {
+	cfg := i.Config
+	log := i.Logger
+
+	iterations := max(cfg.Bench.Iterations, 1)
+	report := &BenchmarkReport{Iterations: iterations, Datasource: cfg.Datasource}
+
+	acquire := make([]time.Duration, 0, iterations)
+	build := make([]time.Duration, 0, iterations)
+	var total time.Duration
+
+	for n := 0; n < iterations; n++ {
+		start := time.Now()
+
+		hosts, err := i.GetHosts()
+		if err != nil {
+			return nil, err
+		}
+		acquired := time.Now()
+
+		i.Tree = NewTree()
+		if err := i.ImportHosts(hosts); err != nil {
+			return nil, err
+		}
+		i.ExportInventory(make(map[string]*AnsibleGroup))
+		built := time.Now()
+
+		acquire = append(acquire, acquired.Sub(start))
+		build = append(build, built.Sub(acquired))
+		total += built.Sub(start)
+
+		report.Hosts = len(hosts)
+		if i.Metadata != nil {
+			report.Records = i.Metadata.Records
+		}
+
+		log.Debugf("benchmark iteration %d: %d hosts in %s", n+1, len(hosts), built.Sub(start))
+	}
+
+	report.Acquire = newBenchmarkStage(acquire)
+	report.Build = newBenchmarkStage(build)
+
+	if average := total / time.Duration(iterations); average > 0 {
+		report.HostsPerSecond = float64(report.Hosts) / average.Seconds()
+	}
+
+	return report, nil
+}
+
+// newBenchmarkStage summarizes the durations of a benchmark stage.
+func newBenchmarkStage(durations []time.Duration) *BenchmarkStage {
+	var sum, least, most time.Duration
+
+	for n, d := range durations {
+		sum += d
+
+		if n == 0 || d < least {
+			least = d
+		}
+
+		if d > most {
+			most = d
+		}
+	}
+
+	return &BenchmarkStage{
+		Min: least.String(),
+		Avg: (sum / time.Duration(max(len(durations), 1))).String(),
+		Max: most.String(),
+	}
+}
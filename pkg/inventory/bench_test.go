@@ -0,0 +1,65 @@
+package inventory
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInventory_GenerateHosts(t *testing.T) {
+	cfg := &Config{Datasource: EtcdDatasourceType}
+	cfg.Etcd.Zones = []string{"infra.local.", "lab.local.", "dev.local."}
+	cfg.Txt.Vars.Separator = ","
+	cfg.Txt.Vars.Equalsign = "="
+	cfg.Bench.Hosts = 100
+	cfg.Bench.Zones = 2
+	cfg.Bench.OS = 1
+	cfg.Bench.Envs = 2
+	cfg.Bench.Roles = 5
+	cfg.Bench.Services = 0
+	cfg.Bench.Vars = 3
+
+	i := &Inventory{Config: cfg}
+
+	hosts, err := i.GenerateHosts()
+	if err != nil {
+		t.Fatalf("Inventory.GenerateHosts() error = %v", err)
+	}
+
+	if len(hosts) != cfg.Bench.Hosts {
+		t.Errorf("Inventory.GenerateHosts() hosts = %d, want %d", len(hosts), cfg.Bench.Hosts)
+	}
+
+	values := map[string]map[string]bool{"os": {}, "env": {}, "role": {}, "srv": {}, "zone": {}}
+	for host, attrsList := range hosts {
+		_, zone, _ := strings.Cut(host, ".")
+		values["zone"][zone] = true
+
+		for _, attrs := range attrsList {
+			values["os"][attrs.OS] = true
+			values["env"][attrs.Env] = true
+			values["role"][attrs.Role] = true
+			values["srv"][attrs.Srv] = true
+
+			if n := len(strings.Split(attrs.Vars, ",")); n != cfg.Bench.Vars {
+				t.Errorf("Inventory.GenerateHosts() %s vars = %d, want %d", host, n, cfg.Bench.Vars)
+			}
+		}
+	}
+
+	want := map[string]int{"os": 1, "env": 2, "role": 5, "srv": 1, "zone": 2}
+	for key, cardinality := range want {
+		if len(values[key]) != cardinality {
+			t.Errorf("Inventory.GenerateHosts() %s cardinality = %d, want %d", key, len(values[key]), cardinality)
+		}
+	}
+
+	again, err := i.GenerateHosts()
+	if err != nil {
+		t.Fatalf("Inventory.GenerateHosts() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(hosts, again) {
+		t.Errorf("Inventory.GenerateHosts() is not deterministic")
+	}
+}
@@ -0,0 +1,294 @@
+package inventory
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Current portable bundle schema version.
+	BundleSchemaVersion int = 1
+
+	// Names of the portable bundle members.
+	bundleManifestFile  string = "manifest.json"
+	bundleRecordsFile   string = "records.json"
+	bundleSignatureFile string = "manifest.sig"
+
+	// Maximum size of a single portable bundle member.
+	bundleMaxMemberSize int64 = 1 << 30
+)
+
+// loadBundleKey reads the base64-encoded bundle signing key.
+func loadBundleKey(cfg *Config) ([]byte, error) {
+	key := cfg.Bundle.Key
+
+	if len(key) == 0 && len(cfg.Bundle.Path) > 0 {
+		data, err := os.ReadFile(cfg.Bundle.Path)
+		if err != nil {
+			return nil, errors.Wrap(err, "bundle signing key loading failure")
+		}
+		key = string(data)
+	}
+
+	if len(strings.TrimSpace(key)) == 0 {
+		return nil, errors.New("bundle signing key is not configured")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid bundle signing key")
+	}
+
+	return data, nil
+}
+
+// signBundle computes the HMAC-SHA256 signature of a bundle manifest.
+func signBundle(key []byte, manifest []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(manifest)
+
+	return mac.Sum(nil)
+}
+
+// recordFormatFingerprint returns the fingerprint of the configuration that defines how host records are parsed.
+// Inventories with the same fingerprint read host records the same way.
+func (i *Inventory) recordFormatFingerprint() string {
+	txt := i.Config.Txt
+
+	format := struct {
+		Separator, Equalsign         string
+		VarsSeparator, VarsEqualsign string
+		Keys                         []string
+		Extra                        []string
+	}{
+		Separator:     txt.Kv.Separator,
+		Equalsign:     txt.Kv.Equalsign,
+		VarsSeparator: txt.Vars.Separator,
+		VarsEqualsign: txt.Vars.Equalsign,
+		Keys:          []string{txt.Keys.Os, txt.Keys.Env, txt.Keys.Role, txt.Keys.Srv, txt.Keys.Vars, txt.Keys.ID},
+		Extra:         txt.Keys.Extra,
+	}
+
+	data, _ := json.Marshal(format)
+	sum := sha256.Sum256(data)
+
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// ExportBundle writes all host records of the datasource to a signed, gzip-compressed tar archive.
+// The archive holds the host records, a manifest describing them and the signature of the manifest, which covers the records via their digest.
+func (i *Inventory) ExportBundle(w io.Writer) (*BundleManifest, error) {
+	cfg := i.Config
+
+	key, err := loadBundleKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	level := cfg.Bundle.Level
+	if level < gzip.BestSpeed || level > gzip.BestCompression {
+		return nil, errors.Errorf("invalid bundle compression level: %d", level)
+	}
+
+	records, err := i.Datasource.GetAllRecords()
+	if err != nil {
+		return nil, errors.Wrap(err, "record loading failure")
+	}
+
+	// Sort records to produce the same bundle for the same records.
+	sort.SliceStable(records, func(a, b int) bool {
+		if records[a].Hostname != records[b].Hostname {
+			return records[a].Hostname < records[b].Hostname
+		}
+		if records[a].Attributes != records[b].Attributes {
+			return records[a].Attributes < records[b].Attributes
+		}
+
+		return records[a].Source < records[b].Source
+	})
+
+	hosts := make(map[string]bool)
+	for _, r := range records {
+		hosts[r.Hostname] = true
+	}
+
+	recordsData, err := json.Marshal(records)
+	if err != nil {
+		return nil, errors.Wrap(err, "record marshalling failure")
+	}
+	digest := sha256.Sum256(recordsData)
+
+	manifest := &BundleManifest{
+		Schema:      BundleSchemaVersion,
+		Version:     Version().Version,
+		Created:     time.Now().UTC().Truncate(time.Second),
+		Datasource:  cfg.Datasource,
+		Fingerprint: i.recordFormatFingerprint(),
+		Records:     len(records),
+		Hosts:       len(hosts),
+		Digest:      "sha256:" + hex.EncodeToString(digest[:]),
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "manifest marshalling failure")
+	}
+
+	gz, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return nil, err
+	}
+	tw := tar.NewWriter(gz)
+
+	members := []struct {
+		name string
+		data []byte
+	}{
+		{bundleManifestFile, manifestData},
+		{bundleSignatureFile, []byte(hex.EncodeToString(signBundle(key, manifestData)))},
+		{bundleRecordsFile, recordsData},
+	}
+
+	for _, m := range members {
+		header := &tar.Header{Name: m.name, Mode: 0o644, Size: int64(len(m.data)), ModTime: manifest.Created, Format: tar.FormatPAX}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, errors.Wrap(err, "bundle write failure")
+		}
+		if _, err := tw.Write(m.data); err != nil {
+			return nil, errors.Wrap(err, "bundle write failure")
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, errors.Wrap(err, "bundle write failure")
+	}
+	if err := gz.Close(); err != nil {
+		return nil, errors.Wrap(err, "bundle write failure")
+	}
+
+	return manifest, nil
+}
+
+// readBundle reads the members of a portable bundle.
+func readBundle(r io.Reader) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "bundle read failure")
+	}
+	defer gz.Close()
+
+	members := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "bundle read failure")
+		}
+
+		switch header.Name {
+		case bundleManifestFile, bundleRecordsFile, bundleSignatureFile:
+		default:
+			return nil, errors.Errorf("unexpected bundle member: %s", header.Name)
+		}
+
+		if _, ok := members[header.Name]; ok {
+			return nil, errors.Errorf("duplicate bundle member: %s", header.Name)
+		}
+
+		if header.Size > bundleMaxMemberSize {
+			return nil, errors.Errorf("bundle member is too large: %s", header.Name)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, errors.Wrap(err, "bundle read failure")
+		}
+
+		members[header.Name] = data
+	}
+
+	for _, name := range []string{bundleManifestFile, bundleRecordsFile, bundleSignatureFile} {
+		if _, ok := members[name]; !ok {
+			return nil, errors.Errorf("missing bundle member: %s", name)
+		}
+	}
+
+	return members, nil
+}
+
+// ImportBundle verifies a portable bundle and publishes its host records via the datasource.
+// Bundles with an invalid signature, an unsupported schema version or a different host record format configuration are rejected.
+// If dryRun is true, the bundle is only verified.
+func (i *Inventory) ImportBundle(r io.Reader, dryRun bool) (*BundleManifest, error) {
+	key, err := loadBundleKey(i.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := readBundle(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the signature before trusting anything else in the bundle.
+	signature, err := hex.DecodeString(strings.TrimSpace(string(members[bundleSignatureFile])))
+	if err != nil || !hmac.Equal(signature, signBundle(key, members[bundleManifestFile])) {
+		return nil, errors.New("invalid bundle signature")
+	}
+
+	manifest := &BundleManifest{}
+	if err := json.Unmarshal(members[bundleManifestFile], manifest); err != nil {
+		return nil, errors.Wrap(err, "manifest parsing failure")
+	}
+
+	if manifest.Schema < 1 || manifest.Schema > BundleSchemaVersion {
+		return nil, errors.Errorf("unsupported bundle schema version: %d", manifest.Schema)
+	}
+
+	if digest := sha256.Sum256(members[bundleRecordsFile]); manifest.Digest != "sha256:"+hex.EncodeToString(digest[:]) {
+		return nil, errors.New("bundle records do not match the manifest digest")
+	}
+
+	if fingerprint := i.recordFormatFingerprint(); manifest.Fingerprint != fingerprint {
+		return nil, errors.Errorf("host record format configuration differs from the one the bundle has been created with: %s, want %s", fingerprint, manifest.Fingerprint)
+	}
+
+	records := make([]*DatasourceRecord, 0)
+	if err := json.Unmarshal(members[bundleRecordsFile], &records); err != nil {
+		return nil, errors.Wrap(err, "record parsing failure")
+	}
+
+	if len(records) != manifest.Records {
+		return nil, errors.Errorf("bundle holds %d host records, manifest lists %d", len(records), manifest.Records)
+	}
+
+	if dryRun {
+		return manifest, nil
+	}
+
+	// Record locations are specific to the source datasource.
+	for _, r := range records {
+		r.Source = ""
+	}
+
+	if err := i.publish("bundle-import", records); err != nil {
+		return nil, errors.Wrap(err, "record publishing failure")
+	}
+
+	return manifest, nil
+}
@@ -0,0 +1,125 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+// testPublishingDatasource is a Datasource keeping the last set of published host records.
+type testPublishingDatasource struct {
+	testDatasource
+	published []*DatasourceRecord
+}
+
+func (d *testPublishingDatasource) PublishRecords(records []*DatasourceRecord) error {
+	d.published = records
+
+	return nil
+}
+
+func TestInventory_ImportBundle(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte("bundle-signing-key"))
+
+	source := newTestInventory(t, false, []*DatasourceRecord{
+		{Hostname: "db01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=db;SRV=postgres;VARS=", Source: "db01.infra.local."},
+		{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS=", Source: "app01.infra.local."},
+		{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=nginx;VARS=", Source: "app01.infra.local."},
+	})
+	source.Config.Bundle.Key = key
+
+	var bundle bytes.Buffer
+	manifest, err := source.ExportBundle(&bundle)
+	if err != nil {
+		t.Fatalf("Inventory.ExportBundle() error = %v", err)
+	}
+
+	if manifest.Records != 3 || manifest.Hosts != 2 || manifest.Schema != BundleSchemaVersion {
+		t.Errorf("Inventory.ExportBundle() = %+v, want 3 records of 2 hosts", manifest)
+	}
+
+	// Records are sorted and published without their source locations.
+	want := []*DatasourceRecord{
+		{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=nginx;VARS="},
+		{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS="},
+		{Hostname: "db01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=db;SRV=postgres;VARS="},
+	}
+
+	tests := []struct {
+		name      string
+		key       string
+		separator string
+		dryRun    bool
+		bundle    []byte
+		want      []*DatasourceRecord
+		wantErr   bool
+	}{
+		{
+			name:   "valid",
+			key:    key,
+			bundle: bundle.Bytes(),
+			want:   want,
+		},
+		{
+			name:   "valid-dry-run",
+			key:    key,
+			dryRun: true,
+			bundle: bundle.Bytes(),
+		},
+		{
+			name:    "invalid-key",
+			key:     base64.StdEncoding.EncodeToString([]byte("other-key")),
+			bundle:  bundle.Bytes(),
+			wantErr: true,
+		},
+		{
+			name:    "invalid-no-key",
+			bundle:  bundle.Bytes(),
+			wantErr: true,
+		},
+		{
+			// Records are parsed differently in the target inventory.
+			name:      "invalid-fingerprint",
+			key:       key,
+			separator: ",",
+			bundle:    bundle.Bytes(),
+			wantErr:   true,
+		},
+		{
+			name:    "invalid-truncated",
+			key:     key,
+			bundle:  bundle.Bytes()[:bundle.Len()/2],
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := newTestInventory(t, false, nil)
+			target.Config.Bundle.Key = tt.key
+			if len(tt.separator) > 0 {
+				target.Config.Txt.Kv.Separator = tt.separator
+			}
+
+			ds := &testPublishingDatasource{}
+			target.Datasource = ds
+
+			got, err := target.ImportBundle(bytes.NewReader(tt.bundle), tt.dryRun)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Inventory.ImportBundle() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(got, manifest) {
+				t.Errorf("Inventory.ImportBundle() = %+v, want %+v", got, manifest)
+			}
+
+			if !reflect.DeepEqual(ds.published, tt.want) {
+				t.Errorf("Inventory.ImportBundle() published %v, want %v", ds.published, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package inventory
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ChaosDatasource wraps a datasource and injects faults into the host records it returns: latency, failed calls, dropped records and malformed records.
+// Faults are chosen by a seeded random generator, so the same configuration and sequence of calls produces the same faults.
+type ChaosDatasource struct {
+	// Wrapped datasource.
+	Datasource
+	// Inventory configuration.
+	Config *Config
+	// Inventory logger.
+	Logger Logger
+
+	// Guards the random generator.
+	mu sync.Mutex
+	// Fault generator.
+	random *rand.Rand
+}
+
+// NewChaosDatasource wraps a datasource into a fault-injecting datasource.
+func NewChaosDatasource(ds Datasource, cfg *Config, log Logger) (*ChaosDatasource, error) {
+	chaos := cfg.Chaos
+
+	for name, rate := range map[string]float64{"failure": chaos.FailureRate, "drop": chaos.DropRate, "malformed": chaos.MalformedRate} {
+		if rate < 0 || rate > 1 {
+			return nil, errors.Errorf("invalid chaos %s rate: %v", name, rate)
+		}
+	}
+
+	log.Warnf("chaos datasource enabled, host records will be unreliable (seed: %d)", chaos.Seed)
+
+	return &ChaosDatasource{
+		Datasource: ds,
+		Config:     cfg,
+		Logger:     log,
+		random:     rand.New(rand.NewSource(chaos.Seed)),
+	}, nil
+}
+
+// roll returns true with the given probability.
+func (c *ChaosDatasource) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.random.Float64() < rate
+}
+
+// delay sleeps for a random duration up to the configured latency.
+func (c *ChaosDatasource) delay() {
+	latency := c.Config.Chaos.Latency
+	if latency <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	d := time.Duration(c.random.Int63n(int64(latency)))
+	c.mu.Unlock()
+
+	time.Sleep(d)
+}
+
+// malform corrupts the attributes of a host record in one of several ways.
+func (c *ChaosDatasource) malform(attrs string) string {
+	cfg := c.Config
+
+	c.mu.Lock()
+	kind := c.random.Intn(3)
+	c.mu.Unlock()
+
+	switch kind {
+	case 0:
+		// Truncated record.
+		return attrs[:len(attrs)/2]
+	case 1:
+		// Missing key/value separators.
+		return strings.ReplaceAll(attrs, cfg.Txt.Kv.Equalsign, "")
+	default:
+		// Invalid characters.
+		return attrs + cfg.Txt.Kv.Separator + cfg.Txt.Keys.Os + cfg.Txt.Kv.Equalsign + "\x00"
+	}
+}
+
+// inject applies faults to the result of a datasource call.
+func (c *ChaosDatasource) inject(call string, records []*DatasourceRecord, err error) ([]*DatasourceRecord, error) {
+	cfg := c.Config
+	log := c.Logger
+
+	c.delay()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if c.roll(cfg.Chaos.FailureRate) {
+		log.Debugf("chaos: failing %s call", call)
+		return nil, errors.Errorf("chaos: injected %s failure", call)
+	}
+
+	result := make([]*DatasourceRecord, 0, len(records))
+	for _, r := range records {
+		if c.roll(cfg.Chaos.DropRate) {
+			log.Debugf("[%s] chaos: dropping host record", r.Hostname)
+			continue
+		}
+
+		if c.roll(cfg.Chaos.MalformedRate) {
+			log.Debugf("[%s] chaos: malforming host record", r.Hostname)
+			r = &DatasourceRecord{Hostname: r.Hostname, Attributes: c.malform(r.Attributes), Source: r.Source}
+		}
+
+		result = append(result, r)
+	}
+
+	return result, nil
+}
+
+// GetAllRecords acquires all available host records from the wrapped datasource and injects faults.
+func (c *ChaosDatasource) GetAllRecords() ([]*DatasourceRecord, error) {
+	records, err := c.Datasource.GetAllRecords()
+
+	return c.inject("GetAllRecords", records, err)
+}
+
+// GetHostRecords acquires all available records for a specific host from the wrapped datasource and injects faults.
+func (c *ChaosDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error) {
+	records, err := c.Datasource.GetHostRecords(host)
+
+	return c.inject("GetHostRecords", records, err)
+}
+
+// Metadata returns the metadata of the wrapped datasource, if it is available.
+func (c *ChaosDatasource) Metadata() map[string]interface{} {
+	if ds, ok := c.Datasource.(DescribedDatasource); ok {
+		return ds.Metadata()
+	}
+
+	return nil
+}
+
+// GetGroupVariables returns the group variables of the wrapped datasource, if it supports them.
+func (c *ChaosDatasource) GetGroupVariables() (map[string]map[string]interface{}, error) {
+	if ds, ok := c.Datasource.(GroupVarsDatasource); ok {
+		c.delay()
+		return ds.GetGroupVariables()
+	}
+
+	return nil, nil
+}
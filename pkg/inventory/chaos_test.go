@@ -0,0 +1,113 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChaosDatasource_GetAllRecords(t *testing.T) {
+	records := []*DatasourceRecord{
+		{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS="},
+		{Hostname: "app02.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS="},
+		{Hostname: "db01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=db;SRV=postgres;VARS="},
+	}
+
+	tests := []struct {
+		name      string
+		failure   float64
+		drop      float64
+		malformed float64
+		latency   time.Duration
+		want      int
+		wantErr   bool
+	}{
+		{
+			name: "valid-passthrough",
+			want: 3,
+		},
+		{
+			name:    "valid-latency",
+			latency: 10 * time.Millisecond,
+			want:    3,
+		},
+		{
+			name: "valid-drop",
+			drop: 1,
+			want: 0,
+		},
+		{
+			name:      "valid-malformed",
+			malformed: 1,
+			want:      3,
+		},
+		{
+			name:    "invalid-failure",
+			failure: 1,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.Txt.Kv.Separator = ";"
+			cfg.Txt.Kv.Equalsign = "="
+			cfg.Txt.Keys.Os = "OS"
+			cfg.Chaos.Seed = 1
+			cfg.Chaos.Latency = tt.latency
+			cfg.Chaos.FailureRate = tt.failure
+			cfg.Chaos.DropRate = tt.drop
+			cfg.Chaos.MalformedRate = tt.malformed
+
+			c, err := NewChaosDatasource(&testDatasource{records: records}, cfg, &testLogger{})
+			if err != nil {
+				t.Fatalf("NewChaosDatasource() error = %v", err)
+			}
+
+			start := time.Now()
+			got, err := c.GetAllRecords()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ChaosDatasource.GetAllRecords() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if elapsed := time.Since(start); elapsed > tt.latency+time.Second {
+				t.Errorf("ChaosDatasource.GetAllRecords() took %s, latency %s", elapsed, tt.latency)
+			}
+			if len(got) != tt.want {
+				t.Errorf("ChaosDatasource.GetAllRecords() = %d records, want %d", len(got), tt.want)
+			}
+
+			for n, r := range got {
+				if malformed := r.Attributes != records[n].Attributes; malformed != (tt.malformed > 0) {
+					t.Errorf("ChaosDatasource.GetAllRecords() record %s = %q, malformed %v", r.Hostname, r.Attributes, tt.malformed > 0)
+				}
+			}
+		})
+	}
+
+	t.Run("valid-seed", func(t *testing.T) {
+		cfg := &Config{}
+		cfg.Txt.Kv.Equalsign = "="
+		cfg.Chaos.Seed = 42
+		cfg.Chaos.DropRate = 0.5
+		cfg.Chaos.MalformedRate = 0.5
+
+		results := make([][]*DatasourceRecord, 0)
+		for n := 0; n < 2; n++ {
+			c, err := NewChaosDatasource(&testDatasource{records: records}, cfg, &testLogger{})
+			if err != nil {
+				t.Fatalf("NewChaosDatasource() error = %v", err)
+			}
+
+			got, err := c.GetAllRecords()
+			if err != nil {
+				t.Fatalf("ChaosDatasource.GetAllRecords() error = %v", err)
+			}
+			results = append(results, got)
+		}
+
+		if !reflect.DeepEqual(results[0], results[1]) {
+			t.Errorf("ChaosDatasource.GetAllRecords() faults differ for the same seed: %v, %v", results[0], results[1])
+		}
+	})
+}
@@ -0,0 +1,755 @@
+package inventory
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Cloud datasource type.
+	CloudDatasourceType string = "cloud"
+
+	// Cloud providers.
+	cloudAWSProvider   string = "aws"
+	cloudGCPProvider   string = "gcp"
+	cloudAzureProvider string = "azure"
+
+	// Access tokens are renewed this long before they expire.
+	cloudTokenExpiryWindow time.Duration = 5 * time.Minute
+
+	// EC2 API version.
+	ec2APIVersion string = "2016-11-15"
+	// OAuth scope of GCP access tokens.
+	gcpComputeScope string = "https://www.googleapis.com/auth/compute.readonly"
+	// GCP OAuth token endpoint used if the service account key does not specify one.
+	gcpTokenURI string = "https://oauth2.googleapis.com/token"
+	// Azure Compute API version.
+	azureComputeAPIVersion string = "2024-03-01"
+)
+
+type (
+	// CloudDatasource implements a read-only datasource listing the instances of cloud providers (AWS EC2, GCP Compute Engine and Azure virtual machines)
+	// and converting their tags into host records, so that instances appear in the inventory even before their DNS records are created.
+	CloudDatasource struct {
+		// Inventory configuration.
+		Config *Config
+		// Inventory logger.
+		Logger Logger
+		// HTTP client used for GCP and Azure requests.
+		Client *http.Client
+
+		// AWS API client.
+		aws *awsClient
+		// Parsed tag filters.
+		filters []cloudFilter
+		// GCP service account key, nil if the metadata server is used.
+		gcpKey *gcpServiceAccount
+		// GCP and Azure access tokens.
+		gcpToken   *cloudToken
+		azureToken *cloudToken
+	}
+
+	// cloudFilter represents a tag filter.
+	cloudFilter struct {
+		Key   string
+		Value string
+		// Match any value of the tag.
+		Any bool
+	}
+
+	// cloudInstance represents an instance listed by a cloud provider.
+	cloudInstance struct {
+		// Hostname used if the hostname tag is not configured or missing.
+		Name string
+		// Instance tags or labels.
+		Tags map[string]string
+		// The instance is running.
+		Running bool
+		// Instance location used as the host record source.
+		Source string
+	}
+
+	// cloudToken caches an OAuth access token.
+	cloudToken struct {
+		// Guards the token.
+		mu sync.Mutex
+		// Current token, empty until the first request.
+		value string
+		// Expiration time of the token.
+		expires time.Time
+		// Acquires a new token and returns it with its lifetime.
+		fetch func(ctx context.Context) (string, time.Duration, error)
+	}
+
+	// oauthToken represents an OAuth token endpoint response. Azure managed identity tokens have their lifetime encoded as a string.
+	oauthToken struct {
+		AccessToken string      `json:"access_token"`
+		ExpiresIn   json.Number `json:"expires_in"`
+	}
+
+	// ec2Instances represents a DescribeInstances response.
+	ec2Instances struct {
+		Reservations []struct {
+			Instances []struct {
+				ID             string `xml:"instanceId"`
+				PrivateDNSName string `xml:"privateDnsName"`
+				State          string `xml:"instanceState>name"`
+				Tags           []struct {
+					Key   string `xml:"key"`
+					Value string `xml:"value"`
+				} `xml:"tagSet>item"`
+			} `xml:"instancesSet>item"`
+		} `xml:"reservationSet>item"`
+		NextToken string `xml:"nextToken"`
+	}
+
+	// gcpServiceAccount represents a GCP service account key file.
+	gcpServiceAccount struct {
+		Type         string `json:"type"`
+		ProjectID    string `json:"project_id"`
+		PrivateKeyID string `json:"private_key_id"`
+		PrivateKey   string `json:"private_key"`
+		ClientEmail  string `json:"client_email"`
+		TokenURI     string `json:"token_uri"`
+
+		// Parsed private key.
+		key *rsa.PrivateKey
+	}
+
+	// gcpInstances represents an aggregated list of Compute Engine instances.
+	gcpInstances struct {
+		Items map[string]struct {
+			Instances []struct {
+				Name     string            `json:"name"`
+				Hostname string            `json:"hostname"`
+				Zone     string            `json:"zone"`
+				Status   string            `json:"status"`
+				Labels   map[string]string `json:"labels"`
+			} `json:"instances"`
+		} `json:"items"`
+		NextPageToken string `json:"nextPageToken"`
+	}
+
+	// azureMachines represents a list of Azure virtual machines with their instance views.
+	azureMachines struct {
+		Value []struct {
+			ID         string            `json:"id"`
+			Name       string            `json:"name"`
+			Tags       map[string]string `json:"tags"`
+			Properties struct {
+				OSProfile struct {
+					ComputerName string `json:"computerName"`
+				} `json:"osProfile"`
+				InstanceView struct {
+					Statuses []struct {
+						Code string `json:"code"`
+					} `json:"statuses"`
+				} `json:"instanceView"`
+			} `json:"properties"`
+		} `json:"value"`
+		NextLink string `json:"nextLink"`
+	}
+)
+
+// get returns the current access token, renewing it if it is about to expire.
+func (t *cloudToken) get(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.value) == 0 || time.Now().Add(cloudTokenExpiryWindow).After(t.expires) {
+		value, lifetime, err := t.fetch(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		t.value, t.expires = value, time.Now().Add(lifetime)
+	}
+
+	return t.value, nil
+}
+
+// match checks if instance tags match all tag filters.
+func (c *CloudDatasource) match(tags map[string]string) bool {
+	for _, f := range c.filters {
+		value, ok := tags[f.Key]
+		if !ok || !f.Any && value != f.Value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// send performs a GCP or Azure API request and decodes the JSON response into 'result'.
+func (c *CloudDatasource) send(req *http.Request, result interface{}) error {
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		// OAuth errors are strings with a description, API errors are objects with a message.
+		var body struct {
+			Error       json.RawMessage `json:"error"`
+			Description string          `json:"error_description"`
+		}
+		if json.Unmarshal(data, &body) != nil || len(body.Error) == 0 {
+			return errors.New(resp.Status)
+		}
+
+		var apiError struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body.Error, &apiError) == nil {
+			return errors.Errorf("%s: %s", resp.Status, apiError.Message)
+		}
+
+		var code string
+		json.Unmarshal(body.Error, &code)
+
+		return errors.Errorf("%s: %s: %s", resp.Status, code, body.Description)
+	}
+
+	if err := json.Unmarshal(data, result); err != nil {
+		return errors.Wrap(err, "response parsing failure")
+	}
+
+	return nil
+}
+
+// get performs an authenticated GCP or Azure API GET request.
+func (c *CloudDatasource) get(ctx context.Context, endpoint string, token *cloudToken, result interface{}) error {
+	value, err := token.get(ctx)
+	if err != nil {
+		return errors.Wrap(err, "access token request failure")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+value)
+
+	return c.send(req, result)
+}
+
+// token performs an OAuth token request and returns the access token with its lifetime.
+func (c *CloudDatasource) token(req *http.Request) (string, time.Duration, error) {
+	result := &oauthToken{}
+	if err := c.send(req, result); err != nil {
+		return "", 0, err
+	}
+
+	if len(result.AccessToken) == 0 {
+		return "", 0, errors.New("no access token returned")
+	}
+
+	seconds, err := result.ExpiresIn.Int64()
+	if err != nil {
+		return "", 0, errors.Wrap(err, "invalid access token lifetime")
+	}
+
+	return result.AccessToken, time.Duration(seconds) * time.Second, nil
+}
+
+// listAWS lists EC2 instances in all configured regions. Instances that are shutting down or terminated are not listed.
+func (c *CloudDatasource) listAWS(ctx context.Context) ([]*cloudInstance, error) {
+	cfg := c.Config
+
+	regions := cfg.Cloud.AWS.Regions
+	if len(regions) == 0 {
+		regions = []string{cfg.AWS.Region}
+	}
+
+	// Tag filters are applied by EC2 as well to reduce the size of responses.
+	query := url.Values{"Action": {"DescribeInstances"}, "Version": {ec2APIVersion}, "MaxResults": {"1000"}}
+	for n, f := range c.filters {
+		prefix := fmt.Sprintf("Filter.%d.", n+1)
+		if f.Any {
+			query.Set(prefix+"Name", "tag-key")
+			query.Set(prefix+"Value.1", f.Key)
+		} else {
+			query.Set(prefix+"Name", "tag:"+f.Key)
+			query.Set(prefix+"Value.1", f.Value)
+		}
+	}
+
+	instances := make([]*cloudInstance, 0)
+	for _, region := range regions {
+		endpoint := cfg.Cloud.AWS.Endpoint
+		if len(endpoint) == 0 {
+			endpoint = "https://ec2." + region + ".amazonaws.com"
+		}
+
+		query.Del("NextToken")
+		for {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			// Parameters are escaped the same way they are in the signature.
+			params := make([]string, 0, len(query))
+			for key := range query {
+				params = append(params, awsEscape(key)+"="+awsEscape(query.Get(key)))
+			}
+			sort.Strings(params)
+
+			data, err := c.aws.do(http.MethodGet, strings.TrimSuffix(endpoint, "/")+"/?"+strings.Join(params, "&"), region, "ec2", nil)
+			if err != nil {
+				return nil, errors.Wrapf(err, "ec2 %s: DescribeInstances failure", region)
+			}
+
+			page := &ec2Instances{}
+			if err := xml.Unmarshal(data, page); err != nil {
+				return nil, errors.Wrapf(err, "ec2 %s: response parsing failure", region)
+			}
+
+			for _, r := range page.Reservations {
+				for _, i := range r.Instances {
+					if i.State == "shutting-down" || i.State == "terminated" {
+						continue
+					}
+
+					tags := make(map[string]string)
+					for _, t := range i.Tags {
+						tags[t.Key] = t.Value
+					}
+
+					instances = append(instances, &cloudInstance{Name: i.PrivateDNSName, Tags: tags, Running: i.State == "running", Source: "aws/" + region + "/" + i.ID})
+				}
+			}
+
+			if len(page.NextToken) == 0 {
+				break
+			}
+			query.Set("NextToken", page.NextToken)
+		}
+	}
+
+	return instances, nil
+}
+
+// fetchGCPToken acquires a GCP access token with a signed service account assertion or from the metadata server.
+func (c *CloudDatasource) fetchGCPToken(ctx context.Context) (string, time.Duration, error) {
+	cfg := c.Config
+
+	if c.gcpKey == nil {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(cfg.Cloud.GCP.MetadataEndpoint, "/")+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+		if err != nil {
+			return "", 0, err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+
+		return c.token(req)
+	}
+
+	key := c.gcpKey
+	tokenURI := key.TokenURI
+	if len(tokenURI) == 0 {
+		tokenURI = gcpTokenURI
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": key.PrivateKeyID})
+	claims, _ := json.Marshal(map[string]interface{}{"iss": key.ClientEmail, "scope": gcpComputeScope, "aud": tokenURI, "iat": now.Unix(), "exp": now.Add(time.Hour).Unix()})
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", 0, errors.Wrap(err, "service account assertion signing failure")
+	}
+
+	form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {signed + "." + base64.RawURLEncoding.EncodeToString(signature)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return c.token(req)
+}
+
+// gcpProjects returns the GCP projects to list instances in.
+func (c *CloudDatasource) gcpProjects(ctx context.Context) ([]string, error) {
+	cfg := c.Config
+
+	if len(cfg.Cloud.GCP.Projects) > 0 {
+		return cfg.Cloud.GCP.Projects, nil
+	}
+
+	if c.gcpKey != nil && len(c.gcpKey.ProjectID) > 0 {
+		return []string{c.gcpKey.ProjectID}, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(cfg.Cloud.GCP.MetadataEndpoint, "/")+"/computeMetadata/v1/project/project-id", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "project lookup failure")
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil || resp.StatusCode != http.StatusOK || len(data) == 0 {
+		return nil, errors.Errorf("project lookup failure: %s", resp.Status)
+	}
+
+	return []string{strings.TrimSpace(string(data))}, nil
+}
+
+// listGCP lists Compute Engine instances in all zones of the configured projects.
+func (c *CloudDatasource) listGCP(ctx context.Context) ([]*cloudInstance, error) {
+	cfg := c.Config
+
+	projects, err := c.gcpProjects(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "gcp")
+	}
+
+	instances := make([]*cloudInstance, 0)
+	for _, project := range projects {
+		next := ""
+		for {
+			endpoint := strings.TrimSuffix(cfg.Cloud.GCP.Endpoint, "/") + "/compute/v1/projects/" + url.PathEscape(project) + "/aggregated/instances"
+			if len(next) > 0 {
+				endpoint += "?pageToken=" + url.QueryEscape(next)
+			}
+
+			page := &gcpInstances{}
+			if err := c.get(ctx, endpoint, c.gcpToken, page); err != nil {
+				return nil, errors.Wrapf(err, "gcp %s: instance list failure", project)
+			}
+
+			for _, scope := range page.Items {
+				for _, i := range scope.Instances {
+					zone := i.Zone[strings.LastIndex(i.Zone, "/")+1:]
+
+					name := i.Hostname
+					if len(name) == 0 {
+						name = i.Name
+					}
+
+					instances = append(instances, &cloudInstance{Name: name, Tags: i.Labels, Running: i.Status == "RUNNING", Source: "gcp/" + project + "/" + zone + "/" + i.Name})
+				}
+			}
+
+			if next = page.NextPageToken; len(next) == 0 {
+				break
+			}
+		}
+	}
+
+	return instances, nil
+}
+
+// fetchAzureToken acquires an Azure Resource Manager access token for a service principal or the managed identity of the virtual machine.
+func (c *CloudDatasource) fetchAzureToken(ctx context.Context) (string, time.Duration, error) {
+	cfg := c.Config
+	resource := strings.TrimSuffix(cfg.Cloud.Azure.Endpoint, "/")
+
+	if len(cfg.Cloud.Azure.ClientID) == 0 {
+		query := url.Values{"api-version": {"2018-02-01"}, "resource": {resource + "/"}}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(cfg.Cloud.Azure.IMDSEndpoint, "/")+"/metadata/identity/oauth2/token?"+query.Encode(), nil)
+		if err != nil {
+			return "", 0, err
+		}
+		req.Header.Set("Metadata", "true")
+
+		return c.token(req)
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {cfg.Cloud.Azure.ClientID},
+		"client_secret": {cfg.Cloud.Azure.ClientSecret},
+		"scope":         {resource + "/.default"},
+	}
+	endpoint := strings.TrimSuffix(cfg.Cloud.Azure.LoginEndpoint, "/") + "/" + url.PathEscape(cfg.Cloud.Azure.TenantID) + "/oauth2/v2.0/token"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return c.token(req)
+}
+
+// listAzure lists the virtual machines of the configured subscriptions.
+func (c *CloudDatasource) listAzure(ctx context.Context) ([]*cloudInstance, error) {
+	cfg := c.Config
+
+	instances := make([]*cloudInstance, 0)
+	for _, subscription := range cfg.Cloud.Azure.Subscriptions {
+		// The instance view holding the power state is only returned with 'statusOnly'.
+		query := url.Values{"api-version": {azureComputeAPIVersion}, "statusOnly": {"true"}}
+		endpoint := strings.TrimSuffix(cfg.Cloud.Azure.Endpoint, "/") + "/subscriptions/" + url.PathEscape(subscription) + "/providers/Microsoft.Compute/virtualMachines?" + query.Encode()
+
+		for len(endpoint) > 0 {
+			page := &azureMachines{}
+			if err := c.get(ctx, endpoint, c.azureToken, page); err != nil {
+				return nil, errors.Wrapf(err, "azure %s: virtual machine list failure", subscription)
+			}
+
+			for _, vm := range page.Value {
+				// Virtual machines without a power state are assumed to be running.
+				running := true
+				for _, s := range vm.Properties.InstanceView.Statuses {
+					if strings.HasPrefix(s.Code, "PowerState/") {
+						running = s.Code == "PowerState/running"
+					}
+				}
+
+				name := vm.Properties.OSProfile.ComputerName
+				if len(name) == 0 {
+					name = vm.Name
+				}
+
+				instances = append(instances, &cloudInstance{Name: name, Tags: vm.Tags, Running: running, Source: "azure" + strings.ToLower(vm.ID)})
+			}
+
+			endpoint = page.NextLink
+		}
+	}
+
+	return instances, nil
+}
+
+// list lists the instances of all configured cloud providers.
+func (c *CloudDatasource) list() ([]*cloudInstance, error) {
+	cfg := c.Config
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Cloud.Timeout)
+	defer cancel()
+
+	instances := make([]*cloudInstance, 0)
+	for _, provider := range cfg.Cloud.Providers {
+		var list []*cloudInstance
+		var err error
+
+		switch provider {
+		case cloudAWSProvider:
+			list, err = c.listAWS(ctx)
+		case cloudGCPProvider:
+			list, err = c.listGCP(ctx)
+		case cloudAzureProvider:
+			list, err = c.listAzure(ctx)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		instances = append(instances, list...)
+	}
+
+	return instances, nil
+}
+
+// hostname returns the hostname of an instance: the value of the hostname tag or the name provided by the cloud provider, qualified with the configured domain.
+func (c *CloudDatasource) hostname(instance *cloudInstance) string {
+	cfg := c.Config
+
+	name := instance.Name
+	if value, ok := instance.Tags[cfg.Cloud.Hostname]; ok && len(cfg.Cloud.Hostname) > 0 && len(value) > 0 {
+		name = value
+	}
+
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if len(name) > 0 && len(cfg.Cloud.Domain) > 0 && !strings.Contains(name, ".") {
+		name += "." + strings.Trim(strings.ToLower(cfg.Cloud.Domain), ".")
+	}
+
+	return name
+}
+
+// GetAllRecords acquires all available host records. Every instance matching the tag filters is converted into a single host record.
+func (c *CloudDatasource) GetAllRecords() ([]*DatasourceRecord, error) {
+	cfg := c.Config
+	log := c.Logger
+
+	instances, err := c.list()
+	if err != nil {
+		return nil, errors.Wrap(err, "cloud datasource failure")
+	}
+
+	records := make([]*DatasourceRecord, 0)
+	for _, instance := range instances {
+		if !c.match(instance.Tags) || !instance.Running && !cfg.Cloud.Stopped {
+			continue
+		}
+
+		host := c.hostname(instance)
+		if len(host) == 0 {
+			log.Warnf("skipping cloud instance without a hostname: %s", instance.Source)
+			continue
+		}
+
+		attrs, err := mappedAttributes(cfg, func(key string) string {
+			return instance.Tags[lookupFold(cfg.Cloud.Tags, key)]
+		}, cfg.Cloud.Defaults)
+		if err != nil {
+			log.Warnf("skipping cloud instance: %s: %v", instance.Source, err)
+			continue
+		}
+
+		records = append(records, &DatasourceRecord{Hostname: host, Attributes: attrs, Source: instance.Source})
+	}
+
+	return records, nil
+}
+
+// GetHostRecords returns the records of the instances named after a specific host in every configured provider.
+// All instances are listed, so this is as expensive as acquiring all records.
+func (c *CloudDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error) {
+	all, err := c.GetAllRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*DatasourceRecord, 0)
+	for _, r := range all {
+		if strings.EqualFold(r.Hostname, host) {
+			records = append(records, r)
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, errors.Wrap(ErrNoHostRecords, host)
+	}
+
+	return records, nil
+}
+
+// PublishRecords is not supported: instance tags are expected to be managed with the cloud provider tools.
+func (c *CloudDatasource) PublishRecords(records []*DatasourceRecord) error {
+	return errors.New("publishing records is not supported by the cloud datasource")
+}
+
+// Close closes idle connections to the cloud provider APIs, including the separately signed AWS client.
+func (c *CloudDatasource) Close() {
+	c.Client.CloseIdleConnections()
+	c.aws.client.CloseIdleConnections()
+}
+
+// loadGCPKey reads a GCP service account key file.
+func loadGCPKey(path string) (*gcpServiceAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &gcpServiceAccount{}
+	if err := json.Unmarshal(data, key); err != nil {
+		return nil, errors.Wrap(err, "key file parsing failure")
+	}
+
+	if key.Type != "service_account" || len(key.ClientEmail) == 0 {
+		return nil, errors.New("not a service account key file")
+	}
+
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return nil, errors.New("no private key found")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, errors.Wrap(err, "private key parsing failure")
+		}
+	}
+
+	var ok bool
+	if key.key, ok = parsed.(*rsa.PrivateKey); !ok {
+		return nil, errors.New("not an RSA private key")
+	}
+
+	return key, nil
+}
+
+// NewCloudDatasource creates a cloud datasource. Credentials are acquired on the first request.
+func NewCloudDatasource(cfg *Config, log Logger) (*CloudDatasource, error) {
+	c := &CloudDatasource{
+		Config: cfg,
+		Logger: log,
+		Client: &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}},
+		aws:    newAWSClient(cfg, cfg.Cloud.Timeout),
+	}
+	c.gcpToken = &cloudToken{fetch: c.fetchGCPToken}
+	c.azureToken = &cloudToken{fetch: c.fetchAzureToken}
+
+	if len(cfg.Cloud.Providers) == 0 {
+		return nil, errors.New("cloud datasource initialization failure: no cloud providers configured")
+	}
+
+	for _, provider := range cfg.Cloud.Providers {
+		switch provider {
+		case cloudAWSProvider:
+		case cloudGCPProvider:
+			path := cfg.Cloud.GCP.Credentials
+			if len(path) == 0 {
+				path = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+			}
+
+			if len(path) > 0 {
+				key, err := loadGCPKey(path)
+				if err != nil {
+					return nil, errors.Wrapf(err, "cloud datasource initialization failure: gcp service account key: %s", path)
+				}
+				c.gcpKey = key
+			}
+		case cloudAzureProvider:
+			if len(cfg.Cloud.Azure.Subscriptions) == 0 {
+				return nil, errors.New("cloud datasource initialization failure: no azure subscriptions configured")
+			}
+
+			if len(cfg.Cloud.Azure.ClientID) > 0 && len(cfg.Cloud.Azure.TenantID) == 0 {
+				return nil, errors.New("cloud datasource initialization failure: azure tenant ID is not set")
+			}
+		default:
+			return nil, errors.Errorf("cloud datasource initialization failure: unknown cloud provider: %s", provider)
+		}
+	}
+
+	for _, filter := range cfg.Cloud.Filters {
+		key, value, ok := strings.Cut(filter, "=")
+		if len(key) == 0 {
+			return nil, errors.Errorf("cloud datasource initialization failure: invalid tag filter: %s", filter)
+		}
+
+		c.filters = append(c.filters, cloudFilter{Key: key, Value: value, Any: !ok})
+	}
+
+	return c, nil
+}
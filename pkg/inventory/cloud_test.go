@@ -0,0 +1,323 @@
+package inventory
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCloud emulates the EC2, Compute Engine and Azure Resource Manager APIs, the GCP and Azure token endpoints and the GCP metadata server.
+// GCP service account assertions are verified with 'key'.
+func testCloud(t *testing.T, key *rsa.PublicKey) string {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Get("Action") == "DescribeInstances":
+			if !strings.HasPrefix(r.Header.Get("Authorization"), awsSigningAlgorithm+" Credential=AKIDEXAMPLE/") {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			// Instances are returned regardless of the tag filter, as it is applied by the client as well, in two pages.
+			if r.URL.Query().Get("Filter.1.Name") != "tag-key" || r.URL.Query().Get("Filter.1.Value.1") != "Inventory" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			if r.URL.Query().Get("NextToken") != "page2" {
+				fmt.Fprint(w, `<DescribeInstancesResponse><reservationSet><item><instancesSet>
+<item><instanceId>i-1</instanceId><privateDnsName>ip-10-0-0-1.ec2.internal</privateDnsName><instanceState><name>running</name></instanceState>
+<tagSet><item><key>Inventory</key><value>true</value></item><item><key>Name</key><value>App01</value></item><item><key>env</key><value>dev</value></item><item><key>role</key><value>app</value></item></tagSet></item>
+<item><instanceId>i-2</instanceId><privateDnsName>ip-10-0-0-2.ec2.internal</privateDnsName><instanceState><name>stopped</name></instanceState>
+<tagSet><item><key>Inventory</key><value>true</value></item><item><key>env</key><value>prod</value></item><item><key>role</key><value>db</value></item></tagSet></item>
+</instancesSet></item></reservationSet><nextToken>page2</nextToken></DescribeInstancesResponse>`)
+				return
+			}
+
+			fmt.Fprint(w, `<DescribeInstancesResponse><reservationSet><item><instancesSet>
+<item><instanceId>i-3</instanceId><privateDnsName></privateDnsName><instanceState><name>terminated</name></instanceState>
+<tagSet><item><key>Inventory</key><value>true</value></item></tagSet></item>
+<item><instanceId>i-4</instanceId><privateDnsName>ip-10-0-0-4.ec2.internal</privateDnsName><instanceState><name>running</name></instanceState>
+<tagSet><item><key>Name</key><value>web01</value></item><item><key>role</key><value>web</value></item></tagSet></item>
+</instancesSet></item></reservationSet></DescribeInstancesResponse>`)
+		case r.URL.Path == "/token":
+			r.ParseForm()
+
+			parts := strings.Split(r.PostForm.Get("assertion"), ".")
+			if len(parts) != 3 {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error": "invalid_grant", "error_description": "malformed assertion"}`)
+				return
+			}
+
+			signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error": "invalid_grant", "error_description": "invalid signature"}`)
+				return
+			}
+
+			fmt.Fprint(w, `{"access_token": "gcp-token", "expires_in": 3600, "token_type": "Bearer"}`)
+		case r.URL.Path == "/computeMetadata/v1/instance/service-accounts/default/token":
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			fmt.Fprint(w, `{"access_token": "gcp-token", "expires_in": 3600, "token_type": "Bearer"}`)
+		case r.URL.Path == "/computeMetadata/v1/project/project-id":
+			fmt.Fprint(w, "infra-prod")
+		case strings.HasPrefix(r.URL.Path, "/compute/v1/projects/"):
+			if r.Header.Get("Authorization") != "Bearer gcp-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `{"error": {"code": 401, "message": "Request is missing required authentication credential."}}`)
+				return
+			}
+
+			if r.URL.Path != "/compute/v1/projects/infra-prod/aggregated/instances" {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"error": {"code": 404, "message": "The resource was not found"}}`)
+				return
+			}
+
+			fmt.Fprint(w, `{"items": {
+"zones/europe-west1-b": {"instances": [
+  {"name": "app02", "zone": "https://www.googleapis.com/compute/v1/projects/infra-prod/zones/europe-west1-b", "status": "RUNNING", "labels": {"inventory": "true", "env": "prod", "role": "app"}},
+  {"name": "db02", "hostname": "db02.gcp.infra.local", "zone": "https://www.googleapis.com/compute/v1/projects/infra-prod/zones/europe-west1-b", "status": "TERMINATED", "labels": {"inventory": "true", "env": "prod", "role": "db"}}
+]},
+"zones/europe-west1-c": {"warning": {"code": "NO_RESULTS_ON_PAGE"}}
+}}`)
+		case r.URL.Path == "/metadata/identity/oauth2/token":
+			if r.Header.Get("Metadata") != "true" || r.URL.Query().Get("resource") != server.URL+"/" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			fmt.Fprint(w, `{"access_token": "azure-token", "expires_in": "86399", "token_type": "Bearer"}`)
+		case r.URL.Path == "/tenant/oauth2/v2.0/token":
+			r.ParseForm()
+
+			if r.PostForm.Get("client_secret") != "secret" || r.PostForm.Get("scope") != server.URL+"/.default" {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `{"error": "invalid_client", "error_description": "AADSTS7000215: Invalid client secret provided."}`)
+				return
+			}
+
+			fmt.Fprint(w, `{"access_token": "azure-token", "expires_in": 3599, "token_type": "Bearer"}`)
+		case r.URL.Path == "/subscriptions/sub1/providers/Microsoft.Compute/virtualMachines":
+			if r.Header.Get("Authorization") != "Bearer azure-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `{"error": {"code": "AuthenticationFailed", "message": "Authentication failed."}}`)
+				return
+			}
+
+			if len(r.URL.Query().Get("page")) == 0 {
+				fmt.Fprintf(w, `{"value": [
+  {"id": "/subscriptions/sub1/resourceGroups/Infra/providers/Microsoft.Compute/virtualMachines/app03", "name": "app03", "tags": {"inventory": "true", "env": "dev", "role": "app"},
+   "properties": {"osProfile": {"computerName": "app03"}, "instanceView": {"statuses": [{"code": "ProvisioningState/succeeded"}, {"code": "PowerState/running"}]}}}
+], "nextLink": "%s/subscriptions/sub1/providers/Microsoft.Compute/virtualMachines?page=2"}`, server.URL)
+				return
+			}
+
+			fmt.Fprint(w, `{"value": [
+  {"id": "/subscriptions/sub1/resourceGroups/Infra/providers/Microsoft.Compute/virtualMachines/db03", "name": "db03", "tags": {"inventory": "true", "env": "prod", "role": "db"},
+   "properties": {"instanceView": {"statuses": [{"code": "PowerState/deallocated"}]}}}
+]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+// testGCPKey writes a GCP service account key file for an RSA key and returns its path.
+func testGCPKey(t *testing.T, key *rsa.PrivateKey, tokenURI string) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "infra-prod",
+		"private_key_id": "k1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "inventory@infra-prod.iam.gserviceaccount.com",
+		"token_uri":      tokenURI,
+	})
+
+	path := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestCloudDatasource_GetAllRecords(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		providers []string
+		filters   []string
+		stopped   bool
+		gcpKey    bool
+		azureSP   bool
+		secret    string
+		want      []string
+		wantErr   bool
+	}{
+		{
+			// Instances without the filter tag are not listed, the Name tag and the private DNS name are used as hostnames.
+			name:      "valid-aws",
+			providers: []string{"aws"},
+			filters:   []string{"Inventory"},
+			want: []string{
+				"app01.infra.local OS=linux;ENV=dev;ROLE=app;SRV=;VARS= aws/eu-west-1/i-1",
+			},
+		},
+		{
+			name:      "valid-aws-stopped",
+			providers: []string{"aws"},
+			filters:   []string{"Inventory"},
+			stopped:   true,
+			want: []string{
+				"app01.infra.local OS=linux;ENV=dev;ROLE=app;SRV=;VARS= aws/eu-west-1/i-1",
+				"ip-10-0-0-2.ec2.internal OS=linux;ENV=prod;ROLE=db;SRV=;VARS= aws/eu-west-1/i-2",
+			},
+		},
+		{
+			name:      "valid-gcp-metadata",
+			providers: []string{"gcp"},
+			filters:   []string{"inventory=true"},
+			stopped:   true,
+			want: []string{
+				"app02.infra.local OS=linux;ENV=prod;ROLE=app;SRV=;VARS= gcp/infra-prod/europe-west1-b/app02",
+				"db02.gcp.infra.local OS=linux;ENV=prod;ROLE=db;SRV=;VARS= gcp/infra-prod/europe-west1-b/db02",
+			},
+		},
+		{
+			name:      "valid-gcp-key",
+			providers: []string{"gcp"},
+			gcpKey:    true,
+			want: []string{
+				"app02.infra.local OS=linux;ENV=prod;ROLE=app;SRV=;VARS= gcp/infra-prod/europe-west1-b/app02",
+			},
+		},
+		{
+			name:      "valid-azure-managed-identity",
+			providers: []string{"azure"},
+			filters:   []string{"inventory=true"},
+			stopped:   true,
+			want: []string{
+				"app03.infra.local OS=linux;ENV=dev;ROLE=app;SRV=;VARS= azure/subscriptions/sub1/resourcegroups/infra/providers/microsoft.compute/virtualmachines/app03",
+				"db03.infra.local OS=linux;ENV=prod;ROLE=db;SRV=;VARS= azure/subscriptions/sub1/resourcegroups/infra/providers/microsoft.compute/virtualmachines/db03",
+			},
+		},
+		{
+			name:      "valid-all",
+			providers: []string{"aws", "gcp", "azure"},
+			filters:   []string{"Inventory"},
+			azureSP:   true,
+			secret:    "secret",
+			want: []string{
+				"app01.infra.local OS=linux;ENV=dev;ROLE=app;SRV=;VARS= aws/eu-west-1/i-1",
+			},
+		},
+		{
+			name:      "invalid-azure-secret",
+			providers: []string{"azure"},
+			azureSP:   true,
+			secret:    "wrong",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint := testCloud(t, &key.PublicKey)
+
+			cfg := &Config{}
+			cfg.Txt.Keys.Os = "OS"
+			cfg.Txt.Keys.Env = "ENV"
+			cfg.Txt.Keys.Role = "ROLE"
+			cfg.Txt.Keys.Srv = "SRV"
+			cfg.Txt.Keys.Vars = "VARS"
+			cfg.Txt.Keys.ID = "ID"
+			cfg.Txt.Kv.Separator = ";"
+			cfg.Txt.Kv.Equalsign = "="
+			cfg.AWS.Region = "eu-west-1"
+			cfg.AWS.AccessKeyID = "AKIDEXAMPLE"
+			cfg.AWS.SecretAccessKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+			cfg.Cloud.Providers = tt.providers
+			cfg.Cloud.Filters = tt.filters
+			cfg.Cloud.Stopped = tt.stopped
+			cfg.Cloud.Hostname = "Name"
+			cfg.Cloud.Domain = "infra.local."
+			cfg.Cloud.Tags = map[string]string{"env": "env", "role": "role"}
+			cfg.Cloud.Defaults = map[string]string{"os": "linux"}
+			cfg.Cloud.Timeout = 5 * time.Second
+			cfg.Cloud.AWS.Endpoint = endpoint
+			cfg.Cloud.GCP.Endpoint = endpoint
+			cfg.Cloud.GCP.MetadataEndpoint = endpoint
+			cfg.Cloud.Azure.Subscriptions = []string{"sub1"}
+			cfg.Cloud.Azure.Endpoint = endpoint
+			cfg.Cloud.Azure.LoginEndpoint = endpoint
+			cfg.Cloud.Azure.IMDSEndpoint = endpoint
+
+			if tt.gcpKey {
+				cfg.Cloud.GCP.Credentials = testGCPKey(t, key, endpoint+"/token")
+			}
+			if tt.azureSP {
+				cfg.Cloud.Azure.TenantID = "tenant"
+				cfg.Cloud.Azure.ClientID = "inventory"
+				cfg.Cloud.Azure.ClientSecret = tt.secret
+			}
+
+			c, err := NewCloudDatasource(cfg, &testLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			records, err := c.GetAllRecords()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CloudDatasource.GetAllRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			got := make([]string, 0, len(records))
+			for _, r := range records {
+				got = append(got, r.Hostname+" "+r.Attributes+" "+r.Source)
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CloudDatasource.GetAllRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Cloudflare datasource type.
+	CloudflareDatasourceType string = "cloudflare"
+	// TTL value of Cloudflare records with the automatic TTL.
+	cloudflareAutoTTL uint32 = 1
+	// TTL reported for Cloudflare records with the automatic TTL.
+	cloudflareDefaultTTL uint32 = 300
+)
+
+type (
+	// cloudflareControl reads the TXT records of zones through the Cloudflare v4 API, as Cloudflare does not allow zone transfers on most plans.
+	cloudflareControl struct {
+		// Inventory configuration.
+		config *Config
+		// HTTP client.
+		client *http.Client
+
+		// Guards the zone IDs.
+		mu sync.Mutex
+		// Zone IDs by zone name.
+		ids map[string]string
+	}
+
+	// cloudflareResponse represents the envelope of a Cloudflare API response.
+	cloudflareResponse struct {
+		Success bool `json:"success"`
+		Errors  []struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+		Result     json.RawMessage `json:"result"`
+		ResultInfo struct {
+			Page       int `json:"page"`
+			TotalPages int `json:"total_pages"`
+		} `json:"result_info"`
+	}
+
+	// cloudflareZone represents a zone in a Cloudflare API response.
+	cloudflareZone struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+
+	// cloudflareRecord represents a DNS record in a Cloudflare API response.
+	cloudflareRecord struct {
+		Name    string `json:"name"`
+		Type    string `json:"type"`
+		Content string `json:"content"`
+		TTL     uint32 `json:"ttl"`
+	}
+)
+
+// request performs a Cloudflare API GET request, decodes the result into 'result' and returns the response envelope.
+func (c *cloudflareControl) request(ctx context.Context, path string, query url.Values, result interface{}) (*cloudflareResponse, error) {
+	cfg := c.config
+
+	endpoint := strings.TrimSuffix(cfg.Cloudflare.Endpoint, "/") + "/" + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "cloudflare request failure")
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.Cloudflare.Token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "cloudflare request failure")
+	}
+	defer resp.Body.Close()
+
+	envelope := &cloudflareResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(envelope); err != nil {
+		return nil, errors.Wrapf(err, "cloudflare response parsing failure: %s", resp.Status)
+	}
+
+	if !envelope.Success || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		messages := make([]string, 0, len(envelope.Errors))
+		for _, e := range envelope.Errors {
+			messages = append(messages, fmt.Sprintf("%d: %s", e.Code, e.Message))
+		}
+
+		return nil, errors.Errorf("cloudflare request failure: %s: %s", resp.Status, strings.Join(messages, "; "))
+	}
+
+	if err := json.Unmarshal(envelope.Result, result); err != nil {
+		return nil, errors.Wrap(err, "cloudflare response parsing failure")
+	}
+
+	return envelope, nil
+}
+
+// zoneID returns the ID of the Cloudflare zone with a specific name, limited to 'cloudflare.account' if set.
+func (c *cloudflareControl) zoneID(ctx context.Context, zone string) (string, error) {
+	cfg := c.config
+	name := strings.TrimSuffix(strings.ToLower(zone), ".")
+
+	c.mu.Lock()
+	id, ok := c.ids[name]
+	c.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	query := url.Values{"name": {name}}
+	if len(cfg.Cloudflare.Account) > 0 {
+		query.Set("account.id", cfg.Cloudflare.Account)
+	}
+
+	zones := make([]cloudflareZone, 0)
+	if _, err := c.request(ctx, "zones", query, &zones); err != nil {
+		return "", err
+	}
+
+	for _, z := range zones {
+		if strings.EqualFold(z.Name, name) {
+			id = z.ID
+			break
+		}
+	}
+
+	if len(id) == 0 {
+		return "", errors.Errorf("no cloudflare zone found for %s", name)
+	}
+
+	c.mu.Lock()
+	c.ids[name] = id
+	c.mu.Unlock()
+
+	return id, nil
+}
+
+// cloudflareTXT converts a Cloudflare TXT record into a DNS record.
+// The content is either in presentation format (quoted character strings) or a single unquoted string, which is split into 255-byte strings.
+func cloudflareTXT(r cloudflareRecord) (dns.RR, error) {
+	ttl := r.TTL
+	if ttl == cloudflareAutoTTL {
+		ttl = cloudflareDefaultTTL
+	}
+
+	if strings.HasPrefix(r.Content, "\"") {
+		rr, err := dns.NewRR(fmt.Sprintf("%s %d IN TXT %s", dns.Fqdn(r.Name), ttl, r.Content))
+		if err != nil {
+			return nil, errors.Wrap(err, "record parsing failure")
+		}
+
+		return rr, nil
+	}
+
+	rr := &dns.TXT{Hdr: dns.RR_Header{Name: dns.Fqdn(r.Name), Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: ttl}}
+	content := r.Content
+	for len(content) > 255 {
+		rr.Txt = append(rr.Txt, content[:255])
+		content = content[255:]
+	}
+	rr.Txt = append(rr.Txt, content)
+
+	return rr, nil
+}
+
+// readZone reads the TXT records of a Cloudflare zone page by page. The API does not expose SOA records, so zones have no serial.
+func (c *cloudflareControl) readZone(ctx context.Context, zone string) ([]dns.RR, error) {
+	cfg := c.config
+
+	ctx, cancel := context.WithTimeout(ctx, stageTimeout(cfg.Cloudflare.Timeout, cfg.DNS.Timeout))
+	defer cancel()
+
+	id, err := c.zoneID(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	rrs := make([]dns.RR, 0)
+	for page := 1; ; page++ {
+		query := url.Values{"type": {"TXT"}, "page": {strconv.Itoa(page)}}
+		if cfg.Cloudflare.PerPage > 0 {
+			query.Set("per_page", strconv.Itoa(cfg.Cloudflare.PerPage))
+		}
+
+		records := make([]cloudflareRecord, 0)
+		envelope, err := c.request(ctx, "zones/"+id+"/dns_records", query, &records)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range records {
+			if r.Type != "TXT" {
+				continue
+			}
+
+			rr, err := cloudflareTXT(r)
+			if err != nil {
+				return nil, err
+			}
+			rrs = append(rrs, rr)
+		}
+
+		if len(records) == 0 || page >= envelope.ResultInfo.TotalPages {
+			return rrs, nil
+		}
+	}
+}
+
+// NewCloudflareDatasource creates a DNS datasource that reads zones through the Cloudflare API.
+func NewCloudflareDatasource(cfg *Config, log Logger) (*DNSDatasource, error) {
+	if len(cfg.Cloudflare.Token) == 0 {
+		return nil, errors.New("cloudflare datasource initialization failure: no API token configured")
+	}
+
+	d, err := NewDNSDatasource(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Control = &cloudflareControl{
+		config: cfg,
+		client: &http.Client{},
+		ids:    make(map[string]string),
+	}
+
+	return d, nil
+}
@@ -0,0 +1,60 @@
+package inventory
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// serveCloudflare emulates the Cloudflare v4 API serving the 'infra.local' zone, one TXT record per page.
+// Requests without the 'test-token' API token are rejected.
+func serveCloudflare(t *testing.T) string {
+	records := []string{
+		`{"name":"app01.infra.local","type":"TXT","content":"OS=linux;ENV=dev;ROLE=app;SRV=tomcat","ttl":1}`,
+		`{"name":"app01.infra.local","type":"TXT","content":"\"OS=linux;ENV=dev;ROLE=db;\" \"SRV=postgres\"","ttl":3600}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"success":false,"errors":[{"code":6003,"message":"Invalid request headers"}],"result":null}`)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/zones":
+			result := `[]`
+			if r.URL.Query().Get("name") == "infra.local" {
+				result = `[{"id":"023e105f4ecef8ad9ca31a8372d0c353","name":"infra.local"}]`
+			}
+			fmt.Fprintf(w, `{"success":true,"errors":[],"result":%s,"result_info":{"page":1,"total_pages":1}}`, result)
+		case "/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records":
+			var page int
+			fmt.Sscan(r.URL.Query().Get("page"), &page)
+			if page < 1 || page > len(records) || r.URL.Query().Get("type") != "TXT" {
+				fmt.Fprintf(w, `{"success":true,"errors":[],"result":[],"result_info":{"page":%d,"total_pages":%d}}`, page, len(records))
+				return
+			}
+			fmt.Fprintf(w, `{"success":true,"errors":[],"result":[%s],"result_info":{"page":%d,"total_pages":%d}}`, records[page-1], page, len(records))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"success":false,"errors":[{"code":7003,"message":"Could not route to /zones, perhaps your object identifier is invalid?"}],"result":null}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+// newTestCloudflareControl creates a Cloudflare API client for the emulated Cloudflare API.
+func newTestCloudflareControl(t *testing.T, token string) *cloudflareControl {
+	cfg := &Config{}
+	cfg.Cloudflare.Endpoint = serveCloudflare(t)
+	cfg.Cloudflare.Token = token
+	cfg.Cloudflare.PerPage = 1
+	cfg.Cloudflare.Timeout = time.Second
+
+	return &cloudflareControl{config: cfg, client: &http.Client{}, ids: make(map[string]string)}
+}
@@ -0,0 +1,78 @@
+package inventory
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+const (
+	// Byte order collation name.
+	bytesCollation string = "bytes"
+)
+
+// NewCollation returns a host and group name comparison function for a collation: byte order if the name is empty or 'bytes', otherwise the collation of a BCP 47 language tag.
+// Names that are equal according to a language collation are compared in byte order, so sorting is always deterministic.
+func NewCollation(name string, numeric bool) (func(a, b string) int, error) {
+	if len(name) == 0 || name == bytesCollation {
+		return strings.Compare, nil
+	}
+
+	tag, err := language.Parse(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid collation: %s", name)
+	}
+
+	opts := make([]collate.Option, 0)
+	if numeric {
+		opts = append(opts, collate.Numeric)
+	}
+
+	// Collators keep internal buffers and are not safe for concurrent use.
+	var mu sync.Mutex
+	c := collate.New(tag, opts...)
+
+	return func(a, b string) int {
+		mu.Lock()
+		result := c.CompareString(a, b)
+		mu.Unlock()
+
+		if result == 0 {
+			return strings.Compare(a, b)
+		}
+
+		return result
+	}, nil
+}
+
+// CompareNames compares host or group names using the configured collation.
+func (i *Inventory) CompareNames(a, b string) int {
+	if i.Collation == nil {
+		return strings.Compare(a, b)
+	}
+
+	return i.Collation(a, b)
+}
+
+// SortNames sorts host or group names using the configured collation.
+func (i *Inventory) SortNames(names []string) {
+	slices.SortFunc(names, i.CompareNames)
+}
+
+// compare compares host or group names using the collation of this node.
+func (n *Node) compare(a, b string) int {
+	if n.Compare == nil {
+		return strings.Compare(a, b)
+	}
+
+	return n.Compare(a, b)
+}
+
+// sortNames sorts host or group names using the collation of this node.
+func (n *Node) sortNames(names []string) {
+	slices.SortFunc(names, n.compare)
+}
@@ -0,0 +1,70 @@
+package inventory
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestNewCollation(t *testing.T) {
+	names := []string{"app10.infra.local", "b.infra.local", "App2.infra.local", "é.infra.local", "app2.infra.local", "e.infra.local"}
+
+	tests := []struct {
+		name      string
+		collation string
+		numeric   bool
+		want      []string
+		wantErr   bool
+	}{
+		{
+			name:      "valid-default",
+			collation: "",
+			want:      []string{"App2.infra.local", "app10.infra.local", "app2.infra.local", "b.infra.local", "e.infra.local", "é.infra.local"},
+			wantErr:   false,
+		},
+		{
+			name:      "valid-bytes",
+			collation: "bytes",
+			want:      []string{"App2.infra.local", "app10.infra.local", "app2.infra.local", "b.infra.local", "e.infra.local", "é.infra.local"},
+			wantErr:   false,
+		},
+		{
+			name:      "valid-language",
+			collation: "en",
+			want:      []string{"app10.infra.local", "app2.infra.local", "App2.infra.local", "b.infra.local", "e.infra.local", "é.infra.local"},
+			wantErr:   false,
+		},
+		{
+			name:      "valid-language-numeric",
+			collation: "en",
+			numeric:   true,
+			want:      []string{"app2.infra.local", "App2.infra.local", "app10.infra.local", "b.infra.local", "e.infra.local", "é.infra.local"},
+			wantErr:   false,
+		},
+		{
+			name:      "invalid",
+			collation: "en-US-@",
+			want:      nil,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compare, err := NewCollation(tt.collation, tt.numeric)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewCollation() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			var got []string
+			if compare != nil {
+				got = slices.Clone(names)
+				slices.SortFunc(got, compare)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewCollation() sorted = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
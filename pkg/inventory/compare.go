@@ -0,0 +1,216 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// inventoryView holds everything compared between two inventories.
+type inventoryView struct {
+	// Hosts and the groups they belong to.
+	hosts map[string][]string
+	// Groups and the hosts they contain, directly or through child groups.
+	groups map[string][]string
+	// Group variables by group.
+	groupVars map[string]map[string]interface{}
+	// Host variables by host, nil if host variables are disabled.
+	hostVars map[string]map[string]interface{}
+}
+
+// hostVarsEnabled reports if host variables are produced by this inventory.
+func (i *Inventory) hostVarsEnabled() bool {
+	cfg := i.Config
+
+	return cfg.Txt.Vars.Enabled || cfg.Varsources.Enabled || cfg.Services.Enabled
+}
+
+// view acquires host records, rebuilds the inventory tree and collects the hosts, groups and variables of the inventory.
+// Host variables are only collected if 'vars' is true.
+func (i *Inventory) view(vars bool) (*inventoryView, error) {
+	// Inventories may use different attribute keys.
+	setAttributeNames(i.Config)
+
+	hosts, err := i.GetHosts()
+	if err != nil {
+		return nil, err
+	}
+
+	i.Tree = NewTree()
+	if err := i.ImportHosts(hosts); err != nil {
+		return nil, err
+	}
+
+	v := &inventoryView{
+		hosts:     make(map[string][]string),
+		groups:    make(map[string][]string),
+		groupVars: make(map[string]map[string]interface{}),
+	}
+
+	i.ExportHosts(v.hosts)
+	i.ExportGroups(v.groups)
+
+	groups := make(map[string]*AnsibleGroup)
+	i.ExportInventory(groups)
+	for name, group := range groups {
+		v.groupVars[name] = group.Vars
+	}
+
+	if vars {
+		v.hostVars = make(map[string]map[string]interface{})
+		for host := range v.hosts {
+			if v.hostVars[host], err = i.ExportHostVariables(host); err != nil {
+				return nil, errors.Wrapf(err, "%s: host variables loading failure", host)
+			}
+		}
+	}
+
+	return v, nil
+}
+
+// diffNames returns the names only found in 'next' and the names only found in 'prev', both sorted.
+func diffNames(prev []string, next []string) ([]string, []string) {
+	seen := make(map[string]int)
+	for _, name := range prev {
+		seen[name] |= 1
+	}
+	for _, name := range next {
+		seen[name] |= 2
+	}
+
+	added, removed := make([]string, 0), make([]string, 0)
+	for name, where := range seen {
+		switch where {
+		case 1:
+			removed = append(removed, name)
+		case 2:
+			added = append(added, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+
+	return added, removed
+}
+
+// diffVars describes the changes between two sets of variables: '+' for added, '-' for removed and '~' for modified variables.
+// Values are compared and reported in their JSON representation.
+func diffVars(prev map[string]interface{}, next map[string]interface{}) []string {
+	keys := make([]string, 0)
+	for key := range prev {
+		keys = append(keys, key)
+	}
+	for key := range next {
+		if _, ok := prev[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	format := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+
+		return string(data)
+	}
+
+	diff := make([]string, 0)
+	for _, key := range keys {
+		prevValue, prevOk := prev[key]
+		nextValue, nextOk := next[key]
+
+		switch {
+		case !prevOk:
+			diff = append(diff, fmt.Sprintf("+ %s: %s", key, format(nextValue)))
+		case !nextOk:
+			diff = append(diff, fmt.Sprintf("- %s: %s", key, format(prevValue)))
+		case format(prevValue) != format(nextValue):
+			diff = append(diff, fmt.Sprintf("~ %s: %s -> %s", key, format(prevValue), format(nextValue)))
+		}
+	}
+
+	return diff
+}
+
+// mapKeys returns the keys of a map in no particular order.
+func mapKeys[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// compareViews describes the differences between two inventory views.
+func compareViews(prev *inventoryView, next *inventoryView) *InventoryComparison {
+	c := &InventoryComparison{
+		Members:   make(map[string]*MembershipChange),
+		GroupVars: make(map[string][]string),
+		HostVars:  make(map[string][]string),
+	}
+
+	c.AddedHosts, c.RemovedHosts = diffNames(mapKeys(prev.hosts), mapKeys(next.hosts))
+	c.AddedGroups, c.RemovedGroups = diffNames(mapKeys(prev.groups), mapKeys(next.groups))
+
+	for name, hosts := range prev.groups {
+		other, ok := next.groups[name]
+		if !ok {
+			continue
+		}
+
+		if added, removed := diffNames(hosts, other); len(added) > 0 || len(removed) > 0 {
+			c.Members[name] = &MembershipChange{Added: added, Removed: removed}
+		}
+
+		if diff := diffVars(prev.groupVars[name], next.groupVars[name]); len(diff) > 0 {
+			c.GroupVars[name] = diff
+		}
+	}
+
+	if prev.hostVars != nil || next.hostVars != nil {
+		for host := range prev.hosts {
+			if _, ok := next.hosts[host]; !ok {
+				continue
+			}
+
+			if diff := diffVars(prev.hostVars[host], next.hostVars[host]); len(diff) > 0 {
+				c.HostVars[host] = diff
+			}
+		}
+	}
+
+	return c
+}
+
+// Equal reports if the compared inventories are the same.
+func (c *InventoryComparison) Equal() bool {
+	return len(c.AddedHosts) == 0 && len(c.RemovedHosts) == 0 && len(c.AddedGroups) == 0 && len(c.RemovedGroups) == 0 &&
+		len(c.Members) == 0 && len(c.GroupVars) == 0 && len(c.HostVars) == 0
+}
+
+// Compare builds this and the other inventory from their datasources and describes the differences of their hosts, groups and variables,
+// e.g. to validate a migration between datasources. Host variables are compared if they are enabled in either inventory.
+// The inventory trees of both inventories are rebuilt.
+func (i *Inventory) Compare(other *Inventory) (*InventoryComparison, error) {
+	vars := i.hostVarsEnabled() || other.hostVarsEnabled()
+
+	prev, err := i.view(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := other.view(vars)
+	if err != nil {
+		return nil, errors.Wrap(err, "other inventory")
+	}
+
+	// Restore the attribute keys of this inventory.
+	setAttributeNames(i.Config)
+
+	return compareViews(prev, next), nil
+}
@@ -0,0 +1,75 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/creasty/defaults"
+)
+
+// newTestInventory creates an inventory serving a fixed set of host records.
+func newTestInventory(t *testing.T, vars bool, records []*DatasourceRecord) *Inventory {
+	cfg := &Config{}
+	if err := defaults.Set(cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Txt.Vars.Enabled = vars
+
+	i, err := New(cfg, &testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(i.Close)
+
+	i.Datasource = &testDatasource{records: records}
+
+	return i
+}
+
+func TestInventory_Compare(t *testing.T) {
+	this := newTestInventory(t, true, []*DatasourceRecord{
+		{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS=heap=2g"},
+		{Hostname: "db01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=db;SRV=postgres;VARS="},
+	})
+	other := newTestInventory(t, false, []*DatasourceRecord{
+		{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=nginx;VARS=heap=4g"},
+		{Hostname: "app02.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=nginx;VARS="},
+	})
+
+	got, err := this.Compare(other)
+	if err != nil {
+		t.Fatalf("Inventory.Compare() error = %v", err)
+	}
+
+	want := &InventoryComparison{
+		AddedHosts:    []string{"app02.infra.local"},
+		RemovedHosts:  []string{"db01.infra.local"},
+		AddedGroups:   []string{"all_app_nginx", "dev_app_nginx"},
+		RemovedGroups: []string{"all_app_tomcat", "all_db", "all_db_postgres", "dev_app_tomcat", "dev_db", "dev_db_postgres"},
+		Members: map[string]*MembershipChange{
+			"all":            {Added: []string{"app02.infra.local"}, Removed: []string{"db01.infra.local"}},
+			"all_app":        {Added: []string{"app02.infra.local"}, Removed: []string{}},
+			"all_host":       {Added: []string{"app02.infra.local"}, Removed: []string{"db01.infra.local"}},
+			"all_host_linux": {Added: []string{"app02.infra.local"}, Removed: []string{"db01.infra.local"}},
+			"dev":            {Added: []string{"app02.infra.local"}, Removed: []string{"db01.infra.local"}},
+			"dev_app":        {Added: []string{"app02.infra.local"}, Removed: []string{}},
+			"dev_host":       {Added: []string{"app02.infra.local"}, Removed: []string{"db01.infra.local"}},
+			"dev_host_linux": {Added: []string{"app02.infra.local"}, Removed: []string{"db01.infra.local"}},
+		},
+		GroupVars: map[string][]string{},
+		// Host variables are only produced by this inventory.
+		HostVars: map[string][]string{"app01.infra.local": {`- heap: "2g"`}},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Inventory.Compare() = %+v, want %+v", got, want)
+	}
+
+	if got.Equal() {
+		t.Error("Inventory.Compare() reports equal inventories")
+	}
+
+	if same, err := this.Compare(this); err != nil || !same.Equal() {
+		t.Errorf("Inventory.Compare() = %+v, %v, want equal inventories", same, err)
+	}
+}
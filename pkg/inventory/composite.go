@@ -0,0 +1,286 @@
+package inventory
+
+import (
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Records of all datasources having a host are used.
+	CompositeMergeConflicts string = "merge"
+	// Records of the first datasource in the list having a host are used.
+	CompositeFirstConflicts string = "first"
+	// Records of the last datasource in the list having a host are used.
+	CompositeLastConflicts string = "last"
+)
+
+// CompositeDatasource aggregates the host records of several datasources, listed in the 'datasource' parameter.
+// Hosts found in several datasources are resolved according to the composite conflict resolution rules.
+type CompositeDatasource struct {
+	// Inventory configuration.
+	Config *Config
+	// Inventory logger.
+	Logger Logger
+	// Datasource types, in the configured order.
+	Types []string
+	// Datasources, in the order of their types.
+	Datasources []Datasource
+}
+
+// datasourceTypes splits the 'datasource' parameter into datasource types.
+func datasourceTypes(datasource string) []string {
+	types := make([]string, 0)
+	for _, t := range strings.Split(datasource, ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); len(t) > 0 {
+			types = append(types, t)
+		}
+	}
+
+	return types
+}
+
+// conflicts returns the conflict resolution for a specific host: the resolution of the first rule matching the hostname, or the default one.
+func (c *CompositeDatasource) conflicts(host string) string {
+	cfg := c.Config
+
+	for _, rule := range cfg.Composite.Rules {
+		if ok, _ := path.Match(strings.ToLower(rule.Hosts), host); ok {
+			return rule.Conflicts
+		}
+	}
+
+	return cfg.Composite.Conflicts
+}
+
+// resolve combines the records acquired from every datasource, in the order of the datasources, resolving the hosts found in several datasources.
+// Each element of 'found' holds the records of the datasource with the same index. Hosts missing from a preferred datasource keep the records of all datasources.
+func (c *CompositeDatasource) resolve(found [][]*DatasourceRecord) []*DatasourceRecord {
+	log := c.Logger
+
+	// Indexes of the datasources having each host, in order.
+	owners := make(map[string][]int)
+	for n, records := range found {
+		for _, r := range records {
+			host := strings.ToLower(strings.TrimSuffix(r.Hostname, "."))
+			if o := owners[host]; len(o) == 0 || o[len(o)-1] != n {
+				owners[host] = append(o, n)
+			}
+		}
+	}
+
+	// Indexes of the datasources whose records are used for each host found in several datasources.
+	selected := make(map[string]map[int]bool)
+	for host, o := range owners {
+		if len(o) < 2 {
+			continue
+		}
+
+		keep := o
+		switch resolution := c.conflicts(host); resolution {
+		case CompositeMergeConflicts:
+		case CompositeFirstConflicts:
+			keep = o[:1]
+		case CompositeLastConflicts:
+			keep = o[len(o)-1:]
+		default:
+			for _, n := range o {
+				if c.Types[n] == resolution {
+					keep = []int{n}
+				}
+			}
+		}
+
+		names := make([]string, 0, len(keep))
+		selected[host] = make(map[int]bool)
+		for _, n := range keep {
+			selected[host][n] = true
+			names = append(names, c.Types[n])
+		}
+
+		log.Debugf("[%s] host found in several datasources, using records of: %s", host, strings.Join(names, ", "))
+	}
+
+	records := make([]*DatasourceRecord, 0)
+	for n, rs := range found {
+		for _, r := range rs {
+			keep, ok := selected[strings.ToLower(strings.TrimSuffix(r.Hostname, "."))]
+			if ok && !keep[n] {
+				continue
+			}
+
+			if len(r.Source) == 0 {
+				r = &DatasourceRecord{Hostname: r.Hostname, Attributes: r.Attributes, Source: c.Types[n]}
+			}
+
+			records = append(records, r)
+		}
+	}
+
+	return records
+}
+
+// collect calls every datasource concurrently and returns their host records, in the order of the datasources.
+func (c *CompositeDatasource) collect(call func(ds Datasource) ([]*DatasourceRecord, error)) ([][]*DatasourceRecord, error) {
+	found := make([][]*DatasourceRecord, len(c.Datasources))
+	errs := make([]error, len(c.Datasources))
+
+	var wg sync.WaitGroup
+	for n, ds := range c.Datasources {
+		wg.Add(1)
+		go func(n int, ds Datasource) {
+			defer wg.Done()
+			found[n], errs[n] = call(ds)
+		}(n, ds)
+	}
+	wg.Wait()
+
+	for n, err := range errs {
+		if err != nil {
+			return nil, errors.Wrapf(err, "composite datasource failure: %s", c.Types[n])
+		}
+	}
+
+	return found, nil
+}
+
+// GetAllRecords acquires all available host records from every datasource.
+func (c *CompositeDatasource) GetAllRecords() ([]*DatasourceRecord, error) {
+	found, err := c.collect(func(ds Datasource) ([]*DatasourceRecord, error) {
+		return ds.GetAllRecords()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c.resolve(found), nil
+}
+
+// GetHostRecords acquires all available records for a specific host from every datasource.
+// Datasources reporting that they have no records for the host are skipped.
+func (c *CompositeDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error) {
+	found, err := c.collect(func(ds Datasource) ([]*DatasourceRecord, error) {
+		records, err := ds.GetHostRecords(host)
+		if isNoHostRecords(err) {
+			return nil, nil
+		}
+
+		return records, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	records := c.resolve(found)
+	if len(records) == 0 {
+		return nil, errors.Wrap(ErrNoHostRecords, host)
+	}
+
+	return records, nil
+}
+
+// PublishRecords writes host records to the first datasource in the list.
+func (c *CompositeDatasource) PublishRecords(records []*DatasourceRecord) error {
+	return c.Datasources[0].PublishRecords(records)
+}
+
+// Metadata returns the metadata of every datasource that can describe its data, keyed by datasource type.
+func (c *CompositeDatasource) Metadata() map[string]interface{} {
+	metadata := make(map[string]interface{})
+	for n, ds := range c.Datasources {
+		if described, ok := ds.(DescribedDatasource); ok {
+			if m := described.Metadata(); len(m) > 0 {
+				metadata[c.Types[n]] = m
+			}
+		}
+	}
+
+	return metadata
+}
+
+// GetGroupVariables returns the group variables of every datasource that supports them.
+// Variables of a group set in several datasources are merged, with the datasources listed first taking precedence.
+func (c *CompositeDatasource) GetGroupVariables() (map[string]map[string]interface{}, error) {
+	vars := make(map[string]map[string]interface{})
+	for n, ds := range c.Datasources {
+		gv, ok := ds.(GroupVarsDatasource)
+		if !ok {
+			continue
+		}
+
+		groups, err := gv.GetGroupVariables()
+		if err != nil {
+			return nil, errors.Wrapf(err, "composite datasource failure: %s", c.Types[n])
+		}
+
+		for group, values := range groups {
+			if vars[group] == nil {
+				vars[group] = make(map[string]interface{})
+			}
+
+			for key, value := range values {
+				if _, ok := vars[group][key]; !ok {
+					vars[group][key] = value
+				}
+			}
+		}
+	}
+
+	return vars, nil
+}
+
+// Close closes the clients of every datasource.
+func (c *CompositeDatasource) Close() {
+	for _, ds := range c.Datasources {
+		ds.Close()
+	}
+}
+
+// NewCompositeDatasource creates a composite datasource of the datasources listed in the 'datasource' parameter.
+// Every datasource is created with a copy of the inventory configuration selecting its type.
+func NewCompositeDatasource(cfg *Config, log Logger) (*CompositeDatasource, error) {
+	types := datasourceTypes(cfg.Datasource)
+
+	resolutions := []string{cfg.Composite.Conflicts}
+	for _, rule := range cfg.Composite.Rules {
+		if _, err := path.Match(rule.Hosts, ""); err != nil || len(rule.Hosts) == 0 {
+			return nil, errors.Errorf("composite datasource initialization failure: invalid host pattern: '%s'", rule.Hosts)
+		}
+
+		resolutions = append(resolutions, rule.Conflicts)
+	}
+
+	for _, resolution := range resolutions {
+		switch resolution {
+		case CompositeMergeConflicts, CompositeFirstConflicts, CompositeLastConflicts:
+		default:
+			if !slices.Contains(types, resolution) {
+				return nil, errors.Errorf("composite datasource initialization failure: unknown conflict resolution: %s", resolution)
+			}
+		}
+	}
+
+	c := &CompositeDatasource{Config: cfg, Logger: log, Types: types}
+	for n, t := range types {
+		if slices.Contains(types[:n], t) {
+			c.Close()
+			return nil, errors.Errorf("composite datasource initialization failure: duplicate datasource type: %s", t)
+		}
+
+		child := *cfg
+		child.Datasource = t
+
+		ds, err := newDatasource(&child, log)
+		if err != nil {
+			c.Close()
+			return nil, errors.Wrapf(err, "composite datasource initialization failure: %s", t)
+		}
+
+		c.Datasources = append(c.Datasources, ds)
+	}
+
+	return c, nil
+}
@@ -0,0 +1,136 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// newTestCompositeDatasource creates a composite datasource of 'dns', 'etcd' and 'csv' test datasources.
+func newTestCompositeDatasource(conflicts string, rules []CompositeRuleSpec, dns []*DatasourceRecord, etcd []*DatasourceRecord, csv []*DatasourceRecord) *CompositeDatasource {
+	cfg := &Config{}
+	cfg.Composite.Conflicts = conflicts
+	cfg.Composite.Rules = rules
+
+	return &CompositeDatasource{
+		Config:      cfg,
+		Logger:      &testLogger{},
+		Types:       []string{"dns", "etcd", "csv"},
+		Datasources: []Datasource{&testDatasource{records: dns}, &testDatasource{records: etcd}, &testDatasource{records: csv}},
+	}
+}
+
+func TestCompositeDatasource_GetAllRecords(t *testing.T) {
+	dnsApp := &DatasourceRecord{Hostname: "app01.infra.local.", Attributes: "OS=linux;ENV=prod;ROLE=app;SRV=;VARS=", Source: "app01.infra.local."}
+	dnsDB := &DatasourceRecord{Hostname: "db01.infra.local.", Attributes: "OS=linux;ENV=prod;ROLE=db;SRV=;VARS=", Source: "db01.infra.local."}
+	etcdApp := &DatasourceRecord{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=app;SRV=tomcat;VARS="}
+	etcdWeb := &DatasourceRecord{Hostname: "web01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=web;SRV=nginx;VARS="}
+	csvApp := &DatasourceRecord{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=;VARS=", Source: "hosts.csv:2"}
+	csvDB := &DatasourceRecord{Hostname: "db01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=db;SRV=postgres;VARS=", Source: "hosts.csv:3"}
+
+	// Records without a source are attributed to their datasource.
+	etcdAppSource := &DatasourceRecord{Hostname: etcdApp.Hostname, Attributes: etcdApp.Attributes, Source: "etcd"}
+	etcdWebSource := &DatasourceRecord{Hostname: etcdWeb.Hostname, Attributes: etcdWeb.Attributes, Source: "etcd"}
+
+	tests := []struct {
+		name      string
+		conflicts string
+		rules     []CompositeRuleSpec
+		want      []*DatasourceRecord
+	}{
+		{
+			name:      "valid-merge",
+			conflicts: CompositeMergeConflicts,
+			want:      []*DatasourceRecord{dnsApp, dnsDB, etcdAppSource, etcdWebSource, csvApp, csvDB},
+		},
+		{
+			name:      "valid-first",
+			conflicts: CompositeFirstConflicts,
+			want:      []*DatasourceRecord{dnsApp, dnsDB, etcdWebSource},
+		},
+		{
+			name:      "valid-last",
+			conflicts: CompositeLastConflicts,
+			want:      []*DatasourceRecord{etcdWebSource, csvApp, csvDB},
+		},
+		{
+			// The preferred datasource does not have db01, so its records are merged.
+			name:      "valid-preferred",
+			conflicts: "etcd",
+			want:      []*DatasourceRecord{dnsDB, etcdAppSource, etcdWebSource, csvDB},
+		},
+		{
+			name:      "valid-rules",
+			conflicts: CompositeFirstConflicts,
+			rules:     []CompositeRuleSpec{{Hosts: "db*.infra.local", Conflicts: "csv"}, {Hosts: "*", Conflicts: CompositeLastConflicts}},
+			want:      []*DatasourceRecord{etcdWebSource, csvApp, csvDB},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCompositeDatasource(tt.conflicts, tt.rules, []*DatasourceRecord{dnsApp, dnsDB}, []*DatasourceRecord{etcdApp, etcdWeb}, []*DatasourceRecord{csvApp, csvDB})
+
+			got, err := c.GetAllRecords()
+			if err != nil {
+				t.Fatalf("CompositeDatasource.GetAllRecords() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CompositeDatasource.GetAllRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompositeDatasource_GetHostRecords(t *testing.T) {
+	etcdApp := &DatasourceRecord{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=app;SRV=tomcat;VARS=", Source: "app01"}
+	csvApp := &DatasourceRecord{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=;VARS=", Source: "hosts.csv:2"}
+
+	tests := []struct {
+		name    string
+		host    string
+		err     error
+		want    []*DatasourceRecord
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			host: "app01.infra.local",
+			want: []*DatasourceRecord{etcdApp},
+		},
+		{
+			// Datasources without records for the host are skipped.
+			name: "valid-not-found",
+			host: "app01.infra.local",
+			err:  errors.Wrap(ErrNoHostRecords, "app01.infra.local"),
+			want: []*DatasourceRecord{etcdApp},
+		},
+		{
+			name:    "invalid-missing",
+			host:    "db01.infra.local",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-failure",
+			host:    "app01.infra.local",
+			err:     errors.New("connection refused"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCompositeDatasource("first", nil, nil, []*DatasourceRecord{etcdApp}, []*DatasourceRecord{csvApp})
+			c.Datasources[0] = &testDatasource{err: tt.err}
+
+			got, err := c.GetHostRecords(tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CompositeDatasource.GetHostRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CompositeDatasource.GetHostRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// varsourceLabel describes a secondary variable source in conflict reports.
+func (i *Inventory) varsourceLabel(n int) string {
+	sources := i.Config.Varsources.Sources
+	if n < len(sources) {
+		return fmt.Sprintf("varsource %d (%s: %s)", n, sources[n].Type, sources[n].Path)
+	}
+
+	return fmt.Sprintf("varsource %d", n)
+}
+
+// ExportConflicts reports host variables whose sources disagree and unique host identifiers used by several hosts, ordered by hostname.
+// Host variables are merged the same way as in the host variables mode: host records first, then the secondary variable sources, later sources take precedence.
+func (i *Inventory) ExportConflicts() ([]*HostConflict, error) {
+	cfg := i.Config
+	log := i.Logger
+	conflicts := make([]*HostConflict, 0)
+
+	records, err := i.Datasource.GetAllRecords()
+	if err != nil {
+		return nil, errors.Wrap(err, "record loading failure")
+	}
+
+	names := make([]string, 0)
+	hosts := make(map[string][]*DatasourceRecord)
+	attributes := make(map[*DatasourceRecord]*HostAttributes)
+
+	// Unique host identifiers and the records that have used them first.
+	ids := make(map[string]*DatasourceRecord)
+	reported := make(map[string]bool)
+
+	for _, r := range records {
+		attrs, err := i.ParseAttributes(r.Attributes)
+		if err != nil {
+			log.Warnf(warnSkippedRecord, r.Hostname, err)
+			continue
+		}
+
+		if match, err := i.filterHost(r.Hostname, attrs); err != nil {
+			return nil, errors.Wrap(err, "filter processing failure")
+		} else if !match {
+			continue
+		}
+
+		if match, err := matchFilters(r.Hostname, attrs, i.Filters); err != nil {
+			return nil, errors.Wrap(err, "filter processing failure")
+		} else if !match {
+			continue
+		}
+
+		if len(attrs.ID) > 0 {
+			id := strings.ToLower(attrs.ID)
+			if owner, ok := ids[id]; !ok {
+				ids[id] = r
+			} else if owner.Hostname != r.Hostname && !reported[r.Hostname+"/"+id] {
+				reported[r.Hostname+"/"+id] = true
+				conflicts = append(conflicts, &HostConflict{
+					Host:       r.Hostname,
+					Key:        cfg.Txt.Keys.ID,
+					Values:     []*ConflictValue{{Source: owner.Source, Value: attributes[owner].ID}, {Source: r.Source, Value: attrs.ID}},
+					Resolution: fmt.Sprintf("not resolved: both %s and %s use the identifier", owner.Hostname, r.Hostname),
+				})
+			}
+		}
+
+		if _, ok := hosts[r.Hostname]; !ok {
+			names = append(names, r.Hostname)
+		}
+		hosts[r.Hostname] = append(hosts[r.Hostname], r)
+		attributes[r] = attrs
+	}
+
+	i.SortNames(names)
+
+	for _, host := range names {
+		keys := make([]string, 0)
+		values := make(map[string][]*ConflictValue)
+		add := func(source string, vars map[string]string) {
+			for key, value := range vars {
+				if _, ok := values[key]; !ok {
+					keys = append(keys, key)
+				}
+				values[key] = append(values[key], &ConflictValue{Source: source, Value: value})
+			}
+		}
+
+		for n, r := range hosts[host] {
+			source := r.Source
+			if len(source) == 0 {
+				source = fmt.Sprintf("record %d", n)
+			}
+
+			attrs := attributes[r]
+			vars := make(map[string]string)
+			if cfg.Txt.Vars.Enabled {
+				parseVariables(attrs.Vars, cfg.Txt.Vars.Separator, cfg.Txt.Vars.Equalsign, vars)
+			}
+			if len(attrs.ID) > 0 {
+				vars[hostIDVar] = attrs.ID
+			}
+
+			add(source, vars)
+		}
+
+		for n, v := range i.Varsources {
+			vars, err := v.GetHostVariables(host)
+			if err != nil {
+				log.Warnf(warnSkippedVarsource, host, err)
+				continue
+			}
+
+			add(i.varsourceLabel(n), vars)
+		}
+
+		sort.Strings(keys)
+		for _, key := range keys {
+			conflicting := false
+			for _, v := range values[key] {
+				conflicting = conflicting || v.Value != values[key][0].Value
+			}
+
+			if !conflicting {
+				continue
+			}
+
+			winner := values[key][len(values[key])-1]
+			conflicts = append(conflicts, &HostConflict{
+				Host:       host,
+				Key:        key,
+				Values:     values[key],
+				Resolution: fmt.Sprintf("value from %s is used: later sources take precedence", winner.Source),
+			})
+		}
+	}
+
+	sort.SliceStable(conflicts, func(a, b int) bool { return i.CompareNames(conflicts[a].Host, conflicts[b].Host) < 0 })
+
+	return conflicts, nil
+}
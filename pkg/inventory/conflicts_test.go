@@ -0,0 +1,121 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/go-playground/validator/v10/non-standard/validators"
+)
+
+// testVarsource is a Varsource serving fixed host variables.
+type testVarsource map[string]map[string]string
+
+func (v testVarsource) GetHostVariables(host string) (map[string]string, error) {
+	return v[host], nil
+}
+
+func (v testVarsource) Close() {}
+
+func TestInventory_ExportConflicts(t *testing.T) {
+	cfg := &Config{}
+	cfg.Txt.Kv.Separator = ";"
+	cfg.Txt.Kv.Equalsign = "="
+	cfg.Txt.Keys.Os = "OS"
+	cfg.Txt.Keys.Env = "ENV"
+	cfg.Txt.Keys.Role = "ROLE"
+	cfg.Txt.Keys.Srv = "SRV"
+	cfg.Txt.Keys.Vars = "VARS"
+	cfg.Txt.Keys.ID = "ID"
+	cfg.Txt.Vars.Enabled = true
+	cfg.Txt.Vars.Separator = ","
+	cfg.Txt.Vars.Equalsign = "="
+
+	validator := validator.New()
+	validator.RegisterValidation("notblank", validators.NotBlank)
+	validator.RegisterValidation("safelist", isSafeList)
+	validator.RegisterValidation("safelistsep", isSafeListWithSeparator)
+
+	tests := []struct {
+		name       string
+		records    []*DatasourceRecord
+		varsources []Varsource
+		want       []*HostConflict
+	}{
+		{
+			name: "valid-no-conflicts",
+			records: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=;VARS=owner=team1", Source: "a"},
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=db;SRV=;VARS=owner=team1", Source: "b"},
+			},
+			varsources: []Varsource{testVarsource{"app01.infra.local": {"owner": "team1"}}},
+			want:       []*HostConflict{},
+		},
+		{
+			name: "valid-records",
+			records: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=;VARS=owner=team1", Source: "a"},
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=db;SRV=;VARS=owner=team2", Source: "b"},
+			},
+			want: []*HostConflict{
+				{
+					Host:       "app01.infra.local",
+					Key:        "owner",
+					Values:     []*ConflictValue{{Source: "a", Value: "team1"}, {Source: "b", Value: "team2"}},
+					Resolution: "value from b is used: later sources take precedence",
+				},
+			},
+		},
+		{
+			name: "valid-varsources",
+			records: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=;VARS=owner=team1", Source: "a"},
+			},
+			varsources: []Varsource{testVarsource{"app01.infra.local": {"owner": "team2"}}},
+			want: []*HostConflict{
+				{
+					Host:       "app01.infra.local",
+					Key:        "owner",
+					Values:     []*ConflictValue{{Source: "a", Value: "team1"}, {Source: "varsource 0", Value: "team2"}},
+					Resolution: "value from varsource 0 is used: later sources take precedence",
+				},
+			},
+		},
+		{
+			name: "valid-duplicate-id",
+			records: []*DatasourceRecord{
+				{Hostname: "app02.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=;VARS=;ID=4b3a6f1e-2c1d-4e5f-9a8b-7c6d5e4f3a2b", Source: "b"},
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=;VARS=;ID=4b3a6f1e-2c1d-4e5f-9a8b-7c6d5e4f3a2b", Source: "a"},
+			},
+			want: []*HostConflict{
+				{
+					Host:       "app01.infra.local",
+					Key:        "ID",
+					Values:     []*ConflictValue{{Source: "b", Value: "4b3a6f1e-2c1d-4e5f-9a8b-7c6d5e4f3a2b"}, {Source: "a", Value: "4b3a6f1e-2c1d-4e5f-9a8b-7c6d5e4f3a2b"}},
+					Resolution: "not resolved: both app02.infra.local and app01.infra.local use the identifier",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := &Inventory{
+				Config:     cfg,
+				Logger:     &testLogger{},
+				Validator:  validator,
+				Datasource: &testDatasource{records: tt.records},
+				Varsources: tt.varsources,
+			}
+
+			got, err := i.ExportConflicts()
+			if err != nil {
+				t.Errorf("Inventory.ExportConflicts() error = %v", err)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inventory.ExportConflicts() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,358 @@
+package inventory
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Knot DNS control channel datasource type.
+	KnotDatasourceType string = "knot"
+	// NSD control channel datasource type.
+	NSDDatasourceType string = "nsd"
+	// Default Knot DNS control socket.
+	knotDefaultSocket string = "/run/knot/knot.sock"
+	// Default NSD control channel address.
+	nsdDefaultSocket string = "127.0.0.1:8952"
+	// NSD control protocol header.
+	nsdControlHeader string = "NSDCT1"
+)
+
+// Knot DNS control protocol unit types.
+const (
+	knotCtlTypeEnd byte = iota
+	knotCtlTypeData
+	knotCtlTypeExtra
+	knotCtlTypeBlock
+)
+
+// Knot DNS control protocol data item indices. Item codes are offset by knotCtlCodeOffset.
+const (
+	knotCtlIdxCmd byte = iota
+	knotCtlIdxFlags
+	knotCtlIdxError
+	knotCtlIdxSection
+	knotCtlIdxItem
+	knotCtlIdxID
+	knotCtlIdxZone
+	knotCtlIdxOwner
+	knotCtlIdxTTL
+	knotCtlIdxType
+	knotCtlIdxData
+	knotCtlIdxFilter
+
+	// Offset of data item codes. Codes below the offset are unit types.
+	knotCtlCodeOffset byte = 16
+)
+
+type (
+	// zoneReader reads all records of a zone over the control channel of a DNS server.
+	zoneReader interface {
+		readZone(ctx context.Context, zone string) ([]dns.RR, error)
+	}
+
+	// knotControl reads zones using the 'zone-read' command of the Knot DNS control socket.
+	knotControl struct {
+		// Control socket path.
+		socket string
+		// Control channel timeout.
+		timeout time.Duration
+	}
+
+	// nsdControl makes NSD write zones to their zone files using the 'write' command of the NSD control channel and reads the zone files.
+	nsdControl struct {
+		// Control channel address or UNIX socket path.
+		address string
+		// Control channel TLS configuration, nil for UNIX sockets.
+		tls *tls.Config
+		// Control channel timeout.
+		timeout time.Duration
+		// Zone file path pattern.
+		zonefile string
+	}
+)
+
+// isUnixSocket checks if a control channel address is a UNIX socket path.
+func isUnixSocket(address string) bool {
+	return strings.HasPrefix(address, "/")
+}
+
+// dialControl connects to a control channel, using TLS if a TLS configuration is provided.
+func dialControl(ctx context.Context, address string, config *tls.Config) (net.Conn, error) {
+	network := "tcp"
+	if isUnixSocket(address) {
+		network = "unix"
+	}
+
+	var conn net.Conn
+	var err error
+	if config != nil {
+		conn, err = (&tls.Dialer{Config: config}).DialContext(ctx, network, address)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, network, address)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	return conn, nil
+}
+
+// newNSDControlTLS creates the TLS configuration of the NSD control channel.
+// NSD uses self-signed certificates, so the server certificate is verified against the configured one without checking the host name.
+func newNSDControlTLS(cfg *Config) (*tls.Config, error) {
+	c := cfg.Control.TLS
+
+	cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+	if err != nil {
+		return nil, errors.Wrap(err, "control client certificate loading failure")
+	}
+
+	ca, err := os.ReadFile(c.CA)
+	if err != nil {
+		return nil, errors.Wrap(err, "control server certificate loading failure")
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.Errorf("no certificates found in %s", c.CA)
+	}
+
+	return &tls.Config{
+		Certificates:       []tls.Certificate{cert},
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(raw [][]byte, _ [][]*x509.Certificate) error {
+			if len(raw) == 0 {
+				return errors.New("no server certificate presented")
+			}
+
+			leaf, err := x509.ParseCertificate(raw[0])
+			if err != nil {
+				return err
+			}
+
+			_, err = leaf.Verify(x509.VerifyOptions{Roots: pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+
+			return err
+		},
+	}, nil
+}
+
+// writeKnotUnit encodes a Knot DNS control protocol unit.
+func writeKnotUnit(w io.Writer, typ byte, items map[byte]string) error {
+	buf := []byte{typ}
+
+	for idx := knotCtlIdxCmd; idx <= knotCtlIdxFilter; idx++ {
+		value, ok := items[idx]
+		if !ok {
+			continue
+		}
+
+		buf = append(buf, knotCtlCodeOffset+idx)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(value)))
+		buf = append(buf, value...)
+	}
+
+	_, err := w.Write(buf)
+
+	return err
+}
+
+// readKnotUnit decodes a Knot DNS control protocol unit.
+func readKnotUnit(r *bufio.Reader) (byte, map[byte]string, error) {
+	items := make(map[byte]string)
+
+	typ, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if typ != knotCtlTypeData && typ != knotCtlTypeExtra {
+		return typ, items, nil
+	}
+
+	// Data items follow until the next unit type.
+	for {
+		code, err := r.Peek(1)
+		if err == io.EOF {
+			return typ, items, nil
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if code[0] < knotCtlCodeOffset {
+			return typ, items, nil
+		}
+		r.ReadByte()
+
+		var length [2]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return 0, nil, err
+		}
+
+		value := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(r, value); err != nil {
+			return 0, nil, err
+		}
+
+		items[code[0]-knotCtlCodeOffset] = string(value)
+	}
+}
+
+// readZone reads all records of a zone over the Knot DNS control socket.
+func (k *knotControl) readZone(ctx context.Context, zone string) ([]dns.RR, error) {
+	ctx, cancel := context.WithTimeout(ctx, k.timeout)
+	defer cancel()
+
+	conn, err := dialControl(ctx, k.socket, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	cmd := map[byte]string{knotCtlIdxCmd: "zone-read", knotCtlIdxZone: dns.Fqdn(zone)}
+	if err := writeKnotUnit(conn, knotCtlTypeData, cmd); err != nil {
+		return nil, err
+	}
+	if err := writeKnotUnit(conn, knotCtlTypeBlock, nil); err != nil {
+		return nil, err
+	}
+
+	rrs := make([]dns.RR, 0)
+	r := bufio.NewReader(conn)
+
+	// Owner, TTL and type may be omitted in the units carrying the subsequent records of an RRset.
+	var owner, ttl, rtype string
+	for {
+		typ, items, err := readKnotUnit(r)
+		if err != nil {
+			return nil, err
+		}
+
+		switch typ {
+		case knotCtlTypeData, knotCtlTypeExtra:
+		case knotCtlTypeBlock, knotCtlTypeEnd:
+			// Close the session gracefully.
+			writeKnotUnit(conn, knotCtlTypeEnd, nil)
+			return rrs, nil
+		default:
+			return nil, errors.Errorf("unexpected control unit type: %d", typ)
+		}
+
+		if msg := items[knotCtlIdxError]; len(msg) > 0 {
+			return nil, errors.New(msg)
+		}
+
+		if v, ok := items[knotCtlIdxOwner]; ok {
+			owner = v
+		}
+		if v, ok := items[knotCtlIdxTTL]; ok {
+			ttl = v
+		}
+		if v, ok := items[knotCtlIdxType]; ok {
+			rtype = v
+		}
+
+		data, ok := items[knotCtlIdxData]
+		if !ok {
+			continue
+		}
+
+		rr, err := dns.NewRR(fmt.Sprintf("%s %s IN %s %s", owner, ttl, rtype, data))
+		if err != nil {
+			return nil, errors.Wrap(err, "record parsing failure")
+		}
+		if rr != nil {
+			rrs = append(rrs, rr)
+		}
+	}
+}
+
+// readZone makes NSD write a zone to its zone file and reads all records of the zone from the file.
+func (n *nsdControl) readZone(ctx context.Context, zone string) ([]dns.RR, error) {
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+
+	name := strings.TrimSuffix(zone, ".")
+
+	conn, err := dialControl(ctx, n.address, n.tls)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := fmt.Fprintf(conn, "%s write %s\n", nsdControlHeader, name); err != nil {
+		return nil, err
+	}
+
+	// NSD closes the connection after the response.
+	resp, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg := strings.TrimSpace(string(resp)); msg != "ok" {
+		return nil, errors.Errorf("'write' command failure: %s", msg)
+	}
+
+	return parseZonefile(ctx, zone, fmt.Sprintf(n.zonefile, name))
+}
+
+// NewControlDatasource creates a DNS datasource that reads zones over the control channel of a Knot DNS or an NSD server.
+func NewControlDatasource(cfg *Config, log Logger) (*DNSDatasource, error) {
+	d, err := NewDNSDatasource(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+
+	socket := cfg.Control.Socket
+
+	switch cfg.Datasource {
+	case KnotDatasourceType:
+		if len(socket) == 0 {
+			socket = knotDefaultSocket
+		}
+
+		if !isUnixSocket(socket) {
+			return nil, errors.Errorf("knot control socket must be an absolute UNIX socket path: %s", socket)
+		}
+
+		d.Control = &knotControl{socket: socket, timeout: stageTimeout(cfg.Control.Timeout, cfg.DNS.Timeout)}
+	case NSDDatasourceType:
+		if len(socket) == 0 {
+			socket = nsdDefaultSocket
+		}
+
+		c := &nsdControl{address: socket, timeout: stageTimeout(cfg.Control.Timeout, cfg.DNS.Timeout), zonefile: cfg.Control.Zonefile}
+		if !isUnixSocket(socket) {
+			if c.tls, err = newNSDControlTLS(cfg); err != nil {
+				return nil, err
+			}
+		}
+
+		d.Control = c
+	default:
+		return nil, errors.Errorf("unknown control channel datasource type: %s", cfg.Datasource)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,247 @@
+package inventory
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveControl accepts a single control channel connection on a UNIX socket and handles it.
+func serveControl(t *testing.T, handle func(net.Conn)) string {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "control.sock")
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		handle(conn)
+	}()
+
+	return socket
+}
+
+// serveKnot emulates a Knot DNS server responding to the 'zone-read' command with the given units.
+func serveKnot(units []map[byte]string) func(net.Conn) {
+	return func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		for {
+			typ, items, err := readKnotUnit(r)
+			if err != nil || typ == knotCtlTypeBlock {
+				break
+			}
+
+			if typ == knotCtlTypeData && items[knotCtlIdxCmd] != "zone-read" {
+				units = []map[byte]string{{knotCtlIdxError: "invalid command"}}
+			}
+		}
+
+		for _, unit := range units {
+			writeKnotUnit(conn, knotCtlTypeData, unit)
+		}
+		writeKnotUnit(conn, knotCtlTypeBlock, nil)
+	}
+}
+
+// serveNSD emulates an NSD server responding to the 'write' command.
+func serveNSD(resp string) func(net.Conn) {
+	return func(conn net.Conn) {
+		bufio.NewReader(conn).ReadString('\n')
+		fmt.Fprint(conn, resp)
+	}
+}
+
+func Test_zoneReader_readZone(t *testing.T) {
+	dir := t.TempDir()
+	zonefile := "$TTL 3600\n" +
+		"@ IN SOA ns1.infra.local. admin.infra.local. 7 3600 600 86400 60\n" +
+		"app01 IN TXT \"OS=linux;ENV=dev;ROLE=app;SRV=tomcat\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "infra.local.zone"), []byte(zonefile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	soa := map[byte]string{
+		knotCtlIdxZone:  "infra.local.",
+		knotCtlIdxOwner: "infra.local.",
+		knotCtlIdxTTL:   "3600",
+		knotCtlIdxType:  "SOA",
+		knotCtlIdxData:  "ns1.infra.local. admin.infra.local. 7 3600 600 86400 60",
+	}
+	txt := map[byte]string{
+		knotCtlIdxZone:  "infra.local.",
+		knotCtlIdxOwner: "app01.infra.local.",
+		knotCtlIdxTTL:   "3600",
+		knotCtlIdxType:  "TXT",
+		knotCtlIdxData:  "\"OS=linux;ENV=dev;ROLE=app;SRV=tomcat\"",
+	}
+
+	tests := []struct {
+		name    string
+		reader  func(t *testing.T) zoneReader
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "valid-knot",
+			reader: func(t *testing.T) zoneReader {
+				return &knotControl{socket: serveControl(t, serveKnot([]map[byte]string{soa, txt, {knotCtlIdxData: "\"OS=linux;ENV=dev;ROLE=db;SRV=postgres\""}})), timeout: time.Second}
+			},
+			want: []string{
+				"infra.local.\t3600\tIN\tSOA\tns1.infra.local. admin.infra.local. 7 3600 600 86400 60",
+				"app01.infra.local.\t3600\tIN\tTXT\t\"OS=linux;ENV=dev;ROLE=app;SRV=tomcat\"",
+				"app01.infra.local.\t3600\tIN\tTXT\t\"OS=linux;ENV=dev;ROLE=db;SRV=postgres\"",
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid-nsd",
+			reader: func(t *testing.T) zoneReader {
+				return &nsdControl{address: serveControl(t, serveNSD("ok\n")), timeout: time.Second, zonefile: filepath.Join(dir, "%s.zone")}
+			},
+			want: []string{
+				"infra.local.\t3600\tIN\tSOA\tns1.infra.local. admin.infra.local. 7 3600 600 86400 60",
+				"app01.infra.local.\t3600\tIN\tTXT\t\"OS=linux;ENV=dev;ROLE=app;SRV=tomcat\"",
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid-route53",
+			reader: func(t *testing.T) zoneReader {
+				return newTestRoute53Control(t)
+			},
+			want: []string{
+				"infra.local.\t900\tIN\tSOA\tns1.infra.local. admin.infra.local. 7 7200 900 1209600 86400",
+				"app01.infra.local.\t300\tIN\tTXT\t\"OS=linux;ENV=dev;ROLE=app;SRV=tomcat\"",
+				"app01.infra.local.\t300\tIN\tTXT\t\"OS=linux;ENV=dev;ROLE=db;\" \"SRV=postgres\"",
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid-cloudflare",
+			reader: func(t *testing.T) zoneReader {
+				return newTestCloudflareControl(t, "test-token")
+			},
+			want: []string{
+				"app01.infra.local.\t300\tIN\tTXT\t\"OS=linux;ENV=dev;ROLE=app;SRV=tomcat\"",
+				"app01.infra.local.\t3600\tIN\tTXT\t\"OS=linux;ENV=dev;ROLE=db;\" \"SRV=postgres\"",
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid-powerdns",
+			reader: func(t *testing.T) zoneReader {
+				return newTestPowerDNSControl(t, "test-key")
+			},
+			want: []string{
+				"infra.local.\t3600\tIN\tSOA\tns1.infra.local. admin.infra.local. 7 3600 600 86400 60",
+				"app01.infra.local.\t300\tIN\tTXT\t\"OS=linux;ENV=dev;ROLE=app;SRV=tomcat\"",
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid-ssh",
+			reader: func(t *testing.T) zoneReader {
+				return newTestSSHControl(t, "dig axfr %s")
+			},
+			want: []string{
+				"infra.local.\t3600\tIN\tSOA\tns1.infra.local. admin.infra.local. 7 3600 600 86400 60",
+				"app01.infra.local.\t3600\tIN\tTXT\t\"OS=linux;ENV=dev;ROLE=app;SRV=tomcat\"",
+				"infra.local.\t3600\tIN\tSOA\tns1.infra.local. admin.infra.local. 7 3600 600 86400 60",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid-ssh-command",
+			reader: func(t *testing.T) zoneReader {
+				return newTestSSHControl(t, "drill axfr %s")
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid-route53-zone",
+			reader: func(t *testing.T) zoneReader {
+				r := newTestRoute53Control(t)
+				r.ids["infra.local."] = "ZMISSING"
+				return r
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid-cloudflare-token",
+			reader: func(t *testing.T) zoneReader {
+				return newTestCloudflareControl(t, "invalid-token")
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid-powerdns-key",
+			reader: func(t *testing.T) zoneReader {
+				return newTestPowerDNSControl(t, "invalid-key")
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid-knot-error",
+			reader: func(t *testing.T) zoneReader {
+				return &knotControl{socket: serveControl(t, serveKnot([]map[byte]string{{knotCtlIdxError: "no such zone found"}})), timeout: time.Second}
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid-nsd-error",
+			reader: func(t *testing.T) zoneReader {
+				return &nsdControl{address: serveControl(t, serveNSD("error zone infra.local not configured\n")), timeout: time.Second, zonefile: filepath.Join(dir, "%s.zone")}
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid-socket",
+			reader: func(t *testing.T) zoneReader {
+				return &knotControl{socket: filepath.Join(dir, "missing.sock"), timeout: time.Second}
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rrs, err := tt.reader(t).readZone(context.Background(), "infra.local.")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("zoneReader.readZone() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			var got []string
+			for _, rr := range rrs {
+				got = append(got, strings.TrimSpace(rr.String()))
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("zoneReader.readZone() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
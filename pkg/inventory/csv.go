@@ -0,0 +1,261 @@
+package inventory
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// CSV datasource type.
+	CSVDatasourceType string = "csv"
+)
+
+// CSVDatasource implements a read-only datasource backed by a CSV file, e.g. exported from a spreadsheet or a legacy CMDB.
+// Every row describes a single host record: host attributes are either mapped from columns or read as complete host records.
+type CSVDatasource struct {
+	// Inventory configuration.
+	Config *Config
+	// Inventory logger.
+	Logger Logger
+
+	// Field delimiter.
+	delimiter rune
+	// Comment character.
+	comment rune
+}
+
+// csvColumn resolves a column reference to a column index: a column name if the file has a header, a column number starting from 1 otherwise.
+func csvColumn(ref string, header []string) (int, error) {
+	if header == nil {
+		n, err := strconv.Atoi(ref)
+		if err != nil || n < 1 {
+			return 0, errors.Errorf("invalid column number: %s", ref)
+		}
+
+		return n - 1, nil
+	}
+
+	for n, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), ref) {
+			return n, nil
+		}
+	}
+
+	return 0, errors.Errorf("unknown column: %s", ref)
+}
+
+// rowRecords converts a CSV row into host records.
+func (c *CSVDatasource) rowRecords(row []string, source string, hostCol int, recordsCol int, columns map[string]int) ([]*DatasourceRecord, error) {
+	cfg := c.Config
+
+	cell := func(n int) (string, error) {
+		if n >= len(row) {
+			return "", errors.Errorf("row has no column %d", n+1)
+		}
+
+		return strings.TrimSpace(row[n]), nil
+	}
+
+	host, err := cell(hostCol)
+	if err != nil {
+		return nil, err
+	}
+	if len(host) == 0 {
+		return nil, errors.New("hostname is not set")
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	records := make([]*DatasourceRecord, 0)
+
+	if recordsCol >= 0 {
+		value, err := cell(recordsCol)
+		if err != nil {
+			return nil, err
+		}
+
+		// A quoted cell can hold several records, one per line.
+		for _, line := range strings.Split(value, "\n") {
+			if line = strings.TrimSpace(line); len(line) > 0 {
+				records = append(records, &DatasourceRecord{Hostname: host, Attributes: line, Source: source})
+			}
+		}
+
+		return records, nil
+	}
+
+	var cellErr error
+	attrs, err := mappedAttributes(cfg, func(key string) string {
+		n, ok := columns[strings.ToLower(key)]
+		if !ok {
+			return ""
+		}
+
+		value, err := cell(n)
+		if err != nil {
+			cellErr = err
+		}
+
+		return value
+	}, cfg.CSV.Defaults)
+	if cellErr != nil {
+		return nil, cellErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return append(records, &DatasourceRecord{Hostname: host, Attributes: attrs, Source: source}), nil
+}
+
+// read reads all host records from the CSV file. Rows that cannot be converted are skipped.
+func (c *CSVDatasource) read() ([]*DatasourceRecord, error) {
+	cfg := c.Config
+	log := c.Logger
+
+	file, err := os.Open(cfg.CSV.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.Comma = c.delimiter
+	r.Comment = c.comment
+	r.TrimLeadingSpace = true
+	// Rows with missing columns are skipped one by one instead of failing the whole file.
+	r.FieldsPerRecord = -1
+
+	var header []string
+	if cfg.CSV.Header {
+		if header, err = r.Read(); err != nil {
+			if err == io.EOF {
+				return make([]*DatasourceRecord, 0), nil
+			}
+
+			return nil, errors.Wrap(err, "header parsing failure")
+		}
+
+		// Spreadsheet applications often prepend a byte order mark.
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	hostCol, err := csvColumn(cfg.CSV.Hostname, header)
+	if err != nil {
+		return nil, errors.Wrap(err, "hostname column")
+	}
+
+	recordsCol := -1
+	columns := make(map[string]int)
+	if len(cfg.CSV.Records) > 0 {
+		if recordsCol, err = csvColumn(cfg.CSV.Records, header); err != nil {
+			return nil, errors.Wrap(err, "records column")
+		}
+	} else {
+		for key, ref := range cfg.CSV.Columns {
+			n, err := csvColumn(ref, header)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s column", key)
+			}
+
+			columns[strings.ToLower(key)] = n
+		}
+	}
+
+	records := make([]*DatasourceRecord, 0)
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "row parsing failure")
+		}
+
+		line, _ := r.FieldPos(0)
+		source := fmt.Sprintf("%s:%d", cfg.CSV.Path, line)
+
+		rowRecords, err := c.rowRecords(row, source, hostCol, recordsCol, columns)
+		if err != nil {
+			log.Warnf("skipping csv row: %s: %v", source, err)
+			continue
+		}
+
+		records = append(records, rowRecords...)
+	}
+
+	return records, nil
+}
+
+// GetAllRecords acquires all available host records.
+func (c *CSVDatasource) GetAllRecords() ([]*DatasourceRecord, error) {
+	records, err := c.read()
+	if err != nil {
+		return nil, errors.Wrap(err, "csv datasource failure")
+	}
+
+	return records, nil
+}
+
+// GetHostRecords reads the whole file and returns the records of a specific host.
+func (c *CSVDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error) {
+	all, err := c.GetAllRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*DatasourceRecord, 0)
+	for _, r := range all {
+		if strings.EqualFold(r.Hostname, host) {
+			records = append(records, r)
+		}
+	}
+
+	return records, nil
+}
+
+// PublishRecords is not supported: the file is expected to be maintained where it is exported from.
+func (c *CSVDatasource) PublishRecords(records []*DatasourceRecord) error {
+	return errors.New("publishing records is not supported by the csv datasource")
+}
+
+// Close does nothing: the file is only kept open while it is read.
+func (c *CSVDatasource) Close() {}
+
+// NewCSVDatasource creates a CSV datasource.
+func NewCSVDatasource(cfg *Config, log Logger) (*CSVDatasource, error) {
+	c := &CSVDatasource{Config: cfg, Logger: log}
+
+	if len(cfg.CSV.Path) == 0 {
+		return nil, errors.New("csv datasource initialization failure: file path is not set")
+	}
+
+	if utf8.RuneCountInString(cfg.CSV.Delimiter) != 1 {
+		return nil, errors.Errorf("csv datasource initialization failure: delimiter must be a single character: %q", cfg.CSV.Delimiter)
+	}
+	c.delimiter, _ = utf8.DecodeRuneInString(cfg.CSV.Delimiter)
+
+	switch utf8.RuneCountInString(cfg.CSV.Comment) {
+	case 0:
+	case 1:
+		c.comment, _ = utf8.DecodeRuneInString(cfg.CSV.Comment)
+	default:
+		return nil, errors.Errorf("csv datasource initialization failure: comment must be a single character: %q", cfg.CSV.Comment)
+	}
+
+	if c.delimiter == c.comment {
+		return nil, errors.New("csv datasource initialization failure: delimiter and comment characters are the same")
+	}
+
+	if len(cfg.CSV.Records) == 0 && len(cfg.CSV.Columns) == 0 {
+		return nil, errors.New("csv datasource initialization failure: neither the records column nor the column mapping is set")
+	}
+
+	return c, nil
+}
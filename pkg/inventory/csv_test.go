@@ -0,0 +1,110 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCSVDatasource_GetAllRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		header  bool
+		columns map[string]string
+		records string
+		want    []string
+		wantErr bool
+	}{
+		{
+			// Rows without a hostname or with missing columns are skipped, empty columns fall back to the defaults.
+			name:    "valid-columns",
+			data:    "\ufeffHost;OS family;Environment;Roles;Services\n# decommissioned\nAPP01.infra.local;Ubuntu;dev;app,web;tomcat\ndb01.infra.local;Ubuntu;;db;\n;Ubuntu;dev;lost;\nkiosk.infra.local;Ubuntu\n",
+			header:  true,
+			columns: map[string]string{"os": "os family", "env": "Environment", "role": "Roles", "srv": "Services"},
+			want: []string{
+				"app01.infra.local OS=ubuntu;ENV=dev;ROLE=app,web;SRV=tomcat;VARS= :3",
+				"db01.infra.local OS=ubuntu;ENV=prod;ROLE=db;SRV=;VARS= :4",
+			},
+		},
+		{
+			name:    "valid-records-no-header",
+			data:    "app01.infra.local;\"OS=linux;ENV=dev;ROLE=app;SRV=tomcat\nOS=linux;ENV=dev;ROLE=web;SRV=nginx\"\n",
+			records: "2",
+			want: []string{
+				"app01.infra.local OS=linux;ENV=dev;ROLE=app;SRV=tomcat :1",
+				"app01.infra.local OS=linux;ENV=dev;ROLE=web;SRV=nginx :1",
+			},
+		},
+		{
+			name:    "invalid-column",
+			data:    "hostname;os\napp01.infra.local;linux\n",
+			header:  true,
+			columns: map[string]string{"os": "os", "env": "environment"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid-quotes",
+			data:    "hostname;os\napp01.infra.local;\"linux\n",
+			header:  true,
+			columns: map[string]string{"os": "os"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "hosts.csv")
+			if err := os.WriteFile(path, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := &Config{}
+			cfg.Txt.Keys.Os = "OS"
+			cfg.Txt.Keys.Env = "ENV"
+			cfg.Txt.Keys.Role = "ROLE"
+			cfg.Txt.Keys.Srv = "SRV"
+			cfg.Txt.Keys.Vars = "VARS"
+			cfg.Txt.Keys.ID = "ID"
+			cfg.Txt.Kv.Separator = ";"
+			cfg.Txt.Kv.Equalsign = "="
+			cfg.CSV.Path = path
+			cfg.CSV.Delimiter = ";"
+			cfg.CSV.Comment = "#"
+			cfg.CSV.Header = tt.header
+			cfg.CSV.Hostname = "host"
+			if !tt.header {
+				cfg.CSV.Hostname = "1"
+			}
+			cfg.CSV.Records = tt.records
+			cfg.CSV.Columns = tt.columns
+			cfg.CSV.Defaults = map[string]string{"env": "prod"}
+			cfg.Normalize.Lowercase = true
+
+			c, err := NewCSVDatasource(cfg, &testLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			records, err := c.GetAllRecords()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CSVDatasource.GetAllRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			got := make([]string, 0, len(records))
+			for _, r := range records {
+				got = append(got, r.Hostname+" "+r.Attributes+" "+r.Source[len(path):])
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CSVDatasource.GetAllRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,18 +1,131 @@
 package inventory
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/pkg/errors"
 )
 
+// stageTimeout returns a stage timeout, falling back to the datasource timeout if the stage timeout is not set.
+func stageTimeout(stage time.Duration, fallback time.Duration) time.Duration {
+	if stage > 0 {
+		return stage
+	}
+
+	return fallback
+}
+
+// Error describes the datasource failure.
+func (e *DatasourceError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the datasource failure.
+func (e *DatasourceError) Unwrap() error {
+	return e.Err
+}
+
+// Error reports the number of zones that could not be read.
+func (e *ZoneFailureError) Error() string {
+	return fmt.Sprintf("%d of %d zones could not be read", len(e.Zones), e.Total)
+}
+
+// ErrNoHostRecords is returned by datasources reporting that a host has no records.
+var ErrNoHostRecords = errors.New("no host records found")
+
+// checkZones returns a ZoneFailureError if none of the zones read by a GetAllRecords call could be read or if more zones than allowed have failed.
+// The limit is not applied if set to 0.
+func checkZones(failed []string, total int, limit int) error {
+	if total == 0 || len(failed) == 0 {
+		return nil
+	}
+
+	if len(failed) < total && (limit == 0 || len(failed) <= limit) {
+		return nil
+	}
+
+	return &ZoneFailureError{Zones: failed, Total: total}
+}
+
 // NewDatasource creates a datasource based on the inventory configuration.
+// If several datasource types are listed, their records are aggregated by a CompositeDatasource.
+// If fault injection is enabled, the datasource is wrapped into a ChaosDatasource.
 func NewDatasource(cfg *Config, log Logger) (Datasource, error) {
+	var ds Datasource
+	var err error
+
+	if len(datasourceTypes(cfg.Datasource)) > 1 {
+		ds, err = NewCompositeDatasource(cfg, log)
+	} else {
+		ds, err = newDatasource(cfg, log)
+	}
+
+	if err != nil || !cfg.Chaos.Enabled {
+		return ds, err
+	}
+
+	chaos, err := NewChaosDatasource(ds, cfg, log)
+	if err != nil {
+		ds.Close()
+		return nil, err
+	}
+
+	return chaos, nil
+}
+
+// newDatasource creates a datasource of the type selected by the inventory configuration.
+func newDatasource(cfg *Config, log Logger) (Datasource, error) {
+	var ds Datasource
+	var err error
+
 	// Select datasource implementation.
 	switch cfg.Datasource {
+	case CloudDatasourceType:
+		ds, err = NewCloudDatasource(cfg, log)
+	case CloudflareDatasourceType:
+		ds, err = NewCloudflareDatasource(cfg, log)
+	case ConsulDatasourceType, NomadDatasourceType:
+		ds, err = NewWorkloadsDatasource(cfg, log)
+	case CSVDatasourceType:
+		ds, err = NewCSVDatasource(cfg, log)
 	case DNSDatasourceType:
-		return NewDNSDatasource(cfg, log)
+		ds, err = NewDNSDatasource(cfg, log)
 	case EtcdDatasourceType:
-		return NewEtcdDatasource(cfg, log)
+		ds, err = NewEtcdDatasource(cfg, log)
+	case ExecDatasourceType:
+		ds, err = NewExecDatasource(cfg, log)
+	case FreeIPADatasourceType:
+		ds, err = NewFreeIPADatasource(cfg, log)
+	case HTTPDatasourceType:
+		ds, err = NewHTTPDatasource(cfg, log)
+	case KnotDatasourceType, NSDDatasourceType:
+		ds, err = NewControlDatasource(cfg, log)
+	case KubernetesDatasourceType:
+		ds, err = NewKubernetesDatasource(cfg, log)
+	case LDAPDatasourceType:
+		ds, err = NewLDAPDatasource(cfg, log)
+	case PhpIPAMDatasourceType:
+		ds, err = NewPhpIPAMDatasource(cfg, log)
+	case PowerDNSDatasourceType:
+		ds, err = NewPowerDNSDatasource(cfg, log)
+	case PuppetDBDatasourceType:
+		ds, err = NewPuppetDBDatasource(cfg, log)
+	case Route53DatasourceType:
+		ds, err = NewRoute53Datasource(cfg, log)
+	case S3DatasourceType:
+		ds, err = NewS3Datasource(cfg, log)
+	case ServiceNowDatasourceType:
+		ds, err = NewServiceNowDatasource(cfg, log)
+	case SQLiteDatasourceType:
+		ds, err = NewSQLiteDatasource(cfg, log)
+	case SSHDatasourceType:
+		ds, err = NewSSHDatasource(cfg, log)
+	case VaultDatasourceType:
+		ds, err = NewVaultDatasource(cfg, log)
 	default:
 		return nil, errors.Errorf("unknown datasource type: %s", cfg.Datasource)
 	}
+
+	return ds, err
 }
@@ -0,0 +1,44 @@
+package inventory
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_stageTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		stage    time.Duration
+		fallback time.Duration
+		want     time.Duration
+	}{
+		{
+			name:     "valid-stage",
+			stage:    2 * time.Second,
+			fallback: 10 * time.Second,
+			want:     2 * time.Second,
+		},
+		{
+			// Stage timeouts may be longer than the datasource timeout.
+			name:     "valid-stage-longer",
+			stage:    time.Minute,
+			fallback: 10 * time.Second,
+			want:     time.Minute,
+		},
+		{
+			name:     "valid-fallback",
+			fallback: 10 * time.Second,
+			want:     10 * time.Second,
+		},
+		{
+			name: "valid-unset",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stageTimeout(tt.stage, tt.fallback); got != tt.want {
+				t.Errorf("stageTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package inventory
+
+import (
+	"strings"
+)
+
+// applyRoleDefaults fills the attributes missing from a host record with the default values of its roles.
+// For hosts with several roles, every missing attribute is taken from the first listed role that has a default value for it.
+func (i *Inventory) applyRoleDefaults(attrs *HostAttributes) {
+	cfg := i.Config
+
+	if len(cfg.Defaults) == 0 || len(attrs.Role) == 0 {
+		return
+	}
+
+	fields := map[string]*string{
+		strings.ToLower(cfg.Txt.Keys.Os):   &attrs.OS,
+		strings.ToLower(cfg.Txt.Keys.Srv):  &attrs.Srv,
+		strings.ToLower(cfg.Txt.Keys.Vars): &attrs.Vars,
+	}
+
+	for _, role := range strings.Split(attrs.Role, ",") {
+		// Viper folds map keys to lower case, so roles and attribute keys are matched case-insensitively.
+		defaults, ok := i.roleDefaults(role)
+		if !ok {
+			continue
+		}
+
+		for key, value := range defaults {
+			if field, ok := fields[strings.ToLower(key)]; ok && len(*field) == 0 {
+				*field = value
+			}
+		}
+	}
+}
+
+// roleDefaults returns the default attribute values of a role.
+func (i *Inventory) roleDefaults(role string) (map[string]string, bool) {
+	for name, defaults := range i.Config.Defaults {
+		if strings.EqualFold(name, role) {
+			return defaults, true
+		}
+	}
+
+	return nil, false
+}
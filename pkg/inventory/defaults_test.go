@@ -0,0 +1,56 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInventory_applyRoleDefaults(t *testing.T) {
+	cfg := &Config{}
+	cfg.Txt.Keys.Os = "OS"
+	cfg.Txt.Keys.Env = "ENV"
+	cfg.Txt.Keys.Role = "ROLE"
+	cfg.Txt.Keys.Srv = "SRV"
+	cfg.Txt.Keys.Vars = "VARS"
+	cfg.Defaults = map[string]map[string]string{
+		"db":  {"srv": "postgres", "os": "linux", "env": "prod"},
+		"app": {"SRV": "tomcat", "VARS": "java_heap=2g"},
+	}
+
+	tests := []struct {
+		name  string
+		attrs *HostAttributes
+		want  *HostAttributes
+	}{
+		{
+			name:  "defaults",
+			attrs: &HostAttributes{Env: "dev", Role: "db"},
+			want:  &HostAttributes{OS: "linux", Env: "dev", Role: "db", Srv: "postgres"},
+		},
+		{
+			name:  "overridden",
+			attrs: &HostAttributes{OS: "windows", Env: "dev", Role: "DB", Srv: "mssql"},
+			want:  &HostAttributes{OS: "windows", Env: "dev", Role: "DB", Srv: "mssql"},
+		},
+		{
+			name:  "multiple-roles",
+			attrs: &HostAttributes{Env: "dev", Role: "app,db"},
+			want:  &HostAttributes{OS: "linux", Env: "dev", Role: "app,db", Srv: "tomcat", Vars: "java_heap=2g"},
+		},
+		{
+			name:  "unknown-role",
+			attrs: &HostAttributes{OS: "linux", Env: "dev", Role: "web"},
+			want:  &HostAttributes{OS: "linux", Env: "dev", Role: "web"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := &Inventory{Config: cfg}
+
+			i.applyRoleDefaults(tt.attrs)
+			if !reflect.DeepEqual(tt.attrs, tt.want) {
+				t.Errorf("Inventory.applyRoleDefaults() = %+v, want %+v", tt.attrs, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package inventory
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ISC DHCP lease file variable source type.
+	ISCDHCPVarsourceType string = "dhcp-isc"
+	// Kea DHCP lease file (memfile) variable source type.
+	KeaDHCPVarsourceType string = "dhcp-kea"
+	// Time format used in ISC DHCP lease files.
+	iscDHCPTimeFormat string = "2006/01/02 15:04:05"
+	// Kea lease state of an active lease.
+	keaLeaseStateDefault string = "0"
+)
+
+type (
+	// DHCPLease represents an active DHCP lease.
+	DHCPLease struct {
+		// Leased IP address.
+		Address string
+		// Client hardware address.
+		HWAddress string
+		// Client hostname.
+		Hostname string
+		// Lease expiration time.
+		Expires time.Time
+	}
+
+	// DHCPVarsource implements a variable source that reads active leases from an ISC DHCP or Kea lease file.
+	DHCPVarsource struct {
+		// Variable source specification.
+		Spec VarsourceSpec
+
+		// Guards the lease cache.
+		mu sync.Mutex
+		// Modification time of the lease file when it was last parsed.
+		modTime time.Time
+		// Active leases from the last parse, mapped by lower case hostname.
+		leases map[string]*DHCPLease
+	}
+)
+
+// addLease adds an active lease to a lease map, keeping the lease that expires last for every hostname.
+func addLease(leases map[string]*DHCPLease, lease *DHCPLease, now time.Time) {
+	if len(lease.Hostname) == 0 || len(lease.Address) == 0 || (!lease.Expires.IsZero() && lease.Expires.Before(now)) {
+		return
+	}
+
+	name := strings.ToLower(strings.TrimSuffix(lease.Hostname, "."))
+	if l, ok := leases[name]; !ok || lease.Expires.After(l.Expires) {
+		leases[name] = lease
+	}
+}
+
+// parseISCLeases parses an ISC DHCP lease file, returning active leases mapped by lower case hostname.
+func parseISCLeases(r io.Reader, now time.Time) (map[string]*DHCPLease, error) {
+	leases := make(map[string]*DHCPLease)
+
+	var lease *DHCPLease
+	var active bool
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimSuffix(line, ";"))
+
+		switch {
+		case fields[0] == "lease" && len(fields) >= 2:
+			lease = &DHCPLease{Address: fields[1]}
+			active = false
+		case lease == nil:
+			continue
+		case fields[0] == "}":
+			if active {
+				addLease(leases, lease, now)
+			}
+			lease = nil
+		case fields[0] == "ends" && len(fields) >= 4:
+			if t, err := time.Parse(iscDHCPTimeFormat, fields[2]+" "+fields[3]); err == nil {
+				lease.Expires = t
+			}
+		case fields[0] == "binding" && len(fields) >= 3 && fields[1] == "state":
+			active = fields[2] == "active"
+		case fields[0] == "hardware" && len(fields) >= 3:
+			lease.HWAddress = fields[2]
+		case fields[0] == "client-hostname" && len(fields) >= 2:
+			lease.Hostname = strings.Trim(strings.Join(fields[1:], " "), "\"")
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "lease file parsing failure")
+	}
+
+	return leases, nil
+}
+
+// parseKeaLeases parses a Kea memfile lease file (CSV), returning active leases mapped by lower case hostname.
+func parseKeaLeases(r io.Reader, now time.Time) (map[string]*DHCPLease, error) {
+	leases := make(map[string]*DHCPLease)
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return leases, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "lease file parsing failure")
+	}
+
+	columns := make(map[string]int)
+	for n, name := range header {
+		columns[name] = n
+	}
+
+	for _, name := range []string{"address", "hwaddr", "expire", "hostname", "state"} {
+		if _, ok := columns[name]; !ok {
+			return nil, errors.Errorf("lease file parsing failure: missing column %s", name)
+		}
+	}
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "lease file parsing failure")
+		}
+
+		if len(row) < len(header) || row[columns["state"]] != keaLeaseStateDefault {
+			continue
+		}
+
+		lease := &DHCPLease{
+			Address:   row[columns["address"]],
+			HWAddress: row[columns["hwaddr"]],
+			Hostname:  row[columns["hostname"]],
+		}
+
+		if expire, err := strconv.ParseInt(row[columns["expire"]], 10, 64); err == nil {
+			lease.Expires = time.Unix(expire, 0)
+		}
+
+		addLease(leases, lease, now)
+	}
+
+	return leases, nil
+}
+
+// load parses the lease file if it has changed since it was last parsed.
+func (v *DHCPVarsource) load() (map[string]*DHCPLease, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	info, err := os.Stat(v.Spec.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "lease file read failure")
+	}
+
+	if v.leases != nil && info.ModTime().Equal(v.modTime) {
+		return v.leases, nil
+	}
+
+	file, err := os.Open(v.Spec.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "lease file read failure")
+	}
+	defer file.Close()
+
+	var leases map[string]*DHCPLease
+	switch strings.ToLower(v.Spec.Type) {
+	case KeaDHCPVarsourceType:
+		leases, err = parseKeaLeases(file, time.Now())
+	default:
+		leases, err = parseISCLeases(file, time.Now())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	v.leases = leases
+	v.modTime = info.ModTime()
+
+	return leases, nil
+}
+
+// GetHostVariables acquires the address of a host from its active DHCP lease.
+// Leases are matched by the full hostname first, then by its first label.
+func (v *DHCPVarsource) GetHostVariables(host string) (map[string]string, error) {
+	variables := make(map[string]string)
+
+	leases, err := v.load()
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.ToLower(strings.TrimSuffix(host, "."))
+	lease, ok := leases[name]
+	if !ok {
+		if lease, ok = leases[strings.Split(name, ".")[0]]; !ok {
+			return variables, nil
+		}
+	}
+
+	variables["ansible_host"] = lease.Address
+	if len(lease.HWAddress) > 0 {
+		variables["dhcp_hwaddr"] = lease.HWAddress
+	}
+	if !lease.Expires.IsZero() {
+		variables["dhcp_expires"] = lease.Expires.UTC().Format(time.RFC3339)
+	}
+
+	return variables, nil
+}
+
+// Close does nothing: the lease file is only kept open while it is parsed.
+func (v *DHCPVarsource) Close() {}
@@ -0,0 +1,141 @@
+package inventory
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_parseISCLeases(t *testing.T) {
+	now := time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)
+
+	type args struct {
+		leases string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    map[string]*DHCPLease
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			args: args{
+				leases: `# The format of this file is documented in the dhcpd.leases(5) manual page.
+lease 10.0.0.1 {
+  starts 1 2024/01/01 00:00:00;
+  ends 1 2024/01/01 12:00:00;
+  binding state active;
+  hardware ethernet 00:11:22:33:44:55;
+  client-hostname "app01";
+}
+lease 10.0.0.2 {
+  starts 0 2023/12/31 00:00:00;
+  ends 0 2023/12/31 12:00:00;
+  binding state active;
+  client-hostname "app02";
+}
+lease 10.0.0.3 {
+  starts 1 2024/01/01 00:00:00;
+  ends 1 2024/01/01 12:00:00;
+  binding state free;
+  client-hostname "app03";
+}
+lease 10.0.0.4 {
+  starts 1 2024/01/01 00:00:00;
+  ends never;
+  binding state active;
+  client-hostname "App04";
+}
+`,
+			},
+			want: map[string]*DHCPLease{
+				"app01": {Address: "10.0.0.1", HWAddress: "00:11:22:33:44:55", Hostname: "app01", Expires: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)},
+				"app04": {Address: "10.0.0.4", Hostname: "App04"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid-renewed",
+			args: args{
+				leases: `lease 10.0.0.1 {
+  ends 1 2024/01/01 12:00:00;
+  binding state active;
+  client-hostname "app01";
+}
+lease 10.0.0.5 {
+  ends 1 2024/01/01 18:00:00;
+  binding state active;
+  client-hostname "app01";
+}
+`,
+			},
+			want: map[string]*DHCPLease{
+				"app01": {Address: "10.0.0.5", Hostname: "app01", Expires: time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC)},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseISCLeases(strings.NewReader(tt.args.leases), now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseISCLeases() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseISCLeases() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseKeaLeases(t *testing.T) {
+	now := time.Unix(1704067200, 0)
+
+	type args struct {
+		leases string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    map[string]*DHCPLease
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			args: args{
+				leases: `address,hwaddr,client_id,valid_lifetime,expire,subnet_id,fqdn_fwd,fqdn_rev,hostname,state,user_context,pool_id
+10.0.0.1,00:11:22:33:44:55,,3600,1704070800,1,0,0,app01.infra.local.,0,,0
+10.0.0.2,00:11:22:33:44:56,,3600,1704063600,1,0,0,app02.infra.local.,0,,0
+10.0.0.3,00:11:22:33:44:57,,3600,1704070800,1,0,0,app03.infra.local.,1,,0
+`,
+			},
+			want: map[string]*DHCPLease{
+				"app01.infra.local": {Address: "10.0.0.1", HWAddress: "00:11:22:33:44:55", Hostname: "app01.infra.local.", Expires: time.Unix(1704070800, 0)},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid-missing-column",
+			args: args{
+				leases: "address,hwaddr\n10.0.0.1,00:11:22:33:44:55\n",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseKeaLeases(strings.NewReader(tt.args.leases), now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseKeaLeases() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseKeaLeases() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
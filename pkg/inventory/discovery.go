@@ -0,0 +1,97 @@
+package inventory
+
+import (
+	"context"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+// discoverZones returns the zones of the resolver search domains and the zones delegated from the configured parent domains.
+// Failures are logged and the affected search domains and parents are skipped.
+func (d *DNSDatasource) discoverZones() []string {
+	cfg := d.Config
+	log := d.Logger
+	zones := make([]string, 0)
+
+	if cfg.DNS.Discovery.Search {
+		conf, err := dns.ClientConfigFromFile(cfg.DNS.Discovery.Resolvconf)
+		if err != nil {
+			log.Warnf("[%s] skipping resolver search domains: %v", cfg.DNS.Discovery.Resolvconf, err)
+		} else {
+			for _, domain := range conf.Search {
+				zone, err := d.lookupZone(context.Background(), domain)
+				if err != nil {
+					log.Warnf("[%s] skipping search domain: %v", domain, err)
+					continue
+				}
+
+				log.Debugf("[%s] found zone %s", domain, zone)
+				zones = append(zones, zone)
+			}
+		}
+	}
+
+	for _, parent := range cfg.DNS.Discovery.Parents {
+		rrs, err := d.transferZone(context.Background(), parent)
+		if err != nil {
+			log.Warnf("[%s] skipping parent domain: %v", parent, err)
+			continue
+		}
+
+		children := parseDelegations(dns.Fqdn(parent), rrs)
+		log.Debugf("[%s] found %d delegated zones", parent, len(children))
+
+		zones = append(zones, strings.ToLower(dns.Fqdn(parent)))
+		zones = append(zones, children...)
+	}
+
+	return zones
+}
+
+// lookupZone returns the zone a domain belongs to: the domain itself if it is a zone apex, or the enclosing zone
+// named by the SOA record in the authority section of the response.
+func (d *DNSDatasource) lookupZone(ctx context.Context, domain string) (string, error) {
+	domain = dns.Fqdn(domain)
+
+	rx, err := d.exchange(ctx, newDNSQuery(d.Config, domain, dns.TypeSOA))
+	if err != nil {
+		return "", errors.Wrap(err, "SOA query failure")
+	}
+
+	if rx.Rcode != dns.RcodeSuccess && rx.Rcode != dns.RcodeNameError {
+		return "", errors.Errorf("SOA query failure: %s", dns.RcodeToString[rx.Rcode])
+	}
+
+	for _, rr := range append(rx.Answer, rx.Ns...) {
+		if soa, ok := rr.(*dns.SOA); ok && dns.IsSubDomain(soa.Hdr.Name, domain) {
+			return strings.ToLower(soa.Hdr.Name), nil
+		}
+	}
+
+	return "", errors.New("no SOA record found")
+}
+
+// parseDelegations extracts the zones delegated from a parent zone: owners of NS records below the zone apex.
+func parseDelegations(parent string, rrs []dns.RR) []string {
+	zones := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, rr := range rrs {
+		ns, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+
+		name := strings.ToLower(ns.Hdr.Name)
+		if dns.CountLabel(name) <= dns.CountLabel(parent) || !dns.IsSubDomain(parent, name) || seen[name] {
+			continue
+		}
+
+		seen[name] = true
+		zones = append(zones, name)
+	}
+
+	return zones
+}
@@ -0,0 +1,151 @@
+package inventory
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func Test_parseDelegations(t *testing.T) {
+	rr := func(s string) dns.RR {
+		r, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name string
+		rrs  []dns.RR
+		want []string
+	}{
+		{
+			name: "valid",
+			rrs: []dns.RR{
+				rr("infra.local. 3600 IN SOA ns1.infra.local. admin.infra.local. 1 3600 600 86400 60"),
+				rr("infra.local. 3600 IN NS ns1.infra.local."),
+				rr("DC1.infra.local. 3600 IN NS ns1.dc1.infra.local."),
+				rr("dc1.infra.local. 3600 IN NS ns2.dc1.infra.local."),
+				rr("ns1.dc1.infra.local. 3600 IN A 192.0.2.1"),
+				rr("dc2.infra.local. 3600 IN NS ns1.infra.local."),
+				rr("infra.local. 3600 IN SOA ns1.infra.local. admin.infra.local. 1 3600 600 86400 60"),
+			},
+			want: []string{"dc1.infra.local.", "dc2.infra.local."},
+		},
+		{
+			name: "valid-empty",
+			rrs: []dns.RR{
+				rr("infra.local. 3600 IN NS ns1.infra.local."),
+				rr("app01.infra.local. 3600 IN TXT \"OS=linux;ENV=dev;ROLE=app;SRV=tomcat\""),
+			},
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDelegations("infra.local.", tt.rrs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDelegations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// startTestSOAServer starts a local DNS server answering SOA queries for the 'infra.local.' and 'other.local.' zones.
+// Queries for other domains are refused.
+func startTestSOAServer(t *testing.T) string {
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+
+		name := r.Question[0].Name
+		for _, zone := range []string{"infra.local.", "other.local."} {
+			if !dns.IsSubDomain(zone, name) {
+				continue
+			}
+
+			soa, _ := dns.NewRR(zone + " 3600 IN SOA ns1." + zone + " admin." + zone + " 1 3600 600 86400 60")
+			if name == zone {
+				m.Answer = append(m.Answer, soa)
+			} else {
+				m.Rcode = dns.RcodeNameError
+				m.Ns = append(m.Ns, soa)
+			}
+
+			w.WriteMsg(m)
+			return
+		}
+
+		m.Rcode = dns.RcodeRefused
+		w.WriteMsg(m)
+	})
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &dns.Server{PacketConn: pc, Handler: handler}
+	go server.ActivateAndServe()
+	t.Cleanup(func() { server.Shutdown() })
+
+	return pc.LocalAddr().String()
+}
+
+func TestDNSDatasource_zones_discovery(t *testing.T) {
+	resolvconf := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := os.WriteFile(resolvconf, []byte("nameserver 127.0.0.1\nsearch infra.local dc1.infra.local other.local example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		search     bool
+		resolvconf string
+		want       []string
+	}{
+		{
+			name:       "valid-search",
+			search:     true,
+			resolvconf: resolvconf,
+			want:       []string{"infra.local.", "other.local."},
+		},
+		{
+			name:       "valid-search-disabled",
+			search:     false,
+			resolvconf: resolvconf,
+			want:       []string{"infra.local."},
+		},
+		{
+			name:       "invalid-resolvconf",
+			search:     true,
+			resolvconf: filepath.Join(t.TempDir(), "missing.conf"),
+			want:       []string{"infra.local."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.DNS.Server = startTestSOAServer(t)
+			cfg.DNS.Timeout = 5 * time.Second
+			cfg.DNS.Zones = []string{"infra.local."}
+			cfg.DNS.Discovery.Search = tt.search
+			cfg.DNS.Discovery.Resolvconf = tt.resolvconf
+
+			d, err := NewDNSDatasource(cfg, &testLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+
+			if got := d.zones(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DNSDatasource.zones() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,14 @@
 package inventory
 
 import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/miekg/dns"
@@ -15,6 +22,8 @@ const (
 	dnsRrTxtType uint16 = 16
 	// Number of the field that contains the TXT record value.
 	dnsRrTxtField int = 1
+	// Supported catalog zone schema version.
+	catalogZoneVersion string = "2"
 )
 
 type (
@@ -28,6 +37,31 @@ type (
 		Client *dns.Client
 		// DNS zone transfer parameters.
 		Transfer *dns.Transfer
+		// Zone serials seen by the last GetAllRecords call, guarded by serialsMu.
+		Serials map[string]uint32
+		// Zones skipped by the last GetAllRecords call, guarded by serialsMu.
+		Failed []string
+		// Configured zones and member zones of catalog zones, populated on first use and refreshed by GetAllRecords, guarded by zonesMu.
+		Zones []string
+		// Idle persistent TCP connections reused for DNS queries, nil if connection reuse is disabled.
+		Conns chan *dns.Conn
+		// Control channel client used to read zones instead of the DNS server, nil unless a control channel datasource type is used.
+		Control zoneReader
+
+		// Trace file writer, nil unless tracing is enabled.
+		trace *tracer
+		// GSS-TSIG context negotiator, nil unless GSS-TSIG is enabled.
+		gss *gssTSIG
+
+		// Guards the zone list, held while it is populated so catalog zones are transferred once.
+		zonesMu sync.Mutex
+		// Guards the zone serials.
+		serialsMu sync.Mutex
+
+		// Guards the no-transfer record cache.
+		cacheMu sync.Mutex
+		// No-transfer records by zone, used by per-host lookups if caching is enabled.
+		cache map[string]*notransferCache
 	}
 )
 
@@ -47,6 +81,7 @@ func (d *DNSDatasource) processRecord(rr dns.RR) *DatasourceRecord {
 	return &DatasourceRecord{
 		Hostname:   name,
 		Attributes: attrs,
+		Source:     rr.Header().Name,
 	}
 }
 
@@ -73,13 +108,48 @@ func (d *DNSDatasource) makeFQDN(host string, zone string) string {
 	return strings.TrimPrefix(dns.Fqdn(name+"."+domain), ".")
 }
 
+// notransferHosts returns the FQDNs of the no-transfer hosts of a zone.
+func (d *DNSDatasource) notransferHosts(zone string) []string {
+	cfg := d.Config
+
+	hosts := []string{cfg.DNS.Notransfer.Host}
+	for _, spec := range cfg.DNS.Notransfer.Zones {
+		if strings.EqualFold(dns.Fqdn(spec.Zone), dns.Fqdn(zone)) && len(spec.Hosts) > 0 {
+			hosts = spec.Hosts
+			break
+		}
+	}
+
+	names := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		names = append(names, d.makeFQDN(host, zone))
+	}
+
+	return names
+}
+
+// getNotransferHosts acquires and merges the TXT records of all no-transfer hosts of a zone.
+func (d *DNSDatasource) getNotransferHosts(ctx context.Context, zone string) ([]dns.RR, error) {
+	records := make([]dns.RR, 0)
+
+	for _, host := range d.notransferHosts(zone) {
+		rrs, err := d.getHost(ctx, host)
+		if err != nil {
+			return nil, errors.Wrap(err, host)
+		}
+
+		records = append(records, rrs...)
+	}
+
+	return records, nil
+}
+
 // findZone selects a matching zone from the datasource configuration based on the hostname.
 func (d *DNSDatasource) findZone(host string) (string, error) {
-	cfg := d.Config
 	var zone string
 
 	// Try finding a matching zone in the configuration.
-	for _, z := range cfg.DNS.Zones {
+	for _, z := range d.zones() {
 		if strings.HasSuffix(strings.Trim(host, "."), strings.Trim(z, ".")) {
 			zone = z
 			break
@@ -93,72 +163,564 @@ func (d *DNSDatasource) findZone(host string) (string, error) {
 	return zone, nil
 }
 
-// getZone acquires TXT records for all hosts in a specific zone.
-func (d *DNSDatasource) getZone(zone string) ([]dns.RR, error) {
+// exchange performs a DNS query, reusing a persistent connection if connection reuse is enabled.
+func (d *DNSDatasource) exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	cfg := d.Config
+
+	d.trace.dnsMessage("query", cfg.DNS.Server, msg, nil)
+
+	rx, err := d.exchangeMsg(ctx, msg)
+	d.trace.dnsMessage("response", cfg.DNS.Server, rx, err)
+
+	return rx, err
+}
+
+// exchangeMsg implements exchange.
+func (d *DNSDatasource) exchangeMsg(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	cfg := d.Config
+
+	if d.Conns == nil {
+		rx, _, err := d.Client.ExchangeContext(ctx, msg, cfg.DNS.Server)
+		return rx, err
+	}
+
+	// Idle connections may have been closed by the server, so a failed query is retried once over a new connection.
+	for attempt := 0; ; attempt++ {
+		var conn *dns.Conn
+		var err error
+
+		reused := false
+		select {
+		case conn = <-d.Conns:
+			reused = true
+		default:
+			if conn, err = d.Client.DialContext(ctx, cfg.DNS.Server); err != nil {
+				return nil, err
+			}
+		}
+
+		rx, _, err := d.Client.ExchangeWithConnContext(ctx, msg, conn)
+		if err != nil {
+			conn.Close()
+
+			if reused && attempt == 0 && ctx.Err() == nil {
+				continue
+			}
+
+			return nil, err
+		}
+
+		select {
+		case d.Conns <- conn:
+		default:
+			conn.Close()
+		}
+
+		return rx, nil
+	}
+}
+
+// getSerial acquires the SOA serial of a specific zone.
+func (d *DNSDatasource) getSerial(ctx context.Context, zone string) (uint32, error) {
+	cfg := d.Config
+
+	rx, err := d.exchange(ctx, newDNSQuery(cfg, zone, dns.TypeSOA))
+	if err != nil {
+		return 0, errors.Wrap(err, "dns request failed")
+	}
+
+	for _, rr := range rx.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Serial, nil
+		}
+	}
+
+	return 0, errors.New("no SOA record found")
+}
+
+// transferZone acquires all records of a specific zone with a zone transfer, aborting it when the context is done.
+func (d *DNSDatasource) transferZone(ctx context.Context, zone string) ([]dns.RR, error) {
 	cfg := d.Config
+	log := d.Logger
 	records := make([]dns.RR, 0)
 
 	msg := new(dns.Msg)
 	msg.SetAxfr(dns.Fqdn(zone))
 
+	transfer := *d.Transfer
+
+	var verifier *tsigVerifier
 	if cfg.DNS.Tsig.Enabled {
-		d.Transfer.TsigSecret = map[string]string{cfg.DNS.Tsig.Key: cfg.DNS.Tsig.Secret}
-		msg.SetTsig(cfg.DNS.Tsig.Key, cfg.DNS.Tsig.Algo, 300, time.Now().Unix())
+		verifier = &tsigVerifier{secret: cfg.DNS.Tsig.Secret, strict: cfg.DNS.Tsig.Strict}
+		key := cfg.DNS.Tsig.Key
+
+		// GSS-TSIG messages are signed with a security context negotiated with the server, named by its TKEY key name.
+		if d.gss != nil {
+			c, err := d.gss.context(ctx, cfg.DNS.Server, transfer.DialTimeout)
+			if err != nil {
+				return nil, errors.Wrap(err, "zone transfer failed")
+			}
+
+			verifier.gss = c
+			key = c.name
+		}
+
+		transfer.TsigProvider = verifier
+		msg.SetTsig(key, cfg.DNS.Tsig.Algo, 300, time.Now().Unix())
+	}
+
+	// Dial the server here to be able to abort the transfer by closing the connection.
+	dialer := &net.Dialer{Timeout: transfer.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", cfg.DNS.Server)
+	if err != nil {
+		return nil, errors.Wrap(err, "zone transfer failed")
 	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	transfer.Conn = &dns.Conn{Conn: conn}
 
 	// Perform the transfer.
-	c, err := d.Transfer.In(msg, cfg.DNS.Server)
+	d.trace.dnsMessage("transfer", cfg.DNS.Server, msg, nil)
+	c, err := transfer.In(msg, cfg.DNS.Server)
 	if err != nil {
+		d.trace.dnsMessage("transfer", cfg.DNS.Server, nil, err)
+		d.discardGSS(verifier)
 		return nil, errors.Wrap(err, "zone transfer failed")
 	}
 
-	// Process transferred records. Ignore anything that is not a TXT recordd. Ignore the special inventory record as well.
+	var messages int
 	for e := range c {
-		for _, rr := range e.RR {
-			if rr.Header().Rrtype == dnsRrTxtType && rr.Header().Name != d.makeFQDN(cfg.DNS.Notransfer.Host, zone) {
-				records = append(records, rr)
+		// A failed transfer would otherwise yield an incomplete zone.
+		if e.Error != nil {
+			d.trace.dnsMessage("transfer", cfg.DNS.Server, nil, e.Error)
+
+			if ctx.Err() != nil {
+				return nil, errors.Wrap(ctx.Err(), "zone transfer failed")
 			}
+			d.discardGSS(verifier)
+
+			return nil, errors.Wrap(e.Error, "zone transfer failed")
+		}
+		messages++
+
+		d.trace.dnsRecords(cfg.DNS.Server, zone, messages, e.RR)
+		records = append(records, e.RR...)
+	}
+
+	if verifier != nil {
+		if cfg.DNS.Tsig.Strict && verifier.signed < messages {
+			return nil, errors.Errorf("zone transfer failed: %d of %d messages are not signed", messages-verifier.signed, messages)
+		}
+
+		if verifier.failed > 0 {
+			log.Warnf("[%s] %d of %d zone transfer messages carry an invalid TSIG signature", zone, verifier.failed, messages)
 		}
 	}
 
 	return records, nil
 }
 
-// getHost acquires all TXT records for a specific host.
-func (d *DNSDatasource) getHost(host string) ([]dns.RR, error) {
+// discardGSS drops the GSS-TSIG context of a failed zone transfer: the server may have forgotten it, e.g. after a restart.
+func (d *DNSDatasource) discardGSS(verifier *tsigVerifier) {
+	if d.gss != nil && verifier != nil && verifier.gss != nil {
+		d.gss.discard(verifier.gss)
+	}
+}
+
+// getZone acquires TXT records for all hosts in a specific zone and the zone serial.
+func (d *DNSDatasource) getZone(ctx context.Context, zone string) ([]dns.RR, uint32, error) {
+	rrs, err := d.transferZone(ctx, zone)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	records, serial := d.filterZone(zone, rrs)
+
+	return records, serial, nil
+}
+
+// filterZone selects the inventory TXT records from all records of a specific zone and finds the zone serial.
+// In the no-transfer mode only the records of the special inventory hosts are selected, otherwise they are ignored.
+func (d *DNSDatasource) filterZone(zone string, rrs []dns.RR) ([]dns.RR, uint32) {
+	cfg := d.Config
+	records := make([]dns.RR, 0)
+	var serial uint32
+
+	special := d.notransferHosts(zone)
+	for _, rr := range rrs {
+		if soa, ok := rr.(*dns.SOA); ok {
+			serial = soa.Serial
+		}
+
+		if rr.Header().Rrtype != dnsRrTxtType {
+			continue
+		}
+
+		isSpecial := slices.ContainsFunc(special, func(host string) bool { return strings.EqualFold(host, rr.Header().Name) })
+		if isSpecial == cfg.DNS.Notransfer.Enabled {
+			records = append(records, rr)
+		}
+	}
+
+	return records, serial
+}
+
+// zonefileSource returns the zone file configured for a specific zone, if any.
+func (d *DNSDatasource) zonefileSource(zone string) string {
+	for _, spec := range d.Config.DNS.Zonefile.Zones {
+		if strings.EqualFold(dns.Fqdn(spec.Zone), dns.Fqdn(zone)) {
+			return spec.Source
+		}
+	}
+
+	return ""
+}
+
+// openZonefile opens a zone file on disk or fetches it over HTTP(S).
+func openZonefile(ctx context.Context, source string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.Open(source)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+// parseZonefile reads all records of a specific zone from a BIND zone file.
+func parseZonefile(ctx context.Context, zone string, source string) ([]dns.RR, error) {
+	r, err := openZonefile(ctx, source)
+	if err != nil {
+		return nil, errors.Wrap(err, "zone file loading failure")
+	}
+	defer r.Close()
+
+	return parseZone(r, zone, source)
+}
+
+// parseZone reads all records of a specific zone in the zone file format. The source is only used in error messages.
+func parseZone(r io.Reader, zone string, source string) ([]dns.RR, error) {
+	rrs := make([]dns.RR, 0)
+
+	parser := dns.NewZoneParser(r, dns.Fqdn(zone), source)
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		rrs = append(rrs, rr)
+	}
+
+	if err := parser.Err(); err != nil {
+		return nil, errors.Wrap(err, "zone file parsing failure")
+	}
+
+	return rrs, nil
+}
+
+// readZonefile acquires TXT records for all hosts in a specific zone and the zone serial from a BIND zone file.
+func (d *DNSDatasource) readZonefile(ctx context.Context, zone string, source string) ([]dns.RR, uint32, error) {
+	rrs, err := parseZonefile(ctx, zone, source)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	records, serial := d.filterZone(zone, rrs)
+
+	return records, serial, nil
+}
+
+// getCatalog acquires the list of member zones of an RFC 9432 catalog zone.
+func (d *DNSDatasource) getCatalog(catalog string) ([]string, error) {
+	rrs, err := d.transferZone(context.Background(), catalog)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCatalog(dns.Fqdn(catalog), rrs)
+}
+
+// parseCatalog extracts member zones from the records of a catalog zone.
+// Member zones are the targets of PTR records named '<unique-id>.zones.<catalog>'.
+func parseCatalog(catalog string, rrs []dns.RR) ([]string, error) {
+	var version string
+	members := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, rr := range rrs {
+		name := strings.ToLower(rr.Header().Name)
+
+		switch rr := rr.(type) {
+		case *dns.TXT:
+			if name == "version."+strings.ToLower(catalog) {
+				version = strings.Join(rr.Txt, "")
+			}
+		case *dns.PTR:
+			id, ok := strings.CutSuffix(name, ".zones."+strings.ToLower(catalog))
+			if !ok || strings.Contains(id, ".") {
+				// Member properties and other records.
+				continue
+			}
+
+			if zone := strings.ToLower(rr.Ptr); !seen[zone] {
+				seen[zone] = true
+				members = append(members, zone)
+			}
+		}
+	}
+
+	if version != catalogZoneVersion {
+		return nil, errors.Errorf("unsupported catalog zone schema version: %q", version)
+	}
+
+	return members, nil
+}
+
+// zones returns the configured zones, member zones of the configured catalog zones and discovered zones.
+// Catalog zones are transferred and zones are discovered on first use, the list of zones is cached until the next GetAllRecords call.
+func (d *DNSDatasource) zones() []string {
+	d.zonesMu.Lock()
+	defer d.zonesMu.Unlock()
+
+	return d.loadZones(false)
+}
+
+// loadZones populates the zone list, or returns the cached one unless 'refresh' is set. The caller must hold zonesMu.
+func (d *DNSDatasource) loadZones(refresh bool) []string {
 	cfg := d.Config
+	log := d.Logger
+
+	if d.Zones != nil && !refresh {
+		return d.Zones
+	}
+
+	zones := make([]string, 0, len(cfg.DNS.Zones))
+	seen := make(map[string]bool)
+
+	add := func(zone string) {
+		if key := dns.Fqdn(strings.ToLower(zone)); !seen[key] {
+			seen[key] = true
+			zones = append(zones, zone)
+		}
+	}
+
+	for _, zone := range cfg.DNS.Zones {
+		add(zone)
+	}
+
+	for _, catalog := range cfg.DNS.Catalogs {
+		members, err := d.getCatalog(catalog)
+		if err != nil {
+			log.Warnf("[%s] skipping catalog zone: %v", catalog, err)
+			continue
+		}
+
+		log.Debugf("[%s] found %d member zones", catalog, len(members))
+
+		for _, zone := range members {
+			add(zone)
+		}
+	}
+
+	for _, zone := range d.discoverZones() {
+		add(zone)
+	}
+
+	d.Zones = zones
+
+	return zones
+}
+
+// newDNSQuery creates a DNS query message, advertising the configured EDNS0 UDP buffer size.
+func newDNSQuery(cfg *Config, name string, qtype uint16) *dns.Msg {
 	msg := new(dns.Msg)
-	msg.SetQuestion(host, dns.TypeTXT)
+	msg.SetQuestion(name, qtype)
+
+	if cfg.DNS.UDPSize > 0 {
+		msg.SetEdns0(cfg.DNS.UDPSize, false)
+	}
+
+	return msg
+}
+
+// getHost acquires all TXT records for a specific host.
+func (d *DNSDatasource) getHost(ctx context.Context, host string) ([]dns.RR, error) {
+	cfg := d.Config
+	log := d.Logger
+	msg := newDNSQuery(cfg, host, dns.TypeTXT)
 
-	rx, _, err := d.Client.Exchange(msg, cfg.DNS.Server)
+	rx, err := d.exchange(ctx, msg)
 	if err != nil {
 		return nil, errors.Wrap(err, "dns request failed")
 	}
 
+	// A truncated UDP response only carries a part of the records, so the query is repeated over TCP.
+	if rx.Truncated && d.Client.Net != "tcp" {
+		log.Debugf("[%s] truncated response, retrying over TCP", host)
+
+		client := newDNSClient(cfg)
+		client.Net = "tcp"
+
+		d.trace.dnsMessage("query (tcp)", cfg.DNS.Server, msg, nil)
+		rx, _, err = client.ExchangeContext(ctx, msg, cfg.DNS.Server)
+		d.trace.dnsMessage("response (tcp)", cfg.DNS.Server, rx, err)
+
+		if err != nil {
+			return nil, errors.Wrap(err, "dns request over TCP failed")
+		}
+	}
+
+	if rx.Truncated {
+		log.Warnf("[%s] response is still truncated, some host records may be missing", host)
+	}
+
 	return rx.Answer, nil
 }
 
-// GetAllRecords acquires all available host records.
+// readZone acquires TXT records and the serial of a specific zone, using zone transfers, the no-transfer mode or a zone file.
+func (d *DNSDatasource) readZone(ctx context.Context, zone string) ([]dns.RR, uint32, error) {
+	cfg := d.Config
+	log := d.Logger
+
+	if cfg.DNS.ZoneTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.DNS.ZoneTimeout)
+		defer cancel()
+	}
+
+	source := d.zonefileSource(zone)
+	if len(source) > 0 && !cfg.DNS.Zonefile.Fallback {
+		return d.readZonefile(ctx, zone, source)
+	}
+
+	rrs, serial, err := d.queryZone(ctx, zone)
+	if err != nil && len(source) > 0 {
+		log.Warnf("[%s] reading zone file %s: %v", zone, source, err)
+		return d.readZonefile(ctx, zone, source)
+	}
+
+	return rrs, serial, err
+}
+
+// queryZone acquires TXT records and the serial of a specific zone from the DNS server, using zone transfers, the no-transfer mode or the control channel.
+func (d *DNSDatasource) queryZone(ctx context.Context, zone string) ([]dns.RR, uint32, error) {
+	cfg := d.Config
+	log := d.Logger
+
+	if d.Control != nil {
+		rrs, err := d.Control.readZone(ctx, zone)
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "control channel failure")
+		}
+
+		records, serial := d.filterZone(zone, rrs)
+
+		return records, serial, nil
+	}
+
+	if !cfg.DNS.Notransfer.Enabled {
+		return d.getZone(ctx, d.makeFQDN("", zone))
+	}
+
+	rrs, err := d.getNotransferHosts(ctx, zone)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Zone transfers carry the SOA record, the no-transfer mode needs a separate request.
+	var serial uint32
+	if cfg.Metadata.Enabled {
+		if serial, err = d.getSerial(ctx, d.makeFQDN("", zone)); err != nil {
+			log.Debugf("[%s] failed to acquire zone serial: %v", zone, err)
+		}
+	}
+
+	return rrs, serial, nil
+}
+
+// GetAllRecords acquires all available host records, reading several zones concurrently.
 func (d *DNSDatasource) GetAllRecords() ([]*DatasourceRecord, error) {
 	cfg := d.Config
 	log := d.Logger
 	records := make([]*DatasourceRecord, 0)
+	serials := make(map[string]uint32)
+	failed := make([]string, 0)
+
+	// Catalog zones may have changed since the last call.
+	d.zonesMu.Lock()
+	zones := d.shardZones(d.loadZones(true))
+	d.zonesMu.Unlock()
+
+	// Zones that are not read before the deadline are skipped.
+	ctx := context.Background()
+	if cfg.DNS.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.DNS.Deadline)
+		defer cancel()
+	}
 
-	for _, zone := range cfg.DNS.Zones {
-		var rrs []dns.RR
-		var err error
+	type result struct {
+		rrs    []dns.RR
+		serial uint32
+		err    error
+	}
+	results := make([]result, len(zones))
+
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, max(cfg.DNS.Workers, 1))
+	for n, zone := range zones {
+		wg.Add(1)
+
+		go func(n int, zone string) {
+			defer wg.Done()
+
+			select {
+			case workers <- struct{}{}:
+				defer func() { <-workers }()
+			case <-ctx.Done():
+				results[n].err = errors.Wrap(ctx.Err(), "deadline exceeded before the zone could be read")
+				return
+			}
 
-		if cfg.DNS.Notransfer.Enabled {
-			rrs, err = d.getHost(d.makeFQDN(cfg.DNS.Notransfer.Host, zone))
-		} else {
-			rrs, err = d.getZone(d.makeFQDN("", zone))
-		}
-		if err != nil {
-			log.Warnf("[%s] skipping zone: %v", zone, err)
+			results[n].rrs, results[n].serial, results[n].err = d.readZone(ctx, zone)
+		}(n, zone)
+	}
+	wg.Wait()
+
+	// Process zones in the configured order to keep the output stable.
+	for n, zone := range zones {
+		if results[n].err != nil {
+			log.Warnf(warnSkippedZone, zone, results[n].err)
+			failed = append(failed, zone)
 			continue
 		}
 
-		records = append(records, d.processRecords(rrs)...)
+		if results[n].serial > 0 {
+			serials[zone] = results[n].serial
+		}
+
+		records = append(records, d.processRecords(results[n].rrs)...)
+	}
+
+	d.serialsMu.Lock()
+	d.Serials = serials
+	d.Failed = failed
+	d.serialsMu.Unlock()
+
+	if err := checkZones(failed, len(zones), cfg.Fallback.MaxFailedZones); err != nil {
+		return nil, err
 	}
 
 	return records, nil
@@ -169,6 +731,28 @@ func (d *DNSDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error)
 	cfg := d.Config
 	records := make([]*DatasourceRecord, 0)
 
+	// The control channel is the only source of host records if configured.
+	zone, zoneErr := d.findZone(host)
+	if zoneErr != nil && d.Control != nil {
+		return nil, errors.Wrapf(zoneErr, "%s: failed to find zone", host)
+	}
+
+	// Zones read from zone files only or over the control channel are read as a whole.
+	if zoneErr == nil && (d.Control != nil || (len(d.zonefileSource(zone)) > 0 && !cfg.DNS.Zonefile.Fallback)) {
+		rrs, _, err := d.readZone(context.Background(), zone)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range d.processRecords(rrs) {
+			if r.Hostname == host {
+				records = append(records, r)
+			}
+		}
+
+		return records, nil
+	}
+
 	if cfg.DNS.Notransfer.Enabled {
 		// No-transfer mode is enabled.
 		var rrs []dns.RR
@@ -178,8 +762,18 @@ func (d *DNSDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error)
 			return nil, errors.Wrapf(err, "%s: failed to find zone", host)
 		}
 
+		// Use the cached records of the zone if caching is enabled.
+		if cfg.DNS.Notransfer.Cache.Enabled {
+			cached, err := d.cachedNotransferRecords(context.Background(), zone)
+			if err != nil {
+				return nil, err
+			}
+
+			return append(records, cached[host]...), nil
+		}
+
 		// Get no-transfer host records.
-		rrs, err = d.getHost(d.makeFQDN(cfg.DNS.Notransfer.Host, zone))
+		rrs, err = d.getNotransferHosts(context.Background(), zone)
 		if err != nil {
 			return nil, err
 		}
@@ -193,7 +787,7 @@ func (d *DNSDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error)
 		}
 	} else {
 		// No-transfer mode is disabled, no special logic is needed.
-		rrs, err := d.getHost(d.makeFQDN(host, ""))
+		rrs, err := d.getHost(context.Background(), d.makeFQDN(host, ""))
 		if err != nil {
 			return nil, err
 		}
@@ -204,6 +798,72 @@ func (d *DNSDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error)
 	return records, nil
 }
 
+// GetZoneRecords acquires all available host records of a specific zone, which must be a configured, catalog member or discovered zone.
+// The serial of the zone is recorded for the datasource metadata.
+func (d *DNSDatasource) GetZoneRecords(zone string) ([]*DatasourceRecord, error) {
+	var name string
+	for _, z := range d.zones() {
+		if sameZone(z, zone) {
+			name = z
+			break
+		}
+	}
+
+	if len(name) == 0 {
+		return nil, &ZoneError{Zone: zone, Err: errors.New("zone is not configured")}
+	}
+
+	rrs, serial, err := d.readZone(context.Background(), name)
+	if err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+
+	if serial > 0 {
+		d.serialsMu.Lock()
+		if d.Serials == nil {
+			d.Serials = make(map[string]uint32)
+		}
+		d.Serials[name] = serial
+		d.serialsMu.Unlock()
+	}
+
+	return d.processRecords(rrs), nil
+}
+
+// HostZone returns the most specific zone the hostname belongs to. In the no-transfer mode, hosts are expected to be named after the zone their records are kept in.
+func (d *DNSDatasource) HostZone(host string) string {
+	var zone string
+
+	for _, z := range d.zones() {
+		if dns.IsSubDomain(dns.Fqdn(z), dns.Fqdn(host)) && len(strings.Trim(z, ".")) > len(strings.Trim(zone, ".")) {
+			zone = z
+		}
+	}
+
+	return zone
+}
+
+// Metadata returns the zone serials seen by the last GetAllRecords call.
+func (d *DNSDatasource) Metadata() map[string]interface{} {
+	d.serialsMu.Lock()
+	defer d.serialsMu.Unlock()
+
+	serials := make(map[string]uint32, len(d.Serials))
+	for zone, serial := range d.Serials {
+		serials[zone] = serial
+	}
+
+	return map[string]interface{}{"serials": serials}
+}
+
+// FailedZones returns the zones skipped by the last GetAllRecords call.
+func (d *DNSDatasource) FailedZones() []string {
+	d.serialsMu.Lock()
+	defer d.serialsMu.Unlock()
+
+	return append([]string(nil), d.Failed...)
+}
+
 // PublishRecords writes host records to the datasource.
 func (d *DNSDatasource) PublishRecords(records []*DatasourceRecord) error {
 	log := d.Logger
@@ -213,20 +873,76 @@ func (d *DNSDatasource) PublishRecords(records []*DatasourceRecord) error {
 }
 
 // Close shuts down the datasource and performs other housekeeping.
-func (d *DNSDatasource) Close() {}
+func (d *DNSDatasource) Close() {
+	d.trace.Close()
+
+	if d.gss != nil {
+		d.gss.close()
+	}
+
+	if c, ok := d.Control.(io.Closer); ok {
+		c.Close()
+	}
+
+	if d.Conns == nil {
+		return
+	}
+
+	for {
+		select {
+		case conn := <-d.Conns:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// newDNSClient creates a DNS client using the configured timeouts.
+// The client's overall timeout is not set, as it would override the per-stage timeouts.
+func newDNSClient(cfg *Config) *dns.Client {
+	return &dns.Client{
+		UDPSize:      cfg.DNS.UDPSize,
+		DialTimeout:  stageTimeout(cfg.DNS.Timeouts.Dial, cfg.DNS.Timeout),
+		ReadTimeout:  stageTimeout(cfg.DNS.Timeouts.Read, cfg.DNS.Timeout),
+		WriteTimeout: stageTimeout(cfg.DNS.Timeouts.Write, cfg.DNS.Timeout),
+	}
+}
 
 // NewDNSDatasource creates a DNS datasource.
 func NewDNSDatasource(cfg *Config, log Logger) (*DNSDatasource, error) {
-	return &DNSDatasource{
+	d := &DNSDatasource{
 		Config: cfg,
 		Logger: log,
-		Client: &dns.Client{
-			Timeout: cfg.DNS.Timeout,
-		},
+		Client: newDNSClient(cfg),
 		Transfer: &dns.Transfer{
-			DialTimeout:  cfg.DNS.Timeout,
-			ReadTimeout:  cfg.DNS.Timeout,
-			WriteTimeout: cfg.DNS.Timeout,
+			DialTimeout:  stageTimeout(cfg.DNS.Timeouts.Dial, cfg.DNS.Timeout),
+			ReadTimeout:  stageTimeout(cfg.DNS.Timeouts.Read, cfg.DNS.Timeout),
+			WriteTimeout: stageTimeout(cfg.DNS.Timeouts.Write, cfg.DNS.Timeout),
 		},
-	}, nil
+	}
+
+	if count := cfg.DNS.Shard.Count; count > 1 && (cfg.DNS.Shard.Index < 0 || cfg.DNS.Shard.Index >= count) {
+		return nil, errors.Errorf("dns datasource initialization failure: shard index %d is out of range for %d shards", cfg.DNS.Shard.Index, count)
+	}
+
+	trace, err := newTracer(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "dns datasource initialization failure")
+	}
+	d.trace = trace
+
+	if cfg.DNS.Tsig.Enabled && cfg.DNS.Tsig.Algo == gssAlgorithm {
+		if d.gss, err = newGSSTSIG(cfg); err != nil {
+			return nil, errors.Wrap(err, "dns datasource initialization failure")
+		}
+	}
+
+	// Persistent connections are only possible over TCP.
+	if cfg.DNS.Pool > 0 {
+		d.Client.Net = "tcp"
+		d.Conns = make(chan *dns.Conn, cfg.DNS.Pool)
+	}
+
+	return d, nil
 }
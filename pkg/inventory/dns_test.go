@@ -1,6 +1,21 @@
 package inventory
 
-import "testing"
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
 
 func TestDNSDatasource_makeFQDN(t *testing.T) {
 	type args struct {
@@ -126,3 +141,594 @@ func TestDNSDatasource_makeFQDN(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseCatalog(t *testing.T) {
+	rr := func(s string) dns.RR {
+		r, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return r
+	}
+
+	tests := []struct {
+		name    string
+		rrs     []dns.RR
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			rrs: []dns.RR{
+				rr(`catalog.infra.local. 0 IN SOA invalid. invalid. 1 3600 600 2147483646 0`),
+				rr(`catalog.infra.local. 0 IN NS invalid.`),
+				rr(`version.catalog.infra.local. 0 IN TXT "2"`),
+				rr(`a1b2.zones.catalog.infra.local. 0 IN PTR app.infra.local.`),
+				rr(`c3d4.zones.catalog.infra.local. 0 IN PTR DB.infra.local.`),
+				rr(`group.c3d4.zones.catalog.infra.local. 0 IN TXT "prod"`),
+				rr(`e5f6.zones.catalog.infra.local. 0 IN PTR app.infra.local.`),
+			},
+			want:    []string{"app.infra.local.", "db.infra.local."},
+			wantErr: false,
+		},
+		{
+			name: "invalid-version",
+			rrs: []dns.RR{
+				rr(`version.catalog.infra.local. 0 IN TXT "1"`),
+				rr(`a1b2.zones.catalog.infra.local. 0 IN PTR app.infra.local.`),
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCatalog("catalog.infra.local.", tt.rrs)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseCatalog() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCatalog() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSDatasource_notransferHosts(t *testing.T) {
+	cfg := &Config{}
+	cfg.DNS.Notransfer.Host = "ansible-dns-inventory"
+	cfg.DNS.Notransfer.Zones = []NotransferSpec{
+		{Zone: "infra.local.", Hosts: []string{"inventory-1", "inventory-2"}},
+		{Zone: "empty.local", Hosts: []string{}},
+	}
+
+	tests := []struct {
+		name string
+		zone string
+		want []string
+	}{
+		{
+			name: "valid-default",
+			zone: "server.local.",
+			want: []string{"ansible-dns-inventory.server.local."},
+		},
+		{
+			name: "valid-override",
+			zone: "infra.local.",
+			want: []string{"inventory-1.infra.local.", "inventory-2.infra.local."},
+		},
+		{
+			name: "valid-override-case",
+			zone: "INFRA.local",
+			want: []string{"inventory-1.INFRA.local.", "inventory-2.INFRA.local."},
+		},
+		{
+			name: "valid-empty-override",
+			zone: "empty.local.",
+			want: []string{"ansible-dns-inventory.empty.local."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &DNSDatasource{Config: cfg}
+
+			if got := d.notransferHosts(tt.zone); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DNSDatasource.notransferHosts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSDatasource_zones_concurrent(t *testing.T) {
+	zone := `$TTL 3600
+@ IN SOA ns1 hostmaster 2024010101 3600 600 86400 300
+app01 IN TXT "OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS="
+`
+
+	path := filepath.Join(t.TempDir(), "infra.local.zone")
+	if err := os.WriteFile(path, []byte(zone), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{}
+	cfg.DNS.Zones = []string{"infra.local."}
+	cfg.DNS.Zonefile.Zones = []ZonefileSpec{{Zone: "infra.local.", Source: path}}
+
+	d := &DNSDatasource{Config: cfg, Logger: &testLogger{}}
+
+	// Server mode refreshes the inventory while host lookups and metadata requests are served.
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := d.GetAllRecords(); err != nil {
+				t.Errorf("DNSDatasource.GetAllRecords() error = %v", err)
+			}
+			if _, err := d.GetZoneRecords("infra.local"); err != nil {
+				t.Errorf("DNSDatasource.GetZoneRecords() error = %v", err)
+			}
+			if got := d.HostZone("app01.infra.local"); got != "infra.local." {
+				t.Errorf("DNSDatasource.HostZone() = %v, want %v", got, "infra.local.")
+			}
+			d.Metadata()
+		}()
+	}
+	wg.Wait()
+
+	want := map[string]uint32{"infra.local.": 2024010101}
+	if got := d.Metadata()["serials"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("DNSDatasource.Metadata() serials = %v, want %v", got, want)
+	}
+}
+
+// startTestTransferServer starts a local DNS server answering zone transfers over TCP with a single host record per zone.
+// A zone is transferred after its delay, other zones are refused.
+func startTestTransferServer(tb testing.TB, zones map[string]time.Duration) string {
+	done := make(chan struct{})
+
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		zone := r.Question[0].Name
+
+		m := new(dns.Msg)
+		m.SetReply(r)
+
+		delay, ok := zones[zone]
+		if !ok || r.Question[0].Qtype != dns.TypeAXFR {
+			m.Rcode = dns.RcodeRefused
+			w.WriteMsg(m)
+			return
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-done:
+			return
+		}
+
+		soa := &dns.SOA{Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 3600}, Ns: "ns1." + zone, Mbox: "hostmaster." + zone, Serial: 1}
+		txt := &dns.TXT{Hdr: dns.RR_Header{Name: "app01." + zone, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 3600}, Txt: []string{"OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS="}}
+		m.Answer = []dns.RR{soa, txt, soa}
+
+		w.WriteMsg(m)
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	srv := &dns.Server{Listener: l, Handler: handler}
+	go srv.ActivateAndServe()
+
+	tb.Cleanup(func() {
+		close(done)
+		srv.Shutdown()
+	})
+
+	return l.Addr().String()
+}
+
+func TestDNSDatasource_GetAllRecords_timeouts(t *testing.T) {
+	// Transfers of hung zones never complete.
+	const hung = time.Hour
+
+	tests := []struct {
+		name        string
+		zones       []string
+		delays      map[string]time.Duration
+		workers     int
+		zoneTimeout time.Duration
+		deadline    time.Duration
+		maxFailed   int
+		// Upper bound of the run time.
+		within     time.Duration
+		wantHosts  []string
+		wantFailed []string
+		wantErr    bool
+	}{
+		{
+			// Read one after another, the zones would take 1.2s.
+			name:      "valid-concurrent",
+			zones:     []string{"a.local.", "b.local.", "c.local.", "d.local."},
+			delays:    map[string]time.Duration{"a.local.": 300 * time.Millisecond, "b.local.": 300 * time.Millisecond, "c.local.": 300 * time.Millisecond, "d.local.": 300 * time.Millisecond},
+			workers:   4,
+			within:    900 * time.Millisecond,
+			wantHosts: []string{"app01.a.local", "app01.b.local", "app01.c.local", "app01.d.local"},
+		},
+		{
+			name:        "valid-zone-timeout",
+			zones:       []string{"a.local.", "b.local.", "c.local."},
+			delays:      map[string]time.Duration{"a.local.": 0, "b.local.": hung, "c.local.": 0},
+			workers:     1,
+			zoneTimeout: 200 * time.Millisecond,
+			within:      2 * time.Second,
+			wantHosts:   []string{"app01.a.local", "app01.c.local"},
+			wantFailed:  []string{"b.local."},
+		},
+		{
+			// Refused zones are skipped as well.
+			name:       "valid-refused",
+			zones:      []string{"a.local.", "refused.local."},
+			delays:     map[string]time.Duration{"a.local.": 0},
+			workers:    2,
+			within:     2 * time.Second,
+			wantHosts:  []string{"app01.a.local"},
+			wantFailed: []string{"refused.local."},
+		},
+		{
+			name:       "valid-max-failed",
+			zones:      []string{"a.local.", "b.local.", "refused.local."},
+			delays:     map[string]time.Duration{"a.local.": 0, "b.local.": 0},
+			workers:    3,
+			maxFailed:  1,
+			within:     2 * time.Second,
+			wantHosts:  []string{"app01.a.local", "app01.b.local"},
+			wantFailed: []string{"refused.local."},
+		},
+		{
+			// Some zones could be read, but more of them have been skipped than allowed.
+			name:       "invalid-max-failed",
+			zones:      []string{"a.local.", "refused1.local.", "refused2.local."},
+			delays:     map[string]time.Duration{"a.local.": 0},
+			workers:    3,
+			maxFailed:  1,
+			within:     2 * time.Second,
+			wantFailed: []string{"refused1.local.", "refused2.local."},
+			wantErr:    true,
+		},
+		{
+			name:       "valid-deadline",
+			zones:      []string{"a.local.", "b.local.", "c.local."},
+			delays:     map[string]time.Duration{"a.local.": 0, "b.local.": hung, "c.local.": hung},
+			workers:    3,
+			deadline:   300 * time.Millisecond,
+			within:     2 * time.Second,
+			wantHosts:  []string{"app01.a.local"},
+			wantFailed: []string{"b.local.", "c.local."},
+		},
+		{
+			// The second zone waits for the first one and is skipped at the deadline without being read.
+			name:       "invalid-deadline",
+			zones:      []string{"a.local.", "b.local."},
+			delays:     map[string]time.Duration{"a.local.": hung, "b.local.": hung},
+			workers:    1,
+			deadline:   300 * time.Millisecond,
+			within:     2 * time.Second,
+			wantFailed: []string{"a.local.", "b.local."},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.DNS.Server = startTestTransferServer(t, tt.delays)
+			cfg.DNS.Timeout = 10 * time.Second
+			cfg.DNS.Zones = tt.zones
+			cfg.DNS.Workers = tt.workers
+			cfg.DNS.ZoneTimeout = tt.zoneTimeout
+			cfg.DNS.Deadline = tt.deadline
+			cfg.Fallback.MaxFailedZones = tt.maxFailed
+
+			d, err := NewDNSDatasource(cfg, &testLogger{})
+			if err != nil {
+				t.Fatalf("NewDNSDatasource() error = %v", err)
+			}
+			defer d.Close()
+
+			start := time.Now()
+			records, err := d.GetAllRecords()
+			if elapsed := time.Since(start); elapsed > tt.within {
+				t.Errorf("DNSDatasource.GetAllRecords() took %s, want at most %s", elapsed, tt.within)
+			}
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DNSDatasource.GetAllRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			got := make([]string, 0)
+			for _, r := range records {
+				got = append(got, r.Hostname)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.wantHosts) {
+				t.Errorf("DNSDatasource.GetAllRecords() = %v, want %v", got, tt.wantHosts)
+			}
+
+			if got := d.FailedZones(); !slices.Equal(got, tt.wantFailed) {
+				t.Errorf("DNSDatasource.FailedZones() = %v, want %v", got, tt.wantFailed)
+			}
+		})
+	}
+}
+
+// startTestDNSServer starts a local DNS server answering TXT queries over UDP and TCP with a number of records.
+// UDP responses are truncated to 512 bytes.
+func startTestDNSServer(tb testing.TB, records int) string {
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		for n := 0; n < records; n++ {
+			m.Answer = append(m.Answer, &dns.TXT{
+				Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 60},
+				Txt: []string{fmt.Sprintf("app%02d.infra.local:OS=linux;ENV=dev;ROLE=app;SRV=tomcat", n)},
+			})
+		}
+
+		if _, ok := w.RemoteAddr().(*net.UDPAddr); ok {
+			m.Truncate(dns.MinMsgSize)
+		}
+
+		w.WriteMsg(m)
+	})
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		tb.Fatal(err)
+	}
+
+	udp := &dns.Server{PacketConn: pc, Handler: handler}
+	tcp := &dns.Server{Listener: l, Handler: handler}
+	go udp.ActivateAndServe()
+	go tcp.ActivateAndServe()
+
+	tb.Cleanup(func() {
+		udp.Shutdown()
+		tcp.Shutdown()
+	})
+
+	return pc.LocalAddr().String()
+}
+
+func TestDNSDatasource_getHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		records int
+		net     string
+		want    int
+	}{
+		{
+			name:    "valid-udp",
+			records: 1,
+			net:     "",
+			want:    1,
+		},
+		{
+			name:    "valid-udp-truncated",
+			records: 50,
+			net:     "",
+			want:    50,
+		},
+		{
+			name:    "valid-tcp",
+			records: 50,
+			net:     "tcp",
+			want:    50,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.DNS.Server = startTestDNSServer(t, tt.records)
+			cfg.DNS.Timeout = 5 * time.Second
+
+			d, err := NewDNSDatasource(cfg, &testLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+
+			d.Client.Net = tt.net
+
+			rrs, err := d.getHost(context.Background(), "ansible-dns-inventory.infra.local.")
+			if err != nil {
+				t.Fatalf("DNSDatasource.getHost() error = %v", err)
+			}
+			if len(rrs) != tt.want {
+				t.Errorf("DNSDatasource.getHost() returned %d records, want %d", len(rrs), tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSDatasource_exchange(t *testing.T) {
+	server := startTestDNSServer(t, 1)
+
+	tests := []struct {
+		name string
+		pool int
+	}{
+		{
+			// Every query is made over a new connection.
+			name: "valid-no-pool",
+			pool: 0,
+		},
+		{
+			// Queries reuse persistent TCP connections.
+			name: "valid-pool",
+			pool: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.DNS.Server = server
+			cfg.DNS.Timeout = 5 * time.Second
+			cfg.DNS.Pool = tt.pool
+
+			d, err := NewDNSDatasource(cfg, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+
+			for n := 0; n < 3; n++ {
+				rx, err := d.exchange(context.Background(), newDNSQuery(cfg, "ansible-dns-inventory.infra.local.", dns.TypeTXT))
+				if err != nil {
+					t.Fatalf("DNSDatasource.exchange() error = %v", err)
+				}
+				if len(rx.Answer) != 1 {
+					t.Errorf("DNSDatasource.exchange() returned %d records, want 1", len(rx.Answer))
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDNSDatasource_getHost(b *testing.B) {
+	server := startTestDNSServer(b, 1)
+
+	for _, bb := range []struct {
+		name string
+		net  string
+		pool int
+	}{
+		{name: "udp", net: "", pool: 0},
+		{name: "tcp", net: "tcp", pool: 0},
+		{name: "tcp-pool", net: "tcp", pool: 4},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			cfg := &Config{}
+			cfg.DNS.Server = server
+			cfg.DNS.Timeout = 5 * time.Second
+			cfg.DNS.Pool = bb.pool
+
+			d, err := NewDNSDatasource(cfg, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer d.Close()
+
+			d.Client.Net = bb.net
+
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				if _, err := d.getHost(context.Background(), "ansible-dns-inventory.infra.local."); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDNSDatasource_readZonefile(t *testing.T) {
+	zone := `$TTL 3600
+@ IN SOA ns1 hostmaster 2024010101 3600 600 86400 300
+@ IN NS ns1
+ns1 IN A 192.0.2.1
+app01 IN TXT "OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS="
+app01 IN A 192.0.2.10
+db01.infra.local. IN TXT "OS=linux;ENV=dev;ROLE=db;SRV=postgres;VARS="
+ansible-dns-inventory IN TXT "web01.infra.local:OS=linux;ENV=dev;ROLE=web;SRV=nginx;VARS="
+`
+
+	path := filepath.Join(t.TempDir(), "infra.local.zone")
+	if err := os.WriteFile(path, []byte(zone), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/infra.local.zone" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Write([]byte(zone))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		source     string
+		notransfer bool
+		want       []string
+		wantErr    bool
+	}{
+		{
+			name:   "valid-file",
+			source: path,
+			want:   []string{"app01.infra.local", "db01.infra.local"},
+		},
+		{
+			name:   "valid-url",
+			source: server.URL + "/infra.local.zone",
+			want:   []string{"app01.infra.local", "db01.infra.local"},
+		},
+		{
+			name:       "valid-notransfer",
+			source:     path,
+			notransfer: true,
+			want:       []string{"web01.infra.local"},
+		},
+		{
+			name:    "invalid-file",
+			source:  path + ".missing",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-url",
+			source:  server.URL + "/missing.zone",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.DNS.Notransfer.Enabled = tt.notransfer
+			cfg.DNS.Notransfer.Host = "ansible-dns-inventory"
+			cfg.DNS.Notransfer.Separator = ":"
+
+			d := &DNSDatasource{Config: cfg}
+
+			rrs, serial, err := d.readZonefile(context.Background(), "infra.local.", tt.source)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DNSDatasource.readZonefile() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if serial != 2024010101 {
+				t.Errorf("DNSDatasource.readZonefile() serial = %d, want %d", serial, 2024010101)
+			}
+
+			got := make([]string, 0, len(rrs))
+			for _, r := range d.processRecords(rrs) {
+				got = append(got, r.Hostname)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DNSDatasource.readZonefile() hosts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
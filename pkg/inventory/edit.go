@@ -0,0 +1,113 @@
+package inventory
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Error describes the invalid hostname or host attributes.
+func (e *HostEditError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Host, e.Err)
+}
+
+// event creates a publishing event for an operation performed by the actor.
+func (a *Actor) event(operation string) *HookEvent {
+	event := &HookEvent{Operation: operation}
+	if a != nil {
+		event.Actor = a.Name
+		event.Address = a.Address
+	}
+
+	return event
+}
+
+// editingDatasource returns the datasource if it can replace the records of a single host.
+func (i *Inventory) editingDatasource() (EditingDatasource, error) {
+	ds, ok := i.Datasource.(EditingDatasource)
+	if !ok {
+		return nil, errors.Errorf("datasource does not support editing hosts: %s", i.Config.Datasource)
+	}
+
+	return ds, nil
+}
+
+// EditHost validates host attributes and replaces all records of a host with them, one record per attribute set.
+// Records of other hosts are not touched. Event hooks receive the new records with the 'edit' operation.
+func (i *Inventory) EditHost(host string, attrs []*HostAttributes) ([]*DatasourceRecord, error) {
+	return i.EditHostAs(nil, host, attrs)
+}
+
+// EditHostAs works like EditHost, attributing the edit to an actor in event hooks and the audit log. The current system user is used if actor is nil.
+func (i *Inventory) EditHostAs(actor *Actor, host string, attrs []*HostAttributes) ([]*DatasourceRecord, error) {
+	ds, err := i.editingDatasource()
+	if err != nil {
+		return nil, err
+	}
+
+	if msg := lintHostname(host); len(msg) > 0 {
+		return nil, &HostEditError{Host: host, Err: errors.New(msg)}
+	}
+
+	if len(attrs) == 0 {
+		return nil, &HostEditError{Host: host, Err: errors.New("no host attributes given")}
+	}
+
+	records := make([]*DatasourceRecord, 0, len(attrs))
+	for n, a := range attrs {
+		if a == nil {
+			return nil, &HostEditError{Host: host, Err: errors.Errorf("attribute set %d is empty", n)}
+		}
+
+		attrString, err := i.RenderAttributes(a)
+		if err != nil {
+			return nil, &HostEditError{Host: host, Err: errors.Wrapf(err, "attribute set %d", n)}
+		}
+
+		records = append(records, &DatasourceRecord{Hostname: host, Attributes: attrString})
+	}
+
+	event := actor.event("edit")
+	event.Records = records
+	if err := i.publishEvent(event, func() error {
+		return ds.ReplaceHost(host, records)
+	}); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// DeleteHost removes all records of a host and returns them. Nothing is removed and no records are returned if the host has no records.
+// Event hooks receive the host name with the 'delete' operation.
+func (i *Inventory) DeleteHost(host string) ([]*DatasourceRecord, error) {
+	return i.DeleteHostAs(nil, host)
+}
+
+// DeleteHostAs works like DeleteHost, attributing the removal to an actor in event hooks and the audit log. The current system user is used if actor is nil.
+func (i *Inventory) DeleteHostAs(actor *Actor, host string) ([]*DatasourceRecord, error) {
+	ds, err := i.editingDatasource()
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := i.hostRecords(host)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: record loading failure", host)
+	}
+
+	if len(records) == 0 {
+		return records, nil
+	}
+
+	event := actor.event("delete")
+	event.Records = []*DatasourceRecord{}
+	event.Hosts = []string{host}
+	if err := i.publishEvent(event, func() error {
+		return ds.ReplaceHost(host, nil)
+	}); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
@@ -0,0 +1,159 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testEditingDatasource is a Datasource serving a fixed set of host records that records host replacements.
+type testEditingDatasource struct {
+	*testDatasource
+
+	// Replaced hosts and their new records.
+	replaced map[string][]*DatasourceRecord
+}
+
+func (d *testEditingDatasource) ReplaceHost(host string, records []*DatasourceRecord) error {
+	d.replaced[host] = records
+
+	return nil
+}
+
+func TestInventory_EditHost(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     string
+		attrs    []*HostAttributes
+		roles    []string
+		readOnly bool
+		want     []*DatasourceRecord
+		wantErr  bool
+	}{
+		{
+			name: "valid",
+			host: "app01.infra.local",
+			attrs: []*HostAttributes{
+				{OS: "linux", Env: "dev", Role: "app", Srv: "tomcat"},
+				{OS: "linux", Env: "dev", Role: "web", Srv: "nginx", Vars: "port=443"},
+			},
+			want: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS="},
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=web;SRV=nginx;VARS=port=443"},
+			},
+		},
+		{
+			name:    "invalid-hostname",
+			host:    "app_01.infra.local",
+			attrs:   []*HostAttributes{{OS: "linux", Env: "dev", Role: "app"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid-attributes",
+			host:    "app01.infra.local",
+			attrs:   []*HostAttributes{{OS: "linux", Env: "dev"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid-empty",
+			host:    "app01.infra.local",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-policy",
+			host:    "app01.infra.local",
+			attrs:   []*HostAttributes{{OS: "linux", Env: "dev", Role: "app"}},
+			roles:   []string{"db"},
+			wantErr: true,
+		},
+		{
+			name:     "invalid-datasource",
+			host:     "app01.infra.local",
+			attrs:    []*HostAttributes{{OS: "linux", Env: "dev", Role: "app"}},
+			readOnly: true,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newTestInventory(t, false, nil)
+			i.Config.Policy.Enabled = len(tt.roles) > 0
+			i.Config.Policy.Roles = tt.roles
+
+			ds := &testEditingDatasource{testDatasource: &testDatasource{}, replaced: make(map[string][]*DatasourceRecord)}
+			if tt.readOnly {
+				i.Datasource = ds.testDatasource
+			} else {
+				i.Datasource = ds
+			}
+
+			got, err := i.EditHost(tt.host, tt.attrs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Inventory.EditHost() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			replaced, ok := ds.replaced[tt.host]
+			if tt.wantErr {
+				if ok {
+					t.Errorf("Inventory.EditHost() replaced %v despite an error", replaced)
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inventory.EditHost() = %v, want %v", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(replaced, tt.want) {
+				t.Errorf("Inventory.EditHost() replaced %v, want %v", replaced, tt.want)
+			}
+		})
+	}
+}
+
+func TestInventory_DeleteHost(t *testing.T) {
+	records := []*DatasourceRecord{
+		{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat"},
+		{Hostname: "app01.infra.local.dc1", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat"},
+	}
+
+	tests := []struct {
+		name     string
+		host     string
+		want     []*DatasourceRecord
+		replaced bool
+	}{
+		{
+			// Records of hosts sharing the name as a prefix are not removed.
+			name:     "valid",
+			host:     "app01.infra.local",
+			want:     records[:1],
+			replaced: true,
+		},
+		{
+			name: "valid-not-found",
+			host: "app02.infra.local",
+			want: []*DatasourceRecord{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newTestInventory(t, false, nil)
+
+			ds := &testEditingDatasource{testDatasource: &testDatasource{records: records}, replaced: make(map[string][]*DatasourceRecord)}
+			i.Datasource = ds
+
+			got, err := i.DeleteHost(tt.host)
+			if err != nil {
+				t.Fatalf("Inventory.DeleteHost() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inventory.DeleteHost() = %v, want %v", got, tt.want)
+			}
+
+			if replaced, ok := ds.replaced[tt.host]; ok != tt.replaced || len(replaced) > 0 {
+				t.Errorf("Inventory.DeleteHost() replaced %v, %v, want %v", replaced, ok, tt.replaced)
+			}
+		})
+	}
+}
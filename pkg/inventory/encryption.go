@@ -0,0 +1,430 @@
+package inventory
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Prefix of encrypted host record values written before key IDs were introduced: 'enc:v1:<base64>'.
+	encryptedValuePrefixV1 string = "enc:v1:"
+	// Prefix of encrypted host record values: 'enc:v2:<key ID>:<base64>'.
+	encryptedValuePrefix string = "enc:v2:"
+
+	// Encryption key providers.
+	localKeyProvider string = "local"
+	vaultKeyProvider string = "vault"
+)
+
+type (
+	// ValueCipher encrypts and decrypts host record values with AES-GCM.
+	// Values are encrypted with the primary key and decrypted with the key their key ID refers to, so that previous keys can be kept for decryption after a key rotation.
+	ValueCipher struct {
+		// Vault transit data key provider, nil if keys are configured locally.
+		transit *vaultTransit
+
+		// Guards the keys.
+		mu sync.Mutex
+		// Encryption key, nil until the first encryption with the Vault provider.
+		primary *valueKey
+		// Decryption keys, keyed by key ID.
+		keys map[string]*valueKey
+		// Local keys in the order they are configured, tried when decrypting values without a key ID.
+		local []*valueKey
+	}
+
+	// valueKey is an AES-GCM key identified by a key ID.
+	valueKey struct {
+		id   string
+		aead cipher.AEAD
+	}
+
+	// vaultTransit generates and unwraps data keys using a Vault transit secrets engine key.
+	vaultTransit struct {
+		vault *VaultDatasource
+		mount string
+		key   string
+	}
+
+	// vaultTransitData represents the data of a Vault transit data key or decryption response.
+	vaultTransitData struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	}
+)
+
+// readEncryptionKey reads a base64-encoded AES key from the first configured key source. It returns an empty string if no source is configured.
+func readEncryptionKey(cfg *Config) (string, error) {
+	enc := cfg.Etcd.Encryption
+
+	switch {
+	case len(enc.Key) > 0:
+		return enc.Key, nil
+	case len(enc.Path) > 0:
+		data, err := os.ReadFile(enc.Path)
+		if err != nil {
+			return "", err
+		}
+
+		return string(data), nil
+	case len(enc.Command) > 0:
+		var stderr bytes.Buffer
+
+		cmd := exec.Command(enc.Command[0], enc.Command[1:]...)
+		cmd.Stderr = &stderr
+
+		data, err := cmd.Output()
+		if err != nil {
+			return "", errors.Wrapf(err, "key command failed: %s", strings.TrimSpace(stderr.String()))
+		}
+
+		return string(data), nil
+	default:
+		return "", nil
+	}
+}
+
+// newValueKey creates an AES-GCM key. Local keys are identified by a truncated SHA-256 hash of the key.
+func newValueKey(id string, key []byte) (*valueKey, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid encryption key")
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(id) == 0 {
+		sum := sha256.Sum256(key)
+		id = hex.EncodeToString(sum[:8])
+	}
+
+	return &valueKey{id: id, aead: aead}, nil
+}
+
+// newLocalKey decodes a base64-encoded AES key.
+func newLocalKey(encoded string) (*valueKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid encryption key")
+	}
+
+	return newValueKey("", key)
+}
+
+// newValueCipher creates a host record cipher using the configured keys. It returns nil if no keys are configured and encryption is disabled.
+// With the Vault provider, locally configured keys are only used to decrypt values encrypted before switching to Vault.
+func newValueCipher(cfg *Config, log Logger) (*ValueCipher, error) {
+	enc := cfg.Etcd.Encryption
+
+	c := &ValueCipher{keys: make(map[string]*valueKey)}
+
+	primary, err := readEncryptionKey(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "encryption key loading failure")
+	}
+
+	encoded := enc.Keys
+	if len(primary) > 0 {
+		encoded = append([]string{primary}, encoded...)
+	}
+
+	for _, k := range encoded {
+		key, err := newLocalKey(k)
+		if err != nil {
+			return nil, err
+		}
+
+		c.keys[key.id] = key
+		c.local = append(c.local, key)
+	}
+
+	switch enc.Provider {
+	case localKeyProvider:
+		if len(primary) == 0 {
+			if enc.Enabled {
+				return nil, errors.New("encryption is enabled but no key is configured")
+			}
+
+			// Previous keys alone can still decrypt existing values.
+			if len(c.local) == 0 {
+				return nil, nil
+			}
+
+			return c, nil
+		}
+
+		c.primary = c.local[0]
+	case vaultKeyProvider:
+		if len(enc.Transit.Key) == 0 {
+			return nil, errors.New("vault transit key name is not set")
+		}
+
+		vault, err := NewVaultDatasource(cfg, log)
+		if err != nil {
+			return nil, errors.Wrap(err, "vault key provider initialization failure")
+		}
+
+		c.transit = &vaultTransit{vault: vault, mount: strings.Trim(enc.Transit.Mount, "/"), key: enc.Transit.Key}
+	default:
+		return nil, errors.Errorf("unknown encryption key provider: %s", enc.Provider)
+	}
+
+	return c, nil
+}
+
+// request performs a Vault transit API request and returns the response data.
+func (t *vaultTransit) request(op string, body map[string]string) (*vaultTransitData, error) {
+	resp, status, err := t.vault.request(http.MethodPost, t.mount+"/"+op+"/"+t.key, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == http.StatusNotFound {
+		return nil, errors.Errorf("vault transit key not found: %s/%s", t.mount, t.key)
+	}
+
+	data := &vaultTransitData{}
+	if err := json.Unmarshal(resp.Data, data); err != nil {
+		return nil, errors.Wrap(err, "vault response parsing failure")
+	}
+
+	return data, nil
+}
+
+// dataKey generates a data key wrapped with the latest version of the transit key. The wrapped key is used as the key ID.
+func (t *vaultTransit) dataKey() (*valueKey, error) {
+	data, err := t.request("datakey/plaintext", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "data key generation failure")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(data.Plaintext)
+	if err != nil || len(data.Ciphertext) == 0 {
+		return nil, errors.New("data key generation failure: invalid vault response")
+	}
+
+	return newValueKey(data.Ciphertext, key)
+}
+
+// unwrap decrypts a wrapped data key.
+func (t *vaultTransit) unwrap(wrapped string) (*valueKey, error) {
+	data, err := t.request("decrypt", map[string]string{"ciphertext": wrapped})
+	if err != nil {
+		return nil, errors.Wrap(err, "data key decryption failure")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(data.Plaintext)
+	if err != nil {
+		return nil, errors.New("data key decryption failure: invalid vault response")
+	}
+
+	return newValueKey(wrapped, key)
+}
+
+// primaryKey returns the encryption key, generating a data key on first use with the Vault provider.
+func (c *ValueCipher) primaryKey() (*valueKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.primary != nil {
+		return c.primary, nil
+	}
+
+	if c.transit == nil {
+		return nil, errors.New("no encryption key is configured")
+	}
+
+	key, err := c.transit.dataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	c.primary = key
+	c.keys[key.id] = key
+
+	return key, nil
+}
+
+// decryptionKey returns the key with the given key ID, unwrapping Vault data keys on first use.
+func (c *ValueCipher) decryptionKey(id string) (*valueKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if key, ok := c.keys[id]; ok {
+		return key, nil
+	}
+
+	if c.transit == nil || !strings.HasPrefix(id, "vault:") {
+		return nil, errors.Errorf("unknown encryption key: %s", id)
+	}
+
+	key, err := c.transit.unwrap(id)
+	if err != nil {
+		return nil, err
+	}
+
+	c.keys[id] = key
+
+	return key, nil
+}
+
+// KeyID returns the ID of the key a value has been encrypted with: an empty string for plaintext values and 'v1' for values written without a key ID.
+func (c *ValueCipher) KeyID(value string) string {
+	if strings.HasPrefix(value, encryptedValuePrefixV1) {
+		return "v1"
+	}
+
+	data, ok := strings.CutPrefix(value, encryptedValuePrefix)
+	if !ok {
+		return ""
+	}
+
+	// Key IDs of Vault data keys contain colons, base64 data does not.
+	if n := strings.LastIndex(data, ":"); n >= 0 {
+		return data[:n]
+	}
+
+	return ""
+}
+
+// Encrypt encrypts a value with the primary key, binding it to its etcd key so that it cannot be moved to another key unnoticed.
+func (c *ValueCipher) Encrypt(key string, value string) (string, error) {
+	if c == nil {
+		return "", errors.New("no encryption key is configured")
+	}
+
+	primary, err := c.primaryKey()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, primary.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := primary.aead.Seal(nonce, nonce, []byte(value), []byte(key))
+
+	return encryptedValuePrefix + primary.id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value produced by Encrypt. Values without the encryption prefix are returned as is.
+// Values written without a key ID are decrypted with every locally configured key in turn.
+func (c *ValueCipher) Decrypt(key string, value string) (string, error) {
+	var keys []*valueKey
+	var data string
+
+	switch {
+	case strings.HasPrefix(value, encryptedValuePrefixV1):
+		if c == nil {
+			return "", errors.New("encrypted value found but no encryption key is configured")
+		}
+
+		data = strings.TrimPrefix(value, encryptedValuePrefixV1)
+		keys = c.local
+	case strings.HasPrefix(value, encryptedValuePrefix):
+		if c == nil {
+			return "", errors.New("encrypted value found but no encryption key is configured")
+		}
+
+		id := c.KeyID(value)
+		if len(id) == 0 {
+			return "", errors.New("invalid encrypted value: no key ID")
+		}
+
+		k, err := c.decryptionKey(id)
+		if err != nil {
+			return "", err
+		}
+
+		data = strings.TrimPrefix(value, encryptedValuePrefix+id+":")
+		keys = []*valueKey{k}
+	default:
+		return value, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid encrypted value")
+	}
+
+	err = errors.New("no local encryption key is configured")
+	for _, k := range keys {
+		if len(sealed) < k.aead.NonceSize() {
+			return "", errors.New("invalid encrypted value: too short")
+		}
+
+		plain, openErr := k.aead.Open(nil, sealed[:k.aead.NonceSize()], sealed[k.aead.NonceSize():], []byte(key))
+		if openErr == nil {
+			return string(plain), nil
+		}
+
+		err = openErr
+	}
+
+	return "", errors.Wrap(err, "decryption failure")
+}
+
+// Reencrypt decrypts a value and encrypts it again with the primary key. Values already encrypted with the primary key are returned as is.
+// It reports whether the value has changed.
+func (c *ValueCipher) Reencrypt(key string, value string) (string, bool, error) {
+	primary, err := c.primaryKey()
+	if err != nil {
+		return "", false, err
+	}
+
+	if c.KeyID(value) == primary.id {
+		return value, false, nil
+	}
+
+	plain, err := c.Decrypt(key, value)
+	if err != nil {
+		return "", false, err
+	}
+
+	encrypted, err := c.Encrypt(key, plain)
+	if err != nil {
+		return "", false, err
+	}
+
+	return encrypted, true, nil
+}
+
+// isEncryptedValue reports whether a value is encrypted.
+func isEncryptedValue(value string) bool {
+	return strings.HasPrefix(value, encryptedValuePrefix) || strings.HasPrefix(value, encryptedValuePrefixV1)
+}
+
+// Reencrypt encrypts all host records and group variables in the datasource with the current encryption key,
+// so that previous keys can be retired after a key rotation. Plaintext values are encrypted as well. If dryRun is true, nothing is written to the datasource.
+func (i *Inventory) Reencrypt(dryRun bool) (*Reencryption, error) {
+	cfg := i.Config
+
+	ds, ok := i.Datasource.(ReencryptingDatasource)
+	if !ok {
+		return nil, errors.Errorf("datasource does not support re-encrypting host records: %s", cfg.Datasource)
+	}
+
+	report, err := ds.ReencryptRecords(dryRun)
+	if err != nil {
+		return nil, errors.Wrap(err, "re-encryption failure")
+	}
+
+	return report, nil
+}
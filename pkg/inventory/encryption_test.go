@@ -0,0 +1,363 @@
+package inventory
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testEncryptionKey    = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+	testEncryptionKeyOld = "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="
+)
+
+// testTransit is a minimal Vault server with a transit secrets engine mounted at 'transit' and a single 'inventory' key.
+type testTransit struct {
+	mu      sync.Mutex
+	version int
+	keys    map[string][]byte
+	unwraps int
+}
+
+func (s *testTransit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("X-Vault-Token") != "root" {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string][]string{"errors": {"permission denied"}})
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v1/transit/datakey/plaintext/inventory":
+		key := make([]byte, 32)
+		rand.Read(key)
+		wrapped := fmt.Sprintf("vault:v%d:%s", s.version, base64.StdEncoding.EncodeToString(key[:12]))
+		s.keys[wrapped] = key
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"plaintext": base64.StdEncoding.EncodeToString(key), "ciphertext": wrapped}})
+	case "/v1/transit/decrypt/inventory":
+		var body struct {
+			Ciphertext string `json:"ciphertext"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		s.unwraps++
+		key, ok := s.keys[body.Ciphertext]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string][]string{"errors": {"invalid ciphertext"}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"plaintext": base64.StdEncoding.EncodeToString(key)}})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// testValueCipher creates a cipher with a primary key and previous keys.
+func testValueCipher(t *testing.T, key string, keys ...string) *ValueCipher {
+	cfg := &Config{}
+	cfg.Etcd.Encryption.Provider = "local"
+	cfg.Etcd.Encryption.Key = key
+	cfg.Etcd.Encryption.Keys = keys
+
+	c, err := newValueCipher(cfg, &testLogger{})
+	if err != nil {
+		t.Fatalf("newValueCipher() error = %v", err)
+	}
+
+	return c
+}
+
+func TestValueCipher_Decrypt(t *testing.T) {
+	value := "OS=linux;ENV=dev;ROLE=app;SRV=tomcat_backend"
+	key := "server.local./app01.server.local/0"
+
+	old := testValueCipher(t, testEncryptionKeyOld)
+	c := testValueCipher(t, testEncryptionKey, testEncryptionKeyOld)
+
+	encrypted, err := c.Encrypt(key, value)
+	if err != nil {
+		t.Fatalf("ValueCipher.Encrypt() error = %v", err)
+	}
+
+	previous, err := old.Encrypt(key, value)
+	if err != nil {
+		t.Fatalf("ValueCipher.Encrypt() error = %v", err)
+	}
+
+	// Values written before key IDs were introduced.
+	v1 := encryptedValuePrefixV1 + strings.TrimPrefix(previous, encryptedValuePrefix+old.KeyID(previous)+":")
+
+	tests := []struct {
+		name    string
+		cipher  *ValueCipher
+		key     string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			cipher:  c,
+			key:     key,
+			value:   encrypted,
+			want:    value,
+			wantErr: false,
+		},
+		{
+			name:    "valid-plaintext",
+			cipher:  c,
+			key:     key,
+			value:   value,
+			want:    value,
+			wantErr: false,
+		},
+		{
+			name:    "valid-plaintext-no-key",
+			cipher:  nil,
+			key:     key,
+			value:   value,
+			want:    value,
+			wantErr: false,
+		},
+		{
+			name:    "valid-previous-key",
+			cipher:  c,
+			key:     key,
+			value:   previous,
+			want:    value,
+			wantErr: false,
+		},
+		{
+			name:    "valid-v1",
+			cipher:  c,
+			key:     key,
+			value:   v1,
+			want:    value,
+			wantErr: false,
+		},
+		{
+			name:    "invalid-key",
+			cipher:  c,
+			key:     "server.local./app02.server.local/0",
+			value:   encrypted,
+			want:    "",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-unknown-key-id",
+			cipher:  old,
+			key:     key,
+			value:   encrypted,
+			want:    "",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-no-key",
+			cipher:  nil,
+			key:     key,
+			value:   encrypted,
+			want:    "",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-value",
+			cipher:  c,
+			key:     key,
+			value:   encryptedValuePrefix + c.KeyID(encrypted) + ":AAAA",
+			want:    "",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-no-key-id",
+			cipher:  c,
+			key:     key,
+			value:   encryptedValuePrefix + "AAAA",
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cipher.Decrypt(tt.key, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValueCipher.Decrypt() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ValueCipher.Decrypt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValueCipher_Reencrypt(t *testing.T) {
+	value := "OS=linux;ENV=dev;ROLE=app;SRV=tomcat_backend"
+	key := "server.local./app01.server.local/0"
+
+	old := testValueCipher(t, testEncryptionKeyOld)
+	c := testValueCipher(t, testEncryptionKey, testEncryptionKeyOld)
+
+	previous, err := old.Encrypt(key, value)
+	if err != nil {
+		t.Fatalf("ValueCipher.Encrypt() error = %v", err)
+	}
+
+	current, err := c.Encrypt(key, value)
+	if err != nil {
+		t.Fatalf("ValueCipher.Encrypt() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		value       string
+		wantChanged bool
+		wantErr     bool
+	}{
+		{name: "valid-previous-key", value: previous, wantChanged: true},
+		{name: "valid-plaintext", value: value, wantChanged: true},
+		{name: "valid-current-key", value: current, wantChanged: false},
+		{name: "invalid-value", value: encryptedValuePrefix + old.KeyID(previous) + ":AAAA", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed, err := c.Reencrypt(key, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValueCipher.Reencrypt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if changed != tt.wantChanged {
+				t.Errorf("ValueCipher.Reencrypt() changed = %v, want %v", changed, tt.wantChanged)
+			}
+
+			if id := c.KeyID(got); id != c.KeyID(current) {
+				t.Errorf("ValueCipher.Reencrypt() key ID = %s, want %s", id, c.KeyID(current))
+			}
+
+			// The previous key is no longer needed.
+			if plain, err := testValueCipher(t, testEncryptionKey).Decrypt(key, got); err != nil || plain != value {
+				t.Errorf("ValueCipher.Decrypt() = %v, %v, want %v", plain, err, value)
+			}
+		})
+	}
+}
+
+func TestValueCipher_vault(t *testing.T) {
+	value := "OS=linux;ENV=dev;ROLE=app;SRV=tomcat_backend"
+	key := "server.local./app01.server.local/0"
+
+	transit := &testTransit{version: 1, keys: make(map[string][]byte)}
+	server := httptest.NewServer(transit)
+	defer server.Close()
+
+	cfg := &Config{}
+	cfg.Vault.Address = server.URL
+	cfg.Vault.Auth.Method = "token"
+	cfg.Vault.Auth.Token = "root"
+	cfg.Etcd.Encryption.Enabled = true
+	cfg.Etcd.Encryption.Provider = "vault"
+	cfg.Etcd.Encryption.Transit.Mount = "transit"
+	cfg.Etcd.Encryption.Transit.Key = "inventory"
+	// Records encrypted before switching to Vault.
+	cfg.Etcd.Encryption.Keys = []string{testEncryptionKey}
+
+	local, err := testValueCipher(t, testEncryptionKey).Encrypt(key, value)
+	if err != nil {
+		t.Fatalf("ValueCipher.Encrypt() error = %v", err)
+	}
+
+	writer, err := newValueCipher(cfg, &testLogger{})
+	if err != nil {
+		t.Fatalf("newValueCipher() error = %v", err)
+	}
+
+	encrypted, err := writer.Encrypt(key, value)
+	if err != nil {
+		t.Fatalf("ValueCipher.Encrypt() error = %v", err)
+	}
+
+	if id := writer.KeyID(encrypted); !strings.HasPrefix(id, "vault:v1:") {
+		t.Errorf("ValueCipher.Encrypt() key ID = %s, want a wrapped data key", id)
+	}
+
+	// Rotating the transit key does not affect existing data keys.
+	transit.version++
+
+	reader, err := newValueCipher(cfg, &testLogger{})
+	if err != nil {
+		t.Fatalf("newValueCipher() error = %v", err)
+	}
+
+	for _, v := range []string{encrypted, encrypted, local} {
+		if got, err := reader.Decrypt(key, v); err != nil || got != value {
+			t.Errorf("ValueCipher.Decrypt() = %v, %v, want %v", got, err, value)
+		}
+	}
+
+	// Data keys are unwrapped once.
+	if transit.unwraps != 1 {
+		t.Errorf("data key unwrapped %d times, want 1", transit.unwraps)
+	}
+
+	for _, v := range []string{encrypted, local} {
+		rotated, changed, err := reader.Reencrypt(key, v)
+		if err != nil || !changed {
+			t.Fatalf("ValueCipher.Reencrypt() = %v, %v, want a changed value", changed, err)
+		}
+
+		if id := reader.KeyID(rotated); !strings.HasPrefix(id, "vault:v2:") {
+			t.Errorf("ValueCipher.Reencrypt() key ID = %s, want a data key wrapped with the rotated transit key", id)
+		}
+	}
+
+	if _, err := reader.Decrypt(key, encryptedValuePrefix+"vault:v1:unknown:AAAA"); err == nil {
+		t.Error("ValueCipher.Decrypt() error = nil, want an unwrapping error")
+	}
+}
+
+func Test_newValueCipher(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  bool
+		provider string
+		key      string
+		keys     []string
+		wantNil  bool
+		wantErr  bool
+	}{
+		{name: "valid", enabled: true, provider: "local", key: testEncryptionKey},
+		{name: "valid-disabled", enabled: false, provider: "local", wantNil: true},
+		{name: "valid-previous-keys", enabled: false, provider: "local", keys: []string{testEncryptionKeyOld}},
+		{name: "invalid-no-key", enabled: true, provider: "local", keys: []string{testEncryptionKeyOld}, wantErr: true},
+		{name: "invalid-key", enabled: true, provider: "local", key: "AAAA", wantErr: true},
+		{name: "invalid-provider", enabled: true, provider: "kms", key: testEncryptionKey, wantErr: true},
+		{name: "invalid-transit-key", enabled: true, provider: "vault", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.Etcd.Encryption.Enabled = tt.enabled
+			cfg.Etcd.Encryption.Provider = tt.provider
+			cfg.Etcd.Encryption.Key = tt.key
+			cfg.Etcd.Encryption.Keys = tt.keys
+
+			got, err := newValueCipher(cfg, &testLogger{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newValueCipher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (got == nil) != tt.wantNil {
+				t.Errorf("newValueCipher() = %v, wantNil %v", got, tt.wantNil)
+			}
+		})
+	}
+}
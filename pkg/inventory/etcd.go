@@ -4,14 +4,22 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"go.etcd.io/etcd/api/v3/mvccpb"
 	etcdv3 "go.etcd.io/etcd/client/v3"
 	etcdns "go.etcd.io/etcd/client/v3/namespace"
+	"google.golang.org/grpc"
 )
 
 const (
@@ -28,6 +36,19 @@ type (
 		Logger Logger
 		// Etcd client.
 		Client *etcdv3.Client
+		// Host record namespaces selected for reading and publishing, keyed by environment. The default namespace ('prefix') has an empty key.
+		Namespaces map[string]etcdv3.KV
+		// Watchers of the selected host record namespaces, keyed like Namespaces.
+		Watchers map[string]etcdv3.Watcher
+		// Host record cipher, nil if no encryption key is configured.
+		Cipher *ValueCipher
+		// Revision the last GetAllRecords call has read host records at.
+		Revision int64
+		// Zones skipped by the last GetAllRecords call.
+		Failed []string
+
+		// Trace file writer, nil unless tracing is enabled.
+		trace *tracer
 	}
 )
 
@@ -37,32 +58,38 @@ func (e *EtcdDatasource) processKVs(kvs []*mvccpb.KeyValue) []*DatasourceRecord
 	records := make([]*DatasourceRecord, 0)
 
 	// Sets of attributes for every host.
-	hosts := make(map[string]map[int]string)
+	hosts := make(map[string]map[int]*DatasourceRecord)
 
 	for _, kv := range kvs {
 		key := strings.Split(string(kv.Key), "/")
-		value := string(kv.Value)
+
+		value, err := e.Cipher.Decrypt(string(kv.Key), string(kv.Value))
+		if err != nil {
+			log.Warnf(warnSkippedAttributeSet, key[1], err)
+			continue
+		}
 
 		// Determine which set of host attributes we are working with.
 		setN, err := strconv.Atoi(key[2])
 		if err != nil {
-			log.Warnf("[%s] skipping host attributes set: %v", key[1], err)
+			log.Warnf(warnSkippedAttributeSet, key[1], err)
 			continue
 		}
 
 		// Populate this set of attributes for this host, overwriting if it already exists.
 		if hosts[key[1]] == nil {
-			hosts[key[1]] = make(map[int]string)
+			hosts[key[1]] = make(map[int]*DatasourceRecord)
+		}
+		hosts[key[1]][setN] = &DatasourceRecord{
+			Hostname:   key[1],
+			Attributes: value,
+			Source:     string(kv.Key),
 		}
-		hosts[key[1]][setN] = value
 	}
 
-	for name, sets := range hosts {
+	for _, sets := range hosts {
 		for _, set := range sets {
-			records = append(records, &DatasourceRecord{
-				Hostname:   name,
-				Attributes: set,
-			})
+			records = append(records, set)
 		}
 	}
 
@@ -89,24 +116,74 @@ func (e *EtcdDatasource) findZone(host string) (string, error) {
 	return zone, nil
 }
 
-// getPrefix acquires all key/value records for a specific prefix.
-func (e *EtcdDatasource) getPrefix(prefix string) ([]*mvccpb.KeyValue, error) {
+// namespaces returns the names of the selected host record namespaces in a stable order.
+func (e *EtcdDatasource) namespaces() []string {
+	names := make([]string, 0, len(e.Namespaces))
+	for name := range e.Namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// findNamespace selects a host record namespace based on the environment of a host record.
+func (e *EtcdDatasource) findNamespace(attrs string) (string, error) {
+	cfg := e.Config
+	var namespace string
+
+	// Find the environment attribute without fully parsing the record.
+	for _, pair := range strings.Split(attrs, cfg.Txt.Kv.Separator) {
+		if key, value, ok := strings.Cut(pair, cfg.Txt.Kv.Equalsign); ok && key == cfg.Txt.Keys.Env {
+			// Viper folds map keys to lower case.
+			if _, ok := cfg.Etcd.Namespaces[strings.ToLower(value)]; ok {
+				namespace = strings.ToLower(value)
+			}
+			break
+		}
+	}
+
+	if _, ok := e.Namespaces[namespace]; !ok {
+		if len(namespace) == 0 {
+			return namespace, errors.New("default namespace is not selected")
+		}
+
+		return namespace, errors.Errorf("namespace is not selected: %s", namespace)
+	}
+
+	return namespace, nil
+}
+
+// getPrefix acquires all key/value records for a specific prefix, reading at the specified revision unless it is 0.
+// It returns the revision the records have been read at.
+func (e *EtcdDatasource) getPrefix(ctx context.Context, kv etcdv3.KV, prefix string, rev int64) ([]*mvccpb.KeyValue, int64, error) {
 	cfg := e.Config
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.Etcd.Timeout)
-	resp, err := e.Client.Get(ctx, prefix, etcdv3.WithPrefix())
+
+	opts := []etcdv3.OpOption{etcdv3.WithPrefix()}
+	if cfg.Etcd.Consistency == "serializable" {
+		opts = append(opts, etcdv3.WithSerializable())
+	}
+	if rev > 0 {
+		opts = append(opts, etcdv3.WithRev(rev))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, stageTimeout(cfg.Etcd.Timeouts.Read, cfg.Etcd.Timeout))
+	resp, err := kv.Get(ctx, prefix, opts...)
 	cancel()
 	if err != nil {
-		return nil, errors.Wrap(err, "etcd request failure")
+		return nil, 0, errors.Wrap(err, "etcd request failure")
 	}
 
-	return resp.Kvs, nil
+	return resp.Kvs, resp.Header.Revision, nil
 }
 
-// execTxn executes etcd operations in a transaction.
-func (e *EtcdDatasource) execTxn(ops []etcdv3.Op) error {
+// execTxn executes etcd operations in transactions of up to the configured batch size, running several transactions concurrently.
+// Put and delete operations are idempotent, so a failed import can safely be repeated.
+func (e *EtcdDatasource) execTxn(kv etcdv3.KV, ops []etcdv3.Op) error {
 	cfg := e.Config
 
 	var batch []etcdv3.Op
+	batches := make([][]etcdv3.Op, 0)
 	for len(ops) > 0 {
 		if len(ops) >= cfg.Etcd.Import.Batch {
 			batch, ops = ops[0:cfg.Etcd.Import.Batch:cfg.Etcd.Import.Batch], ops[cfg.Etcd.Import.Batch:]
@@ -115,15 +192,38 @@ func (e *EtcdDatasource) execTxn(ops []etcdv3.Op) error {
 			ops = nil
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), cfg.Etcd.Timeout)
-		_, err := e.Client.Txn(ctx).Then(batch...).Commit()
-		cancel()
-		if err != nil {
-			return errors.Wrap(err, "etcd request failure")
-		}
+		batches = append(batches, batch)
 	}
 
-	return nil
+	var wg sync.WaitGroup
+	var once sync.Once
+	var txnErr error
+
+	workers := make(chan struct{}, max(cfg.Etcd.Import.Workers, 1))
+	for _, batch := range batches {
+		workers <- struct{}{}
+		wg.Add(1)
+
+		go func(batch []etcdv3.Op) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), stageTimeout(cfg.Etcd.Timeouts.Write, cfg.Etcd.Timeout))
+			_, err := kv.Txn(ctx).Then(batch...).Commit()
+			cancel()
+			if err != nil {
+				once.Do(func() {
+					txnErr = errors.Wrap(err, "etcd request failure")
+				})
+			}
+		}(batch)
+	}
+
+	wg.Wait()
+
+	return txnErr
 }
 
 // GetAllRecords acquires all available host records.
@@ -132,14 +232,47 @@ func (e *EtcdDatasource) GetAllRecords() ([]*DatasourceRecord, error) {
 	log := e.Logger
 	records := make([]*DatasourceRecord, 0)
 
-	for _, zone := range cfg.Etcd.Zones {
-		kvs, err := e.getPrefix(zone)
-		if err != nil {
-			log.Warnf("[%s] skipping zone: %v", zone, err)
-			continue
+	ctx := context.Background()
+	if cfg.Etcd.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Etcd.Deadline)
+		defer cancel()
+	}
+
+	// Pin all zones to the revision of the first successful read to get a consistent snapshot.
+	var rev int64
+	var total int
+	e.Revision = 0
+	e.Failed = make([]string, 0)
+	for _, namespace := range e.namespaces() {
+		for _, zone := range cfg.Etcd.Zones {
+			total++
+
+			kvs, zoneRev, err := e.getPrefix(ctx, e.Namespaces[namespace], zone, rev)
+			if err != nil {
+				log.Warnf(warnSkippedZone, zone, err)
+				e.Failed = append(e.Failed, zone)
+				continue
+			}
+
+			if cfg.Etcd.Snapshot && rev == 0 {
+				rev = zoneRev
+				log.Debugf("reading etcd zones at revision %d", rev)
+			}
+
+			e.Revision = max(e.Revision, zoneRev)
+
+			records = append(records, e.processKVs(kvs)...)
 		}
+	}
 
-		records = append(records, e.processKVs(kvs)...)
+	// Responses report the current revision, which is newer than the pinned one.
+	if rev > 0 {
+		e.Revision = rev
+	}
+
+	if err := checkZones(e.Failed, total, cfg.Fallback.MaxFailedZones); err != nil {
+		return nil, err
 	}
 
 	return records, nil
@@ -152,27 +285,269 @@ func (e *EtcdDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error
 		return nil, errors.Wrapf(err, "%s: failed to find zone", host)
 	}
 
-	prefix := zone + "/" + host
-	kvs, err := e.getPrefix(prefix)
+	// The environment of a host is unknown until its records are read, so all selected namespaces are searched.
+	records := make([]*DatasourceRecord, 0)
+	prefix := zone + "/" + host + "/"
+	for _, namespace := range e.namespaces() {
+		kvs, _, err := e.getPrefix(context.Background(), e.Namespaces[namespace], prefix, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, e.processKVs(kvs)...)
+	}
+
+	return records, nil
+}
+
+// replayPrefix acquires the events of all keys with a specific prefix between two revisions (inclusive) by replaying them with a watch.
+// If the start revision has been compacted, the compaction revision is returned instead.
+func (e *EtcdDatasource) replayPrefix(ctx context.Context, w etcdv3.Watcher, prefix string, start int64, end int64) ([]*etcdv3.Event, int64, error) {
+	events := make([]*etcdv3.Event, 0)
+
+	if start > end {
+		return events, 0, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Progress notifications are only sent once the watch has caught up, which marks the end of the replay if the prefix has not changed since.
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	ch := w.Watch(ctx, prefix, etcdv3.WithPrefix(), etcdv3.WithRev(start))
+	for {
+		select {
+		case resp, ok := <-ch:
+			if !ok {
+				return nil, 0, errors.New("etcd watch closed")
+			}
+
+			if resp.CompactRevision > 0 {
+				return nil, resp.CompactRevision, nil
+			}
+
+			if err := resp.Err(); err != nil {
+				return nil, 0, errors.Wrap(err, "etcd watch failure")
+			}
+
+			done := resp.IsProgressNotify() && resp.Header.Revision >= end
+			for _, ev := range resp.Events {
+				if ev.Kv.ModRevision > end {
+					done = true
+					break
+				}
+
+				events = append(events, ev)
+				done = done || ev.Kv.ModRevision == end
+			}
+
+			if done {
+				return events, 0, nil
+			}
+		case <-ticker.C:
+			if err := w.RequestProgress(ctx); err != nil {
+				return nil, 0, errors.Wrap(err, "etcd watch failure")
+			}
+		case <-ctx.Done():
+			return nil, 0, errors.Wrap(ctx.Err(), "etcd watch failure")
+		}
+	}
+}
+
+// GetHostHistory acquires all states of the records of a specific host kept by etcd, starting at the oldest revision that has not been compacted.
+// Every revision that has changed the records of the host in any of the selected namespaces produces a state.
+func (e *EtcdDatasource) GetHostHistory(host string) ([]*HostRevision, error) {
+	cfg := e.Config
+	log := e.Logger
+
+	zone, err := e.findZone(host)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrapf(err, "%s: failed to find zone", host)
 	}
 
-	return e.processKVs(kvs), nil
+	type change struct {
+		namespace string
+		event     *etcdv3.Event
+	}
+	changes := make([]change, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), stageTimeout(cfg.Etcd.Timeouts.Read, cfg.Etcd.Timeout))
+	defer cancel()
+
+	prefix := zone + "/" + host + "/"
+	for _, namespace := range e.namespaces() {
+		// The current revision marks the end of the history.
+		_, end, err := e.getPrefix(ctx, e.Namespaces[namespace], prefix, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		events, compacted, err := e.replayPrefix(ctx, e.Watchers[namespace], prefix, 1, end)
+		if err != nil {
+			return nil, err
+		}
+
+		// Older revisions are gone, so history starts with the state at the compaction revision.
+		if compacted > 0 {
+			log.Debugf("[%s] etcd history has been compacted at revision %d", host, compacted)
+
+			kvs, _, err := e.getPrefix(ctx, e.Namespaces[namespace], prefix, compacted)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, kv := range kvs {
+				changes = append(changes, change{namespace: namespace, event: &etcdv3.Event{Type: mvccpb.PUT, Kv: &mvccpb.KeyValue{Key: kv.Key, Value: kv.Value, ModRevision: compacted}}})
+			}
+
+			if events, _, err = e.replayPrefix(ctx, e.Watchers[namespace], prefix, compacted+1, end); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, ev := range events {
+			changes = append(changes, change{namespace: namespace, event: ev})
+		}
+	}
+
+	// Revisions are global, so changes in different namespaces can be merged.
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].event.Kv.ModRevision < changes[j].event.Kv.ModRevision })
+
+	revisions := make([]*HostRevision, 0)
+	state := make(map[string]*DatasourceRecord)
+	for n, c := range changes {
+		id := c.namespace + "/" + string(c.event.Kv.Key)
+
+		if c.event.Type == mvccpb.DELETE {
+			delete(state, id)
+		} else {
+			value, err := e.Cipher.Decrypt(string(c.event.Kv.Key), string(c.event.Kv.Value))
+			if err != nil {
+				log.Warnf(warnSkippedAttributeSet, host, err)
+				continue
+			}
+
+			state[id] = &DatasourceRecord{Hostname: host, Attributes: value, Source: string(c.event.Kv.Key)}
+		}
+
+		// A single transaction may change several records.
+		if n < len(changes)-1 && changes[n+1].event.Kv.ModRevision == c.event.Kv.ModRevision {
+			continue
+		}
+
+		ids := make([]string, 0, len(state))
+		for id := range state {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return compareRecordKeys(ids[i], ids[j]) })
+
+		revision := &HostRevision{Revision: c.event.Kv.ModRevision, Records: make([]*DatasourceRecord, 0, len(ids))}
+		for _, id := range ids {
+			revision.Records = append(revision.Records, state[id])
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, nil
 }
 
-// PublishRecords writes host records to the datasource.
-func (e *EtcdDatasource) PublishRecords(records []*DatasourceRecord) error {
+// compareRecordKeys orders host record keys by namespace and attribute set number.
+func compareRecordKeys(a string, b string) bool {
+	aPrefix, aSet := a[:strings.LastIndex(a, "/")+1], a[strings.LastIndex(a, "/")+1:]
+	bPrefix, bSet := b[:strings.LastIndex(b, "/")+1], b[strings.LastIndex(b, "/")+1:]
+
+	if aPrefix != bPrefix {
+		return aPrefix < bPrefix
+	}
+
+	aN, aErr := strconv.Atoi(aSet)
+	bN, bErr := strconv.Atoi(bSet)
+	if aErr != nil || bErr != nil {
+		return aSet < bSet
+	}
+
+	return aN < bN
+}
+
+// GetGroupVariables acquires group variables from the '<key>/<group>/<variable>' keys of all selected namespaces.
+// Values containing valid JSON are decoded, other values are returned as strings.
+func (e *EtcdDatasource) GetGroupVariables() (map[string]map[string]interface{}, error) {
 	cfg := e.Config
 	log := e.Logger
+	groups := make(map[string]map[string]interface{})
+
+	if !cfg.Etcd.Groupvars.Enabled {
+		return groups, nil
+	}
+
+	prefix := strings.Trim(cfg.Etcd.Groupvars.Key, "/") + "/"
+	for _, namespace := range e.namespaces() {
+		kvs, _, err := e.getPrefix(context.Background(), e.Namespaces[namespace], prefix, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, kv := range kvs {
+			group, name, ok := strings.Cut(strings.TrimPrefix(string(kv.Key), prefix), "/")
+			if !ok || len(group) == 0 || len(name) == 0 {
+				log.Warnf("[%s] skipping invalid group variable key", string(kv.Key))
+				continue
+			}
+
+			value, err := e.Cipher.Decrypt(string(kv.Key), string(kv.Value))
+			if err != nil {
+				log.Warnf("[%s] skipping group variable: %v", string(kv.Key), err)
+				continue
+			}
+
+			if groups[group] == nil {
+				groups[group] = make(map[string]interface{})
+			}
+
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(value), &decoded); err == nil {
+				groups[group][name] = decoded
+			} else {
+				groups[group][name] = value
+			}
+		}
+	}
+
+	return groups, nil
+}
+
+// ClearRecords removes all existing host records if the datasource is configured to do so before publishing.
+// Only the zones listed in the configuration are cleared, so that group variables and other keys are preserved.
+func (e *EtcdDatasource) ClearRecords() error {
+	cfg := e.Config
+
+	if !cfg.Etcd.Import.Clear {
+		return nil
+	}
+
+	for _, namespace := range e.namespaces() {
+		ops := make([]etcdv3.Op, 0, len(cfg.Etcd.Zones))
+		for _, zone := range cfg.Etcd.Zones {
+			ops = append(ops, etcdv3.OpDelete(zone+"/", etcdv3.WithPrefix()))
+		}
 
-	if cfg.Etcd.Import.Clear {
-		if err := e.execTxn([]etcdv3.Op{etcdv3.OpDelete("", etcdv3.WithPrefix())}); err != nil {
+		if err := e.execTxn(e.Namespaces[namespace], ops); err != nil {
 			return err
 		}
 	}
 
-	ops := []etcdv3.Op{}
+	return nil
+}
+
+// PutRecords writes host records to the datasource without removing existing records.
+func (e *EtcdDatasource) PutRecords(records []*DatasourceRecord) error {
+	cfg := e.Config
+	log := e.Logger
+
+	ops := map[string][]etcdv3.Op{}
 	counts := map[string]int{}
 	for _, record := range records {
 		if _, ok := counts[record.Hostname]; ok {
@@ -183,23 +558,222 @@ func (e *EtcdDatasource) PublishRecords(records []*DatasourceRecord) error {
 
 		zone, err := e.findZone(record.Hostname)
 		if err != nil {
-			log.Warnf("[%s] skipping host record: %v", record.Hostname, err)
+			log.Warnf(warnSkippedRecord, record.Hostname, err)
+			continue
+		}
+
+		namespace, err := e.findNamespace(record.Attributes)
+		if err != nil {
+			log.Warnf(warnSkippedRecord, record.Hostname, err)
 			continue
 		}
 
-		ops = append(ops, etcdv3.OpPut(fmt.Sprintf("%s/%s/%d", zone, record.Hostname, counts[record.Hostname]), record.Attributes))
+		key := fmt.Sprintf("%s/%s/%d", zone, record.Hostname, counts[record.Hostname])
+		value := record.Attributes
+
+		if cfg.Etcd.Encryption.Enabled {
+			if value, err = e.Cipher.Encrypt(key, value); err != nil {
+				return errors.Wrap(err, "encryption failure")
+			}
+		}
+
+		ops[namespace] = append(ops[namespace], etcdv3.OpPut(key, value))
+	}
+
+	for _, namespace := range e.namespaces() {
+		if err := e.execTxn(e.Namespaces[namespace], ops[namespace]); err != nil {
+			return err
+		}
 	}
 
-	if err := e.execTxn(ops); err != nil {
+	return nil
+}
+
+// PublishRecords writes host records to the datasource.
+func (e *EtcdDatasource) PublishRecords(records []*DatasourceRecord) error {
+	if err := e.ClearRecords(); err != nil {
 		return err
 	}
 
+	return e.PutRecords(records)
+}
+
+// ReplaceHost replaces all records of a host, removing its existing records from every selected namespace first.
+// Removal and publishing are separate transactions, as etcd rejects transactions that delete and put the same keys.
+func (e *EtcdDatasource) ReplaceHost(host string, records []*DatasourceRecord) error {
+	zone, err := e.findZone(host)
+	if err != nil {
+		return errors.Wrapf(err, "%s: failed to find zone", host)
+	}
+
+	for _, namespace := range e.namespaces() {
+		op := etcdv3.OpDelete(fmt.Sprintf("%s/%s/", zone, host), etcdv3.WithPrefix())
+		if err := e.execTxn(e.Namespaces[namespace], []etcdv3.Op{op}); err != nil {
+			return err
+		}
+	}
+
+	return e.PutRecords(records)
+}
+
+// RenameHost moves all records of a host to a new hostname, one transaction per namespace.
+// A transaction fails if the new hostname already has records in its namespace.
+func (e *EtcdDatasource) RenameHost(from string, to string) error {
+	cfg := e.Config
+
+	fromZone, err := e.findZone(from)
+	if err != nil {
+		return errors.Wrapf(err, "%s: failed to find zone", from)
+	}
+
+	toZone, err := e.findZone(to)
+	if err != nil {
+		return errors.Wrapf(err, "%s: failed to find zone", to)
+	}
+
+	for _, namespace := range e.namespaces() {
+		kv := e.Namespaces[namespace]
+
+		kvs, _, err := e.getPrefix(context.Background(), kv, fmt.Sprintf("%s/%s/", fromZone, from), 0)
+		if err != nil {
+			return err
+		}
+
+		if len(kvs) == 0 {
+			continue
+		}
+
+		ops := make([]etcdv3.Op, 0, 2*len(kvs))
+		cmps := make([]etcdv3.Cmp, 0, len(kvs))
+		for _, pair := range kvs {
+			oldKey := string(pair.Key)
+			newKey := fmt.Sprintf("%s/%s/%s", toZone, to, strings.TrimPrefix(oldKey, fmt.Sprintf("%s/%s/", fromZone, from)))
+
+			// Encrypted values are bound to their keys and have to be encrypted again.
+			value := string(pair.Value)
+			if isEncryptedValue(value) {
+				plain, err := e.Cipher.Decrypt(oldKey, value)
+				if err != nil {
+					return errors.Wrapf(err, "%s: decryption failure", oldKey)
+				}
+
+				if value, err = e.Cipher.Encrypt(newKey, plain); err != nil {
+					return errors.Wrap(err, "encryption failure")
+				}
+			}
+
+			cmps = append(cmps, etcdv3.Compare(etcdv3.CreateRevision(newKey), "=", 0))
+			ops = append(ops, etcdv3.OpPut(newKey, value), etcdv3.OpDelete(oldKey))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), stageTimeout(cfg.Etcd.Timeouts.Write, cfg.Etcd.Timeout))
+		resp, err := kv.Txn(ctx).If(cmps...).Then(ops...).Commit()
+		cancel()
+		if err != nil {
+			return errors.Wrap(err, "etcd request failure")
+		}
+
+		if !resp.Succeeded {
+			return errors.Errorf("%s: host records already exist", to)
+		}
+	}
+
 	return nil
 }
 
+// ReencryptRecords encrypts all host records and group variables of the selected namespaces with the current encryption key,
+// one transaction per batch of keys. A transaction fails if any of its keys has been modified since it was read.
+func (e *EtcdDatasource) ReencryptRecords(dryRun bool) (*Reencryption, error) {
+	cfg := e.Config
+
+	if !cfg.Etcd.Encryption.Enabled {
+		return nil, errors.New("encryption is not enabled")
+	}
+
+	prefixes := slices.Clone(cfg.Etcd.Zones)
+	if cfg.Etcd.Groupvars.Enabled {
+		prefixes = append(prefixes, strings.Trim(cfg.Etcd.Groupvars.Key, "/")+"/")
+	}
+
+	report := &Reencryption{Keys: make(map[string]int)}
+	for _, namespace := range e.namespaces() {
+		kv := e.Namespaces[namespace]
+
+		ops := make([]etcdv3.Op, 0)
+		cmps := make([]etcdv3.Cmp, 0)
+		for _, prefix := range prefixes {
+			kvs, _, err := e.getPrefix(context.Background(), kv, prefix, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, pair := range kvs {
+				key := string(pair.Key)
+
+				id := e.Cipher.KeyID(string(pair.Value))
+				if len(id) == 0 {
+					id = "plaintext"
+				}
+
+				value, changed, err := e.Cipher.Reencrypt(key, string(pair.Value))
+				if err != nil {
+					return nil, errors.Wrapf(err, "%s: re-encryption failure", key)
+				}
+
+				report.Records++
+				report.Keys[id]++
+
+				if changed {
+					report.Changed++
+					cmps = append(cmps, etcdv3.Compare(etcdv3.ModRevision(key), "=", pair.ModRevision))
+					ops = append(ops, etcdv3.OpPut(key, value))
+				}
+			}
+		}
+
+		if dryRun {
+			continue
+		}
+
+		for len(ops) > 0 {
+			n := min(len(ops), max(cfg.Etcd.Import.Batch, 1))
+
+			ctx, cancel := context.WithTimeout(context.Background(), stageTimeout(cfg.Etcd.Timeouts.Write, cfg.Etcd.Timeout))
+			resp, err := kv.Txn(ctx).If(cmps[:n]...).Then(ops[:n]...).Commit()
+			cancel()
+			if err != nil {
+				return nil, errors.Wrap(err, "etcd request failure")
+			}
+
+			if !resp.Succeeded {
+				return nil, errors.New("host records have been modified concurrently, retry the operation")
+			}
+
+			ops, cmps = ops[n:], cmps[n:]
+		}
+	}
+
+	if key, err := e.Cipher.primaryKey(); err == nil {
+		report.Key = key.id
+	}
+
+	return report, nil
+}
+
+// Metadata returns the revision host records have been read at by the last GetAllRecords call.
+func (e *EtcdDatasource) Metadata() map[string]interface{} {
+	return map[string]interface{}{"revision": e.Revision}
+}
+
+// FailedZones returns the zones skipped by the last GetAllRecords call.
+func (e *EtcdDatasource) FailedZones() []string {
+	return e.Failed
+}
+
 // Close shuts down the datasource and performs other housekeeping.
 func (e *EtcdDatasource) Close() {
 	e.Client.Close()
+	e.trace.Close()
 }
 
 func makeEtcdTLSConfig(cfg *Config) (*tls.Config, error) {
@@ -234,40 +808,173 @@ func makeEtcdTLSConfig(cfg *Config) (*tls.Config, error) {
 	}, nil
 }
 
-// NewEtcdDatasource creates an etcd datasource.
-func NewEtcdDatasource(cfg *Config, log Logger) (*EtcdDatasource, error) {
+// probeEtcdEndpoints checks whether etcd endpoints accept connections.
+// It returns the reachable endpoints ordered by connection time, or all endpoints as is if none of them are reachable.
+func probeEtcdEndpoints(endpoints []string, timeout time.Duration) []string {
+	latencies := make([]time.Duration, len(endpoints))
+
+	var wg sync.WaitGroup
+	for n, endpoint := range endpoints {
+		wg.Add(1)
+
+		go func(n int, endpoint string) {
+			defer wg.Done()
+
+			// Endpoints may be specified as URLs.
+			address := endpoint
+			if u, err := url.Parse(endpoint); err == nil && len(u.Host) > 0 {
+				address = u.Host
+			}
+
+			start := time.Now()
+			conn, err := net.DialTimeout("tcp", address, timeout)
+			if err != nil {
+				latencies[n] = -1
+				return
+			}
+			latencies[n] = time.Since(start)
+			conn.Close()
+		}(n, endpoint)
+	}
+	wg.Wait()
+
+	healthy := make([]int, 0, len(endpoints))
+	for n := range endpoints {
+		if latencies[n] >= 0 {
+			healthy = append(healthy, n)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return endpoints
+	}
+
+	sort.SliceStable(healthy, func(a, b int) bool {
+		return latencies[healthy[a]] < latencies[healthy[b]]
+	})
+
+	result := make([]string, 0, len(healthy))
+	for _, n := range healthy {
+		result = append(result, endpoints[n])
+	}
+
+	return result
+}
+
+// dialEtcd creates an etcd client using the etcd datasource configuration, without setting a namespace.
+// Request and response metadata are dumped to the trace file if a tracer is passed.
+func dialEtcd(cfg *Config, trace *tracer) (*etcdv3.Client, error) {
+	endpoints := cfg.Etcd.Endpoints
+	if cfg.Etcd.Health.Enabled && len(endpoints) > 1 {
+		endpoints = probeEtcdEndpoints(endpoints, cfg.Etcd.Health.Timeout)
+	}
+
 	// Etcd client configuration
 	clientCfg := etcdv3.Config{
-		Endpoints:   cfg.Etcd.Endpoints,
-		DialTimeout: cfg.Etcd.Timeout,
-		Username:    cfg.Etcd.Auth.Username,
-		Password:    cfg.Etcd.Auth.Password,
+		Endpoints:            endpoints,
+		DialTimeout:          stageTimeout(cfg.Etcd.Timeouts.Dial, cfg.Etcd.Timeout),
+		DialKeepAliveTime:    cfg.Etcd.Keepalive.Time,
+		DialKeepAliveTimeout: cfg.Etcd.Keepalive.Timeout,
+		PermitWithoutStream:  cfg.Etcd.Keepalive.PermitWithoutStream,
+		MaxCallRecvMsgSize:   int(cfg.Etcd.GRPC.MaxRecvSize),
+		MaxCallSendMsgSize:   int(cfg.Etcd.GRPC.MaxSendSize),
+		RejectOldCluster:     cfg.Etcd.GRPC.RejectOldCluster,
+		Username:             cfg.Etcd.Auth.Username,
+		Password:             cfg.Etcd.Auth.Password,
 	}
 
 	// Setup TLS.
 	if cfg.Etcd.TLS.Enabled {
 		tlsCfg, err := makeEtcdTLSConfig(cfg)
 		if err != nil {
-			return nil, errors.Wrap(err, "etcd datasource initialization failure")
+			return nil, err
 		}
 		clientCfg.TLS = tlsCfg
 	}
 
+	if trace != nil {
+		clientCfg.DialOptions = append(clientCfg.DialOptions, grpc.WithChainUnaryInterceptor(trace.etcdInterceptor()))
+	}
+
 	// Create etcd client.
-	client, err := etcdv3.New(clientCfg)
+	return etcdv3.New(clientCfg)
+}
+
+// NewEtcdClient creates an etcd client using the etcd datasource configuration.
+func NewEtcdClient(cfg *Config) (*etcdv3.Client, error) {
+	client, err := dialEtcd(cfg, nil)
 	if err != nil {
-		return nil, errors.Wrap(err, "etcd datasource initialization failure")
+		return nil, err
 	}
 
 	// Set etcd namespace.
-	ns := cfg.Etcd.Prefix
+	setEtcdNamespace(client, cfg.Etcd.Prefix)
+
+	return client, nil
+}
+
+// setEtcdNamespace restricts an etcd client to a k/v path prefix.
+func setEtcdNamespace(client *etcdv3.Client, ns string) {
 	client.KV = etcdns.NewKV(client.KV, ns+"/")
 	client.Watcher = etcdns.NewWatcher(client.Watcher, ns+"/")
 	client.Lease = etcdns.NewLease(client.Lease, ns+"/")
+}
+
+// NewEtcdDatasource creates an etcd datasource.
+func NewEtcdDatasource(cfg *Config, log Logger) (*EtcdDatasource, error) {
+	switch cfg.Etcd.Consistency {
+	case "linearizable", "serializable":
+	default:
+		return nil, errors.Errorf("unknown etcd read consistency level: %s", cfg.Etcd.Consistency)
+	}
+
+	valueCipher, err := newValueCipher(cfg, log)
+	if err != nil {
+		return nil, errors.Wrap(err, "etcd datasource initialization failure")
+	}
+
+	// Viper folds map keys to lower case.
+	selected := strings.ToLower(cfg.Etcd.Namespace)
+	if _, ok := cfg.Etcd.Namespaces[selected]; len(selected) > 0 && !ok {
+		return nil, errors.Errorf("unknown etcd namespace: %s", cfg.Etcd.Namespace)
+	}
+
+	trace, err := newTracer(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "etcd datasource initialization failure")
+	}
+
+	client, err := dialEtcd(cfg, trace)
+	if err != nil {
+		trace.Close()
+		return nil, errors.Wrap(err, "etcd datasource initialization failure")
+	}
+
+	// Set up host record namespaces.
+	namespaces := make(map[string]etcdv3.KV)
+	watchers := make(map[string]etcdv3.Watcher)
+	for env, prefix := range cfg.Etcd.Namespaces {
+		if len(selected) == 0 || env == selected {
+			namespaces[env] = etcdns.NewKV(client.KV, prefix+"/")
+			watchers[env] = etcdns.NewWatcher(client.Watcher, prefix+"/")
+		}
+	}
+
+	// Set the default etcd namespace.
+	setEtcdNamespace(client, cfg.Etcd.Prefix)
+
+	if len(selected) == 0 {
+		namespaces[""] = client.KV
+		watchers[""] = client.Watcher
+	}
 
 	return &EtcdDatasource{
-		Config: cfg,
-		Logger: log,
-		Client: client,
+		Config:     cfg,
+		Logger:     log,
+		Client:     client,
+		Namespaces: namespaces,
+		Watchers:   watchers,
+		Cipher:     valueCipher,
+		trace:      trace,
 	}, nil
 }
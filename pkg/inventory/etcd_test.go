@@ -0,0 +1,535 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/creasty/defaults"
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	etcdv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// testEtcdStore is an etcd KV service keeping every revision of its keys.
+// Only the requests and comparisons used by the etcd datasource are supported.
+type testEtcdStore struct {
+	etcdserverpb.UnimplementedKVServer
+
+	mu  sync.Mutex
+	rev int64
+	// Versions of every key, oldest first. A deleted key ends with a nil version.
+	versions map[string][]*mvccpb.KeyValue
+	// Range requests received so far.
+	ranges []*etcdserverpb.RangeRequest
+	// Called after every range request.
+	afterRange func()
+}
+
+// current returns the version of a key at a revision, nil if it does not exist.
+func (s *testEtcdStore) current(key string, rev int64) *mvccpb.KeyValue {
+	var found *mvccpb.KeyValue
+	for _, v := range s.versions[key] {
+		if v != nil && v.ModRevision > rev {
+			break
+		}
+		found = v
+	}
+
+	return found
+}
+
+// apply executes put and delete requests in a new revision.
+func (s *testEtcdStore) apply(ops []*etcdserverpb.RequestOp) {
+	s.rev++
+
+	for _, op := range ops {
+		switch {
+		case op.GetRequestPut() != nil:
+			put := op.GetRequestPut()
+			v := &mvccpb.KeyValue{Key: put.Key, Value: put.Value, CreateRevision: s.rev, ModRevision: s.rev}
+			if prev := s.current(string(put.Key), s.rev); prev != nil {
+				v.CreateRevision = prev.CreateRevision
+			}
+			s.versions[string(put.Key)] = append(s.versions[string(put.Key)], v)
+		case op.GetRequestDeleteRange() != nil:
+			key := string(op.GetRequestDeleteRange().Key)
+			if s.current(key, s.rev) != nil {
+				s.versions[key] = append(s.versions[key], nil)
+			}
+		}
+	}
+}
+
+// keys returns all existing keys and their values.
+func (s *testEtcdStore) keys() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kvs := make(map[string]string)
+	for key := range s.versions {
+		if v := s.current(key, s.rev); v != nil {
+			kvs[key] = string(v.Value)
+		}
+	}
+
+	return kvs
+}
+
+func (s *testEtcdStore) Range(ctx context.Context, req *etcdserverpb.RangeRequest) (*etcdserverpb.RangeResponse, error) {
+	s.mu.Lock()
+	s.ranges = append(s.ranges, req)
+
+	rev := req.Revision
+	if rev == 0 {
+		rev = s.rev
+	}
+
+	resp := &etcdserverpb.RangeResponse{Header: &etcdserverpb.ResponseHeader{Revision: s.rev}}
+	for k := range s.versions {
+		key := []byte(k)
+		if !bytes.Equal(key, req.Key) && (len(req.RangeEnd) == 0 || bytes.Compare(key, req.Key) < 0 || bytes.Compare(key, req.RangeEnd) >= 0) {
+			continue
+		}
+
+		if v := s.current(k, rev); v != nil {
+			resp.Kvs = append(resp.Kvs, v)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(resp.Kvs, func(a, b int) bool { return bytes.Compare(resp.Kvs[a].Key, resp.Kvs[b].Key) < 0 })
+	resp.Count = int64(len(resp.Kvs))
+
+	if s.afterRange != nil {
+		s.afterRange()
+	}
+
+	return resp, nil
+}
+
+func (s *testEtcdStore) Put(ctx context.Context, req *etcdserverpb.PutRequest) (*etcdserverpb.PutResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apply([]*etcdserverpb.RequestOp{{Request: &etcdserverpb.RequestOp_RequestPut{RequestPut: req}}})
+
+	return &etcdserverpb.PutResponse{Header: &etcdserverpb.ResponseHeader{Revision: s.rev}}, nil
+}
+
+func (s *testEtcdStore) Txn(ctx context.Context, req *etcdserverpb.TxnRequest) (*etcdserverpb.TxnResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	succeeded := true
+	for _, cmp := range req.Compare {
+		var got, want int64
+		v := s.current(string(cmp.Key), s.rev)
+
+		switch cmp.Target {
+		case etcdserverpb.Compare_CREATE:
+			want = cmp.GetCreateRevision()
+			if v != nil {
+				got = v.CreateRevision
+			}
+		case etcdserverpb.Compare_MOD:
+			want = cmp.GetModRevision()
+			if v != nil {
+				got = v.ModRevision
+			}
+		default:
+			return nil, errors.Errorf("unsupported comparison target: %v", cmp.Target)
+		}
+
+		switch cmp.Result {
+		case etcdserverpb.Compare_EQUAL:
+			succeeded = succeeded && got == want
+		case etcdserverpb.Compare_NOT_EQUAL:
+			succeeded = succeeded && got != want
+		case etcdserverpb.Compare_GREATER:
+			succeeded = succeeded && got > want
+		case etcdserverpb.Compare_LESS:
+			succeeded = succeeded && got < want
+		}
+	}
+
+	if succeeded {
+		s.apply(req.Success)
+	} else {
+		s.apply(req.Failure)
+	}
+
+	return &etcdserverpb.TxnResponse{Header: &etcdserverpb.ResponseHeader{Revision: s.rev}, Succeeded: succeeded}, nil
+}
+
+// startTestEtcdStore starts an etcd KV service holding a set of keys, written in a single revision.
+func startTestEtcdStore(t *testing.T, kvs map[string]string) (*testEtcdStore, string) {
+	store := &testEtcdStore{versions: make(map[string][]*mvccpb.KeyValue)}
+
+	ops := make([]*etcdserverpb.RequestOp, 0, len(kvs))
+	for key, value := range kvs {
+		ops = append(ops, &etcdserverpb.RequestOp{Request: &etcdserverpb.RequestOp_RequestPut{RequestPut: &etcdserverpb.PutRequest{Key: []byte(key), Value: []byte(value)}}})
+	}
+	store.apply(ops)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer()
+	etcdserverpb.RegisterKVServer(srv, store)
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+
+	return store, ln.Addr().String()
+}
+
+// newTestEtcdConfig creates a configuration of the etcd datasource connecting to an endpoint.
+func newTestEtcdConfig(t *testing.T, endpoint string) *Config {
+	cfg := &Config{}
+	if err := defaults.Set(cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Etcd.Endpoints = []string{endpoint}
+	cfg.Etcd.Timeout = 5 * time.Second
+	cfg.Etcd.TLS.Enabled = false
+
+	return cfg
+}
+
+func TestEtcdDatasource_findNamespace(t *testing.T) {
+	cfg := &Config{}
+	cfg.Txt.Kv.Separator = ";"
+	cfg.Txt.Kv.Equalsign = "="
+	cfg.Txt.Keys.Env = "ENV"
+	cfg.Etcd.Namespaces = map[string]string{
+		"prod": "ANSIBLE_INVENTORY_PROD",
+		"lab":  "ANSIBLE_INVENTORY_LAB",
+	}
+
+	tests := []struct {
+		name       string
+		namespaces []string
+		attrs      string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:       "valid",
+			namespaces: []string{"", "prod", "lab"},
+			attrs:      "OS=linux;ENV=prod;ROLE=app;SRV=tomcat",
+			want:       "prod",
+			wantErr:    false,
+		},
+		{
+			name:       "valid-default",
+			namespaces: []string{"", "prod", "lab"},
+			attrs:      "OS=linux;ENV=dev;ROLE=app;SRV=tomcat",
+			want:       "",
+			wantErr:    false,
+		},
+		{
+			name:       "valid-case",
+			namespaces: []string{"lab"},
+			attrs:      "OS=linux;ENV=LAB;ROLE=app;SRV=tomcat",
+			want:       "lab",
+			wantErr:    false,
+		},
+		{
+			name:       "invalid-not-selected",
+			namespaces: []string{"lab"},
+			attrs:      "OS=linux;ENV=prod;ROLE=app;SRV=tomcat",
+			want:       "prod",
+			wantErr:    true,
+		},
+		{
+			name:       "invalid-default-not-selected",
+			namespaces: []string{"lab"},
+			attrs:      "OS=linux;ENV=dev;ROLE=app;SRV=tomcat",
+			want:       "",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &EtcdDatasource{Config: cfg, Namespaces: make(map[string]etcdv3.KV)}
+			for _, namespace := range tt.namespaces {
+				e.Namespaces[namespace] = nil
+			}
+
+			got, err := e.findNamespace(tt.attrs)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EtcdDatasource.findNamespace() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("EtcdDatasource.findNamespace() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_probeEtcdEndpoints(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// Grab a free port and close it to get an unreachable endpoint.
+	d, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := d.Addr().String()
+	d.Close()
+
+	alive := l.Addr().String()
+
+	tests := []struct {
+		name      string
+		endpoints []string
+		want      []string
+	}{
+		{
+			name:      "valid",
+			endpoints: []string{dead, alive},
+			want:      []string{alive},
+		},
+		{
+			name:      "valid-url",
+			endpoints: []string{"http://" + dead, "http://" + alive},
+			want:      []string{"http://" + alive},
+		},
+		{
+			name:      "valid-none-reachable",
+			endpoints: []string{dead},
+			want:      []string{dead},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := probeEtcdEndpoints(tt.endpoints, time.Second); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("probeEtcdEndpoints() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewEtcdDatasource(t *testing.T) {
+	tests := []struct {
+		name        string
+		consistency string
+		wantErr     bool
+	}{
+		{
+			name:        "valid-linearizable",
+			consistency: "linearizable",
+		},
+		{
+			name:        "valid-serializable",
+			consistency: "serializable",
+		},
+		{
+			name:        "invalid-consistency",
+			consistency: "strong",
+			wantErr:     true,
+		},
+		{
+			name:    "invalid-consistency-empty",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, endpoint := startTestEtcdStore(t, nil)
+
+			cfg := newTestEtcdConfig(t, endpoint)
+			cfg.Etcd.Consistency = tt.consistency
+
+			e, err := NewEtcdDatasource(cfg, zap.NewNop().Sugar())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewEtcdDatasource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil && !strings.Contains(err.Error(), "consistency") {
+				t.Errorf("NewEtcdDatasource() error = %v, want a consistency level error", err)
+			}
+
+			if e != nil {
+				e.Close()
+			}
+		})
+	}
+}
+
+func TestEtcdDatasource_GetAllRecords_consistency(t *testing.T) {
+	tests := []struct {
+		name         string
+		consistency  string
+		snapshot     bool
+		want         []string
+		wantRevision int64
+	}{
+		{
+			// Records written while the zones are read show up in the zones read later.
+			name:        "valid-linearizable",
+			consistency: "linearizable",
+			want:        []string{"app01.infra.local", "app02.infra.local", "db01.prod.local", "db02.prod.local"},
+		},
+		{
+			name:        "valid-serializable",
+			consistency: "serializable",
+			want:        []string{"app01.infra.local", "app02.infra.local", "db01.prod.local", "db02.prod.local"},
+		},
+		{
+			// All zones are read at the revision of the first one.
+			name:         "valid-snapshot",
+			consistency:  "linearizable",
+			snapshot:     true,
+			want:         []string{"app01.infra.local", "app02.infra.local", "db01.prod.local"},
+			wantRevision: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, endpoint := startTestEtcdStore(t, map[string]string{
+				"ANSIBLE_INVENTORY/infra.local./app01.infra.local/0": "OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS=",
+				"ANSIBLE_INVENTORY/infra.local./app02.infra.local/0": "OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS=",
+				"ANSIBLE_INVENTORY/prod.local./db01.prod.local/0":    "OS=linux;ENV=prod;ROLE=db;SRV=postgres;VARS=",
+			})
+
+			// Another host record is written right after the first zone has been read.
+			var once sync.Once
+			store.afterRange = func() {
+				once.Do(func() {
+					store.Put(context.Background(), &etcdserverpb.PutRequest{Key: []byte("ANSIBLE_INVENTORY/prod.local./db02.prod.local/0"), Value: []byte("OS=linux;ENV=prod;ROLE=db;SRV=postgres;VARS=")})
+				})
+			}
+
+			cfg := newTestEtcdConfig(t, endpoint)
+			cfg.Etcd.Zones = []string{"infra.local.", "prod.local."}
+			cfg.Etcd.Consistency = tt.consistency
+			cfg.Etcd.Snapshot = tt.snapshot
+
+			e, err := NewEtcdDatasource(cfg, zap.NewNop().Sugar())
+			if err != nil {
+				t.Fatalf("NewEtcdDatasource() error = %v", err)
+			}
+			defer e.Close()
+
+			records, err := e.GetAllRecords()
+			if err != nil {
+				t.Fatalf("EtcdDatasource.GetAllRecords() error = %v", err)
+			}
+
+			got := make([]string, 0)
+			for _, r := range records {
+				got = append(got, r.Hostname)
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EtcdDatasource.GetAllRecords() = %v, want %v", got, tt.want)
+			}
+
+			for n, req := range store.ranges {
+				if req.Serializable != (tt.consistency == "serializable") {
+					t.Errorf("range request %d serializable = %v, want %v", n, req.Serializable, tt.consistency == "serializable")
+				}
+
+				if n > 0 && req.Revision != tt.wantRevision {
+					t.Errorf("range request %d revision = %v, want %v", n, req.Revision, tt.wantRevision)
+				}
+			}
+		})
+	}
+}
+
+func TestEtcdDatasource_RenameHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			from: "app01.infra.local",
+			to:   "app03.infra.local",
+			want: map[string]string{
+				"ANSIBLE_INVENTORY/infra.local./app02.infra.local/0": "OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS=",
+				"ANSIBLE_INVENTORY/infra.local./app03.infra.local/0": "OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS=",
+				"ANSIBLE_INVENTORY/infra.local./app03.infra.local/1": "OS=linux;ENV=dev;ROLE=app;SRV=nginx;VARS=",
+			},
+		},
+		{
+			// Records are moved to the zone of the new hostname.
+			name: "valid-cross-zone",
+			from: "app01.infra.local",
+			to:   "app01.prod.local",
+			want: map[string]string{
+				"ANSIBLE_INVENTORY/infra.local./app02.infra.local/0": "OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS=",
+				"ANSIBLE_INVENTORY/prod.local./app01.prod.local/0":   "OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS=",
+				"ANSIBLE_INVENTORY/prod.local./app01.prod.local/1":   "OS=linux;ENV=dev;ROLE=app;SRV=nginx;VARS=",
+			},
+		},
+		{
+			// Nothing is moved if the new hostname already has records.
+			name:    "invalid-existing",
+			from:    "app01.infra.local",
+			to:      "app02.infra.local",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-zone",
+			from:    "app01.infra.local",
+			to:      "app01.other.local",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initial := map[string]string{
+				"ANSIBLE_INVENTORY/infra.local./app01.infra.local/0": "OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS=",
+				"ANSIBLE_INVENTORY/infra.local./app01.infra.local/1": "OS=linux;ENV=dev;ROLE=app;SRV=nginx;VARS=",
+				"ANSIBLE_INVENTORY/infra.local./app02.infra.local/0": "OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS=",
+			}
+			store, endpoint := startTestEtcdStore(t, initial)
+
+			cfg := newTestEtcdConfig(t, endpoint)
+			cfg.Etcd.Zones = []string{"infra.local.", "prod.local."}
+
+			e, err := NewEtcdDatasource(cfg, zap.NewNop().Sugar())
+			if err != nil {
+				t.Fatalf("NewEtcdDatasource() error = %v", err)
+			}
+			defer e.Close()
+
+			err = e.RenameHost(tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EtcdDatasource.RenameHost() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			want := tt.want
+			if tt.wantErr {
+				want = initial
+			}
+
+			if got := store.keys(); !reflect.DeepEqual(got, want) {
+				t.Errorf("EtcdDatasource.RenameHost() keys = %v, want %v", got, want)
+			}
+		})
+	}
+}
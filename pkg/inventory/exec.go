@@ -0,0 +1,324 @@
+package inventory
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// External process datasource type.
+	ExecDatasourceType string = "exec"
+	// Version of the external datasource protocol.
+	execProtocolVersion int = 1
+	// Time a datasource plugin is given to exit after its stdin is closed.
+	execStopTimeout time.Duration = 5 * time.Second
+
+	// External datasource protocol methods.
+	execHandshakeMethod      string = "handshake"
+	execGetAllRecordsMethod  string = "get_all_records"
+	execGetHostRecordsMethod string = "get_host_records"
+	execPublishRecordsMethod string = "publish_records"
+)
+
+type (
+	// ExecDatasource implements a datasource backed by an external process (a datasource plugin) speaking a line-delimited JSON protocol over stdio.
+	// Every request is written to the plugin's stdin as a single line: {"id": 1, "method": "get_all_records", "params": {...}},
+	// and is answered with a single line on its stdout: {"id": 1, "result": ..., "error": "..."}.
+	// The plugin is started on creation and restarted after failures, it is expected to exit when its stdin is closed. Its stderr is logged at the debug level.
+	ExecDatasource struct {
+		// Inventory configuration.
+		Config *Config
+		// Inventory logger.
+		Logger Logger
+
+		// Guards the plugin process: requests are served one at a time.
+		mu sync.Mutex
+		// Running plugin process, nil until started or after a failure.
+		proc *execProcess
+		// Last request ID.
+		id uint64
+	}
+
+	// execProcess represents a running datasource plugin.
+	execProcess struct {
+		// Plugin command.
+		cmd *exec.Cmd
+		// Plugin stdin.
+		stdin io.WriteCloser
+		// Plugin stdout decoder.
+		stdout *json.Decoder
+	}
+
+	// execRequest represents a request sent to a datasource plugin.
+	execRequest struct {
+		ID     uint64      `json:"id"`
+		Method string      `json:"method"`
+		Params interface{} `json:"params,omitempty"`
+	}
+
+	// execResponse represents a response of a datasource plugin.
+	execResponse struct {
+		ID     uint64          `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  string          `json:"error"`
+	}
+
+	// execHandshake represents the parameters and the result of the handshake request.
+	execHandshake struct {
+		// Protocol version.
+		Protocol int `json:"protocol"`
+		// Plugin-specific settings, only sent to the plugin.
+		Settings map[string]interface{} `json:"settings,omitempty"`
+	}
+
+	// execPluginError represents an error reported by a datasource plugin. The plugin keeps running after such errors.
+	execPluginError struct {
+		// Error message.
+		message string
+	}
+
+	// execLogWriter logs every line written by a datasource plugin to its stderr.
+	execLogWriter struct {
+		// Inventory logger.
+		log Logger
+		// Incomplete line.
+		buf []byte
+	}
+)
+
+// Error implements the error interface.
+func (err *execPluginError) Error() string {
+	return err.message
+}
+
+// Write implements io.Writer.
+func (w *execLogWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+
+	for {
+		n := bytes.IndexByte(w.buf, '\n')
+		if n < 0 {
+			return len(p), nil
+		}
+
+		if line := bytes.TrimSpace(w.buf[:n]); len(line) > 0 {
+			w.log.Debugf("[exec] %s", line)
+		}
+		w.buf = w.buf[n+1:]
+	}
+}
+
+// start starts the datasource plugin and performs the handshake.
+func (e *ExecDatasource) start() error {
+	cfg := e.Config
+
+	cmd := exec.Command(cfg.Exec.Command, cfg.Exec.Args...)
+	cmd.Env = append(os.Environ(), cfg.Exec.Env...)
+	cmd.Stderr = &execLogWriter{log: e.Logger}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return errors.Wrap(err, "plugin start failure")
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return errors.Wrap(err, "plugin start failure")
+	}
+
+	if err := cmd.Start(); err != nil {
+		return errors.Wrap(err, "plugin start failure")
+	}
+
+	proc := &execProcess{cmd: cmd, stdin: stdin, stdout: json.NewDecoder(bufio.NewReader(stdout))}
+
+	params := &execHandshake{Protocol: execProtocolVersion, Settings: cfg.Exec.Settings}
+	result := &execHandshake{}
+	if err := e.roundtrip(proc, execHandshakeMethod, params, result); err != nil {
+		e.stop(proc, true)
+		return errors.Wrap(err, "plugin handshake failure")
+	}
+
+	if result.Protocol != execProtocolVersion {
+		e.stop(proc, false)
+		return errors.Errorf("plugin handshake failure: unsupported protocol version %d, want %d", result.Protocol, execProtocolVersion)
+	}
+
+	e.proc = proc
+
+	return nil
+}
+
+// stop closes the stdin of a datasource plugin and waits for it to exit. The plugin is killed immediately or if it does not exit in time.
+func (e *ExecDatasource) stop(proc *execProcess, kill bool) {
+	proc.stdin.Close()
+
+	if kill {
+		proc.cmd.Process.Kill()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		proc.cmd.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(execStopTimeout):
+		proc.cmd.Process.Kill()
+		<-done
+	}
+}
+
+// roundtrip sends a request to a datasource plugin and decodes the result of the response into 'result'.
+// Requests that are not answered within the configured timeout fail.
+func (e *ExecDatasource) roundtrip(proc *execProcess, method string, params interface{}, result interface{}) error {
+	cfg := e.Config
+
+	e.id++
+	req := &execRequest{ID: e.id, Method: method, Params: params}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return errors.Wrap(err, "plugin request encoding failure")
+	}
+
+	type reply struct {
+		resp *execResponse
+		err  error
+	}
+
+	// A hung plugin may block both writes and reads, so the exchange is abandoned on timeout and the plugin is killed by the caller.
+	ch := make(chan reply, 1)
+	go func() {
+		if _, err := proc.stdin.Write(append(data, '\n')); err != nil {
+			ch <- reply{err: errors.Wrap(err, "plugin request failure")}
+			return
+		}
+
+		resp := &execResponse{}
+		if err := proc.stdout.Decode(resp); err != nil {
+			ch <- reply{err: errors.Wrap(err, "plugin response parsing failure")}
+			return
+		}
+
+		ch <- reply{resp: resp}
+	}()
+
+	var timeout <-chan time.Time
+	if cfg.Exec.Timeout > 0 {
+		timer := time.NewTimer(cfg.Exec.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var r reply
+	select {
+	case r = <-ch:
+	case <-timeout:
+		return errors.Errorf("plugin request failure: %s: no response in %s", method, cfg.Exec.Timeout)
+	}
+
+	if r.err != nil {
+		return r.err
+	}
+
+	if r.resp.ID != req.ID {
+		return errors.Errorf("plugin response failure: response ID %d, want %d", r.resp.ID, req.ID)
+	}
+
+	if len(r.resp.Error) > 0 {
+		return &execPluginError{message: r.resp.Error}
+	}
+
+	if result != nil && len(r.resp.Result) > 0 {
+		if err := json.Unmarshal(r.resp.Result, result); err != nil {
+			return errors.Wrap(err, "plugin response parsing failure")
+		}
+	}
+
+	return nil
+}
+
+// call sends a request to the datasource plugin, starting it if needed. The plugin is stopped after transport failures and timeouts.
+func (e *ExecDatasource) call(method string, params interface{}, result interface{}) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.proc == nil {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	err := e.roundtrip(e.proc, method, params, result)
+	if _, ok := err.(*execPluginError); err != nil && !ok {
+		e.stop(e.proc, true)
+		e.proc = nil
+	}
+
+	return err
+}
+
+// GetAllRecords acquires all available host records.
+func (e *ExecDatasource) GetAllRecords() ([]*DatasourceRecord, error) {
+	records := make([]*DatasourceRecord, 0)
+	if err := e.call(execGetAllRecordsMethod, nil, &records); err != nil {
+		return nil, errors.Wrap(err, "exec datasource failure")
+	}
+
+	return records, nil
+}
+
+// GetHostRecords requests the records of a specific host from the datasource plugin with a 'get_host_records' call.
+func (e *ExecDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error) {
+	records := make([]*DatasourceRecord, 0)
+	if err := e.call(execGetHostRecordsMethod, map[string]string{"host": host}, &records); err != nil {
+		return nil, errors.Wrapf(err, "%s: exec datasource failure", host)
+	}
+
+	return records, nil
+}
+
+// PublishRecords writes host records to the datasource.
+func (e *ExecDatasource) PublishRecords(records []*DatasourceRecord) error {
+	if err := e.call(execPublishRecordsMethod, map[string][]*DatasourceRecord{"records": records}, nil); err != nil {
+		return errors.Wrap(err, "exec datasource failure")
+	}
+
+	return nil
+}
+
+// Close stops the datasource plugin.
+func (e *ExecDatasource) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.proc != nil {
+		e.stop(e.proc, false)
+		e.proc = nil
+	}
+}
+
+// NewExecDatasource creates an external process datasource and starts the datasource plugin.
+func NewExecDatasource(cfg *Config, log Logger) (*ExecDatasource, error) {
+	if len(cfg.Exec.Command) == 0 {
+		return nil, errors.New("exec datasource initialization failure: no plugin command configured")
+	}
+
+	e := &ExecDatasource{Config: cfg, Logger: log}
+	if err := e.start(); err != nil {
+		return nil, errors.Wrap(err, "exec datasource initialization failure")
+	}
+
+	return e, nil
+}
@@ -0,0 +1,161 @@
+package inventory
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestExecDatasourcePlugin is not a test: it implements a datasource plugin in the test binary, started by TestExecDatasource.
+// The plugin keeps records in memory. The 'protocol' setting overrides the protocol version, the 'hang' setting makes get_all_records hang.
+func TestExecDatasourcePlugin(t *testing.T) {
+	if os.Getenv("ADI_TEST_EXEC_PLUGIN") != "1" {
+		return
+	}
+
+	records := []*DatasourceRecord{{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat"}}
+	settings := make(map[string]interface{})
+
+	out := json.NewEncoder(os.Stdout)
+	in := bufio.NewScanner(os.Stdin)
+	for in.Scan() {
+		var req struct {
+			ID     uint64          `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		json.Unmarshal(in.Bytes(), &req)
+
+		resp := map[string]interface{}{"id": req.ID}
+		switch req.Method {
+		case execHandshakeMethod:
+			var params execHandshake
+			json.Unmarshal(req.Params, &params)
+			settings = params.Settings
+
+			protocol := params.Protocol
+			if v, ok := settings["protocol"].(float64); ok {
+				protocol = int(v)
+			}
+			resp["result"] = map[string]int{"protocol": protocol}
+			fmt.Fprintln(os.Stderr, "plugin started")
+		case execGetAllRecordsMethod:
+			if settings["hang"] == true {
+				select {}
+			}
+			resp["result"] = records
+		case execGetHostRecordsMethod:
+			var params struct{ Host string }
+			json.Unmarshal(req.Params, &params)
+
+			found := make([]*DatasourceRecord, 0)
+			for _, r := range records {
+				if r.Hostname == params.Host {
+					found = append(found, r)
+				}
+			}
+			if len(found) == 0 {
+				resp["error"] = "no such host: " + params.Host
+			} else {
+				resp["result"] = found
+			}
+		case execPublishRecordsMethod:
+			var params struct{ Records []*DatasourceRecord }
+			json.Unmarshal(req.Params, &params)
+			records = params.Records
+		default:
+			resp["error"] = "unknown method: " + req.Method
+		}
+
+		out.Encode(resp)
+	}
+
+	os.Exit(0)
+}
+
+// newTestExecConfig creates a configuration running the test binary as a datasource plugin.
+func newTestExecConfig(settings map[string]interface{}) *Config {
+	cfg := &Config{}
+	cfg.Exec.Command = os.Args[0]
+	cfg.Exec.Args = []string{"-test.run=^TestExecDatasourcePlugin$"}
+	cfg.Exec.Env = []string{"ADI_TEST_EXEC_PLUGIN=1"}
+	cfg.Exec.Settings = settings
+	cfg.Exec.Timeout = 5 * time.Second
+
+	return cfg
+}
+
+func TestExecDatasource(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		e, err := NewExecDatasource(newTestExecConfig(nil), &testLogger{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer e.Close()
+
+		records, err := e.GetAllRecords()
+		if err != nil || len(records) != 1 || records[0].Hostname != "app01.infra.local" {
+			t.Fatalf("ExecDatasource.GetAllRecords() = %v, %v", records, err)
+		}
+
+		published := []*DatasourceRecord{
+			{Hostname: "db01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=db;SRV=postgres"},
+			{Hostname: "db01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=backup;SRV="},
+		}
+		if err := e.PublishRecords(published); err != nil {
+			t.Fatalf("ExecDatasource.PublishRecords() error = %v", err)
+		}
+
+		records, err = e.GetHostRecords("db01.infra.local")
+		if err != nil || len(records) != 2 || records[1].Attributes != published[1].Attributes {
+			t.Fatalf("ExecDatasource.GetHostRecords() = %v, %v", records, err)
+		}
+
+		// Plugin errors do not restart the plugin, so the published records are kept.
+		if _, err := e.GetHostRecords("app01.infra.local"); err == nil {
+			t.Fatal("ExecDatasource.GetHostRecords() error = nil, want an error")
+		}
+
+		if records, err := e.GetAllRecords(); err != nil || len(records) != 2 {
+			t.Fatalf("ExecDatasource.GetAllRecords() = %v, %v", records, err)
+		}
+	})
+
+	t.Run("valid-restart", func(t *testing.T) {
+		cfg := newTestExecConfig(map[string]interface{}{"hang": true})
+		cfg.Exec.Timeout = 500 * time.Millisecond
+
+		e, err := NewExecDatasource(cfg, &testLogger{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer e.Close()
+
+		if _, err := e.GetAllRecords(); err == nil {
+			t.Fatal("ExecDatasource.GetAllRecords() error = nil, want a timeout")
+		}
+
+		// The hung plugin has been killed, a new one is started.
+		if records, err := e.GetHostRecords("app01.infra.local"); err != nil || len(records) != 1 {
+			t.Fatalf("ExecDatasource.GetHostRecords() = %v, %v", records, err)
+		}
+	})
+
+	t.Run("invalid-protocol", func(t *testing.T) {
+		if _, err := NewExecDatasource(newTestExecConfig(map[string]interface{}{"protocol": 2}), &testLogger{}); err == nil {
+			t.Fatal("NewExecDatasource() error = nil, want a protocol version error")
+		}
+	})
+
+	t.Run("invalid-command", func(t *testing.T) {
+		cfg := newTestExecConfig(nil)
+		cfg.Exec.Command = "/nonexistent/plugin"
+
+		if _, err := NewExecDatasource(cfg, &testLogger{}); err == nil {
+			t.Fatal("NewExecDatasource() error = nil, want a start error")
+		}
+	})
+}
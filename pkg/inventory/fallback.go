@@ -0,0 +1,131 @@
+package inventory
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Fallback name reported when host records are acquired from the host record cache.
+const fallbackCache string = "cache"
+
+// isNoHostRecords reports whether a datasource error means that a host has no records rather than a datasource failure.
+func isNoHostRecords(err error) bool {
+	return errors.Is(err, ErrNoHostRecords)
+}
+
+// fallbackDatasource returns a fallback datasource, creating it on first use with a copy of the inventory configuration selecting its types.
+// Fault injection only applies to the primary datasource.
+func (i *Inventory) fallbackDatasource(name string) (Datasource, error) {
+	i.fallbackLock.Lock()
+	defer i.fallbackLock.Unlock()
+
+	if ds, ok := i.fallbacks[name]; ok {
+		return ds, nil
+	}
+
+	cfg := *i.Config
+	cfg.Datasource = name
+	cfg.Chaos.Enabled = false
+
+	ds, err := NewDatasource(&cfg, i.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if i.fallbacks == nil {
+		i.fallbacks = make(map[string]Datasource)
+	}
+	i.fallbacks[name] = ds
+
+	return ds, nil
+}
+
+// fallbackRecords acquires all host records from the fallback datasources, in order, after the datasource has failed with the 'failure' error.
+// If every fallback datasource fails, the host record cache of any age is used, if enabled.
+// It returns the records, the datasource that provided them (nil for the host record cache) and the name of the fallback,
+// or the original datasource error.
+func (i *Inventory) fallbackRecords(failure error) ([]*DatasourceRecord, Datasource, string, error) {
+	cfg := i.Config
+	log := i.Logger
+
+	for _, name := range cfg.Fallback.Datasources {
+		ds, err := i.fallbackDatasource(name)
+		if err != nil {
+			log.Warnf("fallback datasource initialization failure: %s: %v", name, err)
+			continue
+		}
+
+		records, err := ds.GetAllRecords()
+		if err != nil {
+			log.Warnf("fallback datasource failure: %s: %v", name, err)
+			continue
+		}
+
+		log.Warnf("datasource failure, using fallback datasource %s: %v", name, failure)
+		return records, ds, name, nil
+	}
+
+	if !cfg.Fallback.Cache || len(cfg.Host.Cache.Path) == 0 {
+		return nil, nil, "", failure
+	}
+
+	cache, err := i.loadHostCache()
+	if err != nil {
+		log.Warnf("host record cache is unavailable: %v", err)
+		return nil, nil, "", failure
+	}
+
+	hosts := make([]string, 0, len(cache.Records))
+	for host := range cache.Records {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	records := make([]*DatasourceRecord, 0)
+	for _, host := range hosts {
+		records = append(records, cache.Records[host]...)
+	}
+
+	log.Warnf("datasource failure, using host record cache from %s: %v", cache.Timestamp, failure)
+	return records, nil, fallbackCache, nil
+}
+
+// fallbackHostRecords acquires the records of a host from the fallback datasources, in order, after the datasource has failed with the 'failure' error.
+// It returns false if no fallback datasource has records for the host.
+func (i *Inventory) fallbackHostRecords(host string, failure error) ([]*DatasourceRecord, bool) {
+	log := i.Logger
+
+	for _, name := range i.Config.Fallback.Datasources {
+		ds, err := i.fallbackDatasource(name)
+		if err != nil {
+			log.Warnf("[%s] fallback datasource initialization failure: %s: %v", host, name, err)
+			continue
+		}
+
+		records, err := i.queryHostRecords(ds, host)
+		if err != nil {
+			if !isNoHostRecords(err) {
+				log.Warnf("[%s] fallback datasource failure: %s: %v", host, name, err)
+			}
+			continue
+		}
+
+		log.Warnf("[%s] datasource failure, using fallback datasource %s: %v", host, name, failure)
+		return records, true
+	}
+
+	return nil, false
+}
+
+// closeFallbacks closes the clients of every fallback datasource created so far.
+func (i *Inventory) closeFallbacks() {
+	i.fallbackLock.Lock()
+	defer i.fallbackLock.Unlock()
+
+	for _, ds := range i.fallbacks {
+		ds.Close()
+	}
+
+	i.fallbacks = nil
+}
@@ -0,0 +1,185 @@
+package inventory
+
+import (
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestInventory_GetHosts_fallback(t *testing.T) {
+	primary := []*DatasourceRecord{{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=app;SRV=;VARS="}}
+	replica := []*DatasourceRecord{{Hostname: "app02.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=app;SRV=;VARS="}}
+	cached := []*DatasourceRecord{{Hostname: "app03.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=app;SRV=;VARS="}}
+
+	tests := []struct {
+		name         string
+		primary      *testDatasource
+		datasources  []string
+		cache        bool
+		written      bool
+		wantHosts    []string
+		wantFallback string
+		wantErr      bool
+	}{
+		{
+			name:        "valid-primary",
+			primary:     &testDatasource{records: primary},
+			datasources: []string{"dns", "etcd"},
+			wantHosts:   []string{"app01.infra.local"},
+		},
+		{
+			// The first fallback datasource fails as well, the second one is used.
+			name:         "valid-fallback",
+			primary:      &testDatasource{err: errors.New("connection refused")},
+			datasources:  []string{"dns", "etcd"},
+			wantHosts:    []string{"app02.infra.local"},
+			wantFallback: "etcd",
+		},
+		{
+			name:         "valid-cache",
+			primary:      &testDatasource{err: errors.New("connection refused")},
+			cache:        true,
+			written:      true,
+			wantHosts:    []string{"app03.infra.local"},
+			wantFallback: fallbackCache,
+		},
+		{
+			// Neither the fallback datasource nor the host record cache are available.
+			name:        "invalid-failure",
+			primary:     &testDatasource{err: errors.New("connection refused")},
+			datasources: []string{"dns"},
+			cache:       true,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newTestInventory(t, false, nil)
+			i.Datasource = tt.primary
+			i.Config.Host.Cache.Path = filepath.Join(t.TempDir(), "cache.json")
+			i.Config.Fallback.Cache = tt.cache
+
+			if tt.written {
+				if err := i.writeHostCache(cached); err != nil {
+					t.Fatalf("Inventory.writeHostCache() error = %v", err)
+				}
+			}
+
+			i.Config.Fallback.Datasources = tt.datasources
+			i.fallbacks = map[string]Datasource{
+				"dns":  &testDatasource{err: errors.New("no route to host")},
+				"etcd": &testDatasource{records: replica},
+			}
+
+			hosts, err := i.GetHosts()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Inventory.GetHosts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			got := make([]string, 0)
+			for host := range hosts {
+				got = append(got, host)
+			}
+
+			if !reflect.DeepEqual(got, tt.wantHosts) {
+				t.Errorf("Inventory.GetHosts() = %v, want %v", got, tt.wantHosts)
+			}
+
+			if i.Metadata.Fallback != tt.wantFallback {
+				t.Errorf("Inventory.Metadata.Fallback = %v, want %v", i.Metadata.Fallback, tt.wantFallback)
+			}
+		})
+	}
+}
+
+func TestInventory_GetHosts_fallback_unreachable(t *testing.T) {
+	// A DNS server address nothing listens on.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := l.Addr().String()
+	l.Close()
+
+	replica := []*DatasourceRecord{{Hostname: "app02.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=app;SRV=;VARS="}}
+
+	i := newTestInventory(t, false, nil)
+	i.Config.DNS.Server = unreachable
+	i.Config.DNS.Timeout = time.Second
+	i.Config.DNS.Zones = []string{"infra.local.", "dev.local."}
+
+	ds, err := NewDNSDatasource(i.Config, i.Logger)
+	if err != nil {
+		t.Fatalf("NewDNSDatasource() error = %v", err)
+	}
+	defer ds.Close()
+
+	i.Datasource = ds
+	i.Config.Fallback.Datasources = []string{"etcd"}
+	i.fallbacks = map[string]Datasource{"etcd": &testDatasource{records: replica}}
+
+	hosts, err := i.GetHosts()
+	if err != nil {
+		t.Fatalf("Inventory.GetHosts() error = %v", err)
+	}
+
+	if _, ok := hosts["app02.infra.local"]; !ok || len(hosts) != 1 {
+		t.Errorf("Inventory.GetHosts() = %v, want [app02.infra.local]", hosts)
+	}
+
+	if i.Metadata.Fallback != "etcd" {
+		t.Errorf("Inventory.Metadata.Fallback = %v, want %v", i.Metadata.Fallback, "etcd")
+	}
+}
+
+func TestInventory_lookupHostRecords_fallback(t *testing.T) {
+	replica := []*DatasourceRecord{{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=app;SRV=;VARS=replica=1"}}
+
+	tests := []struct {
+		name    string
+		primary *testDatasource
+		want    []*DatasourceRecord
+		wantErr bool
+	}{
+		{
+			name:    "valid-fallback",
+			primary: &testDatasource{err: errors.New("connection refused")},
+			want:    replica,
+		},
+		{
+			// Hosts missing from the datasource are not looked up in the fallback datasources.
+			name:    "invalid-not-found",
+			primary: &testDatasource{err: errors.Wrap(ErrNoHostRecords, "app01.infra.local")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.Fallback.Datasources = []string{"dns", "etcd"}
+
+			i := &Inventory{Config: cfg, Logger: &testLogger{}, Datasource: tt.primary}
+			i.fallbacks = map[string]Datasource{
+				"dns":  &testDatasource{err: errors.New("no route to host")},
+				"etcd": &testDatasource{records: replica},
+			}
+
+			got, err := i.lookupHostRecords("app01.infra.local")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Inventory.lookupHostRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inventory.lookupHostRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
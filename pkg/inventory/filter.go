@@ -0,0 +1,182 @@
+package inventory
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Separator between terms of a filter expression.
+	filterExprTermSeparator string = ","
+	// Separator between values of a filter expression term.
+	filterExprValueSeparator string = "|"
+)
+
+// filterExprOperators maps filter expression operators to host filter operators. Longer operators must come first.
+var filterExprOperators = [][]string{
+	{"!=", "notin"},
+	{"!~", "notregex"},
+	{"=", "in"},
+	{"~", "regex"},
+}
+
+// filterHost evaluates host record filters specified in the configuration and determines if a record should be processed by the inventory.
+func (i *Inventory) filterHost(host string, attrs *HostAttributes) (bool, error) {
+	cfg := i.Config
+
+	if !cfg.Filter.Enabled {
+		return true, nil
+	}
+
+	return matchFilters(host, attrs, cfg.Filter.Filters)
+}
+
+// matchFilters determines if a host record matches all of the filters.
+func matchFilters(host string, attrs *HostAttributes, filters []HostFilter) (bool, error) {
+	for _, filter := range filters {
+		var value string
+
+		switch filter.Key {
+		case "host":
+			value = host
+		case adiHostAttributeNames["OS"]:
+			value = attrs.OS
+		case adiHostAttributeNames["ENV"]:
+			value = attrs.Env
+		case adiHostAttributeNames["ROLE"]:
+			value = attrs.Role
+		case adiHostAttributeNames["SRV"]:
+			value = attrs.Srv
+		case adiHostAttributeNames["ID"]:
+			value = attrs.ID
+		default:
+			return false, errors.Errorf("unknown key: %s", filter.Key)
+		}
+
+		switch strings.ToLower(filter.Operator) {
+		case "in":
+			if slices.Contains(filter.Values, value) {
+				continue
+			} else {
+				return false, nil
+			}
+		case "notin":
+			if !slices.Contains(filter.Values, value) {
+				continue
+			} else {
+				return false, nil
+			}
+		case "regex":
+			var match bool
+
+			for _, exp := range filter.Values {
+				regex := regexp.MustCompile(exp)
+				if regex.MatchString(value) {
+					match = true
+					break
+				}
+			}
+
+			if match {
+				continue
+			} else {
+				return false, nil
+			}
+		case "notregex":
+			var match bool
+
+			for _, exp := range filter.Values {
+				regex := regexp.MustCompile(exp)
+				if regex.MatchString(value) {
+					match = true
+					break
+				}
+			}
+
+			if !match {
+				continue
+			} else {
+				return false, nil
+			}
+		default:
+			return false, errors.Errorf("unknown operator: %s", filter.Operator)
+		}
+	}
+
+	return true, nil
+}
+
+// ParseFilters parses a filter expression (e.g. 'env=prod,role=db|app,host~^app') into a list of host filters.
+// Terms are separated by commas and all of them must match. Supported operators: '=' (in), '!=' (notin), '~' (regex) and '!~' (notregex).
+// Several values can be specified for a single term by separating them with '|'.
+// Keys are matched case-insensitively against 'host' and the configured host attribute keys, except for the host variables key.
+func (i *Inventory) ParseFilters(expr string) ([]HostFilter, error) {
+	cfg := i.Config
+	filters := make([]HostFilter, 0)
+	keys := []string{"host", cfg.Txt.Keys.Os, cfg.Txt.Keys.Env, cfg.Txt.Keys.Role, cfg.Txt.Keys.Srv, cfg.Txt.Keys.ID}
+
+	for _, term := range strings.Split(expr, filterExprTermSeparator) {
+		term = strings.TrimSpace(term)
+		if len(term) == 0 {
+			continue
+		}
+
+		// Find the first operator in the term.
+		pos := strings.IndexAny(term, "!=~")
+		if pos <= 0 {
+			return nil, errors.Errorf("invalid filter expression term: %s", term)
+		}
+
+		var filter *HostFilter
+		for _, op := range filterExprOperators {
+			if strings.HasPrefix(term[pos:], op[0]) {
+				filter = &HostFilter{
+					Key:      term[:pos],
+					Operator: op[1],
+					Values:   strings.Split(term[pos+len(op[0]):], filterExprValueSeparator),
+				}
+				break
+			}
+		}
+
+		if filter == nil {
+			return nil, errors.Errorf("invalid filter expression operator: %s", term)
+		}
+
+		// Match the key with the configured attribute keys.
+		idx := slices.IndexFunc(keys, func(k string) bool { return strings.EqualFold(k, strings.TrimSpace(filter.Key)) })
+		if idx < 0 {
+			return nil, errors.Errorf("unknown key: %s", filter.Key)
+		}
+		filter.Key = keys[idx]
+
+		// Validate regular expressions early.
+		if filter.Operator == "regex" || filter.Operator == "notregex" {
+			for _, exp := range filter.Values {
+				if _, err := regexp.Compile(exp); err != nil {
+					return nil, errors.Wrapf(err, "invalid regular expression: %s", exp)
+				}
+			}
+		}
+
+		filters = append(filters, *filter)
+	}
+
+	return filters, nil
+}
+
+// FilterSet returns the filters of a named filter set defined in the configuration.
+func (i *Inventory) FilterSet(name string) ([]HostFilter, error) {
+	cfg := i.Config
+
+	for setName, filters := range cfg.Filter.Sets {
+		if strings.EqualFold(setName, name) {
+			return filters, nil
+		}
+	}
+
+	return nil, errors.Errorf("unknown filter set: %s", name)
+}
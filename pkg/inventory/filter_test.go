@@ -0,0 +1,105 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInventory_ParseFilters(t *testing.T) {
+	cfg := &Config{}
+	cfg.Txt.Keys.Os = "OS"
+	cfg.Txt.Keys.Env = "ENV"
+	cfg.Txt.Keys.Role = "ROLE"
+	cfg.Txt.Keys.Srv = "SRV"
+	cfg.Txt.Keys.Vars = "VARS"
+
+	testInventory := &Inventory{
+		Config: cfg,
+	}
+
+	type args struct {
+		expr string
+	}
+	tests := []struct {
+		name    string
+		i       *Inventory
+		args    args
+		want    []HostFilter
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			i:    testInventory,
+			args: args{
+				expr: "env=prod,role=db",
+			},
+			want: []HostFilter{
+				{Key: "ENV", Operator: "in", Values: []string{"prod"}},
+				{Key: "ROLE", Operator: "in", Values: []string{"db"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid-operators",
+			i:    testInventory,
+			args: args{
+				expr: "host~^app,OS!=windows|bsd,srv!~^tomcat, role=app|db",
+			},
+			want: []HostFilter{
+				{Key: "host", Operator: "regex", Values: []string{"^app"}},
+				{Key: "OS", Operator: "notin", Values: []string{"windows", "bsd"}},
+				{Key: "SRV", Operator: "notregex", Values: []string{"^tomcat"}},
+				{Key: "ROLE", Operator: "in", Values: []string{"app", "db"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid-empty",
+			i:    testInventory,
+			args: args{
+				expr: "",
+			},
+			want:    []HostFilter{},
+			wantErr: false,
+		},
+		{
+			name: "invalid-key",
+			i:    testInventory,
+			args: args{
+				expr: "vars=test",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid-no-operator",
+			i:    testInventory,
+			args: args{
+				expr: "env",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid-regex",
+			i:    testInventory,
+			args: args{
+				expr: "host~[",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.i.ParseFilters(tt.args.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Inventory.ParseFilters() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inventory.ParseFilters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
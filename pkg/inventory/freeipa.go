@@ -0,0 +1,308 @@
+package inventory
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// FreeIPA datasource type.
+	FreeIPADatasourceType string = "freeipa"
+)
+
+type (
+	// FreeIPADatasource implements a read-only datasource backed by the host objects of a FreeIPA domain, read through the IPA JSON-RPC API.
+	// The datasource logs in with a password and keeps the session cookie, logging in again when the session expires.
+	FreeIPADatasource struct {
+		// Inventory configuration.
+		Config *Config
+		// Inventory logger.
+		Logger Logger
+		// HTTP client keeping the session cookie.
+		Client *http.Client
+
+		// Guards logins.
+		mu sync.Mutex
+		// IPA server URL without a trailing slash.
+		server string
+	}
+
+	// freeIPAResponse represents an IPA JSON-RPC response.
+	freeIPAResponse struct {
+		Result *struct {
+			Result    []map[string]interface{} `json:"result"`
+			Truncated bool                     `json:"truncated"`
+		} `json:"result"`
+		Error *struct {
+			Code    int    `json:"code"`
+			Name    string `json:"name"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+)
+
+// login starts an IPA session with the configured credentials.
+func (f *FreeIPADatasource) login() error {
+	cfg := f.Config
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	form := url.Values{"user": {cfg.FreeIPA.Username}, "password": {cfg.FreeIPA.Password}}
+	req, err := http.NewRequest(http.MethodPost, f.server+"/ipa/session/login_password", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "text/plain")
+	req.Header.Set("Referer", f.server+"/ipa")
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "ipa login failure")
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// The reason is only given for rejected credentials, e.g. 'invalid-password' or 'password-expired'.
+		if reason := resp.Header.Get("X-IPA-Rejection-Reason"); len(reason) > 0 {
+			return errors.Errorf("ipa login failure: %s: %s", resp.Status, reason)
+		}
+
+		return errors.Errorf("ipa login failure: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// call calls an IPA API method, logging in if there is no session or the session has expired.
+func (f *FreeIPADatasource) call(method string, args []interface{}, options map[string]interface{}) (*freeIPAResponse, error) {
+	body, err := json.Marshal(map[string]interface{}{"method": method, "params": []interface{}{args, options}, "id": 0})
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(http.MethodPost, f.server+"/ipa/session/json", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Referer", f.server+"/ipa")
+
+		resp, err := f.Client.Do(req)
+		if err != nil {
+			return nil, errors.Wrap(err, "ipa request failure")
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			resp.Body.Close()
+
+			if err := f.login(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		result := &freeIPAResponse{}
+		err = json.NewDecoder(resp.Body).Decode(result)
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode != http.StatusOK:
+			return nil, errors.Errorf("ipa request failure: %s", resp.Status)
+		case err != nil:
+			return nil, errors.Wrap(err, "ipa response parsing failure")
+		case result.Error != nil:
+			return nil, errors.Errorf("ipa %s failure: %s (%d): %s", method, result.Error.Name, result.Error.Code, result.Error.Message)
+		case result.Result == nil:
+			return nil, errors.Errorf("ipa %s failure: no result returned", method)
+		}
+
+		return result, nil
+	}
+}
+
+// freeIPAValue returns the value of a host object attribute as a string. Values of multi-valued attributes are joined with commas.
+func freeIPAValue(object map[string]interface{}, attribute string) string {
+	value := object[strings.ToLower(attribute)]
+
+	list, ok := value.([]interface{})
+	if !ok {
+		list = []interface{}{value}
+	}
+
+	values := make([]string, 0, len(list))
+	for _, v := range list {
+		switch v := v.(type) {
+		case string:
+			values = append(values, v)
+		case bool, float64:
+			values = append(values, fmt.Sprint(v))
+		}
+	}
+
+	return strings.Join(values, ",")
+}
+
+// find searches for host objects and converts them into host records. Host objects that cannot be converted are skipped.
+func (f *FreeIPADatasource) find(options map[string]interface{}) ([]*DatasourceRecord, error) {
+	cfg := f.Config
+	log := f.Logger
+
+	options["all"] = true
+	options["sizelimit"] = 0
+	if len(cfg.FreeIPA.HostGroups) > 0 {
+		options["in_hostgroup"] = cfg.FreeIPA.HostGroups
+	}
+
+	args := []interface{}{}
+	if len(cfg.FreeIPA.Criteria) > 0 {
+		args = append(args, cfg.FreeIPA.Criteria)
+	}
+
+	result, err := f.call("host_find", args, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Result.Truncated {
+		log.Warnf("ipa host search results have been truncated by the server search limits")
+	}
+
+	objects := result.Result.Result
+	sort.SliceStable(objects, func(i, j int) bool {
+		return freeIPAValue(objects[i], "fqdn") < freeIPAValue(objects[j], "fqdn")
+	})
+
+	records := make([]*DatasourceRecord, 0, len(objects))
+	for _, object := range objects {
+		source := freeIPAValue(object, "dn")
+		if len(source) == 0 {
+			source = "freeipa/" + freeIPAValue(object, "fqdn")
+		}
+
+		host := strings.ToLower(strings.TrimSuffix(strings.Split(freeIPAValue(object, cfg.FreeIPA.Hostname), ",")[0], "."))
+		if len(host) == 0 {
+			log.Warnf("skipping ipa host without a hostname: %s", source)
+			continue
+		}
+
+		attrs, err := mappedAttributes(cfg, func(key string) string {
+			if attribute := lookupFold(cfg.FreeIPA.Attributes, key); len(attribute) > 0 {
+				return freeIPAValue(object, attribute)
+			}
+
+			return ""
+		}, cfg.FreeIPA.Defaults)
+		if err != nil {
+			log.Warnf("skipping ipa host: %s: %v", source, err)
+			continue
+		}
+
+		records = append(records, &DatasourceRecord{Hostname: host, Attributes: attrs, Source: source})
+	}
+
+	return records, nil
+}
+
+// GetAllRecords acquires all available host records. Every host object describes a single host record.
+func (f *FreeIPADatasource) GetAllRecords() ([]*DatasourceRecord, error) {
+	records, err := f.find(make(map[string]interface{}))
+	if err != nil {
+		return nil, errors.Wrap(err, "freeipa datasource failure")
+	}
+
+	return records, nil
+}
+
+// GetHostRecords searches for the host objects of a specific host with a single 'host_find' call. An error is returned if there are none.
+// Only the host object with a matching FQDN is searched for unless hostnames are read from another attribute.
+func (f *FreeIPADatasource) GetHostRecords(host string) ([]*DatasourceRecord, error) {
+	cfg := f.Config
+
+	options := make(map[string]interface{})
+	if strings.EqualFold(cfg.FreeIPA.Hostname, "fqdn") {
+		options["fqdn"] = host
+	}
+
+	all, err := f.find(options)
+	if err != nil {
+		return nil, errors.Wrap(err, "freeipa datasource failure")
+	}
+
+	records := make([]*DatasourceRecord, 0)
+	for _, r := range all {
+		if strings.EqualFold(r.Hostname, host) {
+			records = append(records, r)
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, errors.Wrap(ErrNoHostRecords, host)
+	}
+
+	return records, nil
+}
+
+// PublishRecords is not supported: host objects are expected to be managed with the IPA tools.
+func (f *FreeIPADatasource) PublishRecords(records []*DatasourceRecord) error {
+	return errors.New("publishing records is not supported by the freeipa datasource")
+}
+
+// Close closes idle connections to the IPA server. The session is kept for later requests.
+func (f *FreeIPADatasource) Close() {
+	f.Client.CloseIdleConnections()
+}
+
+// NewFreeIPADatasource creates a FreeIPA datasource. The session is started with the first request.
+func NewFreeIPADatasource(cfg *Config, log Logger) (*FreeIPADatasource, error) {
+	u, err := url.Parse(cfg.FreeIPA.URL)
+	if err != nil || len(u.Host) == 0 {
+		return nil, errors.Errorf("freeipa datasource initialization failure: invalid URL: %s", cfg.FreeIPA.URL)
+	}
+
+	if len(cfg.FreeIPA.Username) == 0 {
+		return nil, errors.New("freeipa datasource initialization failure: username is not set")
+	}
+
+	if len(cfg.FreeIPA.Hostname) == 0 {
+		return nil, errors.New("freeipa datasource initialization failure: hostname attribute is not set")
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.FreeIPA.TLS.Insecure}
+	if len(cfg.FreeIPA.TLS.CA) > 0 {
+		pool, err := tlsCAPoolFromFile(cfg.FreeIPA.TLS.CA)
+		if err != nil {
+			return nil, errors.Wrap(err, "freeipa datasource initialization failure")
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "freeipa datasource initialization failure")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &FreeIPADatasource{
+		Config: cfg,
+		Logger: log,
+		Client: &http.Client{Transport: transport, Jar: jar, Timeout: cfg.FreeIPA.Timeout},
+		server: strings.TrimSuffix(u.String(), "/"),
+	}, nil
+}
@@ -0,0 +1,212 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testFreeIPA emulates the IPA session login and the host_find method of the JSON-RPC API.
+type testFreeIPA struct {
+	mu sync.Mutex
+	// Valid session ID, empty if sessions have expired.
+	session string
+	// Number of logins.
+	logins int
+}
+
+// serve starts the emulated IPA server accepting the 'inventory' user with the 'secret' password.
+func (s *testFreeIPA) serve(t *testing.T) string {
+	hosts := []map[string]interface{}{
+		{"dn": "fqdn=web01.infra.local,cn=computers,cn=accounts,dc=infra,dc=local", "fqdn": []interface{}{"web01.infra.local"}, "nshostlocation": []interface{}{"prod"}, "userclass": []interface{}{"web"}, "memberof_hostgroup": []interface{}{"linux"}},
+		{"dn": "fqdn=app01.infra.local,cn=computers,cn=accounts,dc=infra,dc=local", "fqdn": []interface{}{"App01.infra.local"}, "nshostlocation": []interface{}{"dev"}, "userclass": []interface{}{"app", "web"}, "memberof_hostgroup": []interface{}{"linux"}, "has_keytab": true},
+		{"dn": "fqdn=db01.infra.local,cn=computers,cn=accounts,dc=infra,dc=local", "fqdn": []interface{}{"db01.infra.local"}, "userclass": []interface{}{"db;app"}, "memberof_hostgroup": []interface{}{"linux"}},
+		{"dn": "fqdn=win01.infra.local,cn=computers,cn=accounts,dc=infra,dc=local", "fqdn": []interface{}{"win01.infra.local"}, "nshostlocation": []interface{}{"prod"}, "userclass": []interface{}{"ad"}},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if !strings.HasSuffix(r.Header.Get("Referer"), "/ipa") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/ipa/session/login_password":
+			r.ParseForm()
+			if r.PostForm.Get("user") != "inventory" || r.PostForm.Get("password") != "secret" {
+				w.Header().Set("X-IPA-Rejection-Reason", "invalid-password")
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			s.logins++
+			s.session = fmt.Sprintf("session%d", s.logins)
+			http.SetCookie(w, &http.Cookie{Name: "ipa_session", Value: "MagBearerToken=" + s.session, Path: "/ipa"})
+		case "/ipa/session/json":
+			cookie, err := r.Cookie("ipa_session")
+			if err != nil || len(s.session) == 0 || cookie.Value != "MagBearerToken="+s.session {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			var call struct {
+				Method string             `json:"method"`
+				Params [2]json.RawMessage `json:"params"`
+			}
+			var args []string
+			var options map[string]interface{}
+			if json.NewDecoder(r.Body).Decode(&call) != nil || call.Method != "host_find" || json.Unmarshal(call.Params[0], &args) != nil || json.Unmarshal(call.Params[1], &options) != nil {
+				json.NewEncoder(w).Encode(map[string]interface{}{"result": nil, "error": map[string]interface{}{"code": 909, "name": "CommandError", "message": "unknown command"}})
+				return
+			}
+
+			result := make([]map[string]interface{}, 0)
+			for _, host := range hosts {
+				fqdn := strings.ToLower(host["fqdn"].([]interface{})[0].(string))
+				if fqdn, ok := options["fqdn"]; ok && !strings.EqualFold(host["fqdn"].([]interface{})[0].(string), fqdn.(string)) {
+					continue
+				}
+				if groups, ok := options["in_hostgroup"]; ok && (host["memberof_hostgroup"] == nil || groups.([]interface{})[0] != host["memberof_hostgroup"].([]interface{})[0]) {
+					continue
+				}
+				if len(args) > 0 && !strings.Contains(fqdn, args[0]) {
+					continue
+				}
+
+				result = append(result, host)
+			}
+
+			json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]interface{}{"result": result, "count": len(result), "truncated": false}, "error": nil})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+// newTestFreeIPADatasource creates a FreeIPA datasource for the emulated IPA server mapping ENV to the host location and ROLE to the user class.
+func newTestFreeIPADatasource(t *testing.T, ipa *testFreeIPA, password string, groups []string) *FreeIPADatasource {
+	cfg := &Config{}
+	cfg.Txt.Keys.Os = "OS"
+	cfg.Txt.Keys.Env = "ENV"
+	cfg.Txt.Keys.Role = "ROLE"
+	cfg.Txt.Keys.Srv = "SRV"
+	cfg.Txt.Keys.Vars = "VARS"
+	cfg.Txt.Keys.ID = "ID"
+	cfg.Txt.Kv.Separator = ";"
+	cfg.Txt.Kv.Equalsign = "="
+	cfg.FreeIPA.URL = ipa.serve(t) + "/"
+	cfg.FreeIPA.Username = "inventory"
+	cfg.FreeIPA.Password = password
+	cfg.FreeIPA.HostGroups = groups
+	cfg.FreeIPA.Hostname = "fqdn"
+	cfg.FreeIPA.Attributes = map[string]string{"env": "nsHostLocation", "role": "userclass"}
+	cfg.FreeIPA.Defaults = map[string]string{"os": "linux", "env": "dev"}
+	cfg.FreeIPA.Timeout = 5 * time.Second
+
+	f, err := NewFreeIPADatasource(cfg, &testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+func TestFreeIPADatasource_GetAllRecords(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		groups   []string
+		want     []*DatasourceRecord
+		wantErr  bool
+	}{
+		{
+			// Host objects with values containing the attribute separator are skipped.
+			name:     "valid",
+			password: "secret",
+			want: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app,web;SRV=;VARS=", Source: "fqdn=app01.infra.local,cn=computers,cn=accounts,dc=infra,dc=local"},
+				{Hostname: "web01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=web;SRV=;VARS=", Source: "fqdn=web01.infra.local,cn=computers,cn=accounts,dc=infra,dc=local"},
+				{Hostname: "win01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=ad;SRV=;VARS=", Source: "fqdn=win01.infra.local,cn=computers,cn=accounts,dc=infra,dc=local"},
+			},
+		},
+		{
+			name:     "valid-hostgroups",
+			password: "secret",
+			groups:   []string{"linux"},
+			want: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app,web;SRV=;VARS=", Source: "fqdn=app01.infra.local,cn=computers,cn=accounts,dc=infra,dc=local"},
+				{Hostname: "web01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=web;SRV=;VARS=", Source: "fqdn=web01.infra.local,cn=computers,cn=accounts,dc=infra,dc=local"},
+			},
+		},
+		{
+			name:     "invalid-password",
+			password: "wrong",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestFreeIPADatasource(t, &testFreeIPA{}, tt.password, tt.groups)
+
+			got, err := f.GetAllRecords()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FreeIPADatasource.GetAllRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FreeIPADatasource.GetAllRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFreeIPADatasource_GetHostRecords(t *testing.T) {
+	ipa := &testFreeIPA{}
+	f := newTestFreeIPADatasource(t, ipa, "secret", nil)
+
+	want := []*DatasourceRecord{
+		{Hostname: "web01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=web;SRV=;VARS=", Source: "fqdn=web01.infra.local,cn=computers,cn=accounts,dc=infra,dc=local"},
+	}
+
+	got, err := f.GetHostRecords("web01.infra.local")
+	if err != nil {
+		t.Fatalf("FreeIPADatasource.GetHostRecords() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FreeIPADatasource.GetHostRecords() = %v, want %v", got, want)
+	}
+
+	// The session is reused, and a new one is started once it has expired.
+	if _, err := f.GetHostRecords("web01.infra.local"); err != nil {
+		t.Fatalf("FreeIPADatasource.GetHostRecords() error = %v", err)
+	}
+
+	ipa.mu.Lock()
+	ipa.session = ""
+	ipa.mu.Unlock()
+
+	if _, err := f.GetHostRecords("web01.infra.local"); err != nil {
+		t.Fatalf("FreeIPADatasource.GetHostRecords() error = %v", err)
+	}
+
+	if ipa.logins != 2 {
+		t.Errorf("FreeIPADatasource.GetHostRecords() logins = %d, want 2", ipa.logins)
+	}
+
+	if _, err := f.GetHostRecords("app99.infra.local"); err == nil {
+		t.Errorf("FreeIPADatasource.GetHostRecords() error = nil, want an error for a missing host")
+	}
+}
@@ -0,0 +1,68 @@
+package inventory
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// virtualGroupNameRegex matches valid Ansible group names.
+var virtualGroupNameRegex = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+
+// ParseVirtualGroups parses the virtual group expressions defined in the configuration.
+func (i *Inventory) ParseVirtualGroups() (map[string][]HostFilter, error) {
+	cfg := i.Config
+	groups := make(map[string][]HostFilter, len(cfg.Groups))
+
+	for name, expr := range cfg.Groups {
+		if !virtualGroupNameRegex.MatchString(name) {
+			return nil, errors.Errorf("%s: invalid virtual group name", name)
+		}
+
+		filters, err := i.ParseFilters(expr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s: virtual group expression parsing failure", name)
+		}
+
+		groups[name] = filters
+	}
+
+	return groups, nil
+}
+
+// importVirtualGroups adds hosts to the virtual groups they match. Virtual groups are children of the root group.
+func (i *Inventory) importVirtualGroups(hosts map[string][]*HostAttributes) {
+	log := i.Logger
+
+	if len(i.VirtualGroups) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(i.VirtualGroups))
+	for name := range i.VirtualGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		group := i.Tree.AddChild(name)
+
+		for host, attrsList := range hosts {
+			for _, attrs := range attrsList {
+				match, err := matchFilters(host, attrs, i.VirtualGroups[name])
+				if err != nil {
+					log.Warnf("[%s] skipping virtual group %s: %v", host, name, err)
+					break
+				}
+
+				if match {
+					group.AddHost(host)
+					break
+				}
+			}
+		}
+	}
+
+	i.Tree.SortChildren()
+}
@@ -0,0 +1,74 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInventory_ParseVirtualGroups(t *testing.T) {
+	cfg := &Config{}
+	cfg.Txt.Keys.Os = "OS"
+	cfg.Txt.Keys.Env = "ENV"
+	cfg.Txt.Keys.Role = "ROLE"
+	cfg.Txt.Keys.Srv = "SRV"
+	cfg.Txt.Keys.Vars = "VARS"
+
+	tests := []struct {
+		name    string
+		groups  map[string]string
+		want    map[string][]HostFilter
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			groups: map[string]string{
+				"webtier": "role=app|web,env=prod",
+			},
+			want: map[string][]HostFilter{
+				"webtier": {
+					{Key: "ROLE", Operator: "in", Values: []string{"app", "web"}},
+					{Key: "ENV", Operator: "in", Values: []string{"prod"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "valid-empty",
+			groups:  map[string]string{},
+			want:    map[string][]HostFilter{},
+			wantErr: false,
+		},
+		{
+			name: "invalid-name",
+			groups: map[string]string{
+				"web-tier": "role=web",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid-expression",
+			groups: map[string]string{
+				"webtier": "vars=test",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testCfg := *cfg
+			testCfg.Groups = tt.groups
+			i := &Inventory{Config: &testCfg}
+
+			got, err := i.ParseVirtualGroups()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Inventory.ParseVirtualGroups() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inventory.ParseVirtualGroups() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
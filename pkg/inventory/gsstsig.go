@@ -0,0 +1,613 @@
+package inventory
+
+import (
+	"context"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	krb5client "github.com/jcmturner/gokrb5/v8/client"
+	krb5config "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/crypto"
+	"github.com/jcmturner/gokrb5/v8/gssapi"
+	"github.com/jcmturner/gokrb5/v8/iana/chksumtype"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/iana/flags"
+	"github.com/jcmturner/gokrb5/v8/iana/keyusage"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/jcmturner/gokrb5/v8/types"
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+const (
+	// TSIG algorithm name of GSS-TSIG.
+	gssAlgorithm string = "gss-tsig."
+	// Lifetime of GSS-TSIG contexts requested with TKEY. Servers shorten it to the lifetime of the Kerberos ticket.
+	gssContextLifetime time.Duration = time.Hour
+	// Contexts expiring within this margin are negotiated again before use.
+	gssContextMargin time.Duration = time.Minute
+	// TKEY mode of the GSS-API negotiation (RFC 2930).
+	gssTKEYMode uint16 = 3
+	// Maximum number of TKEY round trips of a negotiation.
+	gssMaxRounds int = 3
+
+	// Requested GSS-API context flags: mutual authentication, replay detection and integrity.
+	gssFlags uint32 = gssapi.ContextFlagMutual | gssapi.ContextFlagReplay | gssapi.ContextFlagInteg
+)
+
+var (
+	// Kerberos 5 GSS-API mechanism OID.
+	oidKRB5 = asn1.ObjectIdentifier(gssapi.OIDKRB5.OID())
+	// Kerberos 5 mechanism OID as returned by Microsoft acceptors.
+	oidMSKRB5 = asn1.ObjectIdentifier(gssapi.OIDMSLegacyKRB5.OID())
+)
+
+type (
+	// gssTSIG negotiates and caches GSS-TSIG security contexts (RFC 3645) with the DNS server.
+	gssTSIG struct {
+		// Kerberos configuration.
+		krb5 *krb5config.Config
+		// Kerberos client using the keytab, nil if a credential cache is used.
+		client *krb5client.Client
+		// Credential cache path, used if no keytab is configured.
+		ccache string
+		// DNS service principal.
+		service types.PrincipalName
+		// Realm of the DNS service principal.
+		realm string
+
+		// Guards the context.
+		mu sync.Mutex
+		// Current security context, nil until negotiated.
+		current *gssContext
+	}
+
+	// gssContext is an established Kerberos GSS-API security context used to sign and verify messages.
+	gssContext struct {
+		// TKEY key name, used as the TSIG key name.
+		name string
+		// Key of MIC tokens: the acceptor subkey if asserted, the initiator subkey otherwise.
+		key types.EncryptionKey
+		// Whether the key is the acceptor subkey.
+		acceptorSubkey bool
+		// Context expiration time.
+		expires time.Time
+
+		// Guards the sequence number.
+		mu sync.Mutex
+		// Sequence number of the next MIC token.
+		seq uint64
+	}
+
+	// gssInitiator is the state of a security context being established.
+	gssInitiator struct {
+		// Service ticket session key.
+		key types.EncryptionKey
+		// Authenticator of the AP-REQ, holding the initiator subkey and sequence number.
+		authenticator types.Authenticator
+		// Established context once the acceptor has replied, nil before that.
+		context *gssContext
+	}
+
+	// spnegoNegTokenResp is a SPNEGO NegTokenResp. Unlike the gokrb5 one, its negotiation state is optional.
+	spnegoNegTokenResp struct {
+		NegState      asn1.Enumerated       `asn1:"optional,explicit,tag:0"`
+		SupportedMech asn1.ObjectIdentifier `asn1:"optional,explicit,tag:1"`
+		ResponseToken []byte                `asn1:"optional,explicit,tag:2"`
+		MechListMIC   []byte                `asn1:"optional,explicit,tag:3"`
+	}
+)
+
+// newGSSTSIG creates a GSS-TSIG context negotiator using the GSS-TSIG configuration.
+// The client principal defaults to the first principal of the keytab or to the default principal of the credential cache.
+// The service principal defaults to 'DNS/<server host name>' in the client realm.
+func newGSSTSIG(cfg *Config) (*gssTSIG, error) {
+	gss := cfg.DNS.Tsig.Gss
+	g := &gssTSIG{}
+
+	var principal types.PrincipalName
+	var realm string
+	if len(gss.Keytab) > 0 {
+		kt, err := keytab.Load(gss.Keytab)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s: keytab reading failure", gss.Keytab)
+		}
+
+		if len(kt.Entries) == 0 {
+			return nil, errors.Errorf("%s: keytab holds no keys", gss.Keytab)
+		}
+
+		first := kt.Entries[0].Principal
+		principal, realm = types.PrincipalName{NameType: first.NameType, NameString: first.Components}, first.Realm
+		if len(gss.Principal) > 0 {
+			principal, realm = parseKrbPrincipal(gss.Principal, nametype.KRB_NT_PRINCIPAL, realm)
+		}
+		if len(gss.Realm) > 0 {
+			realm = gss.Realm
+		}
+
+		if !krbKeytabHolds(kt, principal, realm) {
+			return nil, errors.Errorf("%s: keytab holds no keys of %s@%s", gss.Keytab, principal.PrincipalNameString(), realm)
+		}
+
+		g.krb5 = newKrb5Config(cfg, realm)
+		g.client = krb5client.NewWithKeytab(principal.PrincipalNameString(), realm, kt, g.krb5)
+	} else {
+		g.ccache = gss.Ccache
+		if len(g.ccache) == 0 {
+			g.ccache = os.Getenv("KRB5CCNAME")
+		}
+		if len(g.ccache) == 0 {
+			g.ccache = "/tmp/krb5cc_" + strconv.Itoa(os.Getuid())
+		}
+
+		if strings.Contains(g.ccache, ":") && !strings.HasPrefix(g.ccache, "FILE:") {
+			return nil, errors.Errorf("%s: only file credential caches are supported", g.ccache)
+		}
+		g.ccache = strings.TrimPrefix(g.ccache, "FILE:")
+
+		ccache, err := credentials.LoadCCache(g.ccache)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s: credential cache reading failure", g.ccache)
+		}
+
+		principal, realm = ccache.DefaultPrincipal.PrincipalName, ccache.DefaultPrincipal.Realm
+		if len(gss.Principal) > 0 {
+			if p, r := parseKrbPrincipal(gss.Principal, nametype.KRB_NT_PRINCIPAL, realm); !p.Equal(principal) || r != realm {
+				return nil, errors.Errorf("%s: credential cache holds tickets of %s@%s, not %s", g.ccache, principal.PrincipalNameString(), realm, gss.Principal)
+			}
+		}
+
+		// The realm of the DNS service principal may differ from the realm of the cached tickets.
+		if len(gss.Realm) > 0 {
+			realm = gss.Realm
+		}
+
+		g.krb5 = newKrb5Config(cfg, realm)
+	}
+
+	if len(realm) == 0 {
+		return nil, errors.New("kerberos realm is not set")
+	}
+
+	service := gss.Service
+	if len(service) == 0 {
+		host, _, err := net.SplitHostPort(cfg.DNS.Server)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid DNS server address")
+		}
+
+		if net.ParseIP(host) != nil {
+			return nil, errors.Errorf("%s: the DNS service principal must be set when the DNS server is specified by address", host)
+		}
+
+		service = "DNS/" + strings.TrimSuffix(host, ".")
+	}
+
+	g.service, g.realm = parseKrbPrincipal(service, nametype.KRB_NT_SRV_HST, realm)
+
+	// Service tickets are requested from the KDCs of the service realm.
+	g.krb5.DomainRealm[strings.ToLower(g.service.NameString[len(g.service.NameString)-1])] = g.realm
+
+	return g, nil
+}
+
+// newKrb5Config builds the Kerberos configuration of a client realm. The KDCs of the realm default to the '_kerberos' SRV records, as do the KDCs of other realms.
+func newKrb5Config(cfg *Config, realm string) *krb5config.Config {
+	c := krb5config.New()
+	c.LibDefaults.DefaultRealm = realm
+	c.LibDefaults.DNSLookupKDC = true
+
+	if kdcs := cfg.DNS.Tsig.Gss.KDCs; len(kdcs) > 0 {
+		r := krb5config.Realm{Realm: realm}
+		for _, kdc := range kdcs {
+			if _, _, err := net.SplitHostPort(kdc); err != nil {
+				kdc = net.JoinHostPort(kdc, "88")
+			}
+			r.KDC = append(r.KDC, kdc)
+		}
+		c.Realms = append(c.Realms, r)
+	}
+
+	return c
+}
+
+// parseKrbPrincipal parses a 'name[/instance]@REALM' principal name. The name type and the realm default to the given ones.
+func parseKrbPrincipal(s string, nameType int32, realm string) (types.PrincipalName, string) {
+	name, r := types.ParseSPNString(s)
+	name.NameType = nameType
+	if len(r) == 0 {
+		r = realm
+	}
+
+	return name, r
+}
+
+// krbKeytabHolds returns whether a keytab holds keys of a principal.
+func krbKeytabHolds(kt *keytab.Keytab, principal types.PrincipalName, realm string) bool {
+	for _, e := range kt.Entries {
+		if e.Principal.Realm == realm && principal.Equal(types.PrincipalName{NameString: e.Principal.Components}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// login returns the Kerberos client. Credential caches are read again every time, so that tickets renewed by kinit or sssd are picked up.
+func (g *gssTSIG) login() (*krb5client.Client, error) {
+	if g.client != nil {
+		return g.client, nil
+	}
+
+	ccache, err := credentials.LoadCCache(g.ccache)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: credential cache reading failure", g.ccache)
+	}
+
+	cl, err := krb5client.NewFromCCache(ccache, g.krb5)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: credential cache reading failure", g.ccache)
+	}
+
+	return cl, nil
+}
+
+// close stops the renewal of the ticket-granting tickets acquired with the keytab.
+func (g *gssTSIG) close() {
+	if g.client != nil {
+		g.client.Destroy()
+	}
+}
+
+// context returns a valid security context, negotiating a new one with the DNS server if necessary.
+func (g *gssTSIG) context(ctx context.Context, server string, timeout time.Duration) (*gssContext, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.current != nil && time.Now().Add(gssContextMargin).Before(g.current.expires) {
+		return g.current, nil
+	}
+
+	c, err := g.negotiate(ctx, server, timeout)
+	if err != nil {
+		return nil, errors.Wrap(err, "GSS-TSIG negotiation failure")
+	}
+	g.current = c
+
+	return c, nil
+}
+
+// discard drops a security context rejected by the DNS server, so that the next request negotiates a new one.
+func (g *gssTSIG) discard(c *gssContext) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.current == c {
+		g.current = nil
+	}
+}
+
+// negotiate establishes a security context with the DNS server by exchanging SPNEGO tokens in TKEY queries over TCP (RFC 3645 section 3.1).
+func (g *gssTSIG) negotiate(ctx context.Context, server string, timeout time.Duration) (*gssContext, error) {
+	cl, err := g.login()
+	if err != nil {
+		return nil, err
+	}
+
+	ticket, key, err := cl.GetServiceTicket(g.service.PrincipalNameString())
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s@%s: service ticket request failure", g.service.PrincipalNameString(), g.realm)
+	}
+
+	init, token, err := newGSSInitiator(cl, ticket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	nc, err := dialer.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer nc.Close()
+
+	stop := context.AfterFunc(ctx, func() { nc.Close() })
+	defer stop()
+
+	conn := &dns.Conn{Conn: nc}
+	name := fmt.Sprintf("%d.sig-%s.", dns.Id(), g.service.NameString[len(g.service.NameString)-1])
+	now := time.Now()
+
+	for round := 0; round < gssMaxRounds; round++ {
+		msg := new(dns.Msg)
+		msg.SetQuestion(dns.Fqdn(name), dns.TypeTKEY)
+		msg.Question[0].Qclass = dns.ClassANY
+		msg.Extra = append(msg.Extra, &dns.TKEY{
+			Hdr:        dns.RR_Header{Name: dns.Fqdn(name), Rrtype: dns.TypeTKEY, Class: dns.ClassANY},
+			Algorithm:  gssAlgorithm,
+			Inception:  uint32(now.Unix()),
+			Expiration: uint32(now.Add(gssContextLifetime).Unix()),
+			Mode:       gssTKEYMode,
+			KeySize:    uint16(len(token)),
+			Key:        hex.EncodeToString(token),
+		})
+
+		if timeout > 0 {
+			nc.SetDeadline(time.Now().Add(timeout))
+		}
+
+		if err := conn.WriteMsg(msg); err != nil {
+			return nil, err
+		}
+
+		raw := make([]byte, dns.MaxMsgSize)
+		n, err := conn.Read(raw)
+		if err != nil {
+			return nil, err
+		}
+		raw = raw[:n]
+
+		rx := new(dns.Msg)
+		if err := rx.Unpack(raw); err != nil {
+			return nil, err
+		}
+
+		if rx.Rcode != dns.RcodeSuccess {
+			return nil, errors.Errorf("TKEY query failed: %s", dns.RcodeToString[rx.Rcode])
+		}
+
+		var tkey *dns.TKEY
+		for _, rr := range rx.Answer {
+			if t, ok := rr.(*dns.TKEY); ok && strings.EqualFold(t.Hdr.Name, dns.Fqdn(name)) {
+				tkey = t
+			}
+		}
+
+		if tkey == nil {
+			return nil, errors.New("no TKEY record found in the response")
+		}
+
+		if tkey.Error != 0 {
+			return nil, errors.Errorf("TKEY query failed: %s", dns.RcodeToString[int(tkey.Error)])
+		}
+
+		reply, err := hex.DecodeString(tkey.Key)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid TKEY key data")
+		}
+
+		token, err = init.accept(reply)
+		if err != nil {
+			return nil, err
+		}
+
+		if token != nil {
+			continue
+		}
+
+		// The ticket lifetime is not known to the initiator: the context expires when the server says so.
+		c := init.context
+		c.name = dns.Fqdn(name)
+		c.expires = now.Add(gssContextLifetime)
+		if e := time.Unix(int64(tkey.Expiration), 0); tkey.Expiration > 0 && e.Before(c.expires) {
+			c.expires = e
+		}
+
+		// The final response is signed with the new context.
+		if rx.IsTsig() != nil {
+			if err := dns.TsigVerifyWithProvider(raw, &tsigVerifier{gss: c, strict: true}, "", false); err != nil {
+				return nil, errors.Wrap(err, "TKEY response signature verification failure")
+			}
+		}
+
+		return c, nil
+	}
+
+	return nil, errors.New("too many TKEY round trips")
+}
+
+// newGSSInitiator starts establishing a security context with a service ticket and returns the initial SPNEGO token.
+// The token holds a Kerberos AP-REQ requesting mutual authentication, with a fresh subkey and sequence number.
+func newGSSInitiator(cl *krb5client.Client, ticket messages.Ticket, key types.EncryptionKey) (*gssInitiator, []byte, error) {
+	// Microsoft acceptors sign RC4 contexts with RFC 4757 tokens instead of RFC 4121 ones, so only AES session keys are accepted.
+	switch key.KeyType {
+	case etypeID.AES128_CTS_HMAC_SHA1_96, etypeID.AES256_CTS_HMAC_SHA1_96, etypeID.AES128_CTS_HMAC_SHA256_128, etypeID.AES256_CTS_HMAC_SHA384_192:
+	default:
+		return nil, nil, errors.Errorf("unsupported service ticket encryption type: %d", key.KeyType)
+	}
+
+	etype, err := crypto.GetEtype(key.KeyType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	auth, err := types.NewAuthenticator(cl.Credentials.Domain(), cl.Credentials.CName())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := auth.GenerateSeqNumberAndSubKey(key.KeyType, etype.GetKeyByteSize()); err != nil {
+		return nil, nil, err
+	}
+
+	// The checksum holds the length of the (empty) channel bindings, the bindings and the context flags, in little-endian order.
+	checksum := binary.LittleEndian.AppendUint32(nil, 16)
+	checksum = append(checksum, make([]byte, 16)...)
+	checksum = binary.LittleEndian.AppendUint32(checksum, gssFlags)
+	auth.Cksum = types.Checksum{CksumType: chksumtype.GSSAPI, Checksum: checksum}
+
+	apReq, err := messages.NewAPReq(ticket, key, auth)
+	if err != nil {
+		return nil, nil, err
+	}
+	types.SetFlag(&apReq.APOptions, flags.APOptionMutualRequired)
+
+	// The gokrb5 token does not hold a subkey, so its AP-REQ is replaced.
+	mech, err := spnego.NewKRB5TokenAPREQ(cl, ticket, key, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	mech.APReq = apReq
+
+	mechToken, err := mech.Marshal()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	negTokenInit := spnego.NegTokenInit{MechTokenBytes: mechToken}
+	negTokenInit.MechTypes = append(negTokenInit.MechTypes, gssapi.OIDKRB5.OID())
+
+	token, err := (&spnego.SPNEGOToken{Init: true, NegTokenInit: negTokenInit}).Marshal()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &gssInitiator{key: key, authenticator: auth}, token, nil
+}
+
+// accept processes a token of the acceptor, returning the next token to send or nil if the context is established.
+func (init *gssInitiator) accept(token []byte) ([]byte, error) {
+	// Acceptors may reply with a bare Kerberos token.
+	if len(token) > 0 && token[0] == 0x60 {
+		return nil, init.acceptMechToken(token)
+	}
+
+	resp := &spnegoNegTokenResp{}
+	if _, err := asn1.UnmarshalWithParams(token, resp, "explicit,tag:1"); err != nil {
+		return nil, errors.Wrap(err, "invalid SPNEGO token")
+	}
+
+	// An absent state means the negotiation is complete.
+	state := spnego.NegState(resp.NegState)
+	if state == spnego.NegStateReject {
+		return nil, errors.New("GSS-API context rejected by the DNS server")
+	}
+
+	if len(resp.SupportedMech) > 0 && !resp.SupportedMech.Equal(oidKRB5) && !resp.SupportedMech.Equal(oidMSKRB5) {
+		return nil, errors.Errorf("unsupported GSS-API mechanism selected by the DNS server: %s", resp.SupportedMech)
+	}
+
+	if len(resp.ResponseToken) > 0 {
+		if init.context != nil {
+			return nil, errors.New("unexpected GSS-API token")
+		}
+
+		if err := init.acceptMechToken(resp.ResponseToken); err != nil {
+			return nil, err
+		}
+	}
+
+	if init.context == nil {
+		return nil, errors.New("GSS-API context not established by the DNS server")
+	}
+
+	if len(resp.MechListMIC) > 0 {
+		if err := init.context.verifyMIC(spnegoMechTypes(), resp.MechListMIC); err != nil {
+			return nil, errors.Wrap(err, "SPNEGO mechanism list verification failure")
+		}
+	}
+
+	if state == spnego.NegStateAcceptCompleted {
+		return nil, nil
+	}
+
+	// The acceptor waits for the mechanism list MIC of the initiator.
+	mic, err := init.context.getMIC(spnegoMechTypes())
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.MarshalWithParams(spnegoNegTokenResp{MechListMIC: mic}, "explicit,tag:1")
+}
+
+// acceptMechToken processes the Kerberos AP-REP of the acceptor, establishing the context.
+func (init *gssInitiator) acceptMechToken(token []byte) error {
+	mech := &spnego.KRB5Token{}
+	if err := mech.Unmarshal(token); err != nil {
+		return errors.Wrap(err, "invalid GSS-API token")
+	}
+
+	switch {
+	case mech.IsKRBError():
+		return mech.KRBError
+	case !mech.IsAPRep():
+		return errors.New("unexpected GSS-API token")
+	}
+
+	plain, err := crypto.DecryptEncPart(mech.APRep.EncPart, init.key, keyusage.AP_REP_ENCPART)
+	if err != nil {
+		return errors.Wrap(err, "AP-REP decryption failure")
+	}
+
+	part := &messages.EncAPRepPart{}
+	if err := part.Unmarshal(plain); err != nil {
+		return errors.Wrap(err, "invalid AP-REP")
+	}
+
+	auth := init.authenticator
+	if part.CTime.Unix() != auth.CTime.Unix() || part.Cusec != auth.Cusec {
+		return errors.New("AP-REP does not match the authenticator")
+	}
+
+	c := &gssContext{key: auth.SubKey, seq: uint64(auth.SeqNumber)}
+	if len(part.Subkey.KeyValue) > 0 {
+		c.key, c.acceptorSubkey = part.Subkey, true
+	}
+	init.context = c
+
+	return nil
+}
+
+// spnegoMechTypes encodes the SPNEGO mechanism list, also covered by the mechanism list MIC.
+func spnegoMechTypes() []byte {
+	data, _ := asn1.Marshal([]asn1.ObjectIdentifier{oidKRB5})
+
+	return data
+}
+
+// getMIC computes the MIC token of a message (RFC 4121 section 4.2.6.1).
+func (c *gssContext) getMIC(msg []byte) ([]byte, error) {
+	c.mu.Lock()
+	seq := c.seq
+	c.seq++
+	c.mu.Unlock()
+
+	token := &gssapi.MICToken{SndSeqNum: seq, Payload: msg}
+	if c.acceptorSubkey {
+		token.Flags |= gssapi.MICTokenFlagAcceptorSubkey
+	}
+
+	if err := token.SetChecksum(c.key, keyusage.GSSAPI_INITIATOR_SIGN); err != nil {
+		return nil, err
+	}
+
+	return token.Marshal()
+}
+
+// verifyMIC checks the MIC token of a message sent by the acceptor.
+func (c *gssContext) verifyMIC(msg []byte, data []byte) error {
+	token := &gssapi.MICToken{}
+	if err := token.Unmarshal(data, true); err != nil {
+		return errors.Wrap(err, "invalid MIC token")
+	}
+	token.Payload = msg
+
+	if _, err := token.Verify(c.key, keyusage.GSSAPI_ACCEPTOR_SIGN); err != nil {
+		return errors.Wrap(err, "MIC token verification failed")
+	}
+
+	return nil
+}
@@ -0,0 +1,569 @@
+package inventory
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/asn1tools"
+	"github.com/jcmturner/gokrb5/v8/crypto"
+	"github.com/jcmturner/gokrb5/v8/gssapi"
+	"github.com/jcmturner/gokrb5/v8/iana/chksumtype"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/iana/flags"
+	"github.com/jcmturner/gokrb5/v8/iana/keyusage"
+	"github.com/jcmturner/gokrb5/v8/iana/msgtype"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/jcmturner/gokrb5/v8/types"
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+const testRealm = "TEST.LOCAL"
+
+type (
+	// testAcceptor is a DNS server accepting GSS-TSIG contexts with the keytab of the test DNS service and serving signed zone transfers.
+	testAcceptor struct {
+		// DNS service keytab.
+		keytab *keytab.Keytab
+		// Require the mechanism list MIC of the initiator in a second round trip.
+		mechListMIC bool
+
+		mu sync.Mutex
+		// Security contexts by key name.
+		contexts map[string]*testAcceptorContext
+		// Number of established contexts.
+		established int
+	}
+
+	// testAcceptorContext is a security context of the test acceptor.
+	testAcceptorContext struct {
+		key types.EncryptionKey
+		seq uint64
+		// The context waits for the mechanism list MIC of the initiator.
+		pending bool
+	}
+
+	// testCredential is a ticket with its session key, as stored in a credential cache.
+	testCredential struct {
+		service string
+		ticket  messages.Ticket
+		key     types.EncryptionKey
+	}
+)
+
+// testKeytab creates a keytab holding the keys of a principal derived from a password, one per encryption type.
+func testKeytab(tb testing.TB, principal string, password string, etypes ...int32) *keytab.Keytab {
+	kt := keytab.New()
+	for _, etype := range etypes {
+		if err := kt.AddEntry(principal, testRealm, password, time.Now(), 2, etype); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	return kt
+}
+
+// testKeytabFile writes a keytab to a file.
+func testKeytabFile(tb testing.TB, kt *keytab.Keytab) string {
+	data, err := kt.Marshal()
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	path := filepath.Join(tb.TempDir(), "krb5.keytab")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		tb.Fatal(err)
+	}
+
+	return path
+}
+
+// testTicket issues a ticket of 'ansible@TEST.LOCAL' for a service, encrypted with the key of the service.
+func testTicket(tb testing.TB, service string, kt *keytab.Keytab, etype int32) *testCredential {
+	now := time.Now().UTC()
+	sname, _ := types.ParseSPNString(service)
+
+	ticket, key, err := messages.NewTicket(types.NewPrincipalName(nametype.KRB_NT_PRINCIPAL, "ansible"), testRealm, sname, testRealm, types.NewKrbFlags(), kt, etype, 2, now, now, now.Add(time.Hour), now.Add(time.Hour))
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return &testCredential{service: service, ticket: ticket, key: key}
+}
+
+// testCCache writes a version 4 credential cache holding credentials of 'ansible@TEST.LOCAL'.
+func testCCache(tb testing.TB, credentials ...*testCredential) string {
+	appendString := func(data []byte, s string) []byte {
+		data = binary.BigEndian.AppendUint32(data, uint32(len(s)))
+		return append(data, s...)
+	}
+
+	appendPrincipal := func(data []byte, name string, nameType int32) []byte {
+		pn, _ := types.ParseSPNString(name)
+		data = binary.BigEndian.AppendUint32(data, uint32(nameType))
+		data = binary.BigEndian.AppendUint32(data, uint32(len(pn.NameString)))
+		data = appendString(data, testRealm)
+		for _, c := range pn.NameString {
+			data = appendString(data, c)
+		}
+
+		return data
+	}
+
+	data := []byte{5, 4, 0, 0}
+	data = appendPrincipal(data, "ansible", nametype.KRB_NT_PRINCIPAL)
+	for _, cred := range credentials {
+		ticket, err := cred.ticket.Marshal()
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		data = appendPrincipal(data, "ansible", nametype.KRB_NT_PRINCIPAL)
+		data = appendPrincipal(data, cred.service, nametype.KRB_NT_SRV_INST)
+		data = binary.BigEndian.AppendUint16(data, uint16(cred.key.KeyType))
+		data = binary.BigEndian.AppendUint32(data, uint32(len(cred.key.KeyValue)))
+		data = append(data, cred.key.KeyValue...)
+		for _, t := range []time.Time{time.Now(), time.Now().Add(-time.Minute), time.Now().Add(time.Hour), time.Now().Add(time.Hour)} {
+			data = binary.BigEndian.AppendUint32(data, uint32(t.Unix()))
+		}
+		data = append(data, 0)
+		data = binary.BigEndian.AppendUint32(data, 0)
+		data = binary.BigEndian.AppendUint32(data, 0)
+		data = binary.BigEndian.AppendUint32(data, 0)
+		data = appendString(data, string(ticket))
+		data = binary.BigEndian.AppendUint32(data, 0)
+	}
+
+	path := filepath.Join(tb.TempDir(), "krb5cc")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		tb.Fatal(err)
+	}
+
+	return path
+}
+
+// startTestAcceptor starts a DNS server over TCP serving the 'infra.local.' zone to GSS-TSIG signed transfer requests.
+func startTestAcceptor(tb testing.TB, a *testAcceptor) string {
+	a.contexts = make(map[string]*testAcceptorContext)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	srv := &dns.Server{Listener: l, Handler: a, TsigProvider: a}
+	go srv.ActivateAndServe()
+	tb.Cleanup(func() { srv.Shutdown() })
+
+	return l.Addr().String()
+}
+
+// ServeDNS answers TKEY queries and zone transfer requests.
+func (a *testAcceptor) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+
+	switch r.Question[0].Qtype {
+	case dns.TypeTKEY:
+		tkey, ok := r.Extra[0].(*dns.TKEY)
+		if !ok {
+			m.Rcode = dns.RcodeFormatError
+			w.WriteMsg(m)
+			return
+		}
+
+		token, _ := hex.DecodeString(tkey.Key)
+		reply, complete, err := a.accept(tkey.Hdr.Name, token)
+
+		answer := &dns.TKEY{Hdr: tkey.Hdr, Algorithm: tkey.Algorithm, Inception: tkey.Inception, Expiration: tkey.Expiration, Mode: tkey.Mode}
+		if err != nil {
+			answer.Error = dns.RcodeBadKey
+		} else {
+			answer.Key, answer.KeySize = hex.EncodeToString(reply), uint16(len(reply))
+		}
+		m.Answer = append(m.Answer, answer)
+
+		if complete {
+			m.SetTsig(tkey.Hdr.Name, gssAlgorithm, 300, time.Now().Unix())
+		}
+
+		w.WriteMsg(m)
+	case dns.TypeAXFR:
+		if r.IsTsig() == nil || w.TsigStatus() != nil {
+			m.Rcode = dns.RcodeRefused
+			w.WriteMsg(m)
+			return
+		}
+
+		soa := &dns.SOA{Hdr: dns.RR_Header{Name: "infra.local.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 60}, Ns: "ns1.test.local.", Mbox: "admin.test.local.", Serial: 7}
+		txt := func(host string) dns.RR {
+			return &dns.TXT{Hdr: dns.RR_Header{Name: "ansible-dns-inventory.infra.local.", Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 60}, Txt: []string{host + ".infra.local:OS=linux;ENV=dev;ROLE=app;SRV=tomcat"}}
+		}
+
+		// Two messages: the second one is signed with the MAC of the first one.
+		ch := make(chan *dns.Envelope, 2)
+		ch <- &dns.Envelope{RR: []dns.RR{soa, txt("app01")}}
+		ch <- &dns.Envelope{RR: []dns.RR{txt("app02"), soa}}
+		close(ch)
+
+		new(dns.Transfer).Out(w, r, ch)
+	}
+}
+
+// accept processes an initiator token, returning the reply token and whether the context is established.
+func (a *testAcceptor) accept(name string, token []byte) ([]byte, bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	name = dns.CanonicalName(name)
+
+	// Second round trip: the mechanism list MIC of the initiator.
+	if c, ok := a.contexts[name]; ok && c.pending {
+		// The negotiation state is optional in the initiator token, which the gokrb5 NegTokenResp does not allow.
+		resp := &spnegoNegTokenResp{}
+		if _, err := asn1.UnmarshalWithParams(token, resp, "explicit,tag:1"); err != nil {
+			return nil, false, err
+		}
+
+		if err := c.verify(spnegoMechTypes(), resp.MechListMIC); err != nil {
+			return nil, false, err
+		}
+		c.pending = false
+		a.established++
+
+		reply, err := (&spnego.NegTokenResp{}).Marshal()
+
+		return reply, true, err
+	}
+
+	init := &spnego.SPNEGOToken{}
+	if err := init.Unmarshal(token); err != nil || !init.Init {
+		return nil, false, errors.New("not a SPNEGO NegTokenInit")
+	}
+
+	mech := &spnego.KRB5Token{}
+	if err := mech.Unmarshal(init.NegTokenInit.MechTokenBytes); err != nil || !mech.IsAPReq() {
+		return nil, false, errors.New("not a Kerberos AP-REQ token")
+	}
+
+	apReq := mech.APReq
+	if err := apReq.Ticket.DecryptEncPart(a.keytab, nil); err != nil {
+		return nil, false, err
+	}
+
+	session := apReq.Ticket.DecryptedEncPart.Key
+	if err := apReq.DecryptAuthenticator(session); err != nil {
+		return nil, false, err
+	}
+	auth := apReq.Authenticator
+
+	// The checksum holds the requested context flags.
+	if auth.Cksum.CksumType != chksumtype.GSSAPI || len(auth.Cksum.Checksum) < 24 || binary.LittleEndian.Uint32(auth.Cksum.Checksum[20:]) != gssFlags {
+		return nil, false, errors.New("invalid GSS-API checksum")
+	}
+
+	if !types.IsFlagSet(&apReq.APOptions, flags.APOptionMutualRequired) || len(auth.SubKey.KeyValue) == 0 {
+		return nil, false, errors.New("mutual authentication with a subkey is required")
+	}
+
+	subkey := types.EncryptionKey{KeyType: session.KeyType, KeyValue: make([]byte, len(session.KeyValue))}
+	rand.Read(subkey.KeyValue)
+
+	part, err := asn1.MarshalWithParams(messages.EncAPRepPart{CTime: auth.CTime, Cusec: auth.Cusec, Subkey: subkey, SequenceNumber: 1}, "application,explicit,tag:27")
+	if err != nil {
+		return nil, false, err
+	}
+
+	encPart, err := crypto.GetEncryptedData(part, session, keyusage.AP_REP_ENCPART, 0)
+	if err != nil {
+		return nil, false, err
+	}
+
+	apRep, err := asn1.MarshalWithParams(messages.APRep{PVNO: 5, MsgType: msgtype.KRB_AP_REP, EncPart: encPart}, "application,explicit,tag:15")
+	if err != nil {
+		return nil, false, err
+	}
+
+	oid, _ := asn1.Marshal(oidKRB5)
+	resp := &spnego.NegTokenResp{
+		SupportedMech: gssapi.OIDKRB5.OID(),
+		ResponseToken: asn1tools.AddASNAppTag(append(append(oid, 2, 0), apRep...), 0),
+	}
+
+	c := &testAcceptorContext{key: subkey, seq: 1, pending: a.mechListMIC}
+	a.contexts[name] = c
+
+	if a.mechListMIC {
+		// Incomplete: the acceptor waits for the MIC of the initiator.
+		resp.NegState = 1
+		if resp.MechListMIC, err = c.sign(spnegoMechTypes()); err != nil {
+			return nil, false, err
+		}
+	} else {
+		a.established++
+	}
+
+	reply, err := resp.Marshal()
+
+	return reply, !a.mechListMIC, err
+}
+
+// Generate signs a message with the context named by the TSIG key.
+func (a *testAcceptor) Generate(msg []byte, t *dns.TSIG) ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	c, ok := a.contexts[dns.CanonicalName(t.Hdr.Name)]
+	if !ok {
+		return nil, dns.ErrSecret
+	}
+
+	return c.sign(msg)
+}
+
+// Verify checks the signature of a message with the context named by the TSIG key.
+func (a *testAcceptor) Verify(msg []byte, t *dns.TSIG) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	c, ok := a.contexts[dns.CanonicalName(t.Hdr.Name)]
+	if !ok || c.pending {
+		return dns.ErrSecret
+	}
+
+	mac, err := hex.DecodeString(t.MAC)
+	if err != nil {
+		return err
+	}
+
+	return c.verify(msg, mac)
+}
+
+// sign computes the MIC token of a message sent by the acceptor.
+func (c *testAcceptorContext) sign(msg []byte) ([]byte, error) {
+	token := &gssapi.MICToken{Flags: gssapi.MICTokenFlagSentByAcceptor | gssapi.MICTokenFlagAcceptorSubkey, SndSeqNum: c.seq, Payload: msg}
+	c.seq++
+
+	if err := token.SetChecksum(c.key, keyusage.GSSAPI_ACCEPTOR_SIGN); err != nil {
+		return nil, err
+	}
+
+	return token.Marshal()
+}
+
+// verify checks the MIC token of a message sent by the initiator.
+func (c *testAcceptorContext) verify(msg []byte, data []byte) error {
+	token := &gssapi.MICToken{}
+	if err := token.Unmarshal(data, false); err != nil {
+		return err
+	}
+
+	if token.Flags != gssapi.MICTokenFlagAcceptorSubkey {
+		return errors.New("invalid MIC token flags")
+	}
+	token.Payload = msg
+
+	_, err := token.Verify(c.key, keyusage.GSSAPI_INITIATOR_SIGN)
+
+	return err
+}
+
+func TestDNSDatasource_transferZone_gss(t *testing.T) {
+	tgs := testKeytab(t, "krbtgt/"+testRealm, "tgs", etypeID.AES256_CTS_HMAC_SHA1_96)
+	service := testKeytab(t, "DNS/ns1.test.local", "dns", etypeID.AES256_CTS_HMAC_SHA1_96, etypeID.AES128_CTS_HMAC_SHA1_96, etypeID.RC4_HMAC)
+
+	// A KDC address nothing listens on.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kdc := l.Addr().String()
+	l.Close()
+
+	tests := []struct {
+		name        string
+		etype       int32
+		keytab      *keytab.Keytab
+		mechListMIC bool
+		ccache      bool
+		wantErr     bool
+	}{
+		{
+			name:        "valid",
+			etype:       etypeID.AES256_CTS_HMAC_SHA1_96,
+			keytab:      service,
+			mechListMIC: false,
+			ccache:      true,
+			wantErr:     false,
+		},
+		{
+			name:        "valid-aes128-mechlistmic",
+			etype:       etypeID.AES128_CTS_HMAC_SHA1_96,
+			keytab:      service,
+			mechListMIC: true,
+			ccache:      true,
+			wantErr:     false,
+		},
+		{
+			name:    "invalid-rc4",
+			etype:   etypeID.RC4_HMAC,
+			keytab:  service,
+			ccache:  true,
+			wantErr: true,
+		},
+		{
+			name:    "invalid-service-key",
+			etype:   etypeID.AES256_CTS_HMAC_SHA1_96,
+			keytab:  testKeytab(t, "DNS/ns1.test.local", "other", etypeID.AES256_CTS_HMAC_SHA1_96),
+			ccache:  true,
+			wantErr: true,
+		},
+		{
+			name:    "invalid-kdc",
+			etype:   etypeID.AES256_CTS_HMAC_SHA1_96,
+			keytab:  service,
+			ccache:  false,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acceptor := &testAcceptor{keytab: tt.keytab, mechListMIC: tt.mechListMIC}
+
+			cfg := &Config{}
+			cfg.DNS.Server = startTestAcceptor(t, acceptor)
+			cfg.DNS.Timeout = 5 * time.Second
+			cfg.DNS.Tsig.Enabled = true
+			cfg.DNS.Tsig.Algo = gssAlgorithm
+			cfg.DNS.Tsig.Strict = true
+			cfg.DNS.Tsig.Gss.KDCs = []string{kdc}
+			cfg.DNS.Tsig.Gss.Service = "DNS/ns1.test.local"
+
+			// The service ticket is taken from the credential cache, the keytab requires a KDC.
+			if tt.ccache {
+				cfg.DNS.Tsig.Gss.Ccache = testCCache(t, testTicket(t, "krbtgt/"+testRealm, tgs, etypeID.AES256_CTS_HMAC_SHA1_96), testTicket(t, "DNS/ns1.test.local", service, tt.etype))
+			} else {
+				cfg.DNS.Tsig.Gss.Keytab = testKeytabFile(t, testKeytab(t, "ansible", "secret", etypeID.AES256_CTS_HMAC_SHA1_96))
+			}
+
+			d, err := NewDNSDatasource(cfg, &testLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+
+			// The second transfer reuses the security context.
+			for i := 0; i < 2; i++ {
+				rrs, err := d.transferZone(context.Background(), "infra.local.")
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("DNSDatasource.transferZone() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if err != nil {
+					return
+				}
+
+				if len(rrs) != 4 {
+					t.Errorf("DNSDatasource.transferZone() returned %d records, want 4", len(rrs))
+				}
+			}
+
+			if acceptor.established != 1 {
+				t.Errorf("DNSDatasource.transferZone() established %d contexts, want 1", acceptor.established)
+			}
+		})
+	}
+}
+
+func Test_newGSSTSIG(t *testing.T) {
+	keytab := testKeytabFile(t, testKeytab(t, "ansible", "secret", etypeID.AES256_CTS_HMAC_SHA1_96))
+
+	tests := []struct {
+		name      string
+		keytab    string
+		principal string
+		server    string
+		service   string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:    "valid-server",
+			keytab:  keytab,
+			server:  "ns1.test.local:53",
+			want:    "DNS/ns1.test.local@TEST.LOCAL",
+			wantErr: false,
+		},
+		{
+			name:    "valid-service",
+			keytab:  keytab,
+			server:  "192.0.2.1:53",
+			service: "DNS/dc01.corp.local@CORP.LOCAL",
+			want:    "DNS/dc01.corp.local@CORP.LOCAL",
+			wantErr: false,
+		},
+		{
+			name:      "valid-principal",
+			keytab:    keytab,
+			principal: "ansible@TEST.LOCAL",
+			server:    "ns1.test.local:53",
+			want:      "DNS/ns1.test.local@TEST.LOCAL",
+			wantErr:   false,
+		},
+		{
+			name:      "invalid-principal",
+			keytab:    keytab,
+			principal: "other",
+			server:    "ns1.test.local:53",
+			wantErr:   true,
+		},
+		{
+			name:    "invalid-address",
+			keytab:  keytab,
+			server:  "192.0.2.1:53",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-ccache",
+			keytab:  "",
+			server:  "ns1.test.local:53",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.DNS.Server = tt.server
+			cfg.DNS.Tsig.Gss.Keytab = tt.keytab
+			cfg.DNS.Tsig.Gss.Principal = tt.principal
+			cfg.DNS.Tsig.Gss.Ccache = filepath.Join(t.TempDir(), "missing")
+			cfg.DNS.Tsig.Gss.Service = tt.service
+
+			g, err := newGSSTSIG(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newGSSTSIG() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer g.close()
+
+			// Service tickets are requested from the KDCs of the service realm.
+			host := g.service.NameString[len(g.service.NameString)-1]
+			if got := g.service.PrincipalNameString() + "@" + g.realm; got != tt.want || g.krb5.ResolveRealm(host) != g.realm {
+				t.Errorf("newGSSTSIG() service = %s in %s, want %s", got, g.krb5.ResolveRealm(host), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package inventory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// hostState represents the attributes of all records of a host at a specific point in time.
+type hostState struct {
+	// Etcd revision.
+	revision int64
+	// Snapshot file.
+	snapshot string
+	// Snapshot modification time.
+	timestamp *time.Time
+	// Attributes of every host record.
+	records []map[string]string
+}
+
+// splitAttributeString splits a raw attribute string into its key/value pairs without validating it.
+func (i *Inventory) splitAttributeString(raw string) map[string]string {
+	cfg := i.Config
+	attrs := make(map[string]string)
+
+	for _, pair := range strings.Split(raw, cfg.Txt.Kv.Separator) {
+		if key, value, ok := strings.Cut(pair, cfg.Txt.Kv.Equalsign); ok {
+			attrs[key] = value
+		}
+	}
+
+	return attrs
+}
+
+// formatAttributeMap renders key/value pairs in a stable order.
+func formatAttributeMap(attrs map[string]string) string {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+attrs[key])
+	}
+
+	return strings.Join(pairs, ", ")
+}
+
+// readSnapshot acquires the attributes of all records of a host from an inventory snapshot.
+func readSnapshot(path string, host string) ([]map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]map[string]string, 0)
+
+	if filepath.Ext(path) == ".pb" {
+		w := &WireInventory{}
+		if err := w.UnmarshalProto(data); err != nil {
+			return nil, err
+		}
+
+		for _, h := range w.Hosts {
+			if h.Name != host {
+				continue
+			}
+
+			for _, a := range h.Attributes {
+				attrs := map[string]string{
+					adiHostAttributeNames["OS"]:   a.Os,
+					adiHostAttributeNames["ENV"]:  a.Env,
+					adiHostAttributeNames["ROLE"]: a.Role,
+					adiHostAttributeNames["SRV"]:  a.Srv,
+					adiHostAttributeNames["VARS"]: a.Vars,
+				}
+				if len(a.Id) > 0 {
+					attrs[adiHostAttributeNames["ID"]] = a.Id
+				}
+				for key, value := range a.Extra {
+					attrs[key] = value
+				}
+
+				records = append(records, attrs)
+			}
+		}
+	} else {
+		// JSON exports are valid YAML.
+		hosts := make(map[string][]map[string]string)
+		if err := yaml.Unmarshal(data, &hosts); err != nil {
+			return nil, err
+		}
+
+		records = append(records, hosts[host]...)
+	}
+
+	// Exports do not preserve the order of host records.
+	sort.Slice(records, func(a, b int) bool { return formatAttributeMap(records[a]) < formatAttributeMap(records[b]) })
+
+	return records, nil
+}
+
+// snapshotHistory acquires the states of the records of a host from the configured inventory snapshots, oldest first.
+func (i *Inventory) snapshotHistory(host string) ([]*hostState, error) {
+	cfg := i.Config
+
+	paths, err := filepath.Glob(cfg.History.Snapshots)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid snapshot pattern")
+	}
+
+	states := make([]*hostState, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s: snapshot loading failure", path)
+		}
+
+		records, err := readSnapshot(path, host)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s: snapshot loading failure", path)
+		}
+
+		mtime := info.ModTime()
+		states = append(states, &hostState{snapshot: path, timestamp: &mtime, records: records})
+	}
+
+	sort.SliceStable(states, func(a, b int) bool { return states[a].timestamp.Before(*states[b].timestamp) })
+
+	return states, nil
+}
+
+// diffRecords describes the attribute changes between two states of the records of a host. Records are matched by position.
+func diffRecords(prev []map[string]string, next []map[string]string) []string {
+	diff := make([]string, 0)
+
+	for n := 0; n < max(len(prev), len(next)); n++ {
+		switch {
+		case n >= len(prev):
+			diff = append(diff, fmt.Sprintf("+ record %d: %s", n, formatAttributeMap(next[n])))
+		case n >= len(next):
+			diff = append(diff, fmt.Sprintf("- record %d: %s", n, formatAttributeMap(prev[n])))
+		default:
+			keys := make([]string, 0)
+			for key := range prev[n] {
+				keys = append(keys, key)
+			}
+			for key := range next[n] {
+				if _, ok := prev[n][key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				prevValue, prevOk := prev[n][key]
+				nextValue, nextOk := next[n][key]
+
+				switch {
+				case !prevOk:
+					diff = append(diff, fmt.Sprintf("+ record %d: %s=%s", n, key, nextValue))
+				case !nextOk:
+					diff = append(diff, fmt.Sprintf("- record %d: %s=%s", n, key, prevValue))
+				case prevValue != nextValue:
+					diff = append(diff, fmt.Sprintf("~ record %d: %s: %s -> %s", n, key, prevValue, nextValue))
+				}
+			}
+		}
+	}
+
+	return diff
+}
+
+// HostHistory returns a timeline of the changes of the records of a specific host, oldest first.
+// The history is read from the configured inventory snapshots or, if none are configured, from the datasource.
+func (i *Inventory) HostHistory(host string) ([]*HostChange, error) {
+	cfg := i.Config
+
+	var states []*hostState
+	if len(cfg.History.Snapshots) > 0 {
+		var err error
+		if states, err = i.snapshotHistory(host); err != nil {
+			return nil, err
+		}
+	} else {
+		ds, ok := i.Datasource.(HistoryDatasource)
+		if !ok {
+			return nil, errors.Errorf("datasource does not keep host record history, configure inventory snapshots instead: %s", cfg.Datasource)
+		}
+
+		revisions, err := ds.GetHostHistory(host)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s: history loading failure", host)
+		}
+
+		for _, revision := range revisions {
+			state := &hostState{revision: revision.Revision, records: make([]map[string]string, 0, len(revision.Records))}
+			for _, record := range revision.Records {
+				state.records = append(state.records, i.splitAttributeString(record.Attributes))
+			}
+
+			states = append(states, state)
+		}
+	}
+
+	// Only states that differ from the previous one are changes. Host variables are redacted before comparing states,
+	// so that neither the records nor the diff disclose redacted values.
+	changes := make([]*HostChange, 0)
+	previous := make([]map[string]string, 0)
+	for _, state := range states {
+		if len(cfg.Redact.Keys) > 0 {
+			i.redactAttributeMaps(state.records)
+		}
+
+		diff := diffRecords(previous, state.records)
+		if len(diff) == 0 {
+			continue
+		}
+
+		changes = append(changes, &HostChange{
+			Revision:  state.revision,
+			Snapshot:  state.snapshot,
+			Timestamp: state.timestamp,
+			Records:   state.records,
+			Diff:      diff,
+		})
+		previous = state.records
+	}
+
+	return changes, nil
+}
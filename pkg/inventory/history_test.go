@@ -0,0 +1,112 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testHistoryDatasource is a Datasource serving a fixed host record history.
+type testHistoryDatasource struct {
+	testDatasource
+	revisions []*HostRevision
+}
+
+func (d *testHistoryDatasource) GetHostHistory(host string) ([]*HostRevision, error) {
+	return d.revisions, nil
+}
+
+func TestInventory_HostHistory(t *testing.T) {
+	app := &DatasourceRecord{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat"}
+	db := &DatasourceRecord{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=db;SRV=tomcat;ID=1"}
+
+	snapshots := []string{
+		"app01.infra.local:\n  - {OS: linux, ENV: dev, ROLE: app, SRV: tomcat, VARS: \"\"}\n",
+		"{\"app01.infra.local\": [{\"OS\": \"linux\", \"ENV\": \"dev\", \"ROLE\": \"app\", \"SRV\": \"tomcat\", \"VARS\": \"\"}]}\n",
+		"app01.infra.local:\n  - {OS: linux, ENV: prod, ROLE: app, SRV: tomcat, VARS: \"\"}\n  - {OS: linux, ENV: dev, ROLE: app, SRV: tomcat, VARS: \"\"}\n",
+	}
+
+	tests := []struct {
+		name       string
+		datasource Datasource
+		snapshots  []string
+		want       [][]string
+		wantErr    bool
+	}{
+		{
+			name: "valid-datasource",
+			datasource: &testHistoryDatasource{revisions: []*HostRevision{
+				{Revision: 5, Records: []*DatasourceRecord{app}},
+				{Revision: 6, Records: []*DatasourceRecord{app}},
+				{Revision: 8, Records: []*DatasourceRecord{db}},
+				{Revision: 9, Records: []*DatasourceRecord{}},
+			}},
+			want: [][]string{
+				{"+ record 0: ENV=dev, OS=linux, ROLE=app, SRV=tomcat"},
+				{"+ record 0: ID=1", "~ record 0: ROLE: app -> db"},
+				{"- record 0: ENV=dev, ID=1, OS=linux, ROLE=db, SRV=tomcat"},
+			},
+			wantErr: false,
+		},
+		{
+			name:       "valid-snapshots",
+			datasource: &testDatasource{},
+			snapshots:  snapshots,
+			want: [][]string{
+				{"+ record 0: ENV=dev, OS=linux, ROLE=app, SRV=tomcat, VARS="},
+				{"+ record 1: ENV=prod, OS=linux, ROLE=app, SRV=tomcat, VARS="},
+			},
+			wantErr: false,
+		},
+		{
+			name:       "invalid-no-history",
+			datasource: &testDatasource{},
+			want:       nil,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.Txt.Kv.Separator = ";"
+			cfg.Txt.Kv.Equalsign = "="
+
+			if len(tt.snapshots) > 0 {
+				dir := t.TempDir()
+				cfg.History.Snapshots = filepath.Join(dir, "*.yaml")
+
+				// Snapshots are ordered by modification time, not by name.
+				for n, snapshot := range tt.snapshots {
+					path := filepath.Join(dir, string(rune('z'-n))+".yaml")
+					if err := os.WriteFile(path, []byte(snapshot), 0644); err != nil {
+						t.Fatal(err)
+					}
+
+					mtime := time.Now().Add(time.Duration(n) * time.Minute)
+					if err := os.Chtimes(path, mtime, mtime); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			i := &Inventory{Config: cfg, Logger: &testLogger{}, Datasource: tt.datasource}
+
+			changes, err := i.HostHistory("app01.infra.local")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Inventory.HostHistory() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			var got [][]string
+			for _, change := range changes {
+				got = append(got, change.Diff)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inventory.HostHistory() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
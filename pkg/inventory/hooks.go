@@ -0,0 +1,148 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Pre-publish hook point.
+	PrePublishHook string = "prepublish"
+	// Post-publish hook point.
+	PostPublishHook string = "postpublish"
+	// Default event hook execution timeout.
+	hookDefaultTimeout time.Duration = 30 * time.Second
+)
+
+// runHook executes a single event hook, passing the event payload via stdin.
+func (i *Inventory) runHook(spec HookSpec, payload []byte) error {
+	log := i.Logger
+
+	if len(spec.Command) == 0 {
+		return errors.New("empty hook command")
+	}
+
+	timeout := spec.Timeout
+	if timeout == 0 {
+		timeout = hookDefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, spec.Command[0], spec.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	if stdout.Len() > 0 {
+		log.Debugf("[%s] hook output: %s", spec.Command[0], strings.TrimSpace(stdout.String()))
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "%s: hook failure: %s", strings.Join(spec.Command, " "), strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// runHooks executes all event hooks configured for a hook point.
+// If stopOnError is true, the first failing hook aborts the execution and its error is returned. Otherwise, failures are logged.
+func (i *Inventory) runHooks(hooks []HookSpec, event *HookEvent, stopOnError bool) error {
+	log := i.Logger
+
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "hook payload marshalling failure")
+	}
+
+	for _, spec := range hooks {
+		if err := i.runHook(spec, payload); err != nil {
+			if stopOnError {
+				return err
+			}
+
+			log.Warnf("[%s] %v", event.Hook, err)
+		}
+	}
+
+	return nil
+}
+
+// publish publishes host records via the datasource, executing the configured event hooks around it.
+func (i *Inventory) publish(operation string, records []*DatasourceRecord) error {
+	return i.publishWith(operation, records, func() error {
+		return i.Datasource.PublishRecords(records)
+	})
+}
+
+// publishWith checks the publishing policy and executes the configured event hooks around a custom publishing function.
+func (i *Inventory) publishWith(operation string, records []*DatasourceRecord, write func() error) error {
+	return i.publishEvent(&HookEvent{Operation: operation, Records: records}, write)
+}
+
+// publishEvent checks the records of a publishing event against the publishing policy and executes the configured event hooks around a custom publishing function.
+// The operation is recorded in the audit log, if enabled. Operations without an actor are attributed to the current system user.
+func (i *Inventory) publishEvent(event *HookEvent, write func() error) error {
+	cfg := i.Config
+
+	if len(event.Actor) == 0 {
+		event.Actor = defaultActor()
+	}
+
+	entry := i.auditStart(event)
+
+	if err := i.enforcePolicy(event.Records); err != nil {
+		i.auditFinish(entry, RejectedAuditResult, err)
+		return err
+	}
+
+	event.Hook = PrePublishHook
+	event.Datasource = cfg.Datasource
+
+	if err := i.runHooks(cfg.Hooks.PrePublish, event, true); err != nil {
+		err = errors.Wrap(err, "publishing aborted by hook")
+		i.auditFinish(entry, RejectedAuditResult, err)
+		return err
+	}
+
+	err := write()
+
+	if err != nil {
+		i.auditFinish(entry, FailureAuditResult, err)
+	} else {
+		i.auditFinish(entry, SuccessAuditResult, nil)
+	}
+
+	event.Hook = PostPublishHook
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	if hookErr := i.runHooks(cfg.Hooks.PostPublish, event, false); hookErr != nil {
+		i.Logger.Warnf("[%s] %v", PostPublishHook, hookErr)
+	}
+
+	i.notify(&NotifyEvent{
+		Event:     PublishNotifyEvent,
+		Operation: event.Operation,
+		Records:   len(event.Records),
+		Error:     event.Error,
+	})
+
+	return err
+}
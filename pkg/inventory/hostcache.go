@@ -0,0 +1,167 @@
+package inventory
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// hostCache is the on-disk cache of host records used by per-host variable lookups.
+type hostCache struct {
+	// Time of the host record acquisition.
+	Timestamp time.Time `json:"timestamp"`
+	// Host records by hostname.
+	Records map[string][]*DatasourceRecord `json:"records"`
+}
+
+// writeHostCache replaces the host record cache atomically.
+func (i *Inventory) writeHostCache(records []*DatasourceRecord) error {
+	path := i.Config.Host.Cache.Path
+
+	cache := &hostCache{Timestamp: time.Now().UTC(), Records: make(map[string][]*DatasourceRecord)}
+	for _, r := range records {
+		cache.Records[r.Hostname] = append(cache.Records[r.Hostname], r)
+	}
+
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data)
+}
+
+// updateHostCache replaces the host record cache with all host records acquired from the datasource.
+// Records are likely incomplete if the datasource has skipped some zones or returned no records at all, so they don't replace the cache.
+func (i *Inventory) updateHostCache(records []*DatasourceRecord) {
+	log := i.Logger
+
+	if failed := i.FailedZones(); len(failed) > 0 {
+		log.Warnf("host record cache is not updated: %d zones have been skipped", len(failed))
+		return
+	}
+
+	if len(records) == 0 {
+		log.Warnf("host record cache is not updated: no host records found")
+		return
+	}
+
+	if err := i.writeHostCache(records); err != nil {
+		log.Warnf("host record cache writing failure: %v", err)
+	}
+}
+
+// writeFileAtomic replaces a file atomically by writing to a temporary file in the same directory and renaming it.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadHostCache reads the host record cache.
+func (i *Inventory) loadHostCache() (*hostCache, error) {
+	data, err := os.ReadFile(i.Config.Host.Cache.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := &hostCache{}
+	if err := json.Unmarshal(data, cache); err != nil {
+		return nil, errors.Wrap(err, "invalid host record cache")
+	}
+
+	return cache, nil
+}
+
+// readHostCache reads the records of a host from the host record cache.
+// It returns false if the cache is unavailable or older than maxAge. A maxAge of 0 accepts a cache of any age.
+func (i *Inventory) readHostCache(host string, maxAge time.Duration) ([]*DatasourceRecord, bool) {
+	log := i.Logger
+
+	cache, err := i.loadHostCache()
+	if err != nil {
+		log.Debugf("[%s] host record cache is unavailable: %v", host, err)
+		return nil, false
+	}
+
+	if maxAge > 0 && time.Since(cache.Timestamp) > maxAge {
+		log.Debugf("[%s] host record cache is stale: %s", host, cache.Timestamp)
+		return nil, false
+	}
+
+	return cache.Records[host], true
+}
+
+// lookupHostRecords acquires the records of a host for a per-host variable lookup.
+// A fresh host record cache is used first, then the datasource is queried within the configured timeout.
+// If the query fails, the fallback datasources are queried, then a stale cache is used as a last resort.
+func (i *Inventory) lookupHostRecords(host string) ([]*DatasourceRecord, error) {
+	cfg := i.Config
+	log := i.Logger
+	cached := len(cfg.Host.Cache.Path) > 0
+
+	if cached {
+		if records, ok := i.readHostCache(host, cfg.Host.Cache.TTL); ok {
+			return records, nil
+		}
+	}
+
+	records, err := i.queryHostRecords(i.Datasource, host)
+	if err != nil && !isNoHostRecords(err) {
+		if fallback, ok := i.fallbackHostRecords(host, err); ok {
+			return fallback, nil
+		}
+	}
+
+	if err != nil && cached {
+		if stale, ok := i.readHostCache(host, 0); ok {
+			log.Warnf("[%s] using stale host record cache: %v", host, err)
+			return stale, nil
+		}
+	}
+
+	return records, err
+}
+
+// queryHostRecords acquires the records of a host from a datasource, giving up after the configured timeout.
+func (i *Inventory) queryHostRecords(ds Datasource, host string) ([]*DatasourceRecord, error) {
+	timeout := i.Config.Host.Timeout
+	if timeout == 0 {
+		return ds.GetHostRecords(host)
+	}
+
+	type result struct {
+		records []*DatasourceRecord
+		err     error
+	}
+
+	// Datasources don't accept a context, so a query that times out is left to finish in the background.
+	done := make(chan result, 1)
+	go func() {
+		records, err := ds.GetHostRecords(host)
+		done <- result{records, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.records, r.err
+	case <-time.After(timeout):
+		return nil, errors.Errorf("timed out after %s", timeout)
+	}
+}
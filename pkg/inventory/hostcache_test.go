@@ -0,0 +1,182 @@
+package inventory
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// testDatasource is a Datasource serving a fixed set of host records.
+type testDatasource struct {
+	records []*DatasourceRecord
+	delay   time.Duration
+	err     error
+	// Zones reported as skipped by GetAllRecords.
+	failed []string
+}
+
+func (d *testDatasource) GetAllRecords() ([]*DatasourceRecord, error) {
+	return d.records, d.err
+}
+
+func (d *testDatasource) FailedZones() []string {
+	return d.failed
+}
+
+func (d *testDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error) {
+	time.Sleep(d.delay)
+
+	records := make([]*DatasourceRecord, 0)
+	for _, r := range d.records {
+		if r.Hostname == host {
+			records = append(records, r)
+		}
+	}
+
+	return records, d.err
+}
+
+func (d *testDatasource) PublishRecords(records []*DatasourceRecord) error {
+	return nil
+}
+
+func (d *testDatasource) Close() {}
+
+func TestInventory_lookupHostRecords(t *testing.T) {
+	cached := []*DatasourceRecord{{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=;VARS=cached=1"}}
+	current := []*DatasourceRecord{{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=;VARS=current=1"}}
+
+	tests := []struct {
+		name       string
+		cache      bool
+		ttl        time.Duration
+		datasource *testDatasource
+		want       []*DatasourceRecord
+		wantErr    bool
+	}{
+		{
+			name:       "valid-no-cache",
+			datasource: &testDatasource{records: current},
+			want:       current,
+		},
+		{
+			name:       "valid-fresh-cache",
+			cache:      true,
+			ttl:        time.Minute,
+			datasource: &testDatasource{records: current},
+			want:       cached,
+		},
+		{
+			name:       "valid-stale-cache",
+			cache:      true,
+			ttl:        time.Nanosecond,
+			datasource: &testDatasource{records: current},
+			want:       current,
+		},
+		{
+			name:       "valid-stale-cache-fallback",
+			cache:      true,
+			ttl:        time.Nanosecond,
+			datasource: &testDatasource{records: current, err: errors.New("failure")},
+			want:       cached,
+		},
+		{
+			name:       "valid-timeout-fallback",
+			cache:      true,
+			ttl:        time.Nanosecond,
+			datasource: &testDatasource{records: current, delay: time.Second},
+			want:       cached,
+		},
+		{
+			name:       "invalid-timeout",
+			datasource: &testDatasource{records: current, delay: time.Second},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.Host.Timeout = 100 * time.Millisecond
+			cfg.Host.Cache.TTL = tt.ttl
+
+			i := &Inventory{Config: cfg, Logger: &testLogger{}, Datasource: tt.datasource}
+
+			if tt.cache {
+				cfg.Host.Cache.Path = filepath.Join(t.TempDir(), "cache.json")
+
+				if err := i.writeHostCache(cached); err != nil {
+					t.Fatalf("Inventory.writeHostCache() error = %v", err)
+				}
+			}
+
+			got, err := i.lookupHostRecords("app01.infra.local")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Inventory.lookupHostRecords() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inventory.lookupHostRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInventory_GetHosts_cache(t *testing.T) {
+	cached := []*DatasourceRecord{{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=;VARS="}}
+	current := []*DatasourceRecord{{Hostname: "app02.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=;VARS="}}
+
+	tests := []struct {
+		name       string
+		datasource *testDatasource
+		want       []*DatasourceRecord
+	}{
+		{
+			name:       "valid-complete",
+			datasource: &testDatasource{records: current},
+			want:       current,
+		},
+		{
+			// Records of the skipped zone would be missing from the cache.
+			name:       "valid-partial",
+			datasource: &testDatasource{records: current, failed: []string{"corp.local."}},
+			want:       cached,
+		},
+		{
+			name:       "valid-empty",
+			datasource: &testDatasource{records: []*DatasourceRecord{}},
+			want:       cached,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newTestInventory(t, false, nil)
+			i.Datasource = tt.datasource
+			i.Config.Host.Cache.Path = filepath.Join(t.TempDir(), "cache.json")
+
+			if err := i.writeHostCache(cached); err != nil {
+				t.Fatalf("Inventory.writeHostCache() error = %v", err)
+			}
+
+			if _, err := i.GetHosts(); err != nil {
+				t.Fatalf("Inventory.GetHosts() error = %v", err)
+			}
+
+			cache, err := i.loadHostCache()
+			if err != nil {
+				t.Fatalf("Inventory.loadHostCache() error = %v", err)
+			}
+
+			got := make([]*DatasourceRecord, 0)
+			for _, records := range cache.Records {
+				got = append(got, records...)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("host record cache = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package inventory
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// HTTP datasource type.
+	HTTPDatasourceType string = "http"
+)
+
+// HTTPDatasource implements a read-only datasource backed by an HTTP endpoint returning a list of host records.
+// The response is a JSON or YAML list of objects with the 'hostname' and 'attributes' fields, e.g. produced by a small API in front of a CMDB.
+type HTTPDatasource struct {
+	// Inventory configuration.
+	Config *Config
+	// Inventory logger.
+	Logger Logger
+	// HTTP client.
+	Client *http.Client
+}
+
+// token returns the bearer token, reading it from the token file if configured.
+func (h *HTTPDatasource) token() (string, error) {
+	cfg := h.Config
+
+	if len(cfg.HTTP.TokenFile) == 0 {
+		return cfg.HTTP.Token, nil
+	}
+
+	data, err := os.ReadFile(cfg.HTTP.TokenFile)
+	if err != nil {
+		return "", errors.Wrap(err, "token file read failure")
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// decode parses a list of host records from the response body.
+func (h *HTTPDatasource) decode(resp *http.Response) ([]*DatasourceRecord, error) {
+	cfg := h.Config
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	format := strings.ToLower(cfg.HTTP.Format)
+	if format == "auto" {
+		format = "yaml"
+		if mediatype, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && strings.HasSuffix(mediatype, "json") {
+			format = "json"
+		}
+	}
+
+	records := make([]*DatasourceRecord, 0)
+	switch format {
+	case "json":
+		err = json.Unmarshal(data, &records)
+	default:
+		// YAML is a superset of JSON, so this also covers JSON served with a wrong content type.
+		err = yaml.NewDecoder(bytes.NewReader(data)).Decode(&records)
+		if err == io.EOF {
+			err = nil
+		}
+	}
+
+	return records, err
+}
+
+// fetch requests host records from an endpoint. A missing endpoint (404) yields no records if allowMissing is set.
+func (h *HTTPDatasource) fetch(endpoint string, allowMissing bool) ([]*DatasourceRecord, error) {
+	cfg := h.Config
+	log := h.Logger
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request failure")
+	}
+
+	for name, value := range cfg.HTTP.Headers {
+		req.Header.Set(name, value)
+	}
+
+	token, err := h.token()
+	if err != nil {
+		return nil, errors.Wrap(err, "http request failure")
+	}
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request failure")
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && allowMissing:
+		return make([]*DatasourceRecord, 0), nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, errors.Errorf("http request failure: %s", resp.Status)
+	}
+
+	items, err := h.decode(resp)
+	if err != nil {
+		return nil, errors.Wrap(err, "http response parsing failure")
+	}
+
+	records := make([]*DatasourceRecord, 0, len(items))
+	for n, r := range items {
+		if r == nil || len(r.Hostname) == 0 {
+			log.Warnf("skipping http record #%d: hostname is not set", n)
+			continue
+		}
+
+		r.Hostname = strings.ToLower(strings.TrimSuffix(r.Hostname, "."))
+		r.Attributes = strings.TrimSpace(r.Attributes)
+		if len(r.Source) == 0 {
+			r.Source = redactURL(endpoint)
+		}
+
+		records = append(records, r)
+	}
+
+	return records, nil
+}
+
+// GetAllRecords acquires all available host records.
+func (h *HTTPDatasource) GetAllRecords() ([]*DatasourceRecord, error) {
+	records, err := h.fetch(h.Config.HTTP.URL, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "http datasource failure")
+	}
+
+	return records, nil
+}
+
+// GetHostRecords returns the records of a specific host. A host missing from the per-host endpoint (404) has no records.
+// All records are fetched and filtered unless a per-host endpoint is configured.
+func (h *HTTPDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error) {
+	cfg := h.Config
+
+	var all []*DatasourceRecord
+	var err error
+	if len(cfg.HTTP.HostURL) > 0 {
+		all, err = h.fetch(strings.ReplaceAll(cfg.HTTP.HostURL, varsourceHostPlaceholder, url.PathEscape(host)), true)
+	} else {
+		all, err = h.fetch(cfg.HTTP.URL, false)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "http datasource failure")
+	}
+
+	records := make([]*DatasourceRecord, 0)
+	for _, r := range all {
+		if strings.EqualFold(r.Hostname, host) {
+			records = append(records, r)
+		}
+	}
+
+	return records, nil
+}
+
+// PublishRecords is not supported: records are expected to be managed in the system behind the endpoint.
+func (h *HTTPDatasource) PublishRecords(records []*DatasourceRecord) error {
+	return errors.New("publishing records is not supported by the http datasource")
+}
+
+// Close closes idle connections to the records endpoint. The datasource remains usable.
+func (h *HTTPDatasource) Close() {
+	h.Client.CloseIdleConnections()
+}
+
+// redactURL removes user credentials and the query string from a URL, so that it can be used as a record source.
+func redactURL(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+
+	return u.String()
+}
+
+func makeHTTPTLSConfig(cfg *Config) (*tls.Config, error) {
+	var tlsCAPool *x509.CertPool
+	var tlsKeyPair tls.Certificate
+	var err error
+
+	if len(cfg.HTTP.TLS.CA.PEM) > 0 {
+		tlsCAPool, err = tlsCAPoolFromPEM(cfg.HTTP.TLS.CA.PEM)
+	} else if len(cfg.HTTP.TLS.CA.Path) > 0 {
+		tlsCAPool, err = tlsCAPoolFromFile(cfg.HTTP.TLS.CA.Path)
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "TLS configuration error")
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.HTTP.TLS.Insecure,
+		RootCAs:            tlsCAPool,
+	}
+
+	if len(cfg.HTTP.TLS.Certificate.PEM) > 0 && len(cfg.HTTP.TLS.Key.PEM) > 0 {
+		tlsKeyPair, err = tlsKeyPairFromPEM(cfg.HTTP.TLS.Certificate.PEM, cfg.HTTP.TLS.Key.PEM)
+	} else if len(cfg.HTTP.TLS.Certificate.Path) > 0 && len(cfg.HTTP.TLS.Key.Path) > 0 {
+		tlsKeyPair, err = tlsKeyPairFromFile(cfg.HTTP.TLS.Certificate.Path, cfg.HTTP.TLS.Key.Path)
+	} else {
+		return tlsConfig, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "TLS configuration error")
+	}
+	tlsConfig.Certificates = []tls.Certificate{tlsKeyPair}
+
+	return tlsConfig, nil
+}
+
+// NewHTTPDatasource creates an HTTP datasource.
+func NewHTTPDatasource(cfg *Config, log Logger) (*HTTPDatasource, error) {
+	if len(cfg.HTTP.URL) == 0 {
+		return nil, errors.New("http datasource initialization failure: URL is not set")
+	}
+
+	switch strings.ToLower(cfg.HTTP.Format) {
+	case "auto", "json", "yaml":
+	default:
+		return nil, errors.Errorf("http datasource initialization failure: unknown response format: %s", cfg.HTTP.Format)
+	}
+
+	tlsConfig, err := makeHTTPTLSConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "http datasource initialization failure")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &HTTPDatasource{
+		Config: cfg,
+		Logger: log,
+		Client: &http.Client{Transport: transport, Timeout: cfg.HTTP.Timeout},
+	}, nil
+}
@@ -0,0 +1,218 @@
+package inventory
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// serveHTTPRecords emulates a CMDB API serving host records as JSON and YAML.
+// Requests without the 'test-token' bearer token or the 'X-Inventory: test' header are rejected. The server requires a client certificate if mtls is set.
+func serveHTTPRecords(t *testing.T, mtls bool) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" || r.Header.Get("X-Inventory") != "test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/hosts.json":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			fmt.Fprint(w, `[{"hostname":"APP01.infra.local.","attributes":"OS=linux;ENV=dev;ROLE=app;SRV=tomcat"},{"hostname":"db01.infra.local","attributes":"OS=linux;ENV=dev;ROLE=db;SRV=postgres","source":"cmdb/ci/42"},{"attributes":"OS=linux;ENV=dev;ROLE=lost;SRV="}]`)
+		case "/hosts.yaml":
+			w.Header().Set("Content-Type", "application/yaml")
+			fmt.Fprint(w, "- hostname: app01.infra.local\n  attributes: OS=linux;ENV=dev;ROLE=app;SRV=tomcat\n- hostname: app01.infra.local\n  attributes: OS=linux;ENV=dev;ROLE=web;SRV=nginx\n")
+		case "/hosts/db01.infra.local":
+			fmt.Fprint(w, `[{"hostname":"db01.infra.local","attributes":"OS=linux;ENV=dev;ROLE=db;SRV=postgres"}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	// Rejected handshakes are expected.
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+
+	if mtls {
+		server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+		server.StartTLS()
+	} else {
+		server.Start()
+	}
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestHTTPDatasource_GetAllRecords(t *testing.T) {
+	plain := serveHTTPRecords(t, false)
+	secure := serveHTTPRecords(t, true)
+	cert, key := newTestClientKeyPair(t)
+	ca := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: secure.Certificate().Raw}))
+
+	tests := []struct {
+		name    string
+		url     string
+		format  string
+		token   string
+		mtls    bool
+		want    []string
+		wantErr bool
+		initErr bool
+	}{
+		{
+			// Records without a hostname are skipped, the URL is used as the source if the record has none.
+			name:   "valid-json",
+			url:    plain.URL + "/hosts.json?limit=0",
+			format: "auto",
+			token:  "test-token",
+			want: []string{
+				"app01.infra.local OS=linux;ENV=dev;ROLE=app;SRV=tomcat " + plain.URL + "/hosts.json",
+				"db01.infra.local OS=linux;ENV=dev;ROLE=db;SRV=postgres cmdb/ci/42",
+			},
+		},
+		{
+			name:   "valid-yaml",
+			url:    plain.URL + "/hosts.yaml",
+			format: "yaml",
+			token:  "test-token",
+			want: []string{
+				"app01.infra.local OS=linux;ENV=dev;ROLE=app;SRV=tomcat " + plain.URL + "/hosts.yaml",
+				"app01.infra.local OS=linux;ENV=dev;ROLE=web;SRV=nginx " + plain.URL + "/hosts.yaml",
+			},
+		},
+		{
+			name:   "valid-mtls",
+			url:    secure.URL + "/hosts.yaml",
+			format: "auto",
+			token:  "test-token",
+			mtls:   true,
+			want: []string{
+				"app01.infra.local OS=linux;ENV=dev;ROLE=app;SRV=tomcat " + secure.URL + "/hosts.yaml",
+				"app01.infra.local OS=linux;ENV=dev;ROLE=web;SRV=nginx " + secure.URL + "/hosts.yaml",
+			},
+		},
+		{
+			name:    "invalid-yaml-as-json",
+			url:     plain.URL + "/hosts.yaml",
+			format:  "json",
+			token:   "test-token",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-token",
+			url:     plain.URL + "/hosts.json",
+			format:  "auto",
+			token:   "invalid-token",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-client-certificate",
+			url:     secure.URL + "/hosts.json",
+			format:  "auto",
+			token:   "test-token",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-format",
+			url:     plain.URL + "/hosts.json",
+			format:  "xml",
+			initErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.HTTP.URL = tt.url
+			cfg.HTTP.Format = tt.format
+			cfg.HTTP.Token = tt.token
+			cfg.HTTP.Headers = map[string]string{"x-inventory": "test"}
+			cfg.HTTP.Timeout = 5 * time.Second
+			cfg.HTTP.TLS.CA.PEM = ca
+			if tt.mtls {
+				cfg.HTTP.TLS.Certificate.PEM = cert
+				cfg.HTTP.TLS.Key.PEM = key
+			}
+
+			h, err := NewHTTPDatasource(cfg, &testLogger{})
+			if (err != nil) != tt.initErr {
+				t.Fatalf("NewHTTPDatasource() error = %v, initErr %v", err, tt.initErr)
+			}
+			if err != nil {
+				return
+			}
+			defer h.Close()
+
+			records, err := h.GetAllRecords()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HTTPDatasource.GetAllRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			got := make([]string, 0, len(records))
+			for _, r := range records {
+				got = append(got, r.Hostname+" "+r.Attributes+" "+r.Source)
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("HTTPDatasource.GetAllRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPDatasource_GetHostRecords(t *testing.T) {
+	server := serveHTTPRecords(t, false)
+
+	tests := []struct {
+		name    string
+		hostURL string
+		host    string
+		want    int
+	}{
+		{name: "valid-filtered", host: "app01.infra.local", want: 1},
+		{name: "valid-host-url", hostURL: server.URL + "/hosts/{host}", host: "db01.infra.local", want: 1},
+		{name: "valid-host-url-missing", hostURL: server.URL + "/hosts/{host}", host: "app01.infra.local", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.HTTP.URL = server.URL + "/hosts.json"
+			cfg.HTTP.HostURL = tt.hostURL
+			cfg.HTTP.Format = "auto"
+			cfg.HTTP.Token = "test-token"
+			cfg.HTTP.Headers = map[string]string{"X-Inventory": "test"}
+			cfg.HTTP.Timeout = 5 * time.Second
+
+			h, err := NewHTTPDatasource(cfg, &testLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close()
+
+			records, err := h.GetHostRecords(tt.host)
+			if err != nil {
+				t.Fatalf("HTTPDatasource.GetHostRecords() error = %v", err)
+			}
+
+			if len(records) != tt.want {
+				t.Errorf("HTTPDatasource.GetHostRecords() returned %d records, want %d", len(records), tt.want)
+			}
+			for _, r := range records {
+				if r.Hostname != tt.host {
+					t.Errorf("HTTPDatasource.GetHostRecords() returned a record of %s", r.Hostname)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package inventory
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// readImportState reads the names of hosts that have been imported by a previous run from a state file.
+func readImportState(path string) (map[string]bool, error) {
+	done := make(map[string]bool)
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return done, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "state file read failure")
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if host := strings.TrimSpace(scanner.Text()); len(host) > 0 {
+			done[host] = true
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "state file read failure")
+	}
+
+	return done, nil
+}
+
+// writeImportState appends the names of imported hosts to a state file.
+func writeImportState(path string, hosts []string) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.Wrap(err, "state file write failure")
+	}
+	defer file.Close()
+
+	for _, host := range hosts {
+		if _, err := fmt.Fprintln(file, host); err != nil {
+			return errors.Wrap(err, "state file write failure")
+		}
+	}
+
+	return file.Sync()
+}
+
+// putRecords writes host records via an incremental datasource, retrying failed attempts.
+func (i *Inventory) putRecords(ds IncrementalDatasource, records []*DatasourceRecord) error {
+	cfg := i.Config
+	attempts := max(cfg.Import.Attempts, 1)
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = ds.PutRecords(records); err == nil {
+			return nil
+		}
+
+		if attempt < attempts {
+			i.Logger.Warnf("publishing attempt %d/%d failed: %v", attempt, attempts, err)
+			time.Sleep(cfg.Import.Backoff)
+		}
+	}
+
+	return err
+}
+
+// PublishHostsBulk writes host records to the datasource in batches, reporting progress and results per host.
+// A batch that fails after all attempts is retried host by host, so a single bad host does not abort the whole import.
+// If a state file path is given, imported hosts are recorded there and skipped when the import is restarted.
+// The state file is removed once all hosts have been imported.
+func (i *Inventory) PublishHostsBulk(hosts map[string][]*HostAttributes, state string) (*ImportReport, error) {
+	cfg := i.Config
+	log := i.Logger
+
+	report := &ImportReport{Failed: make(map[string]string)}
+
+	done := make(map[string]bool)
+	if len(state) > 0 {
+		var err error
+		if done, err = readImportState(state); err != nil {
+			return nil, err
+		}
+	}
+	resuming := len(done) > 0
+
+	// Render host records.
+	names := make([]string, 0, len(hosts))
+	records := make(map[string][]*DatasourceRecord)
+	all := make([]*DatasourceRecord, 0)
+
+	for hostname, attrsList := range hosts {
+		report.Hosts++
+
+		if done[hostname] {
+			report.Resumed++
+			continue
+		}
+
+		hostRecords := make([]*DatasourceRecord, 0, len(attrsList))
+		for _, attrs := range attrsList {
+			if match, err := i.filterHost(hostname, attrs); err != nil {
+				return nil, errors.Wrap(err, "filter processing failure")
+			} else if !match {
+				log.Warnf(warnFilteredRecord, hostname)
+				continue
+			}
+
+			attrString, err := i.RenderAttributes(attrs)
+			if err != nil {
+				report.Failed[hostname] = err.Error()
+				break
+			}
+
+			hostRecords = append(hostRecords, &DatasourceRecord{Hostname: hostname, Attributes: attrString})
+		}
+
+		if _, ok := report.Failed[hostname]; ok || len(hostRecords) == 0 {
+			continue
+		}
+
+		names = append(names, hostname)
+		records[hostname] = hostRecords
+		all = append(all, hostRecords...)
+	}
+
+	sort.Strings(names)
+
+	// Drop hosts whose records would be truncated or rejected by DNS.
+	if cfg.Import.Lint {
+		names, all = i.lintImport(names, records, report)
+	}
+
+	if resuming {
+		log.Infof("resuming import: %d of %d hosts have already been imported", report.Resumed, report.Hosts)
+	}
+
+	ds, ok := i.Datasource.(IncrementalDatasource)
+	if !ok {
+		log.Warnf("datasource %s does not support incremental publishing, importing all hosts at once", cfg.Datasource)
+
+		if err := i.publish("import", all); err != nil {
+			for _, name := range names {
+				report.Failed[name] = err.Error()
+			}
+		} else {
+			report.Published = len(names)
+		}
+
+		return report, i.finishImport(report, state)
+	}
+
+	// Per-host failures are reported to post-publish hooks, but do not abort the import.
+	var failed error
+	err := i.publishWith("import", all, func() error {
+		if resuming {
+			log.Info("not clearing existing host records when resuming an import")
+		} else if err := ds.ClearRecords(); err != nil {
+			return errors.Wrap(err, "datasource clearing failure")
+		}
+
+		batch := cfg.Import.Batch
+		if batch <= 0 {
+			batch = len(names)
+		}
+
+		start := time.Now()
+		for n := 0; n < len(names); n += batch {
+			chunk := names[n:min(n+batch, len(names))]
+
+			chunkRecords := make([]*DatasourceRecord, 0)
+			for _, name := range chunk {
+				chunkRecords = append(chunkRecords, records[name]...)
+			}
+
+			published := chunk
+			if err := i.putRecords(ds, chunkRecords); err != nil {
+				log.Warnf("batch publishing failure, publishing hosts one by one: %v", err)
+
+				published = make([]string, 0, len(chunk))
+				for _, name := range chunk {
+					if err := ds.PutRecords(records[name]); err != nil {
+						report.Failed[name] = err.Error()
+						continue
+					}
+
+					published = append(published, name)
+				}
+			}
+
+			report.Published += len(published)
+
+			if len(state) > 0 {
+				if err := writeImportState(state, published); err != nil {
+					return err
+				}
+			}
+
+			log.Infof("import progress: %d/%d hosts processed", n+len(chunk), len(names))
+
+			// Stay within the configured rate by sleeping until the processed hosts are due.
+			if cfg.Import.Rate > 0 {
+				due := time.Duration(float64(n+len(chunk)) / cfg.Import.Rate * float64(time.Second))
+				if wait := due - time.Since(start); wait > 0 {
+					time.Sleep(wait)
+				}
+			}
+		}
+
+		if len(report.Failed) > 0 {
+			failed = errors.Errorf("%d hosts could not be imported", len(report.Failed))
+		}
+
+		return failed
+	})
+	if err != nil && err != failed {
+		return report, err
+	}
+
+	return report, i.finishImport(report, state)
+}
+
+// lintImport checks rendered host records with LintRecords and reports hosts with lint errors as failed.
+// The remaining hosts and their records are returned.
+func (i *Inventory) lintImport(names []string, records map[string][]*DatasourceRecord, report *ImportReport) ([]string, []*DatasourceRecord) {
+	log := i.Logger
+
+	all := make([]*DatasourceRecord, 0)
+	for _, name := range names {
+		all = append(all, records[name]...)
+	}
+
+	// Size warnings are shared by all hosts published under the same name, so every message is only logged once.
+	warned := make(map[string]bool)
+	for _, p := range i.LintRecords(all) {
+		if p.Severity != lintError {
+			if !warned[p.Message] {
+				log.Warnf("[%s] %s", p.Host, p.Message)
+				warned[p.Message] = true
+			}
+			continue
+		}
+
+		if _, ok := report.Failed[p.Host]; !ok {
+			report.Failed[p.Host] = "lint failure: " + p.Message
+		}
+	}
+
+	kept := make([]string, 0, len(names))
+	all = all[:0]
+	for _, name := range names {
+		if _, ok := report.Failed[name]; ok {
+			continue
+		}
+
+		kept = append(kept, name)
+		all = append(all, records[name]...)
+	}
+
+	return kept, all
+}
+
+// finishImport logs the results of a bulk import and removes the state file if all hosts have been imported.
+func (i *Inventory) finishImport(report *ImportReport, state string) error {
+	log := i.Logger
+
+	for host, err := range report.Failed {
+		log.Warnf("[%s] host import failure: %s", host, err)
+	}
+
+	log.Infof("import finished: %d hosts, %d published, %d resumed, %d failed", report.Hosts, report.Published, report.Resumed, len(report.Failed))
+
+	if len(report.Failed) > 0 {
+		return errors.Errorf("%d hosts could not be imported", len(report.Failed))
+	}
+
+	if len(state) > 0 {
+		if err := os.Remove(state); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "state file removal failure")
+		}
+	}
+
+	return nil
+}
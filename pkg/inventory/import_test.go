@@ -0,0 +1,64 @@
+package inventory
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_importState(t *testing.T) {
+	type args struct {
+		batches [][]string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    map[string]bool
+		wantErr bool
+	}{
+		{
+			name: "missing",
+			args: args{
+				batches: [][]string{},
+			},
+			want:    map[string]bool{},
+			wantErr: false,
+		},
+		{
+			name: "several-batches",
+			args: args{
+				batches: [][]string{
+					{"app01.infra.local", "app02.infra.local"},
+					{},
+					{"db01.infra.local"},
+				},
+			},
+			want: map[string]bool{
+				"app01.infra.local": true,
+				"app02.infra.local": true,
+				"db01.infra.local":  true,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "import.state")
+
+			for _, batch := range tt.args.batches {
+				if err := writeImportState(path, batch); err != nil {
+					t.Fatalf("writeImportState() error = %v", err)
+				}
+			}
+
+			got, err := readImportState(path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("readImportState() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readImportState() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"encoding/json"
 	"reflect"
 	"regexp"
-	"slices"
 	"strings"
+	"time"
 	"unsafe"
 
 	"github.com/creasty/defaults"
@@ -20,6 +20,9 @@ import (
 const (
 	adiSafeListRegexString              = "^[A-Za-z0-9\\,]*$"
 	adiSafeListWithSeparatorRegexString = "^[A-Za-z0-9\\,\\-\\_]*$"
+
+	// Host variable containing the unique host identifier.
+	hostIDVar string = "inventory_id"
 )
 
 var (
@@ -49,6 +52,14 @@ func (a *HostAttributes) MarshalJSON() ([]byte, error) {
 	attrs[adiHostAttributeNames["SRV"]] = a.Srv
 	attrs[adiHostAttributeNames["VARS"]] = a.Vars
 
+	if len(a.ID) > 0 {
+		attrs[adiHostAttributeNames["ID"]] = a.ID
+	}
+
+	for key, value := range a.Extra {
+		attrs[key] = value
+	}
+
 	return json.Marshal(attrs)
 }
 
@@ -62,6 +73,14 @@ func (a *HostAttributes) MarshalYAML() (interface{}, error) {
 	attrs[adiHostAttributeNames["SRV"]] = a.Srv
 	attrs[adiHostAttributeNames["VARS"]] = a.Vars
 
+	if len(a.ID) > 0 {
+		attrs[adiHostAttributeNames["ID"]] = a.ID
+	}
+
+	for key, value := range a.Extra {
+		attrs[key] = value
+	}
+
 	return attrs, nil
 }
 
@@ -88,6 +107,8 @@ func (a *HostAttributes) UnmarshalYAML(n *yaml.Node) error {
 			fields[i].Tag = reflect.StructTag(`yaml:"` + adiHostAttributeNames["SRV"] + `"`)
 		case "Vars":
 			fields[i].Tag = reflect.StructTag(`yaml:"` + adiHostAttributeNames["VARS"] + `"`)
+		case "ID":
+			fields[i].Tag = reflect.StructTag(`yaml:"` + adiHostAttributeNames["ID"] + `"`)
 		}
 	}
 
@@ -96,88 +117,58 @@ func (a *HostAttributes) UnmarshalYAML(n *yaml.Node) error {
 	return n.Decode(value.Addr().Interface())
 }
 
-// filterHost evaluates host record filters specified in the configuration and determines if a record should be processed by the inventory.
-func (i *Inventory) filterHost(host string, attrs *HostAttributes) (bool, error) {
-	cfg := i.Config
-
-	if !cfg.Filter.Enabled {
-		return true, nil
+// ImportHosts loads a map of hosts and their attributes into the inventory tree and applies the tree transforms.
+func (i *Inventory) ImportHosts(hosts map[string][]*HostAttributes) error {
+	axes, err := i.groupAxes()
+	if err != nil {
+		return err
 	}
 
-	for _, filter := range cfg.Filter.Filters {
-		var value string
+	i.Tree.Compare = i.Collation
+	i.Tree.ImportHosts(hosts, i.Config.Txt.Keys.Separator, axes)
+	i.importVirtualGroups(hosts)
+	i.importGroupVars()
 
-		switch filter.Key {
-		case "host":
-			value = host
-		case adiHostAttributeNames["OS"]:
-			value = attrs.OS
-		case adiHostAttributeNames["ENV"]:
-			value = attrs.Env
-		case adiHostAttributeNames["ROLE"]:
-			value = attrs.Role
-		case adiHostAttributeNames["SRV"]:
-			value = attrs.Srv
-		default:
-			return false, errors.Errorf("unknown key: %s", filter.Key)
-		}
+	if err := i.transformTree(); err != nil {
+		return err
+	}
 
-		switch strings.ToLower(filter.Operator) {
-		case "in":
-			if slices.Contains(filter.Values, value) {
-				continue
-			} else {
-				return false, nil
-			}
-		case "notin":
-			if !slices.Contains(filter.Values, value) {
-				continue
-			} else {
-				return false, nil
-			}
-		case "regex":
-			var match bool
-
-			for _, exp := range filter.Values {
-				regex := regexp.MustCompile(exp)
-				if regex.MatchString(value) {
-					match = true
-					break
-				}
-			}
+	i.Tree.OrderChildren(i.Config.Order)
 
-			if match {
-				continue
-			} else {
-				return false, nil
-			}
-		case "notregex":
-			var match bool
-
-			for _, exp := range filter.Values {
-				regex := regexp.MustCompile(exp)
-				if regex.MatchString(value) {
-					match = true
-					break
-				}
-			}
+	if err := i.checkThresholds(hosts); err != nil {
+		return err
+	}
 
-			if !match {
-				continue
-			} else {
-				return false, nil
-			}
-		default:
-			return false, errors.Errorf("unknown operator: %s", filter.Operator)
+	if i.Config.Metadata.Enabled && i.Metadata != nil {
+		if i.Tree.Vars == nil {
+			i.Tree.Vars = make(map[string]interface{})
 		}
+
+		i.Tree.Vars[i.Config.Metadata.Var] = i.Metadata
 	}
 
-	return true, nil
+	return nil
 }
 
-// ImportHosts loads a map of hosts and their attributes into the inventory tree.
-func (i *Inventory) ImportHosts(hosts map[string][]*HostAttributes) {
-	i.Tree.ImportHosts(hosts, i.Config.Txt.Keys.Separator)
+// importGroupVars merges group variables acquired from the datasource into the inventory tree.
+func (i *Inventory) importGroupVars() {
+	log := i.Logger
+
+	for group, vars := range i.GroupVars {
+		node := i.Tree.FindChild(group)
+		if node == nil {
+			log.Debugf("[%s] skipping variables of an unknown group", group)
+			continue
+		}
+
+		if node.Vars == nil {
+			node.Vars = make(map[string]interface{})
+		}
+
+		for name, value := range vars {
+			node.Vars[name] = value
+		}
+	}
 }
 
 // ExportHosts exports the inventory tree into a map of hosts and groups they belong to.
@@ -195,80 +186,216 @@ func (i *Inventory) ExportInventory(inventory map[string]*AnsibleGroup) {
 	i.Tree.ExportInventory(inventory)
 }
 
-// GetHostVariables acquires a map of host variables specified via the 'VARS' attribute.
+// ExportValues exports the inventory tree into a nested map of groups, their children and hosts.
+func (i *Inventory) ExportValues(values map[string]interface{}) {
+	i.Tree.ExportValues(values)
+}
+
+// GetHostVariables acquires a map of host variables specified via the 'VARS' attribute and secondary variable sources.
 func (i *Inventory) GetHostVariables(host string) (map[string]string, error) {
 	cfg := i.Config
 	log := i.Logger
 	variables := make(map[string]string)
 
-	records, err := i.Datasource.GetHostRecords(host)
-	if err != nil {
+	records, err := i.lookupHostRecords(host)
+	if err != nil && isNoHostRecords(err) {
 		return nil, errors.Wrap(err, "host record loading failure")
+	} else if err != nil {
+		return nil, &DatasourceError{Err: errors.Wrap(err, "host record loading failure")}
 	}
 
 	for _, r := range records {
 		attrs, err := i.ParseAttributes(r.Attributes)
 		if err != nil {
-			log.Warnf("[%s] skipping host record: %v", r.Hostname, err)
+			log.Warnf(warnSkippedRecord, r.Hostname, err)
 			continue
 		}
 
-		if len(attrs.Vars) > 0 {
-			pairs := strings.Split(attrs.Vars, cfg.Txt.Vars.Separator)
-			for _, p := range pairs {
-				kv := strings.Split(p, cfg.Txt.Vars.Equalsign)
-				if len(kv) == 2 {
-					variables[kv[0]] = kv[1]
-				}
-			}
+		if cfg.Txt.Vars.Enabled {
+			parseVariables(attrs.Vars, cfg.Txt.Vars.Separator, cfg.Txt.Vars.Equalsign, variables)
+		}
+
+		if len(attrs.ID) > 0 {
+			variables[hostIDVar] = attrs.ID
+		}
+	}
+
+	// Merge variables from secondary sources, later sources take precedence.
+	for _, v := range i.Varsources {
+		vars, err := v.GetHostVariables(host)
+		if err != nil {
+			log.Warnf(warnSkippedVarsource, host, err)
+			continue
+		}
+
+		for key, value := range vars {
+			variables[key] = value
 		}
 	}
 
 	return variables, nil
 }
 
+// FailedZones returns the zones the datasource has skipped when host records were last acquired.
+func (i *Inventory) FailedZones() []string {
+	if ds, ok := i.Datasource.(PartialDatasource); ok {
+		return ds.FailedZones()
+	}
+
+	return nil
+}
+
 // GetHosts acquires a map of all hosts and their attributes.
 func (i *Inventory) GetHosts() (map[string][]*HostAttributes, error) {
-	log := i.Logger
 	hosts := make(map[string][]*HostAttributes)
 
-	records, err := i.Datasource.GetAllRecords()
+	// The datasource serving the host records: the primary one, a fallback datasource or none if the host record cache is used.
+	ds := i.Datasource
+	fallback := ""
+
+	records, err := ds.GetAllRecords()
 	if err != nil {
-		return nil, errors.Wrap(err, "record loading failure")
+		records, ds, fallback, err = i.fallbackRecords(err)
+		if err != nil {
+			return nil, &DatasourceError{Err: errors.Wrap(err, "record loading failure")}
+		}
+	}
+
+	// Records acquired from a fallback don't replace the host record cache.
+	if len(i.Config.Host.Cache.Path) > 0 && len(fallback) == 0 {
+		i.updateHostCache(records)
+	}
+
+	// Pinned hosts may belong to other shards in sharded runs and are checked when partial exports are merged.
+	if i.Config.DNS.Shard.Count <= 1 {
+		if records, err = i.pinRecords(records); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := i.collectHosts(records, hosts); err != nil {
+		return nil, err
+	}
+
+	if gv, ok := ds.(GroupVarsDatasource); ok {
+		if i.GroupVars, err = gv.GetGroupVariables(); err != nil {
+			return nil, errors.Wrap(err, "group variables loading failure")
+		}
+	}
+
+	i.Metadata = i.describe(len(records), len(hosts))
+
+	if len(fallback) > 0 {
+		i.Metadata.Fallback = fallback
+		i.Metadata.Source = nil
+
+		if described, ok := ds.(DescribedDatasource); ok {
+			i.Metadata.Source = described.Metadata()
+		}
 	}
 
+	return hosts, nil
+}
+
+// collectHosts parses, normalizes and filters host records, adding the host attributes to a map of hosts.
+func (i *Inventory) collectHosts(records []*DatasourceRecord, hosts map[string][]*HostAttributes) error {
+	log := i.Logger
+	normalize := i.attributeNormalizer()
+
+	// Hostnames by unique host identifier.
+	ids := make(map[string]string)
+
 	for _, r := range records {
 		attrs, err := i.ParseAttributes(r.Attributes)
 		if err != nil {
-			log.Warnf("[%s] skipping host record: %v", r.Hostname, err)
+			log.Warnf(warnSkippedRecord, r.Hostname, err)
 			continue
 		}
 
+		if normalize != nil {
+			normalize(attrs)
+		}
+
 		if match, err := i.filterHost(r.Hostname, attrs); err != nil {
-			return nil, errors.Wrap(err, "filter processing failure")
+			return errors.Wrap(err, "filter processing failure")
 		} else if !match {
-			log.Warnf("[%s] skipping filtered host record", r.Hostname)
+			log.Warnf(warnFilteredRecord, r.Hostname)
 			continue
 		}
 
-		for _, role := range strings.Split(attrs.Role, ",") {
-			for _, srv := range strings.Split(attrs.Srv, ",") {
-				hosts[r.Hostname] = append(hosts[r.Hostname], &HostAttributes{
-					OS:   attrs.OS,
-					Env:  attrs.Env,
-					Role: role,
-					Srv:  srv,
-					Vars: attrs.Vars,
-				})
+		if match, err := matchFilters(r.Hostname, attrs, i.Filters); err != nil {
+			return errors.Wrap(err, "filter processing failure")
+		} else if !match {
+			log.Debugf("[%s] skipping host record not matching runtime filters", r.Hostname)
+			continue
+		}
+
+		if len(attrs.ID) > 0 {
+			id := strings.ToLower(attrs.ID)
+			if owner, ok := ids[id]; ok && owner != r.Hostname {
+				log.Warnf(warnDuplicateHostID, r.Hostname, attrs.ID, owner)
+			} else if !ok {
+				ids[id] = r.Hostname
 			}
 		}
+
+		hosts[r.Hostname] = append(hosts[r.Hostname], splitAttributes(attrs)...)
 	}
 
-	return hosts, nil
+	return nil
+}
+
+// describe produces the metadata of the host records that have just been acquired.
+func (i *Inventory) describe(records int, hosts int) *InventoryMetadata {
+	metadata := &InventoryMetadata{
+		Timestamp:  time.Now().UTC(),
+		Datasource: i.Config.Datasource,
+		Records:    records,
+		Hosts:      hosts,
+	}
+
+	if ds, ok := i.Datasource.(DescribedDatasource); ok {
+		metadata.Source = ds.Metadata()
+	}
+
+	return metadata
 }
 
-// ParseAttributes parses host attributes.
+// splitAttributes produces a set of host attributes for every role and service listed in a host record.
+func splitAttributes(attrs *HostAttributes) []*HostAttributes {
+	sets := make([]*HostAttributes, 0)
+
+	for _, role := range strings.Split(attrs.Role, ",") {
+		for _, srv := range strings.Split(attrs.Srv, ",") {
+			sets = append(sets, &HostAttributes{
+				OS:    attrs.OS,
+				Env:   attrs.Env,
+				Role:  role,
+				Srv:   srv,
+				Vars:  attrs.Vars,
+				ID:    attrs.ID,
+				Extra: attrs.Extra,
+			})
+		}
+	}
+
+	return sets
+}
+
+// ParseAttributes parses and validates host attributes, applying role defaults to the attributes missing from the host record.
 func (i *Inventory) ParseAttributes(raw string) (*HostAttributes, error) {
+	attrs := i.decodeAttributes(raw)
+	i.applyRoleDefaults(attrs)
+
+	if err := i.Validator.Struct(attrs); err != nil {
+		return nil, errors.Wrap(err, "attribute validation error")
+	}
+
+	return attrs, nil
+}
+
+// decodeAttributes parses host attributes as they are stored in the host record, without role defaults and validation.
+func (i *Inventory) decodeAttributes(raw string) *HostAttributes {
 	cfg := i.Config
 	attrs := &HostAttributes{}
 	items := strings.Split(raw, cfg.Txt.Kv.Separator)
@@ -286,14 +413,21 @@ func (i *Inventory) ParseAttributes(raw string) (*HostAttributes, error) {
 			attrs.Srv = kv[1]
 		case cfg.Txt.Keys.Vars:
 			attrs.Vars = kv[1]
+		case cfg.Txt.Keys.ID:
+			attrs.ID = kv[1]
+		default:
+			for _, key := range cfg.Txt.Keys.Extra {
+				if kv[0] == key {
+					if attrs.Extra == nil {
+						attrs.Extra = make(map[string]string)
+					}
+					attrs.Extra[key] = kv[1]
+				}
+			}
 		}
 	}
 
-	if err := i.Validator.Struct(attrs); err != nil {
-		return nil, errors.Wrap(err, "attribute validation error")
-	}
-
-	return attrs, nil
+	return attrs
 }
 
 // RenderAttributes constructs a string representation of the HostAttributes struct.
@@ -308,6 +442,18 @@ func (i *Inventory) RenderAttributes(attributes *HostAttributes) (string, error)
 
 	attrs := [][]string{{cfg.Txt.Keys.Os, attributes.OS}, {cfg.Txt.Keys.Env, attributes.Env}, {cfg.Txt.Keys.Role, attributes.Role}, {cfg.Txt.Keys.Srv, attributes.Srv}, {cfg.Txt.Keys.Vars, attributes.Vars}}
 
+	// Extra attributes are optional and rendered in the configured order.
+	for _, key := range cfg.Txt.Keys.Extra {
+		if value, ok := attributes.Extra[key]; ok {
+			attrs = append(attrs, []string{key, value})
+		}
+	}
+
+	// The host identifier is optional.
+	if len(attributes.ID) > 0 {
+		attrs = append(attrs, []string{cfg.Txt.Keys.ID, attributes.ID})
+	}
+
 	for i, attr := range attrs {
 		attrString.WriteString(attr[0])
 		attrString.WriteString(cfg.Txt.Kv.Equalsign)
@@ -332,7 +478,7 @@ func (i *Inventory) PublishHosts(hosts map[string][]*HostAttributes) error {
 			if match, err := i.filterHost(hostname, attrs); err != nil {
 				return errors.Wrap(err, "filter processing failure")
 			} else if !match {
-				log.Warnf("[%s] skipping filtered host record", hostname)
+				log.Warnf(warnFilteredRecord, hostname)
 				continue
 			}
 
@@ -342,24 +488,44 @@ func (i *Inventory) PublishHosts(hosts map[string][]*HostAttributes) error {
 					Attributes: attrString,
 				})
 			} else {
-				log.Warnf("[%s] skipping host record: %v", hostname, err)
+				log.Warnf(warnSkippedRecord, hostname, err)
 				continue
 			}
 		}
 	}
 
-	return i.Datasource.PublishRecords(records)
+	return i.publish("import", records)
 }
 
-// New creates an instance of the DNS inventory with user-supplied configuration.
-func New(cfg *Config, log Logger) (*Inventory, error) {
-	// Setup package global state
+// Close closes the inventory datasource and variable sources.
+func (i *Inventory) Close() {
+	i.Datasource.Close()
+	i.closeFallbacks()
+
+	for _, v := range i.Varsources {
+		v.Close()
+	}
+
+	if i.Audit != nil {
+		i.Audit.Close()
+	}
+}
+
+// setAttributeNames sets up the host attribute key names used by the attribute marshallers and the inventory tree.
+func setAttributeNames(cfg *Config) {
 	adiHostAttributeNames = make(map[string]string)
 	adiHostAttributeNames["OS"] = cfg.Txt.Keys.Os
 	adiHostAttributeNames["ENV"] = cfg.Txt.Keys.Env
 	adiHostAttributeNames["ROLE"] = cfg.Txt.Keys.Role
 	adiHostAttributeNames["SRV"] = cfg.Txt.Keys.Srv
 	adiHostAttributeNames["VARS"] = cfg.Txt.Keys.Vars
+	adiHostAttributeNames["ID"] = cfg.Txt.Keys.ID
+}
+
+// New creates an instance of the DNS inventory with user-supplied configuration.
+func New(cfg *Config, log Logger) (*Inventory, error) {
+	// Setup package global state
+	setAttributeNames(cfg)
 
 	// Initialize logger.
 	if log == nil {
@@ -371,12 +537,47 @@ func New(cfg *Config, log Logger) (*Inventory, error) {
 		log.Warn("no custom logger passed to inventory.New(), using defaults")
 	}
 
+	log.Debugf("ansible-dns-inventory %s", Version())
+
+	// Initialize host and group name collation.
+	collation, err := NewCollation(cfg.Sort.Collation, cfg.Sort.Numeric)
+	if err != nil {
+		return nil, errors.Wrap(err, "collation initialization failure")
+	}
+
 	// Initialize datasource.
 	ds, err := NewDatasource(cfg, log)
 	if err != nil {
 		return nil, errors.Wrap(err, "datasource initialization failure")
 	}
 
+	// Initialize variable sources.
+	vs, err := NewVarsources(cfg)
+	if err != nil {
+		ds.Close()
+		return nil, errors.Wrap(err, "variable source initialization failure")
+	}
+
+	// Initialize notification sinks.
+	ns, err := NewNotifiers(cfg)
+	if err != nil {
+		ds.Close()
+		for _, v := range vs {
+			v.Close()
+		}
+		return nil, errors.Wrap(err, "notification sink initialization failure")
+	}
+
+	// Initialize the audit log.
+	audit, err := NewAuditLog(cfg)
+	if err != nil {
+		ds.Close()
+		for _, v := range vs {
+			v.Close()
+		}
+		return nil, errors.Wrap(err, "audit log initialization failure")
+	}
+
 	// Initialize struct validator.
 	val := validator.New()
 	val.RegisterValidation("notblank", validators.NotBlank)
@@ -389,9 +590,31 @@ func New(cfg *Config, log Logger) (*Inventory, error) {
 		Validator: val,
 
 		Datasource: ds,
+		Varsources: vs,
+		Notifiers:  ns,
+		Audit:      audit,
+		Collation:  collation,
 		Tree:       NewTree(),
 	}
 
+	// Validate group axes.
+	if _, err := inventory.groupAxes(); err != nil {
+		inventory.Close()
+		return nil, err
+	}
+
+	// Validate host variable redaction rules.
+	if err := inventory.checkRedaction(); err != nil {
+		inventory.Close()
+		return nil, err
+	}
+
+	// Parse virtual group expressions.
+	if inventory.VirtualGroups, err = inventory.ParseVirtualGroups(); err != nil {
+		inventory.Close()
+		return nil, err
+	}
+
 	return inventory, nil
 }
 
@@ -0,0 +1,484 @@
+// Wire format of exported inventory snapshots.
+// The Go implementation (inventory.pb.go) is generated from this schema with protoc-gen-go, see wire.go.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.33.0
+// 	protoc        (unknown)
+// source: pkg/inventory/inventory.proto
+
+package inventory
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// A set of host attributes.
+type WireAttributes struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Os   string `protobuf:"bytes,1,opt,name=os,proto3" json:"os,omitempty"`
+	Env  string `protobuf:"bytes,2,opt,name=env,proto3" json:"env,omitempty"`
+	Role string `protobuf:"bytes,3,opt,name=role,proto3" json:"role,omitempty"`
+	Srv  string `protobuf:"bytes,4,opt,name=srv,proto3" json:"srv,omitempty"`
+	Vars string `protobuf:"bytes,5,opt,name=vars,proto3" json:"vars,omitempty"`
+	Id   string `protobuf:"bytes,6,opt,name=id,proto3" json:"id,omitempty"`
+	// Extra attributes, keyed by attribute key.
+	Extra map[string]string `protobuf:"bytes,7,rep,name=extra,proto3" json:"extra,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (x *WireAttributes) Reset() {
+	*x = WireAttributes{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_inventory_inventory_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WireAttributes) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WireAttributes) ProtoMessage() {}
+
+func (x *WireAttributes) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_inventory_inventory_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WireAttributes.ProtoReflect.Descriptor instead.
+func (*WireAttributes) Descriptor() ([]byte, []int) {
+	return file_pkg_inventory_inventory_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *WireAttributes) GetOs() string {
+	if x != nil {
+		return x.Os
+	}
+	return ""
+}
+
+func (x *WireAttributes) GetEnv() string {
+	if x != nil {
+		return x.Env
+	}
+	return ""
+}
+
+func (x *WireAttributes) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+func (x *WireAttributes) GetSrv() string {
+	if x != nil {
+		return x.Srv
+	}
+	return ""
+}
+
+func (x *WireAttributes) GetVars() string {
+	if x != nil {
+		return x.Vars
+	}
+	return ""
+}
+
+func (x *WireAttributes) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *WireAttributes) GetExtra() map[string]string {
+	if x != nil {
+		return x.Extra
+	}
+	return nil
+}
+
+// A host, its attribute sets and the groups it belongs to.
+type WireHost struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name       string            `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Attributes []*WireAttributes `protobuf:"bytes,2,rep,name=attributes,proto3" json:"attributes,omitempty"`
+	Groups     []string          `protobuf:"bytes,3,rep,name=groups,proto3" json:"groups,omitempty"`
+}
+
+func (x *WireHost) Reset() {
+	*x = WireHost{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_inventory_inventory_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WireHost) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WireHost) ProtoMessage() {}
+
+func (x *WireHost) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_inventory_inventory_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WireHost.ProtoReflect.Descriptor instead.
+func (*WireHost) Descriptor() ([]byte, []int) {
+	return file_pkg_inventory_inventory_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *WireHost) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *WireHost) GetAttributes() []*WireAttributes {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+func (x *WireHost) GetGroups() []string {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+// A group, its children and the hosts that belong to it.
+type WireGroup struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name     string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Children []string `protobuf:"bytes,2,rep,name=children,proto3" json:"children,omitempty"`
+	Hosts    []string `protobuf:"bytes,3,rep,name=hosts,proto3" json:"hosts,omitempty"`
+	// JSON-encoded group variables.
+	Vars string `protobuf:"bytes,4,opt,name=vars,proto3" json:"vars,omitempty"`
+	// Position of the group among its siblings.
+	Order int32 `protobuf:"varint,5,opt,name=order,proto3" json:"order,omitempty"`
+}
+
+func (x *WireGroup) Reset() {
+	*x = WireGroup{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_inventory_inventory_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WireGroup) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WireGroup) ProtoMessage() {}
+
+func (x *WireGroup) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_inventory_inventory_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WireGroup.ProtoReflect.Descriptor instead.
+func (*WireGroup) Descriptor() ([]byte, []int) {
+	return file_pkg_inventory_inventory_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *WireGroup) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *WireGroup) GetChildren() []string {
+	if x != nil {
+		return x.Children
+	}
+	return nil
+}
+
+func (x *WireGroup) GetHosts() []string {
+	if x != nil {
+		return x.Hosts
+	}
+	return nil
+}
+
+func (x *WireGroup) GetVars() string {
+	if x != nil {
+		return x.Vars
+	}
+	return ""
+}
+
+func (x *WireGroup) GetOrder() int32 {
+	if x != nil {
+		return x.Order
+	}
+	return 0
+}
+
+// An inventory snapshot. Hosts and groups are sorted by name.
+type WireInventory struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Hosts  []*WireHost  `protobuf:"bytes,1,rep,name=hosts,proto3" json:"hosts,omitempty"`
+	Groups []*WireGroup `protobuf:"bytes,2,rep,name=groups,proto3" json:"groups,omitempty"`
+}
+
+func (x *WireInventory) Reset() {
+	*x = WireInventory{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_inventory_inventory_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WireInventory) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WireInventory) ProtoMessage() {}
+
+func (x *WireInventory) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_inventory_inventory_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WireInventory.ProtoReflect.Descriptor instead.
+func (*WireInventory) Descriptor() ([]byte, []int) {
+	return file_pkg_inventory_inventory_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *WireInventory) GetHosts() []*WireHost {
+	if x != nil {
+		return x.Hosts
+	}
+	return nil
+}
+
+func (x *WireInventory) GetGroups() []*WireGroup {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+var File_pkg_inventory_inventory_proto protoreflect.FileDescriptor
+
+var file_pkg_inventory_inventory_proto_rawDesc = []byte{
+	0x0a, 0x1d, 0x70, 0x6b, 0x67, 0x2f, 0x69, 0x6e, 0x76, 0x65, 0x6e, 0x74, 0x6f, 0x72, 0x79, 0x2f,
+	0x69, 0x6e, 0x76, 0x65, 0x6e, 0x74, 0x6f, 0x72, 0x79, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12,
+	0x18, 0x61, 0x6e, 0x73, 0x69, 0x62, 0x6c, 0x65, 0x5f, 0x64, 0x6e, 0x73, 0x5f, 0x69, 0x6e, 0x76,
+	0x65, 0x6e, 0x74, 0x6f, 0x72, 0x79, 0x2e, 0x76, 0x31, 0x22, 0x81, 0x02, 0x0a, 0x0e, 0x57, 0x69,
+	0x72, 0x65, 0x41, 0x74, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x73, 0x12, 0x0e, 0x0a, 0x02,
+	0x6f, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x6f, 0x73, 0x12, 0x10, 0x0a, 0x03,
+	0x65, 0x6e, 0x76, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x65, 0x6e, 0x76, 0x12, 0x12,
+	0x0a, 0x04, 0x72, 0x6f, 0x6c, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x6f,
+	0x6c, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x72, 0x76, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x03, 0x73, 0x72, 0x76, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x61, 0x72, 0x73, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x76, 0x61, 0x72, 0x73, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x49, 0x0a, 0x05, 0x65, 0x78, 0x74, 0x72,
+	0x61, 0x18, 0x07, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x33, 0x2e, 0x61, 0x6e, 0x73, 0x69, 0x62, 0x6c,
+	0x65, 0x5f, 0x64, 0x6e, 0x73, 0x5f, 0x69, 0x6e, 0x76, 0x65, 0x6e, 0x74, 0x6f, 0x72, 0x79, 0x2e,
+	0x76, 0x31, 0x2e, 0x57, 0x69, 0x72, 0x65, 0x41, 0x74, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65,
+	0x73, 0x2e, 0x45, 0x78, 0x74, 0x72, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x05, 0x65, 0x78,
+	0x74, 0x72, 0x61, 0x1a, 0x38, 0x0a, 0x0a, 0x45, 0x78, 0x74, 0x72, 0x61, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x80, 0x01,
+	0x0a, 0x08, 0x57, 0x69, 0x72, 0x65, 0x48, 0x6f, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61,
+	0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x48,
+	0x0a, 0x0a, 0x61, 0x74, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x28, 0x2e, 0x61, 0x6e, 0x73, 0x69, 0x62, 0x6c, 0x65, 0x5f, 0x64, 0x6e, 0x73,
+	0x5f, 0x69, 0x6e, 0x76, 0x65, 0x6e, 0x74, 0x6f, 0x72, 0x79, 0x2e, 0x76, 0x31, 0x2e, 0x57, 0x69,
+	0x72, 0x65, 0x41, 0x74, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x73, 0x52, 0x0a, 0x61, 0x74,
+	0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x67, 0x72, 0x6f, 0x75,
+	0x70, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x06, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x73,
+	0x22, 0x7b, 0x0a, 0x09, 0x57, 0x69, 0x72, 0x65, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x12, 0x12, 0x0a,
+	0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d,
+	0x65, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x68, 0x69, 0x6c, 0x64, 0x72, 0x65, 0x6e, 0x18, 0x02, 0x20,
+	0x03, 0x28, 0x09, 0x52, 0x08, 0x63, 0x68, 0x69, 0x6c, 0x64, 0x72, 0x65, 0x6e, 0x12, 0x14, 0x0a,
+	0x05, 0x68, 0x6f, 0x73, 0x74, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x68, 0x6f,
+	0x73, 0x74, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x61, 0x72, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x76, 0x61, 0x72, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x6f, 0x72, 0x64, 0x65, 0x72,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x22, 0x86, 0x01,
+	0x0a, 0x0d, 0x57, 0x69, 0x72, 0x65, 0x49, 0x6e, 0x76, 0x65, 0x6e, 0x74, 0x6f, 0x72, 0x79, 0x12,
+	0x38, 0x0a, 0x05, 0x68, 0x6f, 0x73, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22,
+	0x2e, 0x61, 0x6e, 0x73, 0x69, 0x62, 0x6c, 0x65, 0x5f, 0x64, 0x6e, 0x73, 0x5f, 0x69, 0x6e, 0x76,
+	0x65, 0x6e, 0x74, 0x6f, 0x72, 0x79, 0x2e, 0x76, 0x31, 0x2e, 0x57, 0x69, 0x72, 0x65, 0x48, 0x6f,
+	0x73, 0x74, 0x52, 0x05, 0x68, 0x6f, 0x73, 0x74, 0x73, 0x12, 0x3b, 0x0a, 0x06, 0x67, 0x72, 0x6f,
+	0x75, 0x70, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x61, 0x6e, 0x73, 0x69,
+	0x62, 0x6c, 0x65, 0x5f, 0x64, 0x6e, 0x73, 0x5f, 0x69, 0x6e, 0x76, 0x65, 0x6e, 0x74, 0x6f, 0x72,
+	0x79, 0x2e, 0x76, 0x31, 0x2e, 0x57, 0x69, 0x72, 0x65, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x52, 0x06,
+	0x67, 0x72, 0x6f, 0x75, 0x70, 0x73, 0x42, 0x3b, 0x5a, 0x39, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62,
+	0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x4e, 0x65, 0x6f, 0x6e, 0x53, 0x6c, 0x75, 0x64, 0x67, 0x65, 0x2f,
+	0x61, 0x6e, 0x73, 0x69, 0x62, 0x6c, 0x65, 0x2d, 0x64, 0x6e, 0x73, 0x2d, 0x69, 0x6e, 0x76, 0x65,
+	0x6e, 0x74, 0x6f, 0x72, 0x79, 0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x69, 0x6e, 0x76, 0x65, 0x6e, 0x74,
+	0x6f, 0x72, 0x79, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_pkg_inventory_inventory_proto_rawDescOnce sync.Once
+	file_pkg_inventory_inventory_proto_rawDescData = file_pkg_inventory_inventory_proto_rawDesc
+)
+
+func file_pkg_inventory_inventory_proto_rawDescGZIP() []byte {
+	file_pkg_inventory_inventory_proto_rawDescOnce.Do(func() {
+		file_pkg_inventory_inventory_proto_rawDescData = protoimpl.X.CompressGZIP(file_pkg_inventory_inventory_proto_rawDescData)
+	})
+	return file_pkg_inventory_inventory_proto_rawDescData
+}
+
+var file_pkg_inventory_inventory_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_pkg_inventory_inventory_proto_goTypes = []interface{}{
+	(*WireAttributes)(nil), // 0: ansible_dns_inventory.v1.WireAttributes
+	(*WireHost)(nil),       // 1: ansible_dns_inventory.v1.WireHost
+	(*WireGroup)(nil),      // 2: ansible_dns_inventory.v1.WireGroup
+	(*WireInventory)(nil),  // 3: ansible_dns_inventory.v1.WireInventory
+	nil,                    // 4: ansible_dns_inventory.v1.WireAttributes.ExtraEntry
+}
+var file_pkg_inventory_inventory_proto_depIdxs = []int32{
+	4, // 0: ansible_dns_inventory.v1.WireAttributes.extra:type_name -> ansible_dns_inventory.v1.WireAttributes.ExtraEntry
+	0, // 1: ansible_dns_inventory.v1.WireHost.attributes:type_name -> ansible_dns_inventory.v1.WireAttributes
+	1, // 2: ansible_dns_inventory.v1.WireInventory.hosts:type_name -> ansible_dns_inventory.v1.WireHost
+	2, // 3: ansible_dns_inventory.v1.WireInventory.groups:type_name -> ansible_dns_inventory.v1.WireGroup
+	4, // [4:4] is the sub-list for method output_type
+	4, // [4:4] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_pkg_inventory_inventory_proto_init() }
+func file_pkg_inventory_inventory_proto_init() {
+	if File_pkg_inventory_inventory_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_pkg_inventory_inventory_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WireAttributes); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_inventory_inventory_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WireHost); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_inventory_inventory_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WireGroup); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_inventory_inventory_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WireInventory); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_pkg_inventory_inventory_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_pkg_inventory_inventory_proto_goTypes,
+		DependencyIndexes: file_pkg_inventory_inventory_proto_depIdxs,
+		MessageInfos:      file_pkg_inventory_inventory_proto_msgTypes,
+	}.Build()
+	File_pkg_inventory_inventory_proto = out.File
+	file_pkg_inventory_inventory_proto_rawDesc = nil
+	file_pkg_inventory_inventory_proto_goTypes = nil
+	file_pkg_inventory_inventory_proto_depIdxs = nil
+}
@@ -17,6 +17,8 @@ func TestInventory_ParseAttributes(t *testing.T) {
 	cfg.Txt.Keys.Role = "ROLE"
 	cfg.Txt.Keys.Srv = "SRV"
 	cfg.Txt.Keys.Vars = "VARS"
+	cfg.Txt.Keys.ID = "ID"
+	cfg.Txt.Keys.Extra = []string{"DC"}
 
 	validator := validator.New()
 	validator.RegisterValidation("notblank", validators.NotBlank)
@@ -53,6 +55,53 @@ func TestInventory_ParseAttributes(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid-id",
+			i:    testInventory,
+			args: args{
+				raw: "OS=linux;ENV=dev;ROLE=app;SRV=wildfly_public;VARS=;ID=4b3a6f1e-2c1d-4e5f-9a8b-7c6d5e4f3a2b",
+			},
+			want: &HostAttributes{
+				OS:   "linux",
+				Env:  "dev",
+				Role: "app",
+				Srv:  "wildfly_public",
+				ID:   "4b3a6f1e-2c1d-4e5f-9a8b-7c6d5e4f3a2b",
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid-extra",
+			i:    testInventory,
+			args: args{
+				raw: "OS=linux;ENV=dev;ROLE=app;SRV=;VARS=;DC=msk;RACK=r1",
+			},
+			want: &HostAttributes{
+				OS:    "linux",
+				Env:   "dev",
+				Role:  "app",
+				Extra: map[string]string{"DC": "msk"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid-extra",
+			i:    testInventory,
+			args: args{
+				raw: "OS=linux;ENV=dev;ROLE=app;SRV=;VARS=;DC=msk_1",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid-id",
+			i:    testInventory,
+			args: args{
+				raw: "OS=linux;ENV=dev;ROLE=app;SRV=wildfly_public;ID=app01",
+			},
+			want:    nil,
+			wantErr: true,
+		},
 		{
 			name: "valid-role-list",
 			i:    testInventory,
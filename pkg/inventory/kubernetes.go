@@ -0,0 +1,240 @@
+package inventory
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	// OpenID Connect auth provider of kubeconfig users.
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+)
+
+const (
+	// Kubernetes datasource type.
+	KubernetesDatasourceType string = "kubernetes"
+
+	// Kubernetes datasource sources.
+	kubernetesConfigMapSource string = "configmap"
+	kubernetesCRDSource       string = "crd"
+)
+
+// InventoryHost custom resource.
+var kubernetesCRDResource = schema.GroupVersionResource{Group: "ansible-dns-inventory.io", Version: "v1alpha1", Resource: "inventoryhosts"}
+
+// KubernetesDatasource implements a read-only datasource backed by Kubernetes objects: labeled ConfigMaps or InventoryHost custom resources in a namespace.
+// The Kubernetes API is accessed with client-go, using the credentials of a kubeconfig file (including exec credential plugins and the OpenID Connect
+// auth provider) or of the pod's service account.
+type KubernetesDatasource struct {
+	// Inventory configuration.
+	Config *Config
+	// Inventory logger.
+	Logger Logger
+	// Kubernetes HTTP client shared by the API clients.
+	Client *http.Client
+
+	// Kubernetes API client for ConfigMaps.
+	clientset kubernetes.Interface
+	// Kubernetes API client for InventoryHosts.
+	dynamic dynamic.Interface
+	// Namespace the objects are read from.
+	namespace string
+}
+
+// kubernetesRestConfig loads the client configuration from the configured (or current) context of the kubeconfig files, or from the pod's service account
+// if there are none. It returns the client configuration and the namespace of the context or of the service account.
+func kubernetesRestConfig(cfg *Config) (*rest.Config, string, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = cfg.Kubernetes.Kubeconfig
+
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: cfg.Kubernetes.Context}
+	overrides.Context.Namespace = cfg.Kubernetes.Namespace
+
+	// Exec credential plugins are never run interactively.
+	loader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+
+	restConfig, err := loader.ClientConfig()
+	if err != nil {
+		return nil, "", errors.Wrap(err, "kubeconfig loading failure")
+	}
+
+	namespace, _, err := loader.Namespace()
+	if err != nil {
+		return nil, "", errors.Wrap(err, "kubeconfig loading failure")
+	}
+
+	return restConfig, namespace, nil
+}
+
+// list reads all objects of the configured source matching the label selector, following list continuations.
+// If the first request is rejected as unauthorized, it is retried once: client-go renews exec credential plugin credentials after such a rejection.
+func (k *KubernetesDatasource) list() ([]*DatasourceRecord, error) {
+	cfg := k.Config
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Kubernetes.Timeout)
+	defer cancel()
+
+	records := make([]*DatasourceRecord, 0)
+	opts := metav1.ListOptions{LabelSelector: cfg.Kubernetes.Selector}
+	for attempt := 0; ; attempt++ {
+		next, err := k.page(ctx, opts, &records)
+		switch {
+		case apierrors.IsUnauthorized(err) && attempt == 0 && len(opts.Continue) == 0:
+			continue
+		case err != nil:
+			return nil, errors.Wrap(err, "kubernetes request failure")
+		case len(next) == 0:
+			return records, nil
+		}
+
+		opts.Continue = next
+	}
+}
+
+// page reads a single page of objects, appending their host records. It returns the continuation token of the next page.
+// ConfigMap data keys are hostnames and values are host records, one per line. InventoryHosts list the records of a single host.
+func (k *KubernetesDatasource) page(ctx context.Context, opts metav1.ListOptions, records *[]*DatasourceRecord) (string, error) {
+	cfg := k.Config
+
+	switch cfg.Kubernetes.Source {
+	case kubernetesConfigMapSource:
+		list, err := k.clientset.CoreV1().ConfigMaps(k.namespace).List(ctx, opts)
+		if err != nil {
+			return "", err
+		}
+
+		for _, item := range list.Items {
+			for host, data := range item.Data {
+				for _, line := range strings.Split(data, "\n") {
+					if line = strings.TrimSpace(line); len(line) > 0 {
+						*records = append(*records, &DatasourceRecord{
+							Hostname:   host,
+							Attributes: line,
+							Source:     "configmap/" + item.Namespace + "/" + item.Name + "#" + host,
+						})
+					}
+				}
+			}
+		}
+
+		return list.Continue, nil
+	case kubernetesCRDSource:
+		list, err := k.dynamic.Resource(kubernetesCRDResource).Namespace(k.namespace).List(ctx, opts)
+		if err != nil {
+			return "", err
+		}
+
+		for _, item := range list.Items {
+			// The name of the object is used if the hostname is not set.
+			host, _, _ := unstructured.NestedString(item.Object, "spec", "hostname")
+			if len(host) == 0 {
+				host = item.GetName()
+			}
+
+			lines, _, err := unstructured.NestedStringSlice(item.Object, "spec", "records")
+			if err != nil {
+				return "", errors.Wrapf(err, "inventoryhost/%s/%s", item.GetNamespace(), item.GetName())
+			}
+
+			for _, line := range lines {
+				*records = append(*records, &DatasourceRecord{
+					Hostname:   host,
+					Attributes: line,
+					Source:     "inventoryhost/" + item.GetNamespace() + "/" + item.GetName(),
+				})
+			}
+		}
+
+		return list.GetContinue(), nil
+	}
+
+	return "", errors.Errorf("unknown kubernetes source: %s", cfg.Kubernetes.Source)
+}
+
+// GetAllRecords acquires all available host records.
+func (k *KubernetesDatasource) GetAllRecords() ([]*DatasourceRecord, error) {
+	records, err := k.list()
+	if err != nil {
+		return nil, errors.Wrap(err, "kubernetes datasource failure")
+	}
+
+	return records, nil
+}
+
+// GetHostRecords lists all ConfigMaps and InventoryHosts and returns the records of a specific host.
+// An error is returned if the host has no records.
+func (k *KubernetesDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error) {
+	all, err := k.GetAllRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*DatasourceRecord, 0)
+	for _, r := range all {
+		if strings.EqualFold(r.Hostname, host) {
+			records = append(records, r)
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, errors.Wrap(ErrNoHostRecords, host)
+	}
+
+	return records, nil
+}
+
+// PublishRecords is not supported: the Kubernetes objects are expected to be managed together with the rest of the cluster configuration.
+func (k *KubernetesDatasource) PublishRecords(records []*DatasourceRecord) error {
+	return errors.New("publishing records is not supported by the kubernetes datasource")
+}
+
+// Close closes idle connections to the Kubernetes API server. The datasource remains usable.
+func (k *KubernetesDatasource) Close() {
+	k.Client.CloseIdleConnections()
+}
+
+// NewKubernetesDatasource creates a Kubernetes datasource.
+func NewKubernetesDatasource(cfg *Config, log Logger) (*KubernetesDatasource, error) {
+	if cfg.Kubernetes.Source != kubernetesConfigMapSource && cfg.Kubernetes.Source != kubernetesCRDSource {
+		return nil, errors.Errorf("kubernetes datasource initialization failure: unknown source: %s", cfg.Kubernetes.Source)
+	}
+
+	restConfig, namespace, err := kubernetesRestConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "kubernetes datasource initialization failure")
+	}
+	restConfig.Timeout = cfg.Kubernetes.Timeout
+
+	client, err := rest.HTTPClientFor(restConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "kubernetes datasource initialization failure")
+	}
+
+	clientset, err := kubernetes.NewForConfigAndClient(restConfig, client)
+	if err != nil {
+		return nil, errors.Wrap(err, "kubernetes datasource initialization failure")
+	}
+
+	dynamicClient, err := dynamic.NewForConfigAndClient(restConfig, client)
+	if err != nil {
+		return nil, errors.Wrap(err, "kubernetes datasource initialization failure")
+	}
+
+	return &KubernetesDatasource{
+		Config:    cfg,
+		Logger:    log,
+		Client:    client,
+		clientset: clientset,
+		dynamic:   dynamicClient,
+		namespace: namespace,
+	}, nil
+}
@@ -0,0 +1,560 @@
+package inventory
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// serveKubernetes emulates the Kubernetes API serving ConfigMaps and InventoryHosts in the 'infra' namespace.
+// Requests without the given bearer token are rejected, lists are split into pages of a single object.
+// It returns the cluster of a kubeconfig file for the server: client-go only sends credentials over TLS.
+func serveKubernetes(t *testing.T, token string) string {
+	pages := map[string][]string{
+		"/api/v1/namespaces/infra/configmaps": {
+			`{"apiVersion":"v1","kind":"ConfigMapList","metadata":{"continue":"1"},"items":[{"metadata":{"name":"apps","namespace":"infra"},"data":{"app01.infra.local":"OS=linux;ENV=dev;ROLE=app;SRV=tomcat\nOS=linux;ENV=dev;ROLE=web;SRV=nginx\n"}}]}`,
+			`{"apiVersion":"v1","kind":"ConfigMapList","metadata":{},"items":[{"metadata":{"name":"dbs","namespace":"infra"},"data":{"db01.infra.local":"OS=linux;ENV=dev;ROLE=db;SRV=postgres"}}]}`,
+		},
+		"/apis/ansible-dns-inventory.io/v1alpha1/namespaces/infra/inventoryhosts": {
+			`{"apiVersion":"ansible-dns-inventory.io/v1alpha1","kind":"InventoryHostList","metadata":{},"items":[{"apiVersion":"ansible-dns-inventory.io/v1alpha1","kind":"InventoryHost","metadata":{"name":"app01","namespace":"infra"},"spec":{"hostname":"app01.infra.local","records":["OS=linux;ENV=dev;ROLE=app;SRV=tomcat"]}},{"apiVersion":"ansible-dns-inventory.io/v1alpha1","kind":"InventoryHost","metadata":{"name":"db01.infra.local","namespace":"infra"},"spec":{"records":["OS=linux;ENV=dev;ROLE=db;SRV=postgres"]}}]}`,
+		},
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"kind":"Status","message":"Unauthorized"}`)
+			return
+		}
+
+		list, ok := pages[r.URL.Path]
+		if !ok || r.URL.Query().Get("labelSelector") != "ansible-dns-inventory.io/inventory=true" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"kind":"Status","message":"the server could not find the requested resource"}`)
+			return
+		}
+
+		page := 0
+		if r.URL.Query().Get("continue") == "1" {
+			page = 1
+		}
+
+		fmt.Fprint(w, list[page])
+	}))
+	t.Cleanup(server.Close)
+
+	return testKubeconfigCluster(server)
+}
+
+// testKubeconfigCluster returns the server address and the CA certificate of a TLS test server as fields of a kubeconfig cluster.
+func testKubeconfigCluster(server *httptest.Server) string {
+	ca := base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	return "server: " + server.URL + "\n    certificate-authority-data: " + ca
+}
+
+// newTestKubernetesConfig creates a configuration with a kubeconfig file for the emulated Kubernetes API.
+func newTestKubernetesConfig(t *testing.T, cluster string, user string) *Config {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	data := fmt.Sprintf(`apiVersion: v1
+kind: Config
+current-context: test
+clusters:
+- name: test
+  cluster:
+    %s
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: %s
+    namespace: infra
+users:
+- name: test
+  user:
+    token: test-token
+- name: invalid
+  user:
+    token: invalid-token
+- name: exec
+  user:
+    exec:
+      command: kubectl-login
+`, cluster, user)
+	if err := os.WriteFile(kubeconfig, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{}
+	cfg.Kubernetes.Kubeconfig = kubeconfig
+	cfg.Kubernetes.Source = kubernetesConfigMapSource
+	cfg.Kubernetes.Selector = "ansible-dns-inventory.io/inventory=true"
+	cfg.Kubernetes.Timeout = 5 * time.Second
+
+	return cfg
+}
+
+func TestKubernetesDatasource_GetAllRecords(t *testing.T) {
+	server := serveKubernetes(t, "test-token")
+
+	tests := []struct {
+		name    string
+		source  string
+		user    string
+		want    []string
+		wantErr bool
+		initErr bool
+	}{
+		{
+			name:   "valid-configmap",
+			source: kubernetesConfigMapSource,
+			user:   "test",
+			want: []string{
+				"app01.infra.local OS=linux;ENV=dev;ROLE=app;SRV=tomcat configmap/infra/apps#app01.infra.local",
+				"app01.infra.local OS=linux;ENV=dev;ROLE=web;SRV=nginx configmap/infra/apps#app01.infra.local",
+				"db01.infra.local OS=linux;ENV=dev;ROLE=db;SRV=postgres configmap/infra/dbs#db01.infra.local",
+			},
+		},
+		{
+			// The name of the object is used if the hostname is not set.
+			name:   "valid-crd",
+			source: kubernetesCRDSource,
+			user:   "test",
+			want: []string{
+				"app01.infra.local OS=linux;ENV=dev;ROLE=app;SRV=tomcat inventoryhost/infra/app01",
+				"db01.infra.local OS=linux;ENV=dev;ROLE=db;SRV=postgres inventoryhost/infra/db01.infra.local",
+			},
+		},
+		{
+			name:    "invalid-token",
+			source:  kubernetesConfigMapSource,
+			user:    "invalid",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-exec",
+			source:  kubernetesConfigMapSource,
+			user:    "exec",
+			initErr: true,
+		},
+		{
+			name:    "invalid-source",
+			source:  "secret",
+			user:    "test",
+			initErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestKubernetesConfig(t, server, tt.user)
+			cfg.Kubernetes.Source = tt.source
+
+			k, err := NewKubernetesDatasource(cfg, &testLogger{})
+			if (err != nil) != tt.initErr {
+				t.Fatalf("NewKubernetesDatasource() error = %v, initErr %v", err, tt.initErr)
+			}
+			if err != nil {
+				return
+			}
+			defer k.Close()
+
+			records, err := k.GetAllRecords()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("KubernetesDatasource.GetAllRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			got := make([]string, 0, len(records))
+			for _, r := range records {
+				got = append(got, r.Hostname+" "+r.Attributes+" "+r.Source)
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KubernetesDatasource.GetAllRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestKubernetesExecPlugin is not a test: it implements an exec credential plugin in the test binary, started by TestKubernetesDatasource_credentials.
+// It issues the 'ADI_TEST_KUBERNETES_EXEC_TOKEN' token and counts every run in the 'ADI_TEST_KUBERNETES_EXEC_COUNTER' file.
+// The 'ADI_TEST_KUBERNETES_EXEC' mode selects the behavior: 'rotate' issues a token rejected by the API server on the first run, 'cluster' requires
+// cluster information, 'version' responds with another API version and 'certificate' issues the client certificate and key read from the 'ADI_TEST_KUBERNETES_EXEC_CERT' and 'ADI_TEST_KUBERNETES_EXEC_KEY' files instead of a token.
+func TestKubernetesExecPlugin(t *testing.T) {
+	mode := os.Getenv("ADI_TEST_KUBERNETES_EXEC")
+	if len(mode) == 0 {
+		return
+	}
+
+	request := &struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Spec       struct {
+			Interactive bool `json:"interactive"`
+			Cluster     *struct {
+				Server string `json:"server"`
+			} `json:"cluster"`
+		} `json:"spec"`
+	}{}
+	if err := json.Unmarshal([]byte(os.Getenv("KUBERNETES_EXEC_INFO")), request); err != nil || request.Kind != "ExecCredential" || request.Spec.Interactive {
+		fmt.Fprintln(os.Stderr, "invalid KUBERNETES_EXEC_INFO")
+		os.Exit(1)
+	}
+
+	counter := os.Getenv("ADI_TEST_KUBERNETES_EXEC_COUNTER")
+	runs, _ := os.ReadFile(counter)
+	os.WriteFile(counter, append(runs, '.'), 0o600)
+
+	token, version := os.Getenv("ADI_TEST_KUBERNETES_EXEC_TOKEN"), request.APIVersion
+	switch mode {
+	case "rotate":
+		if len(runs) == 0 {
+			token = "expired-token"
+		}
+	case "cluster":
+		if request.Spec.Cluster == nil || !strings.HasPrefix(request.Spec.Cluster.Server, "https://") {
+			fmt.Fprintln(os.Stderr, "no cluster information")
+			os.Exit(1)
+		}
+	case "version":
+		version = "client.authentication.k8s.io/v1alpha1"
+	case "certificate":
+		cert, _ := os.ReadFile(os.Getenv("ADI_TEST_KUBERNETES_EXEC_CERT"))
+		key, _ := os.ReadFile(os.Getenv("ADI_TEST_KUBERNETES_EXEC_KEY"))
+		fmt.Printf(`{"apiVersion":%q,"kind":"ExecCredential","status":{"clientCertificateData":%q,"clientKeyData":%q}}`, version, cert, key)
+		os.Exit(0)
+	}
+
+	fmt.Printf(`{"apiVersion":%q,"kind":"ExecCredential","status":{"token":%q}}`, version, token)
+	os.Exit(0)
+}
+
+// testJWT returns an unsigned JWT expiring at the given time.
+func testJWT(expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":"ci","exp":%d}`, expires.Unix())))
+
+	return "eyJhbGciOiJub25lIn0." + payload + ".c2lnbmF0dXJl"
+}
+
+// serveOIDC emulates an OpenID Connect provider exchanging the 'refresh-1' refresh token of any client for an ID token and the 'refresh-2' refresh token.
+func serveOIDC(t *testing.T, idToken string) string {
+	var issuer string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/realms/infra/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"issuer":%q,"token_endpoint":%q}`, issuer, issuer+"/protocol/openid-connect/token")
+		case "/realms/infra/protocol/openid-connect/token":
+			if r.PostFormValue("grant_type") != "refresh_token" || r.PostFormValue("refresh_token") != "refresh-1" || len(r.PostFormValue("client_id")) == 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"invalid_grant","error_description":"Invalid refresh token"}`)
+				return
+			}
+
+			fmt.Fprintf(w, `{"access_token":"access-2","id_token":%q,"refresh_token":"refresh-2","token_type":"Bearer"}`, idToken)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	issuer = server.URL + "/realms/infra"
+
+	return issuer
+}
+
+func TestKubernetesDatasource_credentials(t *testing.T) {
+	fresh := testJWT(time.Now().Add(time.Hour))
+	expired := testJWT(time.Now().Add(-time.Hour))
+
+	server := serveKubernetes(t, fresh)
+	issuer := serveOIDC(t, fresh)
+
+	execUser := func(mode string) string {
+		return `
+    exec:
+      apiVersion: client.authentication.k8s.io/v1
+      command: {command}
+      args: ["-test.run=^TestKubernetesExecPlugin$"]
+      env:
+      - name: ADI_TEST_KUBERNETES_EXEC
+        value: ` + mode + `
+      - name: ADI_TEST_KUBERNETES_EXEC_COUNTER
+        value: {counter}
+      - name: ADI_TEST_KUBERNETES_EXEC_TOKEN
+        value: ` + fresh + `
+      provideClusterInfo: true
+      interactiveMode: IfAvailable`
+	}
+	// client-go caches auth providers by issuer and client, so every test case uses its own client.
+	oidcUser := func(client string, idToken string, refreshToken string) string {
+		return `
+    auth-provider:
+      name: oidc
+      config:
+        client-id: ` + client + `
+        idp-issuer-url: ` + issuer + `
+        id-token: ` + idToken + `
+        refresh-token: ` + refreshToken
+	}
+
+	tests := []struct {
+		name        string
+		user        string
+		wantRuns    int
+		wantRefresh bool
+		wantErr     bool
+		initErr     bool
+	}{
+		{
+			// A token rejected by the API server is renewed once, a renewed token is reused for the following pages.
+			name:     "valid-exec-rotate",
+			user:     execUser("rotate"),
+			wantRuns: 2,
+		},
+		{
+			name:     "valid-exec-cluster-info",
+			user:     execUser("cluster"),
+			wantRuns: 1,
+		},
+		{
+			name:    "invalid-exec-version",
+			user:    execUser("version"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid-exec-not-found",
+			user:    "\n    exec:\n      apiVersion: client.authentication.k8s.io/v1beta1\n      command: ./kubectl-login\n      installHint: install kubectl-login",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-exec-interactive",
+			user:    "\n    exec:\n      apiVersion: client.authentication.k8s.io/v1\n      command: kubectl-login\n      interactiveMode: Always",
+			wantErr: true,
+		},
+		{
+			name: "valid-oidc",
+			user: oidcUser("valid", fresh, "refresh-0"),
+		},
+		{
+			name:        "valid-oidc-refresh",
+			user:        oidcUser("valid-refresh", expired, "refresh-1"),
+			wantRefresh: true,
+		},
+		{
+			name:    "invalid-oidc-refresh-token",
+			user:    oidcUser("invalid-refresh", expired, "refresh-0"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid-provider",
+			user:    "\n    auth-provider:\n      name: gcp",
+			initErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			counter := filepath.Join(dir, "runs")
+			user := strings.NewReplacer("{command}", os.Args[0], "{counter}", counter).Replace(tt.user)
+
+			cfg := newTestKubernetesConfig(t, server, "test")
+			data := fmt.Sprintf("apiVersion: v1\nkind: Config\ncurrent-context: test\nclusters:\n- name: test\n  cluster:\n    %s\n"+
+				"contexts:\n- name: test\n  context:\n    cluster: test\n    user: test\n    namespace: infra\nusers:\n- name: test\n  user:%s\n", server, user)
+			if err := os.WriteFile(cfg.Kubernetes.Kubeconfig, []byte(data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			k, err := NewKubernetesDatasource(cfg, &testLogger{})
+			if (err != nil) != tt.initErr {
+				t.Fatalf("NewKubernetesDatasource() error = %v, initErr %v", err, tt.initErr)
+			}
+			if err != nil {
+				return
+			}
+			defer k.Close()
+
+			records, err := k.GetAllRecords()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("KubernetesDatasource.GetAllRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(records) != 3 {
+				t.Errorf("KubernetesDatasource.GetAllRecords() returned %d records, want 3", len(records))
+			}
+
+			if runs, _ := os.ReadFile(counter); len(runs) != tt.wantRuns {
+				t.Errorf("exec credential plugin runs = %d, want %d", len(runs), tt.wantRuns)
+			}
+
+			// Refreshed tokens are written back to the kubeconfig file.
+			kc, err := clientcmd.LoadFromFile(cfg.Kubernetes.Kubeconfig)
+			if err != nil {
+				t.Fatalf("kubeconfig parsing failure: %v", err)
+			}
+			if provider := kc.AuthInfos["test"].AuthProvider; tt.wantRefresh && (provider == nil || provider.Config["id-token"] != fresh || provider.Config["refresh-token"] != "refresh-2") {
+				t.Errorf("kubeconfig auth provider = %v, want refreshed tokens", provider)
+			}
+		})
+	}
+}
+
+// newTestClientKeyPair generates a self-signed client certificate and key in PEM format.
+func newTestClientKeyPair(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestKubernetesDatasource_clientCertificate(t *testing.T) {
+	// The API server authenticates clients with certificates only.
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if len(r.TLS.PeerCertificates) == 0 || len(r.Header.Get("Authorization")) > 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Fprint(w, `{"apiVersion":"v1","kind":"ConfigMapList","metadata":{},"items":[{"metadata":{"name":"apps","namespace":"infra"},"data":{"app01.infra.local":"OS=linux;ENV=dev;ROLE=app;SRV=tomcat"}}]}`)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	cert, key := newTestClientKeyPair(t)
+	for name, data := range map[string]string{"cert.pem": cert, "key.pem": key} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := newTestKubernetesConfig(t, testKubeconfigCluster(server), "test")
+	data := fmt.Sprintf(`current-context: test
+clusters:
+- name: test
+  cluster:
+    %s
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+    namespace: infra
+users:
+- name: test
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: %s
+      args: ["-test.run=^TestKubernetesExecPlugin$"]
+      env:
+      - {name: ADI_TEST_KUBERNETES_EXEC, value: certificate}
+      - {name: ADI_TEST_KUBERNETES_EXEC_COUNTER, value: %s}
+      - {name: ADI_TEST_KUBERNETES_EXEC_CERT, value: %s}
+      - {name: ADI_TEST_KUBERNETES_EXEC_KEY, value: %s}
+`, testKubeconfigCluster(server), os.Args[0], filepath.Join(dir, "runs"), filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err := os.WriteFile(cfg.Kubernetes.Kubeconfig, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Kubernetes.Selector = ""
+
+	k, err := NewKubernetesDatasource(cfg, &testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer k.Close()
+
+	records, err := k.GetAllRecords()
+	if err != nil {
+		t.Fatalf("KubernetesDatasource.GetAllRecords() error = %v", err)
+	}
+	if len(records) != 1 {
+		t.Errorf("KubernetesDatasource.GetAllRecords() = %v, want a single record", records)
+	}
+}
+
+func TestKubernetesDatasource_tokenFile(t *testing.T) {
+	server := serveKubernetes(t, "test-token")
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "valid", token: "test-token\n"},
+		{name: "invalid-token", token: "expired-token", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := filepath.Join(t.TempDir(), "token")
+			if err := os.WriteFile(token, []byte(tt.token), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := newTestKubernetesConfig(t, server, "test")
+			data := fmt.Sprintf("current-context: test\nclusters:\n- name: test\n  cluster:\n    %s\ncontexts:\n- name: test\n  context:\n    cluster: test\n    user: test\n    namespace: infra\n"+
+				"users:\n- name: test\n  user:\n    tokenFile: %s\n", server, token)
+			if err := os.WriteFile(cfg.Kubernetes.Kubeconfig, []byte(data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			k, err := NewKubernetesDatasource(cfg, &testLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer k.Close()
+
+			if _, err := k.GetAllRecords(); (err != nil) != tt.wantErr {
+				t.Errorf("KubernetesDatasource.GetAllRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package inventory
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// LDAP datasource type.
+	LDAPDatasourceType string = "ldap"
+)
+
+// LDAPDatasource implements a read-only datasource backed by LDAP directory entries, e.g. computer objects in Active Directory.
+// Every matching entry describes a single host: host attributes are either mapped from entry attributes or read as complete host records.
+type LDAPDatasource struct {
+	// Inventory configuration.
+	Config *Config
+	// Inventory logger.
+	Logger Logger
+
+	// LDAP server address (host:port).
+	address string
+	// Use LDAPS instead of plain LDAP.
+	ldaps bool
+	// TLS configuration for LDAPS and StartTLS.
+	tlsConfig *tls.Config
+	// Search scope.
+	scope int64
+	// Compiled search filter.
+	filter []byte
+}
+
+// connect establishes an authenticated LDAP connection.
+func (l *LDAPDatasource) connect() (*ldapConn, error) {
+	cfg := l.Config
+
+	dialer := &net.Dialer{Timeout: cfg.LDAP.Timeout}
+
+	var conn net.Conn
+	var err error
+	if l.ldaps {
+		conn, err = tls.DialWithDialer(dialer, "tcp", l.address, l.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", l.address)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "ldap connection failure")
+	}
+
+	c := &ldapConn{conn: conn, reader: bufio.NewReader(conn), timeout: cfg.LDAP.Timeout}
+
+	if cfg.LDAP.StartTLS && !l.ldaps {
+		if err := c.startTLS(l.tlsConfig); err != nil {
+			c.conn.Close()
+			return nil, err
+		}
+	}
+
+	if err := c.bind(cfg.LDAP.BindDN, cfg.LDAP.Password); err != nil {
+		c.close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// attributes returns the names of the entry attributes requested from the server.
+func (l *LDAPDatasource) attributes() []string {
+	cfg := l.Config
+
+	attrs := []string{cfg.LDAP.Hostname}
+	if len(cfg.LDAP.Records) > 0 {
+		return append(attrs, cfg.LDAP.Records)
+	}
+
+	for _, attr := range cfg.LDAP.Attributes {
+		attrs = append(attrs, attr)
+	}
+
+	return attrs
+}
+
+// entryRecords converts an LDAP entry into host records.
+// Multi-valued attributes are joined with commas and values are normalized with the 'normalize' rules, so that directory values pass attribute validation.
+func (l *LDAPDatasource) entryRecords(entry *ldapEntry) ([]*DatasourceRecord, error) {
+	cfg := l.Config
+
+	hosts := entry.Attributes[strings.ToLower(cfg.LDAP.Hostname)]
+	if len(hosts) == 0 || len(hosts[0]) == 0 {
+		return nil, errors.Errorf("%s: entry has no %s attribute", entry.DN, cfg.LDAP.Hostname)
+	}
+	host := strings.ToLower(strings.TrimSuffix(hosts[0], "."))
+
+	records := make([]*DatasourceRecord, 0)
+
+	if len(cfg.LDAP.Records) > 0 {
+		for _, value := range entry.Attributes[strings.ToLower(cfg.LDAP.Records)] {
+			if value = strings.TrimSpace(value); len(value) > 0 {
+				records = append(records, &DatasourceRecord{Hostname: host, Attributes: value, Source: entry.DN})
+			}
+		}
+
+		return records, nil
+	}
+
+	attrs, err := mappedAttributes(cfg, func(key string) string {
+		return strings.Join(entry.Attributes[strings.ToLower(lookupFold(cfg.LDAP.Attributes, key))], ",")
+	}, cfg.LDAP.Defaults)
+	if err != nil {
+		return nil, errors.Wrap(err, entry.DN)
+	}
+
+	records = append(records, &DatasourceRecord{Hostname: host, Attributes: attrs, Source: entry.DN})
+
+	return records, nil
+}
+
+// search finds the entries matching a filter and converts them into host records. Entries that cannot be converted are skipped.
+func (l *LDAPDatasource) search(filter []byte) ([]*DatasourceRecord, error) {
+	cfg := l.Config
+	log := l.Logger
+
+	c, err := l.connect()
+	if err != nil {
+		return nil, errors.Wrap(err, "ldap datasource failure")
+	}
+	defer c.close()
+
+	entries, err := c.search(cfg.LDAP.BaseDN, l.scope, filter, l.attributes(), cfg.LDAP.PageSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "ldap datasource failure")
+	}
+
+	records := make([]*DatasourceRecord, 0, len(entries))
+	for _, entry := range entries {
+		r, err := l.entryRecords(entry)
+		if err != nil {
+			log.Warnf("skipping ldap entry: %v", err)
+			continue
+		}
+
+		records = append(records, r...)
+	}
+
+	return records, nil
+}
+
+// GetAllRecords acquires all available host records.
+func (l *LDAPDatasource) GetAllRecords() ([]*DatasourceRecord, error) {
+	return l.search(l.filter)
+}
+
+// GetHostRecords searches for the entries matching both the configured filter and the hostname of a specific host.
+func (l *LDAPDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error) {
+	cfg := l.Config
+
+	hostFilter, err := ldapCompileFilter("(" + cfg.LDAP.Hostname + "=" + ldapEscape(host) + ")")
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: invalid hostname filter", host)
+	}
+
+	return l.search(berEncode(ldapFilterAnd, l.filter, hostFilter))
+}
+
+// PublishRecords is not supported: directory entries are expected to be managed with the directory tools.
+func (l *LDAPDatasource) PublishRecords(records []*DatasourceRecord) error {
+	return errors.New("publishing records is not supported by the ldap datasource")
+}
+
+// Close does nothing: connections are only kept open for a single search.
+func (l *LDAPDatasource) Close() {}
+
+// NewLDAPDatasource creates an LDAP datasource.
+func NewLDAPDatasource(cfg *Config, log Logger) (*LDAPDatasource, error) {
+	l := &LDAPDatasource{Config: cfg, Logger: log}
+
+	u, err := url.Parse(cfg.LDAP.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "ldap datasource initialization failure")
+	}
+
+	port := u.Port()
+	switch u.Scheme {
+	case "ldap":
+		if len(port) == 0 {
+			port = "389"
+		}
+	case "ldaps":
+		l.ldaps = true
+		if len(port) == 0 {
+			port = "636"
+		}
+	default:
+		return nil, errors.Errorf("ldap datasource initialization failure: unsupported URL scheme: %s", u.Scheme)
+	}
+	l.address = net.JoinHostPort(u.Hostname(), port)
+
+	l.tlsConfig = &tls.Config{ServerName: u.Hostname(), InsecureSkipVerify: cfg.LDAP.TLS.Insecure}
+	if len(cfg.LDAP.TLS.CA) > 0 {
+		pool, err := tlsCAPoolFromFile(cfg.LDAP.TLS.CA)
+		if err != nil {
+			return nil, errors.Wrap(err, "ldap datasource initialization failure")
+		}
+
+		l.tlsConfig.RootCAs = pool
+	}
+
+	scope, ok := ldapScopes[strings.ToLower(cfg.LDAP.Scope)]
+	if !ok {
+		return nil, errors.Errorf("ldap datasource initialization failure: unknown search scope: %s", cfg.LDAP.Scope)
+	}
+	l.scope = scope
+
+	if l.filter, err = ldapCompileFilter(cfg.LDAP.Filter); err != nil {
+		return nil, errors.Wrap(err, "ldap datasource initialization failure")
+	}
+
+	if len(cfg.LDAP.Hostname) == 0 {
+		return nil, errors.New("ldap datasource initialization failure: hostname attribute is not set")
+	}
+
+	return l, nil
+}
@@ -0,0 +1,271 @@
+package inventory
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testLDAPEntry represents a directory entry served by the emulated LDAP server.
+type testLDAPEntry struct {
+	dn    string
+	attrs map[string][]string
+}
+
+// serveLDAP emulates an LDAP server accepting simple binds of 'cn=inventory,dc=infra,dc=local' with the 'test-password' password.
+// Search results are returned in pages of a single entry. Searches for a single host (an AND filter ending with an equality match) only return the entries of that host.
+func serveLDAP(t *testing.T, entries []testLDAPEntry) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	respond := func(c *ldapConn, id int64, op []byte, controls ...[]byte) {
+		parts := [][]byte{berInt(berInteger, id), op}
+		if len(controls) > 0 {
+			parts = append(parts, berEncode(ldapControls, controls...))
+		}
+		c.conn.Write(berEncode(berSequence, parts...))
+	}
+
+	result := func(tag byte, code int64, message string) []byte {
+		return berEncode(tag, berInt(berEnumerated, code), berString(berOctetString, ""), berString(berOctetString, message))
+	}
+
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		c := &ldapConn{conn: conn, reader: bufio.NewReader(conn), timeout: 5 * time.Second}
+
+		for {
+			msg, err := c.receive()
+			if err != nil {
+				return
+			}
+
+			id, op := msg.child(0).int(), msg.child(1)
+			switch op.tag {
+			case ldapBindRequest:
+				if string(op.child(1).value) == "cn=inventory,dc=infra,dc=local" && string(op.child(2).value) == "test-password" {
+					respond(c, id, result(ldapBindResponse, 0, ""))
+				} else {
+					respond(c, id, result(ldapBindResponse, 49, "invalid credentials"))
+				}
+			case ldapSearchRequest:
+				host := ""
+				if filter := op.child(6); filter.tag == ldapFilterAnd {
+					host = string(filter.child(len(filter.children) - 1).child(1).value)
+				}
+
+				matching := make([]testLDAPEntry, 0)
+				for _, e := range entries {
+					if len(host) == 0 || len(e.attrs["dNSHostName"]) > 0 && e.attrs["dNSHostName"][0] == host {
+						matching = append(matching, e)
+					}
+				}
+
+				// The cookie is the index of the next entry.
+				page := 0
+				for _, control := range msg.child(2).children {
+					paged, _, _ := berDecode(control.child(2).value)
+					if cookie := string(paged.child(1).value); len(cookie) > 0 {
+						page = int(cookie[0] - '0')
+					}
+				}
+
+				if page < len(matching) {
+					e := matching[page]
+					attrs := make([][]byte, 0)
+					for name, values := range e.attrs {
+						vals := make([][]byte, 0)
+						for _, v := range values {
+							vals = append(vals, berString(berOctetString, v))
+						}
+						attrs = append(attrs, berEncode(berSequence, berString(berOctetString, name), berEncode(berSet, vals...)))
+					}
+					respond(c, id, berEncode(ldapSearchResultEntry, berString(berOctetString, e.dn), berEncode(berSequence, attrs...)))
+				}
+
+				cookie := ""
+				if page+1 < len(matching) {
+					cookie = string(rune('0' + page + 1))
+				}
+				value := berEncode(berSequence, berInt(berInteger, 0), berString(berOctetString, cookie))
+				respond(c, id, result(ldapSearchResultDone, 0, ""), berEncode(berSequence, berString(berOctetString, ldapPagedResultsOID), berEncode(berOctetString, value)))
+			case ldapUnbindRequest:
+				return
+			}
+		}
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+
+	return "ldap://" + ln.Addr().String()
+}
+
+// newTestLDAPConfig creates a configuration for the emulated LDAP server.
+func newTestLDAPConfig(url string, password string) *Config {
+	cfg := &Config{}
+	cfg.Txt.Keys.Os = "OS"
+	cfg.Txt.Keys.Env = "ENV"
+	cfg.Txt.Keys.Role = "ROLE"
+	cfg.Txt.Keys.Srv = "SRV"
+	cfg.Txt.Keys.Vars = "VARS"
+	cfg.Txt.Keys.ID = "ID"
+	cfg.Txt.Kv.Separator = ";"
+	cfg.Txt.Kv.Equalsign = "="
+	cfg.LDAP.URL = url
+	cfg.LDAP.BindDN = "cn=inventory,dc=infra,dc=local"
+	cfg.LDAP.Password = password
+	cfg.LDAP.BaseDN = "dc=infra,dc=local"
+	cfg.LDAP.Scope = "sub"
+	cfg.LDAP.Filter = "(objectClass=computer)"
+	cfg.LDAP.Hostname = "dNSHostName"
+	cfg.LDAP.PageSize = 1
+	cfg.LDAP.Timeout = 5 * time.Second
+
+	return cfg
+}
+
+func TestLDAPDatasource_GetAllRecords(t *testing.T) {
+	url := serveLDAP(t, []testLDAPEntry{
+		{dn: "CN=APP01,OU=Servers,DC=infra,DC=local", attrs: map[string][]string{
+			"dNSHostName":     {"APP01.infra.local"},
+			"operatingSystem": {"Windows Server 2019 Standard"},
+			"department":      {"dev"},
+			"description":     {"app", "web"},
+			"objectGUID":      {"5f1c"},
+			"info":            {"OS=windows;ENV=dev;ROLE=app;SRV=iis", "OS=windows;ENV=dev;ROLE=web;SRV="},
+		}},
+		{dn: "CN=DB01,OU=Servers,DC=infra,DC=local", attrs: map[string][]string{
+			"dNSHostName":     {"db01.infra.local"},
+			"operatingSystem": {"Linux"},
+			"description":     {"db"},
+		}},
+		{dn: "CN=KIOSK,OU=Workstations,DC=infra,DC=local", attrs: map[string][]string{
+			"operatingSystem": {"Windows 11"},
+		}},
+	})
+
+	tests := []struct {
+		name     string
+		password string
+		records  string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			// Entries without a hostname are skipped, missing attributes fall back to the defaults.
+			name:     "valid-attributes",
+			password: "test-password",
+			want: []string{
+				"app01.infra.local OS=windows;ENV=dev;ROLE=app,web;SRV=;VARS=;ID=5f1c CN=APP01,OU=Servers,DC=infra,DC=local",
+				"db01.infra.local OS=linux;ENV=prod;ROLE=db;SRV=;VARS= CN=DB01,OU=Servers,DC=infra,DC=local",
+			},
+		},
+		{
+			name:     "valid-records",
+			password: "test-password",
+			records:  "info",
+			want: []string{
+				"app01.infra.local OS=windows;ENV=dev;ROLE=app;SRV=iis CN=APP01,OU=Servers,DC=infra,DC=local",
+				"app01.infra.local OS=windows;ENV=dev;ROLE=web;SRV= CN=APP01,OU=Servers,DC=infra,DC=local",
+			},
+		},
+		{
+			name:     "invalid-password",
+			password: "invalid-password",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestLDAPConfig(url, tt.password)
+			cfg.LDAP.Records = tt.records
+			cfg.LDAP.Attributes = map[string]string{"os": "operatingSystem", "env": "department", "role": "description", "id": "objectGUID"}
+			cfg.LDAP.Defaults = map[string]string{"env": "prod"}
+			cfg.Normalize.Lowercase = true
+			cfg.Normalize.Values = map[string]map[string]string{"os": {"Windows Server 2019 Standard": "windows"}}
+
+			l, err := NewLDAPDatasource(cfg, &testLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+
+			records, err := l.GetAllRecords()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LDAPDatasource.GetAllRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			got := make([]string, 0, len(records))
+			for _, r := range records {
+				got = append(got, r.Hostname+" "+r.Attributes+" "+r.Source)
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LDAPDatasource.GetAllRecords() = %v, want %v", got, tt.want)
+			}
+
+			host, err := l.GetHostRecords("db01.infra.local")
+			if err != nil {
+				t.Fatalf("LDAPDatasource.GetHostRecords() error = %v", err)
+			}
+
+			for _, r := range host {
+				if r.Hostname != "db01.infra.local" {
+					t.Errorf("LDAPDatasource.GetHostRecords() returned a record of %s", r.Hostname)
+				}
+			}
+		})
+	}
+}
+
+func Test_ldapCompileFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		want    string
+		wantErr bool
+	}{
+		{name: "valid-equal", filter: "(cn=a)", want: "a3070402636e040161"},
+		{name: "valid-no-parentheses", filter: "cn=a", want: "a3070402636e040161"},
+		{name: "valid-present", filter: "(cn=*)", want: "8702636e"},
+		{name: "valid-not-substring", filter: "(!(cn=a*b))", want: "a20ea40c0402636e3006800161820162"},
+		{name: "valid-and-escaped", filter: "(&(a>=1)(b=\\2a))", want: "a010a506040161040131a30604016204012a"},
+		{name: "invalid-unbalanced", filter: "(&(cn=a)", wantErr: true},
+		{name: "invalid-trailing", filter: "(cn=a))", wantErr: true},
+		{name: "invalid-extensible", filter: "(cn:dn:=a)", wantErr: true},
+		{name: "invalid-not", filter: "(!(a=1)(b=2))", wantErr: true},
+		{name: "invalid-escape", filter: "(cn=\\zz)", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ldapCompileFilter(tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ldapCompileFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := hex.EncodeToString(got); !strings.EqualFold(got, tt.want) {
+				t.Errorf("ldapCompileFilter() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,608 @@
+package inventory
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// BER universal tags.
+	berBoolean     byte = 0x01
+	berInteger     byte = 0x02
+	berOctetString byte = 0x04
+	berEnumerated  byte = 0x0a
+	berSequence    byte = 0x30
+	berSet         byte = 0x31
+
+	// LDAP protocol operation tags.
+	ldapBindRequest           byte = 0x60
+	ldapBindResponse          byte = 0x61
+	ldapUnbindRequest         byte = 0x42
+	ldapSearchRequest         byte = 0x63
+	ldapSearchResultEntry     byte = 0x64
+	ldapSearchResultDone      byte = 0x65
+	ldapSearchResultReference byte = 0x73
+	ldapExtendedRequest       byte = 0x77
+	ldapExtendedResponse      byte = 0x78
+
+	// LDAP context-specific tags: simple bind credentials, message controls and the extended request name.
+	ldapSimpleAuth   byte = 0x80
+	ldapControls     byte = 0xa0
+	ldapExtendedName byte = 0x80
+
+	// LDAP search filter tags.
+	ldapFilterAnd       byte = 0xa0
+	ldapFilterOr        byte = 0xa1
+	ldapFilterNot       byte = 0xa2
+	ldapFilterEqual     byte = 0xa3
+	ldapFilterSubstring byte = 0xa4
+	ldapFilterGreater   byte = 0xa5
+	ldapFilterLess      byte = 0xa6
+	ldapFilterPresent   byte = 0x87
+	ldapFilterApprox    byte = 0xa8
+
+	// LDAP substring filter element tags.
+	ldapSubstringInitial byte = 0x80
+	ldapSubstringAny     byte = 0x81
+	ldapSubstringFinal   byte = 0x82
+
+	// Simple Paged Results control (RFC 2696) and StartTLS extended operation (RFC 4511) identifiers.
+	ldapPagedResultsOID string = "1.2.840.113556.1.4.319"
+	ldapStartTLSOID     string = "1.3.6.1.4.1.1466.20037"
+
+	// Maximum size of an LDAP message accepted from the server.
+	ldapMaxMessageSize int = 64 << 20
+)
+
+// ldapScopes maps search scope names to their protocol values.
+var ldapScopes = map[string]int64{"base": 0, "one": 1, "sub": 2}
+
+type (
+	// berElement represents a decoded BER element. Constructed elements are decoded recursively.
+	berElement struct {
+		// Element tag.
+		tag byte
+		// Element contents.
+		value []byte
+		// Child elements of a constructed element.
+		children []*berElement
+	}
+
+	// ldapConn is a minimal LDAPv3 client supporting simple binds and paged searches.
+	ldapConn struct {
+		// Network connection.
+		conn net.Conn
+		// Buffered connection reader.
+		reader *bufio.Reader
+		// Timeout of a single request.
+		timeout time.Duration
+		// Last message ID.
+		id int64
+	}
+
+	// ldapEntry represents an LDAP search result entry.
+	ldapEntry struct {
+		// Distinguished name of the entry.
+		DN string
+		// Attribute values by lowercase attribute name.
+		Attributes map[string][]string
+	}
+)
+
+// berEncode encodes a BER element.
+func berEncode(tag byte, contents ...[]byte) []byte {
+	var value []byte
+	for _, c := range contents {
+		value = append(value, c...)
+	}
+
+	out := []byte{tag}
+	switch n := len(value); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	default:
+		var length []byte
+		for ; n > 0; n >>= 8 {
+			length = append([]byte{byte(n)}, length...)
+		}
+		out = append(out, 0x80|byte(len(length)))
+		out = append(out, length...)
+	}
+
+	return append(out, value...)
+}
+
+// berInt encodes an integer BER element.
+func berInt(tag byte, v int64) []byte {
+	value := []byte{byte(v)}
+	for v >= 0x80 || v < -0x80 {
+		v >>= 8
+		value = append([]byte{byte(v)}, value...)
+	}
+
+	return berEncode(tag, value)
+}
+
+// berString encodes an octet string BER element.
+func berString(tag byte, s string) []byte {
+	return berEncode(tag, []byte(s))
+}
+
+// berBool encodes a boolean BER element.
+func berBool(v bool) []byte {
+	if v {
+		return berEncode(berBoolean, []byte{0xff})
+	}
+
+	return berEncode(berBoolean, []byte{0x00})
+}
+
+// berDecode decodes the BER element at the start of the data and returns it along with the number of bytes consumed.
+func berDecode(data []byte) (*berElement, int, error) {
+	if len(data) < 2 {
+		return nil, 0, errors.New("truncated BER element")
+	}
+
+	e := &berElement{tag: data[0]}
+	length, offset := int(data[1]), 2
+
+	if length&0x80 != 0 {
+		size := length & 0x7f
+		if size == 0 || size > 4 || len(data) < 2+size {
+			return nil, 0, errors.New("invalid BER element length")
+		}
+
+		length = 0
+		for _, b := range data[2 : 2+size] {
+			length = length<<8 | int(b)
+		}
+		offset += size
+	}
+
+	if length < 0 || len(data)-offset < length {
+		return nil, 0, errors.New("truncated BER element")
+	}
+
+	e.value = data[offset : offset+length]
+
+	// Constructed element.
+	if e.tag&0x20 != 0 {
+		for rest := e.value; len(rest) > 0; {
+			child, n, err := berDecode(rest)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			e.children = append(e.children, child)
+			rest = rest[n:]
+		}
+	}
+
+	return e, offset + length, nil
+}
+
+// int decodes the value of an integer or enumerated element.
+func (e *berElement) int() int64 {
+	var v int64
+	for n, b := range e.value {
+		if n == 0 && b&0x80 != 0 {
+			v = -1
+		}
+		v = v<<8 | int64(b)
+	}
+
+	return v
+}
+
+// child returns a child element or an empty element if there is none.
+func (e *berElement) child(n int) *berElement {
+	if n < len(e.children) {
+		return e.children[n]
+	}
+
+	return &berElement{}
+}
+
+// ldapUnescape decodes the '\XX' escapes of an LDAP filter value.
+func ldapUnescape(s string) (string, error) {
+	var b strings.Builder
+
+	for n := 0; n < len(s); n++ {
+		if s[n] != '\\' {
+			b.WriteByte(s[n])
+			continue
+		}
+
+		if n+2 >= len(s) {
+			return "", errors.Errorf("invalid escape sequence in filter value: %s", s)
+		}
+
+		decoded, err := hex.DecodeString(s[n+1 : n+3])
+		if err != nil {
+			return "", errors.Errorf("invalid escape sequence in filter value: %s", s)
+		}
+
+		b.Write(decoded)
+		n += 2
+	}
+
+	return b.String(), nil
+}
+
+// ldapEscape escapes the special characters of an LDAP filter value.
+func ldapEscape(s string) string {
+	var b strings.Builder
+
+	for n := 0; n < len(s); n++ {
+		switch c := s[n]; c {
+		case '\\', '*', '(', ')', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+// ldapFilterItem encodes a simple filter item: an attribute, an operator and a value.
+func ldapFilterItem(item string) ([]byte, error) {
+	eq := strings.Index(item, "=")
+	if eq < 1 {
+		return nil, errors.Errorf("invalid filter item: %s", item)
+	}
+
+	attr, value := item[:eq], item[eq+1:]
+
+	tag := ldapFilterEqual
+	switch attr[len(attr)-1] {
+	case '>':
+		tag, attr = ldapFilterGreater, attr[:len(attr)-1]
+	case '<':
+		tag, attr = ldapFilterLess, attr[:len(attr)-1]
+	case '~':
+		tag, attr = ldapFilterApprox, attr[:len(attr)-1]
+	}
+
+	if len(attr) == 0 || strings.ContainsAny(attr, ":()*\\ ") {
+		return nil, errors.Errorf("invalid or unsupported filter item: %s", item)
+	}
+
+	if tag == ldapFilterEqual && value == "*" {
+		return berString(ldapFilterPresent, attr), nil
+	}
+
+	if tag == ldapFilterEqual && strings.Contains(value, "*") {
+		parts := strings.Split(value, "*")
+		substrings := make([]byte, 0)
+
+		for n, part := range parts {
+			if len(part) == 0 {
+				continue
+			}
+
+			unescaped, err := ldapUnescape(part)
+			if err != nil {
+				return nil, err
+			}
+
+			switch n {
+			case 0:
+				substrings = append(substrings, berString(ldapSubstringInitial, unescaped)...)
+			case len(parts) - 1:
+				substrings = append(substrings, berString(ldapSubstringFinal, unescaped)...)
+			default:
+				substrings = append(substrings, berString(ldapSubstringAny, unescaped)...)
+			}
+		}
+
+		return berEncode(ldapFilterSubstring, berString(berOctetString, attr), berEncode(berSequence, substrings)), nil
+	}
+
+	unescaped, err := ldapUnescape(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return berEncode(tag, berString(berOctetString, attr), berString(berOctetString, unescaped)), nil
+}
+
+// ldapParseFilter encodes the parenthesized filter at the start of a string filter representation (RFC 4515) and returns the rest of the string.
+// Extensible match filters are not supported.
+func ldapParseFilter(s string) ([]byte, string, error) {
+	if len(s) < 2 || s[0] != '(' {
+		return nil, "", errors.Errorf("invalid filter: %s", s)
+	}
+
+	switch s[1] {
+	case '&', '|', '!':
+		tag := map[byte]byte{'&': ldapFilterAnd, '|': ldapFilterOr, '!': ldapFilterNot}[s[1]]
+
+		rest := s[2:]
+		filters := make([][]byte, 0)
+		for len(rest) > 0 && rest[0] != ')' {
+			filter, r, err := ldapParseFilter(rest)
+			if err != nil {
+				return nil, "", err
+			}
+
+			filters = append(filters, filter)
+			rest = r
+		}
+
+		if len(rest) == 0 {
+			return nil, "", errors.Errorf("unbalanced parentheses in filter: %s", s)
+		}
+
+		if tag == ldapFilterNot && len(filters) != 1 {
+			return nil, "", errors.Errorf("negation must have a single filter: %s", s)
+		}
+
+		return berEncode(tag, filters...), rest[1:], nil
+	default:
+		end := strings.IndexByte(s, ')')
+		if end < 0 {
+			return nil, "", errors.Errorf("unbalanced parentheses in filter: %s", s)
+		}
+
+		item, err := ldapFilterItem(s[1:end])
+		if err != nil {
+			return nil, "", err
+		}
+
+		return item, s[end+1:], nil
+	}
+}
+
+// ldapCompileFilter encodes a string filter representation (RFC 4515).
+func ldapCompileFilter(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "(") {
+		s = "(" + s + ")"
+	}
+
+	filter, rest, err := ldapParseFilter(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) > 0 {
+		return nil, errors.Errorf("unexpected characters after filter: %s", rest)
+	}
+
+	return filter, nil
+}
+
+// ldapResult checks an LDAPResult sequence, returning an error if the result code is not 'success'.
+func ldapResult(op *berElement) error {
+	if code := op.child(0).int(); code != 0 {
+		return errors.Errorf("ldap result code %d: %s", code, string(op.child(2).value))
+	}
+
+	return nil
+}
+
+// send writes an LDAP message with an optional list of controls and returns its message ID.
+func (c *ldapConn) send(op []byte, controls ...[]byte) (int64, error) {
+	c.id++
+
+	parts := [][]byte{berInt(berInteger, c.id), op}
+	if len(controls) > 0 {
+		parts = append(parts, berEncode(ldapControls, controls...))
+	}
+
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+
+	if _, err := c.conn.Write(berEncode(berSequence, parts...)); err != nil {
+		return 0, errors.Wrap(err, "ldap request failure")
+	}
+
+	return c.id, nil
+}
+
+// receive reads the next LDAP message.
+func (c *ldapConn) receive() (*berElement, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return nil, errors.Wrap(err, "ldap response failure")
+	}
+
+	raw := header
+	length := int(header[1])
+	if length&0x80 != 0 {
+		size := length & 0x7f
+		if size == 0 || size > 4 {
+			return nil, errors.New("ldap response failure: invalid message length")
+		}
+
+		lengthBytes := make([]byte, size)
+		if _, err := io.ReadFull(c.reader, lengthBytes); err != nil {
+			return nil, errors.Wrap(err, "ldap response failure")
+		}
+		raw = append(raw, lengthBytes...)
+
+		length = 0
+		for _, b := range lengthBytes {
+			length = length<<8 | int(b)
+		}
+	}
+
+	if length > ldapMaxMessageSize {
+		return nil, errors.Errorf("ldap response failure: message is too large: %d bytes", length)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, body); err != nil {
+		return nil, errors.Wrap(err, "ldap response failure")
+	}
+
+	msg, _, err := berDecode(append(raw, body...))
+	if err != nil {
+		return nil, errors.Wrap(err, "ldap response parsing failure")
+	}
+
+	if msg.tag != berSequence || len(msg.children) < 2 {
+		return nil, errors.New("ldap response parsing failure: invalid message")
+	}
+
+	return msg, nil
+}
+
+// roundtrip sends a request and reads its response, which must have the expected tag.
+func (c *ldapConn) roundtrip(op []byte, tag byte) (*berElement, error) {
+	id, err := c.send(op)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := c.receive()
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.child(0).int() != id || msg.child(1).tag != tag {
+		return nil, errors.New("ldap response parsing failure: unexpected message")
+	}
+
+	return msg.child(1), nil
+}
+
+// startTLS upgrades the connection to TLS with the StartTLS extended operation.
+func (c *ldapConn) startTLS(tlsConfig *tls.Config) error {
+	resp, err := c.roundtrip(berEncode(ldapExtendedRequest, berString(ldapExtendedName, ldapStartTLSOID)), ldapExtendedResponse)
+	if err != nil {
+		return errors.Wrap(err, "ldap starttls failure")
+	}
+
+	if err := ldapResult(resp); err != nil {
+		return errors.Wrap(err, "ldap starttls failure")
+	}
+
+	conn := tls.Client(c.conn, tlsConfig)
+	if err := conn.Handshake(); err != nil {
+		return errors.Wrap(err, "ldap starttls failure")
+	}
+
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+
+	return nil
+}
+
+// bind performs a simple bind. An empty DN and password bind anonymously.
+func (c *ldapConn) bind(dn string, password string) error {
+	resp, err := c.roundtrip(berEncode(ldapBindRequest, berInt(berInteger, 3), berString(berOctetString, dn), berString(ldapSimpleAuth, password)), ldapBindResponse)
+	if err != nil {
+		return errors.Wrap(err, "ldap bind failure")
+	}
+
+	if err := ldapResult(resp); err != nil {
+		return errors.Wrap(err, "ldap bind failure")
+	}
+
+	return nil
+}
+
+// search performs a search, requesting pages of 'pageSize' entries with the Simple Paged Results control unless the page size is 0.
+// Search result references (referrals) are not followed.
+func (c *ldapConn) search(base string, scope int64, filter []byte, attributes []string, pageSize int) ([]*ldapEntry, error) {
+	attrs := make([][]byte, 0, len(attributes))
+	for _, a := range attributes {
+		attrs = append(attrs, berString(berOctetString, a))
+	}
+
+	request := berEncode(ldapSearchRequest,
+		berString(berOctetString, base),
+		berInt(berEnumerated, scope),
+		// Never dereference aliases, no size and time limits, return attribute values.
+		berInt(berEnumerated, 0), berInt(berInteger, 0), berInt(berInteger, 0), berBool(false),
+		filter,
+		berEncode(berSequence, attrs...),
+	)
+
+	entries := make([]*ldapEntry, 0)
+	cookie := ""
+	for {
+		var controls [][]byte
+		if pageSize > 0 {
+			value := berEncode(berSequence, berInt(berInteger, int64(pageSize)), berString(berOctetString, cookie))
+			controls = append(controls, berEncode(berSequence, berString(berOctetString, ldapPagedResultsOID), berBool(false), berEncode(berOctetString, value)))
+		}
+
+		id, err := c.send(request, controls...)
+		if err != nil {
+			return nil, err
+		}
+
+		var done *berElement
+		for done == nil {
+			msg, err := c.receive()
+			if err != nil {
+				return nil, err
+			}
+
+			if msg.child(0).int() != id {
+				return nil, errors.New("ldap response parsing failure: unexpected message")
+			}
+
+			switch op := msg.child(1); op.tag {
+			case ldapSearchResultEntry:
+				entry := &ldapEntry{DN: string(op.child(0).value), Attributes: make(map[string][]string)}
+				for _, attr := range op.child(1).children {
+					name := strings.ToLower(string(attr.child(0).value))
+					for _, v := range attr.child(1).children {
+						entry.Attributes[name] = append(entry.Attributes[name], string(v.value))
+					}
+				}
+
+				entries = append(entries, entry)
+			case ldapSearchResultReference:
+				continue
+			case ldapSearchResultDone:
+				if err := ldapResult(op); err != nil {
+					return nil, errors.Wrap(err, "ldap search failure")
+				}
+
+				done = msg
+			default:
+				return nil, errors.New("ldap response parsing failure: unexpected message")
+			}
+		}
+
+		// Find the cookie of the next page.
+		cookie = ""
+		for _, control := range done.child(2).children {
+			value := control.child(len(control.children) - 1)
+			if string(control.child(0).value) != ldapPagedResultsOID || len(control.children) < 2 || value.tag != berOctetString {
+				continue
+			}
+
+			paged, _, err := berDecode(value.value)
+			if err != nil {
+				return nil, errors.Wrap(err, "ldap response parsing failure")
+			}
+
+			cookie = string(paged.child(1).value)
+		}
+
+		if pageSize == 0 || len(cookie) == 0 {
+			return entries, nil
+		}
+	}
+}
+
+// close unbinds and closes the connection.
+func (c *ldapConn) close() {
+	c.send(berEncode(ldapUnbindRequest))
+	c.conn.Close()
+}
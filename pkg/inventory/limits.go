@@ -0,0 +1,159 @@
+package inventory
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// LimitPattern converts a filter expression (see ParseFilters) into an Ansible host pattern suitable for the '--limit' option.
+// The pattern references the groups produced by the inventory tree, so it selects the same hosts as the expression would.
+// Regular expressions are only supported for the 'host' key. Role and service keys require the role and service group axes to follow the environment axis.
+func (i *Inventory) LimitPattern(expr string) (string, error) {
+	cfg := i.Config
+	sep := cfg.Txt.Keys.Separator
+
+	filters, err := i.ParseFilters(expr)
+	if err != nil {
+		return "", err
+	}
+
+	axes, err := i.groupAxes()
+	if err != nil {
+		return "", err
+	}
+
+	// Role and service groups are only named after the environment, role and service if these are the leading axes.
+	for idx, key := range []string{cfg.Txt.Keys.Role, cfg.Txt.Keys.Srv} {
+		if len(axes) > idx+1 && axes[idx+1] == defaultGroupAxes[idx+1] {
+			continue
+		}
+
+		for _, f := range filters {
+			if f.Key == key {
+				return "", errors.Errorf("key %s is not supported in limit expressions with the configured group axes", key)
+			}
+		}
+	}
+
+	if len(filters) == 0 {
+		return ansibleRootGroup, nil
+	}
+
+	include := make(map[string][]string)
+	exclude := make([]string, 0)
+	hosts := make([]string, 0)
+
+	for _, f := range filters {
+		switch {
+		case f.Key == "host" && f.Operator == "in":
+			hosts = append(hosts, f.Values...)
+		case f.Key == "host" && f.Operator == "regex":
+			for _, v := range f.Values {
+				hosts = append(hosts, "~"+v)
+			}
+		case f.Key == "host" && f.Operator == "notin":
+			exclude = append(exclude, f.Values...)
+		case f.Key == "host" && f.Operator == "notregex":
+			for _, v := range f.Values {
+				exclude = append(exclude, "~"+v)
+			}
+		case f.Operator == "in":
+			if _, ok := include[f.Key]; ok {
+				return "", errors.Errorf("duplicate key in limit expression: %s", f.Key)
+			}
+			include[f.Key] = f.Values
+		case f.Operator == "notin":
+			for _, v := range f.Values {
+				switch f.Key {
+				case cfg.Txt.Keys.Env:
+					exclude = append(exclude, v)
+				case cfg.Txt.Keys.Role:
+					exclude = append(exclude, ansibleRootGroup+sep+v)
+				case cfg.Txt.Keys.Os:
+					exclude = append(exclude, ansibleRootGroup+sep+"host"+sep+v)
+				default:
+					return "", errors.Errorf("exclusion is not supported for key %s in limit expressions", f.Key)
+				}
+			}
+		default:
+			return "", errors.Errorf("operator %s is not supported for key %s in limit expressions", f.Operator, f.Key)
+		}
+	}
+
+	// Build the base groups: root>environment>role>service.
+	envs, ok := include[cfg.Txt.Keys.Env]
+	if !ok {
+		envs = []string{ansibleRootGroup}
+	}
+
+	groups := make([]string, 0)
+	for _, env := range envs {
+		roles, ok := include[cfg.Txt.Keys.Role]
+		if !ok {
+			if _, ok := include[cfg.Txt.Keys.Srv]; ok {
+				return "", errors.Errorf("key %s requires key %s in limit expressions", cfg.Txt.Keys.Srv, cfg.Txt.Keys.Role)
+			}
+
+			groups = append(groups, env)
+			continue
+		}
+
+		for _, role := range roles {
+			srvs, ok := include[cfg.Txt.Keys.Srv]
+			if !ok {
+				groups = append(groups, env+sep+role)
+				continue
+			}
+
+			for _, srv := range srvs {
+				groups = append(groups, env+sep+role+sep+srv)
+			}
+		}
+	}
+
+	// Explicit hosts restrict the base groups unless no attributes have been specified.
+	pattern := make([]string, 0)
+	if len(hosts) > 0 && len(include) == 0 {
+		pattern = append(pattern, hosts...)
+	} else {
+		pattern = append(pattern, groups...)
+
+		if len(hosts) > 1 {
+			return "", errors.New("several host values cannot be combined with attributes in limit expressions")
+		} else if len(hosts) == 1 {
+			pattern = append(pattern, "&"+hosts[0])
+		}
+	}
+
+	// Operating systems are an intersection with the special host groups.
+	if oses, ok := include[cfg.Txt.Keys.Os]; ok {
+		if len(oses) > 1 {
+			return "", errors.Errorf("several values for key %s are not supported in limit expressions", cfg.Txt.Keys.Os)
+		}
+
+		pattern = append(pattern, "&"+ansibleRootGroup+sep+"host"+sep+oses[0])
+	}
+
+	for _, e := range exclude {
+		pattern = append(pattern, "!"+e)
+	}
+
+	return strings.Join(pattern, ":"), nil
+}
+
+// ExportLimits exports the named limit expressions defined in the configuration into a map of Ansible host patterns.
+func (i *Inventory) ExportLimits(limits map[string]string) error {
+	cfg := i.Config
+
+	for name, expr := range cfg.Limits {
+		pattern, err := i.LimitPattern(expr)
+		if err != nil {
+			return errors.Wrapf(err, "%s: limit expression processing failure", name)
+		}
+
+		limits[name] = pattern
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package inventory
+
+import "testing"
+
+func TestInventory_LimitPattern(t *testing.T) {
+	cfg := &Config{}
+	cfg.Txt.Keys.Separator = "_"
+	cfg.Txt.Keys.Os = "OS"
+	cfg.Txt.Keys.Env = "ENV"
+	cfg.Txt.Keys.Role = "ROLE"
+	cfg.Txt.Keys.Srv = "SRV"
+	cfg.Txt.Keys.Vars = "VARS"
+
+	testInventory := &Inventory{
+		Config: cfg,
+	}
+
+	type args struct {
+		expr string
+	}
+	tests := []struct {
+		name    string
+		i       *Inventory
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			i:       testInventory,
+			args:    args{expr: ""},
+			want:    "all",
+			wantErr: false,
+		},
+		{
+			name:    "env-role-os",
+			i:       testInventory,
+			args:    args{expr: "env=prod,role=db,os=linux"},
+			want:    "prod_db:&all_host_linux",
+			wantErr: false,
+		},
+		{
+			name:    "envs-role-srv",
+			i:       testInventory,
+			args:    args{expr: "env=prod|lab,role=app,srv=tomcat"},
+			want:    "prod_app_tomcat:lab_app_tomcat",
+			wantErr: false,
+		},
+		{
+			name:    "role-exclusions",
+			i:       testInventory,
+			args:    args{expr: "role=app,env!=lab,host!=app01.infra.local"},
+			want:    "all_app:!lab:!app01.infra.local",
+			wantErr: false,
+		},
+		{
+			name:    "hosts",
+			i:       testInventory,
+			args:    args{expr: "host=app01.infra.local|app02.infra.local"},
+			want:    "app01.infra.local:app02.infra.local",
+			wantErr: false,
+		},
+		{
+			name:    "host-regex-env",
+			i:       testInventory,
+			args:    args{expr: "env=prod,host~^app"},
+			want:    "prod:&~^app",
+			wantErr: false,
+		},
+		{
+			name:    "invalid-srv-without-role",
+			i:       testInventory,
+			args:    args{expr: "srv=tomcat"},
+			want:    "",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-attribute-regex",
+			i:       testInventory,
+			args:    args{expr: "role~^app"},
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.i.LimitPattern(tt.args.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Inventory.LimitPattern() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Inventory.LimitPattern() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
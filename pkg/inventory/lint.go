@@ -0,0 +1,218 @@
+package inventory
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// Maximum length of a single character string in a TXT record.
+	lintStringLength int = 255
+	// Maximum length of a DNS name in presentation format, without the trailing dot.
+	lintNameLength int = 253
+	// Maximum length of a DNS label.
+	lintLabelLength int = 63
+	// Maximum size of a DNS message.
+	lintMessageSize int = 65535
+	// DNS response size limit over UDP without EDNS0.
+	lintUDPSize int = 512
+
+	// Lint problem severities.
+	lintError   string = "error"
+	lintWarning string = "warning"
+)
+
+// lintLabelRegex matches hostname labels: letters, digits and hyphens, not starting or ending with a hyphen.
+var lintLabelRegex = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?$`)
+
+// lintHostname checks a hostname against the DNS name length limits and the hostname label charset.
+func lintHostname(host string) string {
+	name := strings.TrimSuffix(host, ".")
+
+	if len(name) == 0 {
+		return "hostname is empty"
+	}
+
+	if len(name) > lintNameLength {
+		return fmt.Sprintf("hostname is %d bytes long, the limit is %d bytes", len(name), lintNameLength)
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if len(label) > lintLabelLength {
+			return fmt.Sprintf("label %q is %d bytes long, the limit is %d bytes", label, len(label), lintLabelLength)
+		}
+
+		if !lintLabelRegex.MatchString(label) {
+			return fmt.Sprintf("label %q contains characters other than letters, digits and hyphens or starts or ends with a hyphen", label)
+		}
+	}
+
+	return ""
+}
+
+// lintOwner returns the DNS name that would hold a host record: the host itself or, in the no-transfer mode, the no-transfer host of its zone.
+func (i *Inventory) lintOwner(host string) string {
+	cfg := i.Config
+
+	if !cfg.DNS.Notransfer.Enabled {
+		return dns.Fqdn(host)
+	}
+
+	// The longest matching zone wins.
+	var zone string
+	for _, z := range cfg.DNS.Zones {
+		if z := dns.Fqdn(z); dns.IsSubDomain(z, dns.Fqdn(host)) && len(z) > len(zone) {
+			zone = z
+		}
+	}
+
+	if len(zone) == 0 {
+		return dns.Fqdn(host)
+	}
+
+	// Only the configuration is used to find the no-transfer hosts of a zone. Records are expected to be published to the first one.
+	return (&DNSDatasource{Config: cfg}).notransferHosts(zone)[0]
+}
+
+// lintTXT returns the content of the TXT record holding a host record.
+func (i *Inventory) lintTXT(r *DatasourceRecord) string {
+	cfg := i.Config
+
+	if cfg.DNS.Notransfer.Enabled {
+		return r.Hostname + cfg.DNS.Notransfer.Separator + r.Attributes
+	}
+
+	return r.Attributes
+}
+
+// LintRecords checks host records against the constraints of DNS TXT records:
+// the length of a single character string, the size of the records published under a single name and the hostname charset.
+// Problems are ordered by hostname.
+func (i *Inventory) LintRecords(records []*DatasourceRecord) []*LintProblem {
+	cfg := i.Config
+	problems := make([]*LintProblem, 0)
+
+	udpSize := lintUDPSize
+	if cfg.DNS.UDPSize > 0 {
+		udpSize = int(cfg.DNS.UDPSize)
+	}
+
+	owners := make([]string, 0)
+	rrsets := make(map[string][]dns.RR)
+	hosts := make(map[string]map[string]bool)
+	seen := make(map[string]bool)
+
+	for _, r := range records {
+		if msg := lintHostname(r.Hostname); len(msg) > 0 && !seen[r.Hostname] {
+			problems = append(problems, &LintProblem{Host: r.Hostname, Severity: lintError, Check: "hostname", Message: msg})
+		}
+		seen[r.Hostname] = true
+
+		txt := i.lintTXT(r)
+		if len(txt) > lintStringLength {
+			problems = append(problems, &LintProblem{
+				Host:     r.Hostname,
+				Record:   r.Attributes,
+				Severity: lintError,
+				Check:    "string-length",
+				Message:  fmt.Sprintf("TXT string is %d bytes long, the limit is %d bytes", len(txt), lintStringLength),
+			})
+		}
+
+		owner := i.lintOwner(r.Hostname)
+		if _, ok := rrsets[owner]; !ok {
+			owners = append(owners, owner)
+			hosts[owner] = make(map[string]bool)
+		}
+
+		// Strings over the limit have already been reported, split them to estimate the record size.
+		rr := &dns.TXT{Hdr: dns.RR_Header{Name: owner, Rrtype: dns.TypeTXT, Class: dns.ClassINET}}
+		for len(txt) > lintStringLength {
+			rr.Txt = append(rr.Txt, txt[:lintStringLength])
+			txt = txt[lintStringLength:]
+		}
+		rr.Txt = append(rr.Txt, txt)
+
+		rrsets[owner] = append(rrsets[owner], rr)
+		hosts[owner][r.Hostname] = true
+	}
+
+	// Every name is queried with a single request, so all of its records must fit into a single response.
+	for _, owner := range owners {
+		msg := new(dns.Msg)
+		msg.SetQuestion(owner, dns.TypeTXT)
+		msg.Answer = rrsets[owner]
+		size := msg.Len()
+
+		var severity, message string
+		switch {
+		case size > lintMessageSize:
+			severity, message = lintError, fmt.Sprintf("TXT records of %s take %d bytes, the DNS message size limit is %d bytes", owner, size, lintMessageSize)
+		case size > udpSize:
+			severity, message = lintWarning, fmt.Sprintf("TXT records of %s take %d bytes, responses over %d bytes are truncated and retried over TCP", owner, size, udpSize)
+		default:
+			continue
+		}
+
+		names := make([]string, 0, len(hosts[owner]))
+		for host := range hosts[owner] {
+			names = append(names, host)
+		}
+		sort.Strings(names)
+
+		for _, host := range names {
+			problems = append(problems, &LintProblem{Host: host, Severity: severity, Check: "rrset-size", Message: message})
+		}
+	}
+
+	sort.SliceStable(problems, func(a, b int) bool {
+		return i.CompareNames(problems[a].Host, problems[b].Host) < 0
+	})
+
+	return problems
+}
+
+// LintHosts renders host attributes into host records and checks them with LintRecords.
+// Attribute sets that cannot be rendered are reported as problems as well.
+func (i *Inventory) LintHosts(hosts map[string][]*HostAttributes) []*LintProblem {
+	problems := make([]*LintProblem, 0)
+	records := make([]*DatasourceRecord, 0)
+
+	for host, sets := range hosts {
+		for _, attrs := range sets {
+			rendered, err := i.RenderAttributes(attrs)
+			if err != nil {
+				problems = append(problems, &LintProblem{Host: host, Severity: lintError, Check: "attributes", Message: err.Error()})
+				continue
+			}
+
+			records = append(records, &DatasourceRecord{Hostname: host, Attributes: rendered})
+		}
+	}
+
+	// Keep the record order stable for reproducible size estimates.
+	sort.SliceStable(records, func(a, b int) bool { return records[a].Hostname < records[b].Hostname })
+
+	problems = append(problems, i.LintRecords(records)...)
+	sort.SliceStable(problems, func(a, b int) bool {
+		return i.CompareNames(problems[a].Host, problems[b].Host) < 0
+	})
+
+	return problems
+}
+
+// LintErrors counts the problems that make host records unusable.
+func LintErrors(problems []*LintProblem) int {
+	n := 0
+	for _, p := range problems {
+		if p.Severity == lintError {
+			n++
+		}
+	}
+
+	return n
+}
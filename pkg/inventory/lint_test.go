@@ -0,0 +1,93 @@
+package inventory
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInventory_LintRecords(t *testing.T) {
+	long := "OS=linux;ENV=dev;ROLE=app;SRV=;VARS=key=" + strings.Repeat("x", 210)
+
+	many := make([]*DatasourceRecord, 0)
+	for n := 0; n < 300; n++ {
+		many = append(many, &DatasourceRecord{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=" + strings.Repeat("s", 200)})
+	}
+
+	type check struct {
+		Host     string
+		Severity string
+		Check    string
+	}
+
+	tests := []struct {
+		name       string
+		notransfer bool
+		records    []*DatasourceRecord
+		want       []check
+	}{
+		{
+			name:    "valid",
+			records: []*DatasourceRecord{{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=;VARS="}},
+			want:    []check{},
+		},
+		{
+			name: "invalid-hostname",
+			records: []*DatasourceRecord{
+				{Hostname: "app_01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=;VARS="},
+				{Hostname: "app_01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=db;SRV=;VARS="},
+				{Hostname: "-app02.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=;VARS="},
+			},
+			want: []check{{"-app02.infra.local", lintError, "hostname"}, {"app_01.infra.local", lintError, "hostname"}},
+		},
+		{
+			// The hostname is prepended to the attribute string in the no-transfer mode.
+			name:       "invalid-string-length-notransfer",
+			notransfer: true,
+			records:    []*DatasourceRecord{{Hostname: "app01.infra.local", Attributes: long}},
+			want:       []check{{"app01.infra.local", lintError, "string-length"}},
+		},
+		{
+			name:       "valid-string-length",
+			notransfer: false,
+			records:    []*DatasourceRecord{{Hostname: "app01.infra.local", Attributes: long}},
+			want:       []check{},
+		},
+		{
+			// Records of all hosts of a zone share the no-transfer host.
+			name:       "invalid-rrset-size-notransfer",
+			notransfer: true,
+			records: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=" + strings.Repeat("s", 200)},
+				{Hostname: "app02.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=" + strings.Repeat("s", 200)},
+				{Hostname: "app03.other.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=" + strings.Repeat("s", 200)},
+			},
+			want: []check{{"app01.infra.local", lintWarning, "rrset-size"}, {"app02.infra.local", lintWarning, "rrset-size"}},
+		},
+		{
+			name:    "invalid-rrset-size-message",
+			records: many,
+			want:    []check{{"app01.infra.local", lintError, "rrset-size"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.DNS.Zones = []string{"infra.local."}
+			cfg.DNS.Notransfer.Enabled = tt.notransfer
+			cfg.DNS.Notransfer.Host = "ansible-dns-inventory"
+			cfg.DNS.Notransfer.Separator = ":"
+
+			i := &Inventory{Config: cfg}
+
+			got := make([]check, 0)
+			for _, p := range i.LintRecords(tt.records) {
+				got = append(got, check{p.Host, p.Severity, p.Check})
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inventory.LintRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package inventory
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MigrateSeparator rewrites all host records, replacing the 'from' separator with the 'to' separator in service identifiers.
+// It reports the group names that change as a result. If dryRun is true, nothing is written to the datasource.
+// Records that cannot be parsed are published unchanged, so that datasources which clear existing records before publishing do not lose them.
+// Role defaults are taken into account when building the group report, but never written to the rewritten records.
+func (i *Inventory) MigrateSeparator(from string, to string, dryRun bool) (*SeparatorMigration, error) {
+	log := i.Logger
+
+	if len(from) == 0 || len(to) == 0 || from == to {
+		return nil, errors.Errorf("invalid separator migration: '%s' to '%s'", from, to)
+	}
+
+	axes, err := i.groupAxes()
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := i.Datasource.GetAllRecords()
+	if err != nil {
+		return nil, errors.Wrap(err, "record loading failure")
+	}
+
+	report := &SeparatorMigration{
+		Records:   len(records),
+		Skipped:   make([]string, 0),
+		Groups:    make(map[string]string),
+		Conflicts: make(map[string][]string),
+	}
+	hosts := make(map[string][]*HostAttributes)
+	migrated := make([]*DatasourceRecord, 0, len(records))
+
+	for _, r := range records {
+		attrs, err := i.ParseAttributes(r.Attributes)
+		if err != nil {
+			log.Warnf("[%s] leaving host record as is: %v", r.Hostname, err)
+			report.Skipped = append(report.Skipped, r.Hostname)
+			migrated = append(migrated, r)
+			continue
+		}
+
+		hosts[r.Hostname] = append(hosts[r.Hostname], splitAttributes(attrs)...)
+
+		// Service identifiers taken from role defaults are not stored in the record.
+		stored := i.decodeAttributes(r.Attributes)
+		if !strings.Contains(stored.Srv, from) {
+			migrated = append(migrated, r)
+			continue
+		}
+
+		if strings.Contains(stored.Srv, to) {
+			log.Warnf("[%s] service identifier already contains '%s', hierarchies will be merged: %s", r.Hostname, to, stored.Srv)
+		}
+
+		stored.Srv = strings.ReplaceAll(stored.Srv, from, to)
+
+		// A service identifier present in the record takes precedence over the defaults, so the migrated attributes are validated with it.
+		attrs.Srv = stored.Srv
+		if err := i.Validator.Struct(attrs); err != nil {
+			return nil, errors.Wrapf(err, "%s: attribute validation error", r.Hostname)
+		}
+
+		migrated = append(migrated, &DatasourceRecord{Hostname: r.Hostname, Attributes: i.rewriteAttribute(r.Attributes, i.Config.Txt.Keys.Srv, stored.Srv)})
+		report.Changed++
+	}
+
+	// Build the current tree to find out which group names are going to change.
+	tree := NewTree()
+	tree.ImportHosts(hosts, from, axes)
+
+	groups := make(map[string][]string)
+	tree.ExportGroups(groups)
+
+	collapsed := make(map[string][]string)
+	for name := range groups {
+		if renamed := strings.ReplaceAll(name, from, to); renamed != name {
+			report.Groups[name] = renamed
+			collapsed[renamed] = append(collapsed[renamed], name)
+		} else {
+			collapsed[name] = append(collapsed[name], name)
+		}
+	}
+
+	for name, sources := range collapsed {
+		if len(sources) > 1 {
+			sort.Strings(sources)
+			report.Conflicts[name] = sources
+		}
+	}
+
+	if dryRun || report.Changed == 0 {
+		return report, nil
+	}
+
+	if err := i.publish("migrate-separator", migrated); err != nil {
+		return nil, errors.Wrap(err, "record publishing failure")
+	}
+
+	return report, nil
+}
+
+// rewriteAttribute replaces the value of an attribute in a host record, keeping the other attributes as they are stored.
+func (i *Inventory) rewriteAttribute(raw string, key string, value string) string {
+	cfg := i.Config
+	items := strings.Split(raw, cfg.Txt.Kv.Separator)
+
+	for n, item := range items {
+		if kv := strings.SplitN(item, cfg.Txt.Kv.Equalsign, 2); kv[0] == key {
+			items[n] = key + cfg.Txt.Kv.Equalsign + value
+		}
+	}
+
+	return strings.Join(items, cfg.Txt.Kv.Separator)
+}
@@ -0,0 +1,184 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/creasty/defaults"
+	"go.uber.org/zap"
+)
+
+// testMigrationDatasource is a Datasource keeping the host records published by a migration.
+type testMigrationDatasource struct {
+	records   []*DatasourceRecord
+	published []*DatasourceRecord
+}
+
+func (d *testMigrationDatasource) GetAllRecords() ([]*DatasourceRecord, error) {
+	return d.records, nil
+}
+
+func (d *testMigrationDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error) {
+	return nil, nil
+}
+
+func (d *testMigrationDatasource) PublishRecords(records []*DatasourceRecord) error {
+	d.published = records
+
+	return nil
+}
+
+func (d *testMigrationDatasource) Close() {}
+
+func TestInventory_MigrateSeparator(t *testing.T) {
+	tests := []struct {
+		name          string
+		from          string
+		to            string
+		dryRun        bool
+		defaults      map[string]map[string]string
+		records       []*DatasourceRecord
+		want          *SeparatorMigration
+		wantGroups    map[string]string
+		wantPublished []*DatasourceRecord
+		wantErr       bool
+	}{
+		{
+			// Unparseable records are published unchanged along with the rewritten ones.
+			name: "valid",
+			from: "-",
+			to:   "_",
+			records: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat-public;VARS="},
+				{Hostname: "bad01.infra.local", Attributes: "OS=linux;ENV=dev"},
+				{Hostname: "db01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=db;SRV=postgres;VARS="},
+			},
+			want: &SeparatorMigration{Records: 3, Changed: 1, Skipped: []string{"bad01.infra.local"}, Conflicts: map[string][]string{}},
+			wantGroups: map[string]string{
+				"dev-app-tomcat-public": "dev_app_tomcat_public",
+				"dev-db-postgres":       "dev_db_postgres",
+			},
+			wantPublished: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat_public;VARS="},
+				{Hostname: "bad01.infra.local", Attributes: "OS=linux;ENV=dev"},
+				{Hostname: "db01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=db;SRV=postgres;VARS="},
+			},
+		},
+		{
+			name:   "valid-dry-run",
+			from:   "-",
+			to:     "_",
+			dryRun: true,
+			records: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat-public;VARS="},
+			},
+			want:       &SeparatorMigration{Records: 1, Changed: 1, Skipped: []string{}, Conflicts: map[string][]string{}},
+			wantGroups: map[string]string{"dev-app-tomcat-public": "dev_app_tomcat_public"},
+		},
+		{
+			// Distinct groups collapse into one after the migration.
+			name: "valid-conflicts",
+			from: "-",
+			to:   "_",
+			records: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat-public;VARS="},
+				{Hostname: "app02.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat_public;VARS="},
+			},
+			want: &SeparatorMigration{Records: 2, Changed: 1, Skipped: []string{}, Conflicts: map[string][]string{
+				"all_app_tomcat_public": {"all-app-tomcat-public", "all-app-tomcat_public"},
+				"dev_app_tomcat_public": {"dev-app-tomcat-public", "dev-app-tomcat_public"},
+			}},
+			wantGroups: map[string]string{
+				"dev-app-tomcat-public": "dev_app_tomcat_public",
+				"dev-app-tomcat_public": "dev_app_tomcat_public",
+			},
+			wantPublished: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat_public;VARS="},
+				{Hostname: "app02.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat_public;VARS="},
+			},
+		},
+		{
+			// Nothing is published if no service identifier changes.
+			name: "valid-no-op",
+			from: "-",
+			to:   "_",
+			records: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat_public;VARS="},
+				{Hostname: "bad01.infra.local", Attributes: "OS=linux;ENV=dev"},
+			},
+			want: &SeparatorMigration{Records: 2, Skipped: []string{"bad01.infra.local"}, Conflicts: map[string][]string{}},
+		},
+		{
+			// Role defaults are used for the group report, but never written to the records.
+			name: "valid-defaults",
+			from: "-",
+			to:   "_",
+			defaults: map[string]map[string]string{
+				"app": {"OS": "linux", "SRV": "tomcat-public", "VARS": "heap=2g"},
+			},
+			records: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "ENV=dev;ROLE=app;SRV=nginx-public"},
+				{Hostname: "app02.infra.local", Attributes: "ENV=dev;ROLE=app"},
+			},
+			want: &SeparatorMigration{Records: 2, Changed: 1, Skipped: []string{}, Conflicts: map[string][]string{}},
+			wantGroups: map[string]string{
+				"dev-app-nginx-public":  "dev_app_nginx_public",
+				"dev-app-tomcat-public": "dev_app_tomcat_public",
+			},
+			wantPublished: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "ENV=dev;ROLE=app;SRV=nginx_public"},
+				{Hostname: "app02.infra.local", Attributes: "ENV=dev;ROLE=app"},
+			},
+		},
+		{
+			name:    "invalid-separator",
+			from:    "_",
+			to:      "_",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			if err := defaults.Set(cfg); err != nil {
+				t.Fatal(err)
+			}
+			cfg.Defaults = tt.defaults
+
+			i, err := New(cfg, zap.NewNop().Sugar())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			t.Cleanup(i.Close)
+
+			ds := &testMigrationDatasource{records: tt.records}
+			i.Datasource = ds
+
+			got, err := i.MigrateSeparator(tt.from, tt.to, tt.dryRun)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Inventory.MigrateSeparator() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			for name, renamed := range tt.wantGroups {
+				if got.Groups[name] != renamed {
+					t.Errorf("Inventory.MigrateSeparator() group %s = %v, want %v", name, got.Groups[name], renamed)
+				}
+			}
+
+			got.Groups = nil
+			tt.want.Groups = nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inventory.MigrateSeparator() = %+v, want %+v", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(ds.published, tt.wantPublished) {
+				t.Errorf("published records = %v, want %v", ds.published, tt.wantPublished)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package inventory
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// normalizeValue normalizes a single attribute value.
+func normalizeValue(value string, lowercase bool, values map[string]string) string {
+	if lowercase {
+		value = strings.ToLower(value)
+	}
+
+	if v, ok := values[strings.ToLower(value)]; ok {
+		return v
+	}
+
+	return value
+}
+
+// normalizeList normalizes every element of a comma-separated list of attribute values.
+func normalizeList(list string, lowercase bool, values map[string]string) string {
+	elements := strings.Split(list, ",")
+	for n, element := range elements {
+		elements[n] = normalizeValue(element, lowercase, values)
+	}
+
+	return strings.Join(elements, ",")
+}
+
+// attributeNormalizer returns a function that applies value normalization rules from the configuration to a set of host attributes.
+// It returns nil if no normalization rules are configured.
+func (i *Inventory) attributeNormalizer() func(attrs *HostAttributes) {
+	cfg := i.Config
+	lowercase := cfg.Normalize.Lowercase
+
+	if !lowercase && len(cfg.Normalize.Values) == 0 {
+		return nil
+	}
+
+	// Viper folds map keys to lower case, so value maps are matched case-insensitively.
+	maps := make(map[string]map[string]string)
+	for key, values := range cfg.Normalize.Values {
+		m := make(map[string]string, len(values))
+		for from, to := range values {
+			m[strings.ToLower(from)] = to
+		}
+
+		maps[strings.ToLower(key)] = m
+	}
+
+	osMap := maps[strings.ToLower(cfg.Txt.Keys.Os)]
+	envMap := maps[strings.ToLower(cfg.Txt.Keys.Env)]
+	roleMap := maps[strings.ToLower(cfg.Txt.Keys.Role)]
+	srvMap := maps[strings.ToLower(cfg.Txt.Keys.Srv)]
+
+	return func(attrs *HostAttributes) {
+		attrs.OS = normalizeValue(attrs.OS, lowercase, osMap)
+		attrs.Env = normalizeValue(attrs.Env, lowercase, envMap)
+		attrs.Role = normalizeList(attrs.Role, lowercase, roleMap)
+		attrs.Srv = normalizeList(attrs.Srv, lowercase, srvMap)
+	}
+}
+
+// lookupFold returns the value of a map entry whose key matches a key case-insensitively. Viper folds map keys to lower case.
+func lookupFold(m map[string]string, key string) string {
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// mappedAttributes builds a host attribute string from values looked up by host attribute key, e.g. from the columns of a CSV file.
+// Missing values fall back to the defaults. Values are normalized with the 'normalize' rules, so that external data passes attribute validation.
+func mappedAttributes(cfg *Config, lookup func(key string) string, defaults map[string]string) (string, error) {
+	keys := append([]string{cfg.Txt.Keys.Os, cfg.Txt.Keys.Env, cfg.Txt.Keys.Role, cfg.Txt.Keys.Srv, cfg.Txt.Keys.Vars}, cfg.Txt.Keys.Extra...)
+	keys = append(keys, cfg.Txt.Keys.ID)
+
+	pairs := make([]string, 0, len(keys))
+	for n, key := range keys {
+		value := lookup(key)
+		if len(value) == 0 {
+			value = lookupFold(defaults, key)
+		}
+
+		// Extra attributes and the host identifier are optional.
+		if n > 4 && len(value) == 0 {
+			continue
+		}
+
+		values := make(map[string]string)
+		for k, v := range cfg.Normalize.Values {
+			if strings.EqualFold(k, key) {
+				for from, to := range v {
+					values[strings.ToLower(from)] = to
+				}
+			}
+		}
+		value = normalizeList(value, cfg.Normalize.Lowercase, values)
+
+		if strings.Contains(value, cfg.Txt.Kv.Separator) {
+			return "", errors.Errorf("%s value contains the attribute separator: %s", key, value)
+		}
+
+		pairs = append(pairs, key+cfg.Txt.Kv.Equalsign+value)
+	}
+
+	return strings.Join(pairs, cfg.Txt.Kv.Separator), nil
+}
@@ -0,0 +1,56 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInventory_attributeNormalizer(t *testing.T) {
+	cfg := &Config{}
+	cfg.Txt.Keys.Os = "OS"
+	cfg.Txt.Keys.Env = "ENV"
+	cfg.Txt.Keys.Role = "ROLE"
+	cfg.Txt.Keys.Srv = "SRV"
+	cfg.Txt.Keys.Vars = "VARS"
+	cfg.Normalize.Values = map[string]map[string]string{
+		"os":   {"rhel9": "linux", "ubuntu22": "linux"},
+		"ENV":  {"Production": "prod"},
+		"role": {"www": "web"},
+	}
+
+	tests := []struct {
+		name      string
+		lowercase bool
+		attrs     *HostAttributes
+		want      *HostAttributes
+	}{
+		{
+			name:  "values",
+			attrs: &HostAttributes{OS: "rhel9", Env: "production", Role: "app,www", Srv: "nginx", Vars: "a=1"},
+			want:  &HostAttributes{OS: "linux", Env: "prod", Role: "app,web", Srv: "nginx", Vars: "a=1"},
+		},
+		{
+			name:  "unmapped",
+			attrs: &HostAttributes{OS: "windows", Env: "Dev", Role: "db", Srv: ""},
+			want:  &HostAttributes{OS: "windows", Env: "Dev", Role: "db", Srv: ""},
+		},
+		{
+			name:      "lowercase",
+			lowercase: true,
+			attrs:     &HostAttributes{OS: "Ubuntu22", Env: "Dev", Role: "DB", Srv: "Postgres"},
+			want:      &HostAttributes{OS: "linux", Env: "dev", Role: "db", Srv: "postgres"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testCfg := *cfg
+			testCfg.Normalize.Lowercase = tt.lowercase
+			i := &Inventory{Config: &testCfg}
+
+			i.attributeNormalizer()(tt.attrs)
+			if !reflect.DeepEqual(tt.attrs, tt.want) {
+				t.Errorf("Inventory.attributeNormalizer() = %v, want %v", tt.attrs, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Notification event sent after host records have been published (or publishing has failed).
+	PublishNotifyEvent string = "publish"
+	// Notification event sent when hosts appear or disappear between inventory refreshes.
+	ChangeNotifyEvent string = "change"
+
+	// Slack incoming webhook notification sink type.
+	SlackNotifySinkType string = "slack"
+	// PagerDuty Events API v2 notification sink type.
+	PagerDutyNotifySinkType string = "pagerduty"
+	// Email notification sink type.
+	EmailNotifySinkType string = "email"
+	// Generic webhook notification sink type.
+	WebhookNotifySinkType string = "webhook"
+
+	// Default PagerDuty Events API v2 endpoint.
+	pagerDutyDefaultURL string = "https://events.pagerduty.com/v2/enqueue"
+	// Default notification sink request timeout.
+	notifyDefaultTimeout time.Duration = 10 * time.Second
+)
+
+// notifyDefaultTemplates maps notification events to their default message templates.
+var notifyDefaultTemplates = map[string]string{
+	PublishNotifyEvent: `{{ if .Error }}{{ .Operation }} failed{{ else }}{{ .Operation }} completed{{ end }}: {{ .Records }} host records{{ if .Error }}: {{ .Error }}{{ end }}`,
+	ChangeNotifyEvent:  `{{ if .Removed }}{{ len .Removed }} hosts disappeared from {{ join .Envs ", " }}{{ if .Added }}, {{ end }}{{ end }}{{ if .Added }}{{ len .Added }} hosts appeared{{ end }}`,
+}
+
+// notifyTemplateFuncs lists the functions available in message templates.
+var notifyTemplateFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// Notifier sends notifications to a single sink.
+type Notifier struct {
+	// Notification sink specification.
+	Spec NotifySpec
+	// Message templates, keyed by event.
+	Templates map[string]*template.Template
+	// HTTP client.
+	Client *http.Client
+}
+
+// wants checks whether the notifier is subscribed to an event.
+func (n *Notifier) wants(event string) bool {
+	return len(n.Spec.Events) == 0 || slices.Contains(n.Spec.Events, event)
+}
+
+// render produces the message text of an event.
+func (n *Notifier) render(event *NotifyEvent) (string, error) {
+	var buf bytes.Buffer
+
+	if err := n.Templates[event.Event].Execute(&buf, event); err != nil {
+		return "", errors.Wrap(err, "message template failure")
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// post sends a JSON payload with a POST request.
+func (n *Notifier) post(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "payload marshalling failure")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), n.Spec.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Send delivers an event to the sink.
+func (n *Notifier) Send(event *NotifyEvent) error {
+	spec := n.Spec
+
+	message, err := n.render(event)
+	if err != nil {
+		return err
+	}
+
+	switch spec.Type {
+	case SlackNotifySinkType:
+		return n.post(spec.URL, map[string]string{"text": message})
+	case PagerDutyNotifySinkType:
+		url := spec.URL
+		if len(url) == 0 {
+			url = pagerDutyDefaultURL
+		}
+
+		severity := spec.Severity
+		if len(severity) == 0 {
+			severity = "warning"
+		}
+
+		return n.post(url, map[string]interface{}{
+			"routing_key":  spec.Key,
+			"event_action": "trigger",
+			"payload": map[string]interface{}{
+				"summary":        message,
+				"source":         "ansible-dns-inventory",
+				"severity":       severity,
+				"custom_details": event,
+			},
+		})
+	case EmailNotifySinkType:
+		var auth smtp.Auth
+		if len(spec.Username) > 0 {
+			host, _, _ := strings.Cut(spec.SMTP, ":")
+			auth = smtp.PlainAuth("", spec.Username, spec.Password, host)
+		}
+
+		msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: [ansible-dns-inventory] %s\r\n\r\n%s\r\n", spec.From, strings.Join(spec.To, ", "), event.Event, message)
+
+		return smtp.SendMail(spec.SMTP, auth, spec.From, spec.To, []byte(msg))
+	default:
+		payload := *event
+		payload.Message = message
+
+		return n.post(spec.URL, &payload)
+	}
+}
+
+// NewNotifier creates a notifier based on its specification.
+func NewNotifier(spec NotifySpec) (*Notifier, error) {
+	if spec.Timeout == 0 {
+		spec.Timeout = notifyDefaultTimeout
+	}
+
+	spec.Type = strings.ToLower(spec.Type)
+
+	switch spec.Type {
+	case SlackNotifySinkType, WebhookNotifySinkType:
+		if len(spec.URL) == 0 {
+			return nil, errors.Errorf("%s notification sink requires a URL", spec.Type)
+		}
+	case PagerDutyNotifySinkType:
+		if len(spec.Key) == 0 {
+			return nil, errors.New("pagerduty notification sink requires a routing key")
+		}
+	case EmailNotifySinkType:
+		if len(spec.SMTP) == 0 || len(spec.From) == 0 || len(spec.To) == 0 {
+			return nil, errors.New("email notification sink requires an SMTP server, a sender and recipients")
+		}
+	default:
+		return nil, errors.Errorf("unknown notification sink type: %s", spec.Type)
+	}
+
+	for _, event := range spec.Events {
+		if _, ok := notifyDefaultTemplates[event]; !ok {
+			return nil, errors.Errorf("unknown notification event: %s", event)
+		}
+	}
+
+	for event := range spec.Templates {
+		if _, ok := notifyDefaultTemplates[event]; !ok {
+			return nil, errors.Errorf("unknown notification event: %s", event)
+		}
+	}
+
+	n := &Notifier{
+		Spec:      spec,
+		Templates: make(map[string]*template.Template),
+		Client:    &http.Client{Timeout: spec.Timeout},
+	}
+
+	for event, text := range notifyDefaultTemplates {
+		if t, ok := spec.Templates[event]; ok {
+			text = t
+		}
+
+		tmpl, err := template.New(event).Funcs(notifyTemplateFuncs).Parse(text)
+		if err != nil {
+			return nil, errors.Wrap(err, "message template parsing failure")
+		}
+
+		n.Templates[event] = tmpl
+	}
+
+	return n, nil
+}
+
+// NewNotifiers creates notifiers for all configured notification sinks.
+func NewNotifiers(cfg *Config) ([]*Notifier, error) {
+	notifiers := make([]*Notifier, 0)
+
+	if !cfg.Notify.Enabled {
+		return notifiers, nil
+	}
+
+	for _, spec := range cfg.Notify.Sinks {
+		n, err := NewNotifier(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		notifiers = append(notifiers, n)
+	}
+
+	return notifiers, nil
+}
+
+// notify sends an event to all subscribed notification sinks. Failures are logged.
+func (i *Inventory) notify(event *NotifyEvent) {
+	log := i.Logger
+
+	event.Datasource = i.Config.Datasource
+	event.Timestamp = time.Now().UTC()
+
+	for _, n := range i.Notifiers {
+		if !n.wants(event.Event) {
+			continue
+		}
+
+		if err := n.Send(event); err != nil {
+			log.Warnf("[%s] notification failure: %v", n.Spec.Type, err)
+		}
+	}
+}
+
+// TrackChanges compares hosts with the hosts seen by the previous call and sends a change notification if any hosts have appeared or disappeared.
+// The first call only records the current hosts.
+func (i *Inventory) TrackChanges(hosts map[string][]*HostAttributes) {
+	current := make(map[string]string, len(hosts))
+	for host, attrs := range hosts {
+		if len(attrs) > 0 {
+			current[host] = attrs[0].Env
+		} else {
+			current[host] = ""
+		}
+	}
+
+	i.knownLock.Lock()
+	previous := i.known
+	i.known = current
+	i.knownLock.Unlock()
+
+	if previous == nil {
+		return
+	}
+
+	event := &NotifyEvent{
+		Event:   ChangeNotifyEvent,
+		Hosts:   len(current),
+		Added:   make([]string, 0),
+		Removed: make([]string, 0),
+		Envs:    make([]string, 0),
+	}
+
+	for host, env := range previous {
+		if _, ok := current[host]; !ok {
+			event.Removed = append(event.Removed, host)
+
+			if !slices.Contains(event.Envs, env) {
+				event.Envs = append(event.Envs, env)
+			}
+		}
+	}
+
+	for host := range current {
+		if _, ok := previous[host]; !ok {
+			event.Added = append(event.Added, host)
+		}
+	}
+
+	if len(event.Added) == 0 && len(event.Removed) == 0 {
+		return
+	}
+
+	sort.Strings(event.Added)
+	sort.Strings(event.Removed)
+	sort.Strings(event.Envs)
+
+	i.notify(event)
+}
+
+// ResetChanges forgets the hosts seen by TrackChanges, so that the next call only records the current hosts.
+// Instances that stop sending notifications (e.g. after losing the leadership) reset their changes so that they don't report
+// the changes already reported by another instance once they resume.
+func (i *Inventory) ResetChanges() {
+	i.knownLock.Lock()
+	defer i.knownLock.Unlock()
+
+	i.known = nil
+}
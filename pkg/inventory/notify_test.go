@@ -0,0 +1,109 @@
+package inventory
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testLogger discards warnings and debug messages and panics on any other message.
+type testLogger struct {
+	Logger
+}
+
+func (l *testLogger) Warnf(template string, args ...interface{}) {}
+
+func (l *testLogger) Debugf(template string, args ...interface{}) {}
+
+func TestInventory_TrackChanges(t *testing.T) {
+	events := make(chan *NotifyEvent, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := &NotifyEvent{}
+		if err := json.NewDecoder(r.Body).Decode(event); err != nil {
+			t.Errorf("notification payload decoding failure: %v", err)
+		}
+		events <- event
+	}))
+	defer srv.Close()
+
+	n, err := NewNotifier(NotifySpec{Type: "webhook", URL: srv.URL, Events: []string{ChangeNotifyEvent}})
+	if err != nil {
+		t.Fatalf("NewNotifier() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		previous map[string]string
+		hosts    map[string][]*HostAttributes
+		want     string
+	}{
+		{
+			name:     "first-run",
+			previous: nil,
+			hosts:    map[string][]*HostAttributes{"app01.infra.local": {{Env: "prod"}}},
+			want:     "",
+		},
+		{
+			name:     "unchanged",
+			previous: map[string]string{"app01.infra.local": "prod"},
+			hosts:    map[string][]*HostAttributes{"app01.infra.local": {{Env: "prod"}}},
+			want:     "",
+		},
+		{
+			name:     "removed",
+			previous: map[string]string{"app01.infra.local": "prod", "app02.infra.local": "prod", "db01.infra.local": "dev"},
+			hosts:    map[string][]*HostAttributes{"app01.infra.local": {{Env: "prod"}}},
+			want:     "2 hosts disappeared from dev, prod",
+		},
+		{
+			name:     "added-removed",
+			previous: map[string]string{"app01.infra.local": "prod"},
+			hosts:    map[string][]*HostAttributes{"app02.infra.local": {{Env: "prod"}}},
+			want:     "1 hosts disappeared from prod, 1 hosts appeared",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := &Inventory{
+				Config:    &Config{},
+				Logger:    &testLogger{},
+				Notifiers: []*Notifier{n},
+				known:     tt.previous,
+			}
+
+			i.TrackChanges(tt.hosts)
+
+			var got string
+			select {
+			case event := <-events:
+				got = event.Message
+			default:
+			}
+
+			if got != tt.want {
+				t.Errorf("Inventory.TrackChanges() message = %q, want %q", got, tt.want)
+			}
+
+			if len(i.known) != len(tt.hosts) {
+				t.Errorf("Inventory.TrackChanges() known hosts = %v, want %d hosts", i.known, len(tt.hosts))
+			}
+		})
+	}
+}
+
+func TestInventory_ResetChanges(t *testing.T) {
+	i := &Inventory{Config: &Config{}, Logger: &testLogger{}, known: map[string]string{"app01.infra.local": "prod"}}
+
+	i.ResetChanges()
+	if i.known != nil {
+		t.Errorf("Inventory.ResetChanges() known hosts = %v, want none", i.known)
+	}
+
+	// The first call after a reset records a new baseline without reporting the hosts as added.
+	i.TrackChanges(map[string][]*HostAttributes{"app02.infra.local": {{Env: "prod"}}})
+	if len(i.known) != 1 {
+		t.Errorf("Inventory.TrackChanges() known hosts = %v, want 1 host", i.known)
+	}
+}
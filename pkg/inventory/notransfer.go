@@ -0,0 +1,132 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// notransferCache holds the no-transfer records of a zone as of a specific SOA serial.
+type notransferCache struct {
+	// Zone name.
+	Zone string `json:"zone"`
+	// Zone SOA serial the records have been read at.
+	Serial uint32 `json:"serial"`
+	// Host records by hostname.
+	Records map[string][]*DatasourceRecord `json:"records"`
+}
+
+// notransferCachePath returns the path of the on-disk no-transfer record cache of a zone, or an empty string if there is no on-disk cache.
+func (d *DNSDatasource) notransferCachePath(zone string) string {
+	dir := d.Config.DNS.Notransfer.Cache.Dir
+	if len(dir) == 0 {
+		return ""
+	}
+
+	return filepath.Join(dir, "notransfer-"+strings.ToLower(strings.TrimSuffix(dns.Fqdn(zone), "."))+".json")
+}
+
+// readNotransferCache reads the on-disk no-transfer record cache of a zone. It returns nil if the cache is unavailable.
+func (d *DNSDatasource) readNotransferCache(zone string) *notransferCache {
+	log := d.Logger
+
+	path := d.notransferCachePath(zone)
+	if len(path) == 0 {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Debugf("[%s] no-transfer record cache is unavailable: %v", zone, err)
+		return nil
+	}
+
+	cache := &notransferCache{}
+	if err := json.Unmarshal(data, cache); err != nil {
+		log.Debugf("[%s] no-transfer record cache is invalid: %v", zone, err)
+		return nil
+	}
+
+	return cache
+}
+
+// writeNotransferCache replaces the on-disk no-transfer record cache of a zone, if configured.
+func (d *DNSDatasource) writeNotransferCache(cache *notransferCache) {
+	log := d.Logger
+
+	path := d.notransferCachePath(cache.Zone)
+	if len(path) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(cache)
+	if err == nil {
+		err = writeFileAtomic(path, data)
+	}
+
+	if err != nil {
+		log.Warnf("[%s] no-transfer record cache writing failure: %v", cache.Zone, err)
+	}
+}
+
+// cachedNotransferRecords returns the no-transfer records of a zone by hostname, reading them only if the zone SOA serial has changed
+// since they have been cached in the process or on disk. The records are read without caching if the serial cannot be acquired.
+func (d *DNSDatasource) cachedNotransferRecords(ctx context.Context, zone string) (map[string][]*DatasourceRecord, error) {
+	cfg := d.Config
+	log := d.Logger
+
+	serial, serialErr := d.getSerial(ctx, zone)
+	if serialErr != nil {
+		log.Debugf("[%s] skipping no-transfer record cache: %v", zone, serialErr)
+	}
+
+	// Lookups of the same zone wait for each other, so that the records are read only once.
+	d.cacheMu.Lock()
+	defer d.cacheMu.Unlock()
+
+	if serialErr == nil {
+		if cache, ok := d.cache[zone]; ok && cache.Serial == serial {
+			return cache.Records, nil
+		}
+
+		if cache := d.readNotransferCache(zone); cache != nil && cache.Serial == serial {
+			d.storeNotransferCache(cache)
+			return cache.Records, nil
+		}
+	}
+
+	rrs, err := d.getNotransferHosts(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := &notransferCache{Zone: zone, Serial: serial, Records: make(map[string][]*DatasourceRecord)}
+	for _, rr := range rrs {
+		if !strings.Contains(dns.Field(rr, dnsRrTxtField), cfg.DNS.Notransfer.Separator) {
+			continue
+		}
+
+		r := d.processRecord(rr)
+		cache.Records[r.Hostname] = append(cache.Records[r.Hostname], r)
+	}
+
+	if serialErr == nil {
+		d.storeNotransferCache(cache)
+		d.writeNotransferCache(cache)
+	}
+
+	return cache.Records, nil
+}
+
+// storeNotransferCache keeps the no-transfer records of a zone in the process. The caller must hold the cache lock.
+func (d *DNSDatasource) storeNotransferCache(cache *notransferCache) {
+	if d.cache == nil {
+		d.cache = make(map[string]*notransferCache)
+	}
+
+	d.cache[cache.Zone] = cache
+}
@@ -0,0 +1,108 @@
+package inventory
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// startTestNotransferServer starts a local DNS server answering SOA queries for the 'infra.local.' zone with the current serial,
+// and TXT queries with no-transfer records. The number of TXT queries is counted.
+func startTestNotransferServer(t *testing.T, serial *uint32, queries *int32) string {
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+
+		switch r.Question[0].Qtype {
+		case dns.TypeSOA:
+			m.Answer = append(m.Answer, &dns.SOA{
+				Hdr:    dns.RR_Header{Name: "infra.local.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 60},
+				Ns:     "ns1.infra.local.",
+				Mbox:   "admin.infra.local.",
+				Serial: atomic.LoadUint32(serial),
+			})
+		case dns.TypeTXT:
+			atomic.AddInt32(queries, 1)
+			for _, txt := range []string{
+				"app01.infra.local:OS=linux;ENV=dev;ROLE=app;SRV=tomcat",
+				"app01.infra.local:OS=linux;ENV=dev;ROLE=web;SRV=nginx",
+				"db01.infra.local:OS=linux;ENV=dev;ROLE=db;SRV=postgres",
+				"v=spf1 -all",
+			} {
+				m.Answer = append(m.Answer, &dns.TXT{
+					Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 60},
+					Txt: []string{txt},
+				})
+			}
+		}
+
+		w.WriteMsg(m)
+	})
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &dns.Server{PacketConn: pc, Handler: handler}
+	go server.ActivateAndServe()
+	t.Cleanup(func() { server.Shutdown() })
+
+	return pc.LocalAddr().String()
+}
+
+func TestDNSDatasource_GetHostRecords_cache(t *testing.T) {
+	serial := uint32(1)
+	queries := int32(0)
+
+	cfg := &Config{}
+	cfg.DNS.Server = startTestNotransferServer(t, &serial, &queries)
+	cfg.DNS.Timeout = 5 * time.Second
+	cfg.DNS.Zones = []string{"infra.local."}
+	cfg.DNS.Notransfer.Enabled = true
+	cfg.DNS.Notransfer.Host = "ansible-dns-inventory"
+	cfg.DNS.Notransfer.Separator = ":"
+	cfg.DNS.Notransfer.Cache.Enabled = true
+	cfg.DNS.Notransfer.Cache.Dir = t.TempDir()
+
+	lookup := func(d *DNSDatasource, host string, want int, wantQueries int32) {
+		t.Helper()
+
+		records, err := d.GetHostRecords(host)
+		if err != nil {
+			t.Fatalf("DNSDatasource.GetHostRecords() error = %v", err)
+		}
+		if len(records) != want {
+			t.Errorf("DNSDatasource.GetHostRecords(%s) returned %d records, want %d", host, len(records), want)
+		}
+		if got := atomic.LoadInt32(&queries); got != wantQueries {
+			t.Errorf("DNSDatasource.GetHostRecords(%s) made %d TXT queries, want %d", host, got, wantQueries)
+		}
+	}
+
+	d, err := NewDNSDatasource(cfg, &testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// The records of the zone are read once per serial.
+	lookup(d, "app01.infra.local", 2, 1)
+	lookup(d, "db01.infra.local", 1, 1)
+	lookup(d, "web01.infra.local", 0, 1)
+
+	atomic.StoreUint32(&serial, 2)
+	lookup(d, "app01.infra.local", 2, 2)
+
+	// Another process uses the on-disk cache.
+	other, err := NewDNSDatasource(cfg, &testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	lookup(other, "db01.infra.local", 1, 2)
+}
@@ -0,0 +1,368 @@
+package inventory
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// phpIPAM datasource type.
+	PhpIPAMDatasourceType string = "phpipam"
+
+	// Prefix of the address fields referring to the fields of the address subnet.
+	phpIPAMSubnetPrefix string = "subnet."
+)
+
+type (
+	// PhpIPAMDatasource implements a read-only datasource building host records from the addresses kept in phpIPAM,
+	// mapping address and subnet fields (including custom fields) to host attributes.
+	PhpIPAMDatasource struct {
+		// Inventory configuration.
+		Config *Config
+		// Inventory logger.
+		Logger Logger
+		// HTTP client.
+		Client *http.Client
+
+		// API URL, including the application ID.
+		api string
+	}
+
+	// phpIPAMResponse represents the envelope of a phpIPAM API response.
+	phpIPAMResponse struct {
+		Code    int             `json:"code"`
+		Success bool            `json:"success"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+
+	// phpIPAMObject represents a subnet or an address. Custom fields are returned as 'custom_<name>' fields.
+	phpIPAMObject map[string]interface{}
+)
+
+// get performs an API request and decodes the data of the response into v.
+// It returns false if the requested objects do not exist, which phpIPAM reports for empty subnets and searches without results.
+func (p *PhpIPAMDatasource) get(path string, v interface{}) (bool, error) {
+	cfg := p.Config
+
+	req, err := http.NewRequest(http.MethodGet, p.api+path, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "phpipam request failure")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("token", cfg.PhpIPAM.Token)
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return false, errors.Wrap(err, "phpipam request failure")
+	}
+	defer resp.Body.Close()
+
+	envelope := &phpIPAMResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(envelope); err != nil {
+		return false, errors.Wrapf(err, "phpipam response parsing failure: %s: %s", path, resp.Status)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	if resp.StatusCode != http.StatusOK || !envelope.Success {
+		return false, errors.Errorf("phpipam request failure: %s: %s: %s", path, resp.Status, envelope.Message)
+	}
+
+	if err := json.Unmarshal(envelope.Data, v); err != nil {
+		return false, errors.Wrapf(err, "phpipam response parsing failure: %s", path)
+	}
+
+	return true, nil
+}
+
+// field returns the value of a field as a string. An empty string is returned if the field is missing or is not a scalar.
+func (o phpIPAMObject) field(name string) string {
+	switch v := o[name].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return ""
+	}
+}
+
+// cidr returns the subnet in CIDR notation.
+func (o phpIPAMObject) cidr() string {
+	return o.field("subnet") + "/" + o.field("mask")
+}
+
+// subnets lists the configured subnets, or all subnets of all sections.
+func (p *PhpIPAMDatasource) subnets() ([]phpIPAMObject, error) {
+	cfg := p.Config
+	subnets := make([]phpIPAMObject, 0)
+
+	if len(cfg.PhpIPAM.Subnets) > 0 {
+		for _, cidr := range cfg.PhpIPAM.Subnets {
+			found := make([]phpIPAMObject, 0)
+			if ok, err := p.get("/subnets/cidr/"+cidr+"/", &found); err != nil {
+				return nil, err
+			} else if !ok || len(found) == 0 {
+				return nil, errors.Errorf("subnet not found: %s", cidr)
+			}
+
+			subnets = append(subnets, found...)
+		}
+
+		return subnets, nil
+	}
+
+	sections := make([]phpIPAMObject, 0)
+	if _, err := p.get("/sections/", &sections); err != nil {
+		return nil, err
+	}
+
+	for _, section := range sections {
+		found := make([]phpIPAMObject, 0)
+		if _, err := p.get("/sections/"+url.PathEscape(section.field("id"))+"/subnets/", &found); err != nil {
+			return nil, err
+		}
+
+		subnets = append(subnets, found...)
+	}
+
+	return subnets, nil
+}
+
+// selected reports whether a subnet is one of the configured subnets. All subnets are selected if none are configured.
+func (p *PhpIPAMDatasource) selected(subnet phpIPAMObject) bool {
+	cfg := p.Config
+
+	if len(cfg.PhpIPAM.Subnets) == 0 {
+		return true
+	}
+
+	_, network, err := net.ParseCIDR(subnet.cidr())
+	if err != nil {
+		return false
+	}
+
+	for _, cidr := range cfg.PhpIPAM.Subnets {
+		if _, n, err := net.ParseCIDR(cidr); err == nil && n.String() == network.String() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hostname converts the hostname of an address into an inventory hostname, appending the configured domain to names that are not fully qualified.
+func (p *PhpIPAMDatasource) hostname(address phpIPAMObject) string {
+	cfg := p.Config
+
+	name := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(address.field("hostname")), "."))
+	if len(name) > 0 && len(cfg.PhpIPAM.Domain) > 0 && !strings.Contains(name, ".") {
+		name += "." + strings.Trim(strings.ToLower(cfg.PhpIPAM.Domain), ".")
+	}
+
+	return name
+}
+
+// records converts addresses into host records, one per address. Addresses without a hostname or unknown subnets are skipped.
+// Addresses of the same host producing the same host record (e.g. several interfaces in a subnet) produce a single record.
+func (p *PhpIPAMDatasource) records(addresses []phpIPAMObject, subnets map[string]phpIPAMObject) []*DatasourceRecord {
+	cfg := p.Config
+	log := p.Logger
+
+	type entry struct {
+		host    string
+		address phpIPAMObject
+		subnet  phpIPAMObject
+		source  string
+	}
+
+	entries := make([]*entry, 0, len(addresses))
+	for _, address := range addresses {
+		subnet, ok := subnets[address.field("subnetId")]
+		if !ok {
+			continue
+		}
+
+		e := &entry{host: p.hostname(address), address: address, subnet: subnet, source: "phpipam/" + subnet.cidr() + "/" + address.field("ip")}
+		if len(e.host) == 0 {
+			log.Debugf("skipping phpipam address without a hostname: %s", e.source)
+			continue
+		}
+
+		entries = append(entries, e)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].host != entries[j].host {
+			return entries[i].host < entries[j].host
+		}
+
+		return entries[i].source < entries[j].source
+	})
+
+	seen := make(map[string]bool)
+	records := make([]*DatasourceRecord, 0, len(entries))
+	for _, e := range entries {
+		attrs, err := mappedAttributes(cfg, func(key string) string {
+			field := lookupFold(cfg.PhpIPAM.Attributes, key)
+			if name, ok := strings.CutPrefix(field, phpIPAMSubnetPrefix); ok {
+				return e.subnet.field(name)
+			}
+
+			return e.address.field(field)
+		}, cfg.PhpIPAM.Defaults)
+		if err != nil {
+			log.Warnf("skipping phpipam address: %s: %v", e.source, err)
+			continue
+		}
+
+		if key := e.host + "\x00" + attrs; !seen[key] {
+			seen[key] = true
+			records = append(records, &DatasourceRecord{Hostname: e.host, Attributes: attrs, Source: e.source})
+		}
+	}
+
+	return records
+}
+
+// GetAllRecords acquires all available host records.
+func (p *PhpIPAMDatasource) GetAllRecords() ([]*DatasourceRecord, error) {
+	subnets, err := p.subnets()
+	if err != nil {
+		return nil, errors.Wrap(err, "phpipam datasource failure")
+	}
+
+	index := make(map[string]phpIPAMObject)
+	addresses := make([]phpIPAMObject, 0)
+	for _, subnet := range subnets {
+		id := subnet.field("id")
+		if _, ok := index[id]; ok {
+			continue
+		}
+		index[id] = subnet
+
+		found := make([]phpIPAMObject, 0)
+		if _, err := p.get("/subnets/"+url.PathEscape(id)+"/addresses/", &found); err != nil {
+			return nil, errors.Wrap(err, "phpipam datasource failure")
+		}
+
+		addresses = append(addresses, found...)
+	}
+
+	return p.records(addresses, index), nil
+}
+
+// GetHostRecords returns the records of the addresses of a specific host in the configured subnets, reading the subnet of every address found.
+// Addresses are searched by hostname, and by the short hostname as well if it carries the configured domain.
+func (p *PhpIPAMDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error) {
+	cfg := p.Config
+
+	names := []string{host}
+	if domain := strings.Trim(cfg.PhpIPAM.Domain, "."); len(domain) > 0 {
+		if short, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(domain)); ok && !strings.Contains(short, ".") {
+			names = append(names, short)
+		}
+	}
+
+	subnets := make(map[string]phpIPAMObject)
+	addresses := make([]phpIPAMObject, 0)
+	for _, name := range names {
+		found := make([]phpIPAMObject, 0)
+		if _, err := p.get("/addresses/search_hostname/"+url.PathEscape(name)+"/", &found); err != nil {
+			return nil, errors.Wrap(err, "phpipam datasource failure")
+		}
+
+		for _, address := range found {
+			id := address.field("subnetId")
+			if _, ok := subnets[id]; !ok {
+				subnet := phpIPAMObject{}
+				if _, err := p.get("/subnets/"+url.PathEscape(id)+"/", &subnet); err != nil {
+					return nil, errors.Wrap(err, "phpipam datasource failure")
+				}
+				subnets[id] = subnet
+			}
+
+			addresses = append(addresses, address)
+		}
+	}
+
+	for id, subnet := range subnets {
+		if !p.selected(subnet) {
+			delete(subnets, id)
+		}
+	}
+
+	records := make([]*DatasourceRecord, 0)
+	for _, r := range p.records(addresses, subnets) {
+		if strings.EqualFold(r.Hostname, host) {
+			records = append(records, r)
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, errors.Wrap(ErrNoHostRecords, host)
+	}
+
+	return records, nil
+}
+
+// PublishRecords is not supported: addresses are managed in phpIPAM.
+func (p *PhpIPAMDatasource) PublishRecords(records []*DatasourceRecord) error {
+	return errors.New("publishing records is not supported by the phpipam datasource")
+}
+
+// Close closes idle connections to the phpIPAM API. The datasource remains usable.
+func (p *PhpIPAMDatasource) Close() {
+	p.Client.CloseIdleConnections()
+}
+
+// NewPhpIPAMDatasource creates a phpIPAM datasource.
+func NewPhpIPAMDatasource(cfg *Config, log Logger) (*PhpIPAMDatasource, error) {
+	u, err := url.Parse(cfg.PhpIPAM.URL)
+	if err != nil || len(u.Host) == 0 {
+		return nil, errors.Errorf("phpipam datasource initialization failure: invalid URL: %s", cfg.PhpIPAM.URL)
+	}
+
+	if len(cfg.PhpIPAM.Token) == 0 {
+		return nil, errors.New("phpipam datasource initialization failure: app code is not set")
+	}
+
+	for _, cidr := range cfg.PhpIPAM.Subnets {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, errors.Wrap(err, "phpipam datasource initialization failure: invalid subnet")
+		}
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.PhpIPAM.TLS.Insecure}
+	if len(cfg.PhpIPAM.TLS.CA) > 0 {
+		pool, err := tlsCAPoolFromFile(cfg.PhpIPAM.TLS.CA)
+		if err != nil {
+			return nil, errors.Wrap(err, "phpipam datasource initialization failure")
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &PhpIPAMDatasource{
+		Config: cfg,
+		Logger: log,
+		Client: &http.Client{Transport: transport, Timeout: cfg.PhpIPAM.Timeout},
+		api:    strings.TrimSuffix(u.String(), "/"),
+	}, nil
+}
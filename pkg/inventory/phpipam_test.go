@@ -0,0 +1,239 @@
+package inventory
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPhpIPAM emulates the sections, subnets and addresses controllers of the phpIPAM API for the 'inventory' application,
+// accepting the 'app-code' app code.
+func testPhpIPAM(t *testing.T) string {
+	type object = map[string]interface{}
+
+	subnets := []object{
+		{"id": "7", "sectionId": "1", "subnet": "10.1.0.0", "mask": "24", "description": "prod web", "custom_environment": "prod"},
+		{"id": "8", "sectionId": "1", "subnet": "10.2.0.0", "mask": "24", "description": "dev", "custom_environment": "dev"},
+		{"id": "9", "sectionId": "2", "subnet": "10.3.0.0", "mask": "24", "description": "empty"},
+	}
+
+	addresses := map[string][]object{
+		"7": {
+			{"id": "71", "subnetId": "7", "ip": "10.1.0.11", "hostname": "web01", "custom_role": "web", "custom_service": "nginx"},
+			// A second interface of the same host.
+			{"id": "72", "subnetId": "7", "ip": "10.1.0.12", "hostname": "web01", "custom_role": "web", "custom_service": "nginx"},
+			{"id": "73", "subnetId": "7", "ip": "10.1.0.13", "hostname": nil, "custom_role": "web"},
+		},
+		"8": {
+			{"id": "81", "subnetId": "8", "ip": "10.2.0.21", "hostname": "App01.infra.local", "custom_role": "app", "custom_service": nil},
+			{"id": "82", "subnetId": "8", "ip": "10.2.0.22", "hostname": "web01", "custom_role": "web", "custom_service": "nginx"},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond := func(code int, data interface{}) {
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(object{"code": code, "success": code == http.StatusOK, "data": data, "message": http.StatusText(code)})
+		}
+
+		if r.Header.Get("token") != "app-code" {
+			respond(http.StatusForbidden, nil)
+			return
+		}
+
+		path, ok := strings.CutPrefix(r.URL.Path, "/api/inventory/")
+		if !ok {
+			respond(http.StatusBadRequest, nil)
+			return
+		}
+		parts := strings.Split(strings.Trim(path, "/"), "/")
+
+		switch {
+		case path == "sections/":
+			respond(http.StatusOK, []object{{"id": "1", "name": "Servers"}, {"id": "2", "name": "Lab"}})
+		case len(parts) == 3 && parts[0] == "sections" && parts[2] == "subnets":
+			found := make([]object, 0)
+			for _, s := range subnets {
+				if s["sectionId"] == parts[1] {
+					found = append(found, s)
+				}
+			}
+			respond(http.StatusOK, found)
+		case len(parts) == 4 && parts[0] == "subnets" && parts[1] == "cidr":
+			for _, s := range subnets {
+				if s["subnet"] == parts[2] && s["mask"] == parts[3] {
+					respond(http.StatusOK, []object{s})
+					return
+				}
+			}
+			respond(http.StatusNotFound, nil)
+		case len(parts) == 2 && parts[0] == "subnets":
+			for _, s := range subnets {
+				if s["id"] == parts[1] {
+					respond(http.StatusOK, s)
+					return
+				}
+			}
+			respond(http.StatusNotFound, nil)
+		case len(parts) == 3 && parts[0] == "subnets" && parts[2] == "addresses":
+			// phpIPAM reports empty subnets as not found.
+			if found, ok := addresses[parts[1]]; ok {
+				respond(http.StatusOK, found)
+			} else {
+				respond(http.StatusNotFound, nil)
+			}
+		case len(parts) == 3 && parts[0] == "addresses" && parts[1] == "search_hostname":
+			found := make([]object, 0)
+			for _, id := range []string{"7", "8"} {
+				for _, a := range addresses[id] {
+					if a["hostname"] == parts[2] {
+						found = append(found, a)
+					}
+				}
+			}
+			if len(found) == 0 {
+				respond(http.StatusNotFound, nil)
+			} else {
+				respond(http.StatusOK, found)
+			}
+		default:
+			respond(http.StatusNotFound, nil)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL + "/api/inventory/"
+}
+
+// newTestPhpIPAMDatasource creates a phpIPAM datasource for the emulated phpIPAM server mapping ENV to a subnet custom field and ROLE and SRV to address custom fields.
+func newTestPhpIPAMDatasource(t *testing.T, token string, subnets []string) *PhpIPAMDatasource {
+	cfg := &Config{}
+	cfg.Txt.Keys.Os = "OS"
+	cfg.Txt.Keys.Env = "ENV"
+	cfg.Txt.Keys.Role = "ROLE"
+	cfg.Txt.Keys.Srv = "SRV"
+	cfg.Txt.Keys.Vars = "VARS"
+	cfg.Txt.Keys.ID = "ID"
+	cfg.Txt.Kv.Separator = ";"
+	cfg.Txt.Kv.Equalsign = "="
+	cfg.PhpIPAM.URL = testPhpIPAM(t)
+	cfg.PhpIPAM.Token = token
+	cfg.PhpIPAM.Subnets = subnets
+	cfg.PhpIPAM.Domain = "infra.local"
+	cfg.PhpIPAM.Attributes = map[string]string{"env": "subnet.custom_environment", "role": "custom_role", "srv": "custom_service"}
+	cfg.PhpIPAM.Defaults = map[string]string{"os": "linux"}
+	cfg.PhpIPAM.Timeout = 5 * time.Second
+
+	p, err := NewPhpIPAMDatasource(cfg, &testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+func TestPhpIPAMDatasource_GetAllRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		subnets []string
+		want    []*DatasourceRecord
+		wantErr bool
+	}{
+		{
+			// Addresses of a host producing the same record are merged, addresses without a hostname are skipped.
+			name:  "valid",
+			token: "app-code",
+			want: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=;VARS=", Source: "phpipam/10.2.0.0/24/10.2.0.21"},
+				{Hostname: "web01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=web;SRV=nginx;VARS=", Source: "phpipam/10.1.0.0/24/10.1.0.11"},
+				{Hostname: "web01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=web;SRV=nginx;VARS=", Source: "phpipam/10.2.0.0/24/10.2.0.22"},
+			},
+		},
+		{
+			name:    "valid-subnets",
+			token:   "app-code",
+			subnets: []string{"10.1.0.0/24"},
+			want: []*DatasourceRecord{
+				{Hostname: "web01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=web;SRV=nginx;VARS=", Source: "phpipam/10.1.0.0/24/10.1.0.11"},
+			},
+		},
+		{
+			name:    "invalid-subnet",
+			token:   "app-code",
+			subnets: []string{"10.9.0.0/24"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid-token",
+			token:   "wrong",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPhpIPAMDatasource(t, tt.token, tt.subnets)
+
+			got, err := p.GetAllRecords()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PhpIPAMDatasource.GetAllRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PhpIPAMDatasource.GetAllRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPhpIPAMDatasource_GetHostRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		subnets []string
+		want    []*DatasourceRecord
+		wantErr bool
+	}{
+		{
+			// Short hostnames are found by the qualified hostname.
+			name: "valid-short",
+			host: "web01.infra.local",
+			want: []*DatasourceRecord{
+				{Hostname: "web01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=web;SRV=nginx;VARS=", Source: "phpipam/10.1.0.0/24/10.1.0.11"},
+				{Hostname: "web01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=web;SRV=nginx;VARS=", Source: "phpipam/10.2.0.0/24/10.2.0.22"},
+			},
+		},
+		{
+			name:    "valid-subnets",
+			host:    "web01.infra.local",
+			subnets: []string{"10.2.0.0/24"},
+			want: []*DatasourceRecord{
+				{Hostname: "web01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=web;SRV=nginx;VARS=", Source: "phpipam/10.2.0.0/24/10.2.0.22"},
+			},
+		},
+		{
+			name:    "invalid-missing",
+			host:    "db01.infra.local",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPhpIPAMDatasource(t, "app-code", tt.subnets)
+
+			got, err := p.GetHostRecords(tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PhpIPAMDatasource.GetHostRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PhpIPAMDatasource.GetHostRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
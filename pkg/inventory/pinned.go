@@ -0,0 +1,259 @@
+package inventory
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Fail the run if pinned hosts are missing.
+	PinnedFailMode string = "fail"
+	// Use the snapshot records of missing pinned hosts.
+	PinnedInjectMode string = "inject"
+)
+
+type (
+	// pinnedSnapshot is the on-disk snapshot of the records of pinned hosts.
+	pinnedSnapshot struct {
+		// Records of pinned hosts by hostname.
+		Hosts map[string]*pinnedHost `json:"hosts"`
+	}
+
+	// pinnedHost represents the records of a pinned host in the snapshot.
+	pinnedHost struct {
+		// Time the records have last been acquired at.
+		Timestamp time.Time `json:"timestamp"`
+		// Host records.
+		Records []*DatasourceRecord `json:"records"`
+	}
+)
+
+// pinnedHosts reads the lowercased names of pinned hosts. It returns nil if host pinning is disabled.
+func (i *Inventory) pinnedHosts() ([]string, error) {
+	cfg := i.Config
+
+	if len(cfg.Pinned.Path) == 0 {
+		return nil, nil
+	}
+
+	switch cfg.Pinned.Mode {
+	case PinnedFailMode:
+	case PinnedInjectMode:
+		if len(cfg.Pinned.Snapshot) == 0 {
+			return nil, errors.New("pinned hosts snapshot file is not set")
+		}
+	default:
+		return nil, errors.Errorf("unknown pinned hosts mode: %s", cfg.Pinned.Mode)
+	}
+
+	file, err := os.Open(cfg.Pinned.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "pinned hosts file read failure")
+	}
+	defer file.Close()
+
+	hosts := make([]string, 0)
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		host := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(scanner.Text())), ".")
+		if len(host) > 0 && !strings.HasPrefix(host, "#") && !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "pinned hosts file read failure")
+	}
+
+	return hosts, nil
+}
+
+// readPinnedSnapshot reads the snapshot of pinned host records. A missing snapshot is empty.
+func readPinnedSnapshot(path string) (*pinnedSnapshot, error) {
+	snapshot := &pinnedSnapshot{Hosts: make(map[string]*pinnedHost)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return snapshot, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "pinned hosts snapshot read failure")
+	}
+
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, errors.Wrap(err, "pinned hosts snapshot parsing failure")
+	}
+
+	if snapshot.Hosts == nil {
+		snapshot.Hosts = make(map[string]*pinnedHost)
+	}
+
+	return snapshot, nil
+}
+
+// updatePinnedSnapshot replaces the snapshot records of the pinned hosts that have been found and forgets hosts that are no longer pinned.
+func (i *Inventory) updatePinnedSnapshot(snapshot *pinnedSnapshot, found map[string][]*DatasourceRecord) {
+	log := i.Logger
+
+	now := time.Now().UTC()
+	for host, records := range found {
+		if len(records) > 0 {
+			snapshot.Hosts[host] = &pinnedHost{Timestamp: now, Records: records}
+		}
+	}
+
+	for host := range snapshot.Hosts {
+		if _, ok := found[host]; !ok {
+			delete(snapshot.Hosts, host)
+		}
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err == nil {
+		err = writeFileAtomic(i.Config.Pinned.Snapshot, data)
+	}
+
+	if err != nil {
+		log.Warnf("pinned hosts snapshot writing failure: %v", err)
+	}
+}
+
+// injectPinnedHosts returns the snapshot records of missing pinned hosts in the 'inject' mode, or fails if pinned hosts are missing.
+// Pinned hosts without snapshot records make the 'inject' mode fail as well.
+func (i *Inventory) injectPinnedHosts(missing []string, snapshot *pinnedSnapshot) ([]*DatasourceRecord, error) {
+	log := i.Logger
+
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	records := make([]*DatasourceRecord, 0)
+	unknown := make([]string, 0)
+
+	for _, host := range missing {
+		if snapshot == nil || snapshot.Hosts[host] == nil || len(snapshot.Hosts[host].Records) == 0 {
+			unknown = append(unknown, host)
+			continue
+		}
+
+		pinned := snapshot.Hosts[host]
+		log.Warnf("[%s] pinned host is missing, using its records acquired at %s", host, pinned.Timestamp.Format(time.RFC3339))
+		records = append(records, pinned.Records...)
+	}
+
+	if len(unknown) > 0 {
+		return nil, errors.Errorf("%d pinned host(s) missing: %s", len(unknown), strings.Join(unknown, ", "))
+	}
+
+	return records, nil
+}
+
+// pinRecords checks that every pinned host has at least one valid host record among the records acquired from the datasource.
+// In the 'inject' mode, the snapshot is updated with the records of the pinned hosts that have been found,
+// and the snapshot records of the missing ones are added to the records. Otherwise, missing pinned hosts fail the run.
+func (i *Inventory) pinRecords(records []*DatasourceRecord) ([]*DatasourceRecord, error) {
+	cfg := i.Config
+
+	pinned, err := i.pinnedHosts()
+	if err != nil || len(pinned) == 0 {
+		return records, err
+	}
+
+	found := make(map[string][]*DatasourceRecord)
+	for _, host := range pinned {
+		found[host] = nil
+	}
+
+	for _, r := range records {
+		host := strings.ToLower(strings.TrimSuffix(r.Hostname, "."))
+		if _, ok := found[host]; !ok {
+			continue
+		}
+
+		if _, err := i.ParseAttributes(r.Attributes); err == nil {
+			found[host] = append(found[host], r)
+		}
+	}
+
+	missing := make([]string, 0)
+	for _, host := range pinned {
+		if len(found[host]) == 0 {
+			missing = append(missing, host)
+		}
+	}
+
+	var snapshot *pinnedSnapshot
+	if cfg.Pinned.Mode == PinnedInjectMode {
+		if snapshot, err = readPinnedSnapshot(cfg.Pinned.Snapshot); err != nil {
+			return nil, err
+		}
+
+		i.updatePinnedSnapshot(snapshot, found)
+	}
+
+	injected, err := i.injectPinnedHosts(missing, snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(records, injected...), nil
+}
+
+// pinMergedHosts checks that every pinned host is present in at least one of the partial exports merged into a set of hosts.
+// In the 'inject' mode, the snapshot records of missing pinned hosts are added to the hosts.
+// The snapshot is updated with records rendered from the merged hosts, unless runtime filters may have removed some of them.
+func (i *Inventory) pinMergedHosts(seen map[string]int, hosts map[string][]*HostAttributes) error {
+	cfg := i.Config
+
+	pinned, err := i.pinnedHosts()
+	if err != nil || len(pinned) == 0 {
+		return err
+	}
+
+	present := make(map[string]string)
+	for host := range seen {
+		present[strings.ToLower(strings.TrimSuffix(host, "."))] = host
+	}
+
+	missing := make([]string, 0)
+	for _, host := range pinned {
+		if _, ok := present[host]; !ok {
+			missing = append(missing, host)
+		}
+	}
+
+	var snapshot *pinnedSnapshot
+	if cfg.Pinned.Mode == PinnedInjectMode {
+		if snapshot, err = readPinnedSnapshot(cfg.Pinned.Snapshot); err != nil {
+			return err
+		}
+
+		if len(i.Filters) == 0 {
+			found := make(map[string][]*DatasourceRecord)
+			for _, host := range pinned {
+				found[host] = nil
+
+				for _, attrs := range hosts[present[host]] {
+					if attrString, err := i.RenderAttributes(attrs); err == nil {
+						found[host] = append(found[host], &DatasourceRecord{Hostname: present[host], Attributes: attrString})
+					}
+				}
+			}
+
+			i.updatePinnedSnapshot(snapshot, found)
+		}
+	}
+
+	injected, err := i.injectPinnedHosts(missing, snapshot)
+	if err != nil {
+		return err
+	}
+
+	return i.collectHosts(injected, hosts)
+}
@@ -0,0 +1,132 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestInventory_pinRecords(t *testing.T) {
+	app01 := &DatasourceRecord{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=app;SRV=tomcat;VARS="}
+	db01 := &DatasourceRecord{Hostname: "db01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=db;SRV=postgres;VARS="}
+	invalid := &DatasourceRecord{Hostname: "db01.infra.local", Attributes: "OS=linux;ENV=prod"}
+
+	// Every case runs twice: the first run acquires 'first', the second one 'second'.
+	tests := []struct {
+		name    string
+		mode    string
+		first   []*DatasourceRecord
+		second  []*DatasourceRecord
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "valid-fail",
+			mode:   PinnedFailMode,
+			first:  []*DatasourceRecord{app01, db01},
+			second: []*DatasourceRecord{app01, db01},
+			want:   []string{"app01.infra.local", "db01.infra.local"},
+		},
+		{
+			// A pinned host without valid records is missing.
+			name:    "invalid-fail",
+			mode:    PinnedFailMode,
+			first:   []*DatasourceRecord{app01, db01},
+			second:  []*DatasourceRecord{app01, invalid},
+			wantErr: true,
+		},
+		{
+			name:   "valid-inject",
+			mode:   PinnedInjectMode,
+			first:  []*DatasourceRecord{app01, db01},
+			second: []*DatasourceRecord{app01},
+			want:   []string{"app01.infra.local", "db01.infra.local"},
+		},
+		{
+			// Pinned hosts that have never been seen cannot be injected.
+			name:    "invalid-inject",
+			mode:    PinnedInjectMode,
+			first:   []*DatasourceRecord{app01},
+			second:  []*DatasourceRecord{app01},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "pinned")
+			if err := os.WriteFile(path, []byte("# critical hosts\nDB01.infra.local.\n\ndb01.infra.local\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			run := func(records []*DatasourceRecord) (map[string][]*HostAttributes, error) {
+				i := newTestInventory(t, false, records)
+				i.Config.Pinned.Path = path
+				i.Config.Pinned.Mode = tt.mode
+				i.Config.Pinned.Snapshot = filepath.Join(dir, "snapshot.json")
+
+				return i.GetHosts()
+			}
+
+			if _, err := run(tt.first); err != nil && tt.mode == PinnedFailMode {
+				t.Fatalf("Inventory.GetHosts() error = %v", err)
+			}
+
+			hosts, err := run(tt.second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Inventory.GetHosts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			got := make([]string, 0, len(hosts))
+			for host := range hosts {
+				got = append(got, host)
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inventory.GetHosts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInventory_pinMergedHosts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pinned")
+	if err := os.WriteFile(path, []byte("db01.infra.local\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	i := newTestInventory(t, false, nil)
+	i.Config.Pinned.Path = path
+	i.Config.Pinned.Mode = PinnedInjectMode
+	i.Config.Pinned.Snapshot = filepath.Join(dir, "snapshot.json")
+
+	app01 := map[string][]*HostAttributes{"app01.infra.local": {{OS: "linux", Env: "prod", Role: "app", Srv: "tomcat"}}}
+	db01 := map[string][]*HostAttributes{"db01.infra.local": {{OS: "linux", Env: "prod", Role: "db", Srv: "postgres"}}}
+
+	// The pinned host has never been seen.
+	if _, err := i.MergeHosts([]map[string][]*HostAttributes{app01}); err == nil {
+		t.Fatal("Inventory.MergeHosts() error = nil, want an error for a missing pinned host")
+	}
+
+	if _, err := i.MergeHosts([]map[string][]*HostAttributes{app01, db01}); err != nil {
+		t.Fatalf("Inventory.MergeHosts() error = %v", err)
+	}
+
+	// The pinned host is injected from the snapshot written by the previous merge.
+	got, err := i.MergeHosts([]map[string][]*HostAttributes{app01})
+	if err != nil {
+		t.Fatalf("Inventory.MergeHosts() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got["db01.infra.local"], db01["db01.infra.local"]) {
+		t.Errorf("Inventory.MergeHosts() = %v, want %v", got["db01.infra.local"], db01["db01.infra.local"])
+	}
+}
@@ -0,0 +1,123 @@
+package inventory
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	// Role catalog policy rule.
+	rolesPolicyRule string = "roles"
+	// Required host variables policy rule.
+	varsPolicyRule string = "vars"
+	// Mutually exclusive environments policy rule.
+	exclusivePolicyRule string = "exclusive"
+)
+
+// Error lists all policy violations.
+func (e *PolicyError) Error() string {
+	violations := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		violations = append(violations, fmt.Sprintf("%s: %s", v.Host, v.Message))
+	}
+
+	return fmt.Sprintf("publishing refused, %d policy violation(s): %s", len(e.Violations), strings.Join(violations, "; "))
+}
+
+// requiredVars returns the host variables required in a specific environment.
+func (i *Inventory) requiredVars(env string) []string {
+	// Viper folds map keys to lower case.
+	for name, vars := range i.Config.Policy.Vars {
+		if strings.EqualFold(name, env) {
+			return vars
+		}
+	}
+
+	return nil
+}
+
+// CheckPolicy checks host records against the publishing policy and returns the violations, ordered by hostname.
+// Records are checked as they are going to be written, role defaults are not applied.
+func (i *Inventory) CheckPolicy(records []*DatasourceRecord) []*PolicyViolation {
+	cfg := i.Config
+	policy := cfg.Policy
+	violations := make([]*PolicyViolation, 0)
+
+	if !policy.Enabled {
+		return violations
+	}
+
+	envs := make(map[string][]string)
+	for _, r := range records {
+		attrs := i.splitAttributeString(r.Attributes)
+		env := attrs[cfg.Txt.Keys.Env]
+
+		if len(policy.Roles) > 0 {
+			for _, role := range strings.Split(attrs[cfg.Txt.Keys.Role], ",") {
+				if !slices.ContainsFunc(policy.Roles, func(known string) bool { return strings.EqualFold(known, role) }) {
+					violations = append(violations, &PolicyViolation{Host: r.Hostname, Rule: rolesPolicyRule, Message: fmt.Sprintf("role is not in the catalog: %s", role)})
+				}
+			}
+		}
+
+		if required := i.requiredVars(env); len(required) > 0 {
+			vars := make(map[string]string)
+			parseVariables(attrs[cfg.Txt.Keys.Vars], cfg.Txt.Vars.Separator, cfg.Txt.Vars.Equalsign, vars)
+
+			for _, name := range required {
+				if len(vars[name]) == 0 {
+					violations = append(violations, &PolicyViolation{Host: r.Hostname, Rule: varsPolicyRule, Message: fmt.Sprintf("variable is required in environment %s: %s", env, name)})
+				}
+			}
+		}
+
+		if !slices.ContainsFunc(envs[r.Hostname], func(seen string) bool { return strings.EqualFold(seen, env) }) {
+			envs[r.Hostname] = append(envs[r.Hostname], env)
+		}
+	}
+
+	for host, hostEnvs := range envs {
+		for _, exclusive := range policy.Exclusive {
+			matched := make([]string, 0)
+			for _, env := range hostEnvs {
+				if slices.ContainsFunc(exclusive, func(e string) bool { return strings.EqualFold(e, env) }) {
+					matched = append(matched, env)
+				}
+			}
+
+			if len(matched) > 1 {
+				sort.Strings(matched)
+				violations = append(violations, &PolicyViolation{Host: host, Rule: exclusivePolicyRule, Message: fmt.Sprintf("host belongs to mutually exclusive environments: %s", strings.Join(matched, ", "))})
+			}
+		}
+	}
+
+	sort.SliceStable(violations, func(a, b int) bool { return i.CompareNames(violations[a].Host, violations[b].Host) < 0 })
+
+	return violations
+}
+
+// enforcePolicy checks host records against the publishing policy, logging the violations.
+// It returns a PolicyError if there are violations, unless the policy is in the dry-run mode.
+func (i *Inventory) enforcePolicy(records []*DatasourceRecord) error {
+	cfg := i.Config
+	log := i.Logger
+
+	violations := i.CheckPolicy(records)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	for _, v := range violations {
+		log.Warnf("[%s] policy violation (%s): %s", v.Host, v.Rule, v.Message)
+	}
+
+	if cfg.Policy.DryRun {
+		log.Warnf("policy dry run: publishing %d host records despite %d policy violation(s)", len(records), len(violations))
+		return nil
+	}
+
+	return &PolicyError{Violations: violations}
+}
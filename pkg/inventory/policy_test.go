@@ -0,0 +1,77 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInventory_enforcePolicy(t *testing.T) {
+	cfg := &Config{}
+	cfg.Txt.Kv.Separator = ";"
+	cfg.Txt.Kv.Equalsign = "="
+	cfg.Txt.Keys.Env = "ENV"
+	cfg.Txt.Keys.Role = "ROLE"
+	cfg.Txt.Keys.Vars = "VARS"
+	cfg.Txt.Vars.Separator = ","
+	cfg.Txt.Vars.Equalsign = "="
+	cfg.Policy.Enabled = true
+	cfg.Policy.Roles = []string{"app", "db"}
+	cfg.Policy.Vars = map[string][]string{"prod": {"owner"}}
+	cfg.Policy.Exclusive = [][]string{{"prod", "lab"}}
+
+	tests := []struct {
+		name    string
+		records []*DatasourceRecord
+		dryRun  bool
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			records: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=PROD;ROLE=app,DB;SRV=;VARS=owner=team1"},
+				{Hostname: "app02.infra.local", Attributes: "OS=linux;ENV=lab;ROLE=app;SRV=;VARS="},
+			},
+			want:    []string{},
+			wantErr: false,
+		},
+		{
+			name: "invalid",
+			records: []*DatasourceRecord{
+				{Hostname: "app02.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=web;SRV=;VARS=owner="},
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=app;SRV=;VARS=owner=team1"},
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=lab;ROLE=app;SRV=;VARS="},
+			},
+			want:    []string{"app01.infra.local exclusive", "app02.infra.local roles", "app02.infra.local vars"},
+			wantErr: true,
+		},
+		{
+			name: "invalid-dry-run",
+			records: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=web;SRV=;VARS="},
+			},
+			dryRun:  true,
+			want:    []string{"app01.infra.local roles"},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Policy.DryRun = tt.dryRun
+			i := &Inventory{Config: cfg, Logger: &testLogger{}}
+
+			got := make([]string, 0)
+			for _, v := range i.CheckPolicy(tt.records) {
+				got = append(got, v.Host+" "+v.Rule)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inventory.CheckPolicy() = %v, want %v", got, tt.want)
+			}
+
+			if err := i.enforcePolicy(tt.records); (err != nil) != tt.wantErr {
+				t.Errorf("Inventory.enforcePolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
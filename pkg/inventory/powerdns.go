@@ -0,0 +1,364 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+const (
+	// PowerDNS Authoritative HTTP API datasource type.
+	PowerDNSDatasourceType string = "powerdns"
+
+	// PowerDNS RRset change types.
+	powerdnsReplace string = "REPLACE"
+	powerdnsDelete  string = "DELETE"
+)
+
+type (
+	// PowerDNSDatasource implements a read-write DNS datasource backed by the PowerDNS Authoritative HTTP API.
+	// Zones are read through the API and handled like with the DNS datasource, host records are published by replacing TXT RRsets.
+	PowerDNSDatasource struct {
+		*DNSDatasource
+
+		// PowerDNS API client, also used as the zone reader of the DNS datasource.
+		api *powerdnsControl
+	}
+
+	// powerdnsControl reads and updates zones through the PowerDNS Authoritative HTTP API.
+	powerdnsControl struct {
+		// Inventory configuration.
+		config *Config
+		// HTTP client.
+		client *http.Client
+	}
+
+	// powerdnsZone represents a zone in a PowerDNS API response.
+	powerdnsZone struct {
+		RRsets []powerdnsRRset `json:"rrsets"`
+	}
+
+	// powerdnsRRset represents an RRset in PowerDNS API requests and responses.
+	powerdnsRRset struct {
+		Name       string           `json:"name"`
+		Type       string           `json:"type"`
+		TTL        uint32           `json:"ttl,omitempty"`
+		ChangeType string           `json:"changetype,omitempty"`
+		Records    []powerdnsRecord `json:"records"`
+	}
+
+	// powerdnsRecord represents a single record of an RRset.
+	powerdnsRecord struct {
+		Content  string `json:"content"`
+		Disabled bool   `json:"disabled"`
+	}
+)
+
+// request performs a PowerDNS API request for a zone. The response body is decoded into 'result' if it is not nil.
+func (p *powerdnsControl) request(ctx context.Context, method string, zone string, body interface{}, result interface{}) error {
+	cfg := p.config
+
+	ctx, cancel := context.WithTimeout(ctx, stageTimeout(cfg.PowerDNS.Timeout, cfg.DNS.Timeout))
+	defer cancel()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "powerdns request encoding failure")
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	endpoint := fmt.Sprintf("%s/api/v1/servers/%s/zones/%s", strings.TrimSuffix(cfg.PowerDNS.Address, "/"), url.PathEscape(cfg.PowerDNS.Server), url.PathEscape(dns.Fqdn(zone)))
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return errors.Wrap(err, "powerdns request failure")
+	}
+	req.Header.Set("X-API-Key", cfg.PowerDNS.Key)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "powerdns request failure")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+
+		return errors.Errorf("powerdns request failure: %s: %s", resp.Status, e.Error)
+	}
+
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return errors.Wrap(err, "powerdns response parsing failure")
+		}
+	}
+
+	return nil
+}
+
+// readZone reads the TXT and SOA records of a zone. Other and disabled records are skipped.
+func (p *powerdnsControl) readZone(ctx context.Context, zone string) ([]dns.RR, error) {
+	result := &powerdnsZone{}
+	if err := p.request(ctx, http.MethodGet, zone, nil, result); err != nil {
+		return nil, err
+	}
+
+	rrs := make([]dns.RR, 0)
+	for _, set := range result.RRsets {
+		if set.Type != "TXT" && set.Type != "SOA" {
+			continue
+		}
+
+		for _, record := range set.Records {
+			if record.Disabled {
+				continue
+			}
+
+			// Contents are in zone file presentation format, TXT contents are quoted.
+			rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", set.Name, set.TTL, set.Type, record.Content))
+			if err != nil {
+				return nil, errors.Wrap(err, "record parsing failure")
+			}
+			if rr != nil {
+				rrs = append(rrs, rr)
+			}
+		}
+	}
+
+	return rrs, nil
+}
+
+// patchZone applies RRset changes to a zone.
+func (p *powerdnsControl) patchZone(ctx context.Context, zone string, rrsets []powerdnsRRset) error {
+	if len(rrsets) == 0 {
+		return nil
+	}
+
+	return p.request(ctx, http.MethodPatch, zone, map[string][]powerdnsRRset{"rrsets": rrsets}, nil)
+}
+
+// powerdnsContent renders a TXT record content in presentation format, splitting it into 255-byte strings.
+func powerdnsContent(txt string) string {
+	rr := &dns.TXT{Hdr: dns.RR_Header{Rrtype: dns.TypeTXT, Class: dns.ClassINET}}
+	for len(txt) > 255 {
+		rr.Txt = append(rr.Txt, txt[:255])
+		txt = txt[255:]
+	}
+	rr.Txt = append(rr.Txt, txt)
+
+	return strings.TrimPrefix(rr.String(), rr.Hdr.String())
+}
+
+// txtRRset creates a TXT RRset replacing all TXT records of a name.
+func (p *PowerDNSDatasource) txtRRset(name string, txts []string) powerdnsRRset {
+	cfg := p.Config
+
+	set := powerdnsRRset{Name: dns.Fqdn(name), Type: "TXT", TTL: cfg.PowerDNS.TTL, ChangeType: powerdnsReplace, Records: make([]powerdnsRecord, 0, len(txts))}
+	for _, txt := range txts {
+		set.Records = append(set.Records, powerdnsRecord{Content: powerdnsContent(txt)})
+	}
+
+	return set
+}
+
+// ClearRecords removes all existing host records if the datasource is configured to do so before publishing.
+// Every TXT RRset read as host records is deleted, but only in the zones listed in the configuration.
+func (p *PowerDNSDatasource) ClearRecords() error {
+	cfg := p.Config
+
+	if !cfg.PowerDNS.Import.Clear {
+		return nil
+	}
+
+	ctx := context.Background()
+	for _, zone := range p.zones() {
+		rrs, err := p.api.readZone(ctx, zone)
+		if err != nil {
+			return errors.Wrapf(err, "%s: zone reading failure", zone)
+		}
+
+		records, _ := p.filterZone(zone, rrs)
+
+		rrsets := make([]powerdnsRRset, 0)
+		seen := make(map[string]bool)
+		for _, rr := range records {
+			if name := strings.ToLower(rr.Header().Name); !seen[name] {
+				seen[name] = true
+				rrsets = append(rrsets, powerdnsRRset{Name: rr.Header().Name, Type: "TXT", ChangeType: powerdnsDelete, Records: []powerdnsRecord{}})
+			}
+		}
+
+		if err := p.api.patchZone(ctx, zone, rrsets); err != nil {
+			return errors.Wrapf(err, "%s: host record removal failure", zone)
+		}
+	}
+
+	return nil
+}
+
+// notransferRecords reads the records kept by a no-transfer host of a zone, leaving out the records of hosts matching 'skip'.
+func (p *PowerDNSDatasource) notransferRecords(ctx context.Context, zone string, owner string, skip func(host string) bool) ([]string, error) {
+	cfg := p.Config
+
+	rrs, err := p.api.readZone(ctx, zone)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: zone reading failure", zone)
+	}
+
+	txts := make([]string, 0)
+	for _, rr := range rrs {
+		if txt, ok := rr.(*dns.TXT); ok && strings.EqualFold(txt.Hdr.Name, owner) {
+			content := strings.Join(txt.Txt, "")
+			if name, _, _ := strings.Cut(content, cfg.DNS.Notransfer.Separator); !skip(strings.TrimSuffix(name, ".")) {
+				txts = append(txts, content)
+			}
+		}
+	}
+
+	return txts, nil
+}
+
+// PutRecords writes host records to the datasource without removing the records of other hosts.
+// The TXT records of every published host are replaced with its published records, a single request is made per zone.
+// In the no-transfer mode, records are published to the first no-transfer host of the zone, keeping the records of other hosts.
+func (p *PowerDNSDatasource) PutRecords(records []*DatasourceRecord) error {
+	cfg := p.Config
+	log := p.Logger
+	ctx := context.Background()
+
+	zones := make([]string, 0)
+	hosts := make(map[string][]string)
+	attrs := make(map[string][]string)
+	for _, record := range records {
+		zone, err := p.findZone(record.Hostname)
+		if err != nil {
+			log.Warnf(warnSkippedRecord, record.Hostname, err)
+			continue
+		}
+
+		if _, ok := hosts[zone]; !ok {
+			zones = append(zones, zone)
+		}
+		if _, ok := attrs[record.Hostname]; !ok {
+			hosts[zone] = append(hosts[zone], record.Hostname)
+		}
+
+		attrs[record.Hostname] = append(attrs[record.Hostname], record.Attributes)
+	}
+
+	for _, zone := range zones {
+		rrsets := make([]powerdnsRRset, 0)
+
+		if cfg.DNS.Notransfer.Enabled {
+			owner := p.notransferHosts(zone)[0]
+
+			// Keep the records of other hosts.
+			txts, err := p.notransferRecords(ctx, zone, owner, func(host string) bool { return len(attrs[host]) > 0 })
+			if err != nil {
+				return err
+			}
+
+			for _, host := range hosts[zone] {
+				for _, a := range attrs[host] {
+					txts = append(txts, host+cfg.DNS.Notransfer.Separator+a)
+				}
+			}
+
+			rrsets = append(rrsets, p.txtRRset(owner, txts))
+		} else {
+			for _, host := range hosts[zone] {
+				rrsets = append(rrsets, p.txtRRset(host, attrs[host]))
+			}
+		}
+
+		if err := p.api.patchZone(ctx, zone, rrsets); err != nil {
+			return errors.Wrapf(err, "%s: host record publishing failure", zone)
+		}
+	}
+
+	return nil
+}
+
+// ReplaceHost replaces all records of a host with a single request. The TXT RRset of the host is deleted if there are no records.
+// In the no-transfer mode, the records of the host kept by the first no-transfer host of the zone are replaced instead.
+func (p *PowerDNSDatasource) ReplaceHost(host string, records []*DatasourceRecord) error {
+	cfg := p.Config
+	ctx := context.Background()
+
+	zone, err := p.findZone(host)
+	if err != nil {
+		return errors.Wrapf(err, "%s: failed to find zone", host)
+	}
+
+	name := host
+	txts := make([]string, 0, len(records))
+	if cfg.DNS.Notransfer.Enabled {
+		name = p.notransferHosts(zone)[0]
+
+		if txts, err = p.notransferRecords(ctx, zone, name, func(h string) bool { return h == host }); err != nil {
+			return err
+		}
+	}
+
+	for _, record := range records {
+		if cfg.DNS.Notransfer.Enabled {
+			txts = append(txts, host+cfg.DNS.Notransfer.Separator+record.Attributes)
+		} else {
+			txts = append(txts, record.Attributes)
+		}
+	}
+
+	set := p.txtRRset(name, txts)
+	if len(txts) == 0 {
+		set = powerdnsRRset{Name: dns.Fqdn(name), Type: "TXT", ChangeType: powerdnsDelete, Records: []powerdnsRecord{}}
+	}
+
+	if err := p.api.patchZone(ctx, zone, []powerdnsRRset{set}); err != nil {
+		return errors.Wrapf(err, "%s: host record publishing failure", zone)
+	}
+
+	return nil
+}
+
+// PublishRecords writes host records to the datasource.
+func (p *PowerDNSDatasource) PublishRecords(records []*DatasourceRecord) error {
+	if err := p.ClearRecords(); err != nil {
+		return err
+	}
+
+	return p.PutRecords(records)
+}
+
+// NewPowerDNSDatasource creates a DNS datasource that reads and publishes host records through the PowerDNS Authoritative HTTP API.
+func NewPowerDNSDatasource(cfg *Config, log Logger) (*PowerDNSDatasource, error) {
+	d, err := NewDNSDatasource(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+
+	api := &powerdnsControl{
+		config: cfg,
+		client: &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}},
+	}
+	d.Control = api
+
+	return &PowerDNSDatasource{DNSDatasource: d, api: api}, nil
+}
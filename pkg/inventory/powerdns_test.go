@@ -0,0 +1,277 @@
+package inventory
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// servePowerDNS emulates the PowerDNS API serving the 'infra.local.' zone, keeping its RRsets in memory.
+// Requests without the 'test-key' API key are rejected.
+func servePowerDNS(t *testing.T, rrsets []powerdnsRRset) (string, func() []powerdnsRRset) {
+	var mu sync.Mutex
+	zone := append([]powerdnsRRset(nil), rrsets...)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Header.Get("X-API-Key") != "test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
+			return
+		}
+
+		if r.URL.Path != "/api/v1/servers/localhost/zones/infra.local." {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Could not find domain"})
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(&powerdnsZone{RRsets: zone})
+		case http.MethodPatch:
+			var body struct{ RRsets []powerdnsRRset }
+			json.NewDecoder(r.Body).Decode(&body)
+
+			for _, change := range body.RRsets {
+				kept := make([]powerdnsRRset, 0)
+				for _, set := range zone {
+					if !strings.EqualFold(set.Name, change.Name) || set.Type != change.Type {
+						kept = append(kept, set)
+					}
+				}
+				if change.ChangeType == powerdnsReplace {
+					change.ChangeType = ""
+					kept = append(kept, change)
+				}
+				zone = kept
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL, func() []powerdnsRRset {
+		mu.Lock()
+		defer mu.Unlock()
+
+		sort.Slice(zone, func(a, b int) bool { return zone[a].Name < zone[b].Name })
+		return zone
+	}
+}
+
+// newTestPowerDNSConfig creates a configuration for the emulated PowerDNS API.
+func newTestPowerDNSConfig(address string, key string) *Config {
+	cfg := &Config{}
+	cfg.DNS.Zones = []string{"infra.local."}
+	cfg.DNS.Notransfer.Host = "ansible-dns-inventory"
+	cfg.DNS.Notransfer.Separator = ":"
+	cfg.PowerDNS.Address = address
+	cfg.PowerDNS.Key = key
+	cfg.PowerDNS.Server = "localhost"
+	cfg.PowerDNS.Timeout = time.Second
+	cfg.PowerDNS.TTL = 300
+
+	return cfg
+}
+
+// newTestPowerDNSControl creates a PowerDNS API client for the emulated PowerDNS API.
+func newTestPowerDNSControl(t *testing.T, key string) *powerdnsControl {
+	address, _ := servePowerDNS(t, []powerdnsRRset{
+		{Name: "infra.local.", Type: "SOA", TTL: 3600, Records: []powerdnsRecord{{Content: "ns1.infra.local. admin.infra.local. 7 3600 600 86400 60"}}},
+		{Name: "app01.infra.local.", Type: "A", TTL: 300, Records: []powerdnsRecord{{Content: "10.0.0.1"}}},
+		{Name: "app01.infra.local.", Type: "TXT", TTL: 300, Records: []powerdnsRecord{
+			{Content: `"OS=linux;ENV=dev;ROLE=app;SRV=tomcat"`},
+			{Content: `"OS=linux;ENV=dev;ROLE=db;SRV=postgres"`, Disabled: true},
+		}},
+	})
+
+	return &powerdnsControl{config: newTestPowerDNSConfig(address, key), client: &http.Client{}}
+}
+
+func TestPowerDNSDatasource_PublishRecords(t *testing.T) {
+	spf := powerdnsRRset{Name: "infra.local.", Type: "TXT", TTL: 3600, Records: []powerdnsRecord{{Content: `"v=spf1 -all"`}}}
+
+	tests := []struct {
+		name       string
+		notransfer bool
+		clear      bool
+		zone       []powerdnsRRset
+		records    []*DatasourceRecord
+		want       []powerdnsRRset
+	}{
+		{
+			name: "valid",
+			zone: []powerdnsRRset{
+				{Name: "app01.infra.local.", Type: "TXT", TTL: 300, Records: []powerdnsRecord{{Content: `"OS=linux;ENV=dev;ROLE=app;SRV="`}}},
+				{Name: "db01.infra.local.", Type: "TXT", TTL: 300, Records: []powerdnsRecord{{Content: `"OS=linux;ENV=dev;ROLE=db;SRV="`}}},
+			},
+			records: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat"},
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=web;SRV=\"nginx\""},
+			},
+			want: []powerdnsRRset{
+				{Name: "app01.infra.local.", Type: "TXT", TTL: 300, Records: []powerdnsRecord{{Content: `"OS=linux;ENV=dev;ROLE=app;SRV=tomcat"`}, {Content: `"OS=linux;ENV=dev;ROLE=web;SRV=\"nginx\""`}}},
+				{Name: "db01.infra.local.", Type: "TXT", TTL: 300, Records: []powerdnsRecord{{Content: `"OS=linux;ENV=dev;ROLE=db;SRV="`}}},
+			},
+		},
+		{
+			name:  "valid-clear",
+			clear: true,
+			zone: []powerdnsRRset{
+				{Name: "db01.infra.local.", Type: "TXT", TTL: 300, Records: []powerdnsRecord{{Content: `"OS=linux;ENV=dev;ROLE=db;SRV="`}}},
+			},
+			records: []*DatasourceRecord{{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat"}},
+			want: []powerdnsRRset{
+				{Name: "app01.infra.local.", Type: "TXT", TTL: 300, Records: []powerdnsRecord{{Content: `"OS=linux;ENV=dev;ROLE=app;SRV=tomcat"`}}},
+			},
+		},
+		{
+			// Records of other hosts and other TXT RRsets are kept.
+			name:       "valid-notransfer",
+			notransfer: true,
+			zone: []powerdnsRRset{
+				spf,
+				{Name: "ansible-dns-inventory.infra.local.", Type: "TXT", TTL: 300, Records: []powerdnsRecord{
+					{Content: `"app01.infra.local:OS=linux;ENV=dev;ROLE=app;SRV="`},
+					{Content: `"db01.infra.local:OS=linux;ENV=dev;ROLE=db;SRV="`},
+				}},
+			},
+			records: []*DatasourceRecord{{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat"}},
+			want: []powerdnsRRset{
+				{Name: "ansible-dns-inventory.infra.local.", Type: "TXT", TTL: 300, Records: []powerdnsRecord{
+					{Content: `"db01.infra.local:OS=linux;ENV=dev;ROLE=db;SRV="`},
+					{Content: `"app01.infra.local:OS=linux;ENV=dev;ROLE=app;SRV=tomcat"`},
+				}},
+				spf,
+			},
+		},
+		{
+			name:       "valid-notransfer-clear",
+			notransfer: true,
+			clear:      true,
+			zone: []powerdnsRRset{
+				spf,
+				{Name: "ansible-dns-inventory.infra.local.", Type: "TXT", TTL: 300, Records: []powerdnsRecord{{Content: `"db01.infra.local:OS=linux;ENV=dev;ROLE=db;SRV="`}}},
+			},
+			records: []*DatasourceRecord{{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat"}},
+			want: []powerdnsRRset{
+				{Name: "ansible-dns-inventory.infra.local.", Type: "TXT", TTL: 300, Records: []powerdnsRecord{{Content: `"app01.infra.local:OS=linux;ENV=dev;ROLE=app;SRV=tomcat"`}}},
+				spf,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, zone := servePowerDNS(t, tt.zone)
+
+			cfg := newTestPowerDNSConfig(address, "test-key")
+			cfg.DNS.Notransfer.Enabled = tt.notransfer
+			cfg.PowerDNS.Import.Clear = tt.clear
+
+			p, err := NewPowerDNSDatasource(cfg, &testLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			if err := p.PublishRecords(tt.records); err != nil {
+				t.Fatalf("PowerDNSDatasource.PublishRecords() error = %v", err)
+			}
+
+			if got := zone(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PowerDNSDatasource.PublishRecords() zone = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPowerDNSDatasource_ReplaceHost(t *testing.T) {
+	db := powerdnsRRset{Name: "db01.infra.local.", Type: "TXT", TTL: 300, Records: []powerdnsRecord{{Content: `"OS=linux;ENV=dev;ROLE=db;SRV="`}}}
+
+	tests := []struct {
+		name       string
+		notransfer bool
+		zone       []powerdnsRRset
+		records    []*DatasourceRecord
+		want       []powerdnsRRset
+	}{
+		{
+			name: "valid",
+			zone: []powerdnsRRset{
+				{Name: "app01.infra.local.", Type: "TXT", TTL: 300, Records: []powerdnsRecord{{Content: `"OS=linux;ENV=dev;ROLE=app;SRV="`}, {Content: `"OS=linux;ENV=dev;ROLE=web;SRV="`}}},
+				db,
+			},
+			records: []*DatasourceRecord{{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat"}},
+			want: []powerdnsRRset{
+				{Name: "app01.infra.local.", Type: "TXT", TTL: 300, Records: []powerdnsRecord{{Content: `"OS=linux;ENV=dev;ROLE=app;SRV=tomcat"`}}},
+				db,
+			},
+		},
+		{
+			name: "valid-delete",
+			zone: []powerdnsRRset{
+				{Name: "app01.infra.local.", Type: "TXT", TTL: 300, Records: []powerdnsRecord{{Content: `"OS=linux;ENV=dev;ROLE=app;SRV="`}}},
+				db,
+			},
+			want: []powerdnsRRset{db},
+		},
+		{
+			name:       "valid-notransfer",
+			notransfer: true,
+			zone: []powerdnsRRset{
+				{Name: "ansible-dns-inventory.infra.local.", Type: "TXT", TTL: 300, Records: []powerdnsRecord{
+					{Content: `"app01.infra.local:OS=linux;ENV=dev;ROLE=app;SRV="`},
+					{Content: `"db01.infra.local:OS=linux;ENV=dev;ROLE=db;SRV="`},
+				}},
+			},
+			records: []*DatasourceRecord{{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat"}},
+			want: []powerdnsRRset{
+				{Name: "ansible-dns-inventory.infra.local.", Type: "TXT", TTL: 300, Records: []powerdnsRecord{
+					{Content: `"db01.infra.local:OS=linux;ENV=dev;ROLE=db;SRV="`},
+					{Content: `"app01.infra.local:OS=linux;ENV=dev;ROLE=app;SRV=tomcat"`},
+				}},
+			},
+		},
+		{
+			// The no-transfer host is removed along with the last host record.
+			name:       "valid-notransfer-delete",
+			notransfer: true,
+			zone: []powerdnsRRset{
+				{Name: "ansible-dns-inventory.infra.local.", Type: "TXT", TTL: 300, Records: []powerdnsRecord{{Content: `"app01.infra.local:OS=linux;ENV=dev;ROLE=app;SRV="`}}},
+			},
+			want: []powerdnsRRset{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, zone := servePowerDNS(t, tt.zone)
+
+			cfg := newTestPowerDNSConfig(address, "test-key")
+			cfg.DNS.Notransfer.Enabled = tt.notransfer
+
+			p, err := NewPowerDNSDatasource(cfg, &testLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			if err := p.ReplaceHost("app01.infra.local", tt.records); err != nil {
+				t.Fatalf("PowerDNSDatasource.ReplaceHost() error = %v", err)
+			}
+
+			if got := zone(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PowerDNSDatasource.ReplaceHost() zone = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
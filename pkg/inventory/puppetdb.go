@@ -0,0 +1,364 @@
+package inventory
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// PuppetDB datasource type.
+	PuppetDBDatasourceType string = "puppetdb"
+
+	// Pseudo-fact referring to the Puppet environment of a node.
+	puppetDBEnvironmentFact string = "@environment"
+)
+
+type (
+	// PuppetDBDatasource implements a read-only datasource building host records from the facts of Puppet nodes kept in PuppetDB,
+	// e.g. to keep a single source of truth while migrating from Puppet to Ansible.
+	PuppetDBDatasource struct {
+		// Inventory configuration.
+		Config *Config
+		// Inventory logger.
+		Logger Logger
+		// HTTP client.
+		Client *http.Client
+
+		// Parsed node query, nil if not configured.
+		query interface{}
+	}
+
+	// puppetDBFact represents a fact in a PuppetDB facts query response.
+	puppetDBFact struct {
+		Certname    string      `json:"certname"`
+		Environment string      `json:"environment"`
+		Name        string      `json:"name"`
+		Value       interface{} `json:"value"`
+	}
+
+	// puppetDBNode represents the facts of a node.
+	puppetDBNode struct {
+		Environment string
+		Facts       map[string]interface{}
+	}
+)
+
+// token returns the RBAC token, reading it from the token file if configured.
+func (p *PuppetDBDatasource) token() (string, error) {
+	cfg := p.Config
+
+	if len(cfg.PuppetDB.TokenFile) == 0 {
+		return cfg.PuppetDB.Token, nil
+	}
+
+	data, err := os.ReadFile(cfg.PuppetDB.TokenFile)
+	if err != nil {
+		return "", errors.Wrap(err, "token file read failure")
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// facts returns the names of the top-level facts used by the datasource.
+func (p *PuppetDBDatasource) facts() []string {
+	cfg := p.Config
+
+	paths := []string{cfg.PuppetDB.Hostname}
+	for _, path := range cfg.PuppetDB.Facts {
+		paths = append(paths, path)
+	}
+
+	seen := make(map[string]bool)
+	names := make([]string, 0, len(paths))
+	for _, path := range paths {
+		name, _, _ := strings.Cut(path, ".")
+		if len(name) > 0 && path != puppetDBEnvironmentFact && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// nodes queries the facts used by the datasource and groups them by node certname. 'certname' restricts the query to a single node if set.
+// Nodes without any of the facts are not returned.
+func (p *PuppetDBDatasource) nodes(certname string) (map[string]*puppetDBNode, error) {
+	cfg := p.Config
+
+	names := make([]interface{}, 0)
+	for _, name := range p.facts() {
+		names = append(names, name)
+	}
+
+	query := []interface{}{"and", []interface{}{"in", "name", []interface{}{"array", names}}}
+	if p.query != nil {
+		query = append(query, p.query)
+	}
+	if len(certname) > 0 {
+		query = append(query, []interface{}{"=", "certname", certname})
+	}
+
+	data, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(cfg.PuppetDB.URL, "/")+"/pdb/query/v4/facts?query="+url.QueryEscape(string(data)), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "puppetdb request failure")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	token, err := p.token()
+	if err != nil {
+		return nil, errors.Wrap(err, "puppetdb request failure")
+	}
+	if len(token) > 0 {
+		req.Header.Set("X-Authentication", token)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "puppetdb request failure")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// PuppetDB explains query errors in plain text.
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+		return nil, errors.Errorf("puppetdb request failure: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	facts := make([]*puppetDBFact, 0)
+	if err := json.NewDecoder(resp.Body).Decode(&facts); err != nil {
+		return nil, errors.Wrap(err, "puppetdb response parsing failure")
+	}
+
+	nodes := make(map[string]*puppetDBNode)
+	for _, f := range facts {
+		node, ok := nodes[f.Certname]
+		if !ok {
+			node = &puppetDBNode{Environment: f.Environment, Facts: make(map[string]interface{})}
+			nodes[f.Certname] = node
+		}
+
+		node.Facts[f.Name] = f.Value
+	}
+
+	return nodes, nil
+}
+
+// fact returns the value of a fact of a node as a string. Lists are joined with commas. An empty string is returned if the fact is missing or is a structured fact.
+func (n *puppetDBNode) fact(path string) string {
+	if path == puppetDBEnvironmentFact {
+		return n.Environment
+	}
+
+	var value interface{} = n.Facts
+	for _, element := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = m[element]
+	}
+
+	if list, ok := value.([]interface{}); ok {
+		elements := make([]string, 0, len(list))
+		for _, v := range list {
+			if s := puppetDBScalar(v); len(s) > 0 {
+				elements = append(elements, s)
+			}
+		}
+
+		return strings.Join(elements, ",")
+	}
+
+	return puppetDBScalar(value)
+}
+
+// puppetDBScalar converts a scalar fact value into a string.
+func puppetDBScalar(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return ""
+	}
+}
+
+// records converts nodes into host records, one per node. Nodes that cannot be converted are skipped.
+func (p *PuppetDBDatasource) records(nodes map[string]*puppetDBNode) []*DatasourceRecord {
+	cfg := p.Config
+	log := p.Logger
+
+	certnames := make([]string, 0, len(nodes))
+	for certname := range nodes {
+		certnames = append(certnames, certname)
+	}
+	sort.Strings(certnames)
+
+	records := make([]*DatasourceRecord, 0, len(nodes))
+	for _, certname := range certnames {
+		node := nodes[certname]
+
+		host := certname
+		if len(cfg.PuppetDB.Hostname) > 0 {
+			host = node.fact(cfg.PuppetDB.Hostname)
+		}
+		host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+		if len(host) == 0 {
+			log.Warnf("skipping puppetdb node without a hostname: %s", certname)
+			continue
+		}
+
+		attrs, err := mappedAttributes(cfg, func(key string) string {
+			if path := lookupFold(cfg.PuppetDB.Facts, key); len(path) > 0 {
+				return node.fact(path)
+			}
+
+			return ""
+		}, cfg.PuppetDB.Defaults)
+		if err != nil {
+			log.Warnf("skipping puppetdb node: %s: %v", certname, err)
+			continue
+		}
+
+		records = append(records, &DatasourceRecord{Hostname: host, Attributes: attrs, Source: "puppetdb/" + certname})
+	}
+
+	return records
+}
+
+// GetAllRecords acquires all available host records.
+func (p *PuppetDBDatasource) GetAllRecords() ([]*DatasourceRecord, error) {
+	nodes, err := p.nodes("")
+	if err != nil {
+		return nil, errors.Wrap(err, "puppetdb datasource failure")
+	}
+
+	return p.records(nodes), nil
+}
+
+// GetHostRecords queries the facts used by the datasource for a specific host. An error is returned if there are none.
+// Only the node with a matching certname is queried unless hostnames are read from a fact.
+func (p *PuppetDBDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error) {
+	cfg := p.Config
+
+	certname := ""
+	if len(cfg.PuppetDB.Hostname) == 0 {
+		certname = host
+	}
+
+	nodes, err := p.nodes(certname)
+	if err != nil {
+		return nil, errors.Wrap(err, "puppetdb datasource failure")
+	}
+
+	records := make([]*DatasourceRecord, 0)
+	for _, r := range p.records(nodes) {
+		if strings.EqualFold(r.Hostname, host) {
+			records = append(records, r)
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, errors.Wrap(ErrNoHostRecords, host)
+	}
+
+	return records, nil
+}
+
+// PublishRecords is not supported: node facts are reported by Puppet agents.
+func (p *PuppetDBDatasource) PublishRecords(records []*DatasourceRecord) error {
+	return errors.New("publishing records is not supported by the puppetdb datasource")
+}
+
+// Close closes idle connections to PuppetDB. The datasource remains usable.
+func (p *PuppetDBDatasource) Close() {
+	p.Client.CloseIdleConnections()
+}
+
+func makePuppetDBTLSConfig(cfg *Config) (*tls.Config, error) {
+	var tlsCAPool *x509.CertPool
+	var tlsKeyPair tls.Certificate
+	var err error
+
+	if len(cfg.PuppetDB.TLS.CA.PEM) > 0 {
+		tlsCAPool, err = tlsCAPoolFromPEM(cfg.PuppetDB.TLS.CA.PEM)
+	} else if len(cfg.PuppetDB.TLS.CA.Path) > 0 {
+		tlsCAPool, err = tlsCAPoolFromFile(cfg.PuppetDB.TLS.CA.Path)
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "TLS configuration error")
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.PuppetDB.TLS.Insecure,
+		RootCAs:            tlsCAPool,
+	}
+
+	if len(cfg.PuppetDB.TLS.Certificate.PEM) > 0 && len(cfg.PuppetDB.TLS.Key.PEM) > 0 {
+		tlsKeyPair, err = tlsKeyPairFromPEM(cfg.PuppetDB.TLS.Certificate.PEM, cfg.PuppetDB.TLS.Key.PEM)
+	} else if len(cfg.PuppetDB.TLS.Certificate.Path) > 0 && len(cfg.PuppetDB.TLS.Key.Path) > 0 {
+		tlsKeyPair, err = tlsKeyPairFromFile(cfg.PuppetDB.TLS.Certificate.Path, cfg.PuppetDB.TLS.Key.Path)
+	} else {
+		return tlsConfig, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "TLS configuration error")
+	}
+	tlsConfig.Certificates = []tls.Certificate{tlsKeyPair}
+
+	return tlsConfig, nil
+}
+
+// NewPuppetDBDatasource creates a PuppetDB datasource.
+func NewPuppetDBDatasource(cfg *Config, log Logger) (*PuppetDBDatasource, error) {
+	if len(cfg.PuppetDB.URL) == 0 {
+		return nil, errors.New("puppetdb datasource initialization failure: URL is not set")
+	}
+
+	p := &PuppetDBDatasource{Config: cfg, Logger: log}
+
+	if len(cfg.PuppetDB.Query) > 0 {
+		if err := json.Unmarshal([]byte(cfg.PuppetDB.Query), &p.query); err != nil {
+			return nil, errors.Wrap(err, "puppetdb datasource initialization failure: invalid query")
+		}
+
+		if _, ok := p.query.([]interface{}); !ok {
+			return nil, errors.New("puppetdb datasource initialization failure: invalid query: not an AST query")
+		}
+	}
+
+	tlsConfig, err := makePuppetDBTLSConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "puppetdb datasource initialization failure")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	p.Client = &http.Client{Transport: transport, Timeout: cfg.PuppetDB.Timeout}
+
+	return p, nil
+}
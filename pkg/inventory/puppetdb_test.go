@@ -0,0 +1,199 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testPuppetDB emulates the PuppetDB facts query endpoint. Queries are expected to select facts by name and may restrict them by certname.
+func testPuppetDB(t *testing.T) string {
+	facts := []*puppetDBFact{
+		{Certname: "app01.infra.local", Environment: "production", Name: "os", Value: map[string]interface{}{"family": "RedHat", "release": map[string]interface{}{"major": "9"}}},
+		{Certname: "app01.infra.local", Environment: "production", Name: "role", Value: []interface{}{"app", "web"}},
+		{Certname: "app01.infra.local", Environment: "production", Name: "networking", Value: map[string]interface{}{"fqdn": "App01.infra.local."}},
+		{Certname: "db01.infra.local", Environment: "staging", Name: "os", Value: map[string]interface{}{"family": "Debian"}},
+		{Certname: "db01.infra.local", Environment: "staging", Name: "role", Value: "db;app"},
+		{Certname: "db01.infra.local", Environment: "staging", Name: "networking", Value: map[string]interface{}{"fqdn": "db01.infra.local"}},
+		{Certname: "web01.infra.local", Environment: "production", Name: "os", Value: map[string]interface{}{"family": "Debian"}},
+		{Certname: "web01.infra.local", Environment: "production", Name: "role", Value: "web"},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pdb/query/v4/facts" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.Header.Get("X-Authentication") != "rbac-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, "Authentication required")
+			return
+		}
+
+		var query []interface{}
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("query")), &query); err != nil || len(query) < 2 || query[0] != "and" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "Invalid query")
+			return
+		}
+
+		names := make(map[string]bool)
+		for _, name := range query[1].([]interface{})[2].([]interface{})[1].([]interface{}) {
+			names[name.(string)] = true
+		}
+
+		result := make([]*puppetDBFact, 0)
+		for _, f := range facts {
+			if !names[f.Name] {
+				continue
+			}
+
+			match := true
+			for _, clause := range query[2:] {
+				c := clause.([]interface{})
+				switch c[1] {
+				case "certname":
+					match = match && f.Certname == c[2]
+				case "environment":
+					match = match && f.Environment == c[2]
+				}
+			}
+
+			if match {
+				result = append(result, f)
+			}
+		}
+
+		json.NewEncoder(w).Encode(result)
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+// newTestPuppetDBDatasource creates a PuppetDB datasource for the emulated PuppetDB mapping OS, ROLE and ENV to facts.
+func newTestPuppetDBDatasource(t *testing.T, hostname string, query string) *PuppetDBDatasource {
+	cfg := &Config{}
+	cfg.Txt.Keys.Os = "OS"
+	cfg.Txt.Keys.Env = "ENV"
+	cfg.Txt.Keys.Role = "ROLE"
+	cfg.Txt.Keys.Srv = "SRV"
+	cfg.Txt.Keys.Vars = "VARS"
+	cfg.Txt.Keys.ID = "ID"
+	cfg.Txt.Kv.Separator = ";"
+	cfg.Txt.Kv.Equalsign = "="
+	cfg.Normalize.Lowercase = true
+	cfg.PuppetDB.URL = testPuppetDB(t)
+	cfg.PuppetDB.Token = "rbac-token"
+	cfg.PuppetDB.Query = query
+	cfg.PuppetDB.Hostname = hostname
+	cfg.PuppetDB.Facts = map[string]string{"os": "os.family", "env": "@environment", "role": "role"}
+	cfg.PuppetDB.Defaults = map[string]string{"srv": "puppet"}
+	cfg.PuppetDB.Timeout = 5 * time.Second
+
+	p, err := NewPuppetDBDatasource(cfg, &testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+func TestPuppetDBDatasource_GetAllRecords(t *testing.T) {
+	tests := []struct {
+		name     string
+		hostname string
+		query    string
+		want     []*DatasourceRecord
+	}{
+		{
+			// Nodes with values containing the attribute separator are skipped.
+			name: "valid",
+			want: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=redhat;ENV=production;ROLE=app,web;SRV=puppet;VARS=", Source: "puppetdb/app01.infra.local"},
+				{Hostname: "web01.infra.local", Attributes: "OS=debian;ENV=production;ROLE=web;SRV=puppet;VARS=", Source: "puppetdb/web01.infra.local"},
+			},
+		},
+		{
+			// Nodes without the hostname fact are skipped.
+			name:     "valid-hostname-fact",
+			hostname: "networking.fqdn",
+			want: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=redhat;ENV=production;ROLE=app,web;SRV=puppet;VARS=", Source: "puppetdb/app01.infra.local"},
+			},
+		},
+		{
+			name:  "valid-query",
+			query: `["=", "environment", "production"]`,
+			want: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=redhat;ENV=production;ROLE=app,web;SRV=puppet;VARS=", Source: "puppetdb/app01.infra.local"},
+				{Hostname: "web01.infra.local", Attributes: "OS=debian;ENV=production;ROLE=web;SRV=puppet;VARS=", Source: "puppetdb/web01.infra.local"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPuppetDBDatasource(t, tt.hostname, tt.query)
+
+			got, err := p.GetAllRecords()
+			if err != nil {
+				t.Fatalf("PuppetDBDatasource.GetAllRecords() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PuppetDBDatasource.GetAllRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPuppetDBDatasource_GetHostRecords(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     string
+		hostname string
+		want     []*DatasourceRecord
+		wantErr  bool
+	}{
+		{
+			name: "valid-certname",
+			host: "web01.infra.local",
+			want: []*DatasourceRecord{
+				{Hostname: "web01.infra.local", Attributes: "OS=debian;ENV=production;ROLE=web;SRV=puppet;VARS=", Source: "puppetdb/web01.infra.local"},
+			},
+		},
+		{
+			name:     "valid-hostname-fact",
+			host:     "app01.infra.local",
+			hostname: "networking.fqdn",
+			want: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=redhat;ENV=production;ROLE=app,web;SRV=puppet;VARS=", Source: "puppetdb/app01.infra.local"},
+			},
+		},
+		{
+			name:    "invalid-missing",
+			host:    "app99.infra.local",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPuppetDBDatasource(t, tt.hostname, "")
+
+			got, err := p.GetHostRecords(tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PuppetDBDatasource.GetHostRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PuppetDBDatasource.GetHostRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package inventory
+
+import (
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Values of redacted host variables are replaced with the mask.
+	RedactMaskMode string = "mask"
+	// Redacted host variables are removed.
+	RedactDropMode string = "drop"
+)
+
+// checkRedaction validates the host variable redaction rules. The rules are not checked if redaction is disabled.
+func (i *Inventory) checkRedaction() error {
+	cfg := i.Config
+
+	if len(cfg.Redact.Keys) == 0 {
+		return nil
+	}
+
+	for _, key := range cfg.Redact.Keys {
+		if _, err := path.Match(key, ""); err != nil || len(key) == 0 {
+			return errors.Errorf("invalid redaction key pattern: '%s'", key)
+		}
+	}
+
+	switch cfg.Redact.Mode {
+	case RedactMaskMode, RedactDropMode:
+	default:
+		return errors.Errorf("unknown redaction mode: %s", cfg.Redact.Mode)
+	}
+
+	return nil
+}
+
+// redacted reports whether a host variable matches any of the redaction key patterns. Patterns are matched case-insensitively.
+func (i *Inventory) redacted(key string) bool {
+	for _, pattern := range i.Config.Redact.Keys {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(key)); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redactVariables redacts host variables in place.
+func (i *Inventory) redactVariables(vars map[string]interface{}) {
+	cfg := i.Config
+
+	for key := range vars {
+		if !i.redacted(key) {
+			continue
+		}
+
+		if cfg.Redact.Mode == RedactDropMode {
+			delete(vars, key)
+		} else {
+			vars[key] = cfg.Redact.Mask
+		}
+	}
+}
+
+// redactVars redacts host variables in a string of variables.
+func (i *Inventory) redactVars(raw string) string {
+	cfg := i.Config
+	sep, eq := cfg.Txt.Vars.Separator, cfg.Txt.Vars.Equalsign
+
+	vars := make([]string, 0)
+	for _, p := range strings.Split(raw, sep) {
+		kv := strings.Split(p, eq)
+		switch {
+		case len(p) == 0 || len(kv) != 2 || !i.redacted(kv[0]):
+			vars = append(vars, p)
+		case cfg.Redact.Mode == RedactMaskMode:
+			vars = append(vars, kv[0]+eq+cfg.Redact.Mask)
+		}
+	}
+
+	return strings.Join(vars, sep)
+}
+
+// Redact returns a copy of exported inventory data with the host variables matching the redaction key patterns redacted:
+// hosts with their attribute sets, raw host records and host variable conflicts.
+// Other values, and all values if redaction is disabled, are returned as is. Every export leaving the process goes through Redact,
+// regardless of its format.
+func (i *Inventory) Redact(v interface{}) interface{} {
+	if len(i.Config.Redact.Keys) == 0 {
+		return v
+	}
+
+	switch v := v.(type) {
+	case map[string][]*HostAttributes:
+		return i.RedactHosts(v)
+	case []*DatasourceRecord:
+		return i.RedactRecords(v)
+	case []*HostConflict:
+		return i.redactConflicts(v)
+	default:
+		return v
+	}
+}
+
+// RedactHosts returns a copy of a map of hosts with the host variables matching the redaction key patterns redacted in every attribute set.
+// The original map and attribute sets are not modified, so they can still be published. The map is returned as is if redaction is disabled.
+func (i *Inventory) RedactHosts(hosts map[string][]*HostAttributes) map[string][]*HostAttributes {
+	if len(i.Config.Redact.Keys) == 0 {
+		return hosts
+	}
+
+	redacted := make(map[string][]*HostAttributes, len(hosts))
+	for host, sets := range hosts {
+		for _, attrs := range sets {
+			set := *attrs
+			set.Vars = i.redactVars(attrs.Vars)
+			redacted[host] = append(redacted[host], &set)
+		}
+	}
+
+	return redacted
+}
+
+// RedactRecords returns a copy of raw host records with the host variables in the attributes of every record redacted.
+// The records are returned as is if redaction is disabled.
+func (i *Inventory) RedactRecords(records []*DatasourceRecord) []*DatasourceRecord {
+	cfg := i.Config
+
+	if len(cfg.Redact.Keys) == 0 {
+		return records
+	}
+
+	redacted := make([]*DatasourceRecord, 0, len(records))
+	for _, r := range records {
+		items := strings.Split(r.Attributes, cfg.Txt.Kv.Separator)
+		for n, item := range items {
+			if kv := strings.SplitN(item, cfg.Txt.Kv.Equalsign, 2); len(kv) == 2 && kv[0] == cfg.Txt.Keys.Vars {
+				items[n] = kv[0] + cfg.Txt.Kv.Equalsign + i.redactVars(kv[1])
+			}
+		}
+
+		record := *r
+		record.Attributes = strings.Join(items, cfg.Txt.Kv.Separator)
+		redacted = append(redacted, &record)
+	}
+
+	return redacted
+}
+
+// redactConflicts returns a copy of host variable conflicts with the values of redacted variables redacted.
+// Conflicts of dropped variables are left out.
+func (i *Inventory) redactConflicts(conflicts []*HostConflict) []*HostConflict {
+	cfg := i.Config
+
+	redacted := make([]*HostConflict, 0, len(conflicts))
+	for _, c := range conflicts {
+		if !i.redacted(c.Key) {
+			redacted = append(redacted, c)
+			continue
+		}
+
+		if cfg.Redact.Mode == RedactDropMode {
+			continue
+		}
+
+		conflict := *c
+		conflict.Values = make([]*ConflictValue, 0, len(c.Values))
+		for _, value := range c.Values {
+			conflict.Values = append(conflict.Values, &ConflictValue{Source: value.Source, Value: cfg.Redact.Mask})
+		}
+		redacted = append(redacted, &conflict)
+	}
+
+	return redacted
+}
+
+// redactAttributeMaps redacts the host variables of host records split into attribute maps, in place.
+func (i *Inventory) redactAttributeMaps(records []map[string]string) {
+	key := i.Config.Txt.Keys.Vars
+
+	for _, r := range records {
+		if vars, ok := r[key]; ok {
+			r[key] = i.redactVars(vars)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package inventory
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestInventory_RedactHosts(t *testing.T) {
+	hosts := map[string][]*HostAttributes{
+		"app01.infra.local": {
+			{OS: "linux", Env: "prod", Role: "app", Srv: "tomcat", Vars: "heap=2g,api_token=s3cr3t,DB_Password=hunter2"},
+			{OS: "linux", Env: "prod", Role: "web", Srv: "", Vars: ""},
+		},
+	}
+
+	tests := []struct {
+		name string
+		keys []string
+		mode string
+		want string
+	}{
+		{
+			name: "valid-mask",
+			keys: []string{"*token*", "*password*"},
+			mode: RedactMaskMode,
+			want: "heap=2g,api_token=[redacted],DB_Password=[redacted]",
+		},
+		{
+			name: "valid-drop",
+			keys: []string{"*token*", "*password*"},
+			mode: RedactDropMode,
+			want: "heap=2g",
+		},
+		{
+			name: "valid-disabled",
+			mode: RedactMaskMode,
+			want: "heap=2g,api_token=s3cr3t,DB_Password=hunter2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newTestInventory(t, true, nil)
+			i.Config.Redact.Keys = tt.keys
+			i.Config.Redact.Mode = tt.mode
+
+			got := i.RedactHosts(hosts)
+
+			if vars := got["app01.infra.local"][0].Vars; vars != tt.want {
+				t.Errorf("Inventory.RedactHosts() vars = %v, want %v", vars, tt.want)
+			}
+
+			if vars := got["app01.infra.local"][1].Vars; vars != "" {
+				t.Errorf("Inventory.RedactHosts() vars = %v, want empty", vars)
+			}
+
+			// The original attribute sets are kept intact for publishing.
+			if vars := hosts["app01.infra.local"][0].Vars; vars != "heap=2g,api_token=s3cr3t,DB_Password=hunter2" {
+				t.Errorf("Inventory.RedactHosts() modified the original vars: %v", vars)
+			}
+		})
+	}
+}
+
+func TestInventory_ExportHostVariables_redact(t *testing.T) {
+	records := []*DatasourceRecord{
+		{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=app;SRV=;VARS=heap=2g,api_token=s3cr3t"},
+	}
+
+	tests := []struct {
+		name string
+		mode string
+		want map[string]interface{}
+	}{
+		{
+			name: "valid-mask",
+			mode: RedactMaskMode,
+			want: map[string]interface{}{"heap": "2g", "api_token": "[redacted]"},
+		},
+		{
+			name: "valid-drop",
+			mode: RedactDropMode,
+			want: map[string]interface{}{"heap": "2g"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newTestInventory(t, true, records)
+			i.Config.Redact.Keys = []string{"*TOKEN*"}
+			i.Config.Redact.Mode = tt.mode
+
+			got, err := i.ExportHostVariables("app01.infra.local")
+			if err != nil {
+				t.Fatalf("Inventory.ExportHostVariables() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inventory.ExportHostVariables() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInventory_checkRedaction(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    []string
+		mode    string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			keys: []string{"*token*"},
+			mode: RedactDropMode,
+		},
+		{
+			// Rules are not checked if redaction is disabled.
+			name: "valid-disabled",
+			mode: "hide",
+		},
+		{
+			name:    "invalid-pattern",
+			keys:    []string{"[token"},
+			mode:    RedactMaskMode,
+			wantErr: true,
+		},
+		{
+			name:    "invalid-mode",
+			keys:    []string{"*token*"},
+			mode:    "hide",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.Redact.Keys = tt.keys
+			cfg.Redact.Mode = tt.mode
+
+			i := &Inventory{Config: cfg}
+			if err := i.checkRedaction(); (err != nil) != tt.wantErr {
+				t.Errorf("Inventory.checkRedaction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInventory_Redact(t *testing.T) {
+	tests := []struct {
+		name string
+		mode string
+		v    interface{}
+		want interface{}
+	}{
+		{
+			name: "valid-records",
+			mode: RedactMaskMode,
+			v: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=app;SRV=;VARS=heap=2g,api_token=s3cr3t", Source: "app01.infra.local."},
+			},
+			want: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=app;SRV=;VARS=heap=2g,api_token=[redacted]", Source: "app01.infra.local."},
+			},
+		},
+		{
+			name: "valid-records-drop",
+			mode: RedactDropMode,
+			v: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=app;SRV=;VARS=heap=2g,api_token=s3cr3t"},
+			},
+			want: []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=app;SRV=;VARS=heap=2g"},
+			},
+		},
+		{
+			name: "valid-hosts",
+			mode: RedactMaskMode,
+			v:    map[string][]*HostAttributes{"app01.infra.local": {{OS: "linux", Vars: "api_token=s3cr3t"}}},
+			want: map[string][]*HostAttributes{"app01.infra.local": {{OS: "linux", Vars: "api_token=[redacted]"}}},
+		},
+		{
+			name: "valid-conflicts",
+			mode: RedactMaskMode,
+			v: []*HostConflict{
+				{Host: "app01.infra.local", Key: "heap", Values: []*ConflictValue{{Source: "txt", Value: "1g"}, {Source: "etcd", Value: "2g"}}},
+				{Host: "app01.infra.local", Key: "api_token", Values: []*ConflictValue{{Source: "txt", Value: "s3cr3t"}, {Source: "etcd", Value: "t0ken"}}},
+			},
+			want: []*HostConflict{
+				{Host: "app01.infra.local", Key: "heap", Values: []*ConflictValue{{Source: "txt", Value: "1g"}, {Source: "etcd", Value: "2g"}}},
+				{Host: "app01.infra.local", Key: "api_token", Values: []*ConflictValue{{Source: "txt", Value: "[redacted]"}, {Source: "etcd", Value: "[redacted]"}}},
+			},
+		},
+		{
+			name: "valid-conflicts-drop",
+			mode: RedactDropMode,
+			v: []*HostConflict{
+				{Host: "app01.infra.local", Key: "api_token", Values: []*ConflictValue{{Source: "txt", Value: "s3cr3t"}}},
+			},
+			want: []*HostConflict{},
+		},
+		{
+			// Values without host variables are returned as is.
+			name: "valid-other",
+			mode: RedactMaskMode,
+			v:    map[string][]string{"app": {"app01.infra.local"}},
+			want: map[string][]string{"app": {"app01.infra.local"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newTestInventory(t, true, nil)
+			i.Config.Redact.Keys = []string{"*token*"}
+			i.Config.Redact.Mode = tt.mode
+
+			if got := i.Redact(tt.v); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inventory.Redact() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInventory_Redact_wire(t *testing.T) {
+	i := newTestInventory(t, true, nil)
+	i.Config.Redact.Keys = []string{"*token*"}
+
+	hosts := map[string][]*HostAttributes{"app01.infra.local": {{OS: "linux", Env: "prod", Role: "app", Vars: "api_token=s3cr3t"}}}
+
+	// Snapshot exports are built from the redacted hosts, whatever their encoding.
+	w, err := NewWireInventory(i.Redact(hosts))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b := w.MarshalProto(); bytes.Contains(b, []byte("s3cr3t")) {
+		t.Errorf("WireInventory.MarshalProto() contains a redacted value: %q", b)
+	}
+
+	if hosts["app01.infra.local"][0].Vars != "api_token=s3cr3t" {
+		t.Errorf("Inventory.Redact() modified the original vars: %v", hosts["app01.infra.local"][0].Vars)
+	}
+}
+
+func TestInventory_redactAttributeMaps(t *testing.T) {
+	i := newTestInventory(t, true, nil)
+	i.Config.Redact.Keys = []string{"*token*"}
+
+	records := []map[string]string{{"OS": "linux", "VARS": "heap=2g,api_token=s3cr3t"}, {"OS": "linux"}}
+	want := []map[string]string{{"OS": "linux", "VARS": "heap=2g,api_token=[redacted]"}, {"OS": "linux"}}
+
+	if i.redactAttributeMaps(records); !reflect.DeepEqual(records, want) {
+		t.Errorf("Inventory.redactAttributeMaps() = %v, want %v", records, want)
+	}
+}
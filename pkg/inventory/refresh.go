@@ -0,0 +1,65 @@
+package inventory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Error describes the zone refresh failure.
+func (e *ZoneError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Zone, e.Err)
+}
+
+// sameZone reports whether two zone names refer to the same zone, ignoring the case and the trailing dot.
+func sameZone(a string, b string) bool {
+	return strings.EqualFold(strings.Trim(a, "."), strings.Trim(b, "."))
+}
+
+// RefreshZoneHosts acquires the hosts of a single zone and merges them into a map of hosts acquired earlier,
+// replacing all hosts that belong to the zone. The original map is not modified.
+// The inventory metadata is updated with the new host count and datasource details, the record count is carried over from the last full refresh.
+func (i *Inventory) RefreshZoneHosts(hosts map[string][]*HostAttributes, zone string) (map[string][]*HostAttributes, error) {
+	log := i.Logger
+
+	ds, ok := i.Datasource.(ZoneDatasource)
+	if !ok {
+		return nil, &ZoneError{Zone: zone, Err: errors.Errorf("datasource does not support refreshing single zones: %s", i.Config.Datasource)}
+	}
+
+	if len(strings.Trim(zone, ".")) == 0 {
+		return nil, &ZoneError{Zone: zone, Err: errors.New("zone name is empty")}
+	}
+
+	records, err := ds.GetZoneRecords(zone)
+	if err != nil {
+		return nil, errors.Wrap(err, "record loading failure")
+	}
+
+	refreshed := make(map[string][]*HostAttributes)
+	if err := i.collectHosts(records, refreshed); err != nil {
+		return nil, err
+	}
+
+	merged := make(map[string][]*HostAttributes, len(hosts)+len(refreshed))
+	for host, attrs := range hosts {
+		if !sameZone(ds.HostZone(host), zone) {
+			merged[host] = attrs
+		}
+	}
+
+	for host, attrs := range refreshed {
+		merged[host] = attrs
+	}
+
+	log.Debugf("[%s] zone refreshed: %d host records, %d hosts", zone, len(records), len(refreshed))
+
+	metadata := i.describe(len(records), len(merged))
+	if i.Metadata != nil {
+		metadata.Records = i.Metadata.Records
+	}
+	i.Metadata = metadata
+
+	return merged, nil
+}
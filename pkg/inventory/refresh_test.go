@@ -0,0 +1,90 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestInventory_RefreshZoneHosts(t *testing.T) {
+	dir := t.TempDir()
+	zones := map[string]string{
+		"infra.local.": `$TTL 3600
+@ IN SOA ns1 hostmaster 1 3600 600 86400 300
+app01 IN TXT "OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS="
+db01 IN TXT "OS=linux;ENV=dev;ROLE=db;SRV=postgres;VARS="
+`,
+		"dmz.infra.local.": `$TTL 3600
+@ IN SOA ns1 hostmaster 1 3600 600 86400 300
+web01 IN TXT "OS=linux;ENV=dev;ROLE=web;SRV=nginx;VARS="
+`,
+	}
+
+	write := func(zone string, data string) {
+		if err := os.WriteFile(filepath.Join(dir, zone+"zone"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	i := newTestInventory(t, false, nil)
+	cfg := i.Config
+	cfg.DNS.Zones = nil
+	for zone, data := range zones {
+		write(zone, data)
+		cfg.DNS.Zones = append(cfg.DNS.Zones, zone)
+		cfg.DNS.Zonefile.Zones = append(cfg.DNS.Zonefile.Zones, ZonefileSpec{Zone: zone, Source: filepath.Join(dir, zone+"zone")})
+	}
+	sort.Strings(cfg.DNS.Zones)
+	i.Datasource = &DNSDatasource{Config: cfg, Logger: i.Logger}
+
+	hosts, err := i.GetHosts()
+	if err != nil {
+		t.Fatalf("Inventory.GetHosts() error = %v", err)
+	}
+
+	// Edit both zones, only the refreshed one is expected to change.
+	write("infra.local.", `$TTL 3600
+@ IN SOA ns1 hostmaster 2 3600 600 86400 300
+app01 IN TXT "OS=linux;ENV=prod;ROLE=app;SRV=tomcat;VARS="
+app02 IN TXT "OS=linux;ENV=prod;ROLE=app;SRV=tomcat;VARS="
+`)
+	write("dmz.infra.local.", `$TTL 3600
+@ IN SOA ns1 hostmaster 2 3600 600 86400 300
+`)
+
+	got, err := i.RefreshZoneHosts(hosts, "INFRA.local")
+	if err != nil {
+		t.Fatalf("Inventory.RefreshZoneHosts() error = %v", err)
+	}
+
+	envs := make(map[string]string)
+	for host, attrs := range got {
+		envs[host] = attrs[0].Env
+	}
+	want := map[string]string{"app01.infra.local": "prod", "app02.infra.local": "prod", "web01.dmz.infra.local": "dev"}
+	if !reflect.DeepEqual(envs, want) {
+		t.Errorf("Inventory.RefreshZoneHosts() = %v, want %v", envs, want)
+	}
+
+	if len(hosts) != 3 || hosts["db01.infra.local"] == nil {
+		t.Errorf("Inventory.RefreshZoneHosts() modified the original hosts: %v", hosts)
+	}
+
+	if serials := i.Metadata.Source["serials"]; !reflect.DeepEqual(serials, map[string]uint32{"dmz.infra.local.": 1, "infra.local.": 2}) {
+		t.Errorf("Inventory.RefreshZoneHosts() serials = %v", serials)
+	}
+
+	var zoneErr *ZoneError
+	if _, err := i.RefreshZoneHosts(hosts, "corp.local."); !errors.As(err, &zoneErr) {
+		t.Errorf("Inventory.RefreshZoneHosts() error = %v, want a zone error for an unknown zone", err)
+	}
+
+	i.Datasource = &testDatasource{}
+	if _, err := i.RefreshZoneHosts(hosts, "infra.local."); !errors.As(err, &zoneErr) {
+		t.Errorf("Inventory.RefreshZoneHosts() error = %v, want a zone error for a datasource without zone support", err)
+	}
+}
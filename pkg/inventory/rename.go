@@ -0,0 +1,89 @@
+package inventory
+
+import (
+	"slices"
+
+	"github.com/pkg/errors"
+)
+
+// hostRecords acquires the records of a host, leaving out records of other hosts sharing its name as a prefix.
+func (i *Inventory) hostRecords(host string) ([]*DatasourceRecord, error) {
+	records, err := i.Datasource.GetHostRecords(host)
+	if err != nil {
+		return nil, err
+	}
+
+	matching := make([]*DatasourceRecord, 0, len(records))
+	for _, r := range records {
+		if r.Hostname == host {
+			matching = append(matching, r)
+		}
+	}
+
+	return matching, nil
+}
+
+// RenameHost moves all records of a host to a new hostname and removes the old ones.
+// Record attributes, including the unique host identifier, are carried over as is. If dryRun is true, nothing is written to the datasource.
+func (i *Inventory) RenameHost(from string, to string, dryRun bool) (*HostRename, error) {
+	cfg := i.Config
+	log := i.Logger
+
+	if len(from) == 0 || len(to) == 0 || from == to {
+		return nil, errors.Errorf("invalid host rename: '%s' to '%s'", from, to)
+	}
+
+	ds, ok := i.Datasource.(RenamingDatasource)
+	if !ok {
+		return nil, errors.Errorf("datasource does not support renaming hosts: %s", cfg.Datasource)
+	}
+
+	records, err := i.hostRecords(from)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: record loading failure", from)
+	}
+
+	if len(records) == 0 {
+		return nil, errors.Wrap(ErrNoHostRecords, from)
+	}
+
+	existing, err := i.hostRecords(to)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: record loading failure", to)
+	}
+
+	if len(existing) > 0 {
+		return nil, errors.Errorf("%s: host already has %d record(s)", to, len(existing))
+	}
+
+	report := &HostRename{
+		From:    from,
+		To:      to,
+		Records: len(records),
+		IDs:     make([]string, 0),
+	}
+	renamed := make([]*DatasourceRecord, 0, len(records))
+
+	for _, r := range records {
+		if attrs, err := i.ParseAttributes(r.Attributes); err != nil {
+			log.Warnf("[%s] moving invalid host record as is: %v", from, err)
+		} else if len(attrs.ID) > 0 && !slices.Contains(report.IDs, attrs.ID) {
+			report.IDs = append(report.IDs, attrs.ID)
+		}
+
+		renamed = append(renamed, &DatasourceRecord{Hostname: to, Attributes: r.Attributes})
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	event := &HookEvent{Operation: "rename", Records: renamed, Hosts: []string{from}}
+	if err := i.publishEvent(event, func() error {
+		return ds.RenameHost(from, to)
+	}); err != nil {
+		return nil, errors.Wrap(err, "record publishing failure")
+	}
+
+	return report, nil
+}
@@ -0,0 +1,148 @@
+package inventory
+
+import (
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Host variables used to build target lists of other configuration management tools.
+	ansibleHostVar string = "ansible_host"
+	ansibleUserVar string = "ansible_user"
+	ansiblePortVar string = "ansible_port"
+)
+
+var (
+	// Guards the renderer registry.
+	renderersMu sync.RWMutex
+	// Registered host list renderers by export format name.
+	renderers = map[string]*Renderer{
+		"salt-roster": {Encoding: "yaml", Render: renderSaltRoster},
+		"chef":        {Encoding: "json", Render: renderChef},
+	}
+)
+
+// RegisterRenderer makes a host list renderer available as an export format of the host attributes export mode.
+// Registering a renderer with the name of an existing one replaces it.
+func RegisterRenderer(name string, r *Renderer) {
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+
+	renderers[name] = r
+}
+
+// GetRenderer returns the host list renderer registered under a name.
+func GetRenderer(name string) (*Renderer, bool) {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+
+	r, ok := renderers[name]
+
+	return r, ok
+}
+
+// renderAttributes converts the attribute sets of a host into a list of dictionaries keyed by the configured attribute key names.
+func renderAttributes(sets []*HostAttributes, cfg *Config) []map[string]string {
+	attrs := make([]map[string]string, 0, len(sets))
+
+	for _, set := range sets {
+		attrs = append(attrs, map[string]string{
+			cfg.Txt.Keys.Os:   set.OS,
+			cfg.Txt.Keys.Env:  set.Env,
+			cfg.Txt.Keys.Role: set.Role,
+			cfg.Txt.Keys.Srv:  set.Srv,
+		})
+	}
+
+	return attrs
+}
+
+// renderVariables collects host variables from all attribute sets of a host, later sets take precedence.
+func renderVariables(sets []*HostAttributes, cfg *Config) map[string]string {
+	variables := make(map[string]string)
+
+	for _, set := range sets {
+		if cfg.Txt.Vars.Enabled {
+			parseVariables(set.Vars, cfg.Txt.Vars.Separator, cfg.Txt.Vars.Equalsign, variables)
+		}
+
+		if len(set.ID) > 0 {
+			variables[hostIDVar] = set.ID
+		}
+	}
+
+	return variables
+}
+
+// renderSaltRoster produces a salt-ssh roster.
+// Connection parameters are taken from the 'ansible_host', 'ansible_user' and 'ansible_port' host variables, host attributes and other host variables become grains.
+func renderSaltRoster(hosts map[string][]*HostAttributes, cfg *Config) (interface{}, error) {
+	roster := make(map[string]*SaltRosterTarget, len(hosts))
+
+	for host, sets := range hosts {
+		variables := renderVariables(sets, cfg)
+		target := &SaltRosterTarget{Host: host}
+
+		if address, ok := variables[ansibleHostVar]; ok {
+			target.Host = address
+			delete(variables, ansibleHostVar)
+		}
+
+		if user, ok := variables[ansibleUserVar]; ok {
+			target.User = user
+			delete(variables, ansibleUserVar)
+		}
+
+		if port, ok := variables[ansiblePortVar]; ok {
+			p, err := strconv.Atoi(port)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s: invalid %s", host, ansiblePortVar)
+			}
+			target.Port = p
+			delete(variables, ansiblePortVar)
+		}
+
+		grains := make(map[string]interface{}, len(variables)+1)
+		for key, value := range variables {
+			grains[key] = value
+		}
+		grains[ansibleAttributesVar] = renderAttributes(sets, cfg)
+
+		target.MinionOpts = map[string]interface{}{"grains": grains}
+		roster[host] = target
+	}
+
+	return roster, nil
+}
+
+// renderChef produces a map of Chef node definitions suitable for 'knife bootstrap --json-attribute-file'.
+// The environment is taken from the first attribute set of a host, every role becomes a run list entry.
+func renderChef(hosts map[string][]*HostAttributes, cfg *Config) (interface{}, error) {
+	nodes := make(map[string]*ChefNode, len(hosts))
+
+	for host, sets := range hosts {
+		node := &ChefNode{RunList: make([]string, 0), Attributes: make(map[string]interface{})}
+
+		if len(sets) > 0 {
+			node.Environment = sets[0].Env
+		}
+
+		for _, set := range sets {
+			if entry := "role[" + set.Role + "]"; !slices.Contains(node.RunList, entry) {
+				node.RunList = append(node.RunList, entry)
+			}
+		}
+
+		for key, value := range renderVariables(sets, cfg) {
+			node.Attributes[key] = value
+		}
+		node.Attributes[ansibleAttributesVar] = renderAttributes(sets, cfg)
+
+		nodes[host] = node
+	}
+
+	return nodes, nil
+}
@@ -0,0 +1,95 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenderers(t *testing.T) {
+	cfg := &Config{}
+	cfg.Txt.Vars.Enabled = true
+	cfg.Txt.Vars.Separator = ","
+	cfg.Txt.Vars.Equalsign = "="
+	cfg.Txt.Keys.Os = "OS"
+	cfg.Txt.Keys.Env = "ENV"
+	cfg.Txt.Keys.Role = "ROLE"
+	cfg.Txt.Keys.Srv = "SRV"
+
+	hosts := map[string][]*HostAttributes{
+		"app01.infra.local": {
+			{OS: "linux", Env: "dev", Role: "app", Srv: "tomcat", Vars: "ansible_host=10.0.0.1,ansible_port=2222,ansible_user=deploy"},
+			{OS: "linux", Env: "dev", Role: "app", Srv: "nginx", Vars: "tier=web"},
+			{OS: "linux", Env: "dev", Role: "db", Srv: ""},
+		},
+	}
+
+	attrs := []map[string]string{
+		{"OS": "linux", "ENV": "dev", "ROLE": "app", "SRV": "tomcat"},
+		{"OS": "linux", "ENV": "dev", "ROLE": "app", "SRV": "nginx"},
+		{"OS": "linux", "ENV": "dev", "ROLE": "db", "SRV": ""},
+	}
+
+	tests := []struct {
+		name    string
+		format  string
+		hosts   map[string][]*HostAttributes
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name:   "valid-salt-roster",
+			format: "salt-roster",
+			hosts:  hosts,
+			want: map[string]*SaltRosterTarget{
+				"app01.infra.local": {
+					Host:       "10.0.0.1",
+					User:       "deploy",
+					Port:       2222,
+					MinionOpts: map[string]interface{}{"grains": map[string]interface{}{"tier": "web", ansibleAttributesVar: attrs}},
+				},
+			},
+		},
+		{
+			name:   "valid-chef",
+			format: "chef",
+			hosts:  hosts,
+			want: map[string]*ChefNode{
+				"app01.infra.local": {
+					Environment: "dev",
+					RunList:     []string{"role[app]", "role[db]"},
+					Attributes: map[string]interface{}{
+						"ansible_host":       "10.0.0.1",
+						"ansible_port":       "2222",
+						"ansible_user":       "deploy",
+						"tier":               "web",
+						ansibleAttributesVar: attrs,
+					},
+				},
+			},
+		},
+		{
+			name:    "invalid-salt-roster-port",
+			format:  "salt-roster",
+			hosts:   map[string][]*HostAttributes{"app01.infra.local": {{OS: "linux", Env: "dev", Role: "app", Vars: "ansible_port=ssh"}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ok := GetRenderer(tt.format)
+			if !ok {
+				t.Fatalf("GetRenderer() renderer %s not found", tt.format)
+			}
+
+			got, err := r.Render(tt.hosts, cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Renderer.Render() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Renderer.Render() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
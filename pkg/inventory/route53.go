@@ -0,0 +1,184 @@
+package inventory
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+const (
+	// AWS Route53 datasource type.
+	Route53DatasourceType string = "route53"
+	// Route53 API version.
+	route53APIVersion string = "2013-04-01"
+)
+
+type (
+	// route53Control reads zones with the ListResourceRecordSets action of the AWS Route53 API, which does not support zone transfers.
+	route53Control struct {
+		// Inventory configuration.
+		config *Config
+		// AWS API client.
+		client *awsClient
+
+		// Guards the hosted zone IDs.
+		mu sync.Mutex
+		// Hosted zone IDs by zone name.
+		ids map[string]string
+	}
+
+	// route53HostedZones represents a ListHostedZonesByName response.
+	route53HostedZones struct {
+		HostedZones []struct {
+			ID      string `xml:"Id"`
+			Name    string `xml:"Name"`
+			Private bool   `xml:"Config>PrivateZone"`
+		} `xml:"HostedZones>HostedZone"`
+	}
+
+	// route53RecordSets represents a ListResourceRecordSets response.
+	route53RecordSets struct {
+		RecordSets []struct {
+			Name   string   `xml:"Name"`
+			Type   string   `xml:"Type"`
+			TTL    uint32   `xml:"TTL"`
+			Values []string `xml:"ResourceRecords>ResourceRecord>Value"`
+		} `xml:"ResourceRecordSets>ResourceRecordSet"`
+		IsTruncated          bool   `xml:"IsTruncated"`
+		NextRecordName       string `xml:"NextRecordName"`
+		NextRecordType       string `xml:"NextRecordType"`
+		NextRecordIdentifier string `xml:"NextRecordIdentifier"`
+	}
+)
+
+// request performs a Route53 API request.
+func (r *route53Control) request(ctx context.Context, path string, query url.Values, result interface{}) error {
+	cfg := r.config
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	endpoint := strings.TrimSuffix(cfg.Route53.Endpoint, "/") + "/" + route53APIVersion + "/" + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	// Route53 is a global service, requests are signed for the region of its partition rather than for 'aws.region'.
+	data, err := r.client.do(http.MethodGet, endpoint, cfg.Route53.Region, "route53", nil)
+	if err != nil {
+		return errors.Wrap(err, "route53 request failure")
+	}
+
+	if err := xml.Unmarshal(data, result); err != nil {
+		return errors.Wrap(err, "route53 response parsing failure")
+	}
+
+	return nil
+}
+
+// zoneID returns the ID of the hosted zone serving a zone.
+// If a public and a private hosted zone share the name, 'route53.private' selects one of them.
+func (r *route53Control) zoneID(ctx context.Context, zone string) (string, error) {
+	cfg := r.config
+	name := dns.Fqdn(strings.ToLower(zone))
+
+	r.mu.Lock()
+	id, ok := r.ids[name]
+	r.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	result := &route53HostedZones{}
+	if err := r.request(ctx, "hostedzonesbyname", url.Values{"dnsname": {name}}, result); err != nil {
+		return "", err
+	}
+
+	for _, z := range result.HostedZones {
+		if !strings.EqualFold(z.Name, name) {
+			continue
+		}
+
+		if len(id) == 0 || z.Private == cfg.Route53.Private {
+			id = strings.TrimPrefix(z.ID, "/hostedzone/")
+		}
+	}
+
+	if len(id) == 0 {
+		return "", errors.Errorf("no hosted zone found for %s", name)
+	}
+
+	r.mu.Lock()
+	r.ids[name] = id
+	r.mu.Unlock()
+
+	return id, nil
+}
+
+// readZone reads the TXT and SOA records of a hosted zone. Other records are skipped.
+func (r *route53Control) readZone(ctx context.Context, zone string) ([]dns.RR, error) {
+	id, err := r.zoneID(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	rrs := make([]dns.RR, 0)
+	query := url.Values{}
+
+	for {
+		result := &route53RecordSets{}
+		if err := r.request(ctx, "hostedzone/"+id+"/rrset", query, result); err != nil {
+			return nil, err
+		}
+
+		for _, set := range result.RecordSets {
+			if set.Type != "TXT" && set.Type != "SOA" {
+				continue
+			}
+
+			// Values are in zone file presentation format, TXT values are quoted.
+			for _, value := range set.Values {
+				rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", set.Name, set.TTL, set.Type, value))
+				if err != nil {
+					return nil, errors.Wrap(err, "record parsing failure")
+				}
+				if rr != nil {
+					rrs = append(rrs, rr)
+				}
+			}
+		}
+
+		if !result.IsTruncated {
+			return rrs, nil
+		}
+
+		query = url.Values{"name": {result.NextRecordName}, "type": {result.NextRecordType}}
+		if len(result.NextRecordIdentifier) > 0 {
+			query.Set("identifier", result.NextRecordIdentifier)
+		}
+	}
+}
+
+// NewRoute53Datasource creates a DNS datasource that reads zones from AWS Route53 hosted zones.
+func NewRoute53Datasource(cfg *Config, log Logger) (*DNSDatasource, error) {
+	d, err := NewDNSDatasource(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Control = &route53Control{
+		config: cfg,
+		client: newAWSClient(cfg, stageTimeout(cfg.Route53.Timeout, cfg.DNS.Timeout)),
+		ids:    make(map[string]string),
+	}
+
+	return d, nil
+}
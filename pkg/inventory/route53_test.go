@@ -0,0 +1,60 @@
+package inventory
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serveRoute53 emulates the Route53 API serving the 'infra.local.' public hosted zone, two record sets per page.
+func serveRoute53(t *testing.T) string {
+	sets := []string{
+		`<ResourceRecordSet><Name>infra.local.</Name><Type>SOA</Type><TTL>900</TTL><ResourceRecords><ResourceRecord><Value>ns1.infra.local. admin.infra.local. 7 7200 900 1209600 86400</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>`,
+		`<ResourceRecordSet><Name>app01.infra.local.</Name><Type>A</Type><TTL>300</TTL><ResourceRecords><ResourceRecord><Value>10.0.0.1</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>`,
+		`<ResourceRecordSet><Name>app01.infra.local.</Name><Type>TXT</Type><TTL>300</TTL><ResourceRecords><ResourceRecord><Value>"OS=linux;ENV=dev;ROLE=app;SRV=tomcat"</Value></ResourceRecord><ResourceRecord><Value>"OS=linux;ENV=dev;ROLE=db;" "SRV=postgres"</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), awsSigningAlgorithm+" Credential=AKIDEXAMPLE/") {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `<ErrorResponse><Error><Code>MissingAuthenticationToken</Code><Message>Missing Authentication Token</Message></Error></ErrorResponse>`)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/2013-04-01/hostedzonesbyname":
+			fmt.Fprint(w, `<ListHostedZonesByNameResponse><HostedZones>`+
+				`<HostedZone><Id>/hostedzone/ZPRIVATE</Id><Name>infra.local.</Name><Config><PrivateZone>true</PrivateZone></Config></HostedZone>`+
+				`<HostedZone><Id>/hostedzone/ZPUBLIC</Id><Name>infra.local.</Name><Config><PrivateZone>false</PrivateZone></Config></HostedZone>`+
+				`</HostedZones></ListHostedZonesByNameResponse>`)
+		case "/2013-04-01/hostedzone/ZPUBLIC/rrset":
+			page := sets[2:]
+			next := ""
+			if r.URL.Query().Get("name") == "" {
+				page = sets[:2]
+				next = `<NextRecordName>app01.infra.local.</NextRecordName><NextRecordType>TXT</NextRecordType>`
+			}
+
+			fmt.Fprintf(w, `<ListResourceRecordSetsResponse><ResourceRecordSets>%s</ResourceRecordSets><IsTruncated>%t</IsTruncated>%s</ListResourceRecordSetsResponse>`, strings.Join(page, ""), len(next) > 0, next)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<ErrorResponse><Error><Code>NoSuchHostedZone</Code><Message>No hosted zone found</Message></Error></ErrorResponse>`)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+// newTestRoute53Control creates a Route53 client for the emulated Route53 API using static credentials.
+func newTestRoute53Control(t *testing.T) *route53Control {
+	cfg := &Config{}
+	cfg.Route53.Endpoint = serveRoute53(t)
+	cfg.Route53.Region = "us-east-1"
+	cfg.AWS.AccessKeyID = "AKIDEXAMPLE"
+	cfg.AWS.SecretAccessKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+
+	return &route53Control{config: cfg, client: newAWSClient(cfg, 0), ids: make(map[string]string)}
+}
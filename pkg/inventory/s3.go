@@ -0,0 +1,323 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// S3 datasource type.
+	S3DatasourceType string = "s3"
+)
+
+// S3Datasource implements a datasource backed by an inventory snapshot object in S3 or S3-compatible storage.
+// The snapshot is an 'attrs' export: a YAML or JSON dictionary mapping every host to a list of dictionaries of host attributes.
+type S3Datasource struct {
+	// Inventory configuration.
+	Config *Config
+	// Inventory logger.
+	Logger Logger
+
+	// AWS API client.
+	client *awsClient
+	// Snapshot object URL.
+	object string
+	// Region used to sign requests.
+	region string
+}
+
+// s3Snapshot represents an inventory snapshot, mapping hosts to the attributes of their records.
+type s3Snapshot map[string][]map[string]string
+
+// source returns the location of the snapshot object used in host record sources.
+func (s *S3Datasource) source() string {
+	cfg := s.Config
+
+	return "s3://" + cfg.S3.Bucket + "/" + cfg.S3.Key
+}
+
+// get reads the snapshot object and returns it with its ETag. A missing object is an empty snapshot if allowMissing is set.
+func (s *S3Datasource) get(allowMissing bool) (s3Snapshot, string, error) {
+	req, err := http.NewRequest(http.MethodGet, s.object, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := s.client.sign(req, nil, s.region, "s3"); err != nil {
+		return nil, "", err
+	}
+
+	headers, data, err := s.client.exchange(req)
+	if err != nil {
+		var statusErr *awsStatusError
+		if allowMissing && errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			return make(s3Snapshot), "", nil
+		}
+
+		return nil, "", errors.Wrapf(err, "%s: snapshot reading failure", s.source())
+	}
+
+	// JSON snapshots are valid YAML.
+	snapshot := make(s3Snapshot)
+	if err := yaml.Unmarshal(data, &snapshot); err != nil {
+		return nil, "", errors.Wrapf(err, "%s: snapshot parsing failure", s.source())
+	}
+
+	return snapshot, headers.Get("ETag"), nil
+}
+
+// put writes the snapshot object. If an ETag is given, the write fails if the object has been modified since it was read,
+// otherwise it fails if the object has been created in the meantime (conditional writes only).
+func (s *S3Datasource) put(snapshot s3Snapshot, etag string, conditional bool) error {
+	cfg := s.Config
+
+	var data []byte
+	var err error
+	contentType := "application/yaml"
+
+	if s.format() == "json" {
+		data, err = json.Marshal(snapshot)
+		contentType = "application/json"
+	} else {
+		data, err = yaml.Marshal(snapshot)
+	}
+	if err != nil {
+		return errors.Wrap(err, "snapshot marshalling failure")
+	}
+
+	req, err := http.NewRequest(http.MethodPut, s.object, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	if len(cfg.S3.SSE) > 0 {
+		req.Header.Set("X-Amz-Server-Side-Encryption", cfg.S3.SSE)
+		if cfg.S3.SSE == "aws:kms" && len(cfg.S3.KMSKey) > 0 {
+			req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", cfg.S3.KMSKey)
+		}
+	}
+
+	if conditional && cfg.S3.Conditional {
+		if len(etag) > 0 {
+			req.Header.Set("If-Match", etag)
+		} else {
+			req.Header.Set("If-None-Match", "*")
+		}
+	}
+
+	if err := s.client.sign(req, data, s.region, "s3"); err != nil {
+		return err
+	}
+
+	if _, _, err := s.client.exchange(req); err != nil {
+		var statusErr *awsStatusError
+		if errors.As(err, &statusErr) && (statusErr.StatusCode == http.StatusPreconditionFailed || statusErr.StatusCode == http.StatusConflict) {
+			return errors.Errorf("%s: snapshot has been modified concurrently, retry the operation", s.source())
+		}
+
+		return errors.Wrapf(err, "%s: snapshot writing failure", s.source())
+	}
+
+	return nil
+}
+
+// format returns the snapshot format.
+func (s *S3Datasource) format() string {
+	cfg := s.Config
+
+	if cfg.S3.Format == "auto" {
+		if strings.HasSuffix(strings.ToLower(cfg.S3.Key), ".json") {
+			return "json"
+		}
+
+		return "yaml"
+	}
+
+	return cfg.S3.Format
+}
+
+// records converts a snapshot into host records. Attribute sets that cannot be converted are skipped.
+func (s *S3Datasource) records(snapshot s3Snapshot) []*DatasourceRecord {
+	cfg := s.Config
+	log := s.Logger
+
+	hosts := make([]string, 0, len(snapshot))
+	for host := range snapshot {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	records := make([]*DatasourceRecord, 0)
+	for _, host := range hosts {
+		name := strings.ToLower(strings.TrimSuffix(host, "."))
+
+		for _, attrs := range snapshot[host] {
+			raw, err := mappedAttributes(cfg, func(key string) string { return lookupFold(attrs, key) }, nil)
+			if err != nil {
+				log.Warnf("skipping s3 host record: %s: %v", host, err)
+				continue
+			}
+
+			records = append(records, &DatasourceRecord{Hostname: name, Attributes: raw, Source: s.source()})
+		}
+	}
+
+	return records
+}
+
+// attributes converts a host attribute string into a dictionary of host attributes.
+func (s *S3Datasource) attributes(raw string) map[string]string {
+	cfg := s.Config
+	attrs := make(map[string]string)
+
+	for _, pair := range strings.Split(raw, cfg.Txt.Kv.Separator) {
+		if key, value, ok := strings.Cut(pair, cfg.Txt.Kv.Equalsign); ok {
+			attrs[key] = value
+		}
+	}
+
+	return attrs
+}
+
+// GetAllRecords acquires all available host records.
+func (s *S3Datasource) GetAllRecords() ([]*DatasourceRecord, error) {
+	snapshot, _, err := s.get(false)
+	if err != nil {
+		return nil, errors.Wrap(err, "s3 datasource failure")
+	}
+
+	return s.records(snapshot), nil
+}
+
+// GetHostRecords returns the records of a specific host from the snapshot object.
+// The whole snapshot is read, so this is as expensive as acquiring all records.
+func (s *S3Datasource) GetHostRecords(host string) ([]*DatasourceRecord, error) {
+	all, err := s.GetAllRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*DatasourceRecord, 0)
+	for _, r := range all {
+		if strings.EqualFold(r.Hostname, host) {
+			records = append(records, r)
+		}
+	}
+
+	return records, nil
+}
+
+// PublishRecords writes host records to the snapshot, replacing the whole snapshot or only the published hosts depending on the import configuration.
+func (s *S3Datasource) PublishRecords(records []*DatasourceRecord) error {
+	cfg := s.Config
+
+	snapshot := make(s3Snapshot)
+	etag := ""
+	conditional := false
+
+	if !cfg.S3.Import.Clear {
+		var err error
+		if snapshot, etag, err = s.get(true); err != nil {
+			return errors.Wrap(err, "s3 datasource failure")
+		}
+		conditional = true
+
+		for _, r := range records {
+			delete(snapshot, r.Hostname)
+		}
+	}
+
+	for _, r := range records {
+		snapshot[r.Hostname] = append(snapshot[r.Hostname], s.attributes(r.Attributes))
+	}
+
+	return errors.Wrap(s.put(snapshot, etag, conditional), "s3 datasource failure")
+}
+
+// ReplaceHost replaces all records of a host with the given records, removing the host if there are none.
+func (s *S3Datasource) ReplaceHost(host string, records []*DatasourceRecord) error {
+	snapshot, etag, err := s.get(true)
+	if err != nil {
+		return errors.Wrap(err, "s3 datasource failure")
+	}
+
+	delete(snapshot, host)
+	for _, r := range records {
+		snapshot[r.Hostname] = append(snapshot[r.Hostname], s.attributes(r.Attributes))
+	}
+
+	return errors.Wrap(s.put(snapshot, etag, true), "s3 datasource failure")
+}
+
+// Close closes idle connections to the object storage endpoint. The datasource remains usable.
+func (s *S3Datasource) Close() {
+	s.client.client.CloseIdleConnections()
+}
+
+// NewS3Datasource creates an S3 datasource. Credentials are acquired on the first request.
+func NewS3Datasource(cfg *Config, log Logger) (*S3Datasource, error) {
+	if len(cfg.S3.Bucket) == 0 || len(cfg.S3.Key) == 0 {
+		return nil, errors.New("s3 datasource initialization failure: bucket or object key is not set")
+	}
+
+	switch cfg.S3.Format {
+	case "auto", "json", "yaml":
+	default:
+		return nil, errors.Errorf("s3 datasource initialization failure: unknown snapshot format: %s", cfg.S3.Format)
+	}
+
+	switch cfg.S3.SSE {
+	case "", "AES256", "aws:kms":
+	default:
+		return nil, errors.Errorf("s3 datasource initialization failure: unknown server-side encryption: %s", cfg.S3.SSE)
+	}
+
+	s := &S3Datasource{
+		Config: cfg,
+		Logger: log,
+		client: newAWSClient(cfg, cfg.S3.Timeout),
+		region: cfg.S3.Region,
+	}
+
+	if len(s.region) == 0 {
+		s.region = cfg.AWS.Region
+	}
+
+	endpoint := cfg.S3.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = "https://s3." + s.region + ".amazonaws.com"
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || len(u.Host) == 0 {
+		return nil, errors.Errorf("s3 datasource initialization failure: invalid endpoint: %s", endpoint)
+	}
+
+	// Key segments are escaped the same way they are in the signature.
+	segments := strings.Split(cfg.S3.Key, "/")
+	for n, segment := range segments {
+		segments[n] = awsEscape(segment)
+	}
+	key := strings.Join(segments, "/")
+
+	if cfg.S3.PathStyle {
+		prefix := strings.TrimSuffix(u.EscapedPath(), "/")
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + cfg.S3.Bucket + "/" + cfg.S3.Key
+		u.RawPath = prefix + "/" + awsEscape(cfg.S3.Bucket) + "/" + key
+	} else {
+		u.Host = cfg.S3.Bucket + "." + u.Host
+		u.Path = "/" + cfg.S3.Key
+		u.RawPath = "/" + key
+	}
+	s.object = u.String()
+
+	return s, nil
+}
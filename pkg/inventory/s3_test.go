@@ -0,0 +1,247 @@
+package inventory
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// testS3 emulates an S3 bucket holding a single object with conditional writes.
+type testS3 struct {
+	mu sync.Mutex
+	// Object contents, nil if the object does not exist.
+	data []byte
+	// Server-side encryption requested by the last write.
+	sse string
+	// Reject all conditional writes as if the object had been modified concurrently.
+	conflict bool
+}
+
+func (s *testS3) etag() string {
+	return fmt.Sprintf(`"%x"`, sha256.Sum256(s.data))
+}
+
+// serve starts the emulated S3 API for the 'inventory' bucket and the 'snapshots/hosts file.yaml' object key.
+func (s *testS3) serve(t *testing.T) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if !strings.HasPrefix(r.Header.Get("Authorization"), awsSigningAlgorithm+" Credential=AKIDEXAMPLE/") || len(r.Header.Get("X-Amz-Content-Sha256")) == 0 {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+			return
+		}
+
+		if r.URL.Path != "/inventory/snapshots/hosts file.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchBucket</Code><Message>The specified bucket does not exist</Message></Error>`)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			if s.data == nil {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+				return
+			}
+
+			w.Header().Set("ETag", s.etag())
+			w.Write(s.data)
+		case http.MethodPut:
+			match, none := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+			if (len(match) > 0 || len(none) > 0) && s.conflict || len(match) > 0 && (s.data == nil || match != s.etag()) || none == "*" && s.data != nil {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				fmt.Fprint(w, `<Error><Code>PreconditionFailed</Code><Message>At least one of the pre-conditions you specified did not hold</Message></Error>`)
+				return
+			}
+
+			s.data, _ = io.ReadAll(r.Body)
+			s.sse = r.Header.Get("X-Amz-Server-Side-Encryption")
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+// newTestS3Datasource creates an S3 datasource for the emulated S3 API using static credentials and path-style requests.
+func newTestS3Datasource(t *testing.T, s3 *testS3) *S3Datasource {
+	cfg := &Config{}
+	cfg.Txt.Keys.Os = "OS"
+	cfg.Txt.Keys.Env = "ENV"
+	cfg.Txt.Keys.Role = "ROLE"
+	cfg.Txt.Keys.Srv = "SRV"
+	cfg.Txt.Keys.Vars = "VARS"
+	cfg.Txt.Keys.ID = "ID"
+	cfg.Txt.Kv.Separator = ";"
+	cfg.Txt.Kv.Equalsign = "="
+	cfg.AWS.Region = "eu-west-1"
+	cfg.AWS.AccessKeyID = "AKIDEXAMPLE"
+	cfg.AWS.SecretAccessKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	cfg.S3.Bucket = "inventory"
+	cfg.S3.Key = "snapshots/hosts file.yaml"
+	cfg.S3.Format = "auto"
+	cfg.S3.Endpoint = s3.serve(t)
+	cfg.S3.PathStyle = true
+	cfg.S3.SSE = "aws:kms"
+	cfg.S3.Conditional = true
+
+	s, err := NewS3Datasource(cfg, &testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestS3Datasource_GetAllRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			// Attribute sets that cannot be converted into attribute strings are skipped.
+			name: "valid",
+			data: "APP01.infra.local.:\n  - {OS: linux, ENV: dev, ROLE: app, SRV: tomcat, VARS: \"\"}\n  - {os: linux, env: dev, role: web, srv: nginx, id: a1}\n" +
+				"db01.infra.local:\n  - {OS: linux, ENV: prod, ROLE: \"db;app\", SRV: postgres}\n",
+			want: []string{
+				"app01.infra.local OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS=",
+				"app01.infra.local OS=linux;ENV=dev;ROLE=web;SRV=nginx;VARS=;ID=a1",
+			},
+		},
+		{
+			name:    "invalid-missing",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-snapshot",
+			data:    "- app01.infra.local\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3 := &testS3{}
+			if len(tt.data) > 0 {
+				s3.data = []byte(tt.data)
+			}
+			s := newTestS3Datasource(t, s3)
+
+			records, err := s.GetAllRecords()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("S3Datasource.GetAllRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			got := make([]string, 0, len(records))
+			for _, r := range records {
+				if r.Source != "s3://inventory/snapshots/hosts file.yaml" {
+					t.Errorf("S3Datasource.GetAllRecords() source = %s", r.Source)
+				}
+				got = append(got, r.Hostname+" "+r.Attributes)
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("S3Datasource.GetAllRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestS3Datasource_PublishRecords(t *testing.T) {
+	existing := "app01.infra.local:\n  - {OS: linux, ENV: dev, ROLE: app, SRV: tomcat}\ndb01.infra.local:\n  - {OS: linux, ENV: prod, ROLE: db, SRV: postgres}\n"
+	records := []*DatasourceRecord{
+		{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=web;SRV=nginx;VARS="},
+		{Hostname: "web01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=web;SRV=nginx;VARS="},
+	}
+
+	tests := []struct {
+		name     string
+		data     string
+		clear    bool
+		conflict bool
+		want     s3Snapshot
+		wantErr  bool
+	}{
+		{
+			name:  "valid-clear",
+			data:  existing,
+			clear: true,
+			want: s3Snapshot{
+				"app01.infra.local": {{"OS": "linux", "ENV": "dev", "ROLE": "web", "SRV": "nginx", "VARS": ""}},
+				"web01.infra.local": {{"OS": "linux", "ENV": "prod", "ROLE": "web", "SRV": "nginx", "VARS": ""}},
+			},
+		},
+		{
+			// Hosts that are not published are kept.
+			name: "valid-merge",
+			data: existing,
+			want: s3Snapshot{
+				"app01.infra.local": {{"OS": "linux", "ENV": "dev", "ROLE": "web", "SRV": "nginx", "VARS": ""}},
+				"db01.infra.local":  {{"OS": "linux", "ENV": "prod", "ROLE": "db", "SRV": "postgres"}},
+				"web01.infra.local": {{"OS": "linux", "ENV": "prod", "ROLE": "web", "SRV": "nginx", "VARS": ""}},
+			},
+		},
+		{
+			name: "valid-merge-missing",
+			want: s3Snapshot{
+				"app01.infra.local": {{"OS": "linux", "ENV": "dev", "ROLE": "web", "SRV": "nginx", "VARS": ""}},
+				"web01.infra.local": {{"OS": "linux", "ENV": "prod", "ROLE": "web", "SRV": "nginx", "VARS": ""}},
+			},
+		},
+		{
+			name:     "invalid-conflict",
+			data:     existing,
+			conflict: true,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3 := &testS3{conflict: tt.conflict}
+			if len(tt.data) > 0 {
+				s3.data = []byte(tt.data)
+			}
+			s := newTestS3Datasource(t, s3)
+			s.Config.S3.Import.Clear = tt.clear
+
+			err := s.PublishRecords(records)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("S3Datasource.PublishRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			got := make(s3Snapshot)
+			if err := yaml.Unmarshal(s3.data, &got); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("S3Datasource.PublishRecords() = %v, want %v", got, tt.want)
+			}
+
+			if s3.sse != "aws:kms" {
+				t.Errorf("S3Datasource.PublishRecords() server-side encryption = %q, want aws:kms", s3.sse)
+			}
+		})
+	}
+}
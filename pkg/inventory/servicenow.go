@@ -0,0 +1,391 @@
+package inventory
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ServiceNow datasource type.
+	ServiceNowDatasourceType string = "servicenow"
+
+	// ServiceNow authentication methods.
+	serviceNowBasicAuth string = "basic"
+	serviceNowOAuth     string = "oauth"
+
+	// Access tokens expiring sooner than this are renewed before a request.
+	serviceNowTokenMargin time.Duration = 30 * time.Second
+)
+
+type (
+	// ServiceNowDatasource implements a read-only datasource building host records from the configuration items of a ServiceNow CMDB table,
+	// read through the Table API with basic authentication or OAuth access tokens.
+	ServiceNowDatasource struct {
+		// Inventory configuration.
+		Config *Config
+		// Inventory logger.
+		Logger Logger
+		// HTTP client.
+		Client *http.Client
+
+		// Guards the access token.
+		mu sync.Mutex
+		// OAuth access token and its expiration time.
+		token   string
+		expires time.Time
+		// Instance URL without a trailing slash.
+		instance string
+	}
+
+	// serviceNowError represents the error of a failed ServiceNow API request.
+	serviceNowError struct {
+		Error struct {
+			Message string `json:"message"`
+			Detail  string `json:"detail"`
+		} `json:"error"`
+	}
+)
+
+// accessToken returns a valid OAuth access token, requesting a new one if there is none, it is about to expire or renewal is forced.
+func (s *ServiceNowDatasource) accessToken(renew bool) (string, error) {
+	cfg := s.Config
+	auth := cfg.ServiceNow.Auth
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !renew && len(s.token) > 0 && time.Until(s.expires) > serviceNowTokenMargin {
+		return s.token, nil
+	}
+
+	form := url.Values{"grant_type": {auth.Grant}, "client_id": {auth.ClientID}, "client_secret": {auth.ClientSecret}}
+	if auth.Grant == "password" {
+		form.Set("username", auth.Username)
+		form.Set("password", auth.Password)
+	}
+
+	resp, err := s.Client.PostForm(s.instance+"/oauth_token.do", form)
+	if err != nil {
+		return "", errors.Wrap(err, "servicenow token request failure")
+	}
+	defer resp.Body.Close()
+
+	result := &struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return "", errors.Wrapf(err, "servicenow token response parsing failure: %s", resp.Status)
+	}
+
+	if resp.StatusCode != http.StatusOK || len(result.AccessToken) == 0 {
+		return "", errors.Errorf("servicenow token request failure: %s: %s %s", resp.Status, result.Error, result.ErrorDescription)
+	}
+
+	s.token = result.AccessToken
+	s.expires = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+
+	return s.token, nil
+}
+
+// page reads a page of configuration items, renewing the access token once if it has been rejected.
+func (s *ServiceNowDatasource) page(params url.Values) ([]map[string]interface{}, error) {
+	cfg := s.Config
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(http.MethodGet, s.instance+"/api/now/table/"+url.PathEscape(cfg.ServiceNow.Table)+"?"+params.Encode(), nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "servicenow request failure")
+		}
+		req.Header.Set("Accept", "application/json")
+
+		if cfg.ServiceNow.Auth.Method == serviceNowOAuth {
+			token, err := s.accessToken(attempt > 0)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		} else {
+			req.SetBasicAuth(cfg.ServiceNow.Auth.Username, cfg.ServiceNow.Auth.Password)
+		}
+
+		resp, err := s.Client.Do(req)
+		if err != nil {
+			return nil, errors.Wrap(err, "servicenow request failure")
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && cfg.ServiceNow.Auth.Method == serviceNowOAuth && attempt == 0 {
+			resp.Body.Close()
+			continue
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			result := &serviceNowError{}
+			json.NewDecoder(resp.Body).Decode(result)
+			resp.Body.Close()
+
+			return nil, errors.Errorf("servicenow request failure: %s: %s %s", resp.Status, result.Error.Message, result.Error.Detail)
+		}
+
+		result := &struct {
+			Result []map[string]interface{} `json:"result"`
+		}{}
+		err = json.NewDecoder(resp.Body).Decode(result)
+		resp.Body.Close()
+
+		if err != nil {
+			return nil, errors.Wrap(err, "servicenow response parsing failure")
+		}
+
+		return result.Result, nil
+	}
+}
+
+// fields returns the fields requested from the table: the system ID, the hostname field and the mapped fields.
+func (s *ServiceNowDatasource) fields() []string {
+	cfg := s.Config
+
+	seen := map[string]bool{"sys_id": true, cfg.ServiceNow.Hostname: true}
+	fields := []string{"sys_id", cfg.ServiceNow.Hostname}
+
+	names := make([]string, 0, len(cfg.ServiceNow.Fields))
+	for _, field := range cfg.ServiceNow.Fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+
+	for _, field := range names {
+		if len(field) > 0 && !seen[field] {
+			seen[field] = true
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// items reads all configuration items matching an encoded query, page by page. Items are ordered by system ID, so that pages do not overlap.
+func (s *ServiceNowDatasource) items(query string) ([]map[string]interface{}, error) {
+	cfg := s.Config
+
+	conditions := make([]string, 0, 3)
+	for _, condition := range []string{cfg.ServiceNow.Query, query, "ORDERBYsys_id"} {
+		if len(condition) > 0 {
+			conditions = append(conditions, condition)
+		}
+	}
+
+	params := url.Values{
+		"sysparm_query":                  {strings.Join(conditions, "^")},
+		"sysparm_fields":                 {strings.Join(s.fields(), ",")},
+		"sysparm_limit":                  {strconv.Itoa(cfg.ServiceNow.PageSize)},
+		"sysparm_display_value":          {strconv.FormatBool(cfg.ServiceNow.DisplayValue)},
+		"sysparm_exclude_reference_link": {"true"},
+	}
+
+	items := make([]map[string]interface{}, 0)
+	for offset := 0; ; offset += cfg.ServiceNow.PageSize {
+		params.Set("sysparm_offset", strconv.Itoa(offset))
+
+		page, err := s.page(params)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, page...)
+
+		if len(page) < cfg.ServiceNow.PageSize {
+			return items, nil
+		}
+	}
+}
+
+// serviceNowValue returns the value of a field of a configuration item as a string.
+// Reference fields returned as objects are reduced to their display value, or to their value if there is no display value.
+func serviceNowValue(item map[string]interface{}, field string) string {
+	switch v := item[field].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case map[string]interface{}:
+		if display, ok := v["display_value"].(string); ok && len(display) > 0 {
+			return strings.TrimSpace(display)
+		}
+		if value, ok := v["value"].(string); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+
+	return ""
+}
+
+// hostname returns the inventory hostname of a configuration item, appending the configured domain to names that are not fully qualified.
+func (s *ServiceNowDatasource) hostname(item map[string]interface{}) string {
+	cfg := s.Config
+
+	name := strings.ToLower(strings.TrimSuffix(serviceNowValue(item, cfg.ServiceNow.Hostname), "."))
+	if len(name) > 0 && len(cfg.ServiceNow.Domain) > 0 && !strings.Contains(name, ".") {
+		name += "." + strings.Trim(strings.ToLower(cfg.ServiceNow.Domain), ".")
+	}
+
+	return name
+}
+
+// records converts configuration items into host records, one per item. Items that cannot be converted are skipped.
+func (s *ServiceNowDatasource) records(items []map[string]interface{}) []*DatasourceRecord {
+	cfg := s.Config
+	log := s.Logger
+
+	records := make([]*DatasourceRecord, 0, len(items))
+	for _, item := range items {
+		source := "servicenow/" + cfg.ServiceNow.Table + "/" + serviceNowValue(item, "sys_id")
+
+		host := s.hostname(item)
+		if len(host) == 0 {
+			log.Warnf("skipping servicenow configuration item without a hostname: %s", source)
+			continue
+		}
+
+		attrs, err := mappedAttributes(cfg, func(key string) string {
+			if field := lookupFold(cfg.ServiceNow.Fields, key); len(field) > 0 {
+				return serviceNowValue(item, field)
+			}
+
+			return ""
+		}, cfg.ServiceNow.Defaults)
+		if err != nil {
+			log.Warnf("skipping servicenow configuration item: %s: %v", source, err)
+			continue
+		}
+
+		records = append(records, &DatasourceRecord{Hostname: host, Attributes: attrs, Source: source})
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Hostname < records[j].Hostname
+	})
+
+	return records
+}
+
+// GetAllRecords acquires all available host records.
+func (s *ServiceNowDatasource) GetAllRecords() ([]*DatasourceRecord, error) {
+	items, err := s.items("")
+	if err != nil {
+		return nil, errors.Wrap(err, "servicenow datasource failure")
+	}
+
+	return s.records(items), nil
+}
+
+// GetHostRecords queries the configuration items of a specific host, reading every page of the result. An error is returned if there are none.
+// Items are queried by hostname, and by the short hostname as well if it carries the configured domain.
+func (s *ServiceNowDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error) {
+	cfg := s.Config
+	field := cfg.ServiceNow.Hostname
+
+	query := field + "=" + host
+	if domain := strings.Trim(cfg.ServiceNow.Domain, "."); len(domain) > 0 {
+		if short, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(domain)); ok && !strings.Contains(short, ".") {
+			query += "^OR" + field + "=" + short
+		}
+	}
+
+	items, err := s.items(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "servicenow datasource failure")
+	}
+
+	records := make([]*DatasourceRecord, 0)
+	for _, r := range s.records(items) {
+		if strings.EqualFold(r.Hostname, host) {
+			records = append(records, r)
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, errors.Wrap(ErrNoHostRecords, host)
+	}
+
+	return records, nil
+}
+
+// PublishRecords is not supported: configuration items are managed in the CMDB.
+func (s *ServiceNowDatasource) PublishRecords(records []*DatasourceRecord) error {
+	return errors.New("publishing records is not supported by the servicenow datasource")
+}
+
+// Close closes idle connections to the ServiceNow instance. The access token is kept for later requests.
+func (s *ServiceNowDatasource) Close() {
+	s.Client.CloseIdleConnections()
+}
+
+// NewServiceNowDatasource creates a ServiceNow datasource.
+func NewServiceNowDatasource(cfg *Config, log Logger) (*ServiceNowDatasource, error) {
+	sn := cfg.ServiceNow
+
+	u, err := url.Parse(sn.URL)
+	if err != nil || len(u.Host) == 0 {
+		return nil, errors.Errorf("servicenow datasource initialization failure: invalid URL: %s", sn.URL)
+	}
+
+	switch {
+	case len(sn.Table) == 0:
+		return nil, errors.New("servicenow datasource initialization failure: table is not set")
+	case len(sn.Hostname) == 0:
+		return nil, errors.New("servicenow datasource initialization failure: hostname field is not set")
+	case sn.PageSize <= 0:
+		return nil, errors.Errorf("servicenow datasource initialization failure: invalid page size: %d", sn.PageSize)
+	}
+
+	switch sn.Auth.Method {
+	case serviceNowBasicAuth:
+		if len(sn.Auth.Username) == 0 {
+			return nil, errors.New("servicenow datasource initialization failure: username is not set")
+		}
+	case serviceNowOAuth:
+		if len(sn.Auth.ClientID) == 0 {
+			return nil, errors.New("servicenow datasource initialization failure: OAuth client ID is not set")
+		}
+
+		if sn.Auth.Grant != "password" && sn.Auth.Grant != "client_credentials" {
+			return nil, errors.Errorf("servicenow datasource initialization failure: unsupported OAuth grant type: %s", sn.Auth.Grant)
+		}
+	default:
+		return nil, errors.Errorf("servicenow datasource initialization failure: unsupported authentication method: %s", sn.Auth.Method)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: sn.TLS.Insecure}
+	if len(sn.TLS.CA) > 0 {
+		pool, err := tlsCAPoolFromFile(sn.TLS.CA)
+		if err != nil {
+			return nil, errors.Wrap(err, "servicenow datasource initialization failure")
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &ServiceNowDatasource{
+		Config:   cfg,
+		Logger:   log,
+		Client:   &http.Client{Transport: transport, Timeout: sn.Timeout},
+		instance: strings.TrimSuffix(u.String(), "/"),
+	}, nil
+}
@@ -0,0 +1,278 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServiceNow emulates the Table API of a ServiceNow instance serving the 'cmdb_ci_server' table, accepting the 'inventory:secret' credentials
+// and access tokens issued by the OAuth token endpoint to the 'adi' client. Issued access tokens are revoked after every second table request.
+func testServiceNow(t *testing.T) string {
+	type object = map[string]interface{}
+
+	items := []object{
+		{"sys_id": "01", "fqdn": "web01", "operational_status": "1", "os": "Linux Red Hat", "environment": "Production", "u_role": "web", "u_service": "nginx"},
+		{"sys_id": "02", "fqdn": "App01.infra.local.", "operational_status": "1", "os": "Linux Red Hat", "environment": "Development", "u_role": "app", "u_service": ""},
+		{"sys_id": "03", "fqdn": "", "operational_status": "1", "os": "Linux Red Hat", "environment": "Production", "u_role": "web"},
+		{"sys_id": "04", "fqdn": "db01.infra.local", "operational_status": "2", "os": "Linux Red Hat", "environment": "Production", "u_role": "db", "u_service": "postgres"},
+		{"sys_id": "05", "fqdn": "web02.infra.local", "operational_status": "1", "os": "", "environment": "Production", "u_role": "web", "u_service": "nginx"},
+	}
+
+	var (
+		mu       sync.Mutex
+		token    string
+		issued   int
+		requests int
+	)
+
+	// match evaluates an encoded query made of 'field=value' conditions joined with '^' (AND) and '^OR' (OR).
+	match := func(item object, query string) bool {
+		result, current := true, true
+		for _, condition := range strings.Split(query, "^") {
+			if strings.HasPrefix(condition, "ORDERBY") || len(condition) == 0 {
+				continue
+			}
+
+			or := strings.HasPrefix(condition, "OR")
+			field, value, _ := strings.Cut(strings.TrimPrefix(condition, "OR"), "=")
+			ok := fmt.Sprint(item[field]) == value
+
+			if or {
+				current = current || ok
+			} else {
+				result, current = result && current, ok
+			}
+		}
+
+		return result && current
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.URL.Path == "/oauth_token.do" {
+			if r.PostFormValue("client_id") != "adi" || r.PostFormValue("client_secret") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(object{"error": "access_denied", "error_description": "invalid client"})
+				return
+			}
+
+			issued++
+			token = "token-" + strconv.Itoa(issued)
+			json.NewEncoder(w).Encode(object{"access_token": token, "token_type": "Bearer", "expires_in": 1800})
+			return
+		}
+
+		user, password, basic := r.BasicAuth()
+		bearer := len(token) > 0 && r.Header.Get("Authorization") == "Bearer "+token
+		if !bearer && !(basic && user == "inventory" && password == "secret") {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(object{"error": object{"message": "User Not Authenticated", "detail": "Required to provide Auth information"}, "status": "failure"})
+			return
+		}
+
+		if r.URL.Path != "/api/now/table/cmdb_ci_server" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(object{"error": object{"message": "Invalid table", "detail": ""}, "status": "failure"})
+			return
+		}
+
+		if requests++; bearer && requests%2 == 0 {
+			token = ""
+		}
+
+		query := r.URL.Query()
+		limit, _ := strconv.Atoi(query.Get("sysparm_limit"))
+		offset, _ := strconv.Atoi(query.Get("sysparm_offset"))
+		fields := strings.Split(query.Get("sysparm_fields"), ",")
+
+		found := make([]object, 0)
+		for _, item := range items {
+			if match(item, query.Get("sysparm_query")) {
+				result := object{}
+				for _, field := range fields {
+					if v, ok := item[field]; ok {
+						result[field] = v
+					}
+				}
+				found = append(found, result)
+			}
+		}
+
+		page := make([]object, 0)
+		if offset < len(found) {
+			page = found[offset:min(offset+limit, len(found))]
+		}
+
+		json.NewEncoder(w).Encode(object{"result": page})
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+// newTestServiceNowDatasource creates a ServiceNow datasource for the emulated instance mapping OS, ENV, ROLE and SRV to configuration item fields.
+func newTestServiceNowDatasource(t *testing.T, method string, password string, query string) *ServiceNowDatasource {
+	cfg := &Config{}
+	cfg.Txt.Keys.Os = "OS"
+	cfg.Txt.Keys.Env = "ENV"
+	cfg.Txt.Keys.Role = "ROLE"
+	cfg.Txt.Keys.Srv = "SRV"
+	cfg.Txt.Keys.Vars = "VARS"
+	cfg.Txt.Keys.ID = "ID"
+	cfg.Txt.Kv.Separator = ";"
+	cfg.Txt.Kv.Equalsign = "="
+	cfg.ServiceNow.URL = testServiceNow(t)
+	cfg.ServiceNow.Table = "cmdb_ci_server"
+	cfg.ServiceNow.Query = query
+	cfg.ServiceNow.Hostname = "fqdn"
+	cfg.ServiceNow.Domain = "infra.local"
+	cfg.ServiceNow.Fields = map[string]string{"env": "environment", "role": "u_role", "srv": "u_service", "os": "os"}
+	cfg.ServiceNow.Defaults = map[string]string{"os": "linux"}
+	cfg.ServiceNow.PageSize = 2
+	cfg.ServiceNow.Timeout = 5 * time.Second
+	cfg.ServiceNow.Auth.Method = method
+	cfg.ServiceNow.Auth.Username = "inventory"
+	cfg.ServiceNow.Auth.Password = password
+	cfg.ServiceNow.Auth.ClientID = "adi"
+	cfg.ServiceNow.Auth.ClientSecret = password
+	cfg.ServiceNow.Auth.Grant = "client_credentials"
+
+	s, err := NewServiceNowDatasource(cfg, &testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestServiceNowDatasource_GetAllRecords(t *testing.T) {
+	all := []*DatasourceRecord{
+		{Hostname: "app01.infra.local", Attributes: "OS=Linux Red Hat;ENV=Development;ROLE=app;SRV=;VARS=", Source: "servicenow/cmdb_ci_server/02"},
+		{Hostname: "db01.infra.local", Attributes: "OS=Linux Red Hat;ENV=Production;ROLE=db;SRV=postgres;VARS=", Source: "servicenow/cmdb_ci_server/04"},
+		{Hostname: "web01.infra.local", Attributes: "OS=Linux Red Hat;ENV=Production;ROLE=web;SRV=nginx;VARS=", Source: "servicenow/cmdb_ci_server/01"},
+		{Hostname: "web02.infra.local", Attributes: "OS=linux;ENV=Production;ROLE=web;SRV=nginx;VARS=", Source: "servicenow/cmdb_ci_server/05"},
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		password string
+		query    string
+		want     []*DatasourceRecord
+		wantErr  bool
+	}{
+		{
+			// Items are read in three pages, items without a hostname are skipped.
+			name:     "valid-basic",
+			method:   "basic",
+			password: "secret",
+			want:     all,
+		},
+		{
+			// The access token is revoked after the second page and renewed.
+			name:     "valid-oauth",
+			method:   "oauth",
+			password: "secret",
+			want:     all,
+		},
+		{
+			name:     "valid-query",
+			method:   "basic",
+			password: "secret",
+			query:    "operational_status=1^u_role=web",
+			want:     []*DatasourceRecord{all[2], all[3]},
+		},
+		{
+			name:     "invalid-basic",
+			method:   "basic",
+			password: "wrong",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid-oauth",
+			method:   "oauth",
+			password: "wrong",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServiceNowDatasource(t, tt.method, tt.password, tt.query)
+
+			got, err := s.GetAllRecords()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ServiceNowDatasource.GetAllRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ServiceNowDatasource.GetAllRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceNowDatasource_GetHostRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		query   string
+		want    []*DatasourceRecord
+		wantErr bool
+	}{
+		{
+			// Short hostnames are found by the qualified hostname.
+			name: "valid-short",
+			host: "web01.infra.local",
+			want: []*DatasourceRecord{
+				{Hostname: "web01.infra.local", Attributes: "OS=Linux Red Hat;ENV=Production;ROLE=web;SRV=nginx;VARS=", Source: "servicenow/cmdb_ci_server/01"},
+			},
+		},
+		{
+			name:  "valid-query",
+			host:  "web02.infra.local",
+			query: "operational_status=1",
+			want: []*DatasourceRecord{
+				{Hostname: "web02.infra.local", Attributes: "OS=linux;ENV=Production;ROLE=web;SRV=nginx;VARS=", Source: "servicenow/cmdb_ci_server/05"},
+			},
+		},
+		{
+			// The configured query excludes the item.
+			name:    "invalid-query",
+			host:    "db01.infra.local",
+			query:   "operational_status=1",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-missing",
+			host:    "db02.infra.local",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServiceNowDatasource(t, "oauth", "secret", tt.query)
+
+			got, err := s.GetHostRecords(tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ServiceNowDatasource.GetHostRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ServiceNowDatasource.GetHostRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
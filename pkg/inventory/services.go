@@ -0,0 +1,153 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+// Protocol of services that do not specify one.
+const serviceDefaultProtocol string = "tcp"
+
+// parseService parses a '<port>[/<protocol>]' service definition.
+func parseService(name string, value string) (*HostService, error) {
+	port, proto, _ := strings.Cut(value, "/")
+	if proto == "" {
+		proto = serviceDefaultProtocol
+	}
+
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 1 || p > 65535 {
+		return nil, fmt.Errorf("invalid port: %s", value)
+	}
+
+	return &HostService{Name: name, Port: p, Protocol: strings.ToLower(proto)}, nil
+}
+
+// parseSRVName splits an SRV record name prefix ('_<service>._<protocol>') into a service name and a protocol.
+func parseSRVName(name string) (string, string, error) {
+	service, proto, ok := strings.Cut(strings.Trim(name, "."), ".")
+	if !ok || !strings.HasPrefix(service, "_") || !strings.HasPrefix(proto, "_") || strings.Contains(proto, ".") {
+		return "", "", fmt.Errorf("invalid SRV record name: %s", name)
+	}
+
+	return strings.TrimPrefix(service, "_"), strings.ToLower(strings.TrimPrefix(proto, "_")), nil
+}
+
+// lookupServices acquires host services from the configured SRV records in the domain of a host.
+func (i *Inventory) lookupServices(host string) ([]*HostService, error) {
+	cfg := i.Config
+	services := make([]*HostService, 0)
+
+	_, domain, ok := strings.Cut(strings.Trim(host, "."), ".")
+	if !ok {
+		return services, nil
+	}
+
+	client := newDNSClient(cfg)
+	target := strings.ToLower(dns.Fqdn(host))
+
+	for _, name := range cfg.Services.SRV {
+		service, proto, err := parseSRVName(name)
+		if err != nil {
+			return nil, err
+		}
+
+		msg := newDNSQuery(cfg, dns.Fqdn(strings.Trim(name, ".")+"."+domain), dns.TypeSRV)
+
+		rx, _, err := client.Exchange(msg, cfg.DNS.Server)
+		if err != nil {
+			return nil, errors.Wrap(err, "dns request failed")
+		}
+
+		for _, rr := range rx.Answer {
+			if srv, ok := rr.(*dns.SRV); ok && strings.ToLower(srv.Target) == target {
+				services = append(services, &HostService{Name: service, Port: int(srv.Port), Protocol: proto})
+			}
+		}
+	}
+
+	return services, nil
+}
+
+// GetHostServices acquires a list of host services defined by host variables with the configured prefix and by SRV records pointing to the host.
+func (i *Inventory) GetHostServices(host string, vars map[string]string) []*HostService {
+	cfg := i.Config
+	log := i.Logger
+	services := make([]*HostService, 0)
+
+	for key, value := range vars {
+		name, ok := strings.CutPrefix(key, cfg.Services.Prefix)
+		if !ok || name == "" {
+			continue
+		}
+
+		service, err := parseService(name, value)
+		if err != nil {
+			log.Warnf("[%s] skipping service variable %s: %v", host, key, err)
+			continue
+		}
+
+		services = append(services, service)
+	}
+
+	if len(cfg.Services.SRV) > 0 {
+		srv, err := i.lookupServices(host)
+		if err != nil {
+			log.Warnf("[%s] skipping SRV records: %v", host, err)
+		}
+
+		services = append(services, srv...)
+	}
+
+	// Drop duplicates and keep the order stable.
+	sort.SliceStable(services, func(a, b int) bool {
+		if services[a].Name != services[b].Name {
+			return services[a].Name < services[b].Name
+		}
+		if services[a].Protocol != services[b].Protocol {
+			return services[a].Protocol < services[b].Protocol
+		}
+
+		return services[a].Port < services[b].Port
+	})
+
+	unique := services[:0]
+	for _, s := range services {
+		if len(unique) > 0 && *s == *unique[len(unique)-1] {
+			continue
+		}
+
+		unique = append(unique, s)
+	}
+
+	return unique
+}
+
+// ExportHostVariables acquires host variables and, if enabled, adds the list of host services to them.
+// Host variables matching the redaction rules are redacted.
+func (i *Inventory) ExportHostVariables(host string) (map[string]interface{}, error) {
+	cfg := i.Config
+	export := make(map[string]interface{})
+
+	vars, err := i.GetHostVariables(host)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range vars {
+		export[key] = value
+	}
+
+	if cfg.Services.Enabled {
+		export[cfg.Services.Var] = i.GetHostServices(host, vars)
+	}
+
+	i.redactVariables(export)
+
+	return export, nil
+}
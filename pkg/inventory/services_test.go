@@ -0,0 +1,96 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseService(t *testing.T) {
+	type args struct {
+		name  string
+		value string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *HostService
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			args:    args{name: "http", value: "8080"},
+			want:    &HostService{Name: "http", Port: 8080, Protocol: "tcp"},
+			wantErr: false,
+		},
+		{
+			name:    "valid-protocol",
+			args:    args{name: "syslog", value: "514/UDP"},
+			want:    &HostService{Name: "syslog", Port: 514, Protocol: "udp"},
+			wantErr: false,
+		},
+		{
+			name:    "invalid-port",
+			args:    args{name: "http", value: "http"},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "invalid-range",
+			args:    args{name: "http", value: "65536"},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseService(tt.args.name, tt.args.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseService() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseService() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseSRVName(t *testing.T) {
+	tests := []struct {
+		name        string
+		srv         string
+		wantService string
+		wantProto   string
+		wantErr     bool
+	}{
+		{
+			name:        "valid",
+			srv:         "_ldap._tcp",
+			wantService: "ldap",
+			wantProto:   "tcp",
+			wantErr:     false,
+		},
+		{
+			name:    "invalid-underscore",
+			srv:     "ldap._tcp",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-labels",
+			srv:     "_ldap._tcp.infra",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, proto, err := parseSRVName(tt.srv)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseSRVName() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if service != tt.wantService || proto != tt.wantProto {
+				t.Errorf("parseSRVName() = %v, %v, want %v, %v", service, proto, tt.wantService, tt.wantProto)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package inventory
+
+import (
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+// zoneShard returns the shard a zone is assigned to: the FNV-1a hash of the lowercase zone name without the trailing dot, modulo the number of shards.
+func zoneShard(zone string, count int) int {
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(strings.TrimSuffix(zone, "."))))
+
+	return int(h.Sum32() % uint32(count))
+}
+
+// shardZones returns the zones assigned to the shard of this instance, or all zones if sharding is disabled.
+func (d *DNSDatasource) shardZones(zones []string) []string {
+	cfg := d.Config
+	log := d.Logger
+
+	if cfg.DNS.Shard.Count <= 1 {
+		return zones
+	}
+
+	shard := make([]string, 0, len(zones)/cfg.DNS.Shard.Count+1)
+	for _, zone := range zones {
+		if zoneShard(zone, cfg.DNS.Shard.Count) == cfg.DNS.Shard.Index {
+			shard = append(shard, zone)
+		}
+	}
+
+	log.Debugf("shard %d of %d: reading %d of %d zones", cfg.DNS.Shard.Index, cfg.DNS.Shard.Count, len(shard), len(zones))
+
+	return shard
+}
+
+// MergeHosts combines partial sets of hosts (e.g. the 'attrs' exports of the instances reading different shards of zones) into a single set.
+// Runtime filters are applied to the merged hosts. Hosts found in several partial sets keep the attribute sets of all of them.
+func (i *Inventory) MergeHosts(parts []map[string][]*HostAttributes) (map[string][]*HostAttributes, error) {
+	log := i.Logger
+	hosts := make(map[string][]*HostAttributes)
+
+	// Index of the partial set each host has first been found in.
+	seen := make(map[string]int)
+	sets := 0
+
+	for n, part := range parts {
+		for host, attrs := range part {
+			if first, ok := seen[host]; ok && first != n {
+				log.Warnf("[%s] host found in partial sets %d and %d, check the shard configuration", host, first, n)
+			} else if !ok {
+				seen[host] = n
+			}
+
+			for _, a := range attrs {
+				if a == nil {
+					continue
+				}
+
+				if match, err := matchFilters(host, a, i.Filters); err != nil {
+					return nil, err
+				} else if !match {
+					log.Debugf("[%s] skipping host record not matching runtime filters", host)
+					continue
+				}
+
+				hosts[host] = append(hosts[host], a)
+				sets++
+			}
+		}
+	}
+
+	if err := i.pinMergedHosts(seen, hosts); err != nil {
+		return nil, err
+	}
+
+	i.Metadata = &InventoryMetadata{
+		Timestamp:  time.Now().UTC(),
+		Datasource: i.Config.Datasource,
+		Records:    sets,
+		Hosts:      len(hosts),
+	}
+
+	return hosts, nil
+}
@@ -0,0 +1,86 @@
+package inventory
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestDNSDatasource_shardZones(t *testing.T) {
+	zones := make([]string, 0, 100)
+	for n := 0; n < 100; n++ {
+		zones = append(zones, fmt.Sprintf("zone%d.infra.local.", n))
+	}
+
+	cfg := &Config{}
+	cfg.DNS.Shard.Count = 4
+	d := &DNSDatasource{Config: cfg, Logger: &testLogger{}}
+
+	// Every zone is assigned to exactly one shard, regardless of the case and the trailing dot.
+	got := make([]string, 0, len(zones))
+	for index := 0; index < cfg.DNS.Shard.Count; index++ {
+		cfg.DNS.Shard.Index = index
+
+		shard := d.shardZones(zones)
+		if len(shard) == 0 {
+			t.Errorf("DNSDatasource.shardZones() shard %d is empty", index)
+		}
+
+		for _, zone := range shard {
+			if zoneShard("ZONE"+zone[4:len(zone)-1], cfg.DNS.Shard.Count) != index {
+				t.Errorf("zoneShard(%s) is not stable", zone)
+			}
+		}
+
+		got = append(got, shard...)
+	}
+
+	sort.Strings(got)
+	sort.Strings(zones)
+	if !reflect.DeepEqual(got, zones) {
+		t.Errorf("DNSDatasource.shardZones() = %v, want %v", got, zones)
+	}
+
+	cfg.DNS.Shard.Count = 1
+	if got := d.shardZones(zones); !reflect.DeepEqual(got, zones) {
+		t.Errorf("DNSDatasource.shardZones() = %v, want all zones if sharding is disabled", got)
+	}
+}
+
+func TestInventory_MergeHosts(t *testing.T) {
+	i := newTestInventory(t, false, nil)
+
+	filters, err := i.ParseFilters("env=prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.Filters = filters
+
+	parts := []map[string][]*HostAttributes{
+		{
+			"app01.a.local": {{OS: "linux", Env: "prod", Role: "app", Srv: "tomcat"}},
+			"dev01.a.local": {{OS: "linux", Env: "dev", Role: "app", Srv: "tomcat"}},
+		},
+		{
+			"db01.b.local": {{OS: "linux", Env: "prod", Role: "db", Srv: "postgres"}, {OS: "linux", Env: "prod", Role: "db", Srv: "pgbouncer"}},
+		},
+	}
+
+	got, err := i.MergeHosts(parts)
+	if err != nil {
+		t.Fatalf("Inventory.MergeHosts() error = %v", err)
+	}
+
+	want := map[string][]*HostAttributes{
+		"app01.a.local": parts[0]["app01.a.local"],
+		"db01.b.local":  parts[1]["db01.b.local"],
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Inventory.MergeHosts() = %v, want %v", got, want)
+	}
+
+	if i.Metadata.Records != 3 || i.Metadata.Hosts != 2 {
+		t.Errorf("Inventory.MergeHosts() metadata = %+v, want 3 records and 2 hosts", i.Metadata)
+	}
+}
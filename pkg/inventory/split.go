@@ -0,0 +1,62 @@
+package inventory
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SplitHosts partitions hosts by the values of the host attribute selected by the split export key (e.g. one partition per environment).
+// A host with several values of the attribute (e.g. several roles) is added to every matching partition, with the attribute sets having the value only.
+// Attribute sets with an empty value (e.g. hosts without services when splitting by service) are left out.
+func (i *Inventory) SplitHosts(hosts map[string][]*HostAttributes) (map[string]map[string][]*HostAttributes, error) {
+	cfg := i.Config
+	log := i.Logger
+
+	keys := []string{cfg.Txt.Keys.Os, cfg.Txt.Keys.Env, cfg.Txt.Keys.Role, cfg.Txt.Keys.Srv, cfg.Txt.Keys.ID}
+	key := slices.IndexFunc(keys, func(k string) bool { return strings.EqualFold(k, strings.TrimSpace(cfg.Split.Key)) })
+	if key < 0 {
+		return nil, errors.Errorf("unknown split key: %s", cfg.Split.Key)
+	}
+
+	parts := make(map[string]map[string][]*HostAttributes)
+	for host, sets := range hosts {
+		for _, attrs := range sets {
+			value := []string{attrs.OS, attrs.Env, attrs.Role, attrs.Srv, attrs.ID}[key]
+			if len(value) == 0 {
+				log.Debugf("[%s] skipping attribute set without a split key value", host)
+				continue
+			}
+
+			if parts[value] == nil {
+				parts[value] = make(map[string][]*HostAttributes)
+			}
+
+			parts[value][host] = append(parts[value][host], attrs)
+		}
+	}
+
+	return parts, nil
+}
+
+// SplitPath returns the path of the inventory file written to the 'dir' directory for a split key value.
+// Attribute values come from the datasource, so values that are not a plain file name component are rejected
+// and the resulting path is checked to stay within the directory.
+func (i *Inventory) SplitPath(dir string, value string) (string, error) {
+	cfg := i.Config
+
+	if len(value) == 0 || value == "." || strings.Contains(value, "..") || strings.ContainsAny(value, "/\\\x00") {
+		return "", errors.Errorf("invalid split key value for a file name: %q", value)
+	}
+
+	path := filepath.Join(dir, strings.Replace(cfg.Split.Name, "%s", value, 1))
+
+	rel, err := filepath.Rel(filepath.Clean(dir), path)
+	if err != nil || rel != filepath.Base(path) {
+		return "", errors.Errorf("split inventory file is outside of the output directory: %s", path)
+	}
+
+	return path, nil
+}
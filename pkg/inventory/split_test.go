@@ -0,0 +1,142 @@
+package inventory
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestInventory_SplitHosts(t *testing.T) {
+	records := []*DatasourceRecord{
+		{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=app,web;SRV=tomcat;VARS="},
+		{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=;VARS="},
+		{Hostname: "db01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=db;SRV=postgres;VARS="},
+	}
+
+	prodApp := &HostAttributes{OS: "linux", Env: "prod", Role: "app", Srv: "tomcat"}
+	prodWeb := &HostAttributes{OS: "linux", Env: "prod", Role: "web", Srv: "tomcat"}
+	devApp := &HostAttributes{OS: "linux", Env: "dev", Role: "app", Srv: ""}
+	prodDB := &HostAttributes{OS: "linux", Env: "prod", Role: "db", Srv: "postgres"}
+
+	tests := []struct {
+		name    string
+		key     string
+		want    map[string]map[string][]*HostAttributes
+		wantErr bool
+	}{
+		{
+			name: "valid-env",
+			key:  "ENV",
+			want: map[string]map[string][]*HostAttributes{
+				"prod": {"app01.infra.local": {prodApp, prodWeb}, "db01.infra.local": {prodDB}},
+				"dev":  {"app01.infra.local": {devApp}},
+			},
+		},
+		{
+			// Hosts with several roles are added to the partition of every role.
+			name: "valid-role",
+			key:  "role",
+			want: map[string]map[string][]*HostAttributes{
+				"app": {"app01.infra.local": {prodApp, devApp}},
+				"web": {"app01.infra.local": {prodWeb}},
+				"db":  {"db01.infra.local": {prodDB}},
+			},
+		},
+		{
+			// Attribute sets without services are left out.
+			name: "valid-srv",
+			key:  "SRV",
+			want: map[string]map[string][]*HostAttributes{
+				"tomcat":   {"app01.infra.local": {prodApp, prodWeb}},
+				"postgres": {"db01.infra.local": {prodDB}},
+			},
+		},
+		{
+			name:    "invalid-key",
+			key:     "VARS",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newTestInventory(t, false, records)
+			i.Config.Split.Key = tt.key
+
+			hosts, err := i.GetHosts()
+			if err != nil {
+				t.Fatalf("Inventory.GetHosts() error = %v", err)
+			}
+
+			got, err := i.SplitHosts(hosts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Inventory.SplitHosts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inventory.SplitHosts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInventory_SplitPath(t *testing.T) {
+	dir := filepath.Join("out", "inventory")
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "valid",
+			value: "prod",
+			want:  filepath.Join(dir, "inventory-prod.yaml"),
+		},
+		{
+			name:    "invalid-empty",
+			value:   "",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-parent",
+			value:   "../../etc/cron.d/x",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-dots",
+			value:   "..",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-separator",
+			value:   "prod/app",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-backslash",
+			value:   `..\prod`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid-nul",
+			value:   "prod\x00",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := &Inventory{Config: &Config{}}
+			i.Config.Split.Name = "inventory-%s.yaml"
+
+			got, err := i.SplitPath(dir, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Inventory.SplitPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("Inventory.SplitPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
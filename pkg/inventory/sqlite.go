@@ -0,0 +1,259 @@
+package inventory
+
+import (
+	"database/sql"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	// Pure Go SQLite driver, no cgo required.
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// SQLite datasource type.
+	SQLiteDatasourceType string = "sqlite"
+	// Time in milliseconds to wait for other connections to release their locks on the database file.
+	sqliteBusyTimeout int = 5000
+)
+
+// SQLiteDatasource implements a datasource backed by a single SQLite database file, e.g. for air-gapped or offline runs.
+// Every row of the records table holds a hostname and a host attribute string.
+type SQLiteDatasource struct {
+	// Inventory configuration.
+	Config *Config
+	// Inventory logger.
+	Logger Logger
+
+	// SQLite database handle.
+	db *sql.DB
+}
+
+// table returns the quoted name of the records table.
+func (s *SQLiteDatasource) table() string {
+	return "\"" + strings.ReplaceAll(s.Config.SQLite.Table, "\"", "\"\"") + "\""
+}
+
+// source returns the source of a host record.
+func (s *SQLiteDatasource) source(rowid int64) string {
+	cfg := s.Config
+
+	return cfg.SQLite.Path + ":" + cfg.SQLite.Table + ":" + strconv.FormatInt(rowid, 10)
+}
+
+// records reads the records table, returning the host records that match a predicate. Rows without a hostname are skipped.
+func (s *SQLiteDatasource) records(match func(host string) bool) ([]*DatasourceRecord, error) {
+	cfg := s.Config
+	log := s.Logger
+
+	// SQLite creates missing database files when they are opened.
+	if _, err := os.Stat(cfg.SQLite.Path); err != nil {
+		return nil, errors.Wrap(err, "database reading failure")
+	}
+
+	rows, err := s.db.Query("SELECT rowid, hostname, attributes FROM " + s.table() + " ORDER BY rowid")
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: table %s reading failure", cfg.SQLite.Path, cfg.SQLite.Table)
+	}
+	defer rows.Close()
+
+	records := make([]*DatasourceRecord, 0)
+	for rows.Next() {
+		var rowid int64
+		var hostname, attrs sql.NullString
+		if err := rows.Scan(&rowid, &hostname, &attrs); err != nil {
+			return nil, errors.Wrapf(err, "%s: table %s reading failure", cfg.SQLite.Path, cfg.SQLite.Table)
+		}
+
+		host := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(hostname.String), "."))
+		if len(host) == 0 {
+			log.Warnf("skipping sqlite host record: %s: hostname is not set", s.source(rowid))
+			continue
+		}
+
+		if match(host) {
+			records = append(records, &DatasourceRecord{Hostname: host, Attributes: attrs.String, Source: s.source(rowid)})
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, "%s: table %s reading failure", cfg.SQLite.Path, cfg.SQLite.Table)
+	}
+
+	return records, nil
+}
+
+// update runs a function in a write transaction, creating the records table if it does not exist.
+func (s *SQLiteDatasource) update(fn func(tx *sql.Tx) error) error {
+	cfg := s.Config
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrapf(err, "%s: transaction failure", cfg.SQLite.Path)
+	}
+	// Rolling back a committed transaction does nothing.
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("CREATE TABLE IF NOT EXISTS " + s.table() + " (id INTEGER PRIMARY KEY, hostname TEXT NOT NULL, attributes TEXT NOT NULL)"); err != nil {
+		return errors.Wrapf(err, "%s: table %s creation failure", cfg.SQLite.Path, cfg.SQLite.Table)
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return errors.Wrapf(tx.Commit(), "%s: transaction failure", cfg.SQLite.Path)
+}
+
+// deleteHost removes all records of a host.
+func (s *SQLiteDatasource) deleteHost(tx *sql.Tx, host string) error {
+	_, err := tx.Exec("DELETE FROM "+s.table()+" WHERE lower(trim(hostname)) IN (lower(?), lower(?))", host, host+".")
+
+	return errors.Wrapf(err, "%s: record removal failure", host)
+}
+
+// put appends host records to the table, replacing all existing records of the published hosts.
+func (s *SQLiteDatasource) put(tx *sql.Tx, records []*DatasourceRecord) error {
+	hosts := make(map[string]bool)
+	for _, r := range records {
+		if !hosts[r.Hostname] {
+			if err := s.deleteHost(tx, r.Hostname); err != nil {
+				return err
+			}
+			hosts[r.Hostname] = true
+		}
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO " + s.table() + " (hostname, attributes) VALUES (?, ?)")
+	if err != nil {
+		return errors.Wrap(err, "record writing failure")
+	}
+	defer stmt.Close()
+
+	for _, r := range records {
+		if _, err := stmt.Exec(r.Hostname, r.Attributes); err != nil {
+			return errors.Wrapf(err, "%s: record writing failure", r.Hostname)
+		}
+	}
+
+	return nil
+}
+
+// GetAllRecords acquires all available host records.
+func (s *SQLiteDatasource) GetAllRecords() ([]*DatasourceRecord, error) {
+	records, err := s.records(func(string) bool { return true })
+
+	return records, errors.Wrap(err, "sqlite datasource failure")
+}
+
+// GetHostRecords acquires all available records for a specific host.
+func (s *SQLiteDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error) {
+	records, err := s.records(func(name string) bool { return strings.EqualFold(name, strings.TrimSuffix(host, ".")) })
+
+	return records, errors.Wrap(err, "sqlite datasource failure")
+}
+
+// PublishRecords writes host records to the database file in a single transaction, removing all other records first if the import mode requires it.
+func (s *SQLiteDatasource) PublishRecords(records []*DatasourceRecord) error {
+	cfg := s.Config
+
+	err := s.update(func(tx *sql.Tx) error {
+		if cfg.SQLite.Import.Clear {
+			if _, err := tx.Exec("DELETE FROM " + s.table()); err != nil {
+				return errors.Wrap(err, "record removal failure")
+			}
+		}
+
+		return s.put(tx, records)
+	})
+
+	return errors.Wrap(err, "sqlite datasource failure")
+}
+
+// ClearRecords removes all host records from the database file if the import mode requires it.
+func (s *SQLiteDatasource) ClearRecords() error {
+	cfg := s.Config
+
+	if !cfg.SQLite.Import.Clear {
+		return nil
+	}
+
+	err := s.update(func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM " + s.table())
+
+		return errors.Wrap(err, "record removal failure")
+	})
+
+	return errors.Wrap(err, "sqlite datasource failure")
+}
+
+// PutRecords writes host records to the database file, replacing all existing records of the published hosts.
+func (s *SQLiteDatasource) PutRecords(records []*DatasourceRecord) error {
+	err := s.update(func(tx *sql.Tx) error {
+		return s.put(tx, records)
+	})
+
+	return errors.Wrap(err, "sqlite datasource failure")
+}
+
+// ReplaceHost replaces all records of a host with the given records, removing the host if there are none.
+func (s *SQLiteDatasource) ReplaceHost(host string, records []*DatasourceRecord) error {
+	err := s.update(func(tx *sql.Tx) error {
+		if err := s.deleteHost(tx, host); err != nil {
+			return err
+		}
+
+		return s.put(tx, records)
+	})
+
+	return errors.Wrap(err, "sqlite datasource failure")
+}
+
+// RenameHost moves all records of a host to a new hostname. The new hostname must not have any records.
+func (s *SQLiteDatasource) RenameHost(from string, to string) error {
+	err := s.update(func(tx *sql.Tx) error {
+		var exists bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM "+s.table()+" WHERE lower(trim(hostname)) IN (lower(?), lower(?)))", to, to+".").Scan(&exists)
+		if err != nil {
+			return errors.Wrapf(err, "%s: record reading failure", to)
+		}
+		if exists {
+			return errors.Errorf("%s: host records already exist", to)
+		}
+
+		// Row IDs are kept, so renamed records stay in place.
+		_, err = tx.Exec("UPDATE "+s.table()+" SET hostname = ? WHERE lower(trim(hostname)) IN (lower(?), lower(?))", to, from, from+".")
+
+		return errors.Wrapf(err, "%s: record renaming failure", from)
+	})
+
+	return errors.Wrap(err, "sqlite datasource failure")
+}
+
+// Close closes the database file.
+func (s *SQLiteDatasource) Close() {
+	s.db.Close()
+}
+
+// NewSQLiteDatasource creates a SQLite datasource.
+func NewSQLiteDatasource(cfg *Config, log Logger) (*SQLiteDatasource, error) {
+	if len(cfg.SQLite.Path) == 0 {
+		return nil, errors.New("sqlite datasource initialization failure: database path is not set")
+	}
+
+	if len(cfg.SQLite.Table) == 0 {
+		return nil, errors.New("sqlite datasource initialization failure: table name is not set")
+	}
+
+	// Write transactions take the database lock as they begin, so that concurrent writers wait for each other instead of failing on lock upgrades.
+	dsn := cfg.SQLite.Path + "?_pragma=busy_timeout(" + strconv.Itoa(sqliteBusyTimeout) + ")&_txlock=immediate"
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "sqlite datasource initialization failure")
+	}
+
+	return &SQLiteDatasource{Config: cfg, Logger: log, db: db}, nil
+}
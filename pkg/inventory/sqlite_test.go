@@ -0,0 +1,498 @@
+package inventory
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// sqliteExec runs SQL statements on a database file.
+func sqliteExec(t *testing.T, path string, statements string) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(statements); err != nil {
+		t.Fatalf("sqlite failure: %v", err)
+	}
+}
+
+// sqliteQuery runs a query returning a single row on a database file, formatting the row like the sqlite3 shell does.
+func sqliteQuery(t *testing.T, path string, query string) string {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatalf("sqlite failure: %v", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for n := range values {
+		dest[n] = &values[n]
+	}
+
+	if !rows.Next() {
+		t.Fatalf("sqlite failure: %s returned no rows: %v", query, rows.Err())
+	}
+	if err := rows.Scan(dest...); err != nil {
+		t.Fatal(err)
+	}
+
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		out = append(out, v.String)
+	}
+
+	return strings.Join(out, "|")
+}
+
+func newTestSQLiteDatasource(t *testing.T) *SQLiteDatasource {
+	cfg := &Config{}
+	cfg.SQLite.Path = filepath.Join(t.TempDir(), "inventory.db")
+	cfg.SQLite.Table = "records"
+
+	s, err := NewSQLiteDatasource(cfg, &testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// sqliteRecordStrings formats host records for comparison.
+func sqliteRecordStrings(records []*DatasourceRecord) []string {
+	got := make([]string, 0, len(records))
+	for _, r := range records {
+		got = append(got, r.Hostname+" "+r.Attributes)
+	}
+
+	return got
+}
+
+func TestSQLiteDatasource_GetAllRecords(t *testing.T) {
+	// Enough rows and long enough attributes for a small page size to produce interior pages and overflow chains.
+	var many strings.Builder
+	many.WriteString("PRAGMA page_size=512; CREATE TABLE hosts (id INTEGER PRIMARY KEY, hostname TEXT NOT NULL, attributes TEXT NOT NULL);")
+	for i := 1; i <= 300; i++ {
+		vars := ""
+		if i%50 == 0 {
+			vars = strings.Repeat("x", 3000)
+		}
+		fmt.Fprintf(&many, "INSERT INTO hosts (hostname, attributes) VALUES ('host%03d.infra.local', 'OS=linux;VARS=%s');", i, vars)
+	}
+
+	tests := []struct {
+		name    string
+		table   string
+		sql     string
+		want    []string
+		count   int
+		wantErr bool
+	}{
+		{
+			// Hostnames are normalized, rows without a hostname are skipped and other columns are ignored.
+			name:  "valid",
+			table: "Records",
+			sql: "CREATE TABLE records (\"owner\" TEXT DEFAULT 'ops, infra', hostname TEXT, attributes TEXT, CONSTRAINT host UNIQUE (hostname, attributes));" +
+				"INSERT INTO records VALUES ('ops', 'APP01.infra.local.', 'OS=linux;ENV=dev;ROLE=app;SRV=tomcat');" +
+				"INSERT INTO records VALUES ('ops', 'app01.infra.local', 'OS=linux;ENV=dev;ROLE=web;SRV=nginx');" +
+				"INSERT INTO records VALUES ('dba', '', 'OS=linux;ENV=prod;ROLE=db');" +
+				"INSERT INTO records VALUES (NULL, 'db01.infra.local', 'OS=linux;ENV=prod;ROLE=db;SRV=postgres');",
+			want: []string{
+				"app01.infra.local OS=linux;ENV=dev;ROLE=app;SRV=tomcat",
+				"app01.infra.local OS=linux;ENV=dev;ROLE=web;SRV=nginx",
+				"db01.infra.local OS=linux;ENV=prod;ROLE=db;SRV=postgres",
+			},
+		},
+		{
+			name:  "valid-large",
+			table: "hosts",
+			sql:   many.String(),
+			count: 300,
+		},
+		{
+			// Columns added after rows have been written are read as NULL.
+			name:  "valid-added-column",
+			table: "records",
+			sql:   "CREATE TABLE records (hostname TEXT); INSERT INTO records VALUES ('app01.infra.local'); ALTER TABLE records ADD COLUMN attributes TEXT;",
+			want:  []string{"app01.infra.local "},
+		},
+		{
+			name:    "invalid-table",
+			table:   "records",
+			sql:     "CREATE TABLE hosts (hostname TEXT, attributes TEXT);",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-columns",
+			table:   "records",
+			sql:     "CREATE TABLE records (host TEXT, attributes TEXT);",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-without-rowid",
+			table:   "records",
+			sql:     "CREATE TABLE records (hostname TEXT PRIMARY KEY, attributes TEXT) WITHOUT ROWID;",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSQLiteDatasource(t)
+			s.Config.SQLite.Table = tt.table
+			sqliteExec(t, s.Config.SQLite.Path, tt.sql)
+
+			records, err := s.GetAllRecords()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SQLiteDatasource.GetAllRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if tt.count > 0 {
+				if len(records) != tt.count {
+					t.Fatalf("SQLiteDatasource.GetAllRecords() returned %d records, want %d", len(records), tt.count)
+				}
+
+				for n, r := range records {
+					if want := fmt.Sprintf("host%03d.infra.local", n+1); r.Hostname != want {
+						t.Errorf("SQLiteDatasource.GetAllRecords() hostname = %s, want %s", r.Hostname, want)
+					}
+					if want := fmt.Sprintf("%s:hosts:%d", s.Config.SQLite.Path, n+1); r.Source != want {
+						t.Errorf("SQLiteDatasource.GetAllRecords() source = %s, want %s", r.Source, want)
+					}
+					if (n+1)%50 == 0 && len(r.Attributes) != 3000+len("OS=linux;VARS=") {
+						t.Errorf("SQLiteDatasource.GetAllRecords() attributes length = %d", len(r.Attributes))
+					}
+				}
+
+				return
+			}
+
+			if got := sqliteRecordStrings(records); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SQLiteDatasource.GetAllRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteDatasource_GetAllRecords_file(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		data    []byte
+		wantErr bool
+	}{
+		{
+			name: "valid-empty-table",
+			sql:  "CREATE TABLE records (id INTEGER PRIMARY KEY, hostname TEXT NOT NULL, attributes TEXT NOT NULL);",
+		},
+		{
+			// Reading never creates the database file.
+			name:    "invalid-missing",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-format",
+			data:    []byte("hostname,attributes\napp01.infra.local,OS=linux\n"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSQLiteDatasource(t)
+			path := s.Config.SQLite.Path
+
+			if len(tt.sql) > 0 {
+				sqliteExec(t, path, tt.sql)
+			}
+			if tt.data != nil {
+				if err := os.WriteFile(path, tt.data, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			records, err := s.GetAllRecords()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SQLiteDatasource.GetAllRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(records) != 0 {
+				t.Errorf("SQLiteDatasource.GetAllRecords() = %v, want none", records)
+			}
+
+			if _, err := os.Stat(path); len(tt.sql) == 0 && tt.data == nil && err == nil {
+				t.Errorf("SQLiteDatasource.GetAllRecords() created %s", path)
+			}
+		})
+	}
+}
+
+func TestSQLiteDatasource_PublishRecords(t *testing.T) {
+	existing := []*DatasourceRecord{
+		{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat"},
+		{Hostname: "db01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=db;SRV=postgres"},
+	}
+	records := []*DatasourceRecord{
+		{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=web;SRV=nginx"},
+		{Hostname: "web01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=web;SRV=nginx;VARS=" + strings.Repeat("k=v,", 2000)},
+	}
+
+	tests := []struct {
+		name    string
+		clear   bool
+		missing bool
+		schema  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "valid-clear",
+			clear: true,
+			want:  sqliteRecordStrings(records),
+		},
+		{
+			// Hosts that are not published are kept.
+			name: "valid-merge",
+			want: append([]string{"db01.infra.local OS=linux;ENV=prod;ROLE=db;SRV=postgres"}, sqliteRecordStrings(records)...),
+		},
+		{
+			name:    "valid-merge-missing",
+			missing: true,
+			want:    sqliteRecordStrings(records),
+		},
+		{
+			// Other columns are left to their defaults in published rows and kept in the others.
+			name:   "valid-other-column",
+			schema: "CREATE TABLE records (id INTEGER PRIMARY KEY, hostname TEXT, attributes TEXT, owner TEXT DEFAULT 'ops')",
+			want:   append([]string{"db01.infra.local OS=linux;ENV=prod;ROLE=db;SRV=postgres"}, sqliteRecordStrings(records)...),
+		},
+		{
+			// Nothing is written if any of the records cannot be written.
+			name:    "invalid-constraint",
+			schema:  "CREATE TABLE records (id INTEGER PRIMARY KEY, hostname TEXT, attributes TEXT CHECK (length(attributes) < 1000))",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSQLiteDatasource(t)
+			path := s.Config.SQLite.Path
+
+			if !tt.missing {
+				schema := tt.schema
+				if len(schema) == 0 {
+					schema = "CREATE TABLE records (id INTEGER PRIMARY KEY, hostname TEXT NOT NULL, attributes TEXT NOT NULL)"
+				}
+
+				statements := schema + ";"
+				for _, r := range existing {
+					statements += fmt.Sprintf("INSERT INTO records (hostname, attributes) VALUES ('%s', '%s');", r.Hostname, r.Attributes)
+				}
+				sqliteExec(t, path, statements)
+			}
+
+			s.Config.SQLite.Import.Clear = tt.clear
+
+			err := s.PublishRecords(records)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SQLiteDatasource.PublishRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			all, err := s.GetAllRecords()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := tt.want
+			if tt.wantErr {
+				want = sqliteRecordStrings(existing)
+			}
+			if got := sqliteRecordStrings(all); !reflect.DeepEqual(got, want) {
+				t.Errorf("SQLiteDatasource.PublishRecords() = %v, want %v", got, want)
+			}
+
+			if got := sqliteQuery(t, path, "PRAGMA integrity_check;"); got != "ok" {
+				t.Errorf("integrity check = %s", got)
+			}
+			if got := sqliteQuery(t, path, "SELECT count(*), sum(length(attributes)) FROM records;"); got != fmt.Sprintf("%d|%d", len(want), sqliteAttributesLength(all)) {
+				t.Errorf("count and attributes length = %s", got)
+			}
+			if tt.schema != "" && !tt.wantErr {
+				if got := sqliteQuery(t, path, "SELECT count(*) FROM records WHERE owner = 'ops';"); got != fmt.Sprint(len(want)) {
+					t.Errorf("rows with the default owner = %s, want %d", got, len(want))
+				}
+			}
+		})
+	}
+}
+
+// sqliteAttributesLength returns the total length of the attributes of host records.
+func sqliteAttributesLength(records []*DatasourceRecord) int {
+	n := 0
+	for _, r := range records {
+		n += len(r.Attributes)
+	}
+
+	return n
+}
+
+func TestSQLiteDatasource_PutRecords_concurrent(t *testing.T) {
+	s := newTestSQLiteDatasource(t)
+
+	// Every writer has its own connection to the database file, like separate inventory processes.
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for n := range errs {
+		w, err := NewSQLiteDatasource(s.Config, &testLogger{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer w.Close()
+
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+
+			for i := 0; i < 10; i++ {
+				host := fmt.Sprintf("app%02d.infra.local", n)
+				records := []*DatasourceRecord{{Hostname: host, Attributes: fmt.Sprintf("OS=linux;ROLE=app;VARS=write=%d", i)}}
+				if errs[n] = w.PutRecords(records); errs[n] != nil {
+					return
+				}
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	for n, err := range errs {
+		if err != nil {
+			t.Errorf("SQLiteDatasource.PutRecords() writer %d error = %v", n, err)
+		}
+	}
+
+	records, err := s.GetAllRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(errs) {
+		t.Fatalf("SQLiteDatasource.GetAllRecords() returned %d records, want %d", len(records), len(errs))
+	}
+	for _, r := range records {
+		if !strings.HasSuffix(r.Attributes, "write=9") {
+			t.Errorf("SQLiteDatasource.GetAllRecords() = %s %s, want the last write", r.Hostname, r.Attributes)
+		}
+	}
+}
+
+func TestSQLiteDatasource_RenameHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			from: "app01.infra.local",
+			to:   "app02.infra.local",
+			want: []string{"app02.infra.local OS=linux;ROLE=app", "db01.infra.local OS=linux;ROLE=db", "app02.infra.local OS=linux;ROLE=web"},
+		},
+		{
+			name: "valid-missing",
+			from: "web01.infra.local",
+			to:   "web02.infra.local",
+			want: []string{"app01.infra.local OS=linux;ROLE=app", "db01.infra.local OS=linux;ROLE=db", "app01.infra.local OS=linux;ROLE=web"},
+		},
+		{
+			name:    "invalid-exists",
+			from:    "app01.infra.local",
+			to:      "db01.infra.local",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSQLiteDatasource(t)
+			err := s.PutRecords([]*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ROLE=app"},
+				{Hostname: "db01.infra.local", Attributes: "OS=linux;ROLE=db"},
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ROLE=web"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = s.RenameHost(tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SQLiteDatasource.RenameHost() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			all, err := s.GetAllRecords()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sqliteRecordStrings(all); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SQLiteDatasource.RenameHost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteDatasource_ReplaceHost(t *testing.T) {
+	s := newTestSQLiteDatasource(t)
+	err := s.PutRecords([]*DatasourceRecord{
+		{Hostname: "app01.infra.local", Attributes: "OS=linux;ROLE=app"},
+		{Hostname: "db01.infra.local", Attributes: "OS=linux;ROLE=db"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ReplaceHost("APP01.infra.local", []*DatasourceRecord{{Hostname: "app01.infra.local", Attributes: "OS=linux;ROLE=web"}}); err != nil {
+		t.Fatalf("SQLiteDatasource.ReplaceHost() error = %v", err)
+	}
+
+	records, err := s.GetHostRecords("app01.infra.local.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sqliteRecordStrings(records), []string{"app01.infra.local OS=linux;ROLE=web"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SQLiteDatasource.ReplaceHost() = %v, want %v", got, want)
+	}
+
+	// Replacing a host with no records removes it.
+	if err := s.ReplaceHost("db01.infra.local", nil); err != nil {
+		t.Fatalf("SQLiteDatasource.ReplaceHost() error = %v", err)
+	}
+	if records, err := s.GetHostRecords("db01.infra.local"); err != nil || len(records) != 0 {
+		t.Errorf("SQLiteDatasource.ReplaceHost() left %v, %v", records, err)
+	}
+}
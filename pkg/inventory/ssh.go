@@ -0,0 +1,217 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	// SSH datasource type.
+	SSHDatasourceType string = "ssh"
+)
+
+// sshControl reads zones by running a command printing them in the zone file format (e.g. 'dig axfr') on a remote host over SSH.
+// A single SSH connection is shared by all zones, every zone is read in a separate session.
+type sshControl struct {
+	// Inventory configuration.
+	config *Config
+	// SSH client configuration.
+	client *ssh.ClientConfig
+
+	// Guards the connection.
+	mu sync.Mutex
+	// Current connection, nil until the first zone is read or after a connection failure.
+	conn *ssh.Client
+}
+
+// sshQuote quotes a string for use in a POSIX shell command.
+func sshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// connect returns the current SSH connection, establishing a new one if needed.
+func (s *sshControl) connect() (*ssh.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		return s.conn, nil
+	}
+
+	conn, err := ssh.Dial("tcp", s.config.SSH.Address, s.client)
+	if err != nil {
+		return nil, errors.Wrap(err, "ssh connection failure")
+	}
+	s.conn = conn
+
+	return conn, nil
+}
+
+// reset drops a failed SSH connection so that the next zone establishes a new one.
+func (s *sshControl) reset(conn *ssh.Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == conn {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// readZone runs the configured command for a zone and parses its output.
+func (s *sshControl) readZone(ctx context.Context, zone string) ([]dns.RR, error) {
+	cfg := s.config
+
+	ctx, cancel := context.WithTimeout(ctx, stageTimeout(cfg.SSH.Timeout, cfg.DNS.Timeout))
+	defer cancel()
+
+	conn, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := conn.NewSession()
+	if err != nil {
+		s.reset(conn)
+		return nil, errors.Wrap(err, "ssh session failure")
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	command := strings.ReplaceAll(cfg.SSH.Command, "%s", sshQuote(strings.TrimSuffix(zone, ".")))
+
+	done := make(chan error, 1)
+	go func() { done <- session.Run(command) }()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		session.Close()
+		return nil, errors.Wrap(ctx.Err(), "ssh command failure")
+	}
+
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); len(msg) > 0 {
+			return nil, errors.Wrapf(err, "ssh command failure: %s", msg)
+		}
+		return nil, errors.Wrap(err, "ssh command failure")
+	}
+
+	return parseZone(&stdout, zone, cfg.SSH.Address)
+}
+
+// Close closes the SSH connection.
+func (s *sshControl) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+
+	return err
+}
+
+// newSSHClientConfig creates the SSH client configuration: user, authentication methods and host key verification.
+func newSSHClientConfig(cfg *Config) (*ssh.ClientConfig, error) {
+	c := cfg.SSH
+
+	name := c.User
+	if len(name) == 0 {
+		u, err := user.Current()
+		if err != nil {
+			return nil, errors.Wrap(err, "ssh user lookup failure")
+		}
+		name = u.Username
+	}
+
+	signers := make([]ssh.Signer, 0)
+	for _, file := range c.Keys {
+		key, err := os.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrap(err, "ssh private key loading failure")
+		}
+
+		var signer ssh.Signer
+		if len(c.Passphrase) > 0 {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(c.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s: ssh private key parsing failure", file)
+		}
+
+		signers = append(signers, signer)
+	}
+
+	auth := []ssh.AuthMethod{ssh.PublicKeys(signers...)}
+	if socket := os.Getenv("SSH_AUTH_SOCK"); c.Agent && len(socket) > 0 {
+		// The agent connection is kept open for the lifetime of the datasource, as signing requires it.
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, errors.Wrap(err, "ssh agent connection failure")
+		}
+
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+
+	callback := ssh.InsecureIgnoreHostKey()
+	if !c.Insecure {
+		file := c.KnownHosts
+		if len(file) == 0 {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, errors.Wrap(err, "known hosts file lookup failure")
+			}
+			file = filepath.Join(home, ".ssh", "known_hosts")
+		}
+
+		var err error
+		if callback, err = knownhosts.New(file); err != nil {
+			return nil, errors.Wrap(err, "known hosts file loading failure")
+		}
+	}
+
+	return &ssh.ClientConfig{
+		User:            name,
+		Auth:            auth,
+		HostKeyCallback: callback,
+		Timeout:         stageTimeout(c.Timeout, cfg.DNS.Timeout),
+	}, nil
+}
+
+// NewSSHDatasource creates a DNS datasource that reads zones by running a command on a remote host over SSH.
+func NewSSHDatasource(cfg *Config, log Logger) (*DNSDatasource, error) {
+	client, err := newSSHClientConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "ssh datasource initialization failure")
+	}
+
+	d, err := NewDNSDatasource(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Control = &sshControl{config: cfg, client: client}
+
+	return d, nil
+}
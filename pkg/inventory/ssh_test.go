@@ -0,0 +1,116 @@
+package inventory
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// serveSSH emulates an SSH server printing the output for the "dig axfr 'infra.local'" command.
+// Other commands fail with the 'command not found' error.
+func serveSSH(t *testing.T, output string) string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+
+				for ch := range chans {
+					channel, requests, err := ch.Accept()
+					if err != nil {
+						continue
+					}
+
+					go func() {
+						defer channel.Close()
+
+						for req := range requests {
+							if req.Type != "exec" {
+								req.Reply(false, nil)
+								continue
+							}
+							req.Reply(true, nil)
+
+							var payload struct{ Command string }
+							ssh.Unmarshal(req.Payload, &payload)
+
+							status := uint32(0)
+							if payload.Command == "dig axfr 'infra.local'" {
+								channel.Write([]byte(output))
+							} else {
+								channel.Stderr().Write([]byte("command not found"))
+								status = 127
+							}
+
+							channel.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, status))
+							return
+						}
+					}()
+				}
+			}()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+// newTestSSHControl creates an SSH client for the emulated SSH server.
+func newTestSSHControl(t *testing.T, command string) *sshControl {
+	output := strings.Join([]string{
+		"; <<>> DiG 9.18.24 <<>> axfr infra.local",
+		"infra.local.\t3600\tIN\tSOA\tns1.infra.local. admin.infra.local. 7 3600 600 86400 60",
+		"app01.infra.local.\t3600\tIN\tTXT\t\"OS=linux;ENV=dev;ROLE=app;SRV=tomcat\"",
+		"infra.local.\t3600\tIN\tSOA\tns1.infra.local. admin.infra.local. 7 3600 600 86400 60",
+		";; XFR size: 3 records (messages 1, bytes 145)",
+	}, "\n")
+
+	cfg := &Config{}
+	cfg.SSH.Address = serveSSH(t, output)
+	cfg.SSH.User = "inventory"
+	cfg.SSH.Insecure = true
+	cfg.SSH.Timeout = time.Second
+	cfg.SSH.Command = command
+
+	client, err := newSSHClientConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &sshControl{config: cfg, client: client}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
@@ -0,0 +1,81 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// checkThresholds checks the hosts and the inventory tree built from them against the sanity thresholds.
+// Every exceeded threshold is logged as a warning, or all of them are reported as an error in the strict mode.
+func (i *Inventory) checkThresholds(hosts map[string][]*HostAttributes) error {
+	cfg := i.Config
+	log := i.Logger
+	limits := cfg.Thresholds
+
+	violations := make([]string, 0)
+
+	if limits.Sets > 0 {
+		for host, attrs := range hosts {
+			if len(attrs) > limits.Sets {
+				violations = append(violations, fmt.Sprintf("[%s] %d attribute sets, maximum %d", host, len(attrs), limits.Sets))
+			}
+		}
+	}
+
+	if limits.Groups > 0 || limits.Hosts > 0 {
+		// Groups of every host, directly or through child groups.
+		groups := make(map[string]map[string]bool)
+
+		var walk func(n *Node, ancestors []string)
+		walk = func(n *Node, ancestors []string) {
+			if n != i.Tree {
+				ancestors = append(ancestors, n.Name)
+			}
+
+			if limits.Hosts > 0 && len(n.Hosts) > limits.Hosts {
+				violations = append(violations, fmt.Sprintf("[%s] %d hosts, maximum %d", n.Name, len(n.Hosts), limits.Hosts))
+			}
+
+			for host := range n.Hosts {
+				if groups[host] == nil {
+					groups[host] = make(map[string]bool)
+				}
+				for _, name := range ancestors {
+					groups[host][name] = true
+				}
+			}
+
+			for _, child := range n.Children {
+				walk(child, ancestors[:len(ancestors):len(ancestors)])
+			}
+		}
+		walk(i.Tree, nil)
+
+		if limits.Groups > 0 {
+			for host, names := range groups {
+				if len(names) > limits.Groups {
+					violations = append(violations, fmt.Sprintf("[%s] %d groups, maximum %d", host, len(names), limits.Groups))
+				}
+			}
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	sort.Strings(violations)
+
+	if limits.Strict {
+		return errors.Errorf("%d sanity threshold(s) exceeded: %s", len(violations), strings.Join(violations, "; "))
+	}
+
+	for _, v := range violations {
+		log.Warnf("sanity threshold exceeded: %s", v)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package inventory
+
+import (
+	"testing"
+)
+
+func TestInventory_checkThresholds(t *testing.T) {
+	hosts := map[string][]*HostAttributes{
+		"app01.infra.local": {
+			{OS: "linux", Env: "dev", Role: "app", Srv: "tomcat"},
+			{OS: "linux", Env: "dev", Role: "app", Srv: "nginx"},
+			{OS: "linux", Env: "dev", Role: "app", Srv: "redis"},
+		},
+		"app02.infra.local": {{OS: "linux", Env: "dev", Role: "app", Srv: "tomcat"}},
+	}
+
+	tests := []struct {
+		name   string
+		sets   int
+		groups int
+		hosts  int
+		want   string
+	}{
+		{
+			name: "valid-unchecked",
+		},
+		{
+			name:   "valid",
+			sets:   3,
+			groups: 13,
+			hosts:  2,
+		},
+		{
+			name: "invalid-sets",
+			sets: 2,
+			want: "1 sanity threshold(s) exceeded: [app01.infra.local] 3 attribute sets, maximum 2",
+		},
+		{
+			// app01: dev, dev_app, dev_app_{tomcat,nginx,redis}, dev_host, dev_host_linux and the same under the root, except for the environment.
+			name:   "invalid-groups",
+			groups: 12,
+			want:   "1 sanity threshold(s) exceeded: [app01.infra.local] 13 groups, maximum 12",
+		},
+		{
+			name:  "invalid-hosts",
+			hosts: 1,
+			want:  "4 sanity threshold(s) exceeded: [all_app_tomcat] 2 hosts, maximum 1; [all_host_linux] 2 hosts, maximum 1; [dev_app_tomcat] 2 hosts, maximum 1; [dev_host_linux] 2 hosts, maximum 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.Thresholds.Sets = tt.sets
+			cfg.Thresholds.Groups = tt.groups
+			cfg.Thresholds.Hosts = tt.hosts
+			cfg.Thresholds.Strict = true
+
+			i := &Inventory{Config: cfg, Logger: &testLogger{}, Tree: NewTree()}
+			i.Tree.ImportHosts(hosts, "_", nil)
+
+			err := i.checkThresholds(hosts)
+			if got := ""; err != nil {
+				got = err.Error()
+				if got != tt.want {
+					t.Errorf("Inventory.checkThresholds() error = %v, want %v", got, tt.want)
+				}
+			} else if len(tt.want) > 0 {
+				t.Errorf("Inventory.checkThresholds() error = nil, want %v", tt.want)
+			}
+
+			// Violations are only logged if not in the strict mode.
+			cfg.Thresholds.Strict = false
+			if err := i.checkThresholds(hosts); err != nil {
+				t.Errorf("Inventory.checkThresholds() error = %v, want nil", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	"google.golang.org/grpc"
+)
+
+const (
+	// Placeholder for secrets in the trace file.
+	traceRedacted string = "[redacted]"
+)
+
+// tracer dumps datasource requests and responses to the trace file for troubleshooting.
+// All methods of a nil tracer do nothing, so tracing can be left disabled without checks at call sites.
+type tracer struct {
+	// Guards the trace file.
+	mu sync.Mutex
+	// Trace file.
+	file *os.File
+}
+
+// write appends a single entry to the trace file.
+func (t *tracer) write(kind string, summary string, body string) {
+	if t == nil {
+		return
+	}
+
+	entry := fmt.Sprintf("=== %s %s %s\n", time.Now().Format(time.RFC3339Nano), kind, summary)
+	if len(body) > 0 {
+		entry += strings.TrimSuffix(body, "\n") + "\n"
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.file.WriteString(entry)
+}
+
+// dnsMessage dumps a DNS message sent to or received from a server. TSIG signatures are redacted.
+func (t *tracer) dnsMessage(direction string, server string, msg *dns.Msg, err error) {
+	if t == nil {
+		return
+	}
+
+	if err != nil {
+		t.write("dns", fmt.Sprintf("%s %s: %v", direction, server, err), "")
+		return
+	}
+
+	if msg == nil {
+		return
+	}
+
+	msg = msg.Copy()
+	for n, rr := range msg.Extra {
+		if tsig, ok := rr.(*dns.TSIG); ok {
+			redacted := *tsig
+			redacted.MAC = traceRedacted
+			msg.Extra[n] = &redacted
+		}
+	}
+
+	t.write("dns", fmt.Sprintf("%s %s", direction, server), msg.String())
+}
+
+// dnsRecords dumps the records of a single zone transfer message received from a server.
+func (t *tracer) dnsRecords(server string, zone string, n int, rrs []dns.RR) {
+	if t == nil {
+		return
+	}
+
+	var body strings.Builder
+	for _, rr := range rrs {
+		body.WriteString(rr.String())
+		body.WriteString("\n")
+	}
+
+	t.write("dns", fmt.Sprintf("transfer %s %s: message %d, %d records", server, zone, n, len(rrs)), body.String())
+}
+
+// etcdSummary describes an etcd request or response without record values and secrets.
+func etcdSummary(msg interface{}) string {
+	switch m := msg.(type) {
+	case *pb.RangeRequest:
+		return fmt.Sprintf("range key=%q range_end=%q limit=%d revision=%d serializable=%t keys_only=%t count_only=%t", m.Key, m.RangeEnd, m.Limit, m.Revision, m.Serializable, m.KeysOnly, m.CountOnly)
+	case *pb.RangeResponse:
+		return fmt.Sprintf("revision=%d count=%d kvs=%d more=%t", m.GetHeader().GetRevision(), m.Count, len(m.Kvs), m.More)
+	case *pb.PutRequest:
+		return fmt.Sprintf("put key=%q value=%d bytes", m.Key, len(m.Value))
+	case *pb.PutResponse:
+		return fmt.Sprintf("revision=%d", m.GetHeader().GetRevision())
+	case *pb.DeleteRangeRequest:
+		return fmt.Sprintf("delete key=%q range_end=%q", m.Key, m.RangeEnd)
+	case *pb.DeleteRangeResponse:
+		return fmt.Sprintf("revision=%d deleted=%d", m.GetHeader().GetRevision(), m.Deleted)
+	case *pb.TxnRequest:
+		ops := make([]string, 0, len(m.Success))
+		for _, op := range m.Success {
+			switch {
+			case op.GetRequestRange() != nil:
+				ops = append(ops, etcdSummary(op.GetRequestRange()))
+			case op.GetRequestPut() != nil:
+				ops = append(ops, etcdSummary(op.GetRequestPut()))
+			case op.GetRequestDeleteRange() != nil:
+				ops = append(ops, etcdSummary(op.GetRequestDeleteRange()))
+			}
+		}
+		return fmt.Sprintf("txn compare=%d success=%d failure=%d [%s]", len(m.Compare), len(m.Success), len(m.Failure), strings.Join(ops, "; "))
+	case *pb.TxnResponse:
+		return fmt.Sprintf("revision=%d succeeded=%t", m.GetHeader().GetRevision(), m.Succeeded)
+	case *pb.AuthenticateRequest:
+		return fmt.Sprintf("authenticate name=%q password=%s", m.Name, traceRedacted)
+	case *pb.AuthenticateResponse:
+		return fmt.Sprintf("token=%s", traceRedacted)
+	default:
+		return fmt.Sprintf("%T", msg)
+	}
+}
+
+// etcdInterceptor returns a gRPC interceptor dumping the metadata of etcd requests and responses.
+func (t *tracer) etcdInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		response := etcdSummary(reply)
+		if err != nil {
+			response = err.Error()
+		}
+
+		t.write("etcd", fmt.Sprintf("%s %s (%s)", cc.Target(), method, time.Since(start)), fmt.Sprintf("request: %s\nresponse: %s", etcdSummary(req), response))
+
+		return err
+	}
+}
+
+// Close closes the trace file.
+func (t *tracer) Close() {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.file.Close()
+}
+
+// newTracer opens the trace file if tracing is enabled. It returns nil if tracing is disabled.
+func newTracer(cfg *Config) (*tracer, error) {
+	if !cfg.Log.Trace {
+		return nil, nil
+	}
+
+	file, err := os.OpenFile(cfg.Log.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "trace file opening failure")
+	}
+
+	return &tracer{file: file}, nil
+}
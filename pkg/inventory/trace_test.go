@@ -0,0 +1,91 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+)
+
+func Test_tracer(t *testing.T) {
+	signed := new(dns.Msg)
+	signed.SetAxfr("infra.local.")
+	signed.SetTsig("axfr.", dns.HmacSHA256, 300, time.Now().Unix())
+	signed.Extra[0].(*dns.TSIG).MAC = "deadbeef"
+
+	tests := []struct {
+		name        string
+		trace       func(tr *tracer)
+		want        []string
+		wantMissing []string
+	}{
+		{
+			// TSIG MACs are printed in upper case.
+			name:        "valid-dns-tsig",
+			trace:       func(tr *tracer) { tr.dnsMessage("transfer", "127.0.0.1:53", signed, nil) },
+			want:        []string{"dns transfer 127.0.0.1:53", "infra.local.", strings.ToUpper(traceRedacted)},
+			wantMissing: []string{"DEADBEEF"},
+		},
+		{
+			name: "valid-dns-records",
+			trace: func(tr *tracer) {
+				rr, _ := dns.NewRR(`app01.infra.local. 300 IN TXT "OS=linux;ENV=dev;ROLE=app;SRV=;VARS="`)
+				tr.dnsRecords("127.0.0.1:53", "infra.local.", 1, []dns.RR{rr})
+			},
+			want: []string{"message 1, 1 records", "OS=linux;ENV=dev;ROLE=app;SRV=;VARS="},
+		},
+		{
+			name: "valid-etcd-put",
+			trace: func(tr *tracer) {
+				tr.write("etcd", "Txn", etcdSummary(&pb.TxnRequest{Success: []*pb.RequestOp{{Request: &pb.RequestOp_RequestPut{RequestPut: &pb.PutRequest{Key: []byte("infra.local./app01.infra.local/0"), Value: []byte("VARS=password=s3cr3t")}}}}}))
+			},
+			want:        []string{"put key=\"infra.local./app01.infra.local/0\" value=20 bytes"},
+			wantMissing: []string{"s3cr3t"},
+		},
+		{
+			name: "valid-etcd-auth",
+			trace: func(tr *tracer) {
+				tr.write("etcd", "Authenticate", etcdSummary(&pb.AuthenticateRequest{Name: "root", Password: "s3cr3t"}))
+				tr.write("etcd", "Authenticate", etcdSummary(&pb.AuthenticateResponse{Token: "t0k3n"}))
+			},
+			want:        []string{"name=\"root\" password=" + traceRedacted, "token=" + traceRedacted},
+			wantMissing: []string{"s3cr3t", "t0k3n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.Log.Trace = true
+			cfg.Log.File = filepath.Join(t.TempDir(), "trace")
+
+			tr, err := newTracer(cfg)
+			if err != nil {
+				t.Fatalf("newTracer() error = %v", err)
+			}
+
+			tt.trace(tr)
+			tr.Close()
+
+			data, err := os.ReadFile(cfg.Log.File)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, s := range tt.want {
+				if !strings.Contains(string(data), s) {
+					t.Errorf("tracer output does not contain %q:\n%s", s, data)
+				}
+			}
+
+			for _, s := range tt.wantMissing {
+				if strings.Contains(string(data), s) {
+					t.Errorf("tracer output contains %q:\n%s", s, data)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Default tree transform command execution timeout.
+const transformDefaultTimeout time.Duration = 30 * time.Second
+
+// UnmarshalJSON implements a custom JSON Unmarshaller for tree nodes, accepting the tree export format.
+func (n *Node) UnmarshalJSON(data []byte) error {
+	export := &ExportNode{}
+	if err := json.Unmarshal(data, export); err != nil {
+		return err
+	}
+
+	n.Name = export.Name
+	n.Order = export.Order
+	n.Vars = export.Vars
+	n.Children = export.Children
+	if n.Children == nil {
+		n.Children = make([]*Node, 0)
+	}
+
+	n.Hosts = make(map[string]bool, len(export.Hosts))
+	for _, host := range export.Hosts {
+		n.Hosts[host] = true
+	}
+
+	for _, child := range n.Children {
+		child.Parent = n
+	}
+
+	return nil
+}
+
+// TransformTree registers a function that post-processes the inventory tree, e.g. to add groups or prune subtrees.
+// Transforms are executed by ImportHosts in the order they have been registered, after the configured transform commands.
+func (i *Inventory) TransformTree(fn func(*Node) error) {
+	i.Transforms = append(i.Transforms, fn)
+}
+
+// runTransform executes a single tree transform command, passing the tree via stdin and replacing it with the tree printed to stdout.
+func (i *Inventory) runTransform(spec TransformSpec) error {
+	if len(spec.Command) == 0 {
+		return errors.New("empty transform command")
+	}
+
+	timeout := spec.Timeout
+	if timeout == 0 {
+		timeout = transformDefaultTimeout
+	}
+
+	payload, err := json.Marshal(i.Tree)
+	if err != nil {
+		return errors.Wrap(err, "tree marshalling failure")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, spec.Command[0], spec.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "%s: transform failure: %s", strings.Join(spec.Command, " "), strings.TrimSpace(stderr.String()))
+	}
+
+	tree := &Node{}
+	if err := json.Unmarshal(stdout.Bytes(), tree); err != nil {
+		return errors.Wrapf(err, "%s: transform output parsing failure", strings.Join(spec.Command, " "))
+	}
+
+	if tree.Name != ansibleRootGroup {
+		return errors.Errorf("%s: transform output root group must be '%s', got '%s'", strings.Join(spec.Command, " "), ansibleRootGroup, tree.Name)
+	}
+
+	tree.Parent = &Node{}
+	i.Tree = tree
+
+	return nil
+}
+
+// transformTree executes the configured transform commands and the registered transforms.
+func (i *Inventory) transformTree() error {
+	cfg := i.Config
+
+	for _, spec := range cfg.Transforms {
+		if err := i.runTransform(spec); err != nil {
+			return err
+		}
+	}
+
+	for _, fn := range i.Transforms {
+		if err := fn(i.Tree); err != nil {
+			return errors.Wrap(err, "tree transform failure")
+		}
+	}
+
+	return nil
+}
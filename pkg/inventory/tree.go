@@ -18,11 +18,12 @@ func (n *Node) MarshalJSON() ([]byte, error) {
 	for host := range n.Hosts {
 		hosts = append(hosts, host)
 	}
-	sort.Strings(hosts)
+	n.sortNames(hosts)
 
 	return json.Marshal(&ExportNode{
 		Name:     n.Name,
-		Children: n.Children,
+		Order:    n.Order,
+		Children: n.sortedChildren(),
 		Hosts:    hosts,
 		Vars:     n.Vars,
 	})
@@ -35,18 +36,42 @@ func (n *Node) MarshalYAML() (interface{}, error) {
 	for host := range n.Hosts {
 		hosts = append(hosts, host)
 	}
-	sort.Strings(hosts)
+	n.sortNames(hosts)
 
 	return &ExportNode{
 		Name:     n.Name,
-		Children: n.Children,
+		Order:    n.Order,
+		Children: n.sortedChildren(),
 		Hosts:    hosts,
 		Vars:     n.Vars,
 	}, nil
 }
 
+// sortedChildren returns the children of this node in canonical order: by position among siblings (see OrderChildren), then by name.
+// The tree itself is left as is, so the output does not depend on how the tree has been built.
+func (n *Node) sortedChildren() []*Node {
+	children := make([]*Node, len(n.Children))
+	copy(children, n.Children)
+
+	sort.SliceStable(children, func(i, j int) bool {
+		if children[i].Order != children[j].Order {
+			return children[i].Order < children[j].Order
+		}
+
+		return n.compare(children[i].Name, children[j].Name) < 0
+	})
+
+	return children
+}
+
 // ImportHosts loads a map of hosts and their attributes into the inventory tree, using this node as root.
-func (n *Node) ImportHosts(hosts map[string][]*HostAttributes, sep string) {
+// Groups are nested along the axes (attribute names, see groupAxes), starting with the environment: root>environment>role>service by default.
+// Empty attribute values skip their level, service identifiers are split into nested groups by the separator.
+func (n *Node) ImportHosts(hosts map[string][]*HostAttributes, sep string, axes []string) {
+	if len(axes) == 0 {
+		axes = defaultGroupAxes
+	}
+
 	for host, attrs := range hosts {
 		for _, attr := range attrs {
 			// Create an environment list for this host. Add the root environment, if necessary.
@@ -59,30 +84,40 @@ func (n *Node) ImportHosts(hosts map[string][]*HostAttributes, sep string) {
 				// Environment: root>environment
 				envNode := n.AddChild(env)
 
-				// Role: root>environment>role
-				groupName := env + sep + attr.Role
-				groupNode := envNode.AddChild(groupName)
+				// Other axes: root>environment>axis[1]>...>axis[N].
+				groupName := env
+				groupNode := envNode
+				for _, axis := range axes[1:] {
+					values := []string{attr.axisValue(axis)}
+					if axis == "SRV" {
+						values = strings.Split(values[0], sep)
+					}
 
-				// Service: root>environment>role>service[1]>...>service[N].
-				for _, srv := range strings.Split(attr.Srv, sep) {
-					if len(srv) > 0 {
-						groupName = groupName + sep + srv
-						groupNode = groupNode.AddChild(groupName)
+					for _, value := range values {
+						if len(value) > 0 {
+							groupName = groupName + sep + value
+							groupNode = groupNode.AddChild(groupName)
+						}
 					}
 				}
 
-				// The last service group holds the host.
+				// The last group holds the host.
 				groupNode.AddHost(host)
 
 				if env != ansibleRootGroup {
 					// Add host attributes to the inventory_attributes group variable.
+					attributes := map[string]string{
+						adiHostAttributeNames["OS"]:   attr.OS,
+						adiHostAttributeNames["ENV"]:  attr.Env,
+						adiHostAttributeNames["ROLE"]: attr.Role,
+						adiHostAttributeNames["SRV"]:  attr.Srv,
+					}
+					for key, value := range attr.Extra {
+						attributes[key] = value
+					}
+
 					groupNode.Vars = map[string]interface{}{
-						"inventory_attributes": map[string]string{
-							adiHostAttributeNames["OS"]:   attr.OS,
-							adiHostAttributeNames["ENV"]:  attr.Env,
-							adiHostAttributeNames["ROLE"]: attr.Role,
-							adiHostAttributeNames["SRV"]:  attr.Srv,
-						},
+						"inventory_attributes": attributes,
 					}
 				}
 
@@ -145,21 +180,46 @@ func (n *Node) AddChild(name string) *Node {
 		}
 	}
 
-	child := &Node{Name: name, Parent: n, Hosts: make(map[string]bool)}
+	child := &Node{Name: name, Parent: n, Hosts: make(map[string]bool), Compare: n.Compare}
 	n.Children = append(n.Children, child)
 
 	return child
 }
 
+// FindChild returns the descendant node with a specific name, starting from this node, or nil if there is none.
+func (n *Node) FindChild(name string) *Node {
+	if n.Name == name {
+		return n
+	}
+
+	for _, child := range n.Children {
+		if found := child.FindChild(name); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// RemoveChild removes a direct child with a specific name from this node, along with its subtree.
+func (n *Node) RemoveChild(name string) {
+	for idx, c := range n.Children {
+		if c.Name == name {
+			n.Children = append(n.Children[:idx], n.Children[idx+1:]...)
+			return
+		}
+	}
+}
+
 // AddHost adds a host to this node.
 func (n *Node) AddHost(host string) {
 	n.Hosts[host] = true
 }
 
-// SortChildren sorts children by name recursively, starting from this node.
+// SortChildren sorts children by name using the collation of this node recursively, starting from this node.
 func (n *Node) SortChildren() {
 	if len(n.Children) > 0 {
-		sort.Slice(n.Children, func(i, j int) bool { return n.Children[i].Name < n.Children[j].Name })
+		sort.Slice(n.Children, func(i, j int) bool { return n.compare(n.Children[i].Name, n.Children[j].Name) < 0 })
 
 		for _, child := range n.Children {
 			child.SortChildren()
@@ -167,11 +227,44 @@ func (n *Node) SortChildren() {
 	}
 }
 
+// OrderChildren moves the groups listed in priority to the front of their siblings, in the listed order, recursively, starting from this node.
+// Other groups keep their relative order. The resulting position of every group among its siblings is stored in its Order field.
+func (n *Node) OrderChildren(priority []string) {
+	rank := make(map[string]int, len(priority))
+	for i, name := range priority {
+		if _, ok := rank[name]; !ok {
+			rank[name] = i
+		}
+	}
+
+	n.orderChildren(rank)
+}
+
+// orderChildren implements OrderChildren using a map of group names to their priority.
+func (n *Node) orderChildren(rank map[string]int) {
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		ri, iok := rank[n.Children[i].Name]
+		rj, jok := rank[n.Children[j].Name]
+
+		switch {
+		case iok && jok:
+			return ri < rj
+		default:
+			return iok && !jok
+		}
+	})
+
+	for i, child := range n.Children {
+		child.Order = i
+		child.orderChildren(rank)
+	}
+}
+
 // ExportInventory exports the inventory tree into a map ready to be marshalled into a JSON representation of an Ansible inventory, starting from this node.
 func (n *Node) ExportInventory(inventory map[string]*AnsibleGroup) {
-	// Collect node children.
+	// Collect node children in canonical order.
 	children := make([]string, 0, len(n.Children))
-	for _, child := range n.Children {
+	for _, child := range n.sortedChildren() {
 		children = append(children, child.Name)
 	}
 
@@ -180,7 +273,7 @@ func (n *Node) ExportInventory(inventory map[string]*AnsibleGroup) {
 	for host := range n.Hosts {
 		hosts = append(hosts, host)
 	}
-	sort.Strings(hosts)
+	n.sortNames(hosts)
 
 	// Put this node into the map.
 	inventory[n.Name] = &AnsibleGroup{Children: children, Hosts: hosts, Vars: n.Vars}
@@ -219,7 +312,7 @@ func (n *Node) ExportHosts(hosts map[string][]string) {
 		for name := range collected {
 			result = append(result, name)
 		}
-		sort.Strings(result)
+		n.sortNames(result)
 
 		// Add host to map.
 		hosts[host] = result
@@ -241,7 +334,7 @@ func (n *Node) ExportGroups(groups map[string][]string) {
 	for host := range n.GetAllHosts() {
 		hosts = append(hosts, host)
 	}
-	sort.Strings(hosts)
+	n.sortNames(hosts)
 
 	// Add group to map
 	groups[n.Name] = hosts
@@ -254,6 +347,29 @@ func (n *Node) ExportGroups(groups map[string][]string) {
 	}
 }
 
+// ExportValues exports the inventory tree into a nested map of groups, their children and hosts, starting from this node.
+// The result is suitable for use as a Helm values file.
+func (n *Node) ExportValues(values map[string]interface{}) {
+	// Collect node children.
+	children := make(map[string]interface{}, len(n.Children))
+	for _, child := range n.Children {
+		child.ExportValues(children)
+	}
+
+	// Collect node hosts.
+	hosts := make([]string, 0, len(n.Hosts))
+	for host := range n.Hosts {
+		hosts = append(hosts, host)
+	}
+	n.sortNames(hosts)
+
+	// Put this node into the map.
+	values[n.Name] = map[string]interface{}{
+		"children": children,
+		"hosts":    hosts,
+	}
+}
+
 // NewTree initializes an empty inventory tree
 func NewTree() *Node {
 	return &Node{Name: ansibleRootGroup, Parent: &Node{}, Children: make([]*Node, 0), Hosts: make(map[string]bool)}
@@ -0,0 +1,381 @@
+package inventory
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestNode_ExportValues(t *testing.T) {
+	tree := NewTree()
+	tree.AddChild("dev").AddChild("dev_app").AddHost("app01.infra.local")
+	tree.AddChild("dev").AddChild("dev_app").AddHost("app02.infra.local")
+	tree.AddChild("dev").AddChild("dev_db")
+
+	want := map[string]interface{}{
+		"all": map[string]interface{}{
+			"children": map[string]interface{}{
+				"dev": map[string]interface{}{
+					"children": map[string]interface{}{
+						"dev_app": map[string]interface{}{
+							"children": map[string]interface{}{},
+							"hosts":    []string{"app01.infra.local", "app02.infra.local"},
+						},
+						"dev_db": map[string]interface{}{
+							"children": map[string]interface{}{},
+							"hosts":    []string{},
+						},
+					},
+					"hosts": []string{},
+				},
+			},
+			"hosts": []string{},
+		},
+	}
+
+	got := make(map[string]interface{})
+	tree.ExportValues(got)
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Node.ExportValues() = %v, want %v", got, want)
+	}
+}
+
+func TestNode_OrderChildren(t *testing.T) {
+	tree := NewTree()
+	for _, name := range []string{"dev", "lab", "prod", "all_host", "stage"} {
+		tree.AddChild(name)
+	}
+	tree.SortChildren()
+
+	tree.OrderChildren([]string{"prod", "stage", "missing"})
+
+	want := []string{"prod", "stage", "all_host", "dev", "lab"}
+
+	got := make([]string, 0, len(tree.Children))
+	for i, child := range tree.Children {
+		got = append(got, child.Name)
+
+		if child.Order != i {
+			t.Errorf("Node.OrderChildren() order of %s = %d, want %d", child.Name, child.Order, i)
+		}
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Node.OrderChildren() = %v, want %v", got, want)
+	}
+}
+
+func TestInventory_importGroupVars(t *testing.T) {
+	tree := NewTree()
+	tree.AddChild("dev").AddChild("dev_app").AddHost("app01.infra.local")
+	tree.AddChild("dev").AddChild("dev_db")
+	tree.FindChild("dev_app").Vars = map[string]interface{}{"inventory_attributes": map[string]string{"OS": "linux"}}
+
+	i := &Inventory{
+		Logger: &testLogger{},
+		Tree:   tree,
+		GroupVars: map[string]map[string]interface{}{
+			"all":     {"ntp_server": "ntp.infra.local"},
+			"dev_app": {"java_heap": "2g", "ports": []interface{}{8080.0, 8443.0}},
+			"missing": {"unused": true},
+		},
+	}
+
+	i.importGroupVars()
+
+	want := map[string]map[string]interface{}{
+		"all":     {"ntp_server": "ntp.infra.local"},
+		"dev":     nil,
+		"dev_app": {"inventory_attributes": map[string]string{"OS": "linux"}, "java_heap": "2g", "ports": []interface{}{8080.0, 8443.0}},
+		"dev_db":  nil,
+	}
+
+	for name, vars := range want {
+		if got := tree.FindChild(name).Vars; !reflect.DeepEqual(got, vars) {
+			t.Errorf("Inventory.importGroupVars() vars of %s = %v, want %v", name, got, vars)
+		}
+	}
+}
+
+func TestInventory_transformTree(t *testing.T) {
+	tests := []struct {
+		name       string
+		commands   []TransformSpec
+		transforms []func(*Node) error
+		want       map[string][]string
+		wantErr    bool
+	}{
+		{
+			name: "valid-prune",
+			transforms: []func(*Node) error{
+				func(tree *Node) error {
+					tree.FindChild("dev").RemoveChild("dev_db")
+					return nil
+				},
+			},
+			want: map[string][]string{"all": {"dev"}, "dev": {"dev_app"}},
+		},
+		{
+			name: "valid-graft",
+			transforms: []func(*Node) error{
+				func(tree *Node) error {
+					tree.AddChild("canary").AddHost("app01.infra.local")
+					return nil
+				},
+			},
+			want: map[string][]string{"all": {"canary", "dev"}, "dev": {"dev_app", "dev_db"}},
+		},
+		{
+			name:     "valid-command",
+			commands: []TransformSpec{{Command: []string{"cat"}}},
+			transforms: []func(*Node) error{
+				func(tree *Node) error {
+					if !tree.FindChild("dev_app").Hosts["app01.infra.local"] || tree.FindChild("dev_app").Parent.Name != "dev" {
+						return errors.New("tree not restored")
+					}
+					return nil
+				},
+			},
+			want: map[string][]string{"all": {"dev"}, "dev": {"dev_app", "dev_db"}},
+		},
+		{
+			name:     "invalid-command",
+			commands: []TransformSpec{{Command: []string{"false"}}},
+			wantErr:  true,
+		},
+		{
+			name: "invalid-transform",
+			transforms: []func(*Node) error{
+				func(tree *Node) error {
+					return errors.New("failure")
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := NewTree()
+			tree.AddChild("dev").AddChild("dev_app").AddHost("app01.infra.local")
+			tree.AddChild("dev").AddChild("dev_db")
+
+			cfg := &Config{}
+			cfg.Transforms = tt.commands
+
+			i := &Inventory{Config: cfg, Logger: &testLogger{}, Tree: tree}
+			for _, fn := range tt.transforms {
+				i.TransformTree(fn)
+			}
+
+			err := i.transformTree()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Inventory.transformTree() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			i.Tree.SortChildren()
+
+			for name, children := range tt.want {
+				node := i.Tree.FindChild(name)
+				if node == nil {
+					t.Errorf("Inventory.transformTree() group %s not found", name)
+					continue
+				}
+
+				got := make([]string, 0, len(node.Children))
+				for _, child := range node.Children {
+					got = append(got, child.Name)
+				}
+
+				if !reflect.DeepEqual(got, children) {
+					t.Errorf("Inventory.transformTree() children of %s = %v, want %v", name, got, children)
+				}
+			}
+		})
+	}
+}
+
+func TestNode_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		priority []string
+		want     string
+		children []string
+	}{
+		{
+			name:     "unordered",
+			children: []string{"dev", "prod"},
+			want:     `{"name":"all","order":0,"children":[{"name":"dev","order":0,"children":[],"hosts":["app01.infra.local","app02.infra.local"],"vars":{"ntp":"ntp.infra.local"}},{"name":"prod","order":0,"children":[],"hosts":["app03.infra.local"]}],"hosts":[]}`,
+		},
+		{
+			name:     "ordered",
+			priority: []string{"prod"},
+			children: []string{"prod", "dev"},
+			want:     `{"name":"all","order":0,"children":[{"name":"prod","order":0,"children":[],"hosts":["app03.infra.local"]},{"name":"dev","order":1,"children":[],"hosts":["app01.infra.local","app02.infra.local"],"vars":{"ntp":"ntp.infra.local"}}],"hosts":[]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := NewTree()
+			tree.AddChild("prod").AddHost("app03.infra.local")
+			tree.AddChild("dev").AddHost("app02.infra.local")
+			tree.AddChild("dev").AddHost("app01.infra.local")
+			tree.FindChild("dev").Vars = map[string]interface{}{"ntp": "ntp.infra.local"}
+
+			if tt.priority != nil {
+				tree.OrderChildren(tt.priority)
+			}
+
+			got, err := json.Marshal(tree)
+			if err != nil {
+				t.Errorf("Node.MarshalJSON() error = %v", err)
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("Node.MarshalJSON() = %s, want %s", got, tt.want)
+			}
+
+			inventory := make(map[string]*AnsibleGroup)
+			tree.ExportInventory(inventory)
+			if !reflect.DeepEqual(inventory["all"].Children, tt.children) {
+				t.Errorf("Node.ExportInventory() children = %v, want %v", inventory["all"].Children, tt.children)
+			}
+		})
+	}
+}
+
+func TestNode_ImportHosts(t *testing.T) {
+	hosts := map[string][]*HostAttributes{
+		"app01.infra.local": {{OS: "linux", Env: "dev", Role: "app", Srv: "tomcat_public", Extra: map[string]string{"DC": "msk"}}},
+		"app02.infra.local": {{OS: "linux", Env: "dev", Role: "app", Srv: ""}},
+	}
+
+	tests := []struct {
+		name string
+		axes []string
+		want map[string]string
+	}{
+		{
+			// Groups are named and nested after the environment, role and service by default.
+			name: "valid-default",
+			want: map[string]string{
+				"dev":                   "all",
+				"dev_app":               "dev",
+				"dev_app_tomcat":        "dev_app",
+				"dev_app_tomcat_public": "dev_app_tomcat",
+				"dev_host":              "dev",
+				"dev_host_linux":        "dev_host",
+				"all_app":               "all",
+				"all_app_tomcat":        "all_app",
+				"all_app_tomcat_public": "all_app_tomcat",
+				"all_host":              "all",
+				"all_host_linux":        "all_host",
+			},
+		},
+		{
+			// Hosts without an extra attribute skip its level.
+			name: "valid-datacenter",
+			axes: []string{"ENV", "DC", "ROLE", "SRV"},
+			want: map[string]string{
+				"dev":                       "all",
+				"dev_msk":                   "dev",
+				"dev_msk_app":               "dev_msk",
+				"dev_msk_app_tomcat":        "dev_msk_app",
+				"dev_msk_app_tomcat_public": "dev_msk_app_tomcat",
+				"dev_app":                   "dev",
+				"dev_host":                  "dev",
+				"dev_host_linux":            "dev_host",
+				"all_msk":                   "all",
+				"all_msk_app":               "all_msk",
+				"all_msk_app_tomcat":        "all_msk_app",
+				"all_msk_app_tomcat_public": "all_msk_app_tomcat",
+				"all_app":                   "all",
+				"all_host":                  "all",
+				"all_host_linux":            "all_host",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := NewTree()
+			tree.ImportHosts(hosts, "_", tt.axes)
+
+			got := make(map[string]string)
+			var walk func(n *Node)
+			walk = func(n *Node) {
+				for _, child := range n.Children {
+					got[child.Name] = n.Name
+					walk(child)
+				}
+			}
+			walk(tree)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Node.ImportHosts() groups = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInventory_groupAxes(t *testing.T) {
+	tests := []struct {
+		name    string
+		axes    []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "valid-default",
+			want: []string{"ENV", "ROLE", "SRV"},
+		},
+		{
+			name: "valid-extra",
+			axes: []string{"ENVIRONMENT", "DC", "ROLE", "SRV"},
+			want: []string{"ENV", "DC", "ROLE", "SRV"},
+		},
+		{
+			name:    "invalid-first",
+			axes:    []string{"ROLE", "ENVIRONMENT"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid-duplicate",
+			axes:    []string{"ENVIRONMENT", "ROLE", "ROLE"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid-vars",
+			axes:    []string{"ENVIRONMENT", "VARS"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.Txt.Keys.Os = "OS"
+			cfg.Txt.Keys.Env = "ENVIRONMENT"
+			cfg.Txt.Keys.Role = "ROLE"
+			cfg.Txt.Keys.Srv = "SRV"
+			cfg.Txt.Keys.Vars = "VARS"
+			cfg.Txt.Keys.ID = "ID"
+			cfg.Txt.Keys.Extra = []string{"DC"}
+			cfg.Axes = tt.axes
+
+			i := &Inventory{Config: cfg}
+
+			got, err := i.groupAxes()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Inventory.groupAxes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inventory.groupAxes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package inventory
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+
+	"github.com/miekg/dns"
+)
+
+// tsigVerifier implements an HMAC or GSS-TSIG provider that keeps track of the messages it has verified.
+// In lenient mode, messages with invalid signatures are counted instead of aborting the zone transfer.
+type tsigVerifier struct {
+	// Base64-encoded TSIG secret.
+	secret string
+	// GSS-TSIG security context, nil if an HMAC algorithm is used.
+	gss *gssContext
+	// Abort zone transfers on invalid signatures.
+	strict bool
+	// Number of messages carrying a valid signature.
+	signed int
+	// Number of messages carrying an invalid signature.
+	failed int
+}
+
+// Generate computes the HMAC or the GSS-API MIC token of a message.
+func (v *tsigVerifier) Generate(msg []byte, t *dns.TSIG) ([]byte, error) {
+	if v.gss != nil {
+		if dns.CanonicalName(t.Algorithm) != gssAlgorithm {
+			return nil, dns.ErrKeyAlg
+		}
+
+		return v.gss.getMIC(msg)
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(v.secret)
+	if err != nil {
+		return nil, err
+	}
+
+	var h func() hash.Hash
+	switch dns.CanonicalName(t.Algorithm) {
+	case dns.HmacSHA1:
+		h = sha1.New
+	case dns.HmacSHA224:
+		h = sha256.New224
+	case dns.HmacSHA256:
+		h = sha256.New
+	case dns.HmacSHA384:
+		h = sha512.New384
+	case dns.HmacSHA512:
+		h = sha512.New
+	default:
+		return nil, dns.ErrKeyAlg
+	}
+
+	mac := hmac.New(h, secret)
+	mac.Write(msg)
+
+	return mac.Sum(nil), nil
+}
+
+// Verify checks the HMAC or the GSS-API MIC token of a message.
+func (v *tsigVerifier) Verify(msg []byte, t *dns.TSIG) error {
+	var valid bool
+	if v.gss != nil {
+		if dns.CanonicalName(t.Algorithm) != gssAlgorithm {
+			return dns.ErrKeyAlg
+		}
+
+		mac, err := hex.DecodeString(t.MAC)
+		valid = err == nil && v.gss.verifyMIC(msg, mac) == nil
+	} else {
+		sum, err := v.Generate(msg, t)
+		if err != nil {
+			return err
+		}
+
+		mac, err := hex.DecodeString(t.MAC)
+		valid = err == nil && hmac.Equal(sum, mac)
+	}
+
+	if !valid {
+		v.failed++
+
+		if v.strict {
+			return dns.ErrSig
+		}
+
+		return nil
+	}
+
+	v.signed++
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package inventory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func Test_tsigVerifier(t *testing.T) {
+	secret := "c2VjcmV0Cg=="
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("infra.local.", dns.TypeAXFR)
+	msg.SetTsig("axfr.", dns.HmacSHA256, 300, time.Now().Unix())
+
+	signed, _, err := dns.TsigGenerateWithProvider(msg, &tsigVerifier{secret: secret}, "", false)
+	if err != nil {
+		t.Fatalf("dns.TsigGenerateWithProvider() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		secret     string
+		strict     bool
+		wantErr    bool
+		wantSigned int
+		wantFailed int
+	}{
+		{
+			name:       "valid",
+			secret:     secret,
+			strict:     true,
+			wantErr:    false,
+			wantSigned: 1,
+			wantFailed: 0,
+		},
+		{
+			name:       "invalid-strict",
+			secret:     "b3RoZXIK",
+			strict:     true,
+			wantErr:    true,
+			wantSigned: 0,
+			wantFailed: 1,
+		},
+		{
+			name:       "invalid-lenient",
+			secret:     "b3RoZXIK",
+			strict:     false,
+			wantErr:    false,
+			wantSigned: 0,
+			wantFailed: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &tsigVerifier{secret: tt.secret, strict: tt.strict}
+
+			// Verification strips the TSIG record from the message in place.
+			err := dns.TsigVerifyWithProvider(append([]byte(nil), signed...), v, "", false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("tsigVerifier.Verify() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if v.signed != tt.wantSigned || v.failed != tt.wantFailed {
+				t.Errorf("tsigVerifier.Verify() signed = %d, failed = %d, want %d, %d", v.signed, v.failed, tt.wantSigned, tt.wantFailed)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package inventory
 
 import (
+	"sync"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -17,32 +18,131 @@ type (
 		Validator *validator.Validate
 		// Inventory datasource.
 		Datasource Datasource
+		// Secondary host variable sources.
+		Varsources []Varsource
+		// Runtime host record filters, evaluated in addition to the configured filters when acquiring hosts.
+		Filters []HostFilter
+		// Virtual groups and their membership filters, parsed from the configuration.
+		VirtualGroups map[string][]HostFilter
+		// Metadata of the last host record acquisition.
+		Metadata *InventoryMetadata
+		// Group variables acquired from the datasource, keyed by group name.
+		GroupVars map[string]map[string]interface{}
+		// Notification sinks.
+		Notifiers []*Notifier
+		// Audit log, if enabled.
+		Audit *AuditLog
+		// Functions post-processing the inventory tree, registered with TransformTree.
+		Transforms []func(*Node) error
+		// Host and group name comparison function used for sorting, built from the sorting configuration.
+		Collation func(a, b string) int
 		// Inventory tree.
 		Tree *Node
+
+		// Hosts seen by the last TrackChanges call and their environments.
+		known map[string]string
+		// Guards the hosts seen by TrackChanges.
+		knownLock sync.Mutex
+		// Fallback datasources created so far, keyed by their datasource types.
+		fallbacks map[string]Datasource
+		// Guards the fallback datasources.
+		fallbackLock sync.Mutex
 	}
 
 	// Config represents the main inventory configuration.
 	Config struct {
-		// Datasource type.
-		// Currently supported: dns, etcd.
+		// Datasource type, or a comma-separated list of datasource types whose host records are aggregated (e.g. 'dns,etcd,csv').
+		// Currently supported: cloud, cloudflare, consul, csv, dns, etcd, exec, freeipa, http, knot, kubernetes, ldap, nomad, nsd, phpipam, powerdns, puppetdb, route53, s3, servicenow, ssh, vault.
 		Datasource string `mapstructure:"datasource" default:"dns"`
+		// Composite datasource configuration, used if several datasource types are listed.
+		Composite struct {
+			// Conflict resolution for hosts found in several datasources:
+			// 'merge' (records of all datasources), 'first' or 'last' (records of the first or last datasource in the list having the host),
+			// or a datasource type whose records take precedence.
+			Conflicts string `mapstructure:"conflicts" default:"merge"`
+			// Per-host conflict resolution rules. The first rule whose host pattern matches the hostname applies.
+			Rules []CompositeRuleSpec `mapstructure:"rules"`
+		} `mapstructure:"composite"`
+		// Fallback datasource configuration, used if the datasource fails to provide host records.
+		Fallback struct {
+			// Fallback datasource types, tried in order until one of them provides host records. Each entry may be a comma-separated list of datasource types.
+			Datasources []string `mapstructure:"datasources"`
+			// Use the host record cache ('host.cache.path') of any age if every fallback datasource fails.
+			Cache bool `mapstructure:"cache" default:"false"`
+			// Maximum number of zones a DNS, etcd or Vault datasource may skip before it fails. It only fails if no zone could be read if set to 0.
+			MaxFailedZones int `mapstructure:"maxfailedzones" default:"0"`
+		} `mapstructure:"fallback"`
+		// Troubleshooting log configuration.
+		Log struct {
+			// Dump DNS messages and etcd request and response metadata to the trace file. Secrets are redacted.
+			Trace bool `mapstructure:"trace" default:"false"`
+			// Trace file path. Traces are appended to the file.
+			File string `mapstructure:"file" default:"dns-inventory.trace"`
+		} `mapstructure:"log"`
 		// DNS datasource configuration.
 		DNS struct {
 			// DNS server address.
 			Server string `mapstructure:"server" default:"127.0.0.1:53"`
-			// Network timeout for DNS requests.
+			// Network timeout for DNS requests, used for every stage of a request unless overridden in 'timeouts'.
 			Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+			// Per-stage network timeouts for DNS requests and zone transfers.
+			Timeouts Timeouts `mapstructure:"timeouts"`
+			// EDNS0 UDP buffer size advertised in DNS queries. EDNS0 is not used if set to 0.
+			UDPSize uint16 `mapstructure:"udpsize" default:"0"`
 			// DNS zone list.
 			Zones []string `mapstructure:"zones" default:"[\"server.local.\"]"`
+			// Number of persistent TCP connections reused for DNS queries. A new UDP exchange is used for every query if set to 0.
+			Pool int `mapstructure:"pool" default:"0"`
+			// Number of zones read concurrently.
+			Workers int `mapstructure:"workers" default:"4"`
+			// Time limit for reading a single zone. Only 'timeout' applies to individual requests if set to 0.
+			ZoneTimeout time.Duration `mapstructure:"zonetimeout" default:"0s"`
+			// Time limit for reading all zones. Zones that have not been read in time are skipped. No limit is applied if set to 0.
+			Deadline time.Duration `mapstructure:"deadline" default:"0s"`
+			// Deterministic sharding of zones between several inventory instances: every instance reads only the zones assigned to its shard.
+			// Zones are assigned by a hash of the zone name, so all instances must use the same shard count.
+			Shard struct {
+				// Total number of shards. Sharding is disabled if set to 0 or 1.
+				Count int `mapstructure:"count" default:"0"`
+				// Shard of this instance, from 0 to 'count' - 1.
+				Index int `mapstructure:"index" default:"0"`
+			} `mapstructure:"shard"`
+			// RFC 9432 catalog zones listing additional DNS zones.
+			Catalogs []string `mapstructure:"catalogs"`
+			// Zone discovery configuration.
+			Discovery struct {
+				// Add the zones of the resolver search domains.
+				Search bool `mapstructure:"search" default:"false"`
+				// Resolver configuration file listing the search domains.
+				Resolvconf string `mapstructure:"resolvconf" default:"/etc/resolv.conf"`
+				// Parent domains: each parent zone is transferred and the zones delegated from it are added along with the parent zone itself.
+				Parents []string `mapstructure:"parents"`
+			} `mapstructure:"discovery"`
 			// No-transfer mode configuration.
 			Notransfer struct {
 				// Enable no-transfer data retrieval mode.
 				Enabled bool `mapstructure:"enabled" default:"false"`
 				// A host whose TXT records contain inventory data.
 				Host string `mapstructure:"host" default:"ansible-dns-inventory"`
+				// Per-zone overrides of 'host'. Records of all hosts listed for a zone are merged.
+				Zones []NotransferSpec `mapstructure:"zones"`
 				// Separator between a hostname and an attribute string in a TXT record.
 				Separator string `mapstructure:"separator" default:":"`
+				// Caching of the records of a zone for per-host lookups, keyed by zone and SOA serial.
+				Cache struct {
+					// Cache the records of a zone in the process. A SOA query is made before every lookup to validate the cache.
+					Enabled bool `mapstructure:"enabled" default:"false"`
+					// Directory of the on-disk cache shared between runs, one file per zone. The cache is kept in the process only if empty.
+					Dir string `mapstructure:"dir" default:""`
+				} `mapstructure:"cache"`
 			} `mapstructure:"notransfer"`
+			// BIND zone files used instead of zone transfers and the no-transfer mode.
+			Zonefile struct {
+				// Read zone files only if reading a zone from the DNS server fails.
+				Fallback bool `mapstructure:"fallback" default:"false"`
+				// Zone files by zone.
+				Zones []ZonefileSpec `mapstructure:"zones"`
+			} `mapstructure:"zonefile"`
 			// TSIG parameters (used only with zone transfer requests).
 			Tsig struct {
 				// Enable TSIG.
@@ -52,20 +152,323 @@ type (
 				// TSIG secret (base64-encoded).
 				Secret string `mapstructure:"secret" default:"c2VjcmV0Cg=="`
 				// TSIG algorithm.
-				// Allowed values: 'hmac-sha1', hmac-sha224, 'hmac-sha256', 'hmac-sha384', 'hmac-sha512'. 'hmac-sha256' is used if an invalid value is specified.
+				// Allowed values: 'hmac-sha1', hmac-sha224, 'hmac-sha256', 'hmac-sha384', 'hmac-sha512', 'gss-tsig'. 'hmac-sha256' is used if an invalid value is specified.
+				// With 'gss-tsig' the key is negotiated with Kerberos and 'key' and 'secret' are not used.
 				Algo string `mapstructure:"algo" default:"hmac-sha256."`
+				// Require every zone transfer message to carry a valid signature, failing the zone otherwise.
+				// Messages with invalid signatures are only reported if disabled.
+				Strict bool `mapstructure:"strict" default:"false"`
+				// GSS-TSIG (RFC 3645) parameters. The service ticket must use an AES Kerberos encryption type.
+				Gss struct {
+					// Kerberos realm. The realm of the client principal is used if empty.
+					Realm string `mapstructure:"realm" default:""`
+					// Client principal. The first principal of the keytab or the default principal of the credential cache is used if empty.
+					Principal string `mapstructure:"principal" default:""`
+					// Keytab holding the keys of the client principal. The credential cache is used if empty.
+					Keytab string `mapstructure:"keytab" default:""`
+					// File credential cache holding a ticket-granting ticket, e.g. acquired with kinit. '$KRB5CCNAME' or '/tmp/krb5cc_<uid>' is used if empty.
+					Ccache string `mapstructure:"ccache" default:""`
+					// KDC addresses of the realm. The '_kerberos' SRV records of the realm are looked up if empty.
+					KDCs []string `mapstructure:"kdcs"`
+					// DNS service principal. 'DNS/<DNS server host name>' is used if empty.
+					Service string `mapstructure:"service" default:""`
+				} `mapstructure:"gss"`
 			} `mapstructure:"tsig"`
 		} `mapstructure:"dns"`
+		// Knot DNS and NSD control channel datasource configuration. Zones and TXT records are handled according to the DNS datasource configuration.
+		Control struct {
+			// Control channel address. Knot DNS: UNIX socket path ('/run/knot/knot.sock' if empty). NSD: 'host:port' address ('127.0.0.1:8952' if empty) or UNIX socket path.
+			Socket string `mapstructure:"socket" default:""`
+			// Control channel timeout. The DNS datasource timeout is used if set to 0.
+			Timeout time.Duration `mapstructure:"timeout" default:"0s"`
+			// NSD zone file path pattern, '%s' is replaced with the zone name without the trailing dot.
+			// Zones are written to their zone files by NSD before reading, so the pattern must match the NSD configuration.
+			Zonefile string `mapstructure:"zonefile" default:"/var/lib/nsd/%s.zone"`
+			// NSD control channel TLS configuration (not used with UNIX sockets).
+			TLS struct {
+				// Server certificate.
+				CA string `mapstructure:"ca" default:"/etc/nsd/nsd_server.pem"`
+				// Client certificate.
+				Cert string `mapstructure:"cert" default:"/etc/nsd/nsd_control.pem"`
+				// Client private key.
+				Key string `mapstructure:"key" default:"/etc/nsd/nsd_control.key"`
+			} `mapstructure:"tls"`
+		} `mapstructure:"control"`
+		// AWS Route53 datasource configuration. Zones and TXT records are handled according to the DNS datasource configuration.
+		Route53 struct {
+			// Route53 API endpoint.
+			Endpoint string `mapstructure:"endpoint" default:"https://route53.amazonaws.com"`
+			// Region used to sign Route53 API requests: 'us-east-1' for the global AWS partition.
+			Region string `mapstructure:"region" default:"us-east-1"`
+			// Network timeout for Route53 API requests. The DNS datasource timeout is used if set to 0.
+			Timeout time.Duration `mapstructure:"timeout" default:"0s"`
+			// Select the private hosted zone if a public and a private hosted zone share a name.
+			Private bool `mapstructure:"private" default:"false"`
+		} `mapstructure:"route53"`
+		// PowerDNS Authoritative HTTP API datasource configuration. Zones and TXT records are handled according to the DNS datasource configuration.
+		PowerDNS struct {
+			// PowerDNS API address.
+			Address string `mapstructure:"address" default:"http://127.0.0.1:8081"`
+			// PowerDNS API key.
+			Key string `mapstructure:"key" default:""`
+			// PowerDNS server ID.
+			Server string `mapstructure:"server" default:"localhost"`
+			// Network timeout for PowerDNS API requests. The DNS datasource timeout is used if set to 0.
+			Timeout time.Duration `mapstructure:"timeout" default:"0s"`
+			// TTL of published TXT records.
+			TTL uint32 `mapstructure:"ttl" default:"3600"`
+			// PowerDNS datasource import mode configuration.
+			Import struct {
+				// Delete all TXT RRsets read as host records in the configured zones before importing records from file.
+				Clear bool `mapstructure:"clear" default:"false"`
+			} `mapstructure:"import"`
+		} `mapstructure:"powerdns"`
+		// Cloudflare datasource configuration. Zones and TXT records are handled according to the DNS datasource configuration.
+		Cloudflare struct {
+			// Cloudflare v4 API endpoint.
+			Endpoint string `mapstructure:"endpoint" default:"https://api.cloudflare.com/client/v4"`
+			// API token with the 'Zone:Read' and 'DNS:Read' permissions.
+			Token string `mapstructure:"token" default:""`
+			// Account ID used to look up zones by name. Zones of all accounts available to the token are looked up if empty.
+			Account string `mapstructure:"account" default:""`
+			// Number of records requested per page.
+			PerPage int `mapstructure:"perpage" default:"100"`
+			// Time limit for reading a single zone through the Cloudflare API. The DNS datasource timeout is used if set to 0.
+			Timeout time.Duration `mapstructure:"timeout" default:"0s"`
+		} `mapstructure:"cloudflare"`
+		// AWS API credentials configuration.
+		AWS struct {
+			// AWS region.
+			Region string `mapstructure:"region" default:"us-east-1"`
+			// Shared credentials file profile. 'AWS_PROFILE' or 'default' is used if empty.
+			Profile string `mapstructure:"profile" default:""`
+			// Static access key ID. Credentials are acquired from the environment, the shared credentials file,
+			// a web identity token or the EC2 instance metadata service if empty.
+			AccessKeyID string `mapstructure:"accesskeyid" default:""`
+			// Static secret access key.
+			SecretAccessKey string `mapstructure:"secretaccesskey" default:""`
+			// Static session token.
+			SessionToken string `mapstructure:"sessiontoken" default:""`
+			// IAM role assumed using the acquired credentials. Not used if empty.
+			RoleARN string `mapstructure:"rolearn" default:""`
+			// External ID used to assume the IAM role.
+			ExternalID string `mapstructure:"externalid" default:""`
+			// Session name used to assume IAM roles.
+			SessionName string `mapstructure:"sessionname" default:"ansible-dns-inventory"`
+			// STS endpoint. The regional endpoint is used if empty.
+			STSEndpoint string `mapstructure:"stsendpoint" default:""`
+			// EC2 instance metadata service endpoint.
+			IMDSEndpoint string `mapstructure:"imdsendpoint" default:"http://169.254.169.254"`
+		} `mapstructure:"aws"`
+		// S3 datasource configuration. Host records are kept in an inventory snapshot object: an 'attrs' export mapping hosts to lists of attribute dictionaries.
+		// Credentials are acquired according to the 'aws' section.
+		S3 struct {
+			// Bucket name.
+			Bucket string `mapstructure:"bucket" default:""`
+			// Object key of the inventory snapshot.
+			Key string `mapstructure:"key" default:"inventory.yaml"`
+			// Snapshot format: 'yaml', 'json' or 'auto' ('json' for keys ending with '.json', 'yaml' otherwise). Snapshots in both formats can be read with 'yaml'.
+			Format string `mapstructure:"format" default:"auto"`
+			// S3 API endpoint, e.g. of S3-compatible storage. 'https://s3.<region>.amazonaws.com' is used if empty.
+			Endpoint string `mapstructure:"endpoint" default:""`
+			// Use path-style requests ('<endpoint>/<bucket>/<key>') instead of virtual-hosted-style requests, as required by most S3-compatible storage.
+			PathStyle bool `mapstructure:"pathstyle" default:"false"`
+			// Region used to sign S3 requests. The AWS region is used if empty.
+			Region string `mapstructure:"region" default:""`
+			// Server-side encryption of written snapshots: 'AES256' or 'aws:kms'. The default encryption of the bucket is used if empty.
+			SSE string `mapstructure:"sse" default:""`
+			// KMS key ID used with 'aws:kms' encryption. The AWS managed key is used if empty.
+			KMSKey string `mapstructure:"kmskey" default:""`
+			// Use conditional writes to detect concurrent modifications of the snapshot. Disable for S3-compatible storage that does not support them.
+			Conditional bool `mapstructure:"conditional" default:"true"`
+			// Network timeout for S3 requests.
+			Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+			// S3 datasource import mode configuration.
+			Import struct {
+				// Replace the whole snapshot when importing records from file. Only the imported hosts are replaced otherwise.
+				Clear bool `mapstructure:"clear" default:"true"`
+			} `mapstructure:"import"`
+		} `mapstructure:"s3"`
+		// Cloud datasource configuration. Instances are listed through the APIs of cloud providers and their tags (labels in GCP) are converted into host records.
+		Cloud struct {
+			// Cloud providers: 'aws' (EC2), 'gcp' (Compute Engine) and/or 'azure' (virtual machines).
+			Providers []string `mapstructure:"providers" default:"[\"aws\"]"`
+			// Tag filters: only instances having all of these tags are listed. Filters are 'key=value' or 'key' to match any value.
+			Filters []string `mapstructure:"filters"`
+			// List stopped instances as well.
+			Stopped bool `mapstructure:"stopped" default:"false"`
+			// Tag holding the hostname. The private DNS name (AWS), the hostname or the instance name (GCP) or the computer name (Azure) is used if empty or missing.
+			Hostname string `mapstructure:"hostname" default:""`
+			// Domain appended to hostnames that are not fully qualified.
+			Domain string `mapstructure:"domain" default:""`
+			// Mapping of host attribute keys to tags, e.g. 'ENV: environment'.
+			Tags map[string]string `mapstructure:"tags"`
+			// Host attribute values used if the mapped tag is missing, e.g. 'OS: linux'.
+			Defaults map[string]string `mapstructure:"defaults"`
+			// Network timeout for cloud API requests.
+			Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+			// AWS EC2 configuration. Credentials are acquired according to the 'aws' section.
+			AWS struct {
+				// Regions to list instances in. The AWS region is used if empty.
+				Regions []string `mapstructure:"regions"`
+				// EC2 API endpoint. 'https://ec2.<region>.amazonaws.com' is used if empty.
+				Endpoint string `mapstructure:"endpoint" default:""`
+			} `mapstructure:"aws"`
+			// GCP Compute Engine configuration.
+			GCP struct {
+				// Projects to list instances in. The project of the service account key or of the metadata server is used if empty.
+				Projects []string `mapstructure:"projects"`
+				// Path to a service account key file. 'GOOGLE_APPLICATION_CREDENTIALS' is used if empty, or the metadata server if both are empty.
+				Credentials string `mapstructure:"credentials" default:""`
+				// Compute Engine API endpoint.
+				Endpoint string `mapstructure:"endpoint" default:"https://compute.googleapis.com"`
+				// Metadata server endpoint.
+				MetadataEndpoint string `mapstructure:"metadataendpoint" default:"http://metadata.google.internal"`
+			} `mapstructure:"gcp"`
+			// Azure configuration.
+			Azure struct {
+				// Subscriptions to list virtual machines in.
+				Subscriptions []string `mapstructure:"subscriptions"`
+				// Microsoft Entra ID tenant of the service principal.
+				TenantID string `mapstructure:"tenantid" default:""`
+				// Client ID of the service principal. The managed identity of the virtual machine is used if empty.
+				ClientID string `mapstructure:"clientid" default:""`
+				// Client secret of the service principal.
+				ClientSecret string `mapstructure:"clientsecret" default:""`
+				// Azure Resource Manager endpoint.
+				Endpoint string `mapstructure:"endpoint" default:"https://management.azure.com"`
+				// Microsoft Entra ID endpoint.
+				LoginEndpoint string `mapstructure:"loginendpoint" default:"https://login.microsoftonline.com"`
+				// Instance metadata service endpoint used to acquire managed identity tokens.
+				IMDSEndpoint string `mapstructure:"imdsendpoint" default:"http://169.254.169.254"`
+			} `mapstructure:"azure"`
+		} `mapstructure:"cloud"`
+		// Consul ('consul' datasource type) and Nomad ('nomad' datasource type) datasource configuration.
+		// Hosts are the nodes running Consul service instances or Nomad allocations, with a host record for every service or job running on a node.
+		Workloads struct {
+			// Domain appended to node names that are not fully qualified.
+			Domain string `mapstructure:"domain" default:""`
+			// Mapping of host attribute keys to node metadata keys, e.g. 'ENV: environment'. ROLE and SRV are derived from the workloads and cannot be mapped.
+			// '@datacenter' refers to the datacenter of the node and '@namespace' to the namespace of the service instance or allocation.
+			Meta map[string]string `mapstructure:"meta"`
+			// Host attribute values used if the mapped metadata is missing, e.g. 'OS: linux'.
+			Defaults map[string]string `mapstructure:"defaults"`
+			// Network timeout for API requests.
+			Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+			// TLS configuration.
+			TLS struct {
+				// Skip verification of the server's certificate chain and host name.
+				Insecure bool `mapstructure:"insecure" default:"false"`
+				// Trusted CA bundle file. System CAs are used if empty.
+				CA string `mapstructure:"ca" default:""`
+			} `mapstructure:"tls"`
+			// Consul service catalog configuration. ROLE is the service name.
+			Consul struct {
+				// Consul agent address.
+				Address string `mapstructure:"address" default:"http://127.0.0.1:8500"`
+				// ACL token. Not sent if empty.
+				Token string `mapstructure:"token" default:""`
+				// Datacenters to read services from. The datacenter of the agent is used if empty.
+				Datacenters []string `mapstructure:"datacenters"`
+				// Services to read. All services are read if empty.
+				Services []string `mapstructure:"services"`
+				// Services to skip.
+				Exclude []string `mapstructure:"exclude" default:"[\"consul\"]"`
+				// Read only service instances whose health checks are all passing.
+				Passing bool `mapstructure:"passing" default:"false"`
+				// Prefix of the service tags used as SRV, with the prefix removed (e.g. 'srv-' for 'srv-tomcat'). Tags are not used if empty.
+				TagPrefix string `mapstructure:"tagprefix" default:""`
+			} `mapstructure:"consul"`
+			// Nomad allocations configuration. ROLE is the job ID, SRV is the task group.
+			Nomad struct {
+				// Nomad agent address.
+				Address string `mapstructure:"address" default:"http://127.0.0.1:4646"`
+				// ACL token. Not sent if empty.
+				Token string `mapstructure:"token" default:""`
+				// Region to read allocations from. The region of the agent is used if empty.
+				Region string `mapstructure:"region" default:""`
+				// Namespace to read allocations from, '*' for all namespaces.
+				Namespace string `mapstructure:"namespace" default:"*"`
+				// Jobs to read. All jobs are read if empty.
+				Jobs []string `mapstructure:"jobs"`
+			} `mapstructure:"nomad"`
+		} `mapstructure:"workloads"`
+		// SSH datasource configuration. The command output is parsed as a zone file, zones and TXT records are handled according to the DNS datasource configuration.
+		SSH struct {
+			// Remote host address ('host:port').
+			Address string `mapstructure:"address" default:"127.0.0.1:22"`
+			// SSH user. The current user is used if empty.
+			User string `mapstructure:"user" default:""`
+			// Private key files.
+			Keys []string `mapstructure:"keys"`
+			// Private key passphrase.
+			Passphrase string `mapstructure:"passphrase" default:""`
+			// Use the keys of the SSH agent ('SSH_AUTH_SOCK').
+			Agent bool `mapstructure:"agent" default:"true"`
+			// Known hosts file. '~/.ssh/known_hosts' is used if empty.
+			KnownHosts string `mapstructure:"knownhosts" default:""`
+			// Skip host key verification.
+			Insecure bool `mapstructure:"insecure" default:"false"`
+			// Network timeout for connecting and for running the command for a single zone. The DNS datasource timeout is used if set to 0.
+			Timeout time.Duration `mapstructure:"timeout" default:"0s"`
+			// Command printing the records of a zone in the zone file format, '%s' is replaced with the quoted zone name without the trailing dot.
+			Command string `mapstructure:"command" default:"dig +nocmd +nostats axfr %s"`
+		} `mapstructure:"ssh"`
 		// Etcd datasource configuration.
 		Etcd struct {
 			// Etcd cluster endpoints.
 			Endpoints []string `mapstructure:"endpoints" default:"[\"127.0.0.1:2379\"]"`
-			// Network timeout for etcd requests.
+			// Network timeout for etcd requests, used for connecting and for every request unless overridden in 'timeouts'.
 			Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+			// Per-operation network timeouts for etcd connections, read requests and write transactions.
+			Timeouts Timeouts `mapstructure:"timeouts"`
+			// Time limit for reading all zones. Zones that have not been read in time are skipped. No limit is applied if set to 0.
+			Deadline time.Duration `mapstructure:"deadline" default:"0s"`
 			// Etcd k/v path prefix.
 			Prefix string `mapstructure:"prefix" default:"ANSIBLE_INVENTORY"`
 			// Etcd host zone list.
 			Zones []string `mapstructure:"zones" default:"[\"server.local.\"]"`
+			// Host record namespaces: k/v path prefixes used instead of 'prefix' for hosts in specific environments, keyed by the value of the environment attribute.
+			Namespaces map[string]string `mapstructure:"namespaces"`
+			// Restrict reading and publishing host records to the namespace of this environment. All namespaces are used if empty.
+			Namespace string `mapstructure:"namespace" default:""`
+			// Endpoint health check configuration.
+			Health struct {
+				// Probe endpoints before connecting, skip unreachable ones and try the others in order of response time.
+				Enabled bool `mapstructure:"enabled" default:"true"`
+				// Timeout of a single endpoint probe.
+				Timeout time.Duration `mapstructure:"timeout" default:"2s"`
+			} `mapstructure:"health"`
+			// Connection keepalive configuration.
+			Keepalive struct {
+				// Interval between keepalive pings. Keepalive pings are disabled if set to 0.
+				Time time.Duration `mapstructure:"time" default:"30s"`
+				// Time to wait for a keepalive response before closing the connection.
+				Timeout time.Duration `mapstructure:"timeout" default:"10s"`
+				// Send keepalive pings even if there are no active requests.
+				PermitWithoutStream bool `mapstructure:"permitwithoutstream" default:"false"`
+			} `mapstructure:"keepalive"`
+			// gRPC client tuning.
+			GRPC struct {
+				// Maximum size of a response message. The etcd client default is used if set to 0.
+				MaxRecvSize ByteSize `mapstructure:"maxrecvsize" default:"0"`
+				// Maximum size of a request message. The etcd client default (2 MiB) is used if set to 0.
+				MaxSendSize ByteSize `mapstructure:"maxsendsize" default:"0"`
+				// Refuse to connect to etcd clusters running an outdated version.
+				RejectOldCluster bool `mapstructure:"rejectoldcluster" default:"false"`
+			} `mapstructure:"grpc"`
+			// Read consistency level: 'linearizable' or 'serializable'.
+			// Serializable reads are served by any cluster member and may return stale data.
+			Consistency string `mapstructure:"consistency" default:"linearizable"`
+			// Read all zones at the same revision to get an internally consistent snapshot of the inventory.
+			Snapshot bool `mapstructure:"snapshot" default:"true"`
+			// Group variables configuration.
+			Groupvars struct {
+				// Enable reading group variables from '<key>/<group>/<variable>' keys.
+				Enabled bool `mapstructure:"enabled" default:"false"`
+				// Group variables subtree key, relative to the etcd k/v path prefix.
+				Key string `mapstructure:"key" default:"_groupvars"`
+			} `mapstructure:"groupvars"`
 			// Etcd authentication configuration.
 			Auth struct {
 				// Username for authentication.
@@ -95,6 +498,28 @@ type (
 					PEM  string `mapstructure:"pem" default:""`
 				} `mapstructure:"key"`
 			} `mapstructure:"tls"`
+			// Client-side encryption of host records.
+			Encryption struct {
+				// Encrypt host records before publishing them. Encrypted records are decrypted when read regardless of this setting if a key is configured.
+				Enabled bool `mapstructure:"enabled" default:"false"`
+				// Base64-encoded AES key (16, 24 or 32 bytes).
+				Key string `mapstructure:"key" default:""`
+				// Path to a file containing a base64-encoded AES key.
+				Path string `mapstructure:"path" default:""`
+				// Command printing a base64-encoded AES key to stdout (e.g. a KMS client decrypting a data key).
+				Command []string `mapstructure:"command"`
+				// Previous base64-encoded AES keys, only used to decrypt host records encrypted before a key rotation.
+				Keys []string `mapstructure:"keys"`
+				// Encryption key provider: 'local' (the key configured above) or 'vault' (data keys generated and wrapped by a Vault transit key).
+				Provider string `mapstructure:"provider" default:"local"`
+				// Vault transit secrets engine configuration. The address, TLS and authentication settings of the Vault datasource are used.
+				Transit struct {
+					// Transit secrets engine mount path.
+					Mount string `mapstructure:"mount" default:"transit"`
+					// Transit key name.
+					Key string `mapstructure:"key" default:""`
+				} `mapstructure:"transit"`
+			} `mapstructure:"encryption"`
 			// Etcd datasource import mode configuration.
 			Import struct {
 				// Clear all existing host records before importing records from file.
@@ -102,8 +527,325 @@ type (
 				// Batch size used when pushing host records to etcd.
 				// Should not exceed the maximum number of operations permitted in a etcd transaction (max-txn-ops).
 				Batch int `mapstructure:"batch" default:"128"`
+				// Number of transactions executed concurrently when pushing host records to etcd.
+				Workers int `mapstructure:"workers" default:"4"`
 			} `mapstructure:"import"`
 		} `mapstructure:"etcd"`
+		// HashiCorp Vault KV v2 datasource configuration.
+		Vault struct {
+			// Vault server address.
+			Address string `mapstructure:"address" default:"https://127.0.0.1:8200"`
+			// Vault Enterprise namespace. Not used if empty.
+			Namespace string `mapstructure:"namespace" default:""`
+			// Network timeout for Vault requests.
+			Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+			// KV v2 secrets engine mount path.
+			Mount string `mapstructure:"mount" default:"secret"`
+			// Host records path, relative to the mount. The records of a host are stored in the '<path>/<zone>/<hostname>' secret.
+			Path string `mapstructure:"path" default:"ANSIBLE_INVENTORY"`
+			// Vault host zone list.
+			Zones []string `mapstructure:"zones" default:"[\"server.local.\"]"`
+			// Number of host secrets read concurrently.
+			Workers int `mapstructure:"workers" default:"8"`
+			// Vault authentication configuration.
+			Auth struct {
+				// Authentication method: 'token', 'approle' or 'kubernetes'.
+				Method string `mapstructure:"method" default:"token"`
+				// Mount path of the authentication method. The name of the method is used if empty.
+				Mount string `mapstructure:"mount" default:""`
+				// Vault token (token).
+				Token string `mapstructure:"token" default:""`
+				// AppRole role ID (approle).
+				RoleID string `mapstructure:"roleid" default:""`
+				// AppRole secret ID (approle).
+				SecretID string `mapstructure:"secretid" default:""`
+				// Vault role (kubernetes).
+				Role string `mapstructure:"role" default:""`
+				// Path to the service account token (kubernetes).
+				JWT string `mapstructure:"jwt" default:"/var/run/secrets/kubernetes.io/serviceaccount/token"`
+			} `mapstructure:"auth"`
+			// Vault TLS configuration.
+			TLS struct {
+				// Skip verification of the Vault server's certificate chain and host name.
+				Insecure bool `mapstructure:"insecure" default:"false"`
+				// CA certificates (PEM file) trusted to verify the Vault server instead of the system CA certificates.
+				CA string `mapstructure:"ca" default:""`
+			} `mapstructure:"tls"`
+			// Vault datasource import mode configuration.
+			Import struct {
+				// Delete all existing host secrets of the configured zones before importing records from file.
+				Clear bool `mapstructure:"clear" default:"true"`
+			} `mapstructure:"import"`
+		} `mapstructure:"vault"`
+		// Kubernetes datasource configuration.
+		Kubernetes struct {
+			// Path to the kubeconfig file. The files listed in 'KUBECONFIG' or '~/.kube/config' are used if empty, or the pod's service account inside a cluster.
+			Kubeconfig string `mapstructure:"kubeconfig" default:""`
+			// Kubeconfig context. The current context is used if empty.
+			Context string `mapstructure:"context" default:""`
+			// Namespace of the objects. The namespace of the context or of the service account is used if empty, or 'default'.
+			Namespace string `mapstructure:"namespace" default:""`
+			// Objects holding host records: 'configmap' or 'crd' (InventoryHost custom resources).
+			Source string `mapstructure:"source" default:"configmap"`
+			// Label selector of the objects. All objects in the namespace are read if empty.
+			Selector string `mapstructure:"selector" default:"ansible-dns-inventory.io/inventory=true"`
+			// Network timeout for Kubernetes requests.
+			Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+		} `mapstructure:"kubernetes"`
+		// LDAP datasource configuration.
+		LDAP struct {
+			// LDAP server URL: 'ldap://<host>[:<port>]' or 'ldaps://<host>[:<port>]'.
+			URL string `mapstructure:"url" default:"ldap://127.0.0.1:389"`
+			// Upgrade plain LDAP connections to TLS with StartTLS.
+			StartTLS bool `mapstructure:"starttls" default:"false"`
+			// Bind DN for simple authentication. Binds anonymously if empty.
+			BindDN string `mapstructure:"binddn" default:""`
+			// Bind password.
+			Password string `mapstructure:"password" default:""`
+			// Search base DN.
+			BaseDN string `mapstructure:"basedn" default:""`
+			// Search scope: 'base', 'one' or 'sub'.
+			Scope string `mapstructure:"scope" default:"sub"`
+			// Search filter selecting host entries (RFC 4515).
+			Filter string `mapstructure:"filter" default:"(objectClass=computer)"`
+			// Entry attribute holding the hostname.
+			Hostname string `mapstructure:"hostname" default:"dNSHostName"`
+			// Entry attribute holding complete host records, one per value. Host attributes are mapped with 'attributes' if empty.
+			Records string `mapstructure:"records" default:""`
+			// Mapping of host attribute keys to entry attributes, e.g. 'OS: operatingSystem'.
+			Attributes map[string]string `mapstructure:"attributes"`
+			// Host attribute values used if the mapped entry attribute is missing, e.g. 'ENV: prod'.
+			Defaults map[string]string `mapstructure:"defaults"`
+			// Number of entries requested per page with the Simple Paged Results control. Set to 0 to disable paging.
+			PageSize int `mapstructure:"pagesize" default:"500"`
+			// Network timeout for LDAP requests.
+			Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+			// LDAP TLS configuration.
+			TLS struct {
+				// Skip verification of the LDAP server's certificate chain and host name.
+				Insecure bool `mapstructure:"insecure" default:"false"`
+				// PEM file of the CA certificates trusted to verify the LDAP server with LDAPS and StartTLS. System CA certificates are used if empty.
+				CA string `mapstructure:"ca" default:""`
+			} `mapstructure:"tls"`
+		} `mapstructure:"ldap"`
+		// FreeIPA datasource configuration. Host objects are read through the IPA JSON-RPC API.
+		FreeIPA struct {
+			// IPA server URL, e.g. 'https://ipa.infra.local'.
+			URL string `mapstructure:"url" default:""`
+			// User to log in as.
+			Username string `mapstructure:"username" default:""`
+			// Password of the user.
+			Password string `mapstructure:"password" default:""`
+			// Search criteria matched against the host attributes. All hosts are read if empty.
+			Criteria string `mapstructure:"criteria" default:""`
+			// Only read members of these host groups (directly or indirectly).
+			HostGroups []string `mapstructure:"hostgroups"`
+			// Host object attribute holding the hostname.
+			Hostname string `mapstructure:"hostname" default:"fqdn"`
+			// Mapping of host attribute keys to host object attributes, e.g. 'ENV: nshostlocation'.
+			Attributes map[string]string `mapstructure:"attributes"`
+			// Host attribute values used if the mapped host object attribute is missing, e.g. 'OS: linux'.
+			Defaults map[string]string `mapstructure:"defaults"`
+			// Timeout for IPA requests.
+			Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+			// IPA TLS configuration.
+			TLS struct {
+				// Skip verification of the IPA server's certificate chain and host name.
+				Insecure bool `mapstructure:"insecure" default:"false"`
+				// Trusted CA bundle path, e.g. '/etc/ipa/ca.crt'. System CAs are used if empty.
+				CA string `mapstructure:"ca" default:""`
+			} `mapstructure:"tls"`
+		} `mapstructure:"freeipa"`
+		// phpIPAM datasource configuration. Addresses with a hostname are converted into host records.
+		PhpIPAM struct {
+			// API URL, including the application ID, e.g. 'https://ipam.infra.local/api/inventory'.
+			URL string `mapstructure:"url" default:""`
+			// App code of an API application using the 'SSL with App code token' security.
+			Token string `mapstructure:"token" default:""`
+			// Subnets to read addresses from, in CIDR notation. All subnets of all sections are read if empty.
+			Subnets []string `mapstructure:"subnets"`
+			// Domain appended to hostnames that are not fully qualified.
+			Domain string `mapstructure:"domain" default:""`
+			// Mapping of host attribute keys to address fields, e.g. 'ROLE: custom_role'. Fields of the subnet are referred to with the 'subnet.' prefix, e.g. 'ENV: subnet.custom_environment'.
+			Attributes map[string]string `mapstructure:"attributes"`
+			// Host attribute values used if the mapped field is empty, e.g. 'OS: linux'.
+			Defaults map[string]string `mapstructure:"defaults"`
+			// Timeout for phpIPAM requests.
+			Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+			// phpIPAM TLS configuration.
+			TLS struct {
+				// Skip verification of the phpIPAM server's certificate chain and host name.
+				Insecure bool `mapstructure:"insecure" default:"false"`
+				// CA certificate file used to verify the phpIPAM server. System CA certificates are used if empty.
+				CA string `mapstructure:"ca" default:""`
+			} `mapstructure:"tls"`
+		} `mapstructure:"phpipam"`
+		// ServiceNow CMDB datasource configuration. Configuration items of a CMDB table are converted into host records.
+		ServiceNow struct {
+			// Instance URL, e.g. 'https://example.service-now.com'.
+			URL string `mapstructure:"url" default:""`
+			// CMDB table, e.g. 'cmdb_ci_server' or 'cmdb_ci_linux_server'.
+			Table string `mapstructure:"table" default:"cmdb_ci_server"`
+			// Encoded query selecting configuration items, e.g. 'operational_status=1^install_status=1'. All items of the table are read if empty.
+			Query string `mapstructure:"query" default:""`
+			// Field holding the hostname.
+			Hostname string `mapstructure:"hostname" default:"fqdn"`
+			// Domain appended to hostnames that are not fully qualified.
+			Domain string `mapstructure:"domain" default:""`
+			// Mapping of host attribute keys to fields, including dot-walked reference fields, e.g. 'ENV: environment' or 'ROLE: u_ansible_role'.
+			Fields map[string]string `mapstructure:"fields"`
+			// Host attribute values used if the mapped field is empty, e.g. 'OS: linux'.
+			Defaults map[string]string `mapstructure:"defaults"`
+			// Use the display values of choice and reference fields (e.g. 'Production') instead of their actual values (e.g. 'prod' or a sys_id).
+			DisplayValue bool `mapstructure:"displayvalue" default:"true"`
+			// Number of configuration items read per request.
+			PageSize int `mapstructure:"pagesize" default:"1000"`
+			// Timeout for ServiceNow requests.
+			Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+			// Authentication configuration.
+			Auth struct {
+				// Authentication method: 'basic' or 'oauth'.
+				Method string `mapstructure:"method" default:"basic"`
+				// User name for basic authentication and the OAuth 'password' grant.
+				Username string `mapstructure:"username" default:""`
+				// Password for basic authentication and the OAuth 'password' grant.
+				Password string `mapstructure:"password" default:""`
+				// OAuth client ID of an application registry entry.
+				ClientID string `mapstructure:"clientid" default:""`
+				// OAuth client secret.
+				ClientSecret string `mapstructure:"clientsecret" default:""`
+				// OAuth grant type: 'password' or 'client_credentials'.
+				Grant string `mapstructure:"grant" default:"password"`
+			} `mapstructure:"auth"`
+			// ServiceNow TLS configuration.
+			TLS struct {
+				// Skip verification of the instance's certificate chain and host name.
+				Insecure bool `mapstructure:"insecure" default:"false"`
+				// PEM file of the CA certificates trusted to verify the instance, including its OAuth token endpoint. The system CA certificates are used if empty.
+				CA string `mapstructure:"ca" default:""`
+			} `mapstructure:"tls"`
+		} `mapstructure:"servicenow"`
+		// CSV datasource configuration.
+		CSV struct {
+			// Path to the CSV file.
+			Path string `mapstructure:"path" default:""`
+			// Field delimiter.
+			Delimiter string `mapstructure:"delimiter" default:","`
+			// Lines starting with this character are ignored. Set to an empty string to disable comments.
+			Comment string `mapstructure:"comment" default:"#"`
+			// The first line holds column names. Columns are referred to by number, starting from 1, if disabled.
+			Header bool `mapstructure:"header" default:"true"`
+			// Column holding the hostname.
+			Hostname string `mapstructure:"hostname" default:"hostname"`
+			// Column holding complete host records. Host attributes are mapped with 'columns' if empty.
+			Records string `mapstructure:"records" default:""`
+			// Mapping of host attribute keys to columns, e.g. 'OS: os_family'.
+			Columns map[string]string `mapstructure:"columns"`
+			// Host attribute values used if the mapped column is empty, e.g. 'ENV: prod'.
+			Defaults map[string]string `mapstructure:"defaults"`
+		} `mapstructure:"csv"`
+		// SQLite datasource configuration. Host records are kept in a table of a single SQLite database file.
+		SQLite struct {
+			// Path to the database file. It is created when records are published if it does not exist.
+			Path string `mapstructure:"path" default:""`
+			// Table holding host records in the 'hostname' and 'attributes' columns.
+			Table string `mapstructure:"table" default:"records"`
+			// SQLite datasource import mode configuration.
+			Import struct {
+				// Remove all host records before importing records from file. Only the imported hosts are replaced otherwise.
+				Clear bool `mapstructure:"clear" default:"true"`
+			} `mapstructure:"import"`
+		} `mapstructure:"sqlite"`
+		// HTTP datasource configuration.
+		HTTP struct {
+			// URL of the endpoint returning a list of host records.
+			URL string `mapstructure:"url" default:""`
+			// URL of the endpoint returning the records of a single host, with the '{host}' placeholder replaced by the hostname. All records are fetched and filtered if empty.
+			HostURL string `mapstructure:"hosturl" default:""`
+			// Response format: 'json', 'yaml' or 'auto' (detected from the Content-Type header).
+			Format string `mapstructure:"format" default:"auto"`
+			// Additional request headers.
+			Headers map[string]string `mapstructure:"headers"`
+			// Bearer token.
+			Token string `mapstructure:"token" default:""`
+			// Path to a file containing the bearer token. The file is read with every request, so that the token can be rotated.
+			TokenFile string `mapstructure:"tokenfile" default:""`
+			// Timeout for HTTP requests.
+			Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+			// HTTP TLS configuration.
+			TLS struct {
+				// Skip verification of the server's certificate chain and host name.
+				Insecure bool `mapstructure:"insecure" default:"false"`
+				// Trusted CA bundle. System CAs are used if empty.
+				CA struct {
+					Path string `mapstructure:"path" default:""`
+					PEM  string `mapstructure:"pem" default:""`
+				} `mapstructure:"ca"`
+				// Client certificate.
+				Certificate struct {
+					Path string `mapstructure:"path" default:""`
+					PEM  string `mapstructure:"pem" default:""`
+				} `mapstructure:"certificate"`
+				// Client private key.
+				Key struct {
+					Path string `mapstructure:"path" default:""`
+					PEM  string `mapstructure:"pem" default:""`
+				} `mapstructure:"key"`
+			} `mapstructure:"tls"`
+		} `mapstructure:"http"`
+		// PuppetDB datasource configuration. Host records are built from the facts of Puppet nodes.
+		PuppetDB struct {
+			// PuppetDB URL.
+			URL string `mapstructure:"url" default:"http://127.0.0.1:8080"`
+			// Puppet Enterprise RBAC token sent in the 'X-Authentication' header. Not sent if empty.
+			Token string `mapstructure:"token" default:""`
+			// Path to a file containing the RBAC token. The file is read with every request, so that the token can be rotated.
+			TokenFile string `mapstructure:"tokenfile" default:""`
+			// Query restricting the nodes in the PuppetDB AST query language, e.g. '["=", "environment", "production"]'. All nodes are read if empty.
+			Query string `mapstructure:"query" default:""`
+			// Fact holding the hostname, e.g. 'networking.fqdn'. Node certnames are used if empty.
+			Hostname string `mapstructure:"hostname" default:""`
+			// Mapping of host attribute keys to facts, e.g. 'OS: os.family'. Structured facts are referred to with dot-separated paths,
+			// '@environment' refers to the Puppet environment of the node.
+			Facts map[string]string `mapstructure:"facts"`
+			// Host attribute values used if the mapped fact is missing, e.g. 'SRV: puppet'.
+			Defaults map[string]string `mapstructure:"defaults"`
+			// Timeout for PuppetDB requests.
+			Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+			// PuppetDB TLS configuration. Open source PuppetDB expects a client certificate signed by the Puppet CA.
+			TLS struct {
+				// Skip verification of the server's certificate chain and host name.
+				Insecure bool `mapstructure:"insecure" default:"false"`
+				// Trusted CA bundle, e.g. the Puppet CA certificate. System CAs are used if empty.
+				CA struct {
+					Path string `mapstructure:"path" default:""`
+					PEM  string `mapstructure:"pem" default:""`
+				} `mapstructure:"ca"`
+				// Client certificate.
+				Certificate struct {
+					Path string `mapstructure:"path" default:""`
+					PEM  string `mapstructure:"pem" default:""`
+				} `mapstructure:"certificate"`
+				// Client private key.
+				Key struct {
+					Path string `mapstructure:"path" default:""`
+					PEM  string `mapstructure:"pem" default:""`
+				} `mapstructure:"key"`
+			} `mapstructure:"tls"`
+		} `mapstructure:"puppetdb"`
+		// External process datasource configuration.
+		Exec struct {
+			// Datasource plugin executable.
+			Command string `mapstructure:"command" default:""`
+			// Datasource plugin arguments.
+			Args []string `mapstructure:"args"`
+			// Additional environment variables of the datasource plugin ('NAME=value').
+			Env []string `mapstructure:"env"`
+			// Plugin-specific settings sent to the datasource plugin in the handshake request. Keys are lowercased.
+			Settings map[string]interface{} `mapstructure:"settings"`
+			// Time limit for a single request. The plugin is killed and restarted with the next request if it does not respond in time. No limit is applied if set to 0.
+			Timeout time.Duration `mapstructure:"timeout" default:"60s"`
+		} `mapstructure:"exec"`
 		// Host records parsing configuration.
 		Txt struct {
 			// Key/value pair parsing configuration.
@@ -136,12 +878,363 @@ type (
 				Srv string `mapstructure:"srv" default:"SRV"`
 				// Key name of the attribute containing the host variables.
 				Vars string `mapstructure:"vars" default:"VARS"`
+				// Key name of the optional attribute containing a unique host identifier (UUID) preserved across hostname changes.
+				ID string `mapstructure:"id" default:"ID"`
+				// Key names of optional extra attributes (e.g. a datacenter identifier). Extra attributes can be used as group axes.
+				Extra []string `mapstructure:"extra"`
 			} `mapstructure:"keys"`
 		} `mapstructure:"txt"`
+		// Host attribute value normalization, applied after parsing host records and before filtering and grouping.
+		Normalize struct {
+			// Convert attribute values to lower case.
+			Lowercase bool `mapstructure:"lowercase" default:"false"`
+			// Value maps for host attributes, keyed by attribute key (as set in 'txt.keys'). Matching is case-insensitive.
+			// Every value found in a map is replaced with the mapped value. Lists of roles and services are mapped element by element.
+			Values map[string]map[string]string `mapstructure:"values"`
+		} `mapstructure:"normalize"`
+		// Default attribute values per role, keyed by role and attribute key (as set in 'txt.keys'). Matching is case-insensitive.
+		// Only the OS, SRV and VARS attributes can have default values. Defaults are applied to attributes missing from a host record when it is parsed.
+		Defaults map[string]map[string]string `mapstructure:"defaults"`
+		// Sanity thresholds checked when the inventory tree is built, catching runaway host records (e.g. huge service lists). Thresholds set to 0 are not checked.
+		Thresholds struct {
+			// Maximum number of attribute sets per host, after splitting role and service lists.
+			Sets int `mapstructure:"sets" default:"0"`
+			// Maximum number of groups a host belongs to, directly or through child groups. The root group is not counted.
+			Groups int `mapstructure:"groups" default:"0"`
+			// Maximum number of hosts directly in a group.
+			Hosts int `mapstructure:"hosts" default:"0"`
+			// Fail if any threshold is exceeded instead of logging warnings.
+			Strict bool `mapstructure:"strict" default:"false"`
+		} `mapstructure:"thresholds"`
+		// Host pinning: hosts that must always be present in the inventory, protecting critical hosts from datasource failures.
+		Pinned struct {
+			// File listing pinned hostnames, one per line. Empty lines and lines starting with '#' are ignored. Host pinning is disabled if not set.
+			Path string `mapstructure:"path" default:""`
+			// Action taken if pinned hosts are missing: 'fail' (fail the run) or 'inject' (use the records of the last run the hosts have been found in).
+			Mode string `mapstructure:"mode" default:"fail"`
+			// File keeping the records of pinned hosts for the 'inject' mode, updated on every run.
+			Snapshot string `mapstructure:"snapshot" default:""`
+		} `mapstructure:"pinned"`
+		// Policy checks executed before host records are published.
+		Policy struct {
+			// Enable policy checks. Publishing is refused if any host record violates the policy.
+			Enabled bool `mapstructure:"enabled" default:"false"`
+			// Report violations without refusing to publish.
+			DryRun bool `mapstructure:"dryrun" default:"false"`
+			// Role catalog. Host records with other roles violate the policy. Roles are not checked if empty.
+			Roles []string `mapstructure:"roles"`
+			// Host variables that records in specific environments must have a non-empty value for, keyed by environment. Matching is case-insensitive.
+			Vars map[string][]string `mapstructure:"vars"`
+			// Sets of environments a host may not belong to at the same time.
+			Exclusive [][]string `mapstructure:"exclusive"`
+		} `mapstructure:"policy"`
+		// Host record filtering configuration.
 		Filter struct {
 			Enabled bool         `mapstructure:"enabled" default:"false"`
 			Filters []HostFilter `mapstructure:"filters"`
+			// Named filter sets that can be selected at runtime. Names are case-insensitive.
+			Sets map[string][]HostFilter `mapstructure:"sets"`
 		} `mapstructure:"filter"`
+		// Named host limit expressions (see the '-where' flag) exported as Ansible '--limit' host patterns.
+		Limits map[string]string `mapstructure:"limits"`
+		// Virtual groups: named groups under the root group whose members are hosts matching a filter expression (see the '-where' flag).
+		Groups map[string]string `mapstructure:"groups"`
+		// Groups that come first among their siblings in children lists and exports, in the listed order. Other groups are sorted by name.
+		Order []string `mapstructure:"order"`
+		// Attribute keys (as set in 'txt.keys') used as group axes, in hierarchy order. The environment key must come first.
+		// Defaults to the environment, role and service keys if empty.
+		Axes []string `mapstructure:"axes"`
+		// Host and group name sorting configuration.
+		Sort struct {
+			// Collation used to sort host and group names in exports: 'bytes' for byte order or a BCP 47 language tag (e.g. 'en', 'sv', 'und' for the root collation).
+			// Map keys are ordered by the encoders if empty.
+			Collation string `mapstructure:"collation"`
+			// Compare sequences of digits by their numeric value (e.g. 'app2' before 'app10'). Only used with language collations.
+			Numeric bool `mapstructure:"numeric" default:"false"`
+		} `mapstructure:"sort"`
+		// Secondary host variable sources configuration.
+		Varsources struct {
+			// Enable secondary host variable sources.
+			Enabled bool `mapstructure:"enabled" default:"false"`
+			// A list of variable sources. Variables from sources later in this list override earlier ones.
+			Sources []VarsourceSpec `mapstructure:"sources"`
+		} `mapstructure:"varsources"`
+		// Inventory metadata configuration.
+		Metadata struct {
+			// Export inventory metadata as a variable of the 'all' group.
+			Enabled bool `mapstructure:"enabled" default:"false"`
+			// Name of the 'all' group variable holding inventory metadata.
+			Var string `mapstructure:"var" default:"inventory_metadata"`
+		} `mapstructure:"metadata"`
+		// Host services configuration.
+		Services struct {
+			// Enable exporting host services as a structured host variable.
+			Enabled bool `mapstructure:"enabled" default:"false"`
+			// Name of the host variable holding the list of host services.
+			Var string `mapstructure:"var" default:"inventory_services"`
+			// Prefix of host variables that define services: '<prefix><service>=<port>[/<protocol>]'.
+			Prefix string `mapstructure:"prefix" default:"svc_"`
+			// SRV record names ('_<service>._<protocol>') looked up in the domain of a host.
+			SRV []string `mapstructure:"srv"`
+		} `mapstructure:"services"`
+		// Event hooks configuration.
+		Hooks struct {
+			// Commands executed before host records are published. A failing hook aborts publishing.
+			PrePublish []HookSpec `mapstructure:"prepublish"`
+			// Commands executed after host records have been published (or publishing has failed).
+			PostPublish []HookSpec `mapstructure:"postpublish"`
+		} `mapstructure:"hooks"`
+		// Commands post-processing the inventory tree before it is exported.
+		Transforms []TransformSpec `mapstructure:"transforms"`
+		// Fault injection configuration. The datasource is wrapped into a ChaosDatasource that makes host record reads unreliable.
+		Chaos struct {
+			// Enable fault injection. Never enable this in production.
+			Enabled bool `mapstructure:"enabled" default:"false"`
+			// Seed of the fault generator.
+			Seed int64 `mapstructure:"seed" default:"1"`
+			// Maximum latency added to every read. The actual latency is random.
+			Latency time.Duration `mapstructure:"latency" default:"0"`
+			// Probability of a read failing.
+			FailureRate float64 `mapstructure:"failurerate" default:"0"`
+			// Probability of a host record being left out of a read.
+			DropRate float64 `mapstructure:"droprate" default:"0"`
+			// Probability of a host record being malformed.
+			MalformedRate float64 `mapstructure:"malformedrate" default:"0"`
+		} `mapstructure:"chaos"`
+		// Datasource benchmark ('-bench-datasource' mode) and synthetic record generator configuration.
+		Bench struct {
+			// Number of benchmark runs.
+			Iterations int `mapstructure:"iterations" default:"3"`
+			// Publish synthetic host records to the datasource before the benchmark. Existing records are replaced.
+			Seed bool `mapstructure:"seed" default:"false"`
+			// Write synthetic host records to a file as DNS TXT records before the benchmark.
+			ZoneFile string `mapstructure:"zonefile"`
+			// Number of synthetic hosts.
+			Hosts int `mapstructure:"hosts" default:"1000"`
+			// Number of configured zones synthetic hosts are spread across. All zones are used if set to 0.
+			Zones int `mapstructure:"zones" default:"0"`
+			// Number of distinct operating systems.
+			OS int `mapstructure:"os" default:"2"`
+			// Number of distinct environments.
+			Envs int `mapstructure:"envs" default:"3"`
+			// Number of distinct roles.
+			Roles int `mapstructure:"roles" default:"10"`
+			// Number of distinct services.
+			Services int `mapstructure:"services" default:"5"`
+			// Number of host variables per host.
+			Vars int `mapstructure:"vars" default:"0"`
+		} `mapstructure:"bench"`
+		// Per-host variable lookup ('-host' mode) configuration.
+		Host struct {
+			// Maximum time spent querying the datasource for the records of a host. Set to 0 to disable.
+			Timeout time.Duration `mapstructure:"timeout" default:"0"`
+			// Host record cache, written whenever all host records are acquired (e.g. in the '-list' mode) and read by per-host lookups.
+			Cache struct {
+				// Cache file path. The cache is disabled if not set.
+				Path string `mapstructure:"path"`
+				// Maximum age of the cache that is used without querying the datasource. A stale cache is only used if the query fails.
+				TTL time.Duration `mapstructure:"ttl" default:"5m"`
+			} `mapstructure:"cache"`
+		} `mapstructure:"host"`
+		// Host variable redaction in the '-attrs' and '-host' exports, the server mode and scheduled exports. Published host records are not redacted.
+		Redact struct {
+			// Glob patterns matching the names of the host variables to redact, case-insensitive (e.g. '*token*'). Redaction is disabled if empty.
+			Keys []string `mapstructure:"keys"`
+			// Redaction mode: 'mask' (replace the values with the mask) or 'drop' (remove the variables).
+			Mode string `mapstructure:"mode" default:"mask"`
+			// Value replacing the values of redacted host variables in the 'mask' mode.
+			Mask string `mapstructure:"mask" default:"[redacted]"`
+		} `mapstructure:"redact"`
+		// Host record history ('-history' mode) configuration.
+		History struct {
+			// Glob pattern matching inventory snapshots that are used instead of the datasource history: 'attrs' exports in YAML or JSON or protobuf snapshots ('.pb' files).
+			// Snapshots are ordered by modification time.
+			Snapshots string `mapstructure:"snapshots"`
+		} `mapstructure:"history"`
+		// Bulk import configuration.
+		Import struct {
+			// Number of hosts published in a single step.
+			Batch int `mapstructure:"batch" default:"100"`
+			// Maximum number of hosts published per second. Set to 0 to disable rate limiting.
+			Rate float64 `mapstructure:"rate" default:"0"`
+			// Number of attempts made to publish a single step.
+			Attempts int `mapstructure:"attempts" default:"3"`
+			// Delay between attempts.
+			Backoff time.Duration `mapstructure:"backoff" default:"1s"`
+			// Refuse to import hosts whose records fail the lint checks (see LintRecords).
+			Lint bool `mapstructure:"lint" default:"false"`
+			// Conversion of Ansible inventories ('ansible-inventory --list' output) into host records.
+			Ansible struct {
+				// Host variables holding attribute values, keyed by attribute key (as set in 'txt.keys').
+				// By default, attribute values are taken from host variables named after the attribute keys in lower case (e.g. 'os').
+				Keys map[string]string `mapstructure:"keys"`
+				// Attribute values used for hosts that lack the corresponding host variables, keyed by attribute key.
+				Defaults map[string]string `mapstructure:"defaults"`
+				// Convert the remaining scalar host variables into the host variables attribute.
+				Vars bool `mapstructure:"vars" default:"true"`
+			} `mapstructure:"ansible"`
+		} `mapstructure:"import"`
+		// Portable bundle configuration ('-bundle export' and '-bundle import').
+		Bundle struct {
+			// Base64-encoded HMAC-SHA256 key used to sign bundles on export and verify them on import.
+			Key string `mapstructure:"key" default:""`
+			// File holding the base64-encoded signing key. Used if the key is not set.
+			Path string `mapstructure:"path" default:""`
+			// Compression level from 1 (fastest) to 9 (best compression).
+			Level int `mapstructure:"level" default:"9"`
+		} `mapstructure:"bundle"`
+		// Server mode configuration.
+		Server struct {
+			// Address to listen on. Ignored if a socket is passed by systemd socket activation.
+			Listen string `mapstructure:"listen" default:"127.0.0.1:8080"`
+			// Inventory refresh interval. Set to 0 to disable periodic refreshes.
+			Refresh time.Duration `mapstructure:"refresh" default:"5m"`
+			// Timeout for reading request headers and writing responses.
+			Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+			// Response compression configuration.
+			Compression struct {
+				// Compress responses if the client accepts a supported content coding (gzip, zstd).
+				Enabled bool `mapstructure:"enabled" default:"true"`
+				// Minimum size of a response to compress.
+				MinSize ByteSize `mapstructure:"minsize" default:"1024"`
+				// Compression level from 1 (fastest) to 9 (best compression).
+				Level int `mapstructure:"level" default:"6"`
+			} `mapstructure:"compression"`
+			// Leader election configuration for redundant server instances.
+			// Only the leader performs tasks with side effects, while all instances serve inventory data.
+			Election struct {
+				// Enable leader election using the etcd cluster configured in the 'etcd' section.
+				Enabled bool `mapstructure:"enabled" default:"false"`
+				// Election key, relative to the etcd k/v path prefix.
+				Key string `mapstructure:"key" default:"_election"`
+				// Leadership lease TTL. A failed leader is replaced after this interval.
+				TTL time.Duration `mapstructure:"ttl" default:"15s"`
+			} `mapstructure:"election"`
+			// Host editing API configuration.
+			API struct {
+				// Enable the 'PUT /hosts/<name>' and 'DELETE /hosts/<name>' endpoints.
+				Enabled bool `mapstructure:"enabled" default:"false"`
+				// Bearer tokens accepted by the host editing and refresh endpoints in addition to the identities of the authentication providers.
+				Tokens []string `mapstructure:"tokens"`
+				// Maximum size of a request body.
+				MaxBody ByteSize `mapstructure:"maxbody" default:"1048576"`
+			} `mapstructure:"api"`
+			// Authentication configuration of the inventory data and host editing endpoints.
+			Auth struct {
+				// Authentication providers tried in order: 'static', 'oidc'.
+				Providers []string `mapstructure:"providers"`
+				// Permissions of requests without a bearer token: 'read' (inventory data endpoints), 'edit' (host editing API).
+				Anonymous []string `mapstructure:"anonymous" default:"[\"read\"]"`
+				// Permissions granted to the groups of authenticated identities.
+				Groups []AuthGroupSpec `mapstructure:"groups"`
+				// Static token provider configuration.
+				Static struct {
+					// Named bearer tokens.
+					Tokens []AuthTokenSpec `mapstructure:"tokens"`
+				} `mapstructure:"static"`
+				// OpenID Connect provider configuration. Bearer tokens are JWTs signed by the identity provider (ID tokens or JWT access tokens).
+				OIDC struct {
+					// Issuer URL. Signing keys are discovered from '<issuer>/.well-known/openid-configuration'.
+					Issuer string `mapstructure:"issuer" default:""`
+					// Expected token audience, usually the client ID.
+					Audience string `mapstructure:"audience" default:""`
+					// Claim holding the identity name.
+					Username string `mapstructure:"username" default:"preferred_username"`
+					// Claim holding the identity groups. Nested claims are referenced with dots, e.g. 'realm_access.roles'.
+					Groups string `mapstructure:"groups" default:"groups"`
+					// CA certificate file used to verify the identity provider. System CA certificates are used if empty.
+					CA string `mapstructure:"ca" default:""`
+					// Identity provider request timeout.
+					Timeout time.Duration `mapstructure:"timeout" default:"10s"`
+					// Signing key refresh interval. Keys are also refreshed when a token is signed with an unknown key.
+					Refresh time.Duration `mapstructure:"refresh" default:"1h"`
+					// Allowed clock skew when checking token expiration.
+					Leeway time.Duration `mapstructure:"leeway" default:"1m"`
+				} `mapstructure:"oidc"`
+			} `mapstructure:"auth"`
+			// Credentials used by the '-refresh-zone' mode to authenticate to the inventory server, tried in order.
+			// The first of the host editing API tokens is used if none are configured.
+			Client struct {
+				// Bearer token.
+				Token string `mapstructure:"token" default:""`
+				// Path to a file holding a bearer token, read on every request (e.g. a token file kept fresh by an OIDC agent).
+				TokenPath string `mapstructure:"tokenpath" default:""`
+				// Client ID used to request an access token from the OIDC issuer with the client credentials grant.
+				ClientID string `mapstructure:"clientid" default:""`
+				// Client secret used with the client credentials grant.
+				ClientSecret string `mapstructure:"clientsecret" default:""`
+				// Scopes requested with the client credentials grant.
+				Scopes []string `mapstructure:"scopes"`
+			} `mapstructure:"client"`
+			// Web UI configuration.
+			UI struct {
+				// Serve the web UI at '/ui/'. Editing is available in the UI if the host editing API is enabled.
+				Enabled bool `mapstructure:"enabled" default:"false"`
+			} `mapstructure:"ui"`
+			// DNS responder configuration.
+			DNS struct {
+				// Address to serve inventory data over DNS on (UDP and TCP). Leave empty to disable the DNS responder.
+				Listen string `mapstructure:"listen" default:""`
+				// Zone under which group membership records are served: '<group>.<zone>'.
+				Zone string `mapstructure:"zone" default:"groups.inventory."`
+				// TTL of the records served.
+				TTL time.Duration `mapstructure:"ttl" default:"60s"`
+			} `mapstructure:"dns"`
+		} `mapstructure:"server"`
+		// Audit log configuration.
+		Audit struct {
+			// Record every write operation in the audit log. The inventory cannot be created if the audit log cannot be opened.
+			Enabled bool `mapstructure:"enabled" default:"false"`
+			// Audit log output: 'file' or 'syslog'.
+			Output string `mapstructure:"output" default:"file"`
+			// Audit log file path. Entries are appended to the file as JSON lines.
+			File string `mapstructure:"file" default:"dns-inventory-audit.log"`
+			// Syslog output configuration.
+			Syslog struct {
+				// Network of the syslog server: 'udp', 'tcp' or 'unixgram'.
+				Network string `mapstructure:"network" default:"unixgram"`
+				// Address of the syslog server.
+				Address string `mapstructure:"address" default:"/dev/log"`
+				// Syslog facility: 'user', 'auth', 'authpriv', 'daemon' or 'local0' to 'local7'.
+				Facility string `mapstructure:"facility" default:"authpriv"`
+				// Syslog tag.
+				Tag string `mapstructure:"tag" default:"ansible-dns-inventory"`
+			} `mapstructure:"syslog"`
+			// Record the attributes of the affected hosts before the operation. Requires reading host records from the datasource before every write.
+			Before bool `mapstructure:"before" default:"true"`
+		} `mapstructure:"audit"`
+		// Notification configuration.
+		Notify struct {
+			// Enable notifications.
+			Enabled bool `mapstructure:"enabled" default:"false"`
+			// Notification sinks.
+			Sinks []NotifySpec `mapstructure:"sinks"`
+		} `mapstructure:"notify"`
+		// Split export mode ('-split') configuration. An inventory file is written for every value of a host attribute.
+		Split struct {
+			// Host attribute key whose values select the inventory files of the hosts, e.g. 'ENV' or 'ROLE'.
+			Key string `mapstructure:"key" default:"ENV"`
+			// Inventory file format: any format supported by the '-tree' export mode.
+			Format string `mapstructure:"format" default:"ansible-yaml"`
+			// Inventory file name pattern, '%s' is replaced with the host attribute value.
+			Name string `mapstructure:"name" default:"%s.yml"`
+			// Remove files matching the file name pattern that have not been written by the export, e.g. of environments that no longer exist.
+			Prune bool `mapstructure:"prune" default:"false"`
+		} `mapstructure:"split"`
+		// Scheduled export mode configuration.
+		Cron struct {
+			// Exports written on every scheduled run.
+			Exports []ExportSpec `mapstructure:"exports"`
+			// Leader election configuration for redundant scheduled export instances. Only the leader writes the exports.
+			Election struct {
+				// Enable leader election using the etcd cluster configured in the 'etcd' section.
+				Enabled bool `mapstructure:"enabled" default:"false"`
+				// Election key, relative to the etcd k/v path prefix. Must differ from the server election key.
+				Key string `mapstructure:"key" default:"_cron_election"`
+				// Leadership lease TTL. A failed leader is replaced after this interval.
+				TTL time.Duration `mapstructure:"ttl" default:"15s"`
+			} `mapstructure:"election"`
+		} `mapstructure:"cron"`
 	}
 
 	// Datasource provides an interface for all supported datasources.
@@ -156,12 +1249,108 @@ type (
 		Close()
 	}
 
+	// IncrementalDatasource is implemented by datasources that can publish host records in several steps.
+	IncrementalDatasource interface {
+		// ClearRecords removes existing host records if the datasource is configured to do so before publishing.
+		ClearRecords() error
+		// PutRecords writes host records to the datasource without removing existing records.
+		PutRecords(records []*DatasourceRecord) error
+	}
+
+	// RenamingDatasource is implemented by datasources that can move host records to a new hostname.
+	RenamingDatasource interface {
+		// RenameHost moves all records of a host to a new hostname, removing the old records.
+		RenameHost(from string, to string) error
+	}
+
+	// ReencryptingDatasource is implemented by datasources that can encrypt their host records with a new key.
+	ReencryptingDatasource interface {
+		// ReencryptRecords encrypts all host records with the current encryption key and reports the keys they have been encrypted with.
+		ReencryptRecords(dryRun bool) (*Reencryption, error)
+	}
+
+	// EditingDatasource is implemented by datasources that can replace the records of a single host.
+	EditingDatasource interface {
+		// ReplaceHost replaces all records of a host with the given records, removing the host if there are none.
+		ReplaceHost(host string, records []*DatasourceRecord) error
+	}
+
+	// GroupVarsDatasource is implemented by datasources that can store group variables.
+	GroupVarsDatasource interface {
+		// GetGroupVariables returns group variables, keyed by group name.
+		GetGroupVariables() (map[string]map[string]interface{}, error)
+	}
+
+	// HistoryDatasource is implemented by datasources that keep the history of host records.
+	HistoryDatasource interface {
+		// GetHostHistory returns the states of the records of a specific host, oldest first.
+		GetHostHistory(host string) ([]*HostRevision, error)
+	}
+
+	// DescribedDatasource is implemented by datasources that can describe the source data they have read.
+	DescribedDatasource interface {
+		// Metadata returns datasource-specific details of the data read by the last GetAllRecords call (e.g. zone serials).
+		Metadata() map[string]interface{}
+	}
+
+	// PartialDatasource is implemented by datasources that skip the zones they fail to read.
+	PartialDatasource interface {
+		// FailedZones returns the zones skipped by the last GetAllRecords call.
+		FailedZones() []string
+	}
+
+	// ZoneDatasource is implemented by datasources that read host records zone by zone and can read a single zone.
+	ZoneDatasource interface {
+		// GetZoneRecords returns all host records of a specific zone.
+		GetZoneRecords(zone string) ([]*DatasourceRecord, error)
+		// HostZone returns the zone the records of a host are read from, or an empty string if the host does not belong to any of the zones.
+		HostZone(host string) string
+	}
+
+	// Varsource provides an interface for all supported secondary host variable sources.
+	Varsource interface {
+		// GetHostVariables returns additional variables for a specific host.
+		GetHostVariables(host string) (map[string]string, error)
+		// Close closes variable source clients and performs other housekeeping.
+		Close()
+	}
+
 	// DatasourceRecord represents a single host record returned by a datasource.
 	DatasourceRecord struct {
 		// Host name.
-		Hostname string
+		Hostname string `json:"hostname" yaml:"hostname"`
 		// Host attributes.
-		Attributes string
+		Attributes string `json:"attributes" yaml:"attributes"`
+		// Location of the record in the datasource (e.g. a DNS owner name or an etcd key), if known.
+		Source string `json:"source,omitempty" yaml:"source,omitempty"`
+	}
+
+	// DatasourceError is returned if host records cannot be acquired from the datasource, as opposed to invalid host records or configuration.
+	DatasourceError struct {
+		// Datasource failure.
+		Err error
+	}
+
+	// ZoneFailureError is returned by datasources reading host records zone by zone if no zone or more zones than allowed by 'fallback.maxfailedzones' could be read.
+	ZoneFailureError struct {
+		// Zones that could not be read.
+		Zones []string
+		// Total number of zones.
+		Total int
+	}
+
+	// BuildInfo represents ansible-dns-inventory version and build information.
+	BuildInfo struct {
+		// Release version, set at build time or derived from the module version.
+		Version string `json:"version" yaml:"version"`
+		// Build time.
+		Time string `json:"time" yaml:"time"`
+		// VCS revision.
+		Revision string `json:"revision" yaml:"revision"`
+		// VCS working tree had uncommitted changes at build time.
+		Dirty bool `json:"dirty" yaml:"dirty"`
+		// Go toolchain version.
+		GoVersion string `json:"go" yaml:"go"`
 	}
 
 	// Logger provides a logging interface for the inventory and its datasources.
@@ -195,6 +1384,214 @@ type (
 		Values []string
 	}
 
+	// VarsourceSpec represents a secondary host variable source specification.
+	VarsourceSpec struct {
+		// Variable source type.
+		// Allowed values:
+		// txt: TXT records of a name constructed by prepending Path to the hostname.
+		// etcd: keys under the '<Path>/<hostname>/' prefix of the etcd datasource namespace.
+		// http: a JSON object returned by a GET request to the URL in Path ('{host}' is replaced with the hostname).
+		// consul: keys under the '<Path>/<hostname>/' prefix of the Consul KV store at Address.
+		// dhcp-isc, dhcp-kea: the address of an active lease for the host found in the ISC DHCP or Kea (memfile) lease file in Path.
+		Type string
+		// Source-specific location of host variables.
+		Path string
+		// Source address (consul).
+		Address string
+		// Authentication token (http, consul).
+		Token string
+		// Network timeout for variable source requests (10s if not set).
+		Timeout time.Duration
+	}
+
+	// HookSpec represents an event hook specification.
+	HookSpec struct {
+		// Command and its arguments. The event payload is passed to the command as JSON via stdin.
+		Command []string
+		// Command execution timeout (30s if not set).
+		Timeout time.Duration
+	}
+
+	// CompositeRuleSpec represents the conflict resolution of the hosts matching a pattern.
+	CompositeRuleSpec struct {
+		// Hostname glob pattern, e.g. 'db*.infra.local'.
+		Hosts string
+		// Conflict resolution: 'merge', 'first', 'last' or a datasource type.
+		Conflicts string
+	}
+
+	// ZonefileSpec represents the zone file of a specific zone.
+	ZonefileSpec struct {
+		// Zone name.
+		Zone string
+		// Zone file path or HTTP(S) URL.
+		Source string
+	}
+
+	// TransformSpec represents an external tree transform command.
+	TransformSpec struct {
+		// Command and its arguments. The tree is passed to the command as JSON via stdin and the command must print the resulting tree to stdout.
+		Command []string
+		// Command execution timeout (30s if not set).
+		Timeout time.Duration
+	}
+
+	// NotransferSpec represents the no-transfer hosts of a specific zone.
+	NotransferSpec struct {
+		// Zone name.
+		Zone string
+		// Hosts whose TXT records contain inventory data for this zone.
+		Hosts []string
+	}
+
+	// Timeouts represents per-stage network timeouts of a datasource. Stages set to 0 use the datasource 'timeout'.
+	Timeouts struct {
+		// Connection timeout.
+		Dial time.Duration `mapstructure:"dial" default:"0s"`
+		// Timeout for reading a DNS response or for an etcd read request.
+		Read time.Duration `mapstructure:"read" default:"0s"`
+		// Timeout for writing a DNS request or for an etcd write transaction.
+		Write time.Duration `mapstructure:"write" default:"0s"`
+	}
+
+	// ExportSpec represents a single export written by the scheduled export mode.
+	ExportSpec struct {
+		// Exported data: 'list', 'hosts', 'groups', 'attrs' or 'tree'.
+		Export string
+		// Export format ('yaml' if not set).
+		Format string
+		// Path of a file to write the export to. The file is replaced atomically.
+		Path string
+		// URL to push the export to with a POST request.
+		URL string
+		// Push request timeout (30s if not set).
+		Timeout time.Duration
+	}
+
+	// AuthGroupSpec maps a group of authenticated identities to server permissions.
+	AuthGroupSpec struct {
+		// Group name, matched exactly.
+		Group string
+		// Permissions granted to the members: 'read', 'edit'.
+		Permissions []string
+	}
+
+	// AuthTokenSpec represents a named bearer token of the static token authentication provider.
+	AuthTokenSpec struct {
+		// Identity name, recorded as the actor in the audit log.
+		Name string
+		// Bearer token.
+		Token string
+		// Groups of the identity.
+		Groups []string
+	}
+
+	// NotifySpec represents a notification sink specification.
+	NotifySpec struct {
+		// Sink type: 'slack', 'pagerduty', 'email' or 'webhook'.
+		Type string
+		// Events sent to this sink: 'publish', 'change'. All events are sent if empty.
+		Events []string
+		// Message templates (Go text/template), keyed by event. Default messages are used for events not listed here.
+		Templates map[string]string
+		// Slack incoming webhook URL, webhook URL or PagerDuty Events API URL (optional).
+		URL string
+		// PagerDuty routing key.
+		Key string
+		// PagerDuty event severity ('warning' if not set).
+		Severity string
+		// SMTP server address (host:port).
+		SMTP string
+		// SMTP username. Authentication is not used if empty.
+		Username string
+		// SMTP password.
+		Password string
+		// Email sender address.
+		From string
+		// Email recipient addresses.
+		To []string
+		// Request timeout (10s if not set).
+		Timeout time.Duration
+	}
+
+	// NotifyEvent represents a notification event. It is passed to message templates and sent to webhook sinks as JSON.
+	NotifyEvent struct {
+		// Event: 'publish' or 'change'.
+		Event string `json:"event"`
+		// Datasource type.
+		Datasource string `json:"datasource"`
+		// Event time.
+		Timestamp time.Time `json:"timestamp"`
+		// Operation that published host records, e.g. 'import' ('publish' events only).
+		Operation string `json:"operation,omitempty"`
+		// Number of host records published ('publish' events only).
+		Records int `json:"records,omitempty"`
+		// Publishing error, if any ('publish' events only).
+		Error string `json:"error,omitempty"`
+		// Number of hosts in the inventory ('change' events only).
+		Hosts int `json:"hosts,omitempty"`
+		// Hosts that have appeared ('change' events only).
+		Added []string `json:"added,omitempty"`
+		// Hosts that have disappeared ('change' events only).
+		Removed []string `json:"removed,omitempty"`
+		// Environments of the hosts that have disappeared ('change' events only).
+		Envs []string `json:"envs,omitempty"`
+		// Rendered message (webhook sinks only).
+		Message string `json:"message,omitempty"`
+	}
+
+	// HookEvent represents an event hook payload.
+	HookEvent struct {
+		// Hook point: 'prepublish' or 'postpublish'.
+		Hook string `json:"hook"`
+		// Operation that triggered the event, e.g. 'import'.
+		Operation string `json:"operation"`
+		// Datasource type.
+		Datasource string `json:"datasource"`
+		// Host records being published.
+		Records []*DatasourceRecord `json:"records"`
+		// Hosts whose records are removed ('delete' and 'rename' operations only).
+		Hosts []string `json:"hosts,omitempty"`
+		// Actor performing the operation.
+		Actor string `json:"actor,omitempty"`
+		// Network address of the actor (server mode only).
+		Address string `json:"address,omitempty"`
+		// Publishing error (postpublish only).
+		Error string `json:"error,omitempty"`
+	}
+
+	// Actor identifies who performs a write operation.
+	Actor struct {
+		// Actor name, e.g. a system user name.
+		Name string
+		// Network address of the actor (server mode only).
+		Address string
+	}
+
+	// AuditEntry represents a single write operation recorded in the audit log.
+	AuditEntry struct {
+		// Time of the operation.
+		Time time.Time `json:"time"`
+		// Actor performing the operation.
+		Actor string `json:"actor"`
+		// Network address of the actor (server mode only).
+		Address string `json:"address,omitempty"`
+		// Operation, e.g. 'import' or 'edit'.
+		Operation string `json:"operation"`
+		// Datasource type.
+		Datasource string `json:"datasource"`
+		// Hosts affected by the operation.
+		Hosts []string `json:"hosts"`
+		// Host attribute strings before the operation, keyed by hostname.
+		Before map[string][]string `json:"before,omitempty"`
+		// Host attribute strings written by the operation, keyed by hostname. Hosts whose records are removed have no attribute strings.
+		After map[string][]string `json:"after"`
+		// Result: 'success', 'failure' or 'rejected' (by the publishing policy or a pre-publish hook).
+		Result string `json:"result"`
+		// Error message.
+		Error string `json:"error,omitempty"`
+	}
+
 	// HostAttributes represents host attributes found in TXT records.
 	HostAttributes struct {
 		// Host operating system identifier.
@@ -207,9 +1604,279 @@ type (
 		Srv string `validate:"safelistsep" yaml:"SRV"`
 		// Host variables
 		Vars string `validate:"printascii" yaml:"VARS"`
+		// Unique host identifier (optional).
+		ID string `validate:"omitempty,uuid" yaml:"ID,omitempty"`
+		// Extra attributes keyed by attribute key (optional).
+		Extra map[string]string `validate:"dive,omitempty,alphanum" yaml:",inline"`
+	}
+
+	// SeparatorMigration represents the result of a key separator migration.
+	SeparatorMigration struct {
+		// Number of host records processed.
+		Records int `json:"records" yaml:"records"`
+		// Number of host records that have been rewritten.
+		Changed int `json:"changed" yaml:"changed"`
+		// Host records that could not be parsed and were left as is.
+		Skipped []string `json:"skipped" yaml:"skipped"`
+		// Group names that change, mapped to their new names.
+		Groups map[string]string `json:"groups" yaml:"groups"`
+		// New group names that several old group names collapse into.
+		Conflicts map[string][]string `json:"conflicts" yaml:"conflicts"`
+	}
+
+	// Reencryption represents the results of re-encrypting host records with the current encryption key.
+	Reencryption struct {
+		// ID of the current encryption key.
+		Key string `json:"key" yaml:"key"`
+		// Number of values processed.
+		Records int `json:"records" yaml:"records"`
+		// Number of values that have been encrypted again.
+		Changed int `json:"changed" yaml:"changed"`
+		// Number of values per key ID they were encrypted with before: 'plaintext' for unencrypted values, 'v1' for values written without a key ID.
+		Keys map[string]int `json:"keys" yaml:"keys"`
+	}
+
+	// LintProblem represents a host record that violates a constraint of DNS TXT records.
+	LintProblem struct {
+		// Hostname.
+		Host string `json:"host" yaml:"host"`
+		// Host attribute string, empty for problems concerning all records of a host.
+		Record string `json:"record,omitempty" yaml:"record,omitempty"`
+		// Problem severity: 'error' for records that cannot be published or read back intact, 'warning' otherwise.
+		Severity string `json:"severity" yaml:"severity"`
+		// Failed check: 'hostname', 'string-length', 'rrset-size' or 'attributes'.
+		Check string `json:"check" yaml:"check"`
+		// Problem description.
+		Message string `json:"message" yaml:"message"`
+	}
+
+	// HostConflict represents a host variable or attribute whose sources disagree.
+	HostConflict struct {
+		// Hostname.
+		Host string `json:"host" yaml:"host"`
+		// Host variable or attribute key.
+		Key string `json:"key" yaml:"key"`
+		// Conflicting values in order of precedence, lowest first.
+		Values []*ConflictValue `json:"values" yaml:"values"`
+		// How the conflict has been resolved.
+		Resolution string `json:"resolution" yaml:"resolution"`
+	}
+
+	// ConflictValue represents a value provided by a single source.
+	ConflictValue struct {
+		// Value source: a host record or a secondary variable source.
+		Source string `json:"source" yaml:"source"`
+		// Value.
+		Value string `json:"value" yaml:"value"`
+	}
+
+	// PolicyViolation represents a host record that violates the publishing policy.
+	PolicyViolation struct {
+		// Hostname.
+		Host string `json:"host" yaml:"host"`
+		// Violated rule: 'roles', 'vars' or 'exclusive'.
+		Rule string `json:"rule" yaml:"rule"`
+		// Violation details.
+		Message string `json:"message" yaml:"message"`
+	}
+
+	// PolicyError is returned if publishing has been refused because of policy violations.
+	PolicyError struct {
+		// Policy violations.
+		Violations []*PolicyViolation
+	}
+
+	// HostEditError is returned if a host cannot be edited because of an invalid hostname or invalid host attributes.
+	HostEditError struct {
+		// Host name.
+		Host string
+		// Validation error.
+		Err error
+	}
+
+	// ZoneError is returned if a zone cannot be refreshed because it is unknown or the datasource cannot read single zones.
+	ZoneError struct {
+		// Zone name.
+		Zone string
+		// Error.
+		Err error
+	}
+
+	// HostRevision represents the records of a host at a specific point of the datasource history.
+	HostRevision struct {
+		// Datasource revision.
+		Revision int64
+		// Host records, empty if all records have been removed.
+		Records []*DatasourceRecord
+	}
+
+	// HostChange represents a single change of the records of a host.
+	HostChange struct {
+		// Etcd revision of the change.
+		Revision int64 `json:"revision,omitempty" yaml:"revision,omitempty"`
+		// Snapshot file the change has first been seen in.
+		Snapshot string `json:"snapshot,omitempty" yaml:"snapshot,omitempty"`
+		// Modification time of the snapshot file.
+		Timestamp *time.Time `json:"timestamp,omitempty" yaml:"timestamp,omitempty"`
+		// Host record attributes after the change.
+		Records []map[string]string `json:"records" yaml:"records"`
+		// Changed attributes: '+' for added, '-' for removed and '~' for modified ones.
+		Diff []string `json:"diff" yaml:"diff"`
+	}
+
+	// InventoryComparison represents the differences between two inventories, from this inventory to the other one.
+	InventoryComparison struct {
+		// Hosts only found in the other inventory.
+		AddedHosts []string `json:"added_hosts" yaml:"added_hosts"`
+		// Hosts only found in this inventory.
+		RemovedHosts []string `json:"removed_hosts" yaml:"removed_hosts"`
+		// Groups only found in the other inventory.
+		AddedGroups []string `json:"added_groups" yaml:"added_groups"`
+		// Groups only found in this inventory.
+		RemovedGroups []string `json:"removed_groups" yaml:"removed_groups"`
+		// Membership changes of the groups found in both inventories, by group. Hosts of child groups are members as well.
+		Members map[string]*MembershipChange `json:"members" yaml:"members"`
+		// Variable changes of the groups found in both inventories, by group: '+' for added, '-' for removed and '~' for modified variables.
+		GroupVars map[string][]string `json:"group_vars" yaml:"group_vars"`
+		// Variable changes of the hosts found in both inventories, by host. Only compared if host variables are enabled in either inventory.
+		HostVars map[string][]string `json:"host_vars" yaml:"host_vars"`
+	}
+
+	// MembershipChange represents the membership changes of a group.
+	MembershipChange struct {
+		// Hosts only found in the group in the other inventory.
+		Added []string `json:"added" yaml:"added"`
+		// Hosts only found in the group in this inventory.
+		Removed []string `json:"removed" yaml:"removed"`
+	}
+
+	// HostRename represents the result of a host rename.
+	HostRename struct {
+		// Old hostname.
+		From string `json:"from" yaml:"from"`
+		// New hostname.
+		To string `json:"to" yaml:"to"`
+		// Number of host records moved.
+		Records int `json:"records" yaml:"records"`
+		// Unique host identifiers carried over to the new hostname.
+		IDs []string `json:"ids" yaml:"ids"`
+	}
+
+	// ImportReport represents the results of a bulk import.
+	ImportReport struct {
+		// Number of hosts to import.
+		Hosts int `json:"hosts" yaml:"hosts"`
+		// Number of hosts skipped because they have been imported by a previous run.
+		Resumed int `json:"resumed" yaml:"resumed"`
+		// Number of hosts that have been published.
+		Published int `json:"published" yaml:"published"`
+		// Hosts that could not be published, mapped to the errors encountered.
+		Failed map[string]string `json:"failed" yaml:"failed"`
+	}
+
+	// BenchmarkReport represents the result of a datasource benchmark.
+	BenchmarkReport struct {
+		// Datasource type.
+		Datasource string `json:"datasource" yaml:"datasource"`
+		// Number of benchmark runs.
+		Iterations int `json:"iterations" yaml:"iterations"`
+		// Number of host records acquired by the last run.
+		Records int `json:"records" yaml:"records"`
+		// Number of hosts acquired by the last run.
+		Hosts int `json:"hosts" yaml:"hosts"`
+		// Host record acquisition times.
+		Acquire *BenchmarkStage `json:"acquire" yaml:"acquire"`
+		// Inventory build times.
+		Build *BenchmarkStage `json:"build" yaml:"build"`
+		// Average end-to-end throughput.
+		HostsPerSecond float64 `json:"hosts_per_second" yaml:"hosts_per_second"`
+	}
+
+	// BenchmarkStage represents the durations of a benchmark stage.
+	BenchmarkStage struct {
+		Min string `json:"min" yaml:"min"`
+		Avg string `json:"avg" yaml:"avg"`
+		Max string `json:"max" yaml:"max"`
+	}
+
+	// BundleManifest describes the contents of a portable bundle.
+	BundleManifest struct {
+		// Bundle schema version.
+		Schema int `json:"schema" yaml:"schema"`
+		// Version of ansible-dns-inventory that has created the bundle.
+		Version string `json:"version" yaml:"version"`
+		// Time the bundle has been created at.
+		Created time.Time `json:"created" yaml:"created"`
+		// Type of the datasource the host records have been exported from.
+		Datasource string `json:"datasource" yaml:"datasource"`
+		// Fingerprint of the host record format configuration (the 'txt' section).
+		Fingerprint string `json:"fingerprint" yaml:"fingerprint"`
+		// Number of host records.
+		Records int `json:"records" yaml:"records"`
+		// Number of hosts.
+		Hosts int `json:"hosts" yaml:"hosts"`
+		// SHA-256 digest of the host records file.
+		Digest string `json:"digest" yaml:"digest"`
+	}
+
+	// InventoryMetadata describes the source data of an inventory.
+	InventoryMetadata struct {
+		// Time the host records have been acquired at.
+		Timestamp time.Time `json:"timestamp" yaml:"timestamp"`
+		// Datasource type.
+		Datasource string `json:"datasource" yaml:"datasource"`
+		// Fallback the host records have been acquired from if the datasource failed: a fallback datasource type or 'cache'.
+		Fallback string `json:"fallback,omitempty" yaml:"fallback,omitempty"`
+		// Number of host records acquired.
+		Records int `json:"records" yaml:"records"`
+		// Number of hosts in the inventory.
+		Hosts int `json:"hosts" yaml:"hosts"`
+		// Datasource-specific details, e.g. zone serials or the etcd revision.
+		Source map[string]interface{} `json:"source,omitempty" yaml:"source,omitempty"`
+	}
+
+	// HostService represents a network service provided by a host.
+	HostService struct {
+		// Service name.
+		Name string `json:"name" yaml:"name"`
+		// Service port.
+		Port int `json:"port" yaml:"port"`
+		// Service protocol.
+		Protocol string `json:"protocol" yaml:"protocol"`
+	}
+
+	// Renderer converts hosts and their attributes into the target list of another configuration management tool.
+	Renderer struct {
+		// Target list encoding: 'yaml' or 'json'.
+		Encoding string
+		// Produces the target list.
+		Render func(hosts map[string][]*HostAttributes, cfg *Config) (interface{}, error)
+	}
+
+	// SaltRosterTarget represents a salt-ssh roster entry.
+	SaltRosterTarget struct {
+		// Host address.
+		Host string `json:"host" yaml:"host"`
+		// SSH user.
+		User string `json:"user,omitempty" yaml:"user,omitempty"`
+		// SSH port.
+		Port int `json:"port,omitempty" yaml:"port,omitempty"`
+		// Minion options, including grains.
+		MinionOpts map[string]interface{} `json:"minion_opts,omitempty" yaml:"minion_opts,omitempty"`
+	}
+
+	// ChefNode represents a Chef node definition for knife bootstrap.
+	ChefNode struct {
+		// Chef environment.
+		Environment string `json:"chef_environment" yaml:"chef_environment"`
+		// Node run list.
+		RunList []string `json:"run_list" yaml:"run_list"`
+		// Node attributes.
+		Attributes map[string]interface{} `json:"attributes" yaml:"attributes"`
 	}
 
 	// AnsibleGroup is an Ansible group ready to be marshalled into a JSON representation.
+	// encoding/json emits struct fields in declaration order, so the order of the fields below is part of the output format.
 	AnsibleGroup struct {
 		// Group chilren.
 		Children []string `json:"children,omitempty"`
@@ -223,6 +1890,8 @@ type (
 	Node struct {
 		// Group name.
 		Name string
+		// Position of the group among its siblings.
+		Order int
 		// Group parent
 		Parent *Node `json:"-" yaml:"-"`
 		// Group children.
@@ -231,17 +1900,25 @@ type (
 		Hosts map[string]bool
 		// Group variables.
 		Vars map[string]interface{}
+		// Host and group name comparison function, inherited by new children. Byte order is used if nil.
+		Compare func(a, b string) int `json:"-" yaml:"-"`
 	}
 
 	// ExportNode represents an inventory tree node for the tree export mode.
+	// Fields are emitted in declaration order and children are emitted in canonical order (see Node.sortedChildren).
 	ExportNode struct {
 		// Group name.
 		Name string `json:"name" yaml:"name"`
+		// Position of the group among its siblings.
+		Order int `json:"order" yaml:"order"`
 		// Group children.
 		Children []*Node `json:"children" yaml:"children"`
 		// Hosts belonging to this group.
 		Hosts []string `json:"hosts" yaml:"hosts"`
 		// Group variables.
-		Vars map[string]interface{} `json:"vars" yaml:"vars"`
+		Vars map[string]interface{} `json:"vars,omitempty" yaml:"vars,omitempty"`
 	}
+
+	// ByteSize is a size in bytes. In the configuration, sizes are numbers of bytes or numbers with a unit suffix (e.g. '4MiB').
+	ByteSize int
 )
@@ -0,0 +1,296 @@
+package inventory
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	etcdv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	// TXT variable source type.
+	TXTVarsourceType string = "txt"
+	// Etcd variable source type.
+	EtcdVarsourceType string = "etcd"
+	// HTTP variable source type.
+	HTTPVarsourceType string = "http"
+	// Consul variable source type.
+	ConsulVarsourceType string = "consul"
+	// Default network timeout for variable source requests.
+	varsourceDefaultTimeout time.Duration = 10 * time.Second
+	// Hostname placeholder used in variable source URLs.
+	varsourceHostPlaceholder string = "{host}"
+)
+
+type (
+	// TXTVarsource implements a variable source that reads TXT records of a secondary name.
+	TXTVarsource struct {
+		// Inventory configuration.
+		Config *Config
+		// Variable source specification.
+		Spec VarsourceSpec
+		// DNS client.
+		Client *dns.Client
+	}
+
+	// EtcdVarsource implements a variable source that reads an etcd subtree.
+	EtcdVarsource struct {
+		// Inventory configuration.
+		Config *Config
+		// Variable source specification.
+		Spec VarsourceSpec
+		// Etcd client.
+		Client *etcdv3.Client
+	}
+
+	// HTTPVarsource implements a variable source that reads a JSON object from an HTTP endpoint.
+	HTTPVarsource struct {
+		// Variable source specification.
+		Spec VarsourceSpec
+		// HTTP client.
+		Client *http.Client
+	}
+
+	// ConsulVarsource implements a variable source that reads a Consul KV subtree.
+	ConsulVarsource struct {
+		// Variable source specification.
+		Spec VarsourceSpec
+		// HTTP client.
+		Client *http.Client
+	}
+
+	// consulKV represents a single key/value pair returned by the Consul KV API.
+	consulKV struct {
+		Key   string
+		Value string
+	}
+)
+
+// parseVariables parses a host variables string into a map.
+func parseVariables(vars string, sep string, eq string, variables map[string]string) {
+	if len(vars) == 0 {
+		return
+	}
+
+	for _, p := range strings.Split(vars, sep) {
+		kv := strings.Split(p, eq)
+		if len(kv) == 2 {
+			variables[kv[0]] = kv[1]
+		}
+	}
+}
+
+// GetHostVariables acquires host variables from TXT records of the '<path>.<host>' name.
+func (v *TXTVarsource) GetHostVariables(host string) (map[string]string, error) {
+	cfg := v.Config
+	variables := make(map[string]string)
+
+	msg := newDNSQuery(cfg, dns.Fqdn(strings.Trim(v.Spec.Path, ".")+"."+strings.Trim(host, ".")), dns.TypeTXT)
+
+	rx, _, err := v.Client.Exchange(msg, cfg.DNS.Server)
+	if err != nil {
+		return nil, errors.Wrap(err, "dns request failed")
+	}
+
+	for _, rr := range rx.Answer {
+		if rr.Header().Rrtype == dnsRrTxtType {
+			parseVariables(dns.Field(rr, dnsRrTxtField), cfg.Txt.Vars.Separator, cfg.Txt.Vars.Equalsign, variables)
+		}
+	}
+
+	return variables, nil
+}
+
+// Close does nothing: queries are sent with the DNS client of the variable source, which keeps no connections open.
+func (v *TXTVarsource) Close() {}
+
+// GetHostVariables acquires host variables from keys under the '<path>/<host>/' prefix.
+func (v *EtcdVarsource) GetHostVariables(host string) (map[string]string, error) {
+	variables := make(map[string]string)
+	prefix := strings.Trim(v.Spec.Path, "/") + "/" + host + "/"
+
+	ctx, cancel := context.WithTimeout(context.Background(), v.Spec.Timeout)
+	resp, err := v.Client.Get(ctx, prefix, etcdv3.WithPrefix())
+	cancel()
+	if err != nil {
+		return nil, errors.Wrap(err, "etcd request failure")
+	}
+
+	for _, kv := range resp.Kvs {
+		variables[strings.TrimPrefix(string(kv.Key), prefix)] = string(kv.Value)
+	}
+
+	return variables, nil
+}
+
+// Close closes the etcd client of the variable source.
+func (v *EtcdVarsource) Close() {
+	v.Client.Close()
+}
+
+// GetHostVariables acquires host variables from a JSON object returned by an HTTP endpoint.
+func (v *HTTPVarsource) GetHostVariables(host string) (map[string]string, error) {
+	variables := make(map[string]string)
+	values := make(map[string]interface{})
+
+	req, err := http.NewRequest(http.MethodGet, strings.ReplaceAll(v.Spec.Path, varsourceHostPlaceholder, url.PathEscape(host)), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request failure")
+	}
+
+	if len(v.Spec.Token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+v.Spec.Token)
+	}
+
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request failure")
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return variables, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, errors.Errorf("http request failure: %s", resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&values); err != nil {
+		return nil, errors.Wrap(err, "http response parsing failure")
+	}
+
+	for key, value := range values {
+		variables[key] = fmt.Sprint(value)
+	}
+
+	return variables, nil
+}
+
+// Close closes idle connections to the variable endpoint.
+func (v *HTTPVarsource) Close() {
+	v.Client.CloseIdleConnections()
+}
+
+// GetHostVariables acquires host variables from keys under the '<path>/<host>/' prefix of the Consul KV store.
+func (v *ConsulVarsource) GetHostVariables(host string) (map[string]string, error) {
+	variables := make(map[string]string)
+	kvs := make([]consulKV, 0)
+	prefix := strings.Trim(v.Spec.Path, "/") + "/" + host + "/"
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(v.Spec.Address, "/")+"/v1/kv/"+prefix+"?recurse=true", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "consul request failure")
+	}
+
+	if len(v.Spec.Token) > 0 {
+		req.Header.Set("X-Consul-Token", v.Spec.Token)
+	}
+
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "consul request failure")
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return variables, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, errors.Errorf("consul request failure: %s", resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&kvs); err != nil {
+		return nil, errors.Wrap(err, "consul response parsing failure")
+	}
+
+	for _, kv := range kvs {
+		value, err := base64.StdEncoding.DecodeString(kv.Value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s: consul value decoding failure", kv.Key)
+		}
+
+		variables[strings.TrimPrefix(kv.Key, prefix)] = string(value)
+	}
+
+	return variables, nil
+}
+
+// Close closes idle connections to the Consul agent.
+func (v *ConsulVarsource) Close() {
+	v.Client.CloseIdleConnections()
+}
+
+// NewVarsource creates a variable source based on its specification.
+func NewVarsource(cfg *Config, spec VarsourceSpec) (Varsource, error) {
+	if spec.Timeout == 0 {
+		spec.Timeout = varsourceDefaultTimeout
+	}
+
+	switch strings.ToLower(spec.Type) {
+	case TXTVarsourceType:
+		return &TXTVarsource{
+			Config: cfg,
+			Spec:   spec,
+			Client: &dns.Client{Timeout: spec.Timeout, UDPSize: cfg.DNS.UDPSize},
+		}, nil
+	case EtcdVarsourceType:
+		client, err := NewEtcdClient(cfg)
+		if err != nil {
+			return nil, errors.Wrap(err, "etcd variable source initialization failure")
+		}
+
+		return &EtcdVarsource{
+			Config: cfg,
+			Spec:   spec,
+			Client: client,
+		}, nil
+	case HTTPVarsourceType:
+		return &HTTPVarsource{
+			Spec:   spec,
+			Client: &http.Client{Timeout: spec.Timeout},
+		}, nil
+	case ConsulVarsourceType:
+		return &ConsulVarsource{
+			Spec:   spec,
+			Client: &http.Client{Timeout: spec.Timeout},
+		}, nil
+	case ISCDHCPVarsourceType, KeaDHCPVarsourceType:
+		return &DHCPVarsource{
+			Spec: spec,
+		}, nil
+	default:
+		return nil, errors.Errorf("unknown variable source type: %s", spec.Type)
+	}
+}
+
+// NewVarsources creates all variable sources listed in the inventory configuration.
+func NewVarsources(cfg *Config) ([]Varsource, error) {
+	varsources := make([]Varsource, 0)
+
+	if !cfg.Varsources.Enabled {
+		return varsources, nil
+	}
+
+	for _, spec := range cfg.Varsources.Sources {
+		v, err := NewVarsource(cfg, spec)
+		if err != nil {
+			for _, vs := range varsources {
+				vs.Close()
+			}
+
+			return nil, err
+		}
+
+		varsources = append(varsources, v)
+	}
+
+	return varsources, nil
+}
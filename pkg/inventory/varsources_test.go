@@ -0,0 +1,464 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/creasty/defaults"
+	"github.com/miekg/dns"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testEtcdKV is an etcd KV service serving a fixed set of keys. Ranges under a 'fail' path are rejected.
+type testEtcdKV struct {
+	etcdserverpb.UnimplementedKVServer
+
+	kvs map[string]string
+}
+
+func (s *testEtcdKV) Range(ctx context.Context, req *etcdserverpb.RangeRequest) (*etcdserverpb.RangeResponse, error) {
+	if bytes.Contains(req.Key, []byte("/fail")) {
+		return nil, status.Error(codes.PermissionDenied, "etcdserver: permission denied")
+	}
+
+	resp := &etcdserverpb.RangeResponse{Header: &etcdserverpb.ResponseHeader{}}
+	for k, v := range s.kvs {
+		key := []byte(k)
+		if bytes.Equal(key, req.Key) || (len(req.RangeEnd) > 0 && bytes.Compare(key, req.Key) >= 0 && bytes.Compare(key, req.RangeEnd) < 0) {
+			resp.Kvs = append(resp.Kvs, &mvccpb.KeyValue{Key: key, Value: []byte(v)})
+		}
+	}
+	resp.Count = int64(len(resp.Kvs))
+
+	return resp, nil
+}
+
+// startTestEtcdServer starts an etcd KV service serving a fixed set of keys.
+func startTestEtcdServer(t *testing.T, kvs map[string]string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer()
+	etcdserverpb.RegisterKVServer(srv, &testEtcdKV{kvs: kvs})
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+
+	return ln.Addr().String()
+}
+
+// startTestVarsourceDNSServer starts a DNS server answering TXT queries with fixed records. Other names do not exist.
+func startTestVarsourceDNSServer(t *testing.T, records map[string][]string) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+
+		name := r.Question[0].Name
+		values, ok := records[name]
+		if !ok {
+			m.Rcode = dns.RcodeNameError
+		}
+		for _, v := range values {
+			m.Answer = append(m.Answer, &dns.TXT{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 60}, Txt: []string{v}})
+		}
+		if ok {
+			// Records of other types are ignored.
+			m.Answer = append(m.Answer, &dns.A{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.IPv4(10, 0, 0, 1)})
+		}
+
+		w.WriteMsg(m)
+	})}
+	go srv.ActivateAndServe()
+	t.Cleanup(func() { srv.Shutdown() })
+
+	return pc.LocalAddr().String()
+}
+
+// newTestVarsourceConfig returns the default configuration.
+func newTestVarsourceConfig(t *testing.T) *Config {
+	cfg := &Config{}
+	if err := defaults.Set(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	return cfg
+}
+
+func TestTXTVarsource_GetHostVariables(t *testing.T) {
+	cfg := newTestVarsourceConfig(t)
+	cfg.DNS.Server = startTestVarsourceDNSServer(t, map[string][]string{
+		"vars.app01.infra.local.": {"heap=4g,gc=g1", "user=app"},
+		"vars.db01.infra.local.":  {"invalid", "shared_buffers=1g,max_connections"},
+	})
+
+	tests := []struct {
+		name    string
+		path    string
+		host    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "valid", path: "vars", host: "app01.infra.local", want: map[string]string{"heap": "4g", "gc": "g1", "user": "app"}},
+		{name: "valid-dots", path: ".vars.", host: "app01.infra.local.", want: map[string]string{"heap": "4g", "gc": "g1", "user": "app"}},
+		{name: "valid-malformed-pairs", path: "vars", host: "db01.infra.local", want: map[string]string{"shared_buffers": "1g"}},
+		{name: "valid-missing", path: "vars", host: "web01.infra.local", want: map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewVarsource(cfg, VarsourceSpec{Type: TXTVarsourceType, Path: tt.path})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer v.Close()
+
+			got, err := v.GetHostVariables(tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TXTVarsource.GetHostVariables() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TXTVarsource.GetHostVariables() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("invalid-server", func(t *testing.T) {
+		cfg := newTestVarsourceConfig(t)
+
+		// Nothing listens on the port of a closed socket.
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.DNS.Server = pc.LocalAddr().String()
+		pc.Close()
+
+		v, err := NewVarsource(cfg, VarsourceSpec{Type: TXTVarsourceType, Path: "vars", Timeout: 500 * time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := v.GetHostVariables("app01.infra.local"); err == nil {
+			t.Error("TXTVarsource.GetHostVariables() expected error")
+		}
+	})
+}
+
+func TestEtcdVarsource_GetHostVariables(t *testing.T) {
+	cfg := newTestVarsourceConfig(t)
+	cfg.Etcd.TLS.Enabled = false
+	cfg.Etcd.Endpoints = []string{startTestEtcdServer(t, map[string]string{
+		"ANSIBLE_INVENTORY/vars/app01.infra.local/heap":    "4g",
+		"ANSIBLE_INVENTORY/vars/app01.infra.local/gc":      "g1",
+		"ANSIBLE_INVENTORY/vars/app01.infra.local.bak/gc":  "serial",
+		"ANSIBLE_INVENTORY/other/app01.infra.local/region": "eu",
+		"vars/app01.infra.local/outside":                   "namespace",
+	})}
+
+	tests := []struct {
+		name    string
+		path    string
+		host    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "valid", path: "vars", host: "app01.infra.local", want: map[string]string{"heap": "4g", "gc": "g1"}},
+		{name: "valid-slashes", path: "/vars/", host: "app01.infra.local", want: map[string]string{"heap": "4g", "gc": "g1"}},
+		{name: "valid-other-path", path: "other", host: "app01.infra.local", want: map[string]string{"region": "eu"}},
+		{name: "valid-missing", path: "vars", host: "web01.infra.local", want: map[string]string{}},
+		{name: "invalid-request", path: "fail", host: "app01.infra.local", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewVarsource(cfg, VarsourceSpec{Type: EtcdVarsourceType, Path: tt.path, Timeout: 5 * time.Second})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer v.Close()
+
+			got, err := v.GetHostVariables(tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EtcdVarsource.GetHostVariables() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EtcdVarsource.GetHostVariables() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPVarsource_GetHostVariables(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cr3t" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.EscapedPath() {
+		case "/vars/app01.infra.local":
+			w.Write([]byte(`{"heap": "4g", "workers": 8, "debug": false, "ratio": 0.5}`))
+		case "/vars/web%2001.infra.local":
+			w.Write([]byte(`{"escaped": "yes"}`))
+		case "/vars/broken.infra.local":
+			w.Write([]byte(`["heap", "4g"]`))
+		case "/vars/error.infra.local":
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		token   string
+		host    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "valid", token: "s3cr3t", host: "app01.infra.local", want: map[string]string{"heap": "4g", "workers": "8", "debug": "false", "ratio": "0.5"}},
+		{name: "valid-escaped", token: "s3cr3t", host: "web 01.infra.local", want: map[string]string{"escaped": "yes"}},
+		{name: "valid-missing", token: "s3cr3t", host: "db01.infra.local", want: map[string]string{}},
+		{name: "invalid-token", token: "wrong", host: "app01.infra.local", wantErr: true},
+		{name: "invalid-status", token: "s3cr3t", host: "error.infra.local", wantErr: true},
+		{name: "invalid-json", token: "s3cr3t", host: "broken.infra.local", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewVarsource(nil, VarsourceSpec{Type: HTTPVarsourceType, Path: srv.URL + "/vars/{host}", Token: tt.token})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer v.Close()
+
+			got, err := v.GetHostVariables(tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HTTPVarsource.GetHostVariables() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("HTTPVarsource.GetHostVariables() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsulVarsource_GetHostVariables(t *testing.T) {
+	kv := func(key, value string) map[string]string {
+		return map[string]string{"Key": key, "Value": base64.StdEncoding.EncodeToString([]byte(value))}
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Consul-Token") != "s3cr3t" {
+			http.Error(w, "ACL not found", http.StatusForbidden)
+			return
+		}
+		if r.URL.Query().Get("recurse") != "true" {
+			http.Error(w, "recurse is not set", http.StatusBadRequest)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/kv/vars/app01.infra.local/":
+			json.NewEncoder(w).Encode([]map[string]string{kv("vars/app01.infra.local/heap", "4g"), kv("vars/app01.infra.local/gc", "g1")})
+		case "/v1/kv/vars/broken.infra.local/":
+			json.NewEncoder(w).Encode([]map[string]string{{"Key": "vars/broken.infra.local/heap", "Value": "not base64!"}})
+		case "/v1/kv/vars/garbage.infra.local/":
+			w.Write([]byte(`{"Key": "heap"}`))
+		case "/v1/kv/vars/error.infra.local/":
+			http.Error(w, "rpc error", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		address string
+		path    string
+		token   string
+		host    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "valid", address: srv.URL, path: "vars", token: "s3cr3t", host: "app01.infra.local", want: map[string]string{"heap": "4g", "gc": "g1"}},
+		{name: "valid-slashes", address: srv.URL + "/", path: "/vars/", token: "s3cr3t", host: "app01.infra.local", want: map[string]string{"heap": "4g", "gc": "g1"}},
+		{name: "valid-missing", address: srv.URL, path: "vars", token: "s3cr3t", host: "db01.infra.local", want: map[string]string{}},
+		{name: "invalid-token", address: srv.URL, path: "vars", host: "app01.infra.local", wantErr: true},
+		{name: "invalid-status", address: srv.URL, path: "vars", token: "s3cr3t", host: "error.infra.local", wantErr: true},
+		{name: "invalid-base64", address: srv.URL, path: "vars", token: "s3cr3t", host: "broken.infra.local", wantErr: true},
+		{name: "invalid-json", address: srv.URL, path: "vars", token: "s3cr3t", host: "garbage.infra.local", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewVarsource(nil, VarsourceSpec{Type: ConsulVarsourceType, Address: tt.address, Path: tt.path, Token: tt.token})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer v.Close()
+
+			got, err := v.GetHostVariables(tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConsulVarsource.GetHostVariables() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConsulVarsource.GetHostVariables() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewVarsource(t *testing.T) {
+	cfg := newTestVarsourceConfig(t)
+	cfg.Etcd.TLS.Enabled = false
+
+	tests := []struct {
+		name        string
+		spec        VarsourceSpec
+		wantType    interface{}
+		wantTimeout time.Duration
+		wantErr     bool
+	}{
+		{name: "valid-txt", spec: VarsourceSpec{Type: "txt"}, wantType: &TXTVarsource{}, wantTimeout: varsourceDefaultTimeout},
+		{name: "valid-etcd", spec: VarsourceSpec{Type: "etcd"}, wantType: &EtcdVarsource{}, wantTimeout: varsourceDefaultTimeout},
+		{name: "valid-http-case", spec: VarsourceSpec{Type: "HTTP", Timeout: time.Second}, wantType: &HTTPVarsource{}, wantTimeout: time.Second},
+		{name: "valid-consul", spec: VarsourceSpec{Type: "consul"}, wantType: &ConsulVarsource{}, wantTimeout: varsourceDefaultTimeout},
+		{name: "valid-dhcp-isc", spec: VarsourceSpec{Type: "dhcp-isc"}, wantType: &DHCPVarsource{}, wantTimeout: varsourceDefaultTimeout},
+		{name: "invalid-type", spec: VarsourceSpec{Type: "redis"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewVarsource(cfg, tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewVarsource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			defer got.Close()
+
+			if reflect.TypeOf(got) != reflect.TypeOf(tt.wantType) {
+				t.Errorf("NewVarsource() = %T, want %T", got, tt.wantType)
+			}
+
+			spec := reflect.ValueOf(got).Elem().FieldByName("Spec").Interface().(VarsourceSpec)
+			if spec.Timeout != tt.wantTimeout {
+				t.Errorf("NewVarsource() timeout = %v, want %v", spec.Timeout, tt.wantTimeout)
+			}
+		})
+	}
+}
+
+func TestNewVarsources(t *testing.T) {
+	cfg := newTestVarsourceConfig(t)
+	cfg.Varsources.Sources = []VarsourceSpec{{Type: "txt"}, {Type: "http"}}
+
+	got, err := NewVarsources(cfg)
+	if err != nil || len(got) != 0 {
+		t.Errorf("NewVarsources() = %v, %v, want no variable sources while disabled", got, err)
+	}
+
+	cfg.Varsources.Enabled = true
+	got, err = NewVarsources(cfg)
+	if err != nil || len(got) != 2 {
+		t.Errorf("NewVarsources() = %v, %v, want 2 variable sources", got, err)
+	}
+
+	cfg.Varsources.Sources = append(cfg.Varsources.Sources, VarsourceSpec{Type: "redis"})
+	if _, err := NewVarsources(cfg); err == nil || !strings.Contains(err.Error(), "redis") {
+		t.Errorf("NewVarsources() error = %v, want an unknown type error", err)
+	}
+}
+
+func TestInventory_GetHostVariables_varsources(t *testing.T) {
+	json := func(body string) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}))
+		t.Cleanup(srv.Close)
+
+		return srv
+	}
+
+	first := json(`{"heap": "4g", "gc": "g1"}`)
+	second := json(`{"gc": "zgc", "user": "app"}`)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	tests := []struct {
+		name    string
+		sources []string
+		want    map[string]string
+	}{
+		{
+			name: "valid-records-only",
+			want: map[string]string{"heap": "2g", "env": "dev"},
+		},
+		{
+			// Variable sources take precedence over host records.
+			name:    "valid-override-records",
+			sources: []string{first.URL},
+			want:    map[string]string{"heap": "4g", "gc": "g1", "env": "dev"},
+		},
+		{
+			// Later variable sources take precedence over earlier ones.
+			name:    "valid-override-sources",
+			sources: []string{first.URL, second.URL},
+			want:    map[string]string{"heap": "4g", "gc": "zgc", "user": "app", "env": "dev"},
+		},
+		{
+			name:    "valid-order",
+			sources: []string{second.URL, first.URL},
+			want:    map[string]string{"heap": "4g", "gc": "g1", "user": "app", "env": "dev"},
+		},
+		{
+			// Failing variable sources are skipped.
+			name:    "valid-failing-source",
+			sources: []string{first.URL, failing.URL, second.URL},
+			want:    map[string]string{"heap": "4g", "gc": "zgc", "user": "app", "env": "dev"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newTestInventory(t, true, []*DatasourceRecord{
+				{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS=heap=2g,env=dev"},
+			})
+
+			for _, url := range tt.sources {
+				v, err := NewVarsource(i.Config, VarsourceSpec{Type: HTTPVarsourceType, Path: url + "/{host}"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				i.Varsources = append(i.Varsources, v)
+			}
+
+			got, err := i.GetHostVariables("app01.infra.local")
+			if err != nil {
+				t.Fatalf("Inventory.GetHostVariables() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inventory.GetHostVariables() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,488 @@
+package inventory
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Vault datasource type.
+	VaultDatasourceType string = "vault"
+
+	// Vault authentication methods.
+	vaultTokenAuth      string = "token"
+	vaultAppRoleAuth    string = "approle"
+	vaultKubernetesAuth string = "kubernetes"
+)
+
+type (
+	// VaultDatasource implements a HashiCorp Vault KV v2 datasource.
+	// All records of a host are stored in a single '<path>/<zone>/<hostname>' secret, one field per record: '0', '1', etc.
+	VaultDatasource struct {
+		// Inventory configuration.
+		Config *Config
+		// Inventory logger.
+		Logger Logger
+		// Vault HTTP client.
+		Client *http.Client
+		// Zones skipped by the last GetAllRecords call.
+		Failed []string
+
+		// Guards the token.
+		mu sync.Mutex
+		// Current Vault token.
+		token string
+	}
+
+	// vaultResponse represents the parts of a Vault API response used by the datasource.
+	vaultResponse struct {
+		Data   json.RawMessage `json:"data"`
+		Auth   *vaultAuth      `json:"auth"`
+		Errors []string        `json:"errors"`
+	}
+
+	// vaultAuth represents the authentication information of a Vault login response.
+	vaultAuth struct {
+		ClientToken string `json:"client_token"`
+	}
+)
+
+// login acquires a Vault token using the configured authentication method.
+func (v *VaultDatasource) login() error {
+	cfg := v.Config
+	auth := cfg.Vault.Auth
+
+	var body map[string]string
+	switch auth.Method {
+	case vaultTokenAuth:
+		if len(auth.Token) == 0 {
+			return errors.New("vault token is not set")
+		}
+
+		v.setToken(auth.Token)
+		return nil
+	case vaultAppRoleAuth:
+		body = map[string]string{"role_id": auth.RoleID, "secret_id": auth.SecretID}
+	case vaultKubernetesAuth:
+		jwt, err := os.ReadFile(auth.JWT)
+		if err != nil {
+			return errors.Wrap(err, "service account token loading failure")
+		}
+
+		body = map[string]string{"role": auth.Role, "jwt": strings.TrimSpace(string(jwt))}
+	default:
+		return errors.Errorf("unknown vault authentication method: %s", auth.Method)
+	}
+
+	mount := auth.Mount
+	if len(mount) == 0 {
+		mount = auth.Method
+	}
+
+	resp, _, err := v.do(http.MethodPost, "auth/"+strings.Trim(mount, "/")+"/login", body, "")
+	if err != nil {
+		return errors.Wrap(err, "vault login failure")
+	}
+
+	if resp.Auth == nil || len(resp.Auth.ClientToken) == 0 {
+		return errors.New("vault login failure: no token returned")
+	}
+
+	v.setToken(resp.Auth.ClientToken)
+
+	return nil
+}
+
+// setToken replaces the current Vault token.
+func (v *VaultDatasource) setToken(token string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.token = token
+}
+
+// getToken returns the current Vault token.
+func (v *VaultDatasource) getToken() string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.token
+}
+
+// do performs a single Vault API request and returns the decoded response and the HTTP status code.
+// Missing secrets (404) are not treated as errors.
+func (v *VaultDatasource) do(method string, path string, body interface{}, token string) (*vaultResponse, int, error) {
+	cfg := v.Config
+	result := &vaultResponse{}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(cfg.Vault.Address, "/")+"/v1/"+path, reader)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "vault request failure")
+	}
+
+	if len(token) > 0 {
+		req.Header.Set("X-Vault-Token", token)
+	}
+
+	if len(cfg.Vault.Namespace) > 0 {
+		req.Header.Set("X-Vault-Namespace", cfg.Vault.Namespace)
+	}
+
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "vault request failure")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotFound {
+		return result, resp.StatusCode, nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil && err != io.EOF {
+		return nil, resp.StatusCode, errors.Wrap(err, "vault response parsing failure")
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, resp.StatusCode, errors.Errorf("vault request failure: %s: %s", resp.Status, strings.Join(result.Errors, "; "))
+	}
+
+	return result, resp.StatusCode, nil
+}
+
+// request performs an authenticated Vault API request.
+// Expired tokens acquired with the AppRole or Kubernetes authentication methods are renewed by logging in again.
+func (v *VaultDatasource) request(method string, path string, body interface{}) (*vaultResponse, int, error) {
+	cfg := v.Config
+
+	resp, status, err := v.do(method, path, body, v.getToken())
+	if status != http.StatusForbidden || cfg.Vault.Auth.Method == vaultTokenAuth {
+		return resp, status, err
+	}
+
+	if err := v.login(); err != nil {
+		return nil, status, err
+	}
+
+	return v.do(method, path, body, v.getToken())
+}
+
+// secretPath returns the API path of a host record secret: 'data' for secret data, 'metadata' for secret metadata.
+// The host is omitted if empty.
+func (v *VaultDatasource) secretPath(kind string, zone string, host string) string {
+	cfg := v.Config
+
+	path := strings.Trim(cfg.Vault.Mount, "/") + "/" + kind + "/" + strings.Trim(cfg.Vault.Path, "/") + "/" + zone + "/"
+	if len(host) > 0 {
+		path += host
+	}
+
+	return path
+}
+
+// findZone selects a matching zone from the datasource configuration based on the hostname.
+func (v *VaultDatasource) findZone(host string) (string, error) {
+	cfg := v.Config
+	var zone string
+
+	// Try finding a matching zone in the configuration.
+	for _, z := range cfg.Vault.Zones {
+		if strings.HasSuffix(strings.Trim(host, "."), strings.Trim(z, ".")) {
+			zone = z
+			break
+		}
+	}
+
+	if len(zone) == 0 {
+		return zone, errors.New("no matching zones found in config file")
+	}
+
+	return zone, nil
+}
+
+// listHosts returns the names of all hosts that have a secret in a specific zone.
+func (v *VaultDatasource) listHosts(zone string) ([]string, error) {
+	resp, status, err := v.request(http.MethodGet, v.secretPath("metadata", zone, "")+"?list=true", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make([]string, 0)
+	if status == http.StatusNotFound {
+		return hosts, nil
+	}
+
+	var data struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return nil, errors.Wrap(err, "vault response parsing failure")
+	}
+
+	for _, key := range data.Keys {
+		// Subfolders are not host secrets.
+		if !strings.HasSuffix(key, "/") {
+			hosts = append(hosts, key)
+		}
+	}
+
+	return hosts, nil
+}
+
+// readHost acquires all records of a host in a specific zone, ordered by their index.
+func (v *VaultDatasource) readHost(zone string, host string) ([]*DatasourceRecord, error) {
+	log := v.Logger
+	path := v.secretPath("data", zone, host)
+
+	resp, status, err := v.request(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*DatasourceRecord, 0)
+	if status == http.StatusNotFound {
+		return records, nil
+	}
+
+	var data struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return nil, errors.Wrap(err, "vault response parsing failure")
+	}
+
+	fields := make([]string, 0, len(data.Data))
+	for field := range data.Data {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		a, _ := strconv.Atoi(fields[i])
+		b, _ := strconv.Atoi(fields[j])
+		return a < b
+	})
+
+	for _, field := range fields {
+		// Determine which set of host attributes we are working with.
+		if _, err := strconv.Atoi(field); err != nil {
+			log.Warnf(warnSkippedAttributeSet, host, err)
+			continue
+		}
+
+		value, ok := data.Data[field].(string)
+		if !ok {
+			log.Warnf(warnSkippedAttributeSet, host, errors.Errorf("field %s is not a string", field))
+			continue
+		}
+
+		records = append(records, &DatasourceRecord{
+			Hostname:   host,
+			Attributes: value,
+			Source:     path + "#" + field,
+		})
+	}
+
+	return records, nil
+}
+
+// GetAllRecords acquires all available host records.
+func (v *VaultDatasource) GetAllRecords() ([]*DatasourceRecord, error) {
+	cfg := v.Config
+	log := v.Logger
+	records := make([]*DatasourceRecord, 0)
+	v.Failed = make([]string, 0)
+
+	for _, zone := range cfg.Vault.Zones {
+		hosts, err := v.listHosts(zone)
+		if err != nil {
+			log.Warnf(warnSkippedZone, zone, err)
+			v.Failed = append(v.Failed, zone)
+			continue
+		}
+
+		// Read host secrets concurrently, keeping the listing order.
+		results := make([][]*DatasourceRecord, len(hosts))
+		errs := make([]error, len(hosts))
+
+		var wg sync.WaitGroup
+		workers := make(chan struct{}, max(cfg.Vault.Workers, 1))
+		for n, host := range hosts {
+			wg.Add(1)
+
+			go func(n int, host string) {
+				defer wg.Done()
+
+				workers <- struct{}{}
+				defer func() { <-workers }()
+
+				results[n], errs[n] = v.readHost(zone, host)
+			}(n, host)
+		}
+		wg.Wait()
+
+		for n, host := range hosts {
+			if errs[n] != nil {
+				log.Warnf(warnSkippedRecord, host, errs[n])
+				continue
+			}
+
+			records = append(records, results[n]...)
+		}
+	}
+
+	if err := checkZones(v.Failed, len(cfg.Vault.Zones), cfg.Fallback.MaxFailedZones); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// FailedZones returns the zones skipped by the last GetAllRecords call.
+func (v *VaultDatasource) FailedZones() []string {
+	return v.Failed
+}
+
+// GetHostRecords reads the record secret of a specific host in the zone matching its hostname.
+func (v *VaultDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error) {
+	zone, err := v.findZone(host)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: failed to find zone", host)
+	}
+
+	return v.readHost(zone, host)
+}
+
+// ClearRecords removes all existing host records if the datasource is configured to do so before publishing.
+// Secrets are deleted with all of their versions, but only in the zones listed in the configuration.
+func (v *VaultDatasource) ClearRecords() error {
+	cfg := v.Config
+
+	if !cfg.Vault.Import.Clear {
+		return nil
+	}
+
+	for _, zone := range cfg.Vault.Zones {
+		hosts, err := v.listHosts(zone)
+		if err != nil {
+			return errors.Wrapf(err, "%s: host listing failure", zone)
+		}
+
+		for _, host := range hosts {
+			if _, _, err := v.request(http.MethodDelete, v.secretPath("metadata", zone, host), nil); err != nil {
+				return errors.Wrapf(err, "%s: host record removal failure", host)
+			}
+		}
+	}
+
+	return nil
+}
+
+// PutRecords writes host records to the datasource without removing existing records.
+// The secret of every host is replaced with a new version containing the published records of this host.
+func (v *VaultDatasource) PutRecords(records []*DatasourceRecord) error {
+	log := v.Logger
+
+	hosts := make([]string, 0)
+	fields := make(map[string]map[string]string)
+	for _, record := range records {
+		if _, ok := fields[record.Hostname]; !ok {
+			hosts = append(hosts, record.Hostname)
+			fields[record.Hostname] = make(map[string]string)
+		}
+
+		fields[record.Hostname][strconv.Itoa(len(fields[record.Hostname]))] = record.Attributes
+	}
+
+	for _, host := range hosts {
+		zone, err := v.findZone(host)
+		if err != nil {
+			log.Warnf(warnSkippedRecord, host, err)
+			continue
+		}
+
+		body := map[string]interface{}{"data": fields[host]}
+		if _, _, err := v.request(http.MethodPost, v.secretPath("data", zone, host), body); err != nil {
+			return errors.Wrapf(err, "%s: host record publishing failure", host)
+		}
+	}
+
+	return nil
+}
+
+// ReplaceHost replaces all records of a host. The secret of the host is replaced with a new version, or deleted with all of its versions if there are no records.
+func (v *VaultDatasource) ReplaceHost(host string, records []*DatasourceRecord) error {
+	zone, err := v.findZone(host)
+	if err != nil {
+		return errors.Wrapf(err, "%s: failed to find zone", host)
+	}
+
+	if len(records) > 0 {
+		return v.PutRecords(records)
+	}
+
+	if _, _, err := v.request(http.MethodDelete, v.secretPath("metadata", zone, host), nil); err != nil {
+		return errors.Wrapf(err, "%s: host record removal failure", host)
+	}
+
+	return nil
+}
+
+// PublishRecords writes host records to the datasource.
+func (v *VaultDatasource) PublishRecords(records []*DatasourceRecord) error {
+	if err := v.ClearRecords(); err != nil {
+		return err
+	}
+
+	return v.PutRecords(records)
+}
+
+// Close closes idle connections to the Vault server. The Vault token is not revoked.
+func (v *VaultDatasource) Close() {
+	v.Client.CloseIdleConnections()
+}
+
+// NewVaultDatasource creates a Vault datasource.
+func NewVaultDatasource(cfg *Config, log Logger) (*VaultDatasource, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.Vault.TLS.Insecure}
+	if len(cfg.Vault.TLS.CA) > 0 {
+		pool, err := tlsCAPoolFromFile(cfg.Vault.TLS.CA)
+		if err != nil {
+			return nil, errors.Wrap(err, "vault datasource initialization failure")
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	v := &VaultDatasource{
+		Config: cfg,
+		Logger: log,
+		Client: &http.Client{
+			Timeout:   cfg.Vault.Timeout,
+			Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig},
+		},
+	}
+
+	if err := v.login(); err != nil {
+		return nil, errors.Wrap(err, "vault datasource initialization failure")
+	}
+
+	return v, nil
+}
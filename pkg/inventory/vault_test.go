@@ -0,0 +1,176 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testVault is a minimal Vault server with a KV v2 secrets engine mounted at 'secret' and the AppRole authentication method.
+type testVault struct {
+	mu      sync.Mutex
+	secrets map[string]map[string]interface{}
+	tokens  map[string]bool
+	logins  int
+}
+
+func (s *testVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.URL.Path == "/v1/auth/approle/login" {
+		s.logins++
+		token := fmt.Sprintf("token%d", s.logins)
+		s.tokens[token] = true
+		json.NewEncoder(w).Encode(map[string]interface{}{"auth": map[string]string{"client_token": token}})
+		return
+	}
+
+	if !s.tokens[r.Header.Get("X-Vault-Token")] {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string][]string{"errors": {"permission denied"}})
+		return
+	}
+
+	switch path := strings.TrimPrefix(r.URL.Path, "/v1/secret/"); {
+	case strings.HasPrefix(path, "metadata/") && r.URL.Query().Get("list") == "true":
+		keys := make([]string, 0)
+		for secret := range s.secrets {
+			if name, ok := strings.CutPrefix(secret, strings.TrimPrefix(path, "metadata/")); ok && !strings.Contains(name, "/") {
+				keys = append(keys, name)
+			}
+		}
+		if len(keys) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		sort.Strings(keys)
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string][]string{"keys": keys}})
+	case strings.HasPrefix(path, "metadata/") && r.Method == http.MethodDelete:
+		delete(s.secrets, strings.TrimPrefix(path, "metadata/"))
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasPrefix(path, "data/") && r.Method == http.MethodPost:
+		var body struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		s.secrets[strings.TrimPrefix(path, "data/")] = body.Data
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]int{"version": 1}})
+	case strings.HasPrefix(path, "data/") && r.Method == http.MethodGet:
+		data, ok := s.secrets[strings.TrimPrefix(path, "data/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"data": data}})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestVaultDatasource(t *testing.T) {
+	records := []*DatasourceRecord{
+		{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS=password=secret"},
+		{Hostname: "app01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=db;SRV=;VARS="},
+		{Hostname: "app02.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=app;SRV=;VARS="},
+		{Hostname: "app03.other.local", Attributes: "OS=linux;ENV=prod;ROLE=app;SRV=;VARS="},
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "valid-token",
+			method: "token",
+			want: []string{
+				"app01.infra.local OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS=password=secret",
+				"app01.infra.local OS=linux;ENV=dev;ROLE=db;SRV=;VARS=",
+				"app02.infra.local OS=linux;ENV=prod;ROLE=app;SRV=;VARS=",
+			},
+			// Static tokens are not renewed.
+			wantErr: true,
+		},
+		{
+			name:   "valid-approle",
+			method: "approle",
+			want: []string{
+				"app01.infra.local OS=linux;ENV=dev;ROLE=app;SRV=tomcat;VARS=password=secret",
+				"app01.infra.local OS=linux;ENV=dev;ROLE=db;SRV=;VARS=",
+				"app02.infra.local OS=linux;ENV=prod;ROLE=app;SRV=;VARS=",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vault := &testVault{
+				secrets: map[string]map[string]interface{}{"ANSIBLE_INVENTORY/infra.local./stale.infra.local": {"0": "OS=linux"}},
+				tokens:  map[string]bool{"root": true},
+			}
+			server := httptest.NewServer(vault)
+			defer server.Close()
+
+			cfg := &Config{}
+			cfg.Vault.Address = server.URL
+			cfg.Vault.Mount = "secret"
+			cfg.Vault.Path = "ANSIBLE_INVENTORY"
+			cfg.Vault.Zones = []string{"infra.local."}
+			cfg.Vault.Import.Clear = true
+			cfg.Vault.Auth.Method = tt.method
+			cfg.Vault.Auth.Token = "root"
+
+			v, err := NewVaultDatasource(cfg, &testLogger{})
+			if err != nil {
+				t.Fatalf("NewVaultDatasource() error = %v", err)
+			}
+			defer v.Close()
+
+			if err := v.PublishRecords(records); err != nil {
+				t.Fatalf("VaultDatasource.PublishRecords() error = %v", err)
+			}
+
+			all, err := v.GetAllRecords()
+			if err != nil {
+				t.Fatalf("VaultDatasource.GetAllRecords() error = %v", err)
+			}
+
+			got := make([]string, 0)
+			for _, r := range all {
+				got = append(got, r.Hostname+" "+r.Attributes)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("VaultDatasource.GetAllRecords() = %v, want %v", got, tt.want)
+			}
+
+			// Expire all tokens.
+			vault.mu.Lock()
+			vault.tokens = make(map[string]bool)
+			vault.mu.Unlock()
+
+			// No zone can be listed with an expired static token.
+			if _, err := v.GetAllRecords(); (err != nil) != tt.wantErr {
+				t.Errorf("VaultDatasource.GetAllRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			host, err := v.GetHostRecords("app02.infra.local")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VaultDatasource.GetHostRecords() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if err == nil && (len(host) != 1 || host[0].Attributes != records[2].Attributes) {
+				t.Errorf("VaultDatasource.GetHostRecords() = %v, want %v", host, records[2:3])
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package inventory
+
+import (
+	"github.com/NeonSludge/ansible-dns-inventory/internal/build"
+)
+
+// Version returns ansible-dns-inventory version and build information.
+func Version() *BuildInfo {
+	info := build.Get()
+
+	return &BuildInfo{
+		Version:   info.Version,
+		Time:      info.Time,
+		Revision:  info.Revision,
+		Dirty:     info.Dirty,
+		GoVersion: info.GoVersion,
+	}
+}
+
+// String returns a single line representation of build information.
+func (i *BuildInfo) String() string {
+	return (&build.Info{Version: i.Version, Time: i.Time, Revision: i.Revision, Dirty: i.Dirty, GoVersion: i.GoVersion}).String()
+}
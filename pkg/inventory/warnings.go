@@ -0,0 +1,148 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// Per-record warning message templates.
+	warnSkippedRecord       string = "[%s] skipping host record: %v"
+	warnSkippedAttributeSet string = "[%s] skipping host attributes set: %v"
+	warnFilteredRecord      string = "[%s] skipping filtered host record"
+	warnSkippedZone         string = "[%s] skipping zone: %v"
+	warnSkippedVarsource    string = "[%s] skipping variable source: %v"
+	warnDuplicateHostID     string = "[%s] host ID %s is already used by %s"
+)
+
+// warningCategories maps per-record warning message templates to the categories used in warning summaries.
+var warningCategories = map[string]string{
+	warnSkippedRecord:       "validation",
+	warnSkippedAttributeSet: "validation",
+	warnFilteredRecord:      "filter",
+	warnSkippedZone:         "zone failure",
+	warnSkippedVarsource:    "variable source",
+	warnDuplicateHostID:     "validation",
+}
+
+// WarningLogger wraps a Logger, counting per-record warnings by category and optionally suppressing them.
+// Other messages are passed to the wrapped Logger as is.
+type WarningLogger struct {
+	Logger
+	// Suppress per-record warnings.
+	Quiet bool
+
+	// Guards the counters.
+	mu sync.Mutex
+	// Number of per-record warnings by category.
+	counts map[string]int
+	// Number of other warnings.
+	other int
+}
+
+// Warn counts and logs a message.
+func (w *WarningLogger) Warn(args ...interface{}) {
+	w.mu.Lock()
+	w.other++
+	w.mu.Unlock()
+
+	w.Logger.Warn(args...)
+}
+
+// Warnf counts warnings and logs a templated message unless it is a suppressed per-record warning.
+func (w *WarningLogger) Warnf(template string, args ...interface{}) {
+	category, ok := warningCategories[template]
+
+	w.mu.Lock()
+	if ok {
+		w.counts[category]++
+	} else {
+		w.other++
+	}
+	w.mu.Unlock()
+
+	if ok && w.Quiet {
+		return
+	}
+
+	w.Logger.Warnf(template, args...)
+}
+
+// Counts returns the number of per-record warnings by category.
+func (w *WarningLogger) Counts() map[string]int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	counts := make(map[string]int, len(w.counts))
+	for category, n := range w.counts {
+		counts[category] = n
+	}
+
+	return counts
+}
+
+// Other returns the number of warnings that are not per-record warnings.
+func (w *WarningLogger) Other() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.other
+}
+
+// Count returns the total number of per-record warnings.
+func (w *WarningLogger) Count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	total := 0
+	for _, n := range w.counts {
+		total += n
+	}
+
+	return total
+}
+
+// Summary returns a summary of per-record warnings, e.g. '16 records skipped: 12 validation, 3 filter, 1 zone failure'.
+// It returns an empty string if there were no warnings.
+func (w *WarningLogger) Summary() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.counts) == 0 {
+		return ""
+	}
+
+	categories := make([]string, 0, len(w.counts))
+	for category := range w.counts {
+		categories = append(categories, category)
+	}
+
+	// Most frequent categories first.
+	sort.Slice(categories, func(i, j int) bool {
+		if w.counts[categories[i]] != w.counts[categories[j]] {
+			return w.counts[categories[i]] > w.counts[categories[j]]
+		}
+
+		return categories[i] < categories[j]
+	})
+
+	total := 0
+	parts := make([]string, 0, len(categories))
+	for _, category := range categories {
+		total += w.counts[category]
+		parts = append(parts, fmt.Sprintf("%d %s", w.counts[category], category))
+	}
+
+	return fmt.Sprintf("%d records skipped: %s", total, strings.Join(parts, ", "))
+}
+
+// NewWarningLogger wraps a Logger with per-record warning accounting.
+func NewWarningLogger(log Logger, quiet bool) *WarningLogger {
+	return &WarningLogger{
+		Logger: log,
+		Quiet:  quiet,
+		counts: make(map[string]int),
+	}
+}
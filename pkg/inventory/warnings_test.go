@@ -0,0 +1,58 @@
+package inventory
+
+import (
+	"testing"
+)
+
+func TestWarningLogger_Summary(t *testing.T) {
+	tests := []struct {
+		name     string
+		warnings []string
+		want     string
+	}{
+		{
+			name:     "none",
+			warnings: []string{},
+			want:     "",
+		},
+		{
+			name:     "several",
+			warnings: []string{warnSkippedRecord, warnFilteredRecord, warnSkippedZone, warnSkippedRecord, warnSkippedAttributeSet, warnFilteredRecord},
+			want:     "6 records skipped: 3 validation, 2 filter, 1 zone failure",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Per-record warnings are suppressed, so the wrapped logger is never called.
+			w := NewWarningLogger(nil, true)
+
+			for _, template := range tt.warnings {
+				w.Warnf(template, "app01.infra.local", nil)
+			}
+
+			if got := w.Summary(); got != tt.want {
+				t.Errorf("WarningLogger.Summary() = %v, want %v", got, tt.want)
+			}
+			if got := w.Count(); got != len(tt.warnings) {
+				t.Errorf("WarningLogger.Count() = %v, want %v", got, len(tt.warnings))
+			}
+			if got := w.Other(); got != 0 {
+				t.Errorf("WarningLogger.Other() = %v, want 0", got)
+			}
+		})
+	}
+}
+
+func TestWarningLogger_Other(t *testing.T) {
+	w := NewWarningLogger(&testLogger{}, true)
+
+	w.Warnf(warnSkippedRecord, "app01.infra.local", nil)
+	w.Warnf("host record cache writing failure: %v", nil)
+
+	if got := w.Other(); got != 1 {
+		t.Errorf("WarningLogger.Other() = %v, want 1", got)
+	}
+	if got := w.Count(); got != 1 {
+		t.Errorf("WarningLogger.Count() = %v, want 1", got)
+	}
+}
@@ -0,0 +1,122 @@
+package inventory
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative pkg/inventory/inventory.proto
+
+// NewWireInventory converts exported inventory data into an inventory snapshot in the protobuf wire format.
+// It accepts an inventory tree, an exported Ansible inventory and a map of hosts and their attributes.
+func NewWireInventory(v interface{}) (*WireInventory, error) {
+	w := &WireInventory{Hosts: make([]*WireHost, 0), Groups: make([]*WireGroup, 0)}
+	compare := strings.Compare
+
+	switch v := v.(type) {
+	case *Node:
+		compare = v.compare
+
+		if err := w.importNode(v); err != nil {
+			return nil, err
+		}
+
+		hosts := make(map[string][]string)
+		v.ExportHosts(hosts)
+		for name, groups := range hosts {
+			w.Hosts = append(w.Hosts, &WireHost{Name: name, Groups: groups})
+		}
+	case map[string]*AnsibleGroup:
+		for name, group := range v {
+			vars, err := marshalWireVars(group.Vars)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s: group variables marshalling failure", name)
+			}
+
+			w.Groups = append(w.Groups, &WireGroup{Name: name, Children: group.Children, Hosts: slices.Clone(group.Hosts), Vars: vars})
+		}
+	case map[string][]*HostAttributes:
+		for name, sets := range v {
+			host := &WireHost{Name: name, Attributes: make([]*WireAttributes, 0, len(sets))}
+			for _, attrs := range sets {
+				host.Attributes = append(host.Attributes, &WireAttributes{Os: attrs.OS, Env: attrs.Env, Role: attrs.Role, Srv: attrs.Srv, Vars: attrs.Vars, Id: attrs.ID, Extra: maps.Clone(attrs.Extra)})
+			}
+
+			w.Hosts = append(w.Hosts, host)
+		}
+	default:
+		return nil, errors.Errorf("unsupported value: %T", v)
+	}
+
+	w.SortNames(compare)
+
+	return w, nil
+}
+
+// SortNames sorts the hosts and groups of the snapshot, as well as the hosts of every group, using a host and group name comparison function.
+func (w *WireInventory) SortNames(compare func(a, b string) int) {
+	sort.Slice(w.Hosts, func(i, j int) bool { return compare(w.Hosts[i].Name, w.Hosts[j].Name) < 0 })
+	sort.Slice(w.Groups, func(i, j int) bool { return compare(w.Groups[i].Name, w.Groups[j].Name) < 0 })
+
+	for _, group := range w.Groups {
+		slices.SortFunc(group.Hosts, compare)
+	}
+}
+
+// importNode adds a tree node and its descendants to the snapshot.
+func (w *WireInventory) importNode(n *Node) error {
+	vars, err := marshalWireVars(n.Vars)
+	if err != nil {
+		return errors.Wrapf(err, "%s: group variables marshalling failure", n.Name)
+	}
+
+	group := &WireGroup{Name: n.Name, Vars: vars, Order: int32(n.Order)}
+
+	for _, child := range n.Children {
+		group.Children = append(group.Children, child.Name)
+
+		if err := w.importNode(child); err != nil {
+			return err
+		}
+	}
+
+	for host := range n.Hosts {
+		group.Hosts = append(group.Hosts, host)
+	}
+
+	w.Groups = append(w.Groups, group)
+
+	return nil
+}
+
+// marshalWireVars encodes group variables as JSON.
+func marshalWireVars(vars map[string]interface{}) (string, error) {
+	if len(vars) == 0 {
+		return "", nil
+	}
+
+	data, err := json.Marshal(vars)
+
+	return string(data), err
+}
+
+// MarshalProto returns the protobuf encoding of the snapshot.
+// Map fields are encoded in key order, so equal snapshots have equal encodings.
+func (w *WireInventory) MarshalProto() []byte {
+	// Marshalling only fails for messages with unset required fields, which proto3 does not have.
+	b, _ := proto.MarshalOptions{Deterministic: true}.Marshal(w)
+
+	return b
+}
+
+// UnmarshalProto decodes a snapshot from its protobuf encoding, appending its hosts and groups to the snapshot.
+// Unknown fields are kept.
+func (w *WireInventory) UnmarshalProto(b []byte) error {
+	return proto.UnmarshalOptions{Merge: true}.Unmarshal(b, w)
+}
@@ -0,0 +1,144 @@
+package inventory
+
+import (
+	"bytes"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWireInventory_MarshalProto(t *testing.T) {
+	tree := NewTree()
+	tree.AddChild("dev").AddChild("dev_app").AddHost("app01.infra.local")
+	tree.AddChild("dev").AddChild("dev_db").AddHost("db01.infra.local")
+	tree.FindChild("dev_app").Vars = map[string]interface{}{"java_heap": "2g"}
+	tree.OrderChildren([]string{"dev_db"})
+
+	tests := []struct {
+		name    string
+		v       interface{}
+		wantErr bool
+	}{
+		{
+			name: "valid-tree",
+			v:    tree,
+		},
+		{
+			name: "valid-attrs",
+			v: map[string][]*HostAttributes{
+				"app01.infra.local": {{OS: "linux", Env: "dev", Role: "app", Srv: "tomcat", Vars: "a=1"}},
+				"db01.infra.local":  {{OS: "linux", Env: "dev", Role: "db", ID: "0b6f4b36-6b6e-4c3c-9d6c-2f7d8b1f0e51"}},
+			},
+		},
+		{
+			name: "valid-attrs-extra",
+			v: map[string][]*HostAttributes{
+				"app01.infra.local": {
+					{OS: "linux", Env: "dev", Role: "app", Extra: map[string]string{"DC": "msk1", "RACK": "r12", "ZONE": "a"}},
+					{OS: "linux", Env: "dev", Role: "web", Extra: map[string]string{"DC": "spb1"}},
+				},
+			},
+		},
+		{
+			name:    "invalid-value",
+			v:       map[string]string{"app01.infra.local": "dev"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := NewWireInventory(tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewWireInventory() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			data := want.MarshalProto()
+
+			got := &WireInventory{}
+			if err := got.UnmarshalProto(data); err != nil {
+				t.Errorf("WireInventory.UnmarshalProto() error = %v", err)
+				return
+			}
+			if !proto.Equal(got, want) {
+				t.Errorf("WireInventory.UnmarshalProto() = %v, want %v", got, want)
+			}
+
+			// Map fields are encoded in key order.
+			for i := 0; i < 10; i++ {
+				if again := want.MarshalProto(); !bytes.Equal(again, data) {
+					t.Fatalf("WireInventory.MarshalProto() is not deterministic: %x, want %x", again, data)
+				}
+			}
+		})
+	}
+}
+
+func TestNewWireInventory_extra(t *testing.T) {
+	extra := map[string]string{"DC": "msk1"}
+
+	w, err := NewWireInventory(map[string][]*HostAttributes{"app01.infra.local": {{OS: "linux", Env: "dev", Role: "app", Extra: extra}}})
+	if err != nil {
+		t.Fatalf("NewWireInventory() error = %v", err)
+	}
+
+	if got := w.Hosts[0].Attributes[0].GetExtra()["DC"]; got != "msk1" {
+		t.Errorf("extra attribute DC = %q, want %q", got, "msk1")
+	}
+
+	// The snapshot does not share the attributes of the inventory.
+	w.Hosts[0].Attributes[0].Extra["DC"] = "spb1"
+	if extra["DC"] != "msk1" {
+		t.Error("NewWireInventory() shares extra attributes with the inventory")
+	}
+}
+
+func TestWireInventory_UnmarshalProto(t *testing.T) {
+	// A snapshot written by a schema version with an additional host field.
+	var host []byte
+	host = protowire.AppendTag(host, 1, protowire.BytesType)
+	host = protowire.AppendString(host, "app01.infra.local")
+	host = protowire.AppendTag(host, 15, protowire.VarintType)
+	host = protowire.AppendVarint(host, 42)
+
+	var unknown []byte
+	unknown = protowire.AppendTag(unknown, 1, protowire.BytesType)
+	unknown = protowire.AppendBytes(unknown, host)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		wantHost string
+		wantErr  bool
+	}{
+		{name: "valid-empty", data: []byte{}},
+		{name: "valid-unknown-field", data: unknown, wantHost: "app01.infra.local"},
+		{name: "invalid-truncated", data: unknown[:len(unknown)-3], wantErr: true},
+		{name: "invalid-wire-type", data: []byte{0x0f}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &WireInventory{}
+			err := w.UnmarshalProto(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WireInventory.UnmarshalProto() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(tt.wantHost) > 0 && (len(w.Hosts) != 1 || w.Hosts[0].GetName() != tt.wantHost) {
+				t.Errorf("WireInventory.UnmarshalProto() hosts = %v, want %s", w.Hosts, tt.wantHost)
+			}
+
+			// Unknown fields survive a round trip.
+			if !bytes.Equal(w.MarshalProto(), tt.data) {
+				t.Errorf("WireInventory.MarshalProto() = %x, want %x", w.MarshalProto(), tt.data)
+			}
+		})
+	}
+}
@@ -0,0 +1,405 @@
+package inventory
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Consul datasource type.
+	ConsulDatasourceType string = "consul"
+	// Nomad datasource type.
+	NomadDatasourceType string = "nomad"
+
+	// Pseudo-metadata key referring to the datacenter of a node.
+	workloadDatacenterMeta string = "@datacenter"
+	// Pseudo-metadata key referring to the namespace of a service instance or an allocation.
+	workloadNamespaceMeta string = "@namespace"
+)
+
+type (
+	// WorkloadsDatasource implements a read-only datasource deriving hosts from the Consul service catalog or Nomad allocations,
+	// so that dynamically scheduled workloads show up in the inventory groups of the nodes running them.
+	WorkloadsDatasource struct {
+		// Inventory configuration.
+		Config *Config
+		// Inventory logger.
+		Logger Logger
+		// HTTP client.
+		Client *http.Client
+	}
+
+	// workload represents a service instance or an allocation running on a node.
+	workload struct {
+		// Node name.
+		Node string
+		// Node datacenter.
+		Datacenter string
+		// Namespace of the service instance or the allocation.
+		Namespace string
+		// Node metadata.
+		Meta map[string]string
+		// ROLE attribute value.
+		Role string
+		// SRV attribute value.
+		Srv string
+		// Host record source.
+		Source string
+	}
+
+	// consulServiceEntry represents a service instance in a Consul health API response.
+	consulServiceEntry struct {
+		Node struct {
+			Node       string            `json:"Node"`
+			Datacenter string            `json:"Datacenter"`
+			Meta       map[string]string `json:"Meta"`
+		} `json:"Node"`
+		Service struct {
+			ID        string   `json:"ID"`
+			Service   string   `json:"Service"`
+			Tags      []string `json:"Tags"`
+			Namespace string   `json:"Namespace"`
+		} `json:"Service"`
+	}
+
+	// nomadAllocation represents an allocation in a Nomad allocations API response.
+	nomadAllocation struct {
+		ID           string `json:"ID"`
+		Namespace    string `json:"Namespace"`
+		NodeID       string `json:"NodeID"`
+		NodeName     string `json:"NodeName"`
+		JobID        string `json:"JobID"`
+		TaskGroup    string `json:"TaskGroup"`
+		ClientStatus string `json:"ClientStatus"`
+	}
+
+	// nomadNode represents a node in a Nomad node API response.
+	nomadNode struct {
+		Name       string            `json:"Name"`
+		Datacenter string            `json:"Datacenter"`
+		Meta       map[string]string `json:"Meta"`
+	}
+)
+
+// get performs an API request and decodes the JSON response into v, returning the response headers.
+func (w *WorkloadsDatasource) get(address string, path string, params url.Values, header string, token string, v interface{}) (http.Header, error) {
+	name := w.Config.Datasource
+
+	u := strings.TrimSuffix(address, "/") + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s request failure", name)
+	}
+	if len(token) > 0 {
+		req.Header.Set(header, token)
+	}
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s request failure", name)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Both APIs explain errors in plain text.
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+		return nil, errors.Errorf("%s request failure: %s: %s: %s", name, path, resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return nil, errors.Wrapf(err, "%s response parsing failure", name)
+	}
+
+	return resp.Header, nil
+}
+
+// consulWorkloads lists the instances of the selected Consul services in the selected datacenters.
+func (w *WorkloadsDatasource) consulWorkloads() ([]*workload, error) {
+	cfg := w.Config
+	consul := cfg.Workloads.Consul
+
+	datacenters := consul.Datacenters
+	if len(datacenters) == 0 {
+		// The datacenter of the agent.
+		datacenters = []string{""}
+	}
+
+	workloads := make([]*workload, 0)
+	for _, dc := range datacenters {
+		params := url.Values{}
+		if len(dc) > 0 {
+			params.Set("dc", dc)
+		}
+
+		services := consul.Services
+		if len(services) == 0 {
+			catalog := make(map[string][]string)
+			if _, err := w.get(consul.Address, "/v1/catalog/services", params, "X-Consul-Token", consul.Token, &catalog); err != nil {
+				return nil, err
+			}
+
+			services = make([]string, 0, len(catalog))
+			for service := range catalog {
+				services = append(services, service)
+			}
+			sort.Strings(services)
+		}
+
+		if consul.Passing {
+			params.Set("passing", "true")
+		}
+
+		for _, service := range services {
+			if slices.Contains(consul.Exclude, service) {
+				continue
+			}
+
+			entries := make([]*consulServiceEntry, 0)
+			if _, err := w.get(consul.Address, "/v1/health/service/"+url.PathEscape(service), params, "X-Consul-Token", consul.Token, &entries); err != nil {
+				return nil, err
+			}
+
+			for _, e := range entries {
+				srv := make([]string, 0)
+				if len(consul.TagPrefix) > 0 {
+					for _, tag := range e.Service.Tags {
+						if name, ok := strings.CutPrefix(tag, consul.TagPrefix); ok && len(name) > 0 {
+							srv = append(srv, name)
+						}
+					}
+				}
+
+				workloads = append(workloads, &workload{
+					Node:       e.Node.Node,
+					Datacenter: e.Node.Datacenter,
+					Namespace:  e.Service.Namespace,
+					Meta:       e.Node.Meta,
+					Role:       e.Service.Service,
+					Srv:        strings.Join(srv, ","),
+					Source:     "consul/" + e.Node.Datacenter + "/" + e.Node.Node + "/" + e.Service.ID,
+				})
+			}
+		}
+	}
+
+	return workloads, nil
+}
+
+// nomadWorkloads lists the running allocations of the selected Nomad jobs. Nodes are only read if node metadata is mapped to host attributes.
+func (w *WorkloadsDatasource) nomadWorkloads() ([]*workload, error) {
+	cfg := w.Config
+	nomad := cfg.Workloads.Nomad
+
+	params := url.Values{}
+	if len(nomad.Namespace) > 0 {
+		params.Set("namespace", nomad.Namespace)
+	}
+	if len(nomad.Region) > 0 {
+		params.Set("region", nomad.Region)
+	}
+
+	allocations := make([]*nomadAllocation, 0)
+	for {
+		page := make([]*nomadAllocation, 0)
+		headers, err := w.get(nomad.Address, "/v1/allocations", params, "X-Nomad-Token", nomad.Token, &page)
+		if err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, page...)
+
+		next := headers.Get("X-Nomad-NextToken")
+		if len(next) == 0 {
+			break
+		}
+		params.Set("next_token", next)
+	}
+	params.Del("next_token")
+	params.Del("namespace")
+
+	nodeMeta := false
+	for _, meta := range cfg.Workloads.Meta {
+		nodeMeta = nodeMeta || meta != workloadNamespaceMeta
+	}
+
+	nodes := make(map[string]*nomadNode)
+	workloads := make([]*workload, 0, len(allocations))
+	for _, a := range allocations {
+		if a.ClientStatus != "running" || len(nomad.Jobs) > 0 && !slices.Contains(nomad.Jobs, a.JobID) {
+			continue
+		}
+
+		node, ok := nodes[a.NodeID]
+		if !ok {
+			node = &nomadNode{Name: a.NodeName}
+			if nodeMeta {
+				if _, err := w.get(nomad.Address, "/v1/node/"+url.PathEscape(a.NodeID), params, "X-Nomad-Token", nomad.Token, node); err != nil {
+					return nil, err
+				}
+			}
+			nodes[a.NodeID] = node
+		}
+
+		workloads = append(workloads, &workload{
+			Node:       node.Name,
+			Datacenter: node.Datacenter,
+			Namespace:  a.Namespace,
+			Meta:       node.Meta,
+			Role:       a.JobID,
+			Srv:        a.TaskGroup,
+			Source:     "nomad/" + a.Namespace + "/" + a.ID,
+		})
+	}
+
+	return workloads, nil
+}
+
+// hostname returns the hostname of a node, appending the domain to node names that are not fully qualified.
+func (w *WorkloadsDatasource) hostname(node string) string {
+	cfg := w.Config
+
+	name := strings.ToLower(strings.TrimSuffix(node, "."))
+	if len(name) > 0 && len(cfg.Workloads.Domain) > 0 && !strings.Contains(name, ".") {
+		name += "." + strings.Trim(strings.ToLower(cfg.Workloads.Domain), ".")
+	}
+
+	return name
+}
+
+// GetAllRecords acquires all available host records. Every service instance or allocation is converted into a host record,
+// instances of the same service or allocations of the same task group running on a node produce a single record.
+func (w *WorkloadsDatasource) GetAllRecords() ([]*DatasourceRecord, error) {
+	cfg := w.Config
+	log := w.Logger
+
+	var workloads []*workload
+	var err error
+	if cfg.Datasource == NomadDatasourceType {
+		workloads, err = w.nomadWorkloads()
+	} else {
+		workloads, err = w.consulWorkloads()
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s datasource failure", cfg.Datasource)
+	}
+
+	sort.SliceStable(workloads, func(i, j int) bool {
+		return workloads[i].Source < workloads[j].Source
+	})
+
+	seen := make(map[string]bool)
+	records := make([]*DatasourceRecord, 0, len(workloads))
+	for _, wl := range workloads {
+		host := w.hostname(wl.Node)
+		if len(host) == 0 {
+			log.Warnf("skipping %s workload without a node name: %s", cfg.Datasource, wl.Source)
+			continue
+		}
+
+		attrs, err := mappedAttributes(cfg, func(key string) string {
+			switch {
+			case strings.EqualFold(key, cfg.Txt.Keys.Role):
+				return wl.Role
+			case strings.EqualFold(key, cfg.Txt.Keys.Srv):
+				return wl.Srv
+			}
+
+			switch meta := lookupFold(cfg.Workloads.Meta, key); meta {
+			case "":
+				return ""
+			case workloadDatacenterMeta:
+				return wl.Datacenter
+			case workloadNamespaceMeta:
+				return wl.Namespace
+			default:
+				return wl.Meta[meta]
+			}
+		}, cfg.Workloads.Defaults)
+		if err != nil {
+			log.Warnf("skipping %s workload: %s: %v", cfg.Datasource, wl.Source, err)
+			continue
+		}
+
+		if key := host + "\x00" + attrs; !seen[key] {
+			seen[key] = true
+			records = append(records, &DatasourceRecord{Hostname: host, Attributes: attrs, Source: wl.Source})
+		}
+	}
+
+	return records, nil
+}
+
+// GetHostRecords returns the records derived from the workloads running on a specific host. An error is returned if there are none.
+// All workloads are listed, so this is as expensive as acquiring all records.
+func (w *WorkloadsDatasource) GetHostRecords(host string) ([]*DatasourceRecord, error) {
+	all, err := w.GetAllRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*DatasourceRecord, 0)
+	for _, r := range all {
+		if strings.EqualFold(r.Hostname, host) {
+			records = append(records, r)
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, errors.Wrap(ErrNoHostRecords, host)
+	}
+
+	return records, nil
+}
+
+// PublishRecords is not supported: workloads are registered by Consul agents and scheduled by Nomad.
+func (w *WorkloadsDatasource) PublishRecords(records []*DatasourceRecord) error {
+	return errors.Errorf("publishing records is not supported by the %s datasource", w.Config.Datasource)
+}
+
+// Close closes idle connections to the Consul or Nomad API. The datasource remains usable.
+func (w *WorkloadsDatasource) Close() {
+	w.Client.CloseIdleConnections()
+}
+
+// NewWorkloadsDatasource creates a Consul or Nomad datasource, depending on the datasource type.
+func NewWorkloadsDatasource(cfg *Config, log Logger) (*WorkloadsDatasource, error) {
+	address := cfg.Workloads.Consul.Address
+	if cfg.Datasource == NomadDatasourceType {
+		address = cfg.Workloads.Nomad.Address
+	}
+
+	if u, err := url.Parse(address); err != nil || len(u.Host) == 0 {
+		return nil, errors.Errorf("%s datasource initialization failure: invalid address: %s", cfg.Datasource, address)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.Workloads.TLS.Insecure}
+	if len(cfg.Workloads.TLS.CA) > 0 {
+		pool, err := tlsCAPoolFromFile(cfg.Workloads.TLS.CA)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s datasource initialization failure", cfg.Datasource)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &WorkloadsDatasource{
+		Config: cfg,
+		Logger: log,
+		Client: &http.Client{Transport: transport, Timeout: cfg.Workloads.Timeout},
+	}, nil
+}
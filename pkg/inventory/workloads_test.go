@@ -0,0 +1,192 @@
+package inventory
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testWorkloads emulates the Consul catalog and health APIs and the Nomad allocations and node APIs. Nomad allocations are served in pages of two.
+func testWorkloads(t *testing.T) string {
+	type entry = map[string]interface{}
+
+	services := map[string][]entry{
+		"consul": {
+			{"Node": entry{"Node": "srv01", "Datacenter": "dc1"}, "Service": entry{"ID": "consul", "Service": "consul"}},
+		},
+		"web": {
+			{"Node": entry{"Node": "web01", "Datacenter": "dc1", "Meta": entry{"env": "prod"}}, "Service": entry{"ID": "web-1", "Service": "web", "Tags": []string{"srv-nginx", "primary"}}},
+			{"Node": entry{"Node": "web01", "Datacenter": "dc1", "Meta": entry{"env": "prod"}}, "Service": entry{"ID": "web-2", "Service": "web", "Tags": []string{"srv-nginx"}}},
+			{"Node": entry{"Node": "web02", "Datacenter": "dc1", "Meta": entry{"env": "dev"}}, "Service": entry{"ID": "web-3", "Service": "web", "Tags": []string{"srv-nginx"}}, "Failing": true},
+		},
+		"db": {
+			{"Node": entry{"Node": "DB01.infra.local", "Datacenter": "dc1"}, "Service": entry{"ID": "db-1", "Service": "db", "Tags": []string{"srv-postgres"}}},
+		},
+	}
+
+	allocations := []entry{
+		{"ID": "a1", "Namespace": "default", "NodeID": "n1", "NodeName": "worker01", "JobID": "api", "TaskGroup": "http", "ClientStatus": "running"},
+		{"ID": "a2", "Namespace": "default", "NodeID": "n1", "NodeName": "worker01", "JobID": "api", "TaskGroup": "http", "ClientStatus": "running"},
+		{"ID": "a3", "Namespace": "batch", "NodeID": "n2", "NodeName": "worker02", "JobID": "reports", "TaskGroup": "render", "ClientStatus": "running"},
+		{"ID": "a4", "Namespace": "default", "NodeID": "n2", "NodeName": "worker02", "JobID": "api", "TaskGroup": "http", "ClientStatus": "complete"},
+	}
+
+	nodes := map[string]entry{
+		"n1": {"Name": "worker01", "Datacenter": "dc1", "Meta": entry{"env": "prod"}},
+		"n2": {"Name": "worker02", "Datacenter": "dc2", "Meta": entry{}},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		switch {
+		case r.URL.Path == "/v1/catalog/services":
+			if r.Header.Get("X-Consul-Token") != "consul-token" {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte("ACL not found"))
+				return
+			}
+
+			catalog := make(map[string][]string)
+			for name := range services {
+				catalog[name] = []string{}
+			}
+			json.NewEncoder(w).Encode(catalog)
+		case len(r.URL.Path) > len("/v1/health/service/") && r.URL.Path[:len("/v1/health/service/")] == "/v1/health/service/":
+			result := make([]entry, 0)
+			for _, e := range services[r.URL.Path[len("/v1/health/service/"):]] {
+				if query.Get("passing") == "true" && e["Failing"] == true {
+					continue
+				}
+				result = append(result, e)
+			}
+			json.NewEncoder(w).Encode(result)
+		case r.URL.Path == "/v1/allocations":
+			if query.Get("namespace") != "*" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			page := allocations[:2]
+			if query.Get("next_token") == "a3" {
+				page = allocations[2:]
+			} else {
+				w.Header().Set("X-Nomad-NextToken", "a3")
+			}
+			json.NewEncoder(w).Encode(page)
+		case r.URL.Path == "/v1/node/n1" || r.URL.Path == "/v1/node/n2":
+			json.NewEncoder(w).Encode(nodes[r.URL.Path[len("/v1/node/"):]])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+func TestWorkloadsDatasource_GetAllRecords(t *testing.T) {
+	address := testWorkloads(t)
+
+	tests := []struct {
+		name       string
+		datasource string
+		token      string
+		passing    bool
+		meta       map[string]string
+		want       []*DatasourceRecord
+		wantErr    bool
+	}{
+		{
+			// Instances of a service on the same node produce a single record.
+			name:       "valid-consul",
+			datasource: ConsulDatasourceType,
+			token:      "consul-token",
+			meta:       map[string]string{"env": "env"},
+			want: []*DatasourceRecord{
+				{Hostname: "db01.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=db;SRV=postgres;VARS=", Source: "consul/dc1/DB01.infra.local/db-1"},
+				{Hostname: "web01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=web;SRV=nginx;VARS=", Source: "consul/dc1/web01/web-1"},
+				{Hostname: "web02.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=web;SRV=nginx;VARS=", Source: "consul/dc1/web02/web-3"},
+			},
+		},
+		{
+			name:       "valid-consul-passing",
+			datasource: ConsulDatasourceType,
+			token:      "consul-token",
+			passing:    true,
+			meta:       map[string]string{"env": "@datacenter"},
+			want: []*DatasourceRecord{
+				{Hostname: "db01.infra.local", Attributes: "OS=linux;ENV=dc1;ROLE=db;SRV=postgres;VARS=", Source: "consul/dc1/DB01.infra.local/db-1"},
+				{Hostname: "web01.infra.local", Attributes: "OS=linux;ENV=dc1;ROLE=web;SRV=nginx;VARS=", Source: "consul/dc1/web01/web-1"},
+			},
+		},
+		{
+			name:       "invalid-consul-token",
+			datasource: ConsulDatasourceType,
+			token:      "wrong",
+			wantErr:    true,
+		},
+		{
+			name:       "valid-nomad",
+			datasource: NomadDatasourceType,
+			meta:       map[string]string{"env": "env"},
+			want: []*DatasourceRecord{
+				{Hostname: "worker02.infra.local", Attributes: "OS=linux;ENV=dev;ROLE=reports;SRV=render;VARS=", Source: "nomad/batch/a3"},
+				{Hostname: "worker01.infra.local", Attributes: "OS=linux;ENV=prod;ROLE=api;SRV=http;VARS=", Source: "nomad/default/a1"},
+			},
+		},
+		{
+			// Nodes are not read if only the namespace is mapped.
+			name:       "valid-nomad-namespace",
+			datasource: NomadDatasourceType,
+			meta:       map[string]string{"env": "@namespace"},
+			want: []*DatasourceRecord{
+				{Hostname: "worker02.infra.local", Attributes: "OS=linux;ENV=batch;ROLE=reports;SRV=render;VARS=", Source: "nomad/batch/a3"},
+				{Hostname: "worker01.infra.local", Attributes: "OS=linux;ENV=default;ROLE=api;SRV=http;VARS=", Source: "nomad/default/a1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.Datasource = tt.datasource
+			cfg.Txt.Keys.Os = "OS"
+			cfg.Txt.Keys.Env = "ENV"
+			cfg.Txt.Keys.Role = "ROLE"
+			cfg.Txt.Keys.Srv = "SRV"
+			cfg.Txt.Keys.Vars = "VARS"
+			cfg.Txt.Keys.ID = "ID"
+			cfg.Txt.Kv.Separator = ";"
+			cfg.Txt.Kv.Equalsign = "="
+			cfg.Workloads.Domain = "infra.local"
+			cfg.Workloads.Meta = tt.meta
+			cfg.Workloads.Defaults = map[string]string{"os": "linux", "env": "dev"}
+			cfg.Workloads.Timeout = 5 * time.Second
+			cfg.Workloads.Consul.Address = address
+			cfg.Workloads.Consul.Token = tt.token
+			cfg.Workloads.Consul.Exclude = []string{"consul"}
+			cfg.Workloads.Consul.Passing = tt.passing
+			cfg.Workloads.Consul.TagPrefix = "srv-"
+			cfg.Workloads.Nomad.Address = address
+			cfg.Workloads.Nomad.Namespace = "*"
+
+			w, err := NewWorkloadsDatasource(cfg, &testLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+
+			got, err := w.GetAllRecords()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WorkloadsDatasource.GetAllRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WorkloadsDatasource.GetAllRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}